@@ -14,6 +14,12 @@ mkdir -p bin
 
 # Build daemon
 echo -e "${BLUE}Building Go daemon...${NC}"
+# The daemon is built from daemon/src only; stale copies beside it drift
+if compgen -G "daemon/*.go" >/dev/null; then
+    echo -e "${RED}❌ Go sources found in daemon/ - the daemon lives in daemon/src:${NC}"
+    ls daemon/*.go
+    exit 1
+fi
 # Run go mod tidy first to ensure dependencies are up to date
 if cd daemon/src && go mod tidy >/dev/null 2>&1; then
     if go build -o ../../bin/port42d .; then
@@ -316,6 +316,22 @@ impl InfoResponse {
             println!("  {} {}", "Agent:".cyan(), agent.bright_cyan());
         }
         
+        // Provenance: how it was made, in which session, from what prompt
+        if let Some(source) = data["source"].as_str() {
+            println!("\n{}", "Provenance:".bright_green().bold());
+            println!("  {} {}", "Source:".cyan(), source.yellow());
+            if let Some(session) = data["session"].as_str() {
+                if !session.is_empty() {
+                    println!("  {} {}", "Session:".cyan(), session.dimmed());
+                }
+            }
+            if let Some(prompt) = data["user_prompt"].as_str() {
+                if !prompt.is_empty() {
+                    println!("  {} {}", "Prompt:".cyan(), prompt);
+                }
+            }
+        }
+        
         // Tags
         if let Some(tags) = data["tags"].as_array() {
             if !tags.is_empty() {
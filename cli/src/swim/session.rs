@@ -52,9 +52,11 @@ impl SessionHandler {
         let request_id = generate_id();
         let mut request = swim_req.build_request(request_id)?;
         
-        // Add session_id to payload
+        // Add session_id to payload. The CLI only names sessions it is
+        // continuing, including ones an earlier interactive run ended.
         if let Some(obj) = request.payload.as_object_mut() {
             obj.insert("session_id".to_string(), serde_json::Value::String(session_id.to_string()));
+            obj.insert("reopen".to_string(), serde_json::Value::Bool(true));
         }
         
         // Show wave spinner while waiting for response
@@ -140,6 +142,7 @@ impl SessionHandler {
             // Add session_id to payload
             if let Some(obj) = request.payload.as_object_mut() {
                 obj.insert("session_id".to_string(), serde_json::Value::String(session_id.to_string()));
+                obj.insert("reopen".to_string(), serde_json::Value::Bool(true));
             }
             
             // Send approval and get new response
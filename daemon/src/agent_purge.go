@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PurgedRelation is a relation purge_agent deletes
+type PurgedRelation struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// PurgedObject is an object purge_agent strips of its paths, or deletes
+// with delete_blobs
+type PurgedObject struct {
+	ID    string   `json:"id"`
+	Type  string   `json:"type,omitempty"`
+	Title string   `json:"title,omitempty"`
+	Paths []string `json:"paths"`
+	Size  int64    `json:"size"`
+}
+
+// PurgedSession is a session purge_agent drops from the session index and
+// from memory
+type PurgedSession struct {
+	SessionID string `json:"session_id"`
+	State     string `json:"state,omitempty"`
+	Versions  int    `json:"versions"` // Stored session objects
+	InMemory  bool   `json:"in_memory,omitempty"`
+}
+
+// AgentPurgeReport lists what belongs to an agent and, once confirmed,
+// what purging it did
+type AgentPurgeReport struct {
+	Agent       string           `json:"agent"` // Normalized: lowercase, with the @
+	DryRun      bool             `json:"dry_run"`
+	DeleteBlobs bool             `json:"delete_blobs"`
+	Relations   []PurgedRelation `json:"relations"`
+	Objects     []PurgedObject   `json:"objects"`
+	Sessions    []PurgedSession  `json:"sessions"`
+
+	DeletedRelations []string `json:"deleted_relations"`
+	OrphanedChildren []string `json:"orphaned_children,omitempty"` // Other agents' relations a purged one spawned
+	RemovedSymlinks  []string `json:"removed_symlinks"`
+	RemovedPaths     []string `json:"removed_paths"`
+	DeletedObjects   []string `json:"deleted_objects"`
+	KeptObjects      []string `json:"kept_objects,omitempty"` // Something that isn't purged still refers to them
+	FreedBytes       int64    `json:"freed_bytes"`
+	Errors           []string `json:"errors,omitempty"` // Steps that failed; the rest went ahead
+}
+
+// sameAgent reports whether agent names the same agent as name, which has
+// no @; the prefix and case don't matter
+func sameAgent(agent, name string) bool {
+	return agent != "" && strings.EqualFold(strings.TrimPrefix(strings.TrimSpace(agent), "@"), name)
+}
+
+// purgeAgent removes everything whose agent is the given one: its
+// relations (dematerialized, so their symlinks go), the command symlinks
+// and /tools paths of its tools, its sessions, and the paths of its
+// objects, which are left deprecated the way delete_path leaves them. With
+// deleteBlobs the objects themselves, the sessions' stored versions and the
+// tools' executables are deleted too, unless something that isn't purged
+// still refers to them. Without confirm nothing changes and the report
+// lists what would be removed.
+func (d *Daemon) purgeAgent(agent string, deleteBlobs, confirm bool) (*AgentPurgeReport, error) {
+	s := d.storage
+	name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(agent), "@"))
+	report := &AgentPurgeReport{
+		Agent:            "@" + name,
+		DryRun:           !confirm,
+		DeleteBlobs:      deleteBlobs,
+		Relations:        []PurgedRelation{},
+		Objects:          []PurgedObject{},
+		Sessions:         []PurgedSession{},
+		DeletedRelations: []string{},
+		RemovedSymlinks:  []string{},
+		RemovedPaths:     []string{},
+		DeletedObjects:   []string{},
+	}
+
+	var relations []Relation
+	if s.relationStore != nil {
+		var err error
+		if relations, err = s.relationStore.List(); err != nil {
+			return nil, fmt.Errorf("failed to load relations: %w", err)
+		}
+	}
+	purged := make(map[string]bool)
+	for _, relation := range relations {
+		if owner, _ := relation.Properties["agent"].(string); sameAgent(owner, name) {
+			report.Relations = append(report.Relations, PurgedRelation{ID: relation.ID, Type: relation.Type, Name: getRelationName(relation)})
+			purged[relation.ID] = true
+		}
+	}
+
+	// Sessions: those in the index or in memory, plus any only their stored versions know of
+	sessions := make(map[string]*PurgedSession)
+	s.indexMutex.RLock()
+	for sessionID, ref := range s.sessionIndex.Sessions {
+		if sameAgent(ref.Agent, name) {
+			sessions[sessionID] = &PurgedSession{SessionID: sessionID, State: ref.State}
+		}
+	}
+	s.indexMutex.RUnlock()
+	d.mu.RLock()
+	for sessionID, session := range d.sessions {
+		session.mu.Lock()
+		if sameAgent(session.Agent, name) {
+			if sessions[sessionID] == nil {
+				sessions[sessionID] = &PurgedSession{SessionID: sessionID, State: string(session.State)}
+			}
+			sessions[sessionID].InMemory = true
+		}
+		session.mu.Unlock()
+	}
+	d.mu.RUnlock()
+
+	docs, _ := s.searchIndex.snapshot("", "")
+	for _, meta := range docs {
+		if !sameAgent(meta.Agent, name) {
+			continue
+		}
+		if meta.Type == "session" && meta.Session != "" {
+			if sessions[meta.Session] == nil {
+				sessions[meta.Session] = &PurgedSession{SessionID: meta.Session}
+			}
+			sessions[meta.Session].Versions++
+		}
+		report.Objects = append(report.Objects, PurgedObject{
+			ID:    meta.ID,
+			Type:  meta.Type,
+			Title: meta.Title,
+			Paths: cloneStrings(meta.Paths),
+			Size:  meta.Size,
+		})
+	}
+	for _, session := range sessions {
+		report.Sessions = append(report.Sessions, *session)
+	}
+	sort.Slice(report.Relations, func(i, j int) bool { return report.Relations[i].ID < report.Relations[j].ID })
+	sort.Slice(report.Objects, func(i, j int) bool { return report.Objects[i].ID < report.Objects[j].ID })
+	sort.Slice(report.Sessions, func(i, j int) bool { return report.Sessions[i].SessionID < report.Sessions[j].SessionID })
+
+	if !confirm {
+		logger.Infof("🧹 [PURGE] Would purge %s: %d relations, %d objects, %d sessions",
+			report.Agent, len(report.Relations), len(report.Objects), len(report.Sessions))
+		return report, nil
+	}
+	if len(report.Relations) > 0 && d.realityCompiler == nil {
+		return nil, fmt.Errorf("reality compiler not initialized")
+	}
+	fail := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		logger.Warnf("⚠️ [PURGE] %s", message)
+		report.Errors = append(report.Errors, message)
+	}
+
+	// In-memory sessions first, so nothing saves them back
+	d.mu.Lock()
+	for _, session := range report.Sessions {
+		if session.InMemory {
+			delete(d.sessions, session.SessionID)
+		}
+	}
+	d.mu.Unlock()
+	for _, session := range report.Sessions {
+		s.dequeueSessionSave(session.SessionID)
+	}
+
+	// Relations, then the commands and /tools paths of tools nothing else is named
+	executables := make(map[string]bool)
+	byID := make(map[string]Relation, len(relations))
+	for _, relation := range relations {
+		byID[relation.ID] = relation
+	}
+	for _, purgedRelation := range report.Relations {
+		deleted, err := d.realityCompiler.DeleteRelationCascade(purgedRelation.ID, false)
+		if err != nil {
+			fail("failed to delete relation %s: %v", purgedRelation.ID, err)
+			continue
+		}
+		report.DeletedRelations = append(report.DeletedRelations, deleted.DeletedRelations...)
+		report.RemovedSymlinks = append(report.RemovedSymlinks, deleted.RemovedPaths...)
+		for _, child := range deleted.OrphanedChildren {
+			if !purged[child] {
+				report.OrphanedChildren = append(report.OrphanedChildren, child)
+			}
+		}
+		relation := byID[purgedRelation.ID]
+		if executableID, _ := relation.Properties["executable_id"].(string); executableID != "" {
+			executables[executableID] = true
+		}
+		for _, version := range toolVersions(relation) {
+			executables[version.ObjectID] = true
+		}
+	}
+
+	var remaining []Relation
+	if s.relationStore != nil {
+		var err error
+		if remaining, err = s.relationStore.List(); err != nil {
+			return report, fmt.Errorf("failed to load relations: %w", err)
+		}
+	}
+	for _, purgedRelation := range report.Relations {
+		toolName := purgedRelation.Name
+		if purgedRelation.Type != "Tool" || toolName == "" || len(toolsNamed(remaining, toolName)) > 0 {
+			continue
+		}
+		link := filepath.Join(s.commandsDir(), toolName)
+		if _, err := os.Lstat(link); err == nil {
+			if err := s.removeCommandFiles(toolName); err != nil {
+				fail("failed to remove command %s: %v", toolName, err)
+			} else {
+				report.RemovedSymlinks = append(report.RemovedSymlinks, link)
+			}
+		} else {
+			os.Remove(s.commandStorePath(toolName))
+		}
+		paths, holders, err := s.removeToolPaths(toolName)
+		if err != nil {
+			fail("failed to remove paths of %s: %v", toolName, err)
+			continue
+		}
+		report.RemovedPaths = append(report.RemovedPaths, paths...)
+		for _, id := range holders {
+			executables[id] = true
+		}
+	}
+
+	s.purgeAgentSessions(name, report, fail)
+	s.purgeAgentObjects(report, remaining, executables, fail)
+
+	sort.Strings(report.RemovedPaths)
+	logger.Infof("🧹 [PURGE] Purged %s: %d relations, %d sessions, %d symlinks, %d paths, %d objects (%d bytes) removed",
+		report.Agent, len(report.DeletedRelations), len(report.Sessions), len(report.RemovedSymlinks),
+		len(report.RemovedPaths), len(report.DeletedObjects), report.FreedBytes)
+	return report, nil
+}
+
+// purgeAgentSessions drops the report's sessions from the session index
+// and last-session tracking
+func (s *Storage) purgeAgentSessions(name string, report *AgentPurgeReport, fail func(string, ...interface{})) {
+	if len(report.Sessions) == 0 {
+		return
+	}
+
+	s.indexMutex.Lock()
+	for _, session := range report.Sessions {
+		delete(s.sessionIndex.Sessions, session.SessionID)
+		for agent, sessionID := range s.sessionIndex.LastSessions {
+			if sessionID == session.SessionID {
+				delete(s.sessionIndex.LastSessions, agent)
+			}
+		}
+	}
+	s.updateStats()
+	err := s.saveSessionIndex()
+	s.indexMutex.Unlock()
+	if err != nil {
+		fail("failed to save session index: %v", err)
+	}
+
+	for _, session := range report.Sessions {
+		if s.agentSessions != nil {
+			if err := s.agentSessions.ClearLastSession(name, session.SessionID); err != nil {
+				fail("failed to clear last session of %s: %v", report.Agent, err)
+			}
+		}
+		s.unsavedMu.Lock()
+		delete(s.unsaved, session.SessionID)
+		s.unsavedMu.Unlock()
+	}
+}
+
+// purgeAgentObjects strips the report's objects of their paths and command
+// symlinks, then with delete_blobs deletes them and the purged tools'
+// executables where nothing left refers to them
+func (s *Storage) purgeAgentObjects(report *AgentPurgeReport, remaining []Relation, executables map[string]bool, fail func(string, ...interface{})) {
+	sessionVersions := []string{}
+	for _, object := range report.Objects {
+		for _, path := range object.Paths {
+			if cmdName, ok := strings.CutPrefix(path, "/commands/"); ok && s.commandObjectID(cmdName) == object.ID {
+				if err := s.removeCommandFiles(cmdName); err != nil {
+					fail("failed to remove command %s: %v", cmdName, err)
+				} else {
+					report.RemovedSymlinks = append(report.RemovedSymlinks, filepath.Join(s.commandsDir(), cmdName))
+				}
+			}
+		}
+
+		unlock := s.metadataLocks.Lock(object.ID)
+		meta, err := s.LoadMetadata(object.ID)
+		if err != nil {
+			unlock()
+			fail("failed to load metadata for %s: %v", shortID(object.ID), err)
+			continue
+		}
+		report.RemovedPaths = append(report.RemovedPaths, meta.Paths...)
+		meta.Paths = []string{}
+		meta.Lifecycle = "deprecated"
+		err = s.SaveMetadata(meta)
+		unlock()
+		if err != nil {
+			fail("failed to update metadata for %s: %v", shortID(object.ID), err)
+			continue
+		}
+
+		if object.Type == "session" {
+			sessionVersions = append(sessionVersions, object.ID)
+		} else {
+			executables[object.ID] = true
+		}
+	}
+	if !report.DeleteBlobs {
+		return
+	}
+
+	deleted, kept, freed, err := s.deleteUnreferenced(executables)
+	report.DeletedObjects = append(report.DeletedObjects, deleted...)
+	report.KeptObjects = append(report.KeptObjects, kept...)
+	report.FreedBytes += freed
+	if err != nil {
+		fail("%v", err)
+	}
+
+	// GC keeps every session version, so these are checked against what's left directly
+	referenced := make(map[string]bool)
+	s.forEachReference(remaining, func(id string) { referenced[id] = true })
+	for _, id := range sessionVersions {
+		meta := s.searchIndex.get(id)
+		if referenced[id] || (meta != nil && referenced[meta.Session]) {
+			report.KeptObjects = append(report.KeptObjects, id)
+			continue
+		}
+		objectFreed, err := s.deleteObject(id)
+		if err != nil {
+			fail("failed to delete object %s: %v", shortID(id), err)
+			continue
+		}
+		report.DeletedObjects = append(report.DeletedObjects, id)
+		report.FreedBytes += objectFreed
+	}
+	sort.Strings(report.DeletedObjects)
+	sort.Strings(report.KeptObjects)
+}
+
+// handlePurgeAgent removes everything an agent created (dry run unless
+// confirm is true)
+func (d *Daemon) handlePurgeAgent(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		Agent       string `json:"agent"`                  // With or without the @
+		DeleteBlobs bool   `json:"delete_blobs,omitempty"` // Also delete the objects, not just their paths
+		Confirm     bool   `json:"confirm,omitempty"`      // Dry run unless true
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	name := strings.TrimPrefix(strings.TrimSpace(payload.Agent), "@")
+	if name == "" || strings.ContainsAny(name, "/@") {
+		return NewErrorResponse(req.ID, "agent is required, e.g. @ai-muse")
+	}
+
+	report, err := d.purgeAgent(name, payload.DeleteBlobs, payload.Confirm)
+	if err != nil {
+		return NewErrorResponse(req.ID, "Failed to purge agent: "+err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(report)
+	return resp
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -214,7 +215,7 @@ func GetAgentPrompt(agentName string) string {
 	}
 	
 	// Debug logging
-	log.Printf("🔍 Building prompt for %s, personality: %s, style: %s", 
+	logger.Debugf("🔍 Building prompt for %s, personality: %s, style: %s", 
 		agentName, agent.Personality, agent.Style)
 	
 	return prompt.String()
@@ -222,7 +223,7 @@ func GetAgentPrompt(agentName string) string {
 
 // GetModelForAgent returns the model configuration for a specific agent
 func GetModelForAgent(agentName string) (*ModelDefinition, error) {
-	log.Printf("🔍 GetModelForAgent called with: %s", agentName)
+	logger.Debugf("🔍 GetModelForAgent called with: %s", agentName)
 	
 	if agentConfig == nil {
 		return nil, fmt.Errorf("agent configuration not loaded")
@@ -231,7 +232,7 @@ func GetModelForAgent(agentName string) (*ModelDefinition, error) {
 	// Normalize agent name (remove @ prefix if present)
 	cleanName := strings.TrimPrefix(agentName, "@")
 	cleanName = strings.Replace(cleanName, "ai-", "", 1)
-	log.Printf("🔍 Normalized agent name: %s -> %s", agentName, cleanName)
+	logger.Debugf("🔍 Normalized agent name: %s -> %s", agentName, cleanName)
 	
 	// Find the agent
 	agent, exists := agentConfig.Agents[cleanName]
@@ -239,13 +240,13 @@ func GetModelForAgent(agentName string) (*ModelDefinition, error) {
 		log.Printf("❌ Agent %s not found in config", cleanName)
 		return nil, fmt.Errorf("agent %s not found", agentName)
 	}
-	log.Printf("🔍 Found agent config: Model=%s", agent.Model)
+	logger.Debugf("🔍 Found agent config: Model=%s", agent.Model)
 	
 	// Get the model for this agent (use default if not specified)
 	modelKey := agent.Model
 	if modelKey == "" {
 		modelKey = agentConfig.DefaultModel
-		log.Printf("🔍 Using default model: %s", modelKey)
+		logger.Debugf("🔍 Using default model: %s", modelKey)
 	}
 	
 	// Look up the model definition
@@ -254,7 +255,7 @@ func GetModelForAgent(agentName string) (*ModelDefinition, error) {
 		log.Printf("❌ Model %s not found in models registry", modelKey)
 		return nil, fmt.Errorf("model %s not found", modelKey)
 	}
-	log.Printf("🔍 Found model definition: ID=%s, Name=%s", model.ID, model.Name)
+	logger.Debugf("🔍 Found model definition: ID=%s, Name=%s", model.ID, model.Name)
 	
 	// Create a copy to avoid modifying the original
 	modelCopy := model
@@ -262,13 +263,74 @@ func GetModelForAgent(agentName string) (*ModelDefinition, error) {
 	// Apply temperature override if specified
 	if agent.TemperatureOverride != nil {
 		modelCopy.Temperature = *agent.TemperatureOverride
-		log.Printf("🔍 Applied temperature override: %.2f", modelCopy.Temperature)
+		logger.Debugf("🔍 Applied temperature override: %.2f", modelCopy.Temperature)
 	}
 	
-	log.Printf("🔍 Returning model: ID=%s, Name=%s, Temp=%.2f", modelCopy.ID, modelCopy.Name, modelCopy.Temperature)
+	logger.Debugf("🔍 Returning model: ID=%s, Name=%s, Temp=%.2f", modelCopy.ID, modelCopy.Name, modelCopy.Temperature)
 	return &modelCopy, nil
 }
 
+// IsKnownAgent reports whether an agent name ("@ai-engineer", "engineer") is configured
+func IsKnownAgent(agentName string) bool {
+	if agentConfig == nil {
+		return false
+	}
+	cleanName := strings.TrimPrefix(agentName, "@")
+	cleanName = strings.Replace(cleanName, "ai-", "", 1)
+	_, exists := agentConfig.Agents[cleanName]
+	return exists
+}
+
+// KnownAgentNames returns the configured agents as sessions name them
+// (@ai-engineer), sorted; none when the configuration isn't loaded
+func KnownAgentNames() []string {
+	if agentConfig == nil {
+		return nil
+	}
+	names := make([]string, 0, len(agentConfig.Agents))
+	for key, agent := range agentConfig.Agents {
+		name := agent.Name
+		if name == "" {
+			name = "@ai-" + key
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// What a declare naming an agent that agents.json doesn't have gets
+// (PORT42_UNKNOWN_AGENTS)
+const (
+	UnknownAgentsWarn   = "warn"   // Declared anyway, with agent_warning in the response
+	UnknownAgentsReject = "reject" // Fails validation, listing the known agents
+)
+
+// defaultDeclareAgent is the agent of Tool relations declared without one,
+// unless PORT42_DEFAULT_AGENT says otherwise
+const defaultDeclareAgent = "@ai-engineer"
+
+// loadDefaultAgent reads PORT42_DEFAULT_AGENT, in the @ form sessions use
+func loadDefaultAgent() string {
+	agent := "@" + strings.TrimPrefix(envString("PORT42_DEFAULT_AGENT", defaultDeclareAgent), "@")
+	if agentConfig != nil && !IsKnownAgent(agent) {
+		log.Printf("⚠️ Default agent %s isn't in the agent configuration (known: %s)", agent, strings.Join(KnownAgentNames(), ", "))
+	}
+	return agent
+}
+
+// loadUnknownAgentsPolicy reads PORT42_UNKNOWN_AGENTS
+func loadUnknownAgentsPolicy() string {
+	policy := strings.ToLower(envString("PORT42_UNKNOWN_AGENTS", UnknownAgentsWarn))
+	switch policy {
+	case UnknownAgentsWarn, UnknownAgentsReject:
+		return policy
+	default:
+		log.Printf("⚠️ Unknown PORT42_UNKNOWN_AGENTS %q, using %s", policy, UnknownAgentsWarn)
+		return UnknownAgentsWarn
+	}
+}
+
 // GetResponseConfig returns the response configuration
 func GetResponseConfig() ResponseConfig {
 	if agentConfig == nil {
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// AIProvider generates text for tool materialization. The prompts are the
+// same for every provider (agent_guidance.md stays provider-agnostic); only
+// the transport and model differ.
+type AIProvider interface {
+	// Name identifies the provider in logs and explain output
+	Name() string
+	// Available reports whether the provider has credentials
+	Available() bool
+	// Generate sends messages with a system prompt and returns the reply text
+	Generate(ctx context.Context, messages []Message, systemPrompt string, agentName string) (string, error)
+}
+
+// Provider names accepted by PORT42_AI_PROVIDER
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+)
+
+// selectAIProvider picks the tool generation provider at startup, falling
+// back to Anthropic when the requested provider has no API key
+func selectAIProvider() AIProvider {
+	requested := strings.ToLower(envString("PORT42_AI_PROVIDER", ProviderAnthropic))
+
+	var provider AIProvider
+	switch requested {
+	case ProviderOpenAI:
+		openai := NewOpenAIClient()
+		if openai.Available() {
+			provider = openai
+		} else {
+			log.Printf("⚠️ PORT42_AI_PROVIDER=openai but PORT42_OPENAI_API_KEY is not set, falling back to anthropic")
+		}
+	case ProviderAnthropic:
+	default:
+		log.Printf("⚠️ Unknown PORT42_AI_PROVIDER %q, using anthropic", requested)
+	}
+
+	if provider == nil {
+		provider = &anthropicProvider{client: NewAnthropicClient()}
+	}
+
+	log.Printf("🤖 AI provider for tool generation: %s (available: %v)", provider.Name(), provider.Available())
+	return provider
+}
+
+// ==================== Anthropic ====================
+
+// anthropicProvider adapts AnthropicClient to AIProvider
+type anthropicProvider struct {
+	client *AnthropicClient
+}
+
+func (p *anthropicProvider) Name() string { return ProviderAnthropic }
+
+func (p *anthropicProvider) Available() bool { return p.client.apiKey != "" }
+
+func (p *anthropicProvider) Generate(ctx context.Context, messages []Message, systemPrompt string, agentName string) (string, error) {
+	response, err := p.client.SendWithoutToolsContext(ctx, messages, systemPrompt, agentName)
+	if err != nil {
+		return "", err
+	}
+	if len(response.Content) == 0 {
+		return "", nil
+	}
+	return response.Content[0].Text, nil
+}
+
+// ==================== OpenAI ====================
+
+// OpenAIClient talks to the OpenAI chat completions API (or a compatible
+// endpoint set with PORT42_OPENAI_BASE_URL)
+type OpenAIClient struct {
+	apiKey     string
+	baseURL    string
+	model      string
+	embedModel string
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// openAIRequest is a chat completions request
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Temperature float64         `json:"temperature,omitempty"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIResponse is the subset of a chat completions response we use
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewOpenAIClient creates an OpenAI client from PORT42_OPENAI_* settings
+func NewOpenAIClient() *OpenAIClient {
+	return &OpenAIClient{
+		apiKey:     os.Getenv("PORT42_OPENAI_API_KEY"),
+		baseURL:    strings.TrimSuffix(envString("PORT42_OPENAI_BASE_URL", "https://api.openai.com/v1"), "/"),
+		model:      envString("PORT42_OPENAI_MODEL", "gpt-4o"),
+		embedModel: envString("PORT42_OPENAI_EMBEDDING_MODEL", "text-embedding-3-small"),
+		httpClient: &http.Client{Timeout: 300 * time.Second},
+		retry:      loadRetryPolicy(),
+	}
+}
+
+func (c *OpenAIClient) Name() string { return ProviderOpenAI }
+
+func (c *OpenAIClient) Available() bool { return c.apiKey != "" }
+
+// Generate sends a chat completion and returns the first choice
+func (c *OpenAIClient) Generate(ctx context.Context, messages []Message, systemPrompt string, agentName string) (string, error) {
+	if c.apiKey == "" {
+		return "", fmt.Errorf("PORT42_OPENAI_API_KEY not set")
+	}
+
+	// Agent temperature and token limits still come from agents.json
+	temperature := 0.0
+	if modelDef, err := GetModelForAgent(agentName); err == nil {
+		temperature = modelDef.Temperature
+	}
+
+	req := openAIRequest{
+		Model:       c.model,
+		MaxTokens:   GetResponseConfig().MaxTokens,
+		Temperature: temperature,
+	}
+	if systemPrompt != "" {
+		req.Messages = append(req.Messages, openAIMessage{Role: "system", Content: systemPrompt})
+	}
+	for _, msg := range messages {
+		req.Messages = append(req.Messages, openAIMessage{Role: msg.Role, Content: msg.Content})
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Debugf("🔍 OpenAI API Request: model=%s, messages=%d, tokens=%d", req.Model, len(req.Messages), req.MaxTokens)
+
+	var reply string
+	err = c.retry.Do(ctx, "OpenAI", func(ctx context.Context) error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+		startTime := time.Now()
+		resp, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			log.Printf("❌ Network error after %v: %v", time.Since(startTime), err)
+			if ctx.Err() == nil {
+				return retryable(err, nil)
+			}
+			return err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		log.Printf("✅ OpenAI API responded in %v with status %d", time.Since(startTime), resp.StatusCode)
+
+		var openaiResp openAIResponse
+		if err := json.Unmarshal(body, &openaiResp); err != nil {
+			if retryableStatus(resp.StatusCode) {
+				return retryable(fmt.Errorf("API error: status %d", resp.StatusCode), resp)
+			}
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+
+		if openaiResp.Error != nil || resp.StatusCode != http.StatusOK {
+			message := fmt.Sprintf("status %d", resp.StatusCode)
+			if openaiResp.Error != nil {
+				message = openaiResp.Error.Type + " - " + openaiResp.Error.Message
+			}
+			apiErr := fmt.Errorf("API error: %s", message)
+			if retryableStatus(resp.StatusCode) {
+				return retryable(apiErr, resp)
+			}
+			return apiErr
+		}
+
+		if len(openaiResp.Choices) > 0 {
+			reply = openaiResp.Choices[0].Message.Content
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return reply, nil
+}
+
+// openAIEmbeddingRequest is an embeddings request for a batch of inputs
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// openAIEmbeddingResponse is the subset of an embeddings response we use
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (c *OpenAIClient) EmbeddingModel() string { return c.embedModel }
+
+// Embed returns one embedding per text, in order, from the embeddings endpoint
+func (c *OpenAIClient) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if c.apiKey == "" {
+		return nil, fmt.Errorf("PORT42_OPENAI_API_KEY not set")
+	}
+	if len(texts) == 0 {
+		return [][]float64{}, nil
+	}
+
+	jsonData, err := json.Marshal(openAIEmbeddingRequest{Model: c.embedModel, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	var vectors [][]float64
+	err = c.retry.Do(ctx, "OpenAI", func(ctx context.Context) error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+		resp, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			if ctx.Err() == nil {
+				return retryable(err, nil)
+			}
+			return err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		var embedResp openAIEmbeddingResponse
+		if err := json.Unmarshal(body, &embedResp); err != nil {
+			if retryableStatus(resp.StatusCode) {
+				return retryable(fmt.Errorf("API error: status %d", resp.StatusCode), resp)
+			}
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+
+		if embedResp.Error != nil || resp.StatusCode != http.StatusOK {
+			message := fmt.Sprintf("status %d", resp.StatusCode)
+			if embedResp.Error != nil {
+				message = embedResp.Error.Type + " - " + embedResp.Error.Message
+			}
+			apiErr := fmt.Errorf("API error: %s", message)
+			if retryableStatus(resp.StatusCode) {
+				return retryable(apiErr, resp)
+			}
+			return apiErr
+		}
+
+		vectors = make([][]float64, len(texts))
+		for _, item := range embedResp.Data {
+			if item.Index < 0 || item.Index >= len(texts) {
+				return fmt.Errorf("embedding index %d out of range", item.Index)
+			}
+			vectors[item.Index] = item.Embedding
+		}
+		for i, vector := range vectors {
+			if len(vector) == 0 {
+				return fmt.Errorf("no embedding returned for input %d", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
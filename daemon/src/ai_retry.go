@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for AI API retries. Attempts back off exponentially from the
+// base delay (2s, 4s, 8s, ...) up to the max delay, a Retry-After header
+// overrides a shorter backoff, and no retry is started that would run past
+// the call's timeout.
+const (
+	defaultAIMaxAttempts = 3
+	defaultAIBaseDelay   = 2 * time.Second
+	defaultAIMaxDelay    = 60 * time.Second
+	defaultAIJitter      = 0.2
+	defaultAIDeadline    = 10 * time.Minute
+)
+
+// ErrAITimeout is wrapped into errors from AI calls that ran out of time,
+// retries included, so callers can tell a timeout from an API failure
+var ErrAITimeout = errors.New("AI request timed out")
+
+// RetryPolicy controls how AI providers retry 429s, 5xx responses and
+// network errors
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64       // Fraction of each delay randomized, 0 to 1
+	Deadline    time.Duration // Limit for a call and all its retries; an earlier caller deadline still wins
+}
+
+// loadRetryPolicy reads the PORT42_AI_RETRY_* settings and PORT42_AI_TIMEOUT
+func loadRetryPolicy() RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: envInt("PORT42_AI_RETRY_ATTEMPTS", defaultAIMaxAttempts),
+		BaseDelay:   envPositiveDuration("PORT42_AI_RETRY_BASE_DELAY", defaultAIBaseDelay),
+		MaxDelay:    envPositiveDuration("PORT42_AI_RETRY_MAX_DELAY", defaultAIMaxDelay),
+		Jitter:      envFloat("PORT42_AI_RETRY_JITTER", defaultAIJitter),
+		Deadline:    envPositiveDuration("PORT42_AI_TIMEOUT", defaultAIDeadline),
+	}
+	if policy.MaxAttempts < 1 {
+		log.Printf("⚠️ PORT42_AI_RETRY_ATTEMPTS must be at least 1, using 1")
+		policy.MaxAttempts = 1
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		log.Printf("⚠️ PORT42_AI_RETRY_JITTER must be between 0 and 1, using %v", defaultAIJitter)
+		policy.Jitter = defaultAIJitter
+	}
+	return policy
+}
+
+// retryableError marks an attempt failure that may succeed if repeated
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+// retryable wraps err so Do retries it, carrying the response's Retry-After
+// hint if there is one. resp may be nil for network errors.
+func retryable(err error, resp *http.Response) error {
+	re := &retryableError{err: err}
+	if resp != nil {
+		re.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return re
+}
+
+// retryableStatus reports whether an HTTP status is worth retrying: rate
+// limits and server errors (including Anthropic's 529 overloaded)
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil && when.After(now) {
+		return when.Sub(now)
+	}
+	return 0
+}
+
+// withDeadline limits ctx to the policy timeout; a shorter deadline the
+// caller already set is kept
+func (p RetryPolicy) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, p.Deadline)
+}
+
+// backoff is the delay before the given retry (1 for the first retry)
+func (p RetryPolicy) backoff(retry int, retryAfter time.Duration) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	// The server knows better than our backoff curve
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// Do runs attempt until it succeeds, returns an error not wrapped with
+// retryable, runs out of attempts, or the next retry would pass the
+// deadline. provider names the API in retry logs. A deadline that expires
+// during an attempt or a backoff returns an error wrapping ErrAITimeout; a
+// retry skipped because its backoff would pass the deadline returns the API
+// error that prompted it.
+func (p RetryPolicy) Do(ctx context.Context, provider string, attempt func(ctx context.Context) error) error {
+	started := time.Now()
+	ctx, cancel := p.withDeadline(ctx)
+	defer cancel()
+
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %s API gave no answer within %v (%s): %w",
+				ErrAITimeout, provider, time.Since(started).Round(time.Millisecond), pluralize(n, "attempt"), err)
+		}
+		var re *retryableError
+		if err == nil || !errors.As(err, &re) {
+			return err
+		}
+		if n >= p.MaxAttempts {
+			if p.MaxAttempts == 1 {
+				return re.err
+			}
+			return fmt.Errorf("failed after %d attempts: %w", n, re.err)
+		}
+
+		delay := p.backoff(n, re.retryAfter)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			log.Printf("⏱️ Not retrying %s API, deadline is %v away but backoff is %v: %v",
+				provider, time.Until(deadline).Round(time.Millisecond), delay.Round(time.Millisecond), re.err)
+			return re.err
+		}
+
+		log.Printf("🔁 Retrying %s API after %v (attempt %d/%d): %v",
+			provider, delay.Round(time.Millisecond), n+1, p.MaxAttempts, re.err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w: %s API deadline passed while waiting to retry: %w", ErrAITimeout, provider, re.err)
+			}
+			return fmt.Errorf("%s API retry cancelled: %w", provider, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
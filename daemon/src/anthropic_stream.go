@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Streaming frames for possess. A streaming request gets zero or more chunk
+// frames carrying partial assistant text, then the usual response with
+// FrameComplete. All frames are newline-delimited JSON on the same connection.
+const (
+	FrameChunk    = "chunk"
+	FrameComplete = "complete"
+)
+
+// StreamChunk is the Data of a chunk frame
+type StreamChunk struct {
+	Content string `json:"content"`
+}
+
+// anthropicStreamEvent is one server-sent event from the messages API
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+	// content_block_start
+	ContentBlock *struct {
+		Type string `json:"type"`
+		Text string `json:"text,omitempty"`
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	} `json:"content_block,omitempty"`
+	// content_block_delta and message_delta
+	Delta *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text,omitempty"`
+		PartialJSON string `json:"partial_json,omitempty"`
+		StopReason  string `json:"stop_reason,omitempty"`
+	} `json:"delta,omitempty"`
+	Error *AnthropicError `json:"error,omitempty"`
+}
+
+// readAnthropicStream parses a streamed messages response, calling onText for
+// every text delta, and rebuilds the same AnthropicResponse a non-streaming
+// call would have returned. emitted reports whether any text reached onText,
+// since a request can only be retried before that happens.
+func readAnthropicStream(body io.Reader, onText func(string)) (resp *AnthropicResponse, emitted bool, err error) {
+	resp = &AnthropicResponse{}
+	toolInputs := make(map[int]*strings.Builder)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue // event: lines and keepalive blanks
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return nil, emitted, fmt.Errorf("failed to parse stream event: %v", err)
+		}
+
+		switch event.Type {
+		case "content_block_start":
+			if event.ContentBlock == nil {
+				continue
+			}
+			for len(resp.Content) <= event.Index {
+				resp.Content = append(resp.Content, AnthropicContentBlock{})
+			}
+			block := &resp.Content[event.Index]
+			block.Type = event.ContentBlock.Type
+			block.Text = event.ContentBlock.Text
+			block.ID = event.ContentBlock.ID
+			block.Name = event.ContentBlock.Name
+			if block.Type == "tool_use" {
+				toolInputs[event.Index] = &strings.Builder{}
+			}
+
+		case "content_block_delta":
+			if event.Delta == nil || event.Index >= len(resp.Content) {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				resp.Content[event.Index].Text += event.Delta.Text
+				if event.Delta.Text != "" {
+					onText(event.Delta.Text)
+					emitted = true
+				}
+			case "input_json_delta":
+				if input, ok := toolInputs[event.Index]; ok {
+					input.WriteString(event.Delta.PartialJSON)
+				}
+			}
+
+		case "message_delta":
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				resp.StopReason = event.Delta.StopReason
+			}
+
+		case "error":
+			if event.Error != nil {
+				return nil, emitted, fmt.Errorf("API error: %s - %s", event.Error.Type, event.Error.Message)
+			}
+			return nil, emitted, fmt.Errorf("API error in stream")
+
+		case "message_stop":
+			finishToolInputs(resp, toolInputs)
+			return resp, emitted, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, emitted, fmt.Errorf("stream interrupted: %v", err)
+	}
+	return nil, emitted, fmt.Errorf("stream ended before message_stop")
+}
+
+// finishToolInputs sets each tool_use block's input from its accumulated JSON
+func finishToolInputs(resp *AnthropicResponse, toolInputs map[int]*strings.Builder) {
+	for index, input := range toolInputs {
+		raw := input.String()
+		if raw == "" {
+			raw = "{}" // Tools with no arguments stream no input deltas
+		}
+		resp.Content[index].Input = json.RawMessage(raw)
+	}
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"port42/daemon/validation"
+)
+
+// Archive layout. Entries are written in this order so Import can decide
+// how to store each object (compressed or not) from its metadata:
+//
+//	port42-archive.json       manifest
+//	metadata/<id>.json        object metadata
+//	relations/<id>.json       relations
+//	session-index.json        session index
+//	objects/<id>              object content, uncompressed and unchunked
+const (
+	archiveManifestName  = "port42-archive.json"
+	archiveSessionIndex  = "session-index.json"
+	archiveFormatVersion = 1
+)
+
+// maxArchiveEntrySize guards against entries too large to hold in memory
+const maxArchiveEntrySize = 1 << 30
+
+// ArchiveManifest is the first entry of an export
+type ArchiveManifest struct {
+	FormatVersion int       `json:"format_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Objects       int       `json:"objects"`
+	Metadata      int       `json:"metadata"`
+	Relations     int       `json:"relations"`
+	Sessions      int       `json:"sessions"`
+}
+
+// ImportReport summarizes an Import
+type ImportReport struct {
+	Merge bool `json:"merge"`
+
+	ObjectsAdded    int `json:"objects_added"`
+	ObjectsExisting int `json:"objects_existing"`
+	MetadataWritten int `json:"metadata_written"`
+	MetadataKept    int `json:"metadata_kept"` // Merge: local metadata left as it was
+
+	RelationsAdded     int               `json:"relations_added"`
+	RelationsUnchanged int               `json:"relations_unchanged"`
+	RelationsReplaced  int               `json:"relations_replaced"`          // Restore: local relation overwritten
+	RelationsRenamed   map[string]string `json:"relations_renamed,omitempty"` // Merge: archive ID -> new ID
+
+	SessionsAdded    int `json:"sessions_added"`
+	CommandsRelinked int `json:"commands_relinked"`
+
+	// Entries that were refused, e.g. objects whose content doesn't hash to their ID
+	Rejected []string `json:"rejected,omitempty"`
+}
+
+// Export writes the store as a tar.gz: every object (decoded, so the archive
+// doesn't depend on compression or chunking settings), its metadata, all
+// relations and the session index.
+func (s *Storage) Export(w io.Writer) error {
+	ids, err := s.List()
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+	sort.Strings(ids)
+
+	metaFiles, err := s.metadataFiles()
+	if err != nil {
+		return err
+	}
+
+	var relations []Relation
+	if s.relationStore != nil {
+		if relations, err = s.relationStore.List(); err != nil {
+			return fmt.Errorf("failed to list relations: %w", err)
+		}
+		sort.Slice(relations, func(i, j int) bool { return relations[i].ID < relations[j].ID })
+	}
+
+	s.indexMutex.RLock()
+	indexData, err := json.MarshalIndent(s.sessionIndex, "", "  ")
+	sessions := 0
+	if s.sessionIndex != nil {
+		sessions = len(s.sessionIndex.Sessions)
+	}
+	s.indexMutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal session index: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	writeEntry := func(name string, data []byte) error {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	manifest, _ := json.MarshalIndent(ArchiveManifest{
+		FormatVersion: archiveFormatVersion,
+		ExportedAt:    now,
+		Objects:       len(ids),
+		Metadata:      len(metaFiles),
+		Relations:     len(relations),
+		Sessions:      sessions,
+	}, "", "  ")
+	if err := writeEntry(archiveManifestName, manifest); err != nil {
+		return err
+	}
+
+	for _, name := range metaFiles {
+		data, err := os.ReadFile(filepath.Join(s.metadataDir, name))
+		if err != nil {
+			log.Printf("⚠️ [EXPORT] Skipping metadata %s: %v", name, err)
+			continue
+		}
+		if err := writeEntry("metadata/"+name, data); err != nil {
+			return err
+		}
+	}
+
+	for _, relation := range relations {
+		data, err := json.MarshalIndent(relation, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal relation %s: %w", relation.ID, err)
+		}
+		if err := writeEntry("relations/"+relation.ID+".json", data); err != nil {
+			return err
+		}
+	}
+
+	if err := writeEntry(archiveSessionIndex, indexData); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		content, err := s.objects.Get(id)
+		if err != nil {
+			log.Printf("⚠️ [EXPORT] Skipping object %s: %v", shortID(id), err)
+			continue
+		}
+		if err := writeEntry("objects/"+id, content); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	log.Printf("📦 [EXPORT] Exported %d objects, %d metadata, %d relations, %d sessions",
+		len(ids), len(metaFiles), len(relations), sessions)
+	return nil
+}
+
+// metadataFiles lists the metadata JSON files, skipping atomic-write temporaries
+func (s *Storage) metadataFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.metadataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") || strings.Contains(name, ".tmp") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Import restores an archive written by Export. Every object's content is
+// hashed and must match its ID. Nothing local is deleted.
+//
+// With merge false the archive wins: its metadata, relations and session
+// index entries overwrite local ones with the same ID. With merge true local
+// data wins: objects and metadata already present are kept, identical
+// relations are skipped, and a relation whose ID is taken by a different
+// local relation is imported under a new ID (references to it from other
+// imported relations are updated to match).
+func (s *Storage) Import(r io.Reader, merge bool) (ImportReport, error) {
+	report := ImportReport{Merge: merge}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return report, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	metadata := make(map[string]*Metadata)
+	var relations []Relation
+	var index *SessionIndex
+	stored := make(map[string]bool)
+	sawManifest := false
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxArchiveEntrySize {
+			report.Rejected = append(report.Rejected, header.Name+": too large")
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return report, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+
+		name := path.Clean(header.Name)
+		dir, base := path.Split(name)
+		switch {
+		case name == archiveManifestName:
+			var manifest ArchiveManifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return report, fmt.Errorf("invalid archive manifest: %w", err)
+			}
+			if manifest.FormatVersion > archiveFormatVersion {
+				return report, fmt.Errorf("archive format %d is newer than this daemon supports (%d)", manifest.FormatVersion, archiveFormatVersion)
+			}
+			sawManifest = true
+
+		case dir == "metadata/" && strings.HasSuffix(base, ".json"):
+			var meta Metadata
+			if err := json.Unmarshal(data, &meta); err != nil || meta.ID != strings.TrimSuffix(base, ".json") {
+				report.Rejected = append(report.Rejected, name+": invalid metadata")
+				continue
+			}
+			metadata[meta.ID] = &meta
+
+		case dir == "relations/" && strings.HasSuffix(base, ".json"):
+			var relation Relation
+			if err := json.Unmarshal(data, &relation); err != nil || relation.ID == "" {
+				report.Rejected = append(report.Rejected, name+": invalid relation")
+				continue
+			}
+			relations = append(relations, relation)
+
+		case name == archiveSessionIndex:
+			var archived SessionIndex
+			if err := json.Unmarshal(data, &archived); err != nil {
+				report.Rejected = append(report.Rejected, name+": invalid session index")
+				continue
+			}
+			index = &archived
+
+		case dir == "objects/":
+			hash := sha256.Sum256(data)
+			if hex.EncodeToString(hash[:]) != base {
+				report.Rejected = append(report.Rejected, name+": content does not match its ID")
+				continue
+			}
+			if s.objects.Exists(base) {
+				report.ObjectsExisting++
+				stored[base] = true
+				continue
+			}
+			if _, err := s.store(data, shouldCompress(metadata[base])); err != nil {
+				return report, fmt.Errorf("failed to store object %s: %w", shortID(base), err)
+			}
+			report.ObjectsAdded++
+			stored[base] = true
+
+		default:
+			report.Rejected = append(report.Rejected, name+": unknown entry")
+		}
+	}
+
+	if !sawManifest {
+		return report, fmt.Errorf("not a Port 42 archive (no %s)", archiveManifestName)
+	}
+
+	// Metadata is only written for objects that are actually here
+	for id, meta := range metadata {
+		if !stored[id] && !s.objects.Exists(id) {
+			report.Rejected = append(report.Rejected, "metadata/"+id+".json: object missing")
+			continue
+		}
+		if merge {
+			if _, err := s.LoadMetadata(id); err == nil {
+				report.MetadataKept++
+				continue
+			}
+		}
+		if err := s.writeMetadata(meta); err != nil {
+			return report, fmt.Errorf("failed to write metadata %s: %w", shortID(id), err)
+		}
+		report.MetadataWritten++
+	}
+
+	if err := s.importRelations(relations, merge, &report); err != nil {
+		return report, err
+	}
+
+	if index != nil {
+		if err := s.importSessionIndex(index, merge, &report); err != nil {
+			return report, err
+		}
+	}
+
+	log.Printf("📦 [IMPORT] %d objects added (%d already present), %d metadata written, %d relations added, %d renamed, %d sessions added, %d rejected",
+		report.ObjectsAdded, report.ObjectsExisting, report.MetadataWritten, report.RelationsAdded,
+		len(report.RelationsRenamed), report.SessionsAdded, len(report.Rejected))
+	return report, nil
+}
+
+// importRelations saves archived relations, renaming conflicting ones in
+// merge mode, then relinks commands for imported tools that have none
+func (s *Storage) importRelations(relations []Relation, merge bool, report *ImportReport) error {
+	if len(relations) == 0 {
+		return nil
+	}
+	if s.relationStore == nil {
+		report.Rejected = append(report.Rejected, fmt.Sprintf("%d relations: relation store not available", len(relations)))
+		return nil
+	}
+
+	// Decide every relation's local ID first so references can be rewritten
+	renamed := make(map[string]string)
+	var toSave []Relation
+	for _, relation := range relations {
+		if reason := unsafeImportedRelation(relation); reason != "" {
+			report.Rejected = append(report.Rejected, "relations/"+relation.ID+".json: "+reason)
+			continue
+		}
+		existing, err := s.relationStore.Load(relation.ID)
+		switch {
+		case err != nil:
+			report.RelationsAdded++
+		case sameRelation(*existing, relation):
+			report.RelationsUnchanged++
+			continue
+		case !merge:
+			report.RelationsReplaced++
+		default:
+			newID := s.freeRelationID(relation.ID, renamed)
+			renamed[relation.ID] = newID
+			relation.ID = newID
+			report.RelationsAdded++
+		}
+		toSave = append(toSave, relation)
+	}
+
+	for _, relation := range toSave {
+		for _, key := range []string{"parent", "spawned_by"} {
+			if ref, ok := relation.Properties[key].(string); ok && renamed[ref] != "" {
+				relation.Properties[key] = renamed[ref]
+			}
+		}
+		if err := s.relationStore.Save(relation); err != nil {
+			return fmt.Errorf("failed to save relation %s: %w", relation.ID, err)
+		}
+		if relation.Type == "Tool" && s.relinkImportedCommand(relation) {
+			report.CommandsRelinked++
+		}
+	}
+
+	if len(renamed) > 0 {
+		report.RelationsRenamed = renamed
+	}
+	return nil
+}
+
+// unsafeImportedRelation says why an archived relation can't be imported:
+// its ID names the file it's saved to and a tool's name the command it's
+// linked as, so neither may reach outside its directory
+func unsafeImportedRelation(relation Relation) string {
+	if strings.ContainsAny(relation.ID, `/\`) || strings.Contains(relation.ID, "..") {
+		return "invalid relation ID"
+	}
+	if relation.Type == "Tool" {
+		if name, ok := relation.Properties["name"].(string); ok && !validation.ValidToolName(name) {
+			return fmt.Sprintf("invalid tool name %q", name)
+		}
+	}
+	return ""
+}
+
+// sameRelation reports whether two relations differ at most in UpdatedAt
+func sameRelation(a, b Relation) bool {
+	a.UpdatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	left, errA := json.Marshal(a)
+	right, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(left) == string(right)
+}
+
+// freeRelationID finds an unused ID for an imported relation: id-import,
+// then id-import-2, id-import-3, ...
+func (s *Storage) freeRelationID(id string, taken map[string]string) string {
+	used := make(map[string]bool, len(taken))
+	for _, newID := range taken {
+		used[newID] = true
+	}
+	for n := 1; ; n++ {
+		candidate := id + "-import"
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-import-%d", id, n)
+		}
+		if used[candidate] {
+			continue
+		}
+		if _, err := s.relationStore.Load(candidate); err != nil {
+			return candidate
+		}
+	}
+}
+
+// relinkImportedCommand creates the /commands symlink for an imported tool
+// if it doesn't have one. An existing command of the same name is left alone.
+func (s *Storage) relinkImportedCommand(tool Relation) bool {
+	name := getRelationName(tool)
+	executableID, _ := tool.Properties["executable_id"].(string)
+	if name == "" || executableID == "" || !s.objects.Exists(executableID) {
+		return false
+	}
+	homeDir, _ := os.UserHomeDir()
+	if _, err := os.Lstat(filepath.Join(homeDir, ".port42", "commands", name)); err == nil {
+		return false
+	}
+	if err := s.CreateCommandSymlink(executableID, name); err != nil {
+		log.Printf("⚠️ [IMPORT] Failed to link command %s: %v", name, err)
+		return false
+	}
+	return true
+}
+
+// importSessionIndex adds archived sessions to the index. In merge mode
+// local entries and last-session pointers win; otherwise the archive's do.
+func (s *Storage) importSessionIndex(archived *SessionIndex, merge bool, report *ImportReport) error {
+	s.indexMutex.Lock()
+	defer s.indexMutex.Unlock()
+
+	if s.sessionIndex == nil {
+		if err := s.loadSessionIndex(); err != nil {
+			return fmt.Errorf("failed to load session index: %w", err)
+		}
+	}
+	if s.sessionIndex.Sessions == nil {
+		s.sessionIndex.Sessions = make(map[string]SessionReference)
+	}
+	if s.sessionIndex.LastSessions == nil {
+		s.sessionIndex.LastSessions = make(map[string]string)
+	}
+
+	for id, ref := range archived.Sessions {
+		if _, exists := s.sessionIndex.Sessions[id]; exists && merge {
+			continue
+		} else if !exists {
+			report.SessionsAdded++
+		}
+		s.sessionIndex.Sessions[id] = ref
+	}
+	for agent, sessionID := range archived.LastSessions {
+		if _, exists := s.sessionIndex.LastSessions[agent]; exists && merge {
+			continue
+		}
+		s.sessionIndex.LastSessions[agent] = sessionID
+	}
+
+	if err := s.saveSessionIndex(); err != nil {
+		return fmt.Errorf("failed to save session index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// artifactFileType classifies one file of a multi-file artifact from its
+// name, and its MIME type for media
+func artifactFileType(filePath, mimeType string) string {
+	switch {
+	case isMediaMimeType(mimeType):
+		return "media"
+	case strings.HasSuffix(filePath, ".md"):
+		return "document"
+	case strings.HasSuffix(filePath, ".js") || strings.HasSuffix(filePath, ".py"):
+		return "code"
+	case strings.HasSuffix(filePath, ".html") || strings.HasSuffix(filePath, ".css"):
+		return "web"
+	}
+	return "file"
+}
+
+// ArtifactFile is one file of an artifact manifest
+type ArtifactFile struct {
+	Path     string    `json:"path"` // Relative to the artifact, e.g. src/app.js
+	Virtual  string    `json:"virtual_path"`
+	ID       string    `json:"id"`
+	Type     string    `json:"type"` // As artifactFileType classifies it
+	MimeType string    `json:"mime_type,omitempty"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// ArtifactManifest lists the files of /artifacts/{type}/{name}
+type ArtifactManifest struct {
+	Type      string         `json:"type"`
+	Name      string         `json:"name"`
+	Path      string         `json:"path"`
+	Files     []ArtifactFile `json:"files"`
+	Count     int            `json:"count"`
+	TotalSize int64          `json:"total_size"`
+}
+
+// matchesContentTypes reports whether a file is one of types, each either
+// a file type (code, web, ...) or a MIME type or prefix (text/, image/png).
+// No types matches everything.
+func (f ArtifactFile) matchesContentTypes(types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, contentType := range types {
+		contentType = strings.ToLower(strings.TrimSpace(contentType))
+		if contentType == f.Type || (strings.Contains(contentType, "/") && strings.HasPrefix(strings.ToLower(f.MimeType), contentType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArtifactManifest gathers the files stored under an artifact's directory,
+// keeping those of the given content types. The directory is what groups
+// them: files stored before artifact_name was kept in metadata don't have
+// it. Files are ordered by path.
+func (s *Storage) ArtifactManifest(artifactType, name string, contentTypes []string) (*ArtifactManifest, error) {
+	base := fmt.Sprintf("/artifacts/%s/%s", artifactType, name)
+	manifest := &ArtifactManifest{Type: artifactType, Name: name, Path: base, Files: []ArtifactFile{}}
+
+	found := false
+	docs, _ := s.searchIndex.snapshot("", "")
+	for _, doc := range docs {
+		var virtual string
+		for _, p := range doc.Paths {
+			if strings.HasPrefix(p, base+"/") {
+				virtual = p
+				break
+			}
+		}
+		if virtual == "" {
+			continue
+		}
+		found = true
+
+		file := ArtifactFile{
+			Path:     strings.TrimPrefix(virtual, base+"/"),
+			Virtual:  virtual,
+			ID:       doc.ID,
+			MimeType: doc.MimeType,
+			Size:     doc.Size,
+			Modified: doc.Modified,
+		}
+		file.Type = artifactFileType(file.Path, file.MimeType)
+		if !file.matchesContentTypes(contentTypes) {
+			continue
+		}
+		manifest.Files = append(manifest.Files, file)
+		manifest.TotalSize += file.Size
+	}
+
+	if !found {
+		return nil, fmt.Errorf("artifact not found: %s", base)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	manifest.Count = len(manifest.Files)
+	return manifest, nil
+}
+
+// WriteArtifactBundle writes the manifest's files as a tar.gz, each under
+// the artifact's name and its relative path
+func (s *Storage) WriteArtifactBundle(w io.Writer, manifest *ArtifactManifest) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, file := range manifest.Files {
+		content, err := s.Read(file.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Virtual, err)
+		}
+		header := &tar.Header{
+			Name:    filepath.ToSlash(filepath.Join(manifest.Name, file.Path)),
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: file.Modified,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// handleArtifactManifest lists the files of a multi-file artifact, and with
+// bundle set also writes them to a tar.gz
+func (d *Daemon) handleArtifactManifest(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		Type         string   `json:"type"`
+		Name         string   `json:"name"`
+		ContentTypes []string `json:"content_types,omitempty"` // File types or MIME types (prefixes) to keep
+		Bundle       bool     `json:"bundle,omitempty"`
+		Path         string   `json:"path,omitempty"` // Bundle location, defaults to ~/.port42/exports/<name>-<timestamp>.tar.gz
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if payload.Type == "" || payload.Name == "" || strings.Contains(payload.Type+payload.Name, "/") {
+		return NewErrorResponse(req.ID, "type and name are required, as in /artifacts/{type}/{name}")
+	}
+
+	manifest, err := d.storage.ArtifactManifest(payload.Type, payload.Name, payload.ContentTypes)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+
+	data := map[string]interface{}{"manifest": manifest}
+	if payload.Bundle {
+		bundlePath, size, err := d.writeArtifactBundle(manifest, payload.Path)
+		if err != nil {
+			return NewErrorResponse(req.ID, err.Error())
+		}
+		logger.Infof("📦 Bundled %d files of %s into %s (%d bytes)", manifest.Count, manifest.Path, bundlePath, size)
+		data["bundle"] = map[string]interface{}{
+			"path": bundlePath,
+			"size": size,
+		}
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(data)
+	return resp
+}
+
+// writeArtifactBundle writes a bundle where export would put one, subject
+// to the same file access policy
+func (d *Daemon) writeArtifactBundle(manifest *ArtifactManifest, requested string) (string, int64, error) {
+	bundlePath := requested
+	if bundlePath == "" {
+		bundlePath = filepath.Join(d.baseDir, "exports", fmt.Sprintf("%s-%s.tar.gz", manifest.Name, time.Now().Format("20060102-150405")))
+	}
+	bundlePath = expandAccessRoot(bundlePath)
+	if bundlePath == "" {
+		return "", 0, fmt.Errorf("Invalid path: %s", requested)
+	}
+	if !d.isFileAccessAllowed(bundlePath) {
+		return "", 0, fmt.Errorf("Access denied: %s is outside the file access policy", bundlePath)
+	}
+	if err := os.MkdirAll(filepath.Dir(bundlePath), 0755); err != nil {
+		return "", 0, fmt.Errorf("Failed to create bundle directory: %v", err)
+	}
+
+	// Written beside the target and renamed, so a failed bundle leaves nothing behind
+	tmp, err := os.CreateTemp(filepath.Dir(bundlePath), filepath.Base(bundlePath)+".tmp*")
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to create bundle: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := d.storage.WriteArtifactBundle(tmp, manifest); err != nil {
+		tmp.Close()
+		return "", 0, fmt.Errorf("Bundle failed: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, fmt.Errorf("Bundle failed: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), bundlePath); err != nil {
+		return "", 0, fmt.Errorf("Bundle failed: %v", err)
+	}
+
+	var size int64
+	if info, err := os.Stat(bundlePath); err == nil {
+		size = info.Size()
+	}
+	return bundlePath, size, nil
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditLogMaxSize rotates audit.log to audit.log.1 once it grows past this
+const auditLogMaxSize = 5 * 1024 * 1024
+
+// Limits for get_audit
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 1000
+)
+
+// auditedRequests are the request types that change stored data. Each one
+// is recorded in the audit log, whether it succeeded or not.
+var auditedRequests = map[string]bool{
+	"store_path":        true,
+	"commit_upload":     true,
+	"update_path":       true,
+	"delete_path":       true,
+	"move_path":         true,
+	"create_memory":     true,
+	"fork_session":      true,
+	"reassign_session":  true,
+	"restore_version":   true,
+	"batch_op":          true,
+	"gc":                true,
+	"prune":             true,
+	"rebuild_index":     true,
+	"import":            true,
+	"declare_relation":  true,
+	"declare_relations": true,
+	"delete_relation":   true,
+	"uninstall_tool":    true,
+	"purge_agent":       true,
+}
+
+// AuditEntry is one line of the audit log (~/.port42/audit.log)
+type AuditEntry struct {
+	Time      time.Time              `json:"time"`
+	Operation string                 `json:"operation"`        // The request type
+	Target    string                 `json:"target,omitempty"` // Path, relation ID, tool or session acted on
+	Agent     string                 `json:"agent,omitempty"`
+	Session   string                 `json:"session,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Success   bool                   `json:"success"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// AuditLog appends entries to an append-only JSONL file. Writes are
+// serialized so concurrent requests never interleave their lines.
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewAuditLog keeps the audit log in baseDir
+func NewAuditLog(baseDir string) *AuditLog {
+	return &AuditLog{path: filepath.Join(baseDir, "audit.log")}
+}
+
+// Append writes one entry, rotating the log when it gets large
+func (a *AuditLog) Append(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if info, err := os.Stat(a.path); err == nil && info.Size() > auditLogMaxSize {
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// AuditQuery filters get_audit entries. Empty fields match everything.
+type AuditQuery struct {
+	Operations []string  `json:"operations,omitempty"`
+	Target     string    `json:"target,omitempty"` // Prefix of the target, e.g. /commands/ or a relation ID
+	Agent      string    `json:"agent,omitempty"`
+	Session    string    `json:"session,omitempty"`
+	Since      time.Time `json:"since,omitempty"`
+	FailedOnly bool      `json:"failed_only,omitempty"`
+	Limit      int       `json:"limit,omitempty"`
+}
+
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	if len(q.Operations) > 0 && !containsFold(q.Operations, entry.Operation) {
+		return false
+	}
+	if q.Target != "" && !strings.HasPrefix(entry.Target, q.Target) {
+		return false
+	}
+	if q.Agent != "" && !strings.EqualFold(strings.TrimPrefix(entry.Agent, "@"), strings.TrimPrefix(q.Agent, "@")) {
+		return false
+	}
+	if q.Session != "" && entry.Session != q.Session {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+	if q.FailedOnly && entry.Success {
+		return false
+	}
+	return true
+}
+
+// Query returns the newest entries matching q, newest first. The rotated
+// log is read as well, so a rotation doesn't hide recent history.
+func (a *AuditLog) Query(q AuditQuery) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var entries []AuditEntry
+	for _, path := range []string{a.path + ".1", a.path} {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				continue // A torn last line from a crash
+			}
+			if q.matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Newest first, then trim
+	result := make([]AuditEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0 && len(result) < q.Limit; i-- {
+		result = append(result, entries[i])
+	}
+	return result, nil
+}
+
+// auditPayload holds the payload fields that identify what a mutating
+// request acted on
+type auditPayload struct {
+	Path         string     `json:"path"`
+	OldPath      string     `json:"old_path"`
+	NewPath      string     `json:"new_path"`
+	RelationID   string     `json:"relation_id"`
+	Relation     *Relation  `json:"relation"`
+	Relations    []Relation `json:"relations"`
+	Tool         string     `json:"tool"`
+	Version      string     `json:"version"`
+	Name         string     `json:"name"`
+	SessionID    string     `json:"session_id"`
+	NewSessionID string     `json:"new_session_id"`
+	Agent        string     `json:"agent"`
+	Action       string     `json:"action"`
+	Confirm      bool       `json:"confirm"`
+	Cascade      bool       `json:"cascade"`
+	DryRun       *bool      `json:"dry_run"`
+}
+
+// newAuditEntry describes a mutating request and its outcome
+func newAuditEntry(req Request, resp Response) AuditEntry {
+	entry := AuditEntry{
+		Time:      time.Now(),
+		Operation: req.Type,
+		RequestID: req.ID,
+		Success:   resp.Success,
+		Error:     resp.Error,
+		Details:   make(map[string]interface{}),
+	}
+	if req.SessionContext != nil {
+		entry.Agent = req.SessionContext.Agent
+		entry.Session = req.SessionContext.SessionID
+	}
+
+	var p auditPayload
+	if len(req.Payload) > 0 {
+		json.Unmarshal(req.Payload, &p)
+	}
+
+	switch {
+	case p.Path != "":
+		entry.Target = p.Path
+	case p.OldPath != "":
+		entry.Target = p.OldPath
+		entry.Details["new_path"] = p.NewPath
+	case p.RelationID != "":
+		entry.Target = p.RelationID
+	case p.Relation != nil:
+		entry.Target = p.Relation.ID
+		if entry.Target == "" {
+			entry.Target = getRelationName(*p.Relation)
+		}
+		entry.Details["relation_type"] = p.Relation.Type
+		if entry.Agent == "" {
+			entry.Agent, _ = p.Relation.Properties["agent"].(string)
+		}
+	case p.Tool != "":
+		entry.Target = p.Tool
+		entry.Details["version"] = p.Version
+	case p.Name != "":
+		entry.Target = p.Name
+	case p.SessionID != "":
+		entry.Target = p.SessionID
+	}
+
+	if len(p.Relations) > 0 {
+		ids := make([]string, 0, len(p.Relations))
+		for _, relation := range p.Relations {
+			if relation.ID != "" {
+				ids = append(ids, relation.ID)
+			} else {
+				ids = append(ids, getRelationName(relation))
+			}
+		}
+		entry.Details["relations"] = ids
+	}
+	if p.NewSessionID != "" {
+		entry.Details["new_session_id"] = p.NewSessionID
+	}
+	if p.Agent != "" && req.Type == "reassign_session" {
+		entry.Details["new_agent"] = p.Agent
+	} else if p.Agent != "" && req.Type == "purge_agent" {
+		entry.Target = p.Agent
+		entry.Details["confirm"] = p.Confirm
+	} else if p.Agent != "" && entry.Agent == "" {
+		entry.Agent = p.Agent
+	}
+	if p.Action != "" {
+		entry.Details["action"] = p.Action
+		entry.Details["confirm"] = p.Confirm
+	}
+	if p.Cascade {
+		entry.Details["cascade"] = true
+	}
+	if p.DryRun != nil {
+		entry.Details["dry_run"] = *p.DryRun
+	}
+
+	// IDs assigned while handling the request, such as a stored object's
+	if resp.Success && len(resp.Data) > 0 {
+		var data map[string]interface{}
+		if json.Unmarshal(resp.Data, &data) == nil {
+			for _, key := range []string{"object_id", "relation_id", "session_id", "id"} {
+				if id, ok := data[key].(string); ok && id != "" && id != entry.Target {
+					entry.Details[key] = id
+				}
+			}
+		}
+	}
+
+	if len(entry.Details) == 0 {
+		entry.Details = nil
+	}
+	return entry
+}
+
+// recordAudit appends mutating requests to the audit log. A failed write
+// is logged but never fails the request.
+func (d *Daemon) recordAudit(req Request, resp Response) {
+	if d.audit == nil || !auditedRequests[req.Type] {
+		return
+	}
+	if err := d.audit.Append(newAuditEntry(req, resp)); err != nil {
+		logger.Warnf("⚠️ [AUDIT] Failed to append audit log: %v", err)
+	}
+}
+
+// handleGetAudit returns recent audit log entries, newest first
+func (d *Daemon) handleGetAudit(req Request) Response {
+	if d.audit == nil {
+		return NewErrorResponse(req.ID, "Audit log not initialized")
+	}
+
+	var payload struct {
+		AuditQuery
+		Operation string `json:"operation,omitempty"` // Shorthand for a single entry in operations
+		Since     string `json:"since,omitempty"`     // RFC3339 time, or a duration such as "24h" or "7d" back from now
+	}
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+		}
+	}
+
+	query := payload.AuditQuery
+	if payload.Operation != "" {
+		query.Operations = append(query.Operations, payload.Operation)
+	}
+	if payload.Since != "" {
+		since, err := parseAuditSince(payload.Since)
+		if err != nil {
+			return NewErrorResponse(req.ID, err.Error())
+		}
+		query.Since = since
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultAuditLimit
+	} else if query.Limit > maxAuditLimit {
+		query.Limit = maxAuditLimit
+	}
+
+	entries, err := d.audit.Query(query)
+	if err != nil {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Failed to read audit log: %v", err))
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+	})
+	return resp
+}
+
+// parseAuditSince accepts an RFC3339 time or how far back to look
+func parseAuditSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	age, err := parsePruneAge(value)
+	if err != nil || age <= 0 {
+		return time.Time{}, fmt.Errorf("invalid since %q: use an RFC3339 time or a duration such as 24h or 7d", value)
+	}
+	return time.Now().Add(-age), nil
+}
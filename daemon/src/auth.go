@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"sort"
+	"strings"
+)
+
+// defaultAuthExempt lists the request types that don't need the token when
+// PORT42_AUTH_EXEMPT is unset: liveness checks that reveal nothing stored
+const defaultAuthExempt = "ping,status"
+
+// AuthConfig is the optional shared secret every request must carry.
+// It is kept out of Config so the token never reaches the startup log.
+type AuthConfig struct {
+	token  string
+	exempt map[string]bool // Request types allowed without the token
+}
+
+// loadAuthConfig reads PORT42_AUTH_TOKEN and PORT42_AUTH_EXEMPT. Without a
+// token every request is allowed, as before.
+func loadAuthConfig() *AuthConfig {
+	config := &AuthConfig{
+		token:  envString("PORT42_AUTH_TOKEN", ""),
+		exempt: make(map[string]bool),
+	}
+	if config.token == "" {
+		return config
+	}
+
+	exempt := envString("PORT42_AUTH_EXEMPT", defaultAuthExempt)
+	if strings.ToLower(exempt) != "none" {
+		for _, requestType := range strings.Split(exempt, ",") {
+			if requestType = strings.TrimSpace(requestType); requestType != "" {
+				config.exempt[requestType] = true
+			}
+		}
+	}
+
+	log.Printf("🔑 Auth token required for daemon requests (exempt: %s)", config.describeExempt())
+	return config
+}
+
+// Enabled reports whether requests must carry the token
+func (c *AuthConfig) Enabled() bool {
+	return c != nil && c.token != ""
+}
+
+// Allows reports whether req may be handled: auth is off, the request type
+// is exempt, or req.Auth matches the token
+func (c *AuthConfig) Allows(req Request) bool {
+	if !c.Enabled() || c.exempt[req.Type] {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(req.Auth), []byte(c.token)) == 1
+}
+
+// describeExempt lists the exempt request types for logs
+func (c *AuthConfig) describeExempt() string {
+	if len(c.exempt) == 0 {
+		return "none"
+	}
+	types := make([]string, 0, len(c.exempt))
+	for requestType := range c.exempt {
+		types = append(types, requestType)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// rejectUnauthorized returns the error response for a request without a
+// valid token, logging the attempt (but never the token)
+func rejectUnauthorized(req Request) Response {
+	reason := "missing"
+	if req.Auth != "" {
+		reason = "invalid"
+	}
+	log.Printf("🚫 Rejected %s request [%s]: %s auth token", req.Type, req.ID, reason)
+	return NewErrorResponse(req.ID, "Unauthorized: this daemon requires a valid auth token (PORT42_AUTH_TOKEN)")
+}
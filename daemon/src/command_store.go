@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// commandStoreDir holds a copy of each command's executable, under the
+// commands directory. A command's symlink points at its file here instead of
+// at the object, so the link never changes when the command is updated and
+// keeps working whatever later happens to the object it was made from.
+const commandStoreDir = ".store"
+
+// commandsDir is the directory of command symlinks users put on their PATH
+func (s *Storage) commandsDir() string {
+	return filepath.Join(s.baseDir, "commands")
+}
+
+// commandStorePath is the executable a command's symlink points at
+func (s *Storage) commandStorePath(cmdName string) string {
+	return filepath.Join(s.commandsDir(), commandStoreDir, cmdName)
+}
+
+// writeCommandFile rewrites a command's store file with an object's content
+// and points the command's symlink at it. Both are replaced with a rename,
+// so a command being run never sees a half-written executable.
+func (s *Storage) writeCommandFile(objID, cmdName string) error {
+	storePath := s.commandStorePath(cmdName)
+	if err := os.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+		return fmt.Errorf("failed to create command store: %w", err)
+	}
+
+	content, err := s.Read(objID)
+	if err != nil {
+		return fmt.Errorf("failed to read command object: %w", err)
+	}
+	if err := atomicWriteFile(storePath, content, 0755); err != nil {
+		return fmt.Errorf("failed to write command file: %w", err)
+	}
+
+	// Relative, so the link survives ~/.port42 being moved
+	linkPath := filepath.Join(s.commandsDir(), cmdName)
+	target := filepath.Join(commandStoreDir, cmdName)
+	if current, err := os.Readlink(linkPath); err == nil && current == target {
+		return nil
+	}
+	tmpLink := linkPath + ".tmp-link"
+	os.Remove(tmpLink)
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	if err := os.Rename(tmpLink, linkPath); err != nil {
+		os.Remove(tmpLink)
+		return fmt.Errorf("failed to replace symlink: %w", err)
+	}
+	return nil
+}
+
+// removeCommandFiles deletes a command's symlink and its store file
+func (s *Storage) removeCommandFiles(cmdName string) error {
+	err := os.Remove(filepath.Join(s.commandsDir(), cmdName))
+	if storeErr := os.Remove(s.commandStorePath(cmdName)); storeErr != nil && !os.IsNotExist(storeErr) && err == nil {
+		err = storeErr
+	}
+	return err
+}
+
+// commandObjectID returns the ID of the object a command runs: the hash of
+// its store file, or for a link made before the store existed, the object
+// the link points at. Empty if the command isn't a port42 symlink.
+func (s *Storage) commandObjectID(cmdName string) string {
+	target, err := os.Readlink(filepath.Join(s.commandsDir(), cmdName))
+	if err != nil {
+		return ""
+	}
+	if target != filepath.Join(commandStoreDir, cmdName) {
+		return objectIDFromPath(target)
+	}
+	content, err := os.ReadFile(s.commandStorePath(cmdName))
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// migrateCommandLinks moves command symlinks that still point straight at
+// object files over to store files. Links whose object is gone are left
+// alone for the commands view to report as broken.
+func (s *Storage) migrateCommandLinks() {
+	entries, err := os.ReadDir(s.commandsDir())
+	if err != nil {
+		return
+	}
+
+	migrated := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(s.commandsDir(), entry.Name()))
+		if err != nil {
+			continue // Not a symlink
+		}
+		objectID := objectIDFromPath(target)
+		if objectID == "" || !s.objects.Exists(objectID) {
+			continue
+		}
+		if err := s.writeCommandFile(objectID, entry.Name()); err != nil {
+			logger.Warnf("⚠️ [STORAGE] Failed to move command %s to the command store: %v", entry.Name(), err)
+			continue
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		logger.Infof("🔗 [STORAGE] Moved %d command symlinks to the command store", migrated)
+	}
+}
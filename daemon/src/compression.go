@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Whole objects at or above PORT42_COMPRESS_MIN_SIZE bytes are gzipped on
+// disk. Object IDs are still the SHA256 of the original content. Compressed
+// files are recognized by the gzip magic bytes; an object whose original
+// content happens to be gzip data is told apart by checking its hash.
+const defaultCompressMinSize = 4 * 1024
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// compressedMediaExtensions are formats that are already compressed, so
+// gzipping them costs CPU for no space saving
+var compressedMediaExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".avif": true,
+	".mp3": true, ".ogg": true, ".m4a": true, ".flac": true, ".opus": true,
+	".mp4": true, ".mov": true, ".webm": true, ".mkv": true,
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".zst": true, ".7z": true,
+	".pdf": true, ".woff": true, ".woff2": true,
+}
+
+// loadCompressMinSize reads PORT42_COMPRESS_MIN_SIZE; 0 or less disables compression
+func loadCompressMinSize() int {
+	return envInt("PORT42_COMPRESS_MIN_SIZE", defaultCompressMinSize)
+}
+
+// shouldCompress decides from an object's metadata whether it may be gzipped.
+// Commands are executed in place through symlinks and must stay plain, and
+// media that is already compressed is skipped unless PORT42_COMPRESS_MEDIA is set.
+func shouldCompress(meta *Metadata) bool {
+	if meta == nil {
+		return true
+	}
+	switch strings.ToLower(meta.Type) {
+	case "command", "tool", "application/port42-command", "application/port42-tool":
+		return false
+	}
+	if envBool("PORT42_COMPRESS_MEDIA", false) {
+		return true
+	}
+	return !isCompressedMedia(meta)
+}
+
+// isCompressedMedia infers an already-compressed format from metadata
+func isCompressedMedia(meta *Metadata) bool {
+	if isMediaMimeType(meta.MimeType) {
+		return true
+	}
+	for _, kind := range []string{meta.Type, meta.Subtype} {
+		kind = strings.ToLower(kind)
+		if kind == "media" || kind == "image" || kind == "audio" || kind == "video" || kind == "archive" ||
+			strings.HasPrefix(kind, "image/") || strings.HasPrefix(kind, "audio/") || strings.HasPrefix(kind, "video/") {
+			return true
+		}
+	}
+	names := append([]string{meta.Title}, meta.Paths...)
+	for _, name := range names {
+		if compressedMediaExtensions[strings.ToLower(filepath.Ext(name))] {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipContent compresses content, returning ok=false when that doesn't save space
+func gzipContent(content []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return nil, false
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() >= len(content) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// decodeObject returns the original content of an object file. Files that
+// don't look compressed, or don't decompress to content matching id, are
+// returned as stored.
+func decodeObject(id string, data []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, false
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return data, false
+	}
+	defer zr.Close()
+
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return data, false
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != id {
+		return data, false // Stored content is itself gzip data
+	}
+	return content, true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Daemon settings are read from PORT42_* environment variables, following
+// the convention used for PORT42_ANTHROPIC_API_KEY.
+
+// Session lifecycle defaults (PORT42_IDLE_TIMEOUT, PORT42_ABANDON_MULTIPLIER).
+// A session goes idle after IdleTimeout without activity and is abandoned
+// after IdleTimeout*AbandonMultiplier.
+const (
+	defaultIdleTimeout       = 30 * time.Minute
+	defaultAbandonMultiplier = 2.0
+)
+
+// defaultMaxSessions caps sessions held in memory (PORT42_MAX_SESSIONS, 0 for no limit)
+const defaultMaxSessions = 100
+
+// abandonAfter returns how long a session may go without activity before it is abandoned
+func abandonAfter(idleTimeout time.Duration, multiplier float64) time.Duration {
+	return time.Duration(float64(idleTimeout) * multiplier)
+}
+
+// envString returns the value of an environment variable or a default
+func envString(name, def string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return def
+}
+
+// envBool parses a boolean environment variable ("1", "true", "yes", "on")
+func envBool(name string, def bool) bool {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+	switch value {
+	case "":
+		return def
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	default:
+		log.Printf("⚠️ Ignoring invalid value for %s: %q", name, value)
+		return def
+	}
+}
+
+// envInt parses an integer environment variable
+func envInt(name string, def int) int {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️ Ignoring invalid value for %s: %q", name, value)
+		return def
+	}
+	return n
+}
+
+// envDuration parses a duration environment variable (e.g. "30m", "2h")
+func envDuration(name string, def time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️ Ignoring invalid value for %s: %q", name, value)
+		return def
+	}
+	return d
+}
+
+// envFloat parses a floating point environment variable
+func envFloat(name string, def float64) float64 {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("⚠️ Ignoring invalid value for %s: %q", name, value)
+		return def
+	}
+	return f
+}
+
+// envPositiveDuration is envDuration for settings that must be greater than zero
+func envPositiveDuration(name string, def time.Duration) time.Duration {
+	d := envDuration(name, def)
+	if d <= 0 {
+		log.Printf("⚠️ %s must be positive, using %v", name, def)
+		return def
+	}
+	return d
+}
+
+// envPositiveInt is envInt for settings that must be greater than zero
+func envPositiveInt(name string, def int) int {
+	n := envInt(name, def)
+	if n <= 0 {
+		log.Printf("⚠️ %s must be positive, using %v", name, def)
+		return def
+	}
+	return n
+}
+
+// envPositiveFloat is envFloat for settings that must be greater than zero
+func envPositiveFloat(name string, def float64) float64 {
+	f := envFloat(name, def)
+	if f <= 0 {
+		log.Printf("⚠️ %s must be positive, using %v", name, def)
+		return def
+	}
+	return f
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Connection-level keepalive frames. These are separate from the one-shot
+// "ping" request: a client may send FramePing at any time while the
+// connection is open (before its request, or during a stream) and gets a
+// pong frame back without the connection being consumed. On streaming
+// connections the daemon also sends its own ping frames every
+// PORT42_KEEPALIVE_INTERVAL, which clients answer with FramePong.
+//
+// Every frame read from the client refreshes the read deadline, so pings
+// and pongs count as activity for PORT42_CONN_IDLE_TIMEOUT.
+const (
+	FramePing = "keepalive_ping" // Request.Type for a ping frame
+	FramePong = "keepalive_pong" // Request.Type for a pong frame answering a daemon ping
+	FrameStop = "stream_stop"    // Request.Type a client sends to end a stream early
+
+	defaultConnIdleTimeout   = 2 * time.Minute
+	defaultKeepaliveInterval = 30 * time.Second
+)
+
+// clientConn wraps a client connection with frame handling and deadlines
+type clientConn struct {
+	conn        net.Conn
+	decoder     *json.Decoder
+	encoder     *json.Encoder
+	writeMu     sync.Mutex
+	idleTimeout time.Duration
+}
+
+// newClientConn wraps conn using the configured idle timeout
+func newClientConn(conn net.Conn, idleTimeout time.Duration) *clientConn {
+	return &clientConn{
+		conn:        conn,
+		decoder:     json.NewDecoder(conn),
+		encoder:     json.NewEncoder(conn),
+		idleTimeout: idleTimeout,
+	}
+}
+
+// refreshDeadline pushes the read deadline out by the idle timeout
+func (c *clientConn) refreshDeadline() {
+	if c.idleTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+	}
+}
+
+// readFrame reads the next frame and refreshes the read deadline
+func (c *clientConn) readFrame() (Request, error) {
+	c.refreshDeadline()
+	var req Request
+	err := c.decoder.Decode(&req)
+	if err == nil {
+		c.refreshDeadline()
+	}
+	return req, err
+}
+
+// readRequest reads frames until a real request arrives, answering pings
+func (c *clientConn) readRequest() (Request, error) {
+	for {
+		req, err := c.readFrame()
+		if err != nil {
+			return req, err
+		}
+		if !c.handleControlFrame(req) {
+			return req, nil
+		}
+	}
+}
+
+// handleControlFrame answers ping frames and swallows pongs.
+// Returns true if the frame was a keepalive frame.
+func (c *clientConn) handleControlFrame(req Request) bool {
+	switch req.Type {
+	case FramePing:
+		pong := NewResponse(req.ID, true)
+		pong.Frame = "pong"
+		if err := c.send(pong); err != nil {
+			log.Printf("⚠️ Failed to send pong to %s: %v", c.conn.RemoteAddr(), err)
+		}
+		return true
+	case FramePong:
+		return true
+	}
+	return false
+}
+
+// send writes a response frame; safe to call from multiple goroutines
+func (c *clientConn) send(resp Response) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.encoder.Encode(resp)
+}
+
+// startKeepalive sends ping frames every interval until stop is closed.
+// Intended for streaming connections that stay open between responses.
+func (c *clientConn) startKeepalive(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ping := NewResponse("keepalive", true)
+				ping.Frame = "ping"
+				if err := c.send(ping); err != nil {
+					log.Printf("⚠️ Keepalive ping to %s failed: %v", c.conn.RemoteAddr(), err)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// watchClientFrames reads keepalive frames from a streaming client and
+// closes the returned channel when the client disconnects, sends FrameStop,
+// or goes idle past the read deadline
+func (c *clientConn) watchClientFrames() <-chan struct{} {
+	gone := make(chan struct{})
+
+	go func() {
+		defer close(gone)
+		for {
+			req, err := c.readFrame()
+			if err != nil || req.Type == FrameStop {
+				return
+			}
+			if !c.handleControlFrame(req) {
+				log.Printf("⚠️ Ignoring %s frame on streaming connection from %s", req.Type, c.conn.RemoteAddr())
+			}
+		}
+	}()
+
+	return gone
+}
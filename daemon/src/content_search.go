@@ -0,0 +1,75 @@
+package main
+
+import "unicode/utf8"
+
+// Content search sizes. A search reads the content of objects up to its
+// max_content_size, 100KB unless it asks otherwise; no search reads more
+// of an object than the daemon's ceiling.
+const (
+	defaultMaxContentSearchSize = 100 * 1024
+	defaultContentSearchCeiling = 10 * 1024 * 1024 // PORT42_MAX_CONTENT_SEARCH_SIZE
+)
+
+// contentLimits are one search's content sizes, after defaults and the
+// ceiling are applied
+type contentLimits struct {
+	maxSize int64 // Objects up to this size are searched whole
+	prefix  int64 // Bytes searched of larger objects up to ceiling; 0 skips them
+	ceiling int64
+}
+
+// contentSearchLimits resolves a search's content size filters
+func (s *Storage) contentSearchLimits(filters SearchFilters) contentLimits {
+	ceiling := s.contentSearchCeiling
+	if ceiling <= 0 {
+		ceiling = defaultContentSearchCeiling
+	}
+	limits := contentLimits{
+		maxSize: filters.MaxContentSize,
+		prefix:  filters.ContentPrefix,
+		ceiling: ceiling,
+	}
+	if limits.maxSize <= 0 {
+		limits.maxSize = defaultMaxContentSearchSize
+	}
+	if limits.maxSize > ceiling {
+		limits.maxSize = ceiling
+	}
+	if limits.prefix < 0 {
+		limits.prefix = 0
+	}
+	if limits.prefix > ceiling {
+		limits.prefix = ceiling
+	}
+	return limits
+}
+
+// searchable reports whether an object of this size gets a content pass.
+// Objects stored without a size are tried, reading at most maxSize.
+func (l contentLimits) searchable(size int64) bool {
+	if size <= l.maxSize {
+		return true
+	}
+	return l.prefix > 0 && size <= l.ceiling
+}
+
+// readLimit is how many bytes of an object of this size are searched
+func (l contentLimits) readLimit(size int64) int64 {
+	if size <= l.maxSize {
+		return l.maxSize
+	}
+	return l.prefix
+}
+
+// truncateUTF8 cuts content to at most limit bytes without splitting a
+// UTF-8 sequence
+func truncateUTF8(content []byte, limit int64) []byte {
+	if limit <= 0 || int64(len(content)) <= limit {
+		return content
+	}
+	cut := int(limit)
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut]
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// detectMimeType returns the MIME type for content stored under name. The
+// extension decides when it maps to a specific type; content without one,
+// or with a generic one like .bin, is sniffed with http.DetectContentType.
+func detectMimeType(name string, content []byte) string {
+	if ext := strings.ToLower(filepath.Ext(name)); ext != "" {
+		if mimeType := mime.TypeByExtension(ext); mimeType != "" && !strings.HasPrefix(mimeType, "application/octet-stream") {
+			return mimeType
+		}
+	}
+	return http.DetectContentType(content)
+}
+
+// isMediaMimeType reports whether a MIME type is binary media. SVG is an
+// image but also XML text, so it doesn't count.
+func isMediaMimeType(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	if strings.HasPrefix(mimeType, "image/svg") {
+		return false
+	}
+	return strings.HasPrefix(mimeType, "image/") || strings.HasPrefix(mimeType, "audio/") ||
+		strings.HasPrefix(mimeType, "video/")
+}
+
+// decodeArtifactContent returns an artifact file's bytes. Binary files
+// (images, audio) arrive base64-encoded with encoding "base64"; anything
+// else is text and stored as written.
+func decodeArtifactContent(content, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", ReadEncodingUTF8:
+		return []byte(content), nil
+	case ReadEncodingBase64:
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %v", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q (use base64 or utf8)", encoding)
+	}
+}
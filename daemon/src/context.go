@@ -52,4 +52,5 @@ type ContextSuggestion struct {
 	Command    string  `json:"command"`
 	Reason     string  `json:"reason"`
 	Confidence float64 `json:"confidence"`
+	Score      float64 `json:"score"` // Recency and frequency ranking, 0..1; suggestions are sorted by it
 }
\ No newline at end of file
@@ -18,6 +18,7 @@ type ContextCollector struct {
 	maxCommands      int
 	maxTools         int
 	maxMemories      int
+	weights          SuggestionWeights
 }
 
 // NewContextCollector creates a new context collector
@@ -30,6 +31,7 @@ func NewContextCollector(daemon *Daemon) *ContextCollector {
 		recentCommands:   make([]CommandRecord, 0, 30),
 		createdTools:     make([]ToolRecord, 0, 10),
 		accessedMemories: make(map[string]*MemoryAccess),
+		weights:          loadSuggestionWeights(),
 	}
 }
 
@@ -219,40 +221,39 @@ func (cc *ContextCollector) getRecentCommands() []CommandRecord {
 	return result
 }
 
-// generateSuggestions creates contextual command suggestions
+// generateSuggestions creates contextual command suggestions ranked by
+// recency and frequency. Session and command hints are scored by how
+// recently the thing that triggered them happened, scaled by their
+// confidence; tracked commands and paths by how recently and how often
+// they were used.
 func (cc *ContextCollector) generateSuggestions(data *ContextData) []ContextSuggestion {
 	suggestions := []ContextSuggestion{}
+	now := time.Now()
+	hint := func(command, reason string, confidence float64, trigger time.Time) {
+		suggestions = append(suggestions, ContextSuggestion{
+			Command:    command,
+			Reason:     reason,
+			Confidence: confidence,
+			Score:      confidence * cc.weights.score(trigger, 1, now),
+		})
+	}
 	
 	if data.ActiveSession != nil {
+		lastActivity := data.ActiveSession.LastActivity
+		
 		// Suggest viewing session details
-		suggestions = append(suggestions, ContextSuggestion{
-			Command:    fmt.Sprintf("port42 info /memory/%s", data.ActiveSession.ID),
-			Reason:     "View current session details",
-			Confidence: 0.9,
-		})
+		hint(fmt.Sprintf("port42 info /memory/%s", data.ActiveSession.ID), "View current session details", 0.9, lastActivity)
 		
 		// If tool was created, suggest using it
 		if data.ActiveSession.ToolCreated != nil {
-			suggestions = append(suggestions, ContextSuggestion{
-				Command:    fmt.Sprintf("%s --help", *data.ActiveSession.ToolCreated),
-				Reason:     "Learn about your new tool",
-				Confidence: 0.95,
-			})
+			hint(fmt.Sprintf("%s --help", *data.ActiveSession.ToolCreated), "Learn about your new tool", 0.95, lastActivity)
 		}
 		
 		// Suggest continuing session
-		suggestions = append(suggestions, ContextSuggestion{
-			Command:    fmt.Sprintf("port42 swim %s --session last", data.ActiveSession.Agent),
-			Reason:     "Continue your conversation",
-			Confidence: 0.85,
-		})
+		hint(fmt.Sprintf("port42 swim %s --session last", data.ActiveSession.Agent), "Continue your conversation", 0.85, lastActivity)
 	} else {
 		// No active session - suggest starting one
-		suggestions = append(suggestions, ContextSuggestion{
-			Command:    "port42 swim @ai-engineer \"How can I help?\"",
-			Reason:     "Start a new AI session",
-			Confidence: 0.8,
-		})
+		hint("port42 swim @ai-engineer \"How can I help?\"", "Start a new AI session", 0.8, now)
 	}
 	
 	// Based on recent commands, suggest related actions
@@ -261,29 +262,18 @@ func (cc *ContextCollector) generateSuggestions(data *ContextData) []ContextSugg
 		
 		// If last command was search, suggest exploring results
 		if lastCmd.Command == "search" || lastCmd.Command == "ls" {
-			suggestions = append(suggestions, ContextSuggestion{
-				Command:    "port42 ls /tools/",
-				Reason:     "Explore available tools",
-				Confidence: 0.7,
-			})
+			hint("port42 ls /tools/", "Explore available tools", 0.7, lastCmd.Timestamp)
 		}
 		
 		// If context was checked, suggest watch mode
 		if lastCmd.Command == "context" {
-			suggestions = append(suggestions, ContextSuggestion{
-				Command:    "port42 context --watch",
-				Reason:     "Monitor context in real-time",
-				Confidence: 0.75,
-			})
+			hint("port42 context --watch", "Monitor context in real-time", 0.75, lastCmd.Timestamp)
 		}
 	}
 	
-	// Limit to top 5 suggestions
-	if len(suggestions) > 5 {
-		suggestions = suggestions[:5]
-	}
-	
-	return suggestions
+	// What was actually used, then the top 5 of everything
+	suggestions = append(suggestions, cc.activitySuggestions(data, now)...)
+	return rankSuggestions(suggestions)
 }
 
 // generateDisplayName creates a human-readable name for a path
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dependencyInstallerName is written to the Port 42 base directory and
+// installs command-line dependencies with the platform package manager
+const dependencyInstallerName = "install-deps.sh"
+
+// dependencyInstallTimeout bounds an automatic install (PORT42_AUTO_INSTALL_DEPS)
+const dependencyInstallTimeout = 10 * time.Minute
+
+// maxInstallOutput is how much installer output is returned in the declare response
+const maxInstallOutput = 4096
+
+// dependencyNamePattern accepts command, module and package names. Generated
+// checks embed the names in code, so anything else is dropped.
+var dependencyNamePattern = regexp.MustCompile(`^[A-Za-z0-9_@][A-Za-z0-9._+@/-]*$`)
+
+// dependencyInstallMu keeps concurrent declares from running the installer at once
+var dependencyInstallMu sync.Mutex
+
+// validDependencies drops names that can't be safely embedded in a check
+func validDependencies(deps []string) []string {
+	valid := []string{}
+	seen := make(map[string]bool)
+	for _, dep := range deps {
+		dep = strings.TrimSpace(dep)
+		if dep == "" || seen[dep] {
+			continue
+		}
+		if !dependencyNamePattern.MatchString(dep) {
+			log.Printf("⚠️ Ignoring invalid dependency name: %q", dep)
+			continue
+		}
+		seen[dep] = true
+		valid = append(valid, dep)
+	}
+	return valid
+}
+
+// generateDependencyCheck returns code that exits with install instructions
+// when a dependency is missing. Bash checks for commands on PATH; python
+// and node accept either an importable module or a command on PATH.
+func generateDependencyCheck(language string, deps []string) string {
+	deps = validDependencies(deps)
+	if len(deps) == 0 {
+		return ""
+	}
+
+	switch language {
+	case "python":
+		return generatePythonDependencyCheck(deps)
+	case "node", "javascript":
+		return generateNodeDependencyCheck(deps)
+	default:
+		return generateBashDependencyCheck(deps)
+	}
+}
+
+// generateBashDependencyCheck checks each dependency with command -v
+func generateBashDependencyCheck(deps []string) string {
+	check := `# Dependency check
+missing_deps=()
+`
+	for _, dep := range deps {
+		check += fmt.Sprintf("if ! command -v %s &> /dev/null; then\n", dep)
+		check += fmt.Sprintf("  missing_deps+=(%s)\n", dep)
+		check += "fi\n"
+	}
+
+	check += `
+if [ ${#missing_deps[@]} -ne 0 ]; then
+  echo "❌ Missing dependencies: ${missing_deps[*]}"
+  echo ""
+  echo "To install dependencies, run:"
+  echo "  ~/.port42/install-deps.sh ${missing_deps[*]}"
+  echo ""
+  echo "Or install manually:"
+  for dep in "${missing_deps[@]}"; do
+    case "$dep" in
+      lolcat) echo "  brew install lolcat  # or: gem install lolcat" ;;
+      tree) echo "  brew install tree    # or: apt-get install tree" ;;
+      figlet) echo "  brew install figlet  # or: apt-get install figlet" ;;
+      jq) echo "  brew install jq      # or: apt-get install jq" ;;
+      rg|ripgrep) echo "  brew install ripgrep # or: cargo install ripgrep" ;;
+      fzf) echo "  brew install fzf     # or: git clone https://github.com/junegunn/fzf.git" ;;
+      *) echo "  # Install $dep using your package manager" ;;
+    esac
+  done
+  exit 1
+fi
+
+`
+	return check
+}
+
+// generatePythonDependencyCheck checks each dependency with importlib, then shutil.which
+func generatePythonDependencyCheck(deps []string) string {
+	return fmt.Sprintf(`# Dependency check
+import importlib.util as _p42_importlib_util
+import shutil as _p42_shutil
+import sys as _p42_sys
+
+def _p42_missing_deps(deps):
+    missing = []
+    for dep in deps:
+        try:
+            found = _p42_importlib_util.find_spec(dep) is not None
+        except (ImportError, ValueError):
+            found = False
+        if not found and _p42_shutil.which(dep) is None:
+            missing.append(dep)
+    return missing
+
+_p42_missing = _p42_missing_deps(%s)
+if _p42_missing:
+    print("❌ Missing dependencies: " + " ".join(_p42_missing), file=_p42_sys.stderr)
+    print("", file=_p42_sys.stderr)
+    print("To install dependencies, run:", file=_p42_sys.stderr)
+    print("  pip3 install " + " ".join(_p42_missing) + "    # Python modules", file=_p42_sys.stderr)
+    print("  ~/.port42/install-deps.sh " + " ".join(_p42_missing) + "    # command-line tools", file=_p42_sys.stderr)
+    _p42_sys.exit(1)
+
+`, dependencyListLiteral(deps))
+}
+
+// generateNodeDependencyCheck checks each dependency with require.resolve, then PATH
+func generateNodeDependencyCheck(deps []string) string {
+	return fmt.Sprintf(`// Dependency check
+{
+  const p42Fs = require("fs");
+  const p42Path = require("path");
+  const p42OnPath = (dep) => (process.env.PATH || "").split(p42Path.delimiter).some((dir) => {
+    try {
+      p42Fs.accessSync(p42Path.join(dir, dep), p42Fs.constants.X_OK);
+      return true;
+    } catch (e) {
+      return false;
+    }
+  });
+  const p42Missing = %s.filter((dep) => {
+    try {
+      require.resolve(dep);
+      return false;
+    } catch (e) {
+      return !p42OnPath(dep);
+    }
+  });
+  if (p42Missing.length > 0) {
+    console.error("❌ Missing dependencies: " + p42Missing.join(" "));
+    console.error("");
+    console.error("To install dependencies, run:");
+    console.error("  npm install " + p42Missing.join(" ") + "    # Node packages");
+    console.error("  ~/.port42/install-deps.sh " + p42Missing.join(" ") + "    # command-line tools");
+    process.exit(1);
+  }
+}
+
+`, dependencyListLiteral(deps))
+}
+
+// dependencyListLiteral formats deps as a JSON array, which python and
+// node both read as a list literal
+func dependencyListLiteral(deps []string) string {
+	data, _ := json.Marshal(deps)
+	return string(data)
+}
+
+// writeDependencyInstaller writes install-deps.sh to baseDir
+func writeDependencyInstaller(baseDir string) error {
+	installerPath := filepath.Join(baseDir, dependencyInstallerName)
+
+	installer := `#!/bin/bash
+# Port 42 Dependency Installer
+# Generated automatically to help install command dependencies
+
+set -e
+
+echo "🐬 Port 42 Dependency Installer"
+echo ""
+
+# Detect OS
+if [[ "$OSTYPE" == "darwin"* ]]; then
+  OS="macos"
+elif [[ -f /etc/debian_version ]]; then
+  OS="debian"
+elif [[ -f /etc/redhat-release ]]; then
+  OS="redhat"
+else
+  OS="unknown"
+fi
+
+# Function to install a dependency
+install_dep() {
+  local dep=$1
+  echo "📦 Installing $dep..."
+  
+  case "$OS" in
+    macos)
+      if command -v brew &> /dev/null; then
+        brew install "$dep" || true
+      else
+        echo "❌ Homebrew not found. Please install: https://brew.sh"
+        return 1
+      fi
+      ;;
+    debian)
+      sudo apt-get update && sudo apt-get install -y "$dep" || true
+      ;;
+    redhat)
+      sudo yum install -y "$dep" || true
+      ;;
+    *)
+      echo "❌ Unknown OS. Please install $dep manually."
+      return 1
+      ;;
+  esac
+}
+
+# Install each dependency passed as argument
+for dep in "$@"; do
+  if ! command -v "$dep" &> /dev/null; then
+    install_dep "$dep"
+  else
+    echo "✅ $dep is already installed"
+  fi
+done
+
+echo ""
+echo "✨ Installation complete!"
+`
+
+	return os.WriteFile(installerPath, []byte(installer), 0755)
+}
+
+// missingDependencies returns the deps that are neither on PATH nor, for
+// python and node tools, importable by that runtime
+func missingDependencies(language string, deps []string) []string {
+	missing := []string{}
+	for _, dep := range validDependencies(deps) {
+		if _, err := exec.LookPath(dep); err != nil {
+			missing = append(missing, dep)
+		}
+	}
+	if len(missing) == 0 {
+		return missing
+	}
+
+	var probe *exec.Cmd
+	switch language {
+	case "python":
+		probe = exec.Command("python3", append([]string{"-c", `import importlib.util, sys
+for dep in sys.argv[1:]:
+    try:
+        if importlib.util.find_spec(dep) is not None:
+            print(dep)
+    except (ImportError, ValueError):
+        pass
+`}, missing...)...)
+	case "node", "javascript":
+		probe = exec.Command("node", append([]string{"-e", `for (const dep of process.argv.slice(1)) {
+  try {
+    require.resolve(dep);
+    console.log(dep);
+  } catch (e) {}
+}`}, missing...)...)
+	default:
+		return missing
+	}
+
+	output, err := probe.Output()
+	if err != nil {
+		log.Printf("⚠️ Could not check %s modules: %v", language, err)
+		return missing
+	}
+	importable := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		importable[strings.TrimSpace(line)] = true
+	}
+	stillMissing := []string{}
+	for _, dep := range missing {
+		if !importable[dep] {
+			stillMissing = append(stillMissing, dep)
+		}
+	}
+	return stillMissing
+}
+
+// DependencyReport describes a declared tool's dependencies in the declare response
+type DependencyReport struct {
+	Declared  []string `json:"declared"`
+	Missing   []string `json:"missing"`
+	Attempted bool     `json:"install_attempted"`
+	Installed []string `json:"installed,omitempty"`
+	Failed    []string `json:"failed,omitempty"`
+	Output    string   `json:"output,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// checkDependencies reports which deps are missing and, when autoInstall
+// is set, runs install-deps.sh for them. Deps already on PATH or importable
+// are never passed to the installer.
+func checkDependencies(baseDir, language string, deps []string, autoInstall bool) *DependencyReport {
+	report := &DependencyReport{
+		Declared: validDependencies(deps),
+		Missing:  missingDependencies(language, deps),
+	}
+	if !autoInstall || len(report.Missing) == 0 {
+		return report
+	}
+
+	dependencyInstallMu.Lock()
+	defer dependencyInstallMu.Unlock()
+
+	if err := writeDependencyInstaller(baseDir); err != nil {
+		report.Error = fmt.Sprintf("failed to write installer: %v", err)
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dependencyInstallTimeout)
+	defer cancel()
+
+	log.Printf("📦 Installing dependencies: %v", report.Missing)
+	report.Attempted = true
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, filepath.Join(baseDir, dependencyInstallerName), report.Missing...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		report.Error = err.Error()
+		if ctx.Err() != nil {
+			report.Error = fmt.Sprintf("installer timed out after %v", dependencyInstallTimeout)
+		}
+	}
+	report.Output = output.String()
+	if len(report.Output) > maxInstallOutput {
+		report.Output = report.Output[len(report.Output)-maxInstallOutput:]
+	}
+
+	// The installer swallows package manager errors, so check again
+	stillMissing := make(map[string]bool)
+	for _, dep := range missingDependencies(language, report.Missing) {
+		stillMissing[dep] = true
+	}
+	for _, dep := range report.Missing {
+		if stillMissing[dep] {
+			report.Failed = append(report.Failed, dep)
+		} else {
+			report.Installed = append(report.Installed, dep)
+		}
+	}
+	log.Printf("📦 Dependency install finished: installed %v, failed %v", report.Installed, report.Failed)
+	return report
+}
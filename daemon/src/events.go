@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watch targets. A streaming watch subscribes to one of these; the rules
+// target also accepts a one-shot snapshot of rule status.
+const (
+	WatchRules     = "rules"     // rule_triggered, rule_completed, rule_failed
+	WatchRelations = "relations" // relation_declared, relation_materialized, relation_failed
+	WatchTools     = "tools"     // tool_declared, tool_materialized, tool_failed
+	WatchMemory    = "memory"    // memory_created
+)
+
+// FrameEvent carries one WatchData on a streaming watch connection
+const FrameEvent = "event"
+
+// eventBufferSize is how many events a watcher may fall behind before
+// further events are dropped for it
+const eventBufferSize = 64
+
+// isWatchTarget reports whether target can be streamed
+func isWatchTarget(target string) bool {
+	switch target {
+	case WatchRules, WatchRelations, WatchTools, WatchMemory:
+		return true
+	}
+	return false
+}
+
+// EventBus fans daemon activity out to streaming watchers. Publishing never
+// blocks: a watcher that can't keep up misses events rather than stalling
+// the rule engine or a declare. A nil bus discards everything.
+type EventBus struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]*eventSubscriber
+}
+
+// eventSubscriber is one watcher's queue
+type eventSubscriber struct {
+	target  string
+	events  chan WatchData
+	dropped int
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[int]*eventSubscriber)}
+}
+
+// Subscribe returns a channel of events for target and a function that
+// unsubscribes and closes it
+func (b *EventBus) Subscribe(target string) (<-chan WatchData, func()) {
+	sub := &eventSubscriber{target: target, events: make(chan WatchData, eventBufferSize)}
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+			close(sub.events)
+			if sub.dropped > 0 {
+				log.Printf("⚠️ Watcher on %s missed %d events", target, sub.dropped)
+			}
+		})
+	}
+}
+
+// Publish sends an event to every watcher of target
+func (b *EventBus) Publish(target string, event WatchData) {
+	if b == nil {
+		return
+	}
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	event.Target = target
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range b.subs {
+		if sub.target != target {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// publishRelation reports a declared relation on the relations target, and
+// on the tools target too for Tool relations. stage is declared,
+// materialized or failed; path is where a materialized relation landed.
+func (b *EventBus) publishRelation(relation Relation, stage, path, details string) {
+	if b == nil {
+		return
+	}
+	event := WatchData{
+		RelationID:   relation.ID,
+		RelationType: relation.Type,
+		Name:         getRelationName(relation),
+		Path:         path,
+		Details:      details,
+	}
+
+	event.Type = "relation_" + stage
+	b.Publish(WatchRelations, event)
+
+	if relation.Type == "Tool" {
+		event.Type = "tool_" + stage
+		b.Publish(WatchTools, event)
+	}
+}
+
+// publishRule reports rule engine activity for one relation
+func (b *EventBus) publishRule(rule Rule, relation Relation, eventType, details string) {
+	b.Publish(WatchRules, WatchData{
+		Type:         eventType,
+		RuleID:       rule.ID,
+		RuleName:     rule.Name,
+		RelationID:   relation.ID,
+		RelationType: relation.Type,
+		Name:         getRelationName(relation),
+		Details:      details,
+	})
+}
+
+// ruleStatusSnapshot describes every rule's current state, which is what a
+// one-shot rules watch returns and what a streaming one starts with
+func ruleStatusSnapshot(compiler *RealityCompiler) []WatchData {
+	now := time.Now().Format(time.RFC3339)
+	if compiler == nil || compiler.ruleEngine == nil {
+		return []WatchData{{
+			Timestamp: now,
+			Type:      "status",
+			RuleID:    "system",
+			RuleName:  "Rule Engine Status",
+			Details:   "Rule engine not initialized",
+		}}
+	}
+
+	var snapshot []WatchData
+	for _, rule := range compiler.ruleEngine.ListRules() {
+		status := "enabled"
+		if !rule.Enabled {
+			status = "disabled"
+		}
+		snapshot = append(snapshot, WatchData{
+			Timestamp: now,
+			Type:      "rule_status",
+			RuleID:    rule.ID,
+			RuleName:  rule.Name,
+			Details:   fmt.Sprintf("Status: %s, Description: %s", status, rule.Description),
+		})
+	}
+	return snapshot
+}
+
+// streamWatch keeps a watch connection open, writing an event frame per
+// published event until the client disconnects or sends FrameStop, or the
+// daemon shuts down. The final frame is a complete response.
+func (d *Daemon) streamWatch(client *clientConn, req Request, target string) {
+	clientAddr := client.conn.RemoteAddr().String()
+	target = strings.ToLower(target)
+	if !isWatchTarget(target) {
+		client.send(NewErrorResponse(req.ID, fmt.Sprintf("Unsupported watch target: %s", target)))
+		return
+	}
+
+	// Subscribe before the snapshot so nothing slips between the two
+	events, unsubscribe := d.events.Subscribe(target)
+	defer unsubscribe()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	client.startKeepalive(d.config.KeepaliveInterval, stop)
+	gone := client.watchClientFrames()
+
+	log.Printf("👁️ Streaming %s events to %s", target, clientAddr)
+
+	sendEvent := func(event WatchData) error {
+		frame := NewResponse(req.ID, true)
+		frame.Frame = FrameEvent
+		frame.SetData(event)
+		return client.send(frame)
+	}
+
+	if target == WatchRules {
+		for _, status := range ruleStatusSnapshot(d.realityCompiler) {
+			if err := sendEvent(status); err != nil {
+				return
+			}
+		}
+	}
+
+	sent := 0
+	reason := ""
+	for reason == "" {
+		select {
+		case event := <-events:
+			if err := sendEvent(event); err != nil {
+				log.Printf("⚠️ Failed to send %s event to %s: %v", target, clientAddr, err)
+				return
+			}
+			sent++
+		case <-gone:
+			reason = "client stopped"
+		case <-d.shutdownCh:
+			reason = "daemon shutting down"
+		}
+	}
+
+	// A disconnected client won't read this; a stopped one gets a clean end
+	done := NewResponse(req.ID, true)
+	done.Frame = FrameComplete
+	done.SetData(map[string]interface{}{
+		"target": target,
+		"events": sent,
+		"reason": reason,
+	})
+	client.send(done)
+	log.Printf("👁️ Stopped streaming %s events to %s after %d events (%s)", target, clientAddr, sent, reason)
+}
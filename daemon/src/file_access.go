@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileAccessPolicy decides which local files file: references may read.
+// Entries are roots: a path is covered when it is the root or inside it.
+// Roots may use ~ for the home directory, "." for the daemon's working
+// directory, and glob wildcards within a segment (e.g. "~/src/*/docs").
+// The most specific matching root decides, with deny winning a tie, so
+// "~/private" can be carved out of "~" and a project under /var can still
+// be allowed. Paths matching no root are refused.
+type FileAccessPolicy struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+	// IncludeDefaults merges the built-in roots in (default true); set it
+	// to false to use only the roots listed in the file
+	IncludeDefaults *bool `json:"include_defaults,omitempty"`
+
+	source string // Config file the policy came from, empty for defaults
+}
+
+// fileAccessConfigName is read from the Port 42 base directory
+const fileAccessConfigName = "file-access.json"
+
+// Built-in roots: the working directory, ~/.port42 and the rest of home,
+// but never system directories
+var (
+	defaultFileAccessAllow = []string{".", "~/.port42", "~"}
+	defaultFileAccessDeny  = []string{"/etc", "/usr", "/var", "/bin", "/sbin", "/sys", "/proc"}
+)
+
+// credentialDirs are refused wherever they appear, whatever the policy says
+var credentialDirs = []string{".ssh", ".gnupg", ".aws"}
+
+// defaultFileAccessPolicy is the policy used without a config file
+func defaultFileAccessPolicy() *FileAccessPolicy {
+	return &FileAccessPolicy{
+		Allow: append([]string{}, defaultFileAccessAllow...),
+		Deny:  append([]string{}, defaultFileAccessDeny...),
+	}
+}
+
+// loadFileAccessPolicy reads baseDir/file-access.json and merges it with the
+// defaults. A missing file means defaults; an unreadable one is logged and
+// also falls back to defaults rather than opening anything up.
+func loadFileAccessPolicy(baseDir string) *FileAccessPolicy {
+	configPath := filepath.Join(baseDir, fileAccessConfigName)
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to read %s, using default file access policy: %v", configPath, err)
+		}
+		return defaultFileAccessPolicy()
+	}
+
+	var configured FileAccessPolicy
+	if err := json.Unmarshal(data, &configured); err != nil {
+		log.Printf("⚠️ Failed to parse %s, using default file access policy: %v", configPath, err)
+		return defaultFileAccessPolicy()
+	}
+
+	policy := &FileAccessPolicy{source: configPath}
+	if configured.IncludeDefaults == nil || *configured.IncludeDefaults {
+		policy.Allow = append(policy.Allow, defaultFileAccessAllow...)
+		policy.Deny = append(policy.Deny, defaultFileAccessDeny...)
+	}
+	policy.Allow = append(policy.Allow, configured.Allow...)
+	policy.Deny = append(policy.Deny, configured.Deny...)
+
+	// Matching a root against itself only surfaces glob syntax errors
+	for _, root := range append(append([]string{}, policy.Allow...), policy.Deny...) {
+		if _, err := filepath.Match(root, root); err != nil {
+			log.Printf("⚠️ Invalid file access root %q in %s: %v", root, configPath, err)
+		}
+	}
+	return policy
+}
+
+// String summarizes the effective policy for the startup log
+func (p *FileAccessPolicy) String() string {
+	source := "defaults"
+	if p.source != "" {
+		source = p.source
+	}
+	return fmt.Sprintf("allow %v, deny %v (from %s; %s always denied)",
+		p.Allow, p.Deny, source, strings.Join(credentialDirs, ", "))
+}
+
+// Allows reports whether absPath may be read
+func (p *FileAccessPolicy) Allows(absPath string) bool {
+	absPath = filepath.Clean(absPath)
+
+	// Safety rail that no config can lift
+	for _, segment := range strings.Split(absPath, string(filepath.Separator)) {
+		for _, dir := range credentialDirs {
+			if segment == dir {
+				return false
+			}
+		}
+	}
+
+	allowDepth, denyDepth := -1, -1
+	for _, root := range p.Allow {
+		if depth := accessRootDepth(absPath, root); depth > allowDepth {
+			allowDepth = depth
+		}
+	}
+	for _, root := range p.Deny {
+		if depth := accessRootDepth(absPath, root); depth > denyDepth {
+			denyDepth = depth
+		}
+	}
+	return allowDepth >= 0 && allowDepth > denyDepth
+}
+
+// accessRootDepth returns how many segments root has if path is root or
+// inside it, matching each segment of the expanded root as a glob, or -1
+func accessRootDepth(path, root string) int {
+	root = expandAccessRoot(root)
+	if root == "" {
+		return -1
+	}
+	if root == string(filepath.Separator) {
+		return 0
+	}
+
+	rootParts := strings.Split(root, string(filepath.Separator))
+	pathParts := strings.Split(path, string(filepath.Separator))
+	if len(pathParts) < len(rootParts) {
+		return -1
+	}
+	for i, part := range rootParts {
+		if matched, err := filepath.Match(part, pathParts[i]); err != nil || !matched {
+			return -1
+		}
+	}
+	return len(rootParts)
+}
+
+// expandAccessRoot resolves ~ and "." in a root to an absolute, clean path
+func expandAccessRoot(root string) string {
+	root = strings.TrimSpace(root)
+	switch {
+	case root == "":
+		return ""
+	case root == "~" || strings.HasPrefix(root, "~/"):
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		root = filepath.Join(homeDir, root[1:])
+	case !filepath.IsAbs(root):
+		// Relative roots follow the working directory, like relative file: targets
+		cwd, err := os.Getwd()
+		if err != nil {
+			return ""
+		}
+		root = filepath.Join(cwd, root)
+	}
+	return filepath.Clean(root)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"port42/daemon/resolution"
+)
+
+// Limits for file: references. Every file is held to maxReferenceFileSize;
+// globs and directories also stop after maxFileSetFiles files or
+// maxFileSetSize bytes in total.
+const (
+	maxReferenceFileSize = 1 * 1024 * 1024 // 1MB
+	maxFileSetFiles      = 100
+	maxFileSetSize       = 1 * 1024 * 1024 // 1MB
+)
+
+// skippedReferenceDirs are never descended into when a directory is referenced
+var skippedReferenceDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "target": true,
+	"dist": true, "build": true, "__pycache__": true, ".venv": true,
+}
+
+// isGlobPattern reports whether a file: target is a pattern rather than a path
+func isGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// handleLocalFileSet resolves a glob pattern or directory into one
+// FileContent holding every allowed file, with the included and skipped
+// paths listed in its metadata. requested is the target as written.
+func (d *Daemon) handleLocalFileSet(requested, absPattern string, glob bool) (*resolution.FileContent, error) {
+	candidates, err := collectReferenceFiles(absPattern, glob)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no files match: %s", requested)
+	}
+
+	included := []string{}
+	skipped := []map[string]interface{}{}
+	skip := func(path, reason string) {
+		skipped = append(skipped, map[string]interface{}{"path": displayReferencePath(path), "reason": reason})
+	}
+
+	var body strings.Builder
+	var total int64
+	limitHit := false
+	for _, candidate := range candidates {
+		if limitHit {
+			skip(candidate, "aggregate limit reached")
+			continue
+		}
+
+		// Same checks as a single file reference, applied to where the file really is
+		realPath, err := filepath.EvalSymlinks(candidate)
+		if err != nil {
+			skip(candidate, "unreadable")
+			continue
+		}
+		if !d.isFileAccessAllowed(realPath) {
+			log.Printf("🚨 SECURITY WARNING: File access boundary violation blocked - %s", candidate)
+			skip(candidate, "access not allowed")
+			continue
+		}
+		if !d.isFileTypeAllowed(realPath) {
+			skip(candidate, "file type not allowed")
+			continue
+		}
+		info, err := os.Stat(realPath)
+		if err != nil || !info.Mode().IsRegular() {
+			skip(candidate, "not a regular file")
+			continue
+		}
+		if info.Size() > maxReferenceFileSize {
+			skip(candidate, fmt.Sprintf("too large (%d bytes)", info.Size()))
+			continue
+		}
+		if len(included) >= maxFileSetFiles || total+info.Size() > maxFileSetSize {
+			limitHit = true
+			skip(candidate, "aggregate limit reached")
+			continue
+		}
+
+		content, err := os.ReadFile(realPath)
+		if err != nil {
+			skip(candidate, "unreadable")
+			continue
+		}
+
+		display := displayReferencePath(candidate)
+		fmt.Fprintf(&body, "=== %s (%d bytes) ===\n%s\n\n", display, len(content), strings.TrimRight(string(content), "\n"))
+		included = append(included, display)
+		total += int64(len(content))
+	}
+
+	if len(included) == 0 {
+		return nil, fmt.Errorf("no allowed files in %s (%d skipped)", requested, len(skipped))
+	}
+
+	kind := "directory"
+	if glob {
+		kind = "glob"
+	}
+	log.Printf("✅ Local file set accessed: %s (%d files, %d bytes, %d skipped)", requested, len(included), total, len(skipped))
+
+	return &resolution.FileContent{
+		Path:    requested,
+		Content: body.String(),
+		Size:    total,
+		Type:    "text/file-set",
+		Metadata: map[string]interface{}{
+			"absolute_path": absPattern,
+			"kind":          kind,
+			"files":         included,
+			"skipped":       skipped,
+			"truncated":     limitHit,
+		},
+	}, nil
+}
+
+// collectReferenceFiles lists the files a glob matches, or every file under
+// a directory outside skippedReferenceDirs and hidden directories, sorted
+func collectReferenceFiles(absPattern string, glob bool) ([]string, error) {
+	if glob {
+		matches, err := filepath.Glob(absPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern: %w", err)
+		}
+		var files []string
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				files = append(files, match)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(absPattern, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are left out
+		}
+		if entry.IsDir() {
+			name := entry.Name()
+			if path != absPattern && (skippedReferenceDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Stop walking huge trees early; the aggregate limit cuts far sooner
+		if len(files) >= maxFileSetFiles*10 {
+			return filepath.SkipAll
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// displayReferencePath shows a file relative to the working directory when it is inside it
+func displayReferencePath(path string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/fs"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,7 +11,9 @@ import (
 
 // FileRelationStore implements RelationStore using JSON files
 type FileRelationStore struct {
-	baseDir string // ~/.port42/relations/
+	baseDir string        // ~/.port42/relations/
+	locks   idLocks       // Per-relation write locks
+	index   relationIndex // Every relation, kept current by save and Delete
 }
 
 // NewFileRelationStore creates a new file-based relation store
@@ -31,6 +32,31 @@ func NewFileRelationStore(baseDir string) (*FileRelationStore, error) {
 
 // Save stores a relation as a JSON file
 func (store *FileRelationStore) Save(relation Relation) error {
+	unlock := store.locks.Lock(relation.ID)
+	defer unlock()
+	return store.save(relation)
+}
+
+// Update loads a relation, applies update to it and saves the result while
+// holding the relation's lock, so concurrent updates can't lose each other's
+// changes. update must not save relations itself.
+func (store *FileRelationStore) Update(id string, update func(*Relation) error) error {
+	unlock := store.locks.Lock(id)
+	defer unlock()
+	
+	relation, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	if err := update(relation); err != nil {
+		return err
+	}
+	relation.ID = id // An update may not move the relation
+	return store.save(*relation)
+}
+
+// save writes a relation file; callers hold the relation's lock
+func (store *FileRelationStore) save(relation Relation) error {
 	filename := fmt.Sprintf("relation-%s.json", relation.ID)
 	filePath := filepath.Join(store.baseDir, filename)
 	
@@ -40,19 +66,31 @@ func (store *FileRelationStore) Save(relation Relation) error {
 		if fetchedAtInt, ok := relation.Properties["fetched_at"].(int64); ok {
 			fetchedAt = float64(fetchedAtInt)
 		}
-		log.Printf("🔍 [STORE] Saving URLArtifact %s: fetched_at=%v, UpdatedAt=%s", 
+		logger.Debugf("🔍 [STORE] Saving URLArtifact %s: fetched_at=%v, UpdatedAt=%s", 
 			relation.ID, fetchedAt, relation.UpdatedAt.Format("2006-01-02 15:04:05"))
 	}
 	
+	normalizeRelation(&relation)
 	data, err := json.MarshalIndent(relation, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal relation: %w", err)
 	}
 	
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	// Written atomically so Load and List never see a half-written file
+	if err := atomicWriteFile(filePath, data, 0644); err != nil {
+		store.index.invalidate()
 		return fmt.Errorf("failed to write relation file: %w", err)
 	}
 	
+	// Indexed as it reads back, so List returns what Load would
+	var written Relation
+	if err := json.Unmarshal(data, &written); err != nil {
+		store.index.invalidate()
+		return nil
+	}
+	normalizeRelation(&written)
+	store.index.put(store.baseDir, written)
+	
 	return nil
 }
 
@@ -73,6 +111,7 @@ func (store *FileRelationStore) Load(id string) (*Relation, error) {
 	if err := json.Unmarshal(data, &relation); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal relation: %w", err)
 	}
+	normalizeRelation(&relation) // Files written before normalization existed
 	
 	// DEBUG: Log what we're loading for URLArtifacts
 	if relation.Type == "URLArtifact" {
@@ -80,7 +119,7 @@ func (store *FileRelationStore) Load(id string) (*Relation, error) {
 		if fetchedAtInt, ok := relation.Properties["fetched_at"].(int64); ok {
 			fetchedAt = float64(fetchedAtInt)
 		}
-		log.Printf("🔍 [LOAD] Loading URLArtifact %s: fetched_at=%v, UpdatedAt=%s", 
+		logger.Debugf("🔍 [LOAD] Loading URLArtifact %s: fetched_at=%v, UpdatedAt=%s", 
 			relation.ID, fetchedAt, relation.UpdatedAt.Format("2006-01-02 15:04:05"))
 	}
 	
@@ -89,19 +128,7 @@ func (store *FileRelationStore) Load(id string) (*Relation, error) {
 
 // LoadByType retrieves all relations of a specific type
 func (store *FileRelationStore) LoadByType(relationType string) ([]Relation, error) {
-	relations, err := store.List()
-	if err != nil {
-		return nil, err
-	}
-	
-	var filtered []Relation
-	for _, rel := range relations {
-		if rel.Type == relationType {
-			filtered = append(filtered, rel)
-		}
-	}
-	
-	return filtered, nil
+	return store.ListByType(relationType)
 }
 
 // LoadByProperty retrieves relations with a specific property value
@@ -125,21 +152,65 @@ func (store *FileRelationStore) LoadByProperty(key, value string) ([]Relation, e
 
 // Delete removes a relation
 func (store *FileRelationStore) Delete(id string) error {
+	unlock := store.locks.Lock(id)
+	defer unlock()
+	
 	filename := fmt.Sprintf("relation-%s.json", id)
 	filePath := filepath.Join(store.baseDir, filename)
 	
 	if err := os.Remove(filePath); err != nil {
 		if os.IsNotExist(err) {
+			store.index.remove(store.baseDir, id)
 			return fmt.Errorf("relation not found: %s", id)
 		}
+		store.index.invalidate()
 		return fmt.Errorf("failed to delete relation: %w", err)
 	}
+	store.index.remove(store.baseDir, id)
 	
 	return nil
 }
 
-// List retrieves all relations
+// List retrieves all relations, ordered by ID, from the in-memory index.
+// The relations directory is only read the first time and after something
+// other than this store changed it.
 func (store *FileRelationStore) List() ([]Relation, error) {
+	for {
+		if err := store.index.ensure(store.baseDir, store.listFromDisk); err != nil {
+			return nil, err
+		}
+		if relations, ok := store.index.list(); ok {
+			return relations, nil
+		}
+	}
+}
+
+// ListByType retrieves the relations of one type from the index, without
+// going through every relation
+func (store *FileRelationStore) ListByType(relationType string) ([]Relation, error) {
+	for {
+		if err := store.index.ensure(store.baseDir, store.listFromDisk); err != nil {
+			return nil, err
+		}
+		if relations, ok := store.index.listByType(relationType); ok {
+			return relations, nil
+		}
+	}
+}
+
+// InvalidateListCache drops the index, for callers that changed relation
+// files directly; the next List rebuilds it
+func (store *FileRelationStore) InvalidateListCache() {
+	store.index.invalidate()
+}
+
+// ListCacheStats reports how List and ListByType were served
+func (store *FileRelationStore) ListCacheStats() RelationCacheStats {
+	return store.index.stats()
+}
+
+// listFromDisk reads every relation file
+func (store *FileRelationStore) listFromDisk() ([]Relation, error) {
 	var relations []Relation
 	
 	err := filepath.WalkDir(store.baseDir, func(path string, d fs.DirEntry, err error) error {
@@ -160,6 +231,7 @@ func (store *FileRelationStore) List() ([]Relation, error) {
 		if err := json.Unmarshal(data, &relation); err != nil {
 			return fmt.Errorf("failed to unmarshal relation file %s: %w", path, err)
 		}
+		normalizeRelation(&relation)
 		
 		relations = append(relations, relation)
 		return nil
@@ -200,7 +272,7 @@ func (fms *FileMaterializationStore) Save(entity MaterializedEntity) error {
 		return fmt.Errorf("failed to marshal materialization: %w", err)
 	}
 	
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := atomicWriteFile(filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write materialization file: %w", err)
 	}
 	
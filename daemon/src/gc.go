@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// gcGracePeriod protects objects that were just written and may not have
+// their metadata or relation saved yet
+const gcGracePeriod = 10 * time.Minute
+
+// GCOrphan is an object with no remaining referrers
+type GCOrphan struct {
+	ID     string `json:"id"`
+	Size   int64  `json:"size"`
+	Type   string `json:"type,omitempty"`
+	Reason string `json:"reason"` // "no_metadata", "no_paths", or "superseded"
+}
+
+// GCReport summarizes a garbage collection pass
+type GCReport struct {
+	DryRun         bool       `json:"dry_run"`
+	ScannedObjects int        `json:"scanned_objects"`
+	Referenced     int        `json:"referenced"`
+	SkippedRecent  int        `json:"skipped_recent"`
+	Orphans        []GCOrphan `json:"orphans"`
+	DeletedObjects int        `json:"deleted_objects"`
+	DeletedChunks  int        `json:"deleted_chunks"`
+	FreedBytes     int64      `json:"freed_bytes"`
+}
+
+// GC finds objects nothing refers to and deletes them, along with their
+// metadata. An object is kept if any of these refer to it:
+//   - its metadata has a path that no newer object has taken over
+//   - any metadata relationship lists its ID
+//   - any relation property holds its ID (content_id, executable_id, ...)
+//   - it is a version in a tool's history
+//   - the session index points at it, or it is a session version
+//   - a /commands symlink targets it
+func (s *Storage) GC(dryRun bool) (GCReport, error) {
+	report := GCReport{DryRun: dryRun, Orphans: []GCOrphan{}}
+
+	ids, err := s.List()
+	if err != nil {
+		return report, fmt.Errorf("failed to list objects: %w", err)
+	}
+	report.ScannedObjects = len(ids)
+
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		exists[id] = true
+	}
+
+	refs, err := s.gcReferences(exists)
+	if err != nil {
+		return report, err
+	}
+	referenced, reasons, metaByID := refs.referenced, refs.reasons, refs.metaByID
+
+	report.Referenced = len(referenced)
+
+	// Everything else is an orphan, unless it was written very recently
+	sort.Strings(ids)
+	for _, id := range ids {
+		if referenced[id] {
+			continue
+		}
+		modTime, size := s.objectFileInfo(id)
+		if time.Since(modTime) < gcGracePeriod {
+			report.SkippedRecent++
+			continue
+		}
+
+		orphan := GCOrphan{ID: id, Size: size, Reason: "no_metadata"}
+		if meta, ok := metaByID[id]; ok {
+			orphan.Type = meta.Type
+			orphan.Reason = reasons[id]
+		}
+		report.Orphans = append(report.Orphans, orphan)
+
+		if dryRun {
+			report.FreedBytes += size
+			if info, err := os.Stat(filepath.Join(s.metadataDir, id+".json")); err == nil {
+				report.FreedBytes += info.Size()
+			}
+			continue
+		}
+
+		freed, err := s.deleteObject(id)
+		if err != nil {
+			log.Printf("⚠️ [GC] Failed to delete %s: %v", id[:12]+"...", err)
+			continue
+		}
+		report.DeletedObjects++
+		report.FreedBytes += freed
+	}
+
+	// Chunks no longer listed by any manifest
+	if chunked, ok := s.objects.(*ChunkedObjectStore); ok && !dryRun {
+		count, freed := chunked.SweepChunks()
+		s.objectBytes.Add(-freed)
+		report.DeletedChunks = count
+		report.FreedBytes += freed
+	}
+
+	log.Printf("🗑️ [GC] Scanned %d objects: %d referenced, %d orphaned, %d deleted, %d bytes freed (dry_run=%v)",
+		report.ScannedObjects, report.Referenced, len(report.Orphans), report.DeletedObjects, report.FreedBytes, dryRun)
+	return report, nil
+}
+
+// gcRefs is what the reference pass of GC found
+type gcRefs struct {
+	referenced map[string]bool
+	reasons    map[string]string // Why a described object isn't referenced
+	metaByID   map[string]*Metadata
+}
+
+// gcReferences works out which of the existing objects something still
+// refers to, following the rules GC documents
+func (s *Storage) gcReferences(exists map[string]bool) (gcRefs, error) {
+	referenced := make(map[string]bool)
+	reference := func(id string) {
+		if exists[id] {
+			referenced[id] = true
+		}
+	}
+
+	// Metadata: live paths, sessions, and relationship links
+	docs, _ := s.searchIndex.snapshot("", "")
+	metaByID := make(map[string]*Metadata, len(docs))
+	pathOwner := make(map[string]*Metadata)
+	for _, meta := range docs {
+		metaByID[meta.ID] = meta
+		for _, path := range meta.Paths {
+			if owner, ok := pathOwner[path]; !ok || meta.Modified.After(owner.Modified) {
+				pathOwner[path] = meta
+			}
+		}
+		for _, linked := range [][]string{
+			meta.Relationships.ParentArtifacts,
+			meta.Relationships.ChildArtifacts,
+			meta.Relationships.GeneratedCommands,
+			meta.Relationships.References,
+		} {
+			for _, id := range linked {
+				reference(id)
+			}
+		}
+	}
+
+	reasons := make(map[string]string)
+	for _, meta := range docs {
+		// Every session version is kept; session history lives in them
+		if meta.Type == "session" {
+			reference(meta.ID)
+			continue
+		}
+		if len(meta.Paths) == 0 {
+			reasons[meta.ID] = "no_paths"
+			continue
+		}
+		// Kept while it still owns at least one of its paths
+		superseded := true
+		for _, path := range meta.Paths {
+			if pathOwner[path] == meta {
+				superseded = false
+				break
+			}
+		}
+		if superseded {
+			reasons[meta.ID] = "superseded"
+			continue
+		}
+		reference(meta.ID)
+	}
+
+	// Relations, the session index and command symlinks
+	var relations []Relation
+	if s.relationStore != nil {
+		var err error
+		relations, err = s.relationStore.List()
+		if err != nil {
+			return gcRefs{}, fmt.Errorf("failed to load relations: %w", err)
+		}
+	}
+	s.forEachReference(relations, reference)
+
+	return gcRefs{referenced: referenced, reasons: reasons, metaByID: metaByID}, nil
+}
+
+// forEachReference calls reference with every object ID that relations,
+// the session index or a /commands symlink point at
+func (s *Storage) forEachReference(relations []Relation, reference func(id string)) {
+	// Relations: any string property that names an object
+	for _, relation := range relations {
+		for _, value := range relation.Properties {
+			if id, ok := value.(string); ok {
+				reference(id)
+			}
+		}
+		for _, version := range toolVersions(relation) {
+			reference(version.ObjectID)
+		}
+	}
+
+	// Session index
+	s.indexMutex.RLock()
+	if s.sessionIndex != nil {
+		for _, ref := range s.sessionIndex.Sessions {
+			reference(ref.ObjectID)
+		}
+	}
+	s.indexMutex.RUnlock()
+
+	// Command symlinks
+	if entries, err := os.ReadDir(filepath.Join(s.baseDir, "commands")); err == nil {
+		for _, entry := range entries {
+			reference(s.commandObjectID(entry.Name()))
+		}
+	}
+}
+
+// objectFileInfo returns the modification time and on-disk size of an object
+func (s *Storage) objectFileInfo(id string) (time.Time, int64) {
+	paths := []string{s.GetPath(id)}
+	if chunked, ok := s.objects.(*ChunkedObjectStore); ok {
+		paths = append(paths, chunked.manifests.path(id)+".json")
+	}
+
+	var modTime time.Time
+	var size int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+			if info.ModTime().After(modTime) {
+				modTime = info.ModTime()
+			}
+		}
+	}
+	return modTime, size
+}
+
+// deleteObject removes an object and its metadata, returning the bytes freed
+func (s *Storage) deleteObject(id string) (int64, error) {
+	freed, _, err := s.deleteObjectIf(id, nil)
+	return freed, err
+}
+
+// deleteObjectIf is deleteObject, but only once still approves the object's
+// current metadata, checked under its metadata and object locks so an edit
+// racing the deletion either lands first and is seen or waits. With still
+// nil the object is always deleted. deleted is false when still refused or
+// the metadata couldn't be read.
+func (s *Storage) deleteObjectIf(id string, still func(meta *Metadata) bool) (freed int64, deleted bool, err error) {
+	unlockMetadata := s.metadataLocks.Lock(id)
+	defer unlockMetadata()
+	unlockObject := s.objectLocks.Lock(id)
+	defer unlockObject()
+
+	if still != nil {
+		meta, err := s.readMetadataFile(id)
+		if err != nil || !still(meta) {
+			return 0, false, nil
+		}
+	}
+
+	freed, err = s.objects.Delete(id)
+	s.objectBytes.Add(-freed)
+	if err != nil {
+		return freed, false, err
+	}
+
+	metaPath := filepath.Join(s.metadataDir, id+".json")
+	if info, err := os.Stat(metaPath); err == nil {
+		if err := os.Remove(metaPath); err != nil {
+			return freed, false, fmt.Errorf("failed to remove metadata: %w", err)
+		}
+		s.metadataBytes.Add(-info.Size())
+		freed += info.Size()
+	}
+	s.searchIndex.remove(id)
+
+	return freed, true, nil
+}
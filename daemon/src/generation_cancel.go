@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// inFlightGeneration is a possess request whose AI calls can be cancelled
+type inFlightGeneration struct {
+	sessionID string
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// generationRegistry tracks in-flight possess generations by request ID.
+// The zero value is ready to use.
+type generationRegistry struct {
+	mu          sync.Mutex
+	generations map[string]*inFlightGeneration
+}
+
+// start registers a generation and returns the context its provider calls
+// run under. Call done when the request finishes.
+func (r *generationRegistry) start(requestID, sessionID string) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	generation := &inFlightGeneration{sessionID: sessionID, cancel: cancel}
+
+	r.mu.Lock()
+	if r.generations == nil {
+		r.generations = make(map[string]*inFlightGeneration)
+	}
+	r.generations[requestID] = generation
+	r.mu.Unlock()
+
+	return ctx, func() {
+		r.mu.Lock()
+		// A newer request reusing the ID keeps its entry
+		if r.generations[requestID] == generation {
+			delete(r.generations, requestID)
+		}
+		r.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel stops the generation for requestID, or every generation in
+// sessionID when requestID is empty. Returns the request IDs it stopped;
+// generations already cancelled aren't counted again.
+func (r *generationRegistry) cancel(requestID, sessionID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stopped := []string{}
+	for id, generation := range r.generations {
+		if requestID != "" && id != requestID {
+			continue
+		}
+		if requestID == "" && generation.sessionID != sessionID {
+			continue
+		}
+		if generation.cancelled {
+			continue
+		}
+		generation.cancelled = true
+		generation.cancel()
+		stopped = append(stopped, id)
+	}
+	return stopped
+}
+
+// handleCancel aborts an in-flight possess generation, named by the
+// request ID it was sent with or by its session
+func (d *Daemon) handleCancel(req Request) Response {
+	var payload struct {
+		RequestID string `json:"request_id"`
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if payload.RequestID == "" && payload.SessionID == "" {
+		return NewErrorResponse(req.ID, "request_id or session_id is required")
+	}
+
+	stopped := d.generations.cancel(payload.RequestID, payload.SessionID)
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(map[string]interface{}{
+		"cancelled":   len(stopped) > 0,
+		"request_ids": stopped,
+	})
+	return resp
+}
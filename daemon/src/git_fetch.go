@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"port42/daemon/resolution"
+)
+
+// fetchGitFile reads one file from a remote repository without cloning it:
+// a depth-1, blob-less fetch of the ref into a throwaway repository, then
+// only the requested blob is pulled. Needs git 2.19+ on the PATH.
+func fetchGitFile(ctx context.Context, ref resolution.GitReference, maxSize int64) (*resolution.FileContent, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed")
+	}
+
+	dir, err := os.MkdirTemp("", "port42-git-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fetch directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(args ...string) ([]byte, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		// Never stop for credentials; private repositories just fail
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=true")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("git %s timed out", args[0])
+			}
+			return nil, fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+
+	if _, err := run("init", "-q"); err != nil {
+		return nil, err
+	}
+	// A named remote lets git record it as the promisor for missing blobs
+	if _, err := run("remote", "add", "origin", ref.RemoteURL()); err != nil {
+		return nil, err
+	}
+	if _, err := run("fetch", "-q", "--depth=1", "--filter=blob:none", "origin", ref.Ref); err != nil {
+		return nil, err
+	}
+	commitOut, err := run("rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	commit := strings.TrimSpace(string(commitOut))
+	object := commit + ":" + ref.Path
+
+	// Check the size before pulling the blob itself
+	sizeOut, err := run("cat-file", "-s", object)
+	if err != nil {
+		return nil, fmt.Errorf("file not found in %s@%s: %s", ref.Repo, ref.Ref, ref.Path)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(sizeOut)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected size from git: %q", sizeOut)
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("file too large: %s (size: %d bytes, max: %d bytes)", ref.Path, size, maxSize)
+	}
+
+	content, err := run("cat-file", "blob", object)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ Git file fetched: %s (%d bytes, commit %s)", ref, len(content), commit[:12])
+
+	return &resolution.FileContent{
+		Path:    ref.String(),
+		Content: string(content),
+		Size:    size,
+		Type:    "git",
+		Metadata: map[string]interface{}{
+			"commit":     commit,
+			"repository": ref.RemoteURL(),
+		},
+	}, nil
+}
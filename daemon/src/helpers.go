@@ -1,9 +1,103 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
+// Encodings for read_path content. base64 is the default so binary objects
+// round-trip; utf8 returns text as a plain string.
+const (
+	ReadEncodingBase64 = "base64"
+	ReadEncodingUTF8   = "utf8"
+)
+
+// isBinaryContent reports whether content can't be returned as text: its
+// metadata marks it as media or an archive, or it isn't valid UTF-8, or it
+// contains NUL bytes (which valid UTF-8 allows but no text file has)
+func isBinaryContent(meta *Metadata, content []byte) bool {
+	if meta != nil && isCompressedMedia(meta) {
+		return true
+	}
+	return !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0
+}
+
+// writeAttempts is how many times atomicWriteFile tries a write that fails
+// with a transient error, waiting writeRetryDelay (doubling) in between
+const (
+	writeAttempts   = 3
+	writeRetryDelay = 50 * time.Millisecond
+)
+
+// isTransientWriteError reports whether a failed write may succeed if
+// simply tried again, unlike a full disk or a permission error
+func isTransientWriteError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) ||
+		errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+// atomicWriteFile writes data to a temporary file next to path, fsyncs it,
+// and renames it over path. A crash mid-write leaves the old file intact
+// instead of truncated JSON that breaks startup. Transient failures are
+// retried before the error is returned.
+func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	delay := writeRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := writeFileSynced(path, data, perm)
+		if err == nil || attempt == writeAttempts || !isTransientWriteError(err) {
+			return err
+		}
+		logger.Warnf("⚠️ [STORAGE] Write of %s failed (attempt %d/%d), retrying: %v", path, attempt, writeAttempts, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// writeFileSynced is one attempt of atomicWriteFile
+func writeFileSynced(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	
+	// Persist the rename itself; best effort since not every platform allows it
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
 // extractSessionTags extracts relevant tags from a session
 func extractSessionTags(session *Session) []string {
 	tags := []string{"conversation", "ai", session.Agent}
@@ -69,4 +163,21 @@ func isCommonWord(word string) bool {
 		"before": true, "could": true, "should": true, "other": true, "because": true,
 	}
 	return commonWords[word]
-}
\ No newline at end of file
+}
+// stringList reads a relation property holding a list of strings, which is
+// []string when set in memory and []interface{} once loaded from JSON
+func stringList(value interface{}) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		var values []string
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Integrity issue kinds
+const (
+	IssueMissingObject      = "missing_object"      // Metadata for an object that isn't stored
+	IssueMissingMetadata    = "missing_metadata"    // An object nothing describes or refers to
+	IssueDanglingReference  = "dangling_reference"  // A relation's content_id or executable_id names a missing object
+	IssueUnreadableMetadata = "unreadable_metadata" // A metadata file that doesn't parse
+	IssueUnreadableRelation = "unreadable_relation" // A relation file that doesn't parse, which breaks listing relations
+)
+
+// integrityReferenceFields are the relation properties that must name a stored object
+var integrityReferenceFields = []string{"content_id", "executable_id"}
+
+// IntegrityIssue is one inconsistency found by CheckIntegrity
+type IntegrityIssue struct {
+	Kind        string `json:"kind"`
+	ID          string `json:"id"`               // Object ID, or relation ID for relation issues
+	Field       string `json:"field,omitempty"`  // Relation property holding the dangling ID
+	Target      string `json:"target,omitempty"` // The object ID it names
+	Detail      string `json:"detail,omitempty"`
+	Quarantined bool   `json:"quarantined"`
+}
+
+// IntegrityReport summarizes an integrity check
+type IntegrityReport struct {
+	CheckedMetadata  int              `json:"checked_metadata"`
+	CheckedObjects   int              `json:"checked_objects"`
+	CheckedRelations int              `json:"checked_relations"`
+	Issues           []IntegrityIssue `json:"issues"`
+	Quarantined      int              `json:"quarantined"`
+	QuarantineDir    string           `json:"quarantine_dir,omitempty"`
+	Duration         string           `json:"duration"`
+}
+
+// CheckIntegrity scans metadata, objects and relations for entries that no
+// longer agree with each other: metadata whose object is missing, objects
+// with no metadata that nothing else refers to, relations whose content_id
+// or executable_id names a missing object, and files that don't parse.
+// With quarantine set, each inconsistent entry is moved under
+// ~/.port42/quarantine/<time>/ instead of being left in place; nothing is
+// deleted outright. Objects written in the last gcGracePeriod are skipped,
+// since their metadata may still be on its way.
+func (s *Storage) CheckIntegrity(quarantine bool) (IntegrityReport, error) {
+	started := time.Now()
+	report := IntegrityReport{Issues: []IntegrityIssue{}}
+
+	ids, err := s.List()
+	if err != nil {
+		return report, fmt.Errorf("failed to list objects: %w", err)
+	}
+	report.CheckedObjects = len(ids)
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		exists[id] = true
+	}
+
+	var quarantineDir string
+	if quarantine {
+		quarantineDir = filepath.Join(s.baseDir, "quarantine", started.Format("20060102-150405"))
+	}
+	moveAside := func(issue *IntegrityIssue, move func() error) {
+		if !quarantine {
+			return
+		}
+		if err := move(); err != nil {
+			log.Printf("⚠️ [INTEGRITY] Failed to quarantine %s: %v", issue.ID, err)
+			return
+		}
+		issue.Quarantined = true
+		report.Quarantined++
+	}
+
+	// Metadata files, read from disk so unparseable ones are found too
+	described := make(map[string]bool)
+	referenced := make(map[string]bool)
+	entries, err := os.ReadDir(s.metadataDir)
+	if err != nil {
+		return report, fmt.Errorf("failed to read metadata directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		report.CheckedMetadata++
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		path := filepath.Join(s.metadataDir, entry.Name())
+
+		var meta Metadata
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &meta)
+		}
+		if err != nil {
+			issue := IntegrityIssue{Kind: IssueUnreadableMetadata, ID: id, Detail: err.Error()}
+			moveAside(&issue, func() error {
+				return s.quarantineMetadata(quarantineDir, id)
+			})
+			report.Issues = append(report.Issues, issue)
+			continue
+		}
+
+		described[id] = true
+		for _, linked := range [][]string{
+			meta.Relationships.ParentArtifacts,
+			meta.Relationships.ChildArtifacts,
+			meta.Relationships.GeneratedCommands,
+			meta.Relationships.References,
+		} {
+			for _, linkedID := range linked {
+				referenced[linkedID] = true
+			}
+		}
+
+		if !exists[id] {
+			issue := IntegrityIssue{Kind: IssueMissingObject, ID: id, Detail: fmt.Sprintf("%s %q", meta.Type, meta.Title)}
+			moveAside(&issue, func() error {
+				return s.quarantineMetadata(quarantineDir, id)
+			})
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	// Relation files, also read from disk: one that doesn't parse makes
+	// every relation listing fail
+	relationsDir := filepath.Join(s.baseDir, "relations")
+	var relations []Relation
+	if entries, err := os.ReadDir(relationsDir); err == nil {
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasPrefix(name, "relation-") || !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			report.CheckedRelations++
+			relationID := strings.TrimSuffix(strings.TrimPrefix(name, "relation-"), ".json")
+			path := filepath.Join(relationsDir, name)
+			moveRelation := func() error {
+				return moveIntoQuarantine(path, filepath.Join(quarantineDir, "relations", name))
+			}
+
+			var relation Relation
+			data, err := os.ReadFile(path)
+			if err == nil {
+				err = json.Unmarshal(data, &relation)
+			}
+			if err != nil {
+				issue := IntegrityIssue{Kind: IssueUnreadableRelation, ID: relationID, Detail: err.Error()}
+				moveAside(&issue, moveRelation)
+				report.Issues = append(report.Issues, issue)
+				continue
+			}
+
+			var dangling []IntegrityIssue
+			for _, field := range integrityReferenceFields {
+				if target, _ := relation.Properties[field].(string); target != "" && !strings.HasPrefix(target, "relation:") && !exists[target] {
+					dangling = append(dangling, IntegrityIssue{
+						Kind:   IssueDanglingReference,
+						ID:     relation.ID,
+						Field:  field,
+						Target: target,
+						Detail: fmt.Sprintf("%s %q", relation.Type, getRelationName(relation)),
+					})
+				}
+			}
+			if len(dangling) > 0 {
+				moveAside(&dangling[0], moveRelation)
+				for i := range dangling {
+					dangling[i].Quarantined = dangling[0].Quarantined
+				}
+				report.Issues = append(report.Issues, dangling...)
+			}
+			// A relation left in place still keeps its other objects
+			if len(dangling) == 0 || !dangling[0].Quarantined {
+				relations = append(relations, relation)
+			}
+		}
+	}
+	if fileStore, ok := s.relationStore.(*FileRelationStore); ok && report.Quarantined > 0 {
+		fileStore.InvalidateListCache()
+	}
+	s.forEachReference(relations, func(id string) {
+		referenced[id] = true
+	})
+
+	// Objects nothing describes or refers to
+	sort.Strings(ids)
+	for _, id := range ids {
+		if described[id] || referenced[id] {
+			continue
+		}
+		if modTime, _ := s.objectFileInfo(id); time.Since(modTime) < gcGracePeriod {
+			continue
+		}
+		issue := IntegrityIssue{Kind: IssueMissingMetadata, ID: id}
+		moveAside(&issue, func() error {
+			return s.quarantineObject(quarantineDir, id)
+		})
+		report.Issues = append(report.Issues, issue)
+	}
+
+	if report.Quarantined > 0 {
+		report.QuarantineDir = quarantineDir
+	}
+	report.Duration = time.Since(started).Round(time.Millisecond).String()
+	return report, nil
+}
+
+// quarantineMetadata moves a metadata file aside and drops it from search
+func (s *Storage) quarantineMetadata(quarantineDir, id string) error {
+	path := filepath.Join(s.metadataDir, id+".json")
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := moveIntoQuarantine(path, filepath.Join(quarantineDir, "metadata", id+".json")); err != nil {
+		return err
+	}
+	s.metadataBytes.Add(-info.Size())
+	s.searchIndex.remove(id)
+	return nil
+}
+
+// quarantineObject copies an object's content aside, then removes it from
+// the object store. Reading it first works for every object layout.
+func (s *Storage) quarantineObject(quarantineDir, id string) error {
+	content, err := s.objects.Get(id)
+	if err != nil {
+		return fmt.Errorf("failed to read object: %w", err)
+	}
+	dest := filepath.Join(quarantineDir, "objects", id)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := atomicWriteFile(dest, content, 0644); err != nil {
+		return err
+	}
+	_, err = s.deleteObject(id)
+	return err
+}
+
+// moveIntoQuarantine renames path to dest, creating dest's directory
+func moveIntoQuarantine(path, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, dest)
+}
+
+// logIntegrityReport logs an integrity check's summary and each issue
+func logIntegrityReport(report IntegrityReport) {
+	if len(report.Issues) == 0 {
+		log.Printf("✅ [INTEGRITY] %d metadata, %d objects, %d relations consistent (%s)",
+			report.CheckedMetadata, report.CheckedObjects, report.CheckedRelations, report.Duration)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, issue := range report.Issues {
+		counts[issue.Kind]++
+		target := ""
+		if issue.Field != "" {
+			target = fmt.Sprintf(" %s=%s", issue.Field, shortID(issue.Target))
+		}
+		log.Printf("⚠️ [INTEGRITY] %s: %s%s %s (quarantined=%v)", issue.Kind, shortID(issue.ID), target, issue.Detail, issue.Quarantined)
+	}
+	kinds := make([]string, 0, len(counts))
+	for kind, count := range counts {
+		kinds = append(kinds, fmt.Sprintf("%s=%d", kind, count))
+	}
+	sort.Strings(kinds)
+	log.Printf("⚠️ [INTEGRITY] %d issues in %d metadata, %d objects, %d relations (%s), %d quarantined (%s)",
+		len(report.Issues), report.CheckedMetadata, report.CheckedObjects, report.CheckedRelations,
+		strings.Join(kinds, ", "), report.Quarantined, report.Duration)
+	if report.QuarantineDir != "" {
+		log.Printf("📦 [INTEGRITY] Quarantined entries moved to %s", report.QuarantineDir)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// /by-lifecycle/ groups objects by their metadata Lifecycle. Unlike
+// /by-type and /by-agent it isn't stored in metadata paths, since lifecycle
+// changes (HandleDeletePath deprecates objects whose last path is removed),
+// so it is computed from the search index on every listing.
+
+// lifecycleUnset names objects whose metadata has no lifecycle
+const lifecycleUnset = "unset"
+
+// lifecycleState is an object's lifecycle as a directory name
+func lifecycleState(meta *Metadata) string {
+	if state := strings.ToLower(strings.TrimSpace(meta.Lifecycle)); state != "" {
+		return state
+	}
+	return lifecycleUnset
+}
+
+// lifecycleEntryName names an object inside its lifecycle directory: the
+// base of its first path, else its title, else its short ID. Deprecated
+// objects usually have no paths left, which is why the fallbacks exist.
+func lifecycleEntryName(meta *Metadata) string {
+	for _, path := range meta.Paths {
+		if base := filepath.Base(path); base != "" && base != "/" && base != "." {
+			return base
+		}
+	}
+	if title := strings.TrimSpace(strings.ReplaceAll(meta.Title, "/", "-")); title != "" {
+		return title
+	}
+	return meta.ID[:min(12, len(meta.ID))]
+}
+
+// lifecycleObjects maps entry names to the objects in one lifecycle state.
+// Names shared by several objects get the object's short ID appended so
+// every entry resolves to exactly one object.
+func (s *Storage) lifecycleObjects(state string) map[string]*Metadata {
+	docs, _ := s.searchIndex.snapshot("", "")
+
+	byName := make(map[string][]*Metadata)
+	for _, meta := range docs {
+		if lifecycleState(meta) == state {
+			name := lifecycleEntryName(meta)
+			byName[name] = append(byName[name], meta)
+		}
+	}
+
+	objects := make(map[string]*Metadata)
+	for name, metas := range byName {
+		if len(metas) == 1 {
+			objects[name] = metas[0]
+			continue
+		}
+		for _, meta := range metas {
+			objects[name+"-"+meta.ID[:min(8, len(meta.ID))]] = meta
+		}
+	}
+	return objects
+}
+
+// handleByLifecycleView lists the states present at /by-lifecycle/ and the
+// objects in a state at /by-lifecycle/{state}/
+func (s *Storage) handleByLifecycleView(path string) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/by-lifecycle"), "/"), "/")
+
+	if len(parts) == 0 || parts[0] == "" {
+		docs, _ := s.searchIndex.snapshot("", "")
+		counts := make(map[string]int)
+		for _, meta := range docs {
+			counts[lifecycleState(meta)]++
+		}
+		states := make([]string, 0, len(counts))
+		for state := range counts {
+			states = append(states, state)
+		}
+		sort.Strings(states)
+		for _, state := range states {
+			entries = append(entries, map[string]interface{}{
+				"name":  state,
+				"type":  "directory",
+				"count": counts[state],
+			})
+		}
+		return entries
+	}
+
+	if len(parts) > 1 {
+		return entries // Entries are files; there is nothing below them
+	}
+
+	objects := s.lifecycleObjects(strings.ToLower(parts[0]))
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		meta := objects[name]
+		entry := map[string]interface{}{
+			"name":      name,
+			"type":      "file",
+			"id":        meta.ID,
+			"size":      meta.Size,
+			"created":   meta.Created,
+			"modified":  meta.Modified,
+			"lifecycle": meta.Lifecycle,
+			"paths":     cloneStrings(meta.Paths),
+		}
+		if meta.Type != "" {
+			entry["content_type"] = meta.Type
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// resolveByLifecyclePath returns the object behind /by-lifecycle/{state}/{name}
+func (s *Storage) resolveByLifecyclePath(path string) string {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/by-lifecycle"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+	if meta, ok := s.lifecycleObjects(strings.ToLower(parts[0]))[parts[1]]; ok {
+		return meta.ID
+	}
+	return ""
+}
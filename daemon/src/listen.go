@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Listen defaults. Port 42 needs elevated permissions, so without
+// PORT42_PORT the daemon falls back to 4242 when it can't bind 42.
+const (
+	defaultBindAddr     = "127.0.0.1"
+	defaultPort         = "42"
+	defaultFallbackPort = "4242"
+)
+
+// ListenConfig is where the daemon accepts connections (PORT42_BIND_ADDR,
+// PORT42_PORT)
+type ListenConfig struct {
+	BindAddr string
+	Port     string // Empty means try 42, then 4242
+}
+
+// Address returns the host:port to listen on for port
+func (c ListenConfig) Address(port string) string {
+	return net.JoinHostPort(c.BindAddr, port)
+}
+
+// loadListenConfig reads PORT42_BIND_ADDR and PORT42_PORT. Unlike other
+// settings, invalid values are an error rather than falling back to the
+// default, since listening somewhere else than asked is worse than not
+// starting.
+func loadListenConfig() (ListenConfig, error) {
+	config := ListenConfig{
+		BindAddr: envString("PORT42_BIND_ADDR", defaultBindAddr),
+		Port:     strings.TrimSpace(os.Getenv("PORT42_PORT")),
+	}
+
+	bindAddr, err := parseBindAddr(config.BindAddr)
+	if err != nil {
+		return config, fmt.Errorf("invalid PORT42_BIND_ADDR %q: %w", config.BindAddr, err)
+	}
+	config.BindAddr = bindAddr
+
+	if config.Port != "" {
+		if err := validatePort(config.Port); err != nil {
+			return config, fmt.Errorf("invalid PORT42_PORT %q: %w", config.Port, err)
+		}
+	}
+	return config, nil
+}
+
+// parseBindAddr accepts an IP address, optionally in [brackets] for IPv6,
+// or localhost
+func parseBindAddr(value string) (string, error) {
+	addr := strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if addr == "localhost" {
+		return addr, nil
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", fmt.Errorf("not an IP address (use e.g. 127.0.0.1, 0.0.0.0 or ::1)")
+	}
+	return ip.String(), nil
+}
+
+// validatePort accepts port numbers 1-65535
+func validatePort(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("not a number")
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("must be between 1 and 65535")
+	}
+	return nil
+}
+
+// isLoopbackBind reports whether only local clients can reach addr
+func isLoopbackBind(addr string) bool {
+	if addr == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
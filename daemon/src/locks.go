@@ -0,0 +1,28 @@
+package main
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// idLockShards is how many mutexes an idLocks spreads IDs over. IDs that
+// share a shard serialize, so it only needs to be large enough that
+// unrelated writes rarely wait on each other.
+const idLockShards = 64
+
+// idLocks serializes write sequences per object or relation ID using a
+// fixed set of mutexes. The zero value is ready to use. Shards are not
+// reentrant: never take a second ID's lock while holding one from the
+// same idLocks.
+type idLocks struct {
+	shards [idLockShards]sync.Mutex
+}
+
+// Lock locks the shard for id and returns its unlock function
+func (l *idLocks) Lock(id string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	shard := &l.shards[h.Sum32()%idLockShards]
+	shard.Lock()
+	return shard.Unlock
+}
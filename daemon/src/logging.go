@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// LogLevel orders log messages by importance
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota // Step-by-step tracing, off by default
+	LogInfo                  // Lifecycle and completed operations
+	LogWarn                  // Something failed but the daemon carried on
+	LogError                 // A request or operation failed
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogDebug: "debug",
+	LogInfo:  "info",
+	LogWarn:  "warn",
+	LogError: "error",
+}
+
+func (l LogLevel) String() string {
+	if name, ok := logLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// parseLogLevel accepts debug, info, warn (or warning) and error, in any case
+func parseLogLevel(value string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return LogDebug, nil
+	case "info":
+		return LogInfo, nil
+	case "warn", "warning":
+		return LogWarn, nil
+	case "error":
+		return LogError, nil
+	}
+	return LogInfo, fmt.Errorf("unknown log level %q", value)
+}
+
+// loadLogLevel reads PORT42_LOG_LEVEL, defaulting to info
+func loadLogLevel() LogLevel {
+	value := envString("PORT42_LOG_LEVEL", "info")
+	level, err := parseLogLevel(value)
+	if err != nil {
+		log.Printf("⚠️ Ignoring invalid value for PORT42_LOG_LEVEL: %q", value)
+	}
+	return level
+}
+
+// Logger is what daemon code logs through. Messages below its level are
+// dropped before they are formatted.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Enabled(level LogLevel) bool
+}
+
+// logger is the daemon's logger. main sets its level from PORT42_LOG_LEVEL;
+// until then, and in tests, it logs info and above.
+var logger Logger = newStdLogger(LogInfo)
+
+// stdLogger writes through the standard log package, so messages keep
+// going wherever log's output is pointed (daemon.log)
+type stdLogger struct {
+	level LogLevel
+}
+
+func newStdLogger(level LogLevel) *stdLogger {
+	return &stdLogger{level: level}
+}
+
+func (l *stdLogger) Enabled(level LogLevel) bool {
+	return level >= l.level
+}
+
+func (l *stdLogger) logf(level LogLevel, format string, args []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (l *stdLogger) Debugf(format string, args ...interface{}) { l.logf(LogDebug, format, args) }
+func (l *stdLogger) Infof(format string, args ...interface{})  { l.logf(LogInfo, format, args) }
+func (l *stdLogger) Warnf(format string, args ...interface{})  { l.logf(LogWarn, format, args) }
+func (l *stdLogger) Errorf(format string, args ...interface{}) { l.logf(LogError, format, args) }
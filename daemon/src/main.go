@@ -22,53 +22,36 @@ func main() {
 	var listener net.Listener
 	var err error
 	var port string
+	
+	// Verbose [STORAGE] and [DEBUG] lines only show at PORT42_LOG_LEVEL=debug
+	logLevel := loadLogLevel()
+	logger = newStdLogger(logLevel)
+	if logLevel != LogInfo {
+		log.Printf("📝 Log level: %s", logLevel)
+	}
 
-	// Try to listen on port 42 first
-	listener, err = net.Listen("tcp", "127.0.0.1:42")
+	listenConfig, err := loadListenConfig()
 	if err != nil {
-		// Check if it's specifically a permission error
-		if strings.Contains(err.Error(), "permission denied") {
-			// Check if running non-interactively (e.g., with nohup)
-			if !term.IsTerminal(int(os.Stdin.Fd())) {
-				// Non-interactive mode - just fall back to 4242
-				log.Println("🔐 Port 42 requires elevated permissions. Falling back to port 4242...")
-				listener, err = net.Listen("tcp", "127.0.0.1:4242")
-				if err != nil {
-					log.Fatal("Failed to open Port 4242:", err)
-				}
-				port = "4242"
-				log.Println("🐬 Swimming on port 4242...")
-			} else {
-				// Interactive mode - show prompt
-				fmt.Println("🔐 Port 42 requires elevated permissions.")
-				fmt.Println("🐬 The dolphins need permission to swim in the sacred waters of Port 42.")
-				fmt.Println("\nOptions:")
-				fmt.Println("1. Run with sudo: sudo port42d")
-				fmt.Println("2. Use port 4242 instead (no permissions needed)")
-				fmt.Print("\nPress Enter to use port 4242, or Ctrl+C to exit and run with sudo: ")
-				
-				// Wait for user input
-				fmt.Scanln()
-				
-				// Try port 4242
-				listener, err = net.Listen("tcp", "127.0.0.1:4242")
-				if err != nil {
-					log.Fatal("Failed to open Port 4242:", err)
-				}
-				port = "4242"
-				log.Println("🐬 Swimming on port 4242...")
-			}
-		} else {
-			// Some other error (like port already in use)
-			log.Fatal("Failed to open Port 42:", err)
+		log.Fatalf("❌ %v", err)
+	}
+	
+	if listenConfig.Port != "" {
+		// An explicit port is used as is, with no fallback
+		port = listenConfig.Port
+		listener, err = net.Listen("tcp", listenConfig.Address(port))
+		if err != nil {
+			log.Fatalf("Failed to open port %s on %s: %v", port, listenConfig.BindAddr, err)
 		}
+		log.Printf("🐬 Swimming on port %s...", port)
 	} else {
-		port = "42"
-		log.Println("🐬 Port 42 is open. The dolphins are listening...")
+		listener, port = listenWithFallback(listenConfig)
 	}
 	
-	// Log the actual port we're using
-	log.Printf("◊ Listening on localhost:%s", port)
+	// Log the actual address we're using
+	log.Printf("◊ Listening on %s", listenConfig.Address(port))
+	if !isLoopbackBind(listenConfig.BindAddr) && os.Getenv("PORT42_AUTH_TOKEN") == "" {
+		log.Printf("⚠️ Listening on %s without PORT42_AUTH_TOKEN; anyone who can reach this address can use the daemon", listenConfig.BindAddr)
+	}
 	
 	// Debug environment - check PORT42_ANTHROPIC_API_KEY first, then ANTHROPIC_API_KEY
 	apiKey := os.Getenv("PORT42_ANTHROPIC_API_KEY")
@@ -97,15 +80,15 @@ func main() {
 	
 	// Check if running under sudo
 	if apiKey == "" {
-		log.Printf("🔍 Running as user: %s (UID: %d)", os.Getenv("USER"), os.Getuid())
-		log.Printf("🔍 SUDO_USER: %s", os.Getenv("SUDO_USER"))
-		log.Printf("🔍 HOME: %s", os.Getenv("HOME"))
+		logger.Debugf("🔍 Running as user: %s (UID: %d)", os.Getenv("USER"), os.Getuid())
+		logger.Debugf("🔍 SUDO_USER: %s", os.Getenv("SUDO_USER"))
+		logger.Debugf("🔍 HOME: %s", os.Getenv("HOME"))
 		
 		// List all env vars starting with ANTHRO
-		log.Println("🔍 Environment variables containing 'ANTHRO':")
+		logger.Debugf("🔍 Environment variables containing 'ANTHRO':")
 		for _, env := range os.Environ() {
 			if strings.Contains(env, "ANTHRO") {
-				log.Printf("   %s", env)
+				logger.Infof("   %s", env)
 			}
 		}
 	}
@@ -133,3 +116,51 @@ func main() {
 	daemon.Shutdown()
 }
 
+// listenWithFallback tries port 42 and, when that needs permissions the
+// daemon doesn't have, port 4242
+func listenWithFallback(config ListenConfig) (net.Listener, string) {
+	var port string
+	listener, err := net.Listen("tcp", config.Address(defaultPort))
+	if err != nil {
+		// Check if it's specifically a permission error
+		if strings.Contains(err.Error(), "permission denied") {
+			// Check if running non-interactively (e.g., with nohup)
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				// Non-interactive mode - just fall back to 4242
+				log.Println("🔐 Port 42 requires elevated permissions. Falling back to port 4242...")
+				listener, err = net.Listen("tcp", config.Address(defaultFallbackPort))
+				if err != nil {
+					log.Fatal("Failed to open Port 4242:", err)
+				}
+				port = defaultFallbackPort
+				log.Println("🐬 Swimming on port 4242...")
+			} else {
+				// Interactive mode - show prompt
+				fmt.Println("🔐 Port 42 requires elevated permissions.")
+				fmt.Println("🐬 The dolphins need permission to swim in the sacred waters of Port 42.")
+				fmt.Println("\nOptions:")
+				fmt.Println("1. Run with sudo: sudo port42d")
+				fmt.Println("2. Use port 4242 instead (no permissions needed)")
+				fmt.Print("\nPress Enter to use port 4242, or Ctrl+C to exit and run with sudo: ")
+				
+				// Wait for user input
+				fmt.Scanln()
+				
+				// Try port 4242
+				listener, err = net.Listen("tcp", config.Address(defaultFallbackPort))
+				if err != nil {
+					log.Fatal("Failed to open Port 4242:", err)
+				}
+				port = defaultFallbackPort
+				log.Println("🐬 Swimming on port 4242...")
+			}
+		} else {
+			// Some other error (like port already in use)
+			log.Fatal("Failed to open Port 42:", err)
+		}
+	} else {
+		port = defaultPort
+		log.Println("🐬 Port 42 is open. The dolphins are listening...")
+	}
+	return listener, port
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// applyMetadataUpdates applies update_path's metadata_updates to meta and
+// returns the fields that actually changed. tags replaces the whole list;
+// add_tags and remove_tags are applied after it, matching existing tags
+// case-insensitively, so clients can edit one tag without resending the
+// rest. Fields given their current value are left alone.
+func applyMetadataUpdates(meta *Metadata, updates map[string]interface{}) ([]string, error) {
+	changed := []string{}
+	if updates == nil {
+		return changed, nil
+	}
+
+	addTags, err := tagUpdateList(updates, "add_tags")
+	if err != nil {
+		return nil, err
+	}
+	removeTags, err := tagUpdateList(updates, "remove_tags")
+	if err != nil {
+		return nil, err
+	}
+
+	if lifecycle, ok := updates["lifecycle"].(string); ok && lifecycle != meta.Lifecycle {
+		meta.Lifecycle = lifecycle
+		changed = append(changed, "lifecycle")
+	}
+
+	tags := meta.Tags
+	if replace, ok := updates["tags"].([]interface{}); ok {
+		tags = make([]string, len(replace))
+		for i, tag := range replace {
+			tags[i] = fmt.Sprintf("%v", tag)
+		}
+	}
+	tags = removeTagsFold(addTagsFold(tags, addTags), removeTags)
+	if !sameStrings(tags, meta.Tags) {
+		meta.Tags = tags
+		changed = append(changed, "tags")
+	}
+
+	if importance, ok := updates["importance"].(string); ok && !strings.EqualFold(importance, meta.Importance) {
+		meta.Importance = importance
+		changed = append(changed, "importance")
+	}
+	if summary, ok := updates["summary"].(string); ok && summary != meta.Summary {
+		meta.Summary = summary
+		changed = append(changed, "summary")
+	}
+	return changed, nil
+}
+
+// tagUpdateList reads an optional array of strings from updates
+func tagUpdateList(updates map[string]interface{}, key string) ([]string, error) {
+	value, ok := updates[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings", key)
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be an array of strings", key)
+		}
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list, nil
+}
+
+// addTagsFold appends the tags not already present, ignoring case; a tag
+// that is already there keeps its existing spelling
+func addTagsFold(tags, add []string) []string {
+	result := append([]string(nil), tags...)
+	for _, tag := range add {
+		if !containsFold(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// removeTagsFold drops every tag matching one in remove, ignoring case
+func removeTagsFold(tags, remove []string) []string {
+	if len(remove) == 0 {
+		return tags
+	}
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !containsFold(remove, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -118,6 +118,7 @@ func (s *Storage) MigrateMemoryPaths() error {
 			log.Printf("⚠️  Failed to write updated metadata for %s: %v", meta.ID, err)
 			continue
 		}
+		s.searchIndex.add(&meta)
 		
 		updated++
 		log.Printf("✅ Updated paths for session %s", meta.Session)
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// objectLayoutFile records the shard depth objects were last written with
+const objectLayoutFile = "object-layout.json"
+
+// objectLayoutRecord is the content of ~/.port42/object-layout.json
+type objectLayoutRecord struct {
+	ShardDepth int `json:"shard_depth"`
+}
+
+// loadObjectShardDepth reads PORT42_OBJECT_SHARD_DEPTH, clamped to the
+// supported range
+func loadObjectShardDepth() int {
+	depth := envInt("PORT42_OBJECT_SHARD_DEPTH", defaultObjectShardDepth)
+	if depth < 0 || depth > maxObjectShardDepth {
+		log.Printf("⚠️ PORT42_OBJECT_SHARD_DEPTH must be between 0 and %d, using %d", maxObjectShardDepth, defaultObjectShardDepth)
+		return defaultObjectShardDepth
+	}
+	return depth
+}
+
+// migrateShardDepth moves objects, chunks and chunk manifests to the layout
+// for depth if they were written at another depth, and repoints /commands
+// symlinks at the moved files. Stores from before the depth was
+// configurable are at the default depth. The new depth is only recorded
+// once every file has moved, so an interrupted migration resumes at the
+// next start.
+func migrateShardDepth(baseDir string, depth int) error {
+	recordPath := filepath.Join(baseDir, objectLayoutFile)
+	recorded := objectLayoutRecord{ShardDepth: defaultObjectShardDepth}
+	if data, err := os.ReadFile(recordPath); err == nil {
+		if err := json.Unmarshal(data, &recorded); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", objectLayoutFile, err)
+		}
+	}
+	if recorded.ShardDepth == depth {
+		return nil
+	}
+
+	logger.Infof("🔄 [STORAGE] Re-sharding objects from depth %d to %d...", recorded.ShardDepth, depth)
+	var failed []string
+	moved := 0
+	for _, dir := range []string{"objects", "chunks", "manifests"} {
+		n, err := reshardDir(shardLayout{root: filepath.Join(baseDir, dir), depth: depth})
+		moved += n
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", dir, err))
+		}
+	}
+	if err := repointCommandSymlinks(baseDir, shardLayout{root: filepath.Join(baseDir, "objects"), depth: depth}); err != nil {
+		failed = append(failed, fmt.Sprintf("commands: %v", err))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+
+	data, err := json.MarshalIndent(objectLayoutRecord{ShardDepth: depth}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicWriteFile(recordPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to record object layout: %w", err)
+	}
+	logger.Infof("✅ [STORAGE] Re-sharded %d files to depth %d", moved, depth)
+	return nil
+}
+
+// reshardDir moves every file under layout.root to where layout puts it.
+// A file's ID is its path below the root with the separators removed, so
+// files at any depth, including a mix left by an interrupted run, are
+// found. Emptied directories are removed. Returns the number of files moved.
+func reshardDir(layout shardLayout) (int, error) {
+	var files, dirs []string
+	err := filepath.Walk(layout.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != layout.root {
+				dirs = append(dirs, path)
+			}
+		} else if !strings.HasPrefix(info.Name(), ".") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	var firstErr error
+	for _, path := range files {
+		rel, err := filepath.Rel(layout.root, path)
+		if err != nil {
+			return moved, err
+		}
+		target := layout.path(strings.ReplaceAll(rel, string(filepath.Separator), ""))
+		if target == path {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+			err = os.Rename(path, target)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		moved++
+	}
+
+	// Deepest first, so parents are empty by the time they're tried;
+	// directories still holding files fail to remove and stay
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		os.Remove(dir)
+	}
+	return moved, firstErr
+}
+
+// repointCommandSymlinks updates /commands symlinks that target object
+// files to the objects' paths under layout
+func repointCommandSymlinks(baseDir string, layout shardLayout) error {
+	commandsDir := filepath.Join(baseDir, "commands")
+	entries, err := os.ReadDir(commandsDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, entry := range entries {
+		link := filepath.Join(commandsDir, entry.Name())
+		target, err := os.Readlink(link)
+		if err != nil {
+			continue // Not a symlink
+		}
+		objectID := objectIDFromPath(target)
+		if objectID == "" || layout.path(objectID) == target {
+			continue
+		}
+		if err := os.Remove(link); err == nil {
+			err = os.Symlink(layout.path(objectID), link)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to repoint %s: %w", entry.Name(), err)
+		}
+	}
+	return firstErr
+}
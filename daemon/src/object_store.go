@@ -0,0 +1,534 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ObjectStore persists content-addressed objects. IDs are always the SHA256
+// of the full content, so callers never see how the bytes are laid out on disk.
+// Put returns the number of bytes it added to disk; compress allows it to
+// gzip the object on disk.
+type ObjectStore interface {
+	Put(id string, content []byte, compress bool) (int64, error)
+	Get(id string) ([]byte, error)
+	Exists(id string) bool
+	List() ([]string, error)
+	Delete(id string) (int64, error) // Returns the bytes removed from disk
+	// Materialize ensures a plain whole file exists at the object path so it
+	// can be executed in place. Returns the change in bytes on disk.
+	Materialize(id string) (int64, error)
+}
+
+// objectIDLength is the length of an object ID: a hex-encoded SHA256
+const objectIDLength = 2 * sha256.Size
+
+// isObjectID reports whether id is well-formed hex of the object ID length,
+// in either case
+func isObjectID(id string) bool {
+	if len(id) != objectIDLength {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// ==================== Whole-object store ====================
+
+// FileObjectStore stores each object as a single file: objects/3a/4f/2b8c9d...
+type FileObjectStore struct {
+	layout      shardLayout
+	compressMin int // Gzip objects at least this large; 0 disables
+}
+
+// NewFileObjectStore creates a whole-object store rooted at objectsDir,
+// sharded shardDepth levels deep
+func NewFileObjectStore(objectsDir string, compressMin, shardDepth int) *FileObjectStore {
+	return &FileObjectStore{layout: shardLayout{root: objectsDir, depth: shardDepth}, compressMin: compressMin}
+}
+
+// Path returns the filesystem path for an object
+func (fs *FileObjectStore) Path(id string) string {
+	return fs.layout.path(id)
+}
+
+// Put writes the object file, gzipped if allowed and large enough to benefit
+func (fs *FileObjectStore) Put(id string, content []byte, compress bool) (int64, error) {
+	data := content
+	if compress && fs.compressMin > 0 && len(content) >= fs.compressMin {
+		if packed, ok := gzipContent(content); ok {
+			data = packed
+			logger.Debugf("🗜️ [STORAGE] Compressed object %s: %d -> %d bytes", id[:12]+"...", len(content), len(packed))
+		}
+	}
+	
+	path := fs.Path(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create object directory: %w", err)
+	}
+	if err := atomicWriteFile(path, data, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write object: %w", err)
+	}
+	return int64(len(data)), nil
+}
+
+// Get reads the object file, decompressing it if needed
+func (fs *FileObjectStore) Get(id string) ([]byte, error) {
+	data, err := os.ReadFile(fs.Path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("object not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	content, _ := decodeObject(id, data)
+	return content, nil
+}
+
+// Exists reports whether the object file is present
+func (fs *FileObjectStore) Exists(id string) bool {
+	_, err := os.Stat(fs.Path(id))
+	return err == nil
+}
+
+// List returns all object IDs in the store
+func (fs *FileObjectStore) List() ([]string, error) {
+	return fs.layout.list("")
+}
+
+// Delete removes the object file
+func (fs *FileObjectStore) Delete(id string) (int64, error) {
+	return removeFile(fs.Path(id))
+}
+
+// Materialize rewrites a compressed object file as plain content
+func (fs *FileObjectStore) Materialize(id string) (int64, error) {
+	data, err := os.ReadFile(fs.Path(id))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read object: %w", err)
+	}
+	content, compressed := decodeObject(id, data)
+	if !compressed {
+		return 0, nil
+	}
+	if _, err := fs.Put(id, content, false); err != nil {
+		return 0, err
+	}
+	return int64(len(content) - len(data)), nil
+}
+
+// ==================== Chunked store ====================
+
+// Content-defined chunking parameters. Boundaries are picked with a gear
+// rolling hash so an edit only disturbs the chunks around it, letting
+// near-identical tools share most of their chunks.
+const (
+	chunkMinSize      = 2 * 1024
+	chunkMaxSize      = 64 * 1024
+	chunkMask         = uint64(0x1fff) << 51 // 13 high bits, ~8KB average chunk
+	chunkManifestVer  = 1
+	defaultChunkLimit = 16 * 1024 // Objects smaller than this are stored whole
+)
+
+// gearTable holds the per-byte values for the rolling hash
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		sum := sha256.Sum256([]byte{byte(i)})
+		table[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+	return table
+}()
+
+// ChunkManifest lists the chunks that make up a chunked object
+type ChunkManifest struct {
+	Version int      `json:"version"`
+	Size    int64    `json:"size"`
+	Chunks  []string `json:"chunks"`
+}
+
+// DedupStats summarizes how much space chunking is saving
+type DedupStats struct {
+	Enabled        bool    `json:"enabled"`
+	ChunkedObjects int     `json:"chunked_objects"`
+	LogicalBytes   int64   `json:"logical_bytes"`  // Sum of chunked object sizes
+	UniqueChunks   int     `json:"unique_chunks"`
+	StoredBytes    int64   `json:"stored_bytes"`   // Bytes actually on disk in chunks/
+	DedupRatio     float64 `json:"dedup_ratio"`    // logical / stored, 1.0 means no savings
+}
+
+// ChunkedObjectStore splits large objects into content-addressed chunks and
+// stores a manifest per object. Small objects fall through to the whole-object
+// store, and existing whole objects keep being served as-is.
+type ChunkedObjectStore struct {
+	whole     *FileObjectStore
+	chunks    shardLayout
+	manifests shardLayout
+	minSize   int
+}
+
+// NewChunkedObjectStore creates a chunked store alongside the whole-object
+// store, sharding chunks and manifests the same way
+func NewChunkedObjectStore(baseDir string, whole *FileObjectStore, minSize int) (*ChunkedObjectStore, error) {
+	cs := &ChunkedObjectStore{
+		whole:     whole,
+		chunks:    shardLayout{root: filepath.Join(baseDir, "chunks"), depth: whole.layout.depth},
+		manifests: shardLayout{root: filepath.Join(baseDir, "manifests"), depth: whole.layout.depth},
+		minSize:   minSize,
+	}
+	for _, dir := range []string{cs.chunks.root, cs.manifests.root} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	return cs, nil
+}
+
+// Put stores small objects whole and large ones as a chunk manifest.
+// Chunks are stored uncompressed.
+func (cs *ChunkedObjectStore) Put(id string, content []byte, compress bool) (int64, error) {
+	if len(content) < cs.minSize {
+		return cs.whole.Put(id, content, compress)
+	}
+
+	var written int64
+
+	manifest := ChunkManifest{Version: chunkManifestVer, Size: int64(len(content))}
+	newChunks := 0
+	for _, chunk := range splitChunks(content) {
+		sum := sha256.Sum256(chunk)
+		chunkID := hex.EncodeToString(sum[:])
+		path := cs.chunks.path(chunkID)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return written, fmt.Errorf("failed to create chunk directory: %w", err)
+			}
+			if err := atomicWriteFile(path, chunk, 0644); err != nil {
+				return written, fmt.Errorf("failed to write chunk: %w", err)
+			}
+			written += int64(len(chunk))
+			newChunks++
+		}
+		manifest.Chunks = append(manifest.Chunks, chunkID)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return written, fmt.Errorf("failed to marshal chunk manifest: %w", err)
+	}
+	path := cs.manifests.path(id) + ".json"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return written, fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	if err := atomicWriteFile(path, data, 0644); err != nil {
+		return written, fmt.Errorf("failed to write chunk manifest: %w", err)
+	}
+	written += int64(len(data))
+
+	logger.Debugf("🧩 [STORAGE] Chunked object %s: %d chunks (%d new)", id[:12]+"...", len(manifest.Chunks), newChunks)
+	return written, nil
+}
+
+// Get returns the whole object, reassembling it from chunks if needed
+func (cs *ChunkedObjectStore) Get(id string) ([]byte, error) {
+	if cs.whole.Exists(id) {
+		return cs.whole.Get(id)
+	}
+
+	manifest, err := cs.loadManifest(id)
+	if err != nil {
+		return nil, err
+	}
+
+	content := make([]byte, 0, manifest.Size)
+	for _, chunkID := range manifest.Chunks {
+		chunk, err := os.ReadFile(cs.chunks.path(chunkID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %s of object %s: %w", chunkID[:12], id, err)
+		}
+		content = append(content, chunk...)
+	}
+
+	if int64(len(content)) != manifest.Size {
+		return nil, fmt.Errorf("object %s reassembled to %d bytes, expected %d", id, len(content), manifest.Size)
+	}
+	return content, nil
+}
+
+// Exists reports whether the object is stored whole or as a manifest
+func (cs *ChunkedObjectStore) Exists(id string) bool {
+	if cs.whole.Exists(id) {
+		return true
+	}
+	_, err := os.Stat(cs.manifests.path(id) + ".json")
+	return err == nil
+}
+
+// List returns whole and chunked object IDs
+func (cs *ChunkedObjectStore) List() ([]string, error) {
+	ids, err := cs.whole.List()
+	if err != nil {
+		return nil, err
+	}
+	chunked, err := cs.manifests.list(".json")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range chunked {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Delete removes the whole copy and manifest of an object. Chunks are
+// shared between objects and are only removed by SweepChunks.
+func (cs *ChunkedObjectStore) Delete(id string) (int64, error) {
+	freed, err := cs.whole.Delete(id)
+	if err != nil {
+		return freed, err
+	}
+	manifestFreed, err := removeFile(cs.manifests.path(id) + ".json")
+	return freed + manifestFreed, err
+}
+
+// SweepChunks removes chunks that no manifest refers to, returning the
+// number of chunks and bytes removed
+func (cs *ChunkedObjectStore) SweepChunks() (int, int64) {
+	ids, err := cs.manifests.list(".json")
+	if err != nil {
+		logger.Warnf("⚠️ [STORAGE] Failed to list chunk manifests: %v", err)
+		return 0, 0
+	}
+
+	live := make(map[string]bool)
+	for _, id := range ids {
+		manifest, err := cs.loadManifest(id)
+		if err != nil {
+			// An unreadable manifest could still need its chunks
+			logger.Warnf("⚠️ [STORAGE] Skipping chunk sweep, manifest %s unreadable: %v", id[:12], err)
+			return 0, 0
+		}
+		for _, chunkID := range manifest.Chunks {
+			live[chunkID] = true
+		}
+	}
+
+	chunkIDs, err := cs.chunks.list("")
+	if err != nil {
+		logger.Warnf("⚠️ [STORAGE] Failed to list chunks: %v", err)
+		return 0, 0
+	}
+
+	count := 0
+	var freed int64
+	for _, chunkID := range chunkIDs {
+		if live[chunkID] {
+			continue
+		}
+		size, err := removeFile(cs.chunks.path(chunkID))
+		if err != nil {
+			logger.Warnf("⚠️ [STORAGE] Failed to remove chunk %s: %v", chunkID[:12], err)
+			continue
+		}
+		count++
+		freed += size
+	}
+	return count, freed
+}
+
+// Materialize writes a whole copy of a chunked object so it can be
+// executed in place (command symlinks point at the object file).
+// Returns the bytes added to disk.
+func (cs *ChunkedObjectStore) Materialize(id string) (int64, error) {
+	if cs.whole.Exists(id) {
+		return cs.whole.Materialize(id)
+	}
+	content, err := cs.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	return cs.whole.Put(id, content, false)
+}
+
+// Stats walks the manifests and chunks to compute the dedup ratio
+func (cs *ChunkedObjectStore) Stats() DedupStats {
+	stats := DedupStats{Enabled: true}
+
+	ids, err := cs.manifests.list(".json")
+	if err != nil {
+		logger.Warnf("⚠️ [STORAGE] Failed to list chunk manifests: %v", err)
+		return stats
+	}
+	for _, id := range ids {
+		if manifest, err := cs.loadManifest(id); err == nil {
+			stats.ChunkedObjects++
+			stats.LogicalBytes += manifest.Size
+		}
+	}
+
+	filepath.Walk(cs.chunks.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		stats.UniqueChunks++
+		stats.StoredBytes += info.Size()
+		return nil
+	})
+
+	if stats.StoredBytes > 0 {
+		stats.DedupRatio = float64(stats.LogicalBytes) / float64(stats.StoredBytes)
+	} else {
+		stats.DedupRatio = 1.0
+	}
+	return stats
+}
+
+func (cs *ChunkedObjectStore) loadManifest(id string) (*ChunkManifest, error) {
+	data, err := os.ReadFile(cs.manifests.path(id) + ".json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("object not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to read chunk manifest: %w", err)
+	}
+	var manifest ChunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse chunk manifest for %s: %w", id, err)
+	}
+	return &manifest, nil
+}
+
+// splitChunks cuts content at gear-hash boundaries within [chunkMinSize, chunkMaxSize]
+func splitChunks(content []byte) [][]byte {
+	var chunks [][]byte
+	start := 0
+	var hash uint64
+
+	for i := 0; i < len(content); i++ {
+		hash = (hash << 1) + gearTable[content[i]]
+		size := i - start + 1
+		if size < chunkMinSize {
+			continue
+		}
+		if hash&chunkMask == 0 || size >= chunkMaxSize {
+			chunks = append(chunks, content[start:i+1])
+			start = i + 1
+			hash = 0
+		}
+	}
+	if start < len(content) {
+		chunks = append(chunks, content[start:])
+	}
+	return chunks
+}
+
+// ==================== Path helpers ====================
+
+// removeFile deletes a file if present and returns its size
+func removeFile(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// dirSize sums the sizes of all files under root
+func dirSize(root string) int64 {
+	var total int64
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+// Object IDs are sharded into directories of two hex characters each, git
+// style: at the default depth of 2, 3a4f2b8c... lives at 3a/4f/2b8c...
+// PORT42_OBJECT_SHARD_DEPTH picks another depth, down to 0 for a flat
+// directory in small stores.
+const (
+	defaultObjectShardDepth = 2
+	maxObjectShardDepth     = 4
+)
+
+// shardLayout places content-addressed files under root, depth levels deep
+type shardLayout struct {
+	root  string
+	depth int
+}
+
+// objectPath returns the directory and file name an ID is stored under.
+// The file name keeps at least two characters of the ID.
+func (l shardLayout) objectPath(id string) (dir, file string) {
+	levels := max(0, min(l.depth, (len(id)-2)/2))
+	parts := make([]string, 0, levels+1)
+	parts = append(parts, l.root)
+	for i := 0; i < levels; i++ {
+		parts = append(parts, id[i*2:i*2+2])
+	}
+	return filepath.Join(parts...), id[levels*2:]
+}
+
+// path returns the full path an ID is stored at
+func (l shardLayout) path(id string) string {
+	dir, file := l.objectPath(id)
+	return filepath.Join(dir, file)
+}
+
+// list reconstructs the IDs stored under the layout, trimming suffix from
+// each file name. Files at any other depth, and hidden files, are skipped.
+func (l shardLayout) list(suffix string) ([]string, error) {
+	var ids []string
+
+	err := filepath.Walk(l.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip directories and hidden files
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+
+		// Convert path back to ID: 3a/4f/2b8c9d... -> 3a4f2b8c9d...
+		parts := strings.Split(rel, string(filepath.Separator))
+		if len(parts) == l.depth+1 {
+			ids = append(ids, strings.TrimSuffix(strings.Join(parts, ""), suffix))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	return ids, nil
+}
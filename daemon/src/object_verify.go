@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrObjectCorrupt is wrapped into errors for objects whose content no
+// longer hashes to their ID. Its text is the error code clients see.
+var ErrObjectCorrupt = errors.New("CORRUPT")
+
+// Verify problem kinds
+const (
+	VerifyCorrupt    = "corrupt"    // Content doesn't hash to the ID
+	VerifyUnreadable = "unreadable" // Content couldn't be read back
+	VerifyMissing    = "missing"    // No object with that ID
+)
+
+// VerifyFailure is one object that failed verification
+type VerifyFailure struct {
+	ID      string `json:"id"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail"`
+}
+
+// VerifyReport summarizes a verify pass
+type VerifyReport struct {
+	Checked  int             `json:"checked"`
+	Failures []VerifyFailure `json:"failures"`
+	Duration string          `json:"duration"`
+}
+
+// loadVerifyReads reads PORT42_VERIFY_READS. Hashing every read costs a
+// pass over the content, so it is off by default.
+func loadVerifyReads() bool {
+	return envBool("PORT42_VERIFY_READS", false)
+}
+
+// verifyContent checks that content hashes to id
+func verifyContent(id string, content []byte) error {
+	hash := sha256.Sum256(content)
+	actual := hex.EncodeToString(hash[:])
+	if actual != strings.ToLower(id) {
+		return fmt.Errorf("%w: object %s content hashes to %s", ErrObjectCorrupt, shortID(id), shortID(actual))
+	}
+	return nil
+}
+
+// VerifyObject reads an object back and checks its content still hashes to
+// its ID, returning an error wrapping ErrObjectCorrupt when it doesn't
+func (s *Storage) VerifyObject(id string) error {
+	if !isObjectID(id) {
+		return fmt.Errorf("invalid object ID: %s", id)
+	}
+	content, err := s.objects.Get(id)
+	if err != nil {
+		return err
+	}
+	return verifyContent(id, content)
+}
+
+// VerifyObjects verifies the given objects, or every stored object when
+// ids is empty
+func (s *Storage) VerifyObjects(ids []string) (VerifyReport, error) {
+	started := time.Now()
+	report := VerifyReport{Failures: []VerifyFailure{}}
+
+	if len(ids) == 0 {
+		all, err := s.List()
+		if err != nil {
+			return report, fmt.Errorf("failed to list objects: %w", err)
+		}
+		ids = all
+		sort.Strings(ids)
+	}
+
+	for _, id := range ids {
+		report.Checked++
+		if !s.objects.Exists(id) {
+			report.Failures = append(report.Failures, VerifyFailure{ID: id, Problem: VerifyMissing, Detail: "object not found"})
+			continue
+		}
+		if err := s.VerifyObject(id); err != nil {
+			problem := VerifyUnreadable
+			if errors.Is(err, ErrObjectCorrupt) {
+				problem = VerifyCorrupt
+			}
+			report.Failures = append(report.Failures, VerifyFailure{ID: id, Problem: problem, Detail: err.Error()})
+			log.Printf("⚠️ [VERIFY] %s %s: %v", problem, shortID(id), err)
+		}
+	}
+
+	report.Duration = time.Since(started).Round(time.Millisecond).String()
+	log.Printf("🔎 [VERIFY] Checked %d objects, %d failed (%s)", report.Checked, len(report.Failures), report.Duration)
+	return report, nil
+}
+
+// handleVerify rehashes stored objects and reports those that no longer
+// match their IDs
+func (d *Daemon) handleVerify(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		IDs []string `json:"ids,omitempty"` // Defaults to every object
+	}
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+		}
+	}
+
+	ids := make([]string, 0, len(payload.IDs))
+	for _, id := range payload.IDs {
+		if !isObjectID(id) {
+			return NewErrorResponse(req.ID, fmt.Sprintf("Invalid object ID: %q", id))
+		}
+		ids = append(ids, strings.ToLower(id))
+	}
+
+	report, err := d.storage.VerifyObjects(ids)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(report)
+	return resp
+}
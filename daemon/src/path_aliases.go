@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Alias categories, one for each kind of path generateVirtualPaths adds
+const (
+	AliasCanonical = "canonical" // /commands/, /tools/, /artifacts/, /memory/<session>, ... where the object was stored
+	AliasTemporal  = "temporal"  // /by-date/<day>/<name>
+	AliasType      = "type"      // /by-type/<type>/<name>
+	AliasAgent     = "agent"     // /by-agent/<agent>/<kind>/<name>
+	AliasMemory    = "memory"    // /memory/<session>/generated/<name>, the session that made it
+)
+
+// aliasCategories is the order categories are reported in
+var aliasCategories = []string{AliasCanonical, AliasTemporal, AliasType, AliasAgent, AliasMemory}
+
+// PathAlias is one virtual path of an object
+type PathAlias struct {
+	Path     string `json:"path"`
+	Category string `json:"category"`
+}
+
+// PathAliases lists an object's virtual paths, grouped by category
+type PathAliases struct {
+	ObjectID string              `json:"object_id"`
+	Primary  string              `json:"primary,omitempty"` // First canonical path, else the first path
+	Aliases  []PathAlias         `json:"aliases"`
+	Groups   map[string][]string `json:"groups"`
+}
+
+// aliasCategory classifies a virtual path by the prefix generateVirtualPaths
+// gives it. Paths with no generated prefix are where the object was stored.
+func aliasCategory(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/by-date/"):
+		return AliasTemporal
+	case strings.HasPrefix(path, "/by-type/"):
+		return AliasType
+	case strings.HasPrefix(path, "/by-agent/"):
+		return AliasAgent
+	case strings.HasPrefix(path, "/memory/"):
+		// /memory/<session>/generated/<name> or /memory/sessions/<session>/generated/<name>
+		parts := strings.Split(strings.TrimPrefix(path, "/memory/"), "/")
+		for i := 1; i < len(parts)-1; i++ {
+			if parts[i] == "generated" {
+				return AliasMemory
+			}
+		}
+	}
+	return AliasCanonical
+}
+
+// pathAliases categorizes paths in the order they are stored, which is the
+// order generateVirtualPaths produced them in
+func pathAliases(objectID string, paths []string) PathAliases {
+	aliases := PathAliases{
+		ObjectID: objectID,
+		Aliases:  []PathAlias{},
+		Groups:   make(map[string][]string, len(aliasCategories)),
+	}
+	for _, category := range aliasCategories {
+		aliases.Groups[category] = []string{}
+	}
+	for _, path := range paths {
+		category := aliasCategory(path)
+		aliases.Aliases = append(aliases.Aliases, PathAlias{Path: path, Category: category})
+		aliases.Groups[category] = append(aliases.Groups[category], path)
+	}
+
+	if canonical := aliases.Groups[AliasCanonical]; len(canonical) > 0 {
+		aliases.Primary = canonical[0]
+	} else if len(paths) > 0 {
+		aliases.Primary = paths[0]
+	}
+	return aliases
+}
+
+// handleListAliases lists every virtual path of an object, given one of
+// its paths or its object ID, with the category of each
+func (d *Daemon) handleListAliases(req Request) Response {
+	var payload struct {
+		Path     string `json:"path,omitempty"`
+		ObjectID string `json:"object_id,omitempty"`
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+
+	objID := strings.ToLower(strings.TrimSpace(payload.ObjectID))
+	switch {
+	case objID != "":
+		if !isObjectID(objID) {
+			return NewErrorResponse(req.ID, fmt.Sprintf("Invalid object ID: %q", payload.ObjectID))
+		}
+	case payload.Path != "":
+		objID = d.resolvePath(payload.Path)
+		if objID == "" {
+			return NewErrorResponse(req.ID, fmt.Sprintf("Path not found: %s", payload.Path))
+		}
+	default:
+		return NewErrorResponse(req.ID, "path or object_id is required")
+	}
+	if strings.HasPrefix(objID, "relation:") {
+		return NewErrorResponse(req.ID, fmt.Sprintf("%s is a relation view, which has no stored paths; use get_relation with %s", payload.Path, strings.TrimPrefix(objID, "relation:")))
+	}
+
+	meta, err := d.storage.LoadMetadata(objID)
+	if err != nil {
+		return NewErrorResponse(req.ID, fmt.Sprintf("No metadata for object %s", shortID(objID)))
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(pathAliases(objID, meta.Paths))
+	return resp
+}
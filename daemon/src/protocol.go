@@ -3,16 +3,27 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // Request represents an incoming request from the CLI
 type Request struct {
-	Type           string          `json:"type"`
-	ID             string          `json:"id"`
-	Payload        json.RawMessage `json:"payload"`
-	SessionContext *SessionContext `json:"session_context,omitempty"` // Optional session info
-	References     []Reference     `json:"references,omitempty"`      // Universal references
-	UserPrompt     string          `json:"user_prompt,omitempty"`     // Universal user prompt
+	Type            string          `json:"type"`
+	ID              string          `json:"id"`
+	Payload         json.RawMessage `json:"payload"`
+	SessionContext  *SessionContext `json:"session_context,omitempty"`  // Optional session info
+	References      []Reference     `json:"references,omitempty"`       // Universal references
+	UserPrompt      string          `json:"user_prompt,omitempty"`      // Universal user prompt
+	Auth            string          `json:"auth,omitempty"`             // Shared secret, required when PORT42_AUTH_TOKEN is set
+	ProtocolVersion int             `json:"protocol_version,omitempty"` // Highest protocol version the client speaks; unset is ProtocolV1
+
+	// emit sends a chunk frame on the request's connection; set by
+	// handleConnection only for streaming requests
+	emit func(content string)
+	
+	// gone is closed when a streaming client disconnects or sends stop;
+	// nil for other requests
+	gone <-chan struct{}
 }
 
 // SessionContext provides memory session information for relation tracking
@@ -23,17 +34,49 @@ type SessionContext struct {
 
 // Reference represents a contextual reference to enhance tool generation
 type Reference struct {
-	Type    string `json:"type"`              // "search", "tool", "file", "p42", "url"
+	Type    string `json:"type"`              // "search", "tool", "file", "p42", "url", "git"
 	Target  string `json:"target"`            // The thing being referenced
 	Context string `json:"context,omitempty"` // Optional additional context
+	NoCache bool   `json:"no_cache,omitempty"` // url:/git: only - skip the cached copy and fetch fresh
 }
 
 // Response represents the daemon's response
 type Response struct {
-	ID      string          `json:"id"`
-	Success bool            `json:"success"`
-	Data    json.RawMessage `json:"data,omitempty"`
-	Error   string          `json:"error,omitempty"`
+	ID              string          `json:"id"`
+	Success         bool            `json:"success"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	Frame           string          `json:"frame,omitempty"`  // "ping"/"pong" for keepalive, "chunk"/"event"/"complete" for streams
+	ProtocolVersion int             `json:"protocol_version"` // Highest protocol version the daemon speaks
+}
+
+// Protocol versions. A client says which it speaks with protocol_version
+// on each request and the daemon answers in the highest both understand;
+// every response carries the daemon's own version so a client can tell
+// what to ask for. Requests without a version get v1 behavior.
+const (
+	ProtocolV1 = 1 // One response per request
+	ProtocolV2 = 2 // Adds streamed frames: possess chunks, watch events and keepalive pings
+
+	CurrentProtocolVersion = ProtocolV2
+)
+
+// Version is the protocol version to answer the request in: the client's
+// version, or ProtocolV1 when it sent none, capped at the daemon's
+func (r Request) Version() int {
+	switch {
+	case r.ProtocolVersion < ProtocolV1:
+		return ProtocolV1
+	case r.ProtocolVersion > CurrentProtocolVersion:
+		return CurrentProtocolVersion
+	}
+	return r.ProtocolVersion
+}
+
+// Supports reports whether the request may be answered with behavior
+// introduced in protocol version v
+func (r Request) Supports(v int) bool {
+	return r.Version() >= v
 }
 
 // Request types
@@ -53,6 +96,9 @@ type SwimPayload struct {
 	SessionID        string            `json:"session_id,omitempty"`
 	MemoryContext    []string          `json:"memory_context,omitempty"`
 	ApprovalResponse *ApprovalResponse `json:"approval_response,omitempty"`
+	Stream           bool              `json:"stream,omitempty"` // Send chunk frames as the reply is generated
+	Resume           bool              `json:"resume,omitempty"` // Without session_id, continue the agent's last session
+	Reopen           bool              `json:"reopen,omitempty"` // Continue the session even if it was completed or abandoned
 }
 
 // ApprovalRequest sent from daemon to CLI when bash command needs approval
@@ -70,39 +116,110 @@ type ApprovalResponse struct {
 
 // StatusData for status responses
 type StatusData struct {
-	Status    string `json:"status"`
-	Port      string `json:"port"`
-	Sessions  int    `json:"sessions"`
-	Uptime    string `json:"uptime"`
-	Dolphins  string `json:"dolphins"`
-	RuleCount int    `json:"rule_count,omitempty"`
-	Rules     string `json:"rules,omitempty"`
+	Status             string `json:"status"`
+	ProtocolVersion    int    `json:"protocol_version"`     // Highest protocol version the daemon speaks
+	MinProtocolVersion int    `json:"min_protocol_version"` // Oldest still answered; unversioned requests are this
+	Port               string `json:"port"`
+	Sessions           int    `json:"sessions"`
+	Uptime             string `json:"uptime"`
+	Dolphins           string `json:"dolphins"`
+	RuleCount          int    `json:"rule_count,omitempty"`
+	Rules              string `json:"rules,omitempty"`
+	
+	// In-memory session cap and how many sessions it has evicted
+	MaxSessions      int   `json:"max_sessions,omitempty"`
+	SessionEvictions int64 `json:"session_evictions"`
+	
+	// Disk usage from storage stats
+	StorageSize   int64 `json:"storage_size,omitempty"`
+	ObjectBytes   int64 `json:"object_bytes,omitempty"`
+	MetadataBytes int64 `json:"metadata_bytes,omitempty"`
+	
+	// Sessions whose latest save failed; persistence_ok is false while any are
+	PersistenceOK   bool             `json:"persistence_ok"`
+	UnsavedSessions []UnsavedSession `json:"unsaved_sessions,omitempty"`
 }
 
 // WatchPayload for watch requests
 type WatchPayload struct {
-	Target string `json:"target"` // "rules", "sessions", etc.
+	Target string `json:"target"`           // "rules", "relations", "tools", "memory"
+	Stream bool   `json:"stream,omitempty"` // Keep the connection open and send events as they happen
 }
 
-// WatchData for watch responses - streams rule activity
+// WatchData for watch responses - streams rule, relation, tool and memory activity
 type WatchData struct {
 	Timestamp string `json:"timestamp"`
-	Type      string `json:"type"`    // "rule_triggered", "rule_completed", "rule_failed"
+	Target    string `json:"target,omitempty"` // Watch target the event was published on
+	Type      string `json:"type"`    // "rule_triggered", "rule_completed", "rule_failed", "tool_declared", "memory_created", ...
 	RuleID    string `json:"rule_id"`
 	RuleName  string `json:"rule_name"`
 	Details   string `json:"details,omitempty"`
+	
+	// Relation or session the event is about
+	RelationID   string `json:"relation_id,omitempty"`
+	RelationType string `json:"relation_type,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Path         string `json:"path,omitempty"`
+	SessionID    string `json:"session_id,omitempty"`
+	Agent        string `json:"agent,omitempty"`
 }
 
-// ListData for list responses
+// ListData for list responses. Commands stays a plain name list for older
+// clients; Details carries the same commands in the same order with what
+// their Tool relations know about them.
 type ListData struct {
-	Commands []string `json:"commands"`
+	Commands []string      `json:"commands"`
+	Details  []CommandInfo `json:"details"`
+}
+
+// CommandInfo describes one installed command. Legacy commands without a
+// Tool relation only have a name.
+type CommandInfo struct {
+	Name        string     `json:"name"`
+	RelationID  string     `json:"relation_id,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Language    string     `json:"language,omitempty"`
+	Agent       string     `json:"agent,omitempty"`
+	Transforms  []string   `json:"transforms,omitempty"`
+	Created     *time.Time `json:"created,omitempty"`
+}
+
+// AgentInfo describes a configured agent for list_agents responses
+type AgentInfo struct {
+	Key                 string            `json:"key"`  // Config key, e.g. "engineer"
+	Name                string            `json:"name"` // Display name, e.g. "@ai-engineer"
+	Description         string            `json:"description"`
+	Personality         string            `json:"personality,omitempty"`
+	Style               string            `json:"style,omitempty"`
+	ModelKey            string            `json:"model_key,omitempty"`
+	Model               *ModelDefinition  `json:"model,omitempty"` // Resolved model with temperature override applied
+	TemperatureOverride *float64          `json:"temperature_override,omitempty"`
+	HasCustomPrompt     bool              `json:"has_custom_prompt"`
+	LastSession         *AgentLastSession `json:"last_session,omitempty"`
+}
+
+// AgentLastSession summarizes an agent's most recent session for resume options
+type AgentLastSession struct {
+	SessionID    string `json:"session_id"`
+	State        string `json:"state,omitempty"`
+	LastActivity string `json:"last_activity,omitempty"`
+	MessageCount int    `json:"message_count"`
+}
+
+// ListAgentsData for list_agents responses
+type ListAgentsData struct {
+	Agents       []AgentInfo `json:"agents"`
+	DefaultModel string      `json:"default_model,omitempty"`
+	GuidanceFile string      `json:"guidance_file,omitempty"`
+	HasGuidance  bool        `json:"has_guidance"`
 }
 
 // Helper functions
 func NewResponse(id string, success bool) Response {
 	return Response{
-		ID:      id,
-		Success: success,
+		ID:              id,
+		Success:         success,
+		ProtocolVersion: CurrentProtocolVersion,
 	}
 }
 
@@ -135,6 +252,7 @@ func ValidateReference(ref Reference) error {
 		"file":   true,
 		"p42":    true,
 		"url":    true,
+		"git":    true,
 	}
 	
 	if !validTypes[ref.Type] {
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+)
+
+// How a tool came to exist, recorded in its relation's source property.
+// Tools declared before source was recorded are backfilled as unknown.
+const (
+	SourceDeclare = "declare" // declare_relation, including tools an agent declared from a swim
+	SourceSwim    = "swim"    // A command generated directly by possess
+	SourceUnknown = "unknown"
+)
+
+// provenancePromptLimit is how much of the originating prompt info shows
+const provenancePromptLimit = 200
+
+// relationProvenance gathers where a relation came from: its source, the
+// session and agent that produced it and the prompt that asked for it.
+// Declares record the session as memory_session and its agent as
+// crystallized_agent, while generated commands use session_id and agent.
+func relationProvenance(relation Relation) map[string]interface{} {
+	source, _ := relation.Properties["source"].(string)
+	if source == "" {
+		source = SourceUnknown
+	}
+	session, _ := relation.Properties["memory_session"].(string)
+	if session == "" {
+		session, _ = relation.Properties["session_id"].(string)
+	}
+	agent, _ := relation.Properties["crystallized_agent"].(string)
+	if agent == "" {
+		agent, _ = relation.Properties["agent"].(string)
+	}
+	prompt, _ := relation.Properties["user_prompt"].(string)
+
+	return map[string]interface{}{
+		"source":      source,
+		"session":     session,
+		"agent":       agent,
+		"user_prompt": truncatePrompt(prompt, provenancePromptLimit),
+	}
+}
+
+// truncatePrompt shortens a prompt to at most limit runes, marking the cut
+func truncatePrompt(prompt string, limit int) string {
+	prompt = strings.TrimSpace(prompt)
+	runes := []rune(prompt)
+	if len(runes) <= limit {
+		return prompt
+	}
+	return string(runes[:limit]) + "…"
+}
+
+// backfillToolSources marks Tool relations from before source was recorded
+// as unknown, so every tool reports a source
+func (s *Storage) backfillToolSources() {
+	if s.relationStore == nil {
+		return
+	}
+	tools, err := s.relationStore.LoadByType("Tool")
+	if err != nil {
+		return
+	}
+
+	backfilled := 0
+	for _, tool := range tools {
+		if source, _ := tool.Properties["source"].(string); source != "" {
+			continue
+		}
+		err := s.relationStore.Update(tool.ID, func(relation *Relation) error {
+			if relation.Properties == nil {
+				relation.Properties = make(map[string]interface{})
+			}
+			if source, _ := relation.Properties["source"].(string); source == "" {
+				relation.Properties["source"] = SourceUnknown
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Warnf("⚠️ [STORAGE] Failed to backfill source of %s: %v", tool.ID, err)
+			continue
+		}
+		backfilled++
+	}
+	if backfilled > 0 {
+		logger.Infof("🧬 [STORAGE] Marked %d tools without a recorded source as %s", backfilled, SourceUnknown)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPruneStates are what prune removes when no states are given:
+// objects whose last path was deleted and sessions that were abandoned
+var defaultPruneStates = []string{"deprecated", string(SessionAbandoned)}
+
+// defaultPruneAge is how long an object or session must have been left
+// alone before prune removes it, when the request doesn't say
+const defaultPruneAge = 30 * 24 * time.Hour
+
+// PrunedObject is an object prune removed (or would remove)
+type PrunedObject struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Lifecycle string    `json:"lifecycle"`
+	Modified  time.Time `json:"modified"`
+}
+
+// PrunedSession is a session prune removed (or would remove)
+type PrunedSession struct {
+	SessionID   string    `json:"session_id"`
+	Agent       string    `json:"agent,omitempty"`
+	State       string    `json:"state"`
+	LastUpdated time.Time `json:"last_updated"`
+	Versions    int       `json:"versions"` // Stored session objects removed with it
+}
+
+// PruneReport summarizes a prune pass
+type PruneReport struct {
+	DryRun         bool            `json:"dry_run"`
+	OlderThan      string          `json:"older_than"`
+	States         []string        `json:"states"`
+	Objects        []PrunedObject  `json:"objects"`
+	Sessions       []PrunedSession `json:"sessions"`
+	KeptReferenced []string        `json:"kept_referenced"` // Matched, but a relation still refers to them
+	DeletedObjects int             `json:"deleted_objects"`
+	FreedBytes     int64           `json:"freed_bytes"`
+}
+
+// Prune removes objects whose lifecycle and sessions whose state is one of
+// states, once they haven't been modified for olderThan. Their blobs,
+// metadata and search index entries go, and pruned sessions leave the
+// session index. Anything a surviving relation still refers to - an object
+// ID, tool version or memory_session - is kept, as is anything a /commands
+// symlink targets.
+func (s *Storage) Prune(olderThan time.Duration, states []string, dryRun bool) (PruneReport, error) {
+	if len(states) == 0 {
+		states = defaultPruneStates
+	}
+	wanted := make(map[string]bool, len(states))
+	for _, state := range states {
+		if state = strings.ToLower(strings.TrimSpace(state)); state != "" {
+			wanted[state] = true
+		}
+	}
+
+	report := PruneReport{
+		DryRun:         dryRun,
+		OlderThan:      olderThan.String(),
+		States:         make([]string, 0, len(wanted)),
+		Objects:        []PrunedObject{},
+		Sessions:       []PrunedSession{},
+		KeptReferenced: []string{},
+	}
+	for state := range wanted {
+		report.States = append(report.States, state)
+	}
+	sort.Strings(report.States)
+	cutoff := time.Now().Add(-olderThan)
+
+	// What surviving relations and command symlinks still point at
+	referenced := make(map[string]bool)
+	if s.relationStore != nil {
+		relations, err := s.relationStore.List()
+		if err != nil {
+			return report, fmt.Errorf("failed to load relations: %w", err)
+		}
+		for _, relation := range relations {
+			for _, value := range relation.Properties {
+				if id, ok := value.(string); ok && id != "" {
+					referenced[id] = true
+				}
+			}
+			for _, version := range toolVersions(relation) {
+				referenced[version.ObjectID] = true
+			}
+		}
+	}
+	if entries, err := os.ReadDir(filepath.Join(s.baseDir, "commands")); err == nil {
+		for _, entry := range entries {
+			if objectID := s.commandObjectID(entry.Name()); objectID != "" {
+				referenced[objectID] = true
+			}
+		}
+	}
+
+	// Sessions whose last update is past the cutoff
+	s.indexMutex.RLock()
+	prunedSessions := make(map[string]SessionReference)
+	for sessionID, ref := range s.sessionIndex.Sessions {
+		if !wanted[strings.ToLower(ref.State)] || ref.LastUpdated.After(cutoff) {
+			continue
+		}
+		if referenced[sessionID] || referenced[ref.ObjectID] {
+			report.KeptReferenced = append(report.KeptReferenced, sessionID)
+			continue
+		}
+		prunedSessions[sessionID] = ref
+	}
+	s.indexMutex.RUnlock()
+
+	// Objects in a pruned state, plus every stored version of a pruned session
+	docs, _ := s.searchIndex.snapshot("", "")
+	var doomed []string
+	versions := make(map[string]int)
+	for _, meta := range docs {
+		if meta.Type == "session" {
+			if _, ok := prunedSessions[meta.Session]; ok && !referenced[meta.ID] {
+				versions[meta.Session]++
+				doomed = append(doomed, meta.ID)
+			}
+			continue
+		}
+		if !wanted[strings.ToLower(meta.Lifecycle)] || meta.Modified.After(cutoff) {
+			continue
+		}
+		if referenced[meta.ID] {
+			report.KeptReferenced = append(report.KeptReferenced, meta.ID)
+			continue
+		}
+		report.Objects = append(report.Objects, PrunedObject{
+			ID:        meta.ID,
+			Type:      meta.Type,
+			Title:     meta.Title,
+			Lifecycle: meta.Lifecycle,
+			Modified:  meta.Modified,
+		})
+		doomed = append(doomed, meta.ID)
+	}
+
+	for sessionID, ref := range prunedSessions {
+		report.Sessions = append(report.Sessions, PrunedSession{
+			SessionID:   sessionID,
+			Agent:       ref.Agent,
+			State:       ref.State,
+			LastUpdated: ref.LastUpdated,
+			Versions:    versions[sessionID],
+		})
+	}
+	sort.Slice(report.Objects, func(i, j int) bool { return report.Objects[i].ID < report.Objects[j].ID })
+	sort.Slice(report.Sessions, func(i, j int) bool { return report.Sessions[i].SessionID < report.Sessions[j].SessionID })
+	sort.Strings(report.KeptReferenced)
+
+	if dryRun {
+		for _, id := range doomed {
+			_, size := s.objectFileInfo(id)
+			report.FreedBytes += size
+			if info, err := os.Stat(filepath.Join(s.metadataDir, id+".json")); err == nil {
+				report.FreedBytes += info.Size()
+			}
+		}
+		log.Printf("✂️ [PRUNE] Would remove %d objects and %d sessions older than %v (%d bytes)",
+			len(report.Objects), len(report.Sessions), olderThan, report.FreedBytes)
+		return report, nil
+	}
+
+	// Checked again under the object's locks: an object revived or edited
+	// since the scan is kept
+	stillDoomed := func(meta *Metadata) bool {
+		if meta.Type == "session" {
+			_, ok := prunedSessions[meta.Session]
+			return ok
+		}
+		return wanted[strings.ToLower(meta.Lifecycle)] && !meta.Modified.After(cutoff)
+	}
+	for _, id := range doomed {
+		freed, deleted, err := s.deleteObjectIf(id, stillDoomed)
+		if err != nil {
+			log.Printf("⚠️ [PRUNE] Failed to delete %s: %v", id[:12]+"...", err)
+			continue
+		}
+		if !deleted {
+			log.Printf("✂️ [PRUNE] Keeping %s, it changed since the scan", id[:12]+"...")
+			continue
+		}
+		report.DeletedObjects++
+		report.FreedBytes += freed
+	}
+
+	if len(prunedSessions) > 0 {
+		s.indexMutex.Lock()
+		for sessionID := range prunedSessions {
+			delete(s.sessionIndex.Sessions, sessionID)
+		}
+		for agent, sessionID := range s.sessionIndex.LastSessions {
+			if _, ok := prunedSessions[sessionID]; ok {
+				delete(s.sessionIndex.LastSessions, agent)
+			}
+		}
+		s.updateStats()
+		err := s.saveSessionIndex()
+		s.indexMutex.Unlock()
+		if err != nil {
+			return report, fmt.Errorf("failed to save session index: %w", err)
+		}
+	}
+
+	log.Printf("✂️ [PRUNE] Removed %d objects and %d sessions older than %v: %d deleted, %d bytes freed",
+		len(report.Objects), len(report.Sessions), olderThan, report.DeletedObjects, report.FreedBytes)
+	return report, nil
+}
+
+// parsePruneAge parses a Go duration, also accepting whole days as "30d"
+func parsePruneAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var age time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid day count %q", value)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		age = parsed
+	}
+	if age < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return age, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxReadPaths caps how many paths one read_paths request may name
+const maxReadPaths = 100
+
+// handleReadPaths reads several virtual paths in one request, each the way
+// read_path would. A path that fails gets an error entry instead of failing
+// the request. Entries are added in request order until the response would
+// pass PORT42_MAX_RESPONSE_SIZE; the paths after that get a
+// RESPONSE_TOO_LARGE entry, to be read on their own.
+func (d *Daemon) handleReadPaths(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		Paths    []string `json:"paths"`
+		Encoding string   `json:"encoding,omitempty"` // "base64" (default) or "utf8", for every path
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if len(payload.Paths) == 0 {
+		return NewErrorResponse(req.ID, "paths is required")
+	}
+	if len(payload.Paths) > maxReadPaths {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Too many paths: %d (max %d per request)", len(payload.Paths), maxReadPaths))
+	}
+
+	encoding := strings.ToLower(payload.Encoding)
+	if encoding == "" {
+		encoding = ReadEncodingBase64
+	}
+	if encoding != ReadEncodingBase64 && encoding != ReadEncodingUTF8 {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Unsupported encoding: %s (use base64 or utf8)", payload.Encoding))
+	}
+
+	// The envelope, path keys and error entries all need room too
+	budget := d.config.MaxResponseSize
+	if budget > 0 {
+		budget -= responseFlagReserve
+	}
+
+	results := make(map[string]interface{}, len(payload.Paths))
+	used, failed := 0, 0
+	var skipped []string
+	for _, path := range payload.Paths {
+		if _, done := results[path]; done {
+			continue
+		}
+
+		entry, err := d.readPathData(path, encoding)
+		if err != nil {
+			entry = map[string]interface{}{"error": err.Error()}
+		}
+
+		size := encodedSize(entry) + len(path) + 4
+		if budget > 0 && used+size > budget {
+			entry = map[string]interface{}{
+				"error": fmt.Sprintf("RESPONSE_TOO_LARGE: left out to keep the response under %d bytes; read it with read_path", d.config.MaxResponseSize),
+			}
+			size = encodedSize(entry) + len(path) + 4
+			skipped = append(skipped, path)
+		}
+		if _, isError := entry["error"]; isError {
+			failed++
+		}
+		used += size
+		results[path] = entry
+	}
+
+	data := map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+		"failed":  failed,
+	}
+	if len(skipped) > 0 {
+		data["skipped"] = skipped
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(data)
+	return resp
+}
@@ -11,6 +11,7 @@ type RealityCompiler struct {
 	relationStore RelationStore
 	materializers []Materializer
 	ruleEngine    *RuleEngine // Step 2: Auto-spawning rules
+	events        *EventBus   // Declare and materialize activity for watch
 }
 
 // NewRealityCompiler creates a new reality compiler
@@ -43,12 +44,36 @@ func (rc *RealityCompiler) DeclareRelation(relation Relation) (*MaterializedEnti
 	}
 	relation.UpdatedAt = now
 	
-	// Store the relation (what should exist)
-	if err := rc.relationStore.Save(relation); err != nil {
+	// Store the relation (what should exist). Redeclaring a stored relation
+	// keeps its executable history; doing that under the relation's lock
+	// means a concurrent materialize can't have its version overwritten.
+	replaced := false
+	err := rc.relationStore.Update(relation.ID, func(existing *Relation) error {
+		for _, key := range []string{PropVersions, "executable_id"} {
+			if _, has := relation.Properties[key]; has {
+				continue
+			}
+			if value, ok := existing.Properties[key]; ok {
+				if relation.Properties == nil {
+					relation.Properties = make(map[string]interface{})
+				}
+				relation.Properties[key] = value
+			}
+		}
+		replaced = true
+		*existing = relation
+		return nil
+	})
+	if err != nil && !replaced {
+		err = rc.relationStore.Save(relation)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to store relation: %w", err)
 	}
 	
 	log.Printf("✅ Relation stored: %s", relation.ID)
+	rc.events.publishRelation(relation, "declared", "", "")
+	rc.syncDerivedEdges(relation)
 	
 	// Check if this relation type needs materialization
 	if !rc.shouldMaterialize(relation) {
@@ -86,10 +111,12 @@ func (rc *RealityCompiler) DeclareRelation(relation Relation) (*MaterializedEnti
 	// Materialize into physical reality
 	entity, err := materializer.Materialize(relation)
 	if err != nil {
+		rc.events.publishRelation(relation, "failed", "", err.Error())
 		return nil, fmt.Errorf("materialization failed: %w", err)
 	}
 	
 	log.Printf("🎉 Relation materialized successfully: %s -> %s", relation.ID, entity.PhysicalPath)
+	rc.events.publishRelation(relation, "materialized", entity.PhysicalPath, "")
 	
 	// Step 2: Trigger auto-spawning rules (for materialized relations)
 	if rc.ruleEngine != nil {
@@ -110,7 +137,9 @@ func (rc *RealityCompiler) shouldMaterialize(relation Relation) bool {
 	// Data-only relation types don't need physical materialization
 	dataOnlyTypes := map[string]bool{
 		"URLArtifact": true,
+		"GitArtifact": true,
 		"Artifact":    true, // Documentation and other artifacts are metadata-only
+		"Relationship": true, // Graph edges (see relation_graph.go)
 		// Add other data-only types as needed:
 		// "SearchResult": true,
 		// "MemoryContext": true,
@@ -128,6 +157,16 @@ func (rc *RealityCompiler) findMaterializer(relation Relation) Materializer {
 	return nil
 }
 
+// PreviewRelation generates what materializing a relation would produce,
+// without storing the relation or anything it would create
+func (rc *RealityCompiler) PreviewRelation(relation Relation) (*ToolPreview, error) {
+	previewer, ok := rc.findMaterializer(relation).(Previewer)
+	if !ok {
+		return nil, fmt.Errorf("relation type %s cannot be previewed", relation.Type)
+	}
+	return previewer.Preview(relation)
+}
+
 // GetRelation retrieves a relation by ID
 func (rc *RealityCompiler) GetRelation(id string) (*Relation, error) {
 	return rc.relationStore.Load(id)
@@ -145,6 +184,89 @@ func (rc *RealityCompiler) ListRelationsByType(relationType string) ([]Relation,
 
 // DeleteRelation removes a relation and attempts to dematerialize it
 func (rc *RealityCompiler) DeleteRelation(id string) error {
+	_, err := rc.DeleteRelationCascade(id, false)
+	return err
+}
+
+// RelationDeleteResult describes what a delete removed and what it left behind
+type RelationDeleteResult struct {
+	DeletedRelations []string `json:"deleted_relations"`
+	RemovedPaths     []string `json:"removed_paths"`
+	OrphanedChildren []string `json:"orphaned_children,omitempty"`
+}
+
+// FindSpawnedChildren returns the relations whose spawned_by points at id
+func (rc *RealityCompiler) FindSpawnedChildren(id string) ([]Relation, error) {
+	relations, err := rc.relationStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list relations: %w", err)
+	}
+	
+	var children []Relation
+	for _, relation := range relations {
+		if spawnedBy, ok := relation.Properties["spawned_by"].(string); ok && spawnedBy == id {
+			children = append(children, relation)
+		}
+	}
+	return children, nil
+}
+
+// DeleteRelationCascade removes a relation. With cascade, every relation it
+// spawned (directly or through its children) is deleted first; without it,
+// the spawned children are left in place and reported as orphans.
+func (rc *RealityCompiler) DeleteRelationCascade(id string, cascade bool) (*RelationDeleteResult, error) {
+	result := &RelationDeleteResult{
+		DeletedRelations: []string{},
+		RemovedPaths:     []string{},
+	}
+	
+	if !cascade {
+		children, err := rc.FindSpawnedChildren(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			result.OrphanedChildren = append(result.OrphanedChildren, child.ID)
+		}
+		if len(children) > 0 {
+			log.Printf("⚠️ Deleting %s leaves %d spawned children orphaned: %v", id, len(children), result.OrphanedChildren)
+		}
+		if err := rc.deleteSingleRelation(id, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	
+	visited := make(map[string]bool)
+	if err := rc.deleteRelationTree(id, visited, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// deleteRelationTree deletes children before their parent so a failure
+// never leaves a child pointing at a relation that is already gone
+func (rc *RealityCompiler) deleteRelationTree(id string, visited map[string]bool, result *RelationDeleteResult) error {
+	if visited[id] {
+		return nil
+	}
+	visited[id] = true
+	
+	children, err := rc.FindSpawnedChildren(id)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := rc.deleteRelationTree(child.ID, visited, result); err != nil {
+			return err
+		}
+	}
+	
+	return rc.deleteSingleRelation(id, result)
+}
+
+// deleteSingleRelation dematerializes and deletes one relation
+func (rc *RealityCompiler) deleteSingleRelation(id string, result *RelationDeleteResult) error {
 	log.Printf("🗑️ Deleting relation: %s", id)
 	
 	// Load relation first
@@ -162,6 +284,8 @@ func (rc *RealityCompiler) DeleteRelation(id string) error {
 				if err := materializer.Dematerialize(entity); err != nil {
 					log.Printf("⚠️ Failed to dematerialize relation %s: %v", id, err)
 					// Continue with deletion even if dematerialization fails
+				} else if entity.PhysicalPath != "" {
+					result.RemovedPaths = append(result.RemovedPaths, entity.PhysicalPath)
 				}
 			}
 		}
@@ -171,13 +295,26 @@ func (rc *RealityCompiler) DeleteRelation(id string) error {
 	if err := rc.relationStore.Delete(id); err != nil {
 		return fmt.Errorf("failed to delete relation: %w", err)
 	}
+	result.DeletedRelations = append(result.DeletedRelations, id)
+	if relation.Type != "Relationship" {
+		rc.deleteEdgesOf(id)
+	}
 	
 	log.Printf("✅ Relation deleted successfully: %s", id)
 	return nil
 }
 
+// SetEventBus publishes declare, materialize and rule activity to events
+func (rc *RealityCompiler) SetEventBus(events *EventBus) {
+	rc.events = events
+	if rc.ruleEngine != nil {
+		rc.ruleEngine.events = events
+	}
+}
+
 // SetRuleEngine sets the rule engine for auto-spawning behavior
 func (rc *RealityCompiler) SetRuleEngine(ruleEngine *RuleEngine) {
+	ruleEngine.events = rc.events
 	rc.ruleEngine = ruleEngine
 	log.Printf("🎯 Rule engine attached to reality compiler")
 }
\ No newline at end of file
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Secret values are masked out of anything the daemon echoes back, such as
+// explain-mode prompts. Provider API keys are always redacted; PORT42_REDACT_ENV
+// adds a comma-separated allowlist of further environment variables whose
+// values must never be returned.
+const redactedMarker = "[REDACTED]"
+
+// minRedactLength avoids masking short values like "1" all over a prompt
+const minRedactLength = 6
+
+// secretValues returns the current values of every redacted variable
+func secretValues() []string {
+	names := []string{"PORT42_ANTHROPIC_API_KEY", "ANTHROPIC_API_KEY", "PORT42_OPENAI_API_KEY"}
+	for _, name := range strings.Split(envString("PORT42_REDACT_ENV", ""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	var values []string
+	for _, name := range names {
+		if value := os.Getenv(name); len(value) >= minRedactLength {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// redactSecrets replaces any secret value found in text with a marker
+func redactSecrets(text string) string {
+	for _, value := range secretValues() {
+		text = strings.ReplaceAll(text, value, redactedMarker)
+	}
+	return text
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
+	
 	"port42/daemon/resolution"
 )
 
@@ -11,23 +13,45 @@ type ReferenceResolutionResult struct {
 	Success      bool
 	ResolvedText string
 	Contexts     []*resolution.ResolvedContext
+	Truncations  []resolution.TruncationRecord // References cut to fit the context budget
 	Error        error
 }
 
 // ReferenceHandler provides common reference resolution functionality
 type ReferenceHandler struct {
 	resolutionService resolution.ResolutionService
+	budget            resolution.ContextBudget
 }
 
 // NewReferenceHandler creates a new reference handler
 func NewReferenceHandler(resolutionService resolution.ResolutionService) *ReferenceHandler {
 	return &ReferenceHandler{
 		resolutionService: resolutionService,
+		budget:            loadContextBudget(),
 	}
 }
 
-// ResolveReferences performs the common reference resolution logic
-func (rh *ReferenceHandler) ResolveReferences(references []Reference, mode string) *ReferenceResolutionResult {
+// loadContextBudget reads the prompt+reference budget from the environment
+func loadContextBudget() resolution.ContextBudget {
+	budget := resolution.DefaultContextBudget()
+	budget.MaxTotalSize = envInt("PORT42_CONTEXT_BUDGET", budget.MaxTotalSize)
+	budget.MaxReferenceSize = envInt("PORT42_REFERENCE_MAX_SIZE", budget.MaxReferenceSize)
+	
+	policy := strings.ToLower(envString("PORT42_CONTEXT_TRUNCATION", budget.Policy))
+	if resolution.IsValidTruncationPolicy(policy) {
+		budget.Policy = policy
+	} else {
+		log.Printf("⚠️ Unknown PORT42_CONTEXT_TRUNCATION %q, using %s", policy, budget.Policy)
+	}
+	
+	log.Printf("📏 Context budget: total=%d bytes, per-reference=%d bytes, policy=%s", 
+		budget.MaxTotalSize, budget.MaxReferenceSize, budget.Policy)
+	return budget
+}
+
+// ResolveReferences performs the common reference resolution logic.
+// promptSize is the length of the user prompt sharing the context budget.
+func (rh *ReferenceHandler) ResolveReferences(references []Reference, mode string, promptSize int) *ReferenceResolutionResult {
 	result := &ReferenceResolutionResult{
 		Success: false,
 	}
@@ -58,7 +82,7 @@ func (rh *ReferenceHandler) ResolveReferences(references []Reference, mode strin
 		return result
 	}
 
-	log.Printf("🔍 Resolving references for %s context...", mode)
+	logger.Debugf("🔍 Resolving references for %s context...", mode)
 
 	// Phase 3: Convert protocol references to resolution references
 	var resolutionRefs []resolution.Reference
@@ -67,11 +91,12 @@ func (rh *ReferenceHandler) ResolveReferences(references []Reference, mode strin
 			Type:    ref.Type,
 			Target:  ref.Target,
 			Context: ref.Context,
+			NoCache: ref.NoCache,
 		})
 	}
 
 	// Phase 4: Resolve references
-	contextStr, contexts, err := rh.resolutionService.ResolveForAI(resolutionRefs)
+	contextStr, contexts, truncations, err := rh.resolutionService.ResolveForAIWithBudget(resolutionRefs, rh.budget, promptSize)
 	if err != nil {
 		log.Printf("⚠️ Reference resolution failed: %v", err)
 		result.Error = fmt.Errorf("resolution failed: %w", err)
@@ -81,6 +106,12 @@ func (rh *ReferenceHandler) ResolveReferences(references []Reference, mode strin
 	// Phase 5: Process results
 	result.Contexts = contexts
 	result.ResolvedText = contextStr
+	result.Truncations = truncations
+	
+	if len(truncations) > 0 {
+		log.Printf("✂️ %d of %d references truncated to fit %d byte context budget (%s)", 
+			len(truncations), len(references), rh.budget.MaxTotalSize, rh.budget.Policy)
+	}
 
 	if contextStr != "" {
 		log.Printf("✨ Resolved reference context (%d chars)", len(contextStr))
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Edge types. Edges are Relationship relations in the relation store with
+// relationship_type, from and to properties, the same shape the similarity
+// calculator uses for similar_to.
+const (
+	EdgeDependsOn   = "depends_on"   // The tool needs the other tool to work
+	EdgeReferences  = "references"   // The relation was declared with a reference to the other one
+	EdgeDerivedFrom = "derived_from" // The relation was spawned from the other one
+	EdgeSimilarTo   = "similar_to"   // Written by the similarity calculator
+)
+
+// edgeTypes are the edge types get_graph accepts in its types filter
+var edgeTypes = []string{EdgeDependsOn, EdgeReferences, EdgeDerivedFrom, EdgeSimilarTo}
+
+// edgeCreator marks edges derived from a relation's properties, so a
+// redeclare replaces them without touching edges declared by hand
+const edgeCreator = "relation_edges"
+
+// maxGraphDepth caps how far get_graph walks from its root
+const maxGraphDepth = 5
+
+// GraphEdge is one typed edge between two relations
+type GraphEdge struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// GraphNode is a relation reached by get_graph
+type GraphNode struct {
+	ID      string `json:"id"`
+	Type    string `json:"type,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Depth   int    `json:"depth"`             // Edges from the root
+	Missing bool   `json:"missing,omitempty"` // An edge names a relation that no longer exists
+}
+
+// RelationGraph is the neighborhood of a relation
+type RelationGraph struct {
+	Root  string      `json:"root"`
+	Depth int         `json:"depth"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// edgeID names the relation holding an edge, so declaring the same edge
+// twice stores it once
+func edgeID(edgeType, from, to string) string {
+	return fmt.Sprintf("edge-%s-%s-%s", strings.ReplaceAll(edgeType, "_", "-"), from, to)
+}
+
+// newEdgeRelation builds the relation that stores an edge
+func newEdgeRelation(edgeType, from, to string) Relation {
+	return Relation{
+		ID:   edgeID(edgeType, from, to),
+		Type: "Relationship",
+		Properties: map[string]interface{}{
+			"relationship_type": edgeType,
+			"from":              from,
+			"to":                to,
+			"auto_generated":    true,
+			"created_by":        edgeCreator,
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// asGraphEdge reads an edge from a Relationship relation
+func asGraphEdge(relation Relation) (GraphEdge, bool) {
+	if relation.Type != "Relationship" {
+		return GraphEdge{}, false
+	}
+	edge := GraphEdge{
+		ID:   relation.ID,
+		Type: getStringProperty(relation.Properties, "relationship_type"),
+		From: getStringProperty(relation.Properties, "from"),
+		To:   getStringProperty(relation.Properties, "to"),
+	}
+	return edge, edge.Type != "" && edge.From != "" && edge.To != ""
+}
+
+// derivedEdges works out the edges a relation's properties imply:
+// spawned_by gives derived_from, depends_on (tool names or relation IDs)
+// gives depends_on, and references to a tool (tool:, or p42: paths under
+// /tools/ or /commands/) or to a /relations/ path give references.
+// Targets that aren't stored relations are skipped.
+func derivedEdges(relation Relation, relations []Relation) []Relation {
+	byID := make(map[string]bool, len(relations))
+	toolIDs := make(map[string]string)
+	for _, other := range relations {
+		byID[other.ID] = true
+		if other.Type == "Tool" {
+			toolIDs[getRelationName(other)] = other.ID
+		}
+	}
+	resolve := func(target string) string {
+		if byID[target] {
+			return target
+		}
+		return toolIDs[target]
+	}
+
+	var edges []Relation
+	seen := make(map[string]bool)
+	add := func(edgeType, target string) {
+		to := resolve(strings.TrimSpace(target))
+		if to == "" || to == relation.ID {
+			return
+		}
+		edge := newEdgeRelation(edgeType, relation.ID, to)
+		if !seen[edge.ID] {
+			seen[edge.ID] = true
+			edges = append(edges, edge)
+		}
+	}
+
+	if spawnedBy := getStringProperty(relation.Properties, "spawned_by"); spawnedBy != "" {
+		add(EdgeDerivedFrom, spawnedBy)
+	}
+	if value, ok := relation.Properties["depends_on"]; ok {
+		for _, target := range canonicalStringList(value) {
+			add(EdgeDependsOn, target)
+		}
+	}
+	for _, ref := range relationReferences(relation) {
+		if name := referencedToolName(ref); name != "" {
+			add(EdgeReferences, name)
+		} else if id, ok := strings.CutPrefix(strings.TrimPrefix(ref.Target, "p42:"), "/relations/"); ok && ref.Type == "p42" {
+			add(EdgeReferences, strings.SplitN(id, "/", 2)[0])
+		}
+	}
+	return edges
+}
+
+// relationReferences reads the references a relation was declared with,
+// which are []Reference before the relation is stored and decoded JSON after
+func relationReferences(relation Relation) []Reference {
+	value, ok := relation.Properties["references"]
+	if !ok {
+		return nil
+	}
+	if refs, ok := value.([]Reference); ok {
+		return refs
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var refs []Reference
+	json.Unmarshal(data, &refs)
+	return refs
+}
+
+// resolveEdgeEndpoints lets a declared Relationship name its ends by tool
+// name: a from or to that isn't a stored relation but is a tool's name
+// becomes that tool's relation ID. An edge declared without an ID gets
+// the one derived edges use, so the same edge is only stored once.
+func (d *Daemon) resolveEdgeEndpoints(relation *Relation) {
+	for _, key := range []string{"from", "to"} {
+		end := getStringProperty(relation.Properties, key)
+		if end == "" || d.storage == nil || d.storage.relationStore == nil {
+			continue
+		}
+		if _, err := d.storage.relationStore.Load(end); err == nil {
+			continue
+		}
+		if tool, err := d.storage.findToolRelation(end); err == nil {
+			relation.Properties[key] = tool.ID
+		}
+	}
+	if relation.ID == "" {
+		relation.ID = edgeID(getStringProperty(relation.Properties, "relationship_type"),
+			getStringProperty(relation.Properties, "from"), getStringProperty(relation.Properties, "to"))
+	}
+}
+
+// syncDerivedEdges stores the edges a relation's properties imply and
+// removes derived edges from it that no longer apply
+func (rc *RealityCompiler) syncDerivedEdges(relation Relation) {
+	if relation.Type == "Relationship" {
+		return
+	}
+	relations, err := rc.relationStore.List()
+	if err != nil {
+		log.Printf("⚠️ Failed to list relations for %s edges: %v", relation.ID, err)
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for _, edge := range derivedEdges(relation, relations) {
+		wanted[edge.ID] = true
+		if err := rc.relationStore.Save(edge); err != nil {
+			log.Printf("⚠️ Failed to store edge %s: %v", edge.ID, err)
+		}
+	}
+	for _, other := range relations {
+		edge, ok := asGraphEdge(other)
+		if !ok || edge.From != relation.ID || wanted[edge.ID] || getStringProperty(other.Properties, "created_by") != edgeCreator {
+			continue
+		}
+		if err := rc.relationStore.Delete(edge.ID); err != nil {
+			log.Printf("⚠️ Failed to remove stale edge %s: %v", edge.ID, err)
+		}
+	}
+}
+
+// deleteEdgesOf removes every edge from or to id, once id itself is gone
+func (rc *RealityCompiler) deleteEdgesOf(id string) {
+	relations, err := rc.relationStore.List()
+	if err != nil {
+		log.Printf("⚠️ Failed to list relations for %s edges: %v", id, err)
+		return
+	}
+	for _, relation := range relations {
+		if edge, ok := asGraphEdge(relation); ok && (edge.From == id || edge.To == id) {
+			if err := rc.relationStore.Delete(edge.ID); err != nil {
+				log.Printf("⚠️ Failed to remove edge %s: %v", edge.ID, err)
+			}
+		}
+	}
+}
+
+// Graph returns the relations within depth edges of root, following edges
+// out of a node ("out"), into it ("in", e.g. what depends on a tool) or
+// both. root may be a relation ID or a tool name. types limits the edge
+// types followed; empty follows all of them.
+func (rc *RealityCompiler) Graph(root string, depth int, direction string, types []string) (*RelationGraph, error) {
+	relations, err := rc.relationStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list relations: %w", err)
+	}
+
+	byID := make(map[string]Relation, len(relations))
+	for _, relation := range relations {
+		byID[relation.ID] = relation
+	}
+	if _, ok := byID[root]; !ok {
+		if named := toolsNamed(relations, root); len(named) > 0 {
+			tool, _ := chooseToolRelation(root, named)
+			root = tool.ID
+		}
+	}
+	if _, ok := byID[root]; !ok {
+		return nil, fmt.Errorf("no relation or tool named %s", root)
+	}
+
+	follow := make(map[string]bool)
+	for _, edgeType := range types {
+		follow[edgeType] = true
+	}
+	out := make(map[string][]GraphEdge)
+	in := make(map[string][]GraphEdge)
+	for _, relation := range relations {
+		edge, ok := asGraphEdge(relation)
+		if !ok || (len(follow) > 0 && !follow[edge.Type]) {
+			continue
+		}
+		out[edge.From] = append(out[edge.From], edge)
+		in[edge.To] = append(in[edge.To], edge)
+	}
+
+	graph := &RelationGraph{Root: root, Depth: depth, Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	depths := map[string]int{root: 0}
+	edgesSeen := make(map[string]bool)
+	frontier := []string{root}
+	for level := 1; level <= depth && len(frontier) > 0; level++ {
+		var next []string
+		for _, id := range frontier {
+			var edges []GraphEdge
+			if direction != "in" {
+				edges = append(edges, out[id]...)
+			}
+			if direction != "out" {
+				edges = append(edges, in[id]...)
+			}
+			for _, edge := range edges {
+				if !edgesSeen[edge.ID] {
+					edgesSeen[edge.ID] = true
+					graph.Edges = append(graph.Edges, edge)
+				}
+				neighbor := edge.To
+				if neighbor == id {
+					neighbor = edge.From
+				}
+				if _, visited := depths[neighbor]; !visited {
+					depths[neighbor] = level
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	for id, nodeDepth := range depths {
+		node := GraphNode{ID: id, Depth: nodeDepth}
+		if relation, ok := byID[id]; ok {
+			node.Type = relation.Type
+			node.Name = getRelationName(relation)
+		} else {
+			node.Missing = true
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		if graph.Nodes[i].Depth != graph.Nodes[j].Depth {
+			return graph.Nodes[i].Depth < graph.Nodes[j].Depth
+		}
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool { return graph.Edges[i].ID < graph.Edges[j].ID })
+	return graph, nil
+}
+
+// handleGetGraph returns the neighborhood of a relation: the relations
+// within depth edges of it and the edges between them
+func (d *Daemon) handleGetGraph(req Request) Response {
+	if d.realityCompiler == nil {
+		return NewErrorResponse(req.ID, "Reality compiler not initialized")
+	}
+
+	var payload struct {
+		ID        string   `json:"id"`        // Relation ID or tool name
+		Depth     int      `json:"depth"`     // Default 1, at most maxGraphDepth
+		Direction string   `json:"direction"` // "out", "in" or "both" (default)
+		Types     []string `json:"types"`     // Edge types to follow, default all
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if payload.ID == "" {
+		return NewErrorResponse(req.ID, "id is required")
+	}
+	if payload.Depth <= 0 {
+		payload.Depth = 1
+	}
+	if payload.Depth > maxGraphDepth {
+		payload.Depth = maxGraphDepth
+	}
+	switch payload.Direction {
+	case "":
+		payload.Direction = "both"
+	case "in", "out", "both":
+	default:
+		return NewErrorResponse(req.ID, fmt.Sprintf("Invalid direction %q (expected in, out or both)", payload.Direction))
+	}
+	for _, edgeType := range payload.Types {
+		if !contains(edgeTypes, edgeType) {
+			return NewErrorResponse(req.ID, fmt.Sprintf("Unknown edge type %q (expected one of %s)", edgeType, strings.Join(edgeTypes, ", ")))
+		}
+	}
+
+	graph, err := d.realityCompiler.Graph(payload.ID, payload.Depth, payload.Direction, payload.Types)
+	if err != nil {
+		return NewErrorResponse(req.ID, "Failed to build graph: "+err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(graph)
+	return resp
+}
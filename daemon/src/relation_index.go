@@ -0,0 +1,226 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RelationCacheStats reports how List and ListByType were served
+type RelationCacheStats struct {
+	Hits          int64 `json:"hits"`          // Served from the index
+	Misses        int64 `json:"misses"`        // Needed the relations directory read first
+	Invalidations int64 `json:"invalidations"` // Changes made outside the store that dropped the index
+	Cached        int   `json:"cached"`        // Relations indexed, 0 before the first read
+}
+
+// relationIndex holds every relation in memory. It is read from disk on
+// first use and then kept current by the store's own writes, so listing
+// never touches the disk. A change to the relations directory the store
+// didn't make, seen as its modification time moving, drops the index and
+// the next read rebuilds it, so files added or removed outside the daemon
+// still show up. The zero value is ready to use.
+type relationIndex struct {
+	mu      sync.RWMutex
+	loaded  bool
+	byID    map[string]Relation
+	byType  map[string]map[string]bool
+	sorted  []string  // All IDs in order
+	dirTime time.Time // Directory modification time the index is current with
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+// dirModTime is the modification time of dir, zero if it can't be read
+func dirModTime(dir string) time.Time {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// ensure loads the index with load, unless it is current with dir
+func (x *relationIndex) ensure(dir string, load func() ([]Relation, error)) error {
+	modTime := dirModTime(dir)
+
+	x.mu.RLock()
+	current := x.loaded && x.dirTime.Equal(modTime)
+	x.mu.RUnlock()
+	if current {
+		x.hits.Add(1)
+		return nil
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.loaded && x.dirTime.Equal(modTime) {
+		x.hits.Add(1)
+		return nil
+	}
+	if x.loaded {
+		x.invalidations.Add(1)
+	}
+	x.misses.Add(1)
+
+	// The time is taken before reading, so a change made during the read
+	// causes another one next time
+	relations, err := load()
+	if err != nil {
+		x.loaded = false
+		return err
+	}
+	x.byID = make(map[string]Relation, len(relations))
+	x.byType = make(map[string]map[string]bool)
+	x.sorted = nil
+	for _, relation := range relations {
+		x.add(relation)
+	}
+	x.dirTime = modTime
+	x.loaded = true
+	return nil
+}
+
+// add indexes a relation; callers hold mu
+func (x *relationIndex) add(relation Relation) {
+	if previous, ok := x.byID[relation.ID]; ok {
+		if previous.Type != relation.Type {
+			delete(x.byType[previous.Type], relation.ID)
+		}
+	} else {
+		i := sort.SearchStrings(x.sorted, relation.ID)
+		x.sorted = append(x.sorted, "")
+		copy(x.sorted[i+1:], x.sorted[i:])
+		x.sorted[i] = relation.ID
+	}
+	x.byID[relation.ID] = relation
+	if x.byType[relation.Type] == nil {
+		x.byType[relation.Type] = make(map[string]bool)
+	}
+	x.byType[relation.Type][relation.ID] = true
+}
+
+// put records a relation the store just wrote to dir. Nothing happens
+// before the first load, which will read it from disk.
+func (x *relationIndex) put(dir string, relation Relation) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.loaded {
+		return
+	}
+	x.add(relation)
+	x.dirTime = dirModTime(dir)
+}
+
+// remove drops a relation the store just deleted from dir
+func (x *relationIndex) remove(dir string, id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.loaded {
+		return
+	}
+	if relation, ok := x.byID[id]; ok {
+		delete(x.byType[relation.Type], id)
+		delete(x.byID, id)
+		i := sort.SearchStrings(x.sorted, id)
+		x.sorted = append(x.sorted[:i], x.sorted[i+1:]...)
+	}
+	x.dirTime = dirModTime(dir)
+}
+
+// invalidate drops the index, for changes made to relation files directly
+func (x *relationIndex) invalidate() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.loaded {
+		x.invalidations.Add(1)
+	}
+	x.loaded = false
+	x.byID = nil
+	x.byType = nil
+	x.sorted = nil
+}
+
+// list returns copies of every relation, ordered by ID. ok is false when
+// the index was dropped since ensure, and ensure needs calling again.
+func (x *relationIndex) list() (relations []Relation, ok bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if !x.loaded {
+		return nil, false
+	}
+
+	relations = make([]Relation, 0, len(x.sorted))
+	for _, id := range x.sorted {
+		relations = append(relations, cloneRelation(x.byID[id]))
+	}
+	return relations, true
+}
+
+// listByType is list for the relations of one type
+func (x *relationIndex) listByType(relationType string) (relations []Relation, ok bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if !x.loaded {
+		return nil, false
+	}
+
+	ids := make([]string, 0, len(x.byType[relationType]))
+	for id := range x.byType[relationType] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	relations = make([]Relation, 0, len(ids))
+	for _, id := range ids {
+		relations = append(relations, cloneRelation(x.byID[id]))
+	}
+	return relations, true
+}
+
+func (x *relationIndex) stats() RelationCacheStats {
+	x.mu.RLock()
+	cached := len(x.byID)
+	x.mu.RUnlock()
+
+	return RelationCacheStats{
+		Hits:          x.hits.Load(),
+		Misses:        x.misses.Load(),
+		Invalidations: x.invalidations.Load(),
+		Cached:        cached,
+	}
+}
+
+// cloneRelation copies a relation deeply enough that callers can modify
+// its properties without touching the index
+func cloneRelation(relation Relation) Relation {
+	if relation.Properties != nil {
+		relation.Properties = cloneValue(relation.Properties).(map[string]interface{})
+	}
+	return relation
+}
+
+// cloneValue deep-copies the maps and slices of a decoded JSON value
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = cloneValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = cloneValue(item)
+		}
+		return copied
+	case []string:
+		return cloneStrings(v)
+	default:
+		return v
+	}
+}
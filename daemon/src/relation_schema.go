@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Relation properties arrive in whatever shape the client, the AI or an
+// older daemon wrote them: a JSON array decodes to []interface{}, code
+// builds []string, and hand-written declarations sometimes pass a single
+// comma-separated string. normalizeRelation gives known properties one
+// canonical form; the relation store applies it on save and on load, so
+// code reading a relation can rely on the types below.
+
+// toolListProperties are Tool properties stored as []string
+var toolListProperties = []string{"transforms", "dependencies", "depends_on"}
+
+// toolTimeProperties are Tool properties stored as RFC3339 strings
+var toolTimeProperties = []string{PropLastRun}
+
+// normalizeRelation canonicalizes a relation's known properties. The
+// properties map is copied before it is changed, so other holders of the
+// original map (callers, the list cache) don't see it change under them.
+func normalizeRelation(relation *Relation) {
+	if relation.Type != "Tool" || relation.Properties == nil {
+		return
+	}
+
+	properties := make(map[string]interface{}, len(relation.Properties))
+	for key, value := range relation.Properties {
+		properties[key] = value
+	}
+
+	for _, key := range toolListProperties {
+		if value, ok := properties[key]; ok {
+			properties[key] = canonicalStringList(value)
+		}
+	}
+	for _, key := range toolTimeProperties {
+		if value, ok := properties[key]; ok {
+			if formatted, ok := canonicalTime(value); ok {
+				properties[key] = formatted
+			}
+		}
+	}
+	if agent, ok := properties["agent"].(string); ok {
+		if agent = strings.TrimSpace(agent); agent != "" {
+			properties["agent"] = "@" + strings.TrimLeft(agent, "@")
+		}
+	}
+
+	relation.Properties = properties
+}
+
+// canonicalStringList converts a list property to []string: arrays keep
+// their string items, and a bare string is split on commas. Blank and
+// repeated entries, and items that aren't strings, are dropped.
+func canonicalStringList(value interface{}) []string {
+	var items []string
+	switch list := value.(type) {
+	case []string:
+		items = list
+	case []interface{}:
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+	case string:
+		items = strings.Split(list, ",")
+	}
+
+	canonical := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item != "" && !seen[item] {
+			seen[item] = true
+			canonical = append(canonical, item)
+		}
+	}
+	return canonical
+}
+
+// canonicalTime formats a timestamp property as RFC3339. It accepts
+// time.Time, RFC3339 strings with or without fractional seconds, and Unix
+// seconds; anything else is left alone (ok=false).
+func canonicalTime(value interface{}) (string, bool) {
+	switch t := value.(type) {
+	case time.Time:
+		return t.Format(time.RFC3339), true
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(t))
+		if err != nil {
+			return "", false
+		}
+		return parsed.Format(time.RFC3339), true
+	case float64:
+		return time.Unix(int64(t), 0).Format(time.RFC3339), true
+	case int64:
+		return time.Unix(t, 0).Format(time.RFC3339), true
+	case int:
+		return time.Unix(int64(t), 0).Format(time.RFC3339), true
+	}
+	return "", false
+}
@@ -43,6 +43,8 @@ type RelationStore interface {
 	LoadByProperty(key, value string) ([]Relation, error)
 	Delete(id string) error
 	List() ([]Relation, error)
+	// Update atomically loads, modifies and saves one relation
+	Update(id string, update func(*Relation) error) error
 }
 
 // Materializer interface for turning relations into physical reality
@@ -52,6 +54,12 @@ type Materializer interface {
 	Dematerialize(entity *MaterializedEntity) error
 }
 
+// Previewer is implemented by materializers that can show what they would
+// create for a relation without creating anything
+type Previewer interface {
+	Preview(relation Relation) (*ToolPreview, error)
+}
+
 // MaterializationStore tracks what has been materialized
 type MaterializationStore interface {
 	Save(entity MaterializedEntity) error
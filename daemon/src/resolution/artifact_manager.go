@@ -12,10 +12,10 @@ type ArtifactManager struct {
 }
 
 // NewArtifactManager creates a new artifact manager
-func NewArtifactManager(relations RelationsManager) *ArtifactManager {
+func NewArtifactManager(relations RelationsManager, policy CachePolicy) *ArtifactManager {
 	return &ArtifactManager{
 		relations: relations,
-		policy:    DefaultCachePolicy(),
+		policy:    policy,
 	}
 }
 
@@ -49,7 +49,7 @@ func (am *ArtifactManager) LoadCached(artifactID string) (*URLArtifactRelation,
 		age := time.Since(time.Unix(fetchedAt, 0))
 		
 		// DEBUG: Log all timestamp fields for debugging
-		log.Printf("🕐 Cache EXPIRED: %s (age: %v, TTL: %v)", artifactID, age.Truncate(time.Second), am.policy.DefaultTTL)
+		log.Printf("🕐 Cache EXPIRED: %s (age: %v, TTL: %v)", artifactID, age.Truncate(time.Second), am.ttlFor(relation))
 		log.Printf("🔍 DEBUG Timestamps - fetched_at: %d (type: %T), UpdatedAt: %s, CreatedAt: %s", 
 			fetchedAt, relation.Properties["fetched_at"], relation.UpdatedAt.Format("2006-01-02 15:04:05"), relation.CreatedAt.Format("2006-01-02 15:04:05"))
 		if debugStr, exists := relation.Properties["debug_fetched"].(string); exists {
@@ -75,10 +75,30 @@ func (am *ArtifactManager) LoadCached(artifactID string) (*URLArtifactRelation,
 		}
 	}
 	age := time.Since(time.Unix(fetchedAt, 0))
-	log.Printf("✅ Cache VALID: %s (age: %v, TTL: %v)", artifactID, age.Truncate(time.Second), am.policy.DefaultTTL)
+	log.Printf("✅ Cache VALID: %s (age: %v, TTL: %v)", artifactID, age.Truncate(time.Second), am.ttlFor(relation))
 	return relation, nil
 }
 
+// LoadStale returns an expired artifact that can be revalidated with a
+// conditional request, or nil if there is none or it has no validators
+func (am *ArtifactManager) LoadStale(artifactID string) *URLArtifactRelation {
+	if am.relations == nil {
+		return nil
+	}
+	
+	relation, err := am.relations.GetRelationByID(artifactID)
+	if err != nil || relation.Content == "" {
+		return nil
+	}
+	
+	etag, _ := relation.Properties["etag"].(string)
+	lastModified, _ := relation.Properties["last_modified"].(string)
+	if etag == "" && lastModified == "" {
+		return nil
+	}
+	return relation
+}
+
 // Store saves a URL artifact to Relations if it should be cached
 func (am *ArtifactManager) Store(artifact *URLArtifactRelation) error {
 	if am.relations == nil {
@@ -129,7 +149,13 @@ func (am *ArtifactManager) IsExpired(artifact *URLArtifactRelation) bool {
 		return true // No timestamp means expired
 	}
 	
-	return am.policy.IsExpired(time.Unix(fetchedAt, 0))
+	return time.Since(time.Unix(fetchedAt, 0)) > am.ttlFor(artifact)
+}
+
+// ttlFor is the artifact's freshness lifetime, honoring its stored Cache-Control
+func (am *ArtifactManager) ttlFor(artifact *URLArtifactRelation) time.Duration {
+	cacheControl, _ := artifact.Properties["cache_control"].(string)
+	return am.policy.TTLFor(cacheControl)
 }
 
 // UpdateUsage tracks usage of a cached artifact for analytics
@@ -189,8 +215,9 @@ func (am *ArtifactManager) shouldStore(artifact *URLArtifactRelation) bool {
 	statusCode, _ := artifact.Properties["status_code"].(int)
 	contentLength, _ := artifact.Properties["content_length"].(int)
 	contentType, _ := artifact.Properties["content_type"].(string)
+	cacheControl, _ := artifact.Properties["cache_control"].(string)
 	
-	return am.policy.ShouldCache(sourceURL, statusCode, int64(contentLength), contentType)
+	return am.policy.ShouldCache(sourceURL, statusCode, int64(contentLength), contentType, cacheControl)
 }
 
 // updateLastAccessed updates the last accessed timestamp (internal helper)
@@ -1,6 +1,8 @@
 package resolution
 
 import (
+	"context"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,13 +26,66 @@ func (cp CachePolicy) IsExpired(fetchedAt time.Time) bool {
 	return time.Since(fetchedAt) > cp.DefaultTTL
 }
 
+// TTLFor returns how long a response stays fresh: its Cache-Control max-age
+// when it has one, zero for no-cache (always revalidate), else DefaultTTL
+func (cp CachePolicy) TTLFor(cacheControl string) time.Duration {
+	maxAge, hasMaxAge, noCache, _ := parseCacheControl(cacheControl)
+	switch {
+	case noCache:
+		return 0
+	case hasMaxAge:
+		return maxAge
+	default:
+		return cp.DefaultTTL
+	}
+}
+
+// parseCacheControl reads the directives of a Cache-Control header that
+// matter to the reference cache
+func parseCacheControl(value string) (maxAge time.Duration, hasMaxAge, noCache, noStore bool) {
+	for _, directive := range strings.Split(strings.ToLower(value), ",") {
+		directive = strings.TrimSpace(directive)
+		switch {
+		case directive == "no-cache":
+			noCache = true
+		case directive == "no-store":
+			noStore = true
+		case strings.HasPrefix(directive, "max-age="):
+			if seconds, err := strconv.Atoi(strings.Trim(directive[len("max-age="):], `"`)); err == nil && seconds >= 0 {
+				maxAge = time.Duration(seconds) * time.Second
+				hasMaxAge = true
+			}
+		}
+	}
+	return maxAge, hasMaxAge, noCache, noStore
+}
+
+// noCacheKey marks a resolution context whose reference asked to skip the cache
+type noCacheKey struct{}
+
+// withNoCache makes resolvers fetch fresh content; results are still cached
+func withNoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// noCacheRequested reports whether the reference being resolved set no_cache
+func noCacheRequested(ctx context.Context) bool {
+	noCache, _ := ctx.Value(noCacheKey{}).(bool)
+	return noCache
+}
+
 // ShouldCache determines if content should be cached based on response
-func (cp CachePolicy) ShouldCache(url string, statusCode int, contentLength int64, contentType string) bool {
+func (cp CachePolicy) ShouldCache(url string, statusCode int, contentLength int64, contentType string, cacheControl string) bool {
 	// Don't cache HTTP errors
 	if statusCode >= 400 {
 		return false
 	}
 	
+	// The server asked us not to keep it
+	if _, _, _, noStore := parseCacheControl(cacheControl); noStore {
+		return false
+	}
+	
 	// Don't cache oversized content
 	if contentLength > cp.MaxContentSize {
 		return false
@@ -0,0 +1,182 @@
+package resolution
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncation policies for reference content that exceeds the context budget
+const (
+	TruncateHead         = "head"         // Keep references in order, cutting the ones that overflow
+	TruncateTail         = "tail"         // Keep the last references, cutting earlier ones from the front
+	TruncateProportional = "proportional" // Shrink every reference by its share of the budget
+)
+
+// ContextBudget limits how much prompt and reference content reaches the AI
+type ContextBudget struct {
+	MaxTotalSize     int    // Prompt + reference content in bytes (0 = unlimited)
+	MaxReferenceSize int    // Per-reference cap in bytes (0 = unlimited)
+	Policy           string // head, tail, or proportional
+}
+
+// TruncationRecord describes a reference that was cut to fit the budget
+type TruncationRecord struct {
+	Type         string `json:"type"`
+	Target       string `json:"target"`
+	OriginalSize int    `json:"original_size"`
+	IncludedSize int    `json:"included_size"`
+	Omitted      bool   `json:"omitted,omitempty"`
+}
+
+// DefaultContextBudget returns the historical limits (2KB per reference, 8KB total)
+func DefaultContextBudget() ContextBudget {
+	return ContextBudget{
+		MaxTotalSize:     8 * 1024,
+		MaxReferenceSize: 2000,
+		Policy:           TruncateHead,
+	}
+}
+
+// IsValidTruncationPolicy reports whether policy is a known truncation policy
+func IsValidTruncationPolicy(policy string) bool {
+	switch policy {
+	case TruncateHead, TruncateTail, TruncateProportional:
+		return true
+	}
+	return false
+}
+
+const truncationMarker = "[Content truncated for size]"
+
+// allocate fits reference contents into the budget, returning the content to
+// include for each context (empty means omitted) and what was truncated
+func (b ContextBudget) allocate(contexts []*ResolvedContext, promptSize int) ([]string, []TruncationRecord) {
+	contents := make([]string, len(contexts))
+	originals := make([]int, len(contexts))
+	capped := make([]bool, len(contexts))
+	frontCut := make([]bool, len(contexts))
+
+	// Per-reference cap first
+	for i, ctx := range contexts {
+		contents[i] = ctx.Content
+		originals[i] = len(ctx.Content)
+		if b.MaxReferenceSize > 0 && len(contents[i]) > b.MaxReferenceSize {
+			contents[i] = keepHead(contents[i], b.MaxReferenceSize)
+			capped[i] = true
+		}
+	}
+
+	available := -1 // Unlimited
+	if b.MaxTotalSize > 0 {
+		available = b.MaxTotalSize - promptSize
+		if available < 0 {
+			available = 0
+		}
+	}
+
+	if available >= 0 {
+		total := 0
+		for _, content := range contents {
+			total += len(content)
+		}
+
+		if total > available {
+			switch b.Policy {
+			case TruncateTail:
+				remaining := available
+				for i := len(contents) - 1; i >= 0; i-- {
+					before := len(contents[i])
+					contents[i], remaining = fitTail(contents[i], remaining)
+					frontCut[i] = len(contents[i]) < before
+				}
+			case TruncateProportional:
+				for i, content := range contents {
+					share := int(float64(available) * float64(len(content)) / float64(total))
+					contents[i] = keepHead(content, share)
+				}
+			default:
+				remaining := available
+				for i := range contents {
+					contents[i], remaining = fitHead(contents[i], remaining)
+				}
+			}
+		}
+	}
+
+	// Record and mark anything that lost content
+	var records []TruncationRecord
+	for i, ctx := range contexts {
+		if len(contents[i]) == originals[i] && !capped[i] {
+			continue
+		}
+
+		record := TruncationRecord{
+			Type:         ctx.Type,
+			Target:       ctx.Target,
+			OriginalSize: originals[i],
+			IncludedSize: len(contents[i]),
+			Omitted:      contents[i] == "",
+		}
+		records = append(records, record)
+
+		if record.Omitted {
+			log.Printf("✂️ Reference %s:%s omitted (%d bytes, policy=%s)", ctx.Type, ctx.Target, record.OriginalSize, b.Policy)
+			continue
+		}
+		log.Printf("✂️ Reference %s:%s truncated %d -> %d bytes (policy=%s)", ctx.Type, ctx.Target, record.OriginalSize, record.IncludedSize, b.Policy)
+		if frontCut[i] {
+			contents[i] = truncationMarker + "\n" + contents[i]
+		}
+		if capped[i] || !frontCut[i] {
+			contents[i] = contents[i] + "\n" + truncationMarker
+		}
+	}
+
+	return contents, records
+}
+
+// fitHead keeps as much of the start of content as the remaining budget allows
+func fitHead(content string, remaining int) (string, int) {
+	if len(content) <= remaining {
+		return content, remaining - len(content)
+	}
+	return keepHead(content, remaining), 0
+}
+
+// fitTail keeps as much of the end of content as the remaining budget allows
+func fitTail(content string, remaining int) (string, int) {
+	if len(content) <= remaining {
+		return content, remaining - len(content)
+	}
+	return keepTail(content, remaining), 0
+}
+
+// keepHead returns at most n bytes from the start without splitting a rune
+func keepHead(content string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(content) <= n {
+		return content
+	}
+	for n > 0 && !utf8.RuneStart(content[n]) {
+		n--
+	}
+	return strings.TrimSpace(content[:n])
+}
+
+// keepTail returns at most n bytes from the end without splitting a rune
+func keepTail(content string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(content) <= n {
+		return content
+	}
+	start := len(content) - n
+	for start < len(content) && !utf8.RuneStart(content[start]) {
+		start++
+	}
+	return strings.TrimSpace(content[start:])
+}
@@ -0,0 +1,83 @@
+package resolution
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// GitMaxFileSize is the largest file a git: reference will fetch
+const GitMaxFileSize = 50 * 1024
+
+// GitReference identifies one file in a remote repository, written as
+// host/owner/repo[@ref]:path, e.g. github.com/org/repo@v1.2:src/main.go
+type GitReference struct {
+	Host string // github.com
+	Repo string // org/repo
+	Ref  string // Branch, tag or commit; HEAD when omitted
+	Path string // File path inside the repository
+}
+
+// gitRefPattern limits refs to characters git allows in branch and tag
+// names, so a ref can never be mistaken for a command-line option
+var gitRefPattern = regexp.MustCompile(`^[A-Za-z0-9._/][A-Za-z0-9._/-]*$`)
+
+// ParseGitReference parses the target of a git: reference
+func ParseGitReference(target string) (GitReference, error) {
+	target = strings.TrimSpace(target)
+	target = strings.TrimPrefix(target, "https://")
+
+	repoPart, filePath, ok := strings.Cut(target, ":")
+	if !ok || filePath == "" {
+		return GitReference{}, fmt.Errorf("git reference needs a file path: host/owner/repo[@ref]:path")
+	}
+
+	ref := "HEAD"
+	if at := strings.LastIndex(repoPart, "@"); at >= 0 {
+		ref = repoPart[at+1:]
+		repoPart = repoPart[:at]
+	}
+	if !gitRefPattern.MatchString(ref) || strings.Contains(ref, "..") {
+		return GitReference{}, fmt.Errorf("invalid git ref: %q", ref)
+	}
+
+	host, repo, ok := strings.Cut(strings.TrimSuffix(repoPart, ".git"), "/")
+	if !ok || host == "" || !strings.Contains(host, ".") || repo == "" {
+		return GitReference{}, fmt.Errorf("git reference needs a host and repository: %q", repoPart)
+	}
+	repo = strings.Trim(repo, "/")
+	if strings.Contains(repo, "..") || !strings.Contains(repo, "/") {
+		return GitReference{}, fmt.Errorf("invalid repository: %q", repo)
+	}
+
+	// Paths are relative to the repository root and can't climb out of it
+	cleanPath := path.Clean("/" + filePath)[1:]
+	if cleanPath == "" {
+		return GitReference{}, fmt.Errorf("invalid file path: %q", filePath)
+	}
+
+	return GitReference{
+		Host: strings.ToLower(host),
+		Repo: repo,
+		Ref:  ref,
+		Path: cleanPath,
+	}, nil
+}
+
+// RemoteURL is the HTTPS clone URL of the repository
+func (r GitReference) RemoteURL() string {
+	return fmt.Sprintf("https://%s/%s.git", r.Host, r.Repo)
+}
+
+// String formats the reference the way ParseGitReference reads it
+func (r GitReference) String() string {
+	return fmt.Sprintf("%s/%s@%s:%s", r.Host, r.Repo, r.Ref, r.Path)
+}
+
+// ArtifactID is the deterministic relation ID that caches this file
+func (r GitReference) ArtifactID() string {
+	hash := sha256.Sum256([]byte(r.String()))
+	return fmt.Sprintf("git-artifact-%x", hash[:8])
+}
@@ -1,6 +1,9 @@
 package resolution
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // ResolutionService provides the public interface for reference resolution
 type ResolutionService interface {
@@ -8,6 +11,11 @@ type ResolutionService interface {
 	// Returns formatted string, resolved contexts, and error
 	ResolveForAI(references []Reference) (string, []*ResolvedContext, error)
 	
+	// ResolveForAIWithBudget resolves references and fits them into a context budget
+	// alongside a prompt of promptSize bytes. Returns formatted string, resolved
+	// contexts, the references that were truncated, and error
+	ResolveForAIWithBudget(references []Reference, budget ContextBudget, promptSize int) (string, []*ResolvedContext, []TruncationRecord, error)
+	
 	// GetResolutionStats returns statistics about resolution process (DEPRECATED)
 	GetResolutionStats(references []Reference) (*Stats, error)
 	
@@ -20,6 +28,7 @@ type Reference struct {
 	Type    string `json:"type"`
 	Target  string `json:"target"`
 	Context string `json:"context,omitempty"`
+	NoCache bool   `json:"no_cache,omitempty"` // Fetch fresh instead of using a cached artifact
 }
 
 // Stats provides resolution statistics
@@ -48,6 +57,11 @@ type Handlers struct {
 	FileHandler      func(path string) (*FileContent, error)
 	P42Handler       func(p42Path string) (*FileContent, error) // Port 42 VFS access
 	RelationsHandler func() RelationsManager // NEW: For URL artifact Relations
+	GitHandler       func(ctx context.Context, ref GitReference, maxSize int64) (*FileContent, error) // Single file from a remote repository
+	
+	// URLCacheTTL is how long fetched URLs stay fresh without a Cache-Control
+	// max-age; zero keeps DefaultCachePolicy's TTL
+	URLCacheTTL time.Duration
 }
 
 // Data types for handlers (self-contained in this package)
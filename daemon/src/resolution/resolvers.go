@@ -172,14 +172,13 @@ func (r *urlResolver) resolve(ctx context.Context, target string) (*ResolvedCont
 	// Generate artifact ID (deterministic for caching)
 	artifactID := NewURLArtifactID(target).Generate()
 	
-	// DEBUG: For now, force fresh artifacts to test cache behavior
-	// TODO: Remove this and fix Relations update behavior
-	if r.artifactManager != nil {
-		log.Printf("🔍 DEBUG: Checking cache for %s", artifactID)
-	}
-	
 	// Phase 3: Enhanced Resolution Flow - Cache-first with proper fallback logic
 	if r.artifactManager != nil {
+		if noCacheRequested(ctx) {
+			log.Printf("🌐 URL cache BYPASS: %s (no_cache)", target)
+			return r.fetchAndStore(ctx, target, artifactID, nil)
+		}
+		
 		// Try cache first  
 		if cached, err := r.artifactManager.LoadCached(artifactID); err == nil && cached != nil {
 			// Cache hit - successful cache-first resolution
@@ -193,9 +192,16 @@ func (r *urlResolver) resolve(ctx context.Context, target string) (*ResolvedCont
 			}, nil
 		}
 		
+		// Expired entries with an ETag or Last-Modified are revalidated
+		// rather than refetched
+		if stale := r.artifactManager.LoadStale(artifactID); stale != nil {
+			log.Printf("🔄 URL cache STALE: %s -> revalidating", target)
+			return r.fetchAndStore(ctx, target, artifactID, stale)
+		}
+		
 		// Cache miss - proceed to fetch with caching enabled
 		log.Printf("🌐 URL cache MISS: %s -> fetching fresh (will cache)", target)
-		return r.fetchAndStore(ctx, target, artifactID)
+		return r.fetchAndStore(ctx, target, artifactID, nil)
 	} else {
 		// No cache manager - direct fetch without caching
 		log.Printf("🌐 URL direct fetch: %s (no cache available)", target)
@@ -203,8 +209,9 @@ func (r *urlResolver) resolve(ctx context.Context, target string) (*ResolvedCont
 	}
 }
 
-// fetchAndStore fetches URL content and stores as artifact if possible
-func (r *urlResolver) fetchAndStore(ctx context.Context, target, artifactID string) (*ResolvedContext, error) {
+// fetchAndStore fetches URL content and stores as artifact if possible. With
+// a stale artifact the request is conditional, and a 304 reuses its content.
+func (r *urlResolver) fetchAndStore(ctx context.Context, target, artifactID string, stale *URLArtifactRelation) (*ResolvedContext, error) {
 	client := &http.Client{Timeout: 8 * time.Second}
 	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
 	if err != nil {
@@ -217,6 +224,14 @@ func (r *urlResolver) fetchAndStore(ctx context.Context, target, artifactID stri
 	}
 	
 	req.Header.Set("User-Agent", "Port42-ReferenceResolver/1.0")
+	if stale != nil {
+		if etag, _ := stale.Properties["etag"].(string); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified, _ := stale.Properties["last_modified"].(string); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
 	
 	resp, err := client.Do(req)
 	if err != nil {
@@ -229,6 +244,10 @@ func (r *urlResolver) fetchAndStore(ctx context.Context, target, artifactID stri
 	}
 	defer resp.Body.Close()
 	
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		return r.storeRevalidated(target, stale, resp), nil
+	}
+	
 	if resp.StatusCode >= 400 {
 		return &ResolvedContext{
 			Type:    "url",
@@ -272,7 +291,10 @@ func (r *urlResolver) fetchAndStore(ctx context.Context, target, artifactID stri
 				"status_code":    resp.StatusCode,
 				"content_length": len(content),
 				"fetched_at":     freshTimestamp, // Always current time
-				"cache_version":  3,              // Increment for timestamp fix
+				"etag":           resp.Header.Get("ETag"),
+				"last_modified":  resp.Header.Get("Last-Modified"),
+				"cache_control":  resp.Header.Get("Cache-Control"),
+				"cache_version":  4,              // Increment for revalidation fields
 				"last_updated":   freshTimestamp, // Always current time
 				"debug_fetched":  now.Format("2006-01-02 15:04:05"), // Human readable debug
 			},
@@ -293,10 +315,44 @@ func (r *urlResolver) fetchAndStore(ctx context.Context, target, artifactID stri
 	}, nil
 }
 
+// storeRevalidated refreshes a stale artifact after the server answered 304
+// Not Modified, and returns its cached content
+func (r *urlResolver) storeRevalidated(target string, stale *URLArtifactRelation, resp *http.Response) *ResolvedContext {
+	now := time.Now()
+	stale.Properties["fetched_at"] = now.Unix()
+	stale.Properties["last_updated"] = now.Unix()
+	stale.Properties["debug_fetched"] = now.Format("2006-01-02 15:04:05")
+	// A 304 may carry updated validators and freshness
+	for property, header := range map[string]string{"etag": "ETag", "last_modified": "Last-Modified", "cache_control": "Cache-Control"} {
+		if value := resp.Header.Get(header); value != "" {
+			stale.Properties[property] = value
+		}
+	}
+	stale.UpdatedAt = now
+	log.Printf("✅ URL not modified: %s (reusing cached content)", target)
+	
+	// Store artifact (errors are logged but don't fail resolution)
+	r.artifactManager.Store(stale)
+	
+	contentType, _ := stale.Properties["content_type"].(string)
+	formattedContent := formatURLContent(stale.Content, contentType, target)
+	formattedContent += "\n[Revalidated - not modified]"
+	
+	return &ResolvedContext{
+		Type:    "url",
+		Target:  target,
+		Content: formattedContent,
+		Success: true,
+	}
+}
+
 // formatCachedURLContent formats cached URL content with cache indicator
 func (r *urlResolver) formatCachedURLContent(content string, properties map[string]interface{}, url string) string {
 	contentType, _ := properties["content_type"].(string)
 	fetchedAt, _ := properties["fetched_at"].(int64)
+	if fetchedFloat, ok := properties["fetched_at"].(float64); ok {
+		fetchedAt = int64(fetchedFloat) // Relations loaded from JSON
+	}
 	
 	formattedContent := formatURLContent(content, contentType, url)
 	
@@ -374,6 +430,95 @@ func (r *urlResolver) getTimeout() time.Duration {
 	return 10 * time.Second
 }
 
+// gitResolver fetches single files from remote repositories, caching them
+// as GitArtifact relations the same way urlResolver caches pages
+type gitResolver struct {
+	handler         func(ctx context.Context, ref GitReference, maxSize int64) (*FileContent, error)
+	artifactManager *ArtifactManager
+}
+
+func (r *gitResolver) resolve(ctx context.Context, target string) (*ResolvedContext, error) {
+	ref, err := ParseGitReference(target)
+	if err != nil {
+		return &ResolvedContext{
+			Type:    "git",
+			Target:  target,
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	
+	artifactID := ref.ArtifactID()
+	if r.artifactManager != nil && !noCacheRequested(ctx) {
+		if cached, err := r.artifactManager.LoadCached(artifactID); err == nil && cached != nil {
+			log.Printf("🎯 Git cache HIT: %s -> %s", ref, artifactID)
+			commit, _ := cached.Properties["commit"].(string)
+			file := &FileContent{
+				Path:    ref.String(),
+				Content: cached.Content,
+				Size:    int64(len(cached.Content)),
+				Type:    "git",
+				Metadata: map[string]interface{}{
+					"commit": commit,
+				},
+			}
+			return &ResolvedContext{
+				Type:    "git",
+				Target:  target,
+				Content: formatGitContent(file) + "\n[From cache]",
+				Success: true,
+			}, nil
+		}
+	}
+	
+	log.Printf("🌐 Git fetch: %s from %s", ref.Path, ref.RemoteURL())
+	file, err := r.handler(ctx, ref, GitMaxFileSize)
+	if err != nil {
+		return &ResolvedContext{
+			Type:    "git",
+			Target:  target,
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	
+	if r.artifactManager != nil {
+		now := time.Now()
+		commit, _ := file.Metadata["commit"].(string)
+		artifact := &URLArtifactRelation{
+			ID:        artifactID,
+			Type:      "GitArtifact",
+			Content:   file.Content,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Properties: map[string]interface{}{
+				"source":         "git:" + ref.String(),
+				"repository":     ref.RemoteURL(),
+				"ref":            ref.Ref,
+				"path":           ref.Path,
+				"commit":         commit,
+				"content_length": len(file.Content),
+				"fetched_at":     now.Unix(),
+				"last_updated":   now.Unix(),
+			},
+		}
+		
+		// Store artifact (errors are logged but don't fail resolution)
+		r.artifactManager.Store(artifact)
+	}
+	
+	return &ResolvedContext{
+		Type:    "git",
+		Target:  target,
+		Content: formatGitContent(file) + "\n[Freshly fetched]",
+		Success: true,
+	}, nil
+}
+
+func (r *gitResolver) getTimeout() time.Duration {
+	return 20 * time.Second // Fetch negotiation plus a shallow pack
+}
+
 // Formatting functions
 func formatSearchResults(query string, results []SearchResult) string {
 	if len(results) == 0 {
@@ -504,6 +649,11 @@ func formatToolDefinition(tool *ToolDefinition) string {
 
 
 func formatFileContent(file *FileContent) string {
+	// Globs and directories carry a manifest of the files they combined
+	if files, ok := file.Metadata["files"].([]string); ok {
+		return formatFileSetContent(file, files)
+	}
+	
 	var parts []string
 	parts = append(parts, fmt.Sprintf("Local File: %s", file.Path))
 	parts = append(parts, fmt.Sprintf("Type: %s", file.Type))
@@ -526,6 +676,30 @@ func formatFileContent(file *FileContent) string {
 	return strings.Join(parts, "\n")
 }
 
+// formatFileSetContent formats the files collected for a glob or directory
+// reference. The context budget trims it further before it reaches the AI.
+func formatFileSetContent(file *FileContent, files []string) string {
+	var parts []string
+	parts = append(parts, fmt.Sprintf("Local Files: %s", file.Path))
+	parts = append(parts, fmt.Sprintf("Size: %d bytes in %d files", file.Size, len(files)))
+	parts = append(parts, "Included:")
+	for _, path := range files {
+		parts = append(parts, "  "+path)
+	}
+	if skipped, ok := file.Metadata["skipped"].([]map[string]interface{}); ok && len(skipped) > 0 {
+		parts = append(parts, fmt.Sprintf("Skipped: %d files", len(skipped)))
+	}
+	
+	content := strings.TrimSpace(file.Content)
+	if len(content) > SearchContentLimit {
+		content = content[:SearchContentLimit] + fmt.Sprintf("\n[Content truncated - showing first %d chars]", SearchContentLimit)
+	}
+	
+	parts = append(parts, fmt.Sprintf("Content:\n%s", content))
+	
+	return strings.Join(parts, "\n")
+}
+
 // formatP42Content formats Port 42 VFS content
 func formatP42Content(file *FileContent) string {
 	var parts []string
@@ -559,6 +733,25 @@ func formatP42Content(file *FileContent) string {
 	return strings.Join(parts, "\n")
 }
 
+// formatGitContent formats a file fetched from a remote repository
+func formatGitContent(file *FileContent) string {
+	var parts []string
+	parts = append(parts, fmt.Sprintf("Git File: %s", file.Path))
+	if commit, ok := file.Metadata["commit"].(string); ok && commit != "" {
+		parts = append(parts, fmt.Sprintf("Commit: %s", commit))
+	}
+	parts = append(parts, fmt.Sprintf("Size: %d bytes", file.Size))
+	
+	content := strings.TrimSpace(file.Content)
+	if len(content) > 1000 {
+		content = content[:1000] + "\n[Content truncated - showing first 1000 chars]"
+	}
+	
+	parts = append(parts, fmt.Sprintf("Content:\n%s", content))
+	
+	return strings.Join(parts, "\n")
+}
+
 func formatURLContent(body, contentType, url string) string {
 	var parts []string
 	parts = append(parts, fmt.Sprintf("URL: %s", url))
@@ -47,11 +47,21 @@ func newService(handlers Handlers) *service {
 	if handlers.RelationsHandler != nil {
 		relations = handlers.RelationsHandler()
 	}
-	artifactManager := NewArtifactManager(relations)
+	policy := DefaultCachePolicy()
+	if handlers.URLCacheTTL > 0 {
+		policy.DefaultTTL = handlers.URLCacheTTL
+	}
+	artifactManager := NewArtifactManager(relations, policy)
 	s.resolvers["url"] = &urlResolver{
 		relations:       relations,
 		artifactManager: artifactManager,
 	}
+	if handlers.GitHandler != nil {
+		s.resolvers["git"] = &gitResolver{
+			handler:         handlers.GitHandler,
+			artifactManager: artifactManager,
+		}
+	}
 	
 	log.Printf("🔗 Resolution service initialized with %d resolvers", len(s.resolvers))
 	return s
@@ -63,15 +73,25 @@ func (s *service) ResolveForAI(references []Reference) (string, []*ResolvedConte
 		return "", nil, nil
 	}
 	
+	formatted, contexts, _, err := s.ResolveForAIWithBudget(references, DefaultContextBudget(), 0)
+	return formatted, contexts, err
+}
+
+// ResolveForAIWithBudget resolves references and formats them within a context budget
+func (s *service) ResolveForAIWithBudget(references []Reference, budget ContextBudget, promptSize int) (string, []*ResolvedContext, []TruncationRecord, error) {
+	if len(references) == 0 {
+		return "", nil, nil, nil
+	}
+	
 	log.Printf("🔍 Resolving %d references for AI", len(references))
 	
 	// Resolve all references
 	contexts := s.resolveAll(references)
 	
 	// Format for AI consumption
-	formatted := s.formatForAI(contexts)
+	formatted, truncations := s.formatForAI(contexts, budget, promptSize)
 	
-	return formatted, contexts, nil
+	return formatted, contexts, truncations, nil
 }
 
 // GetResolutionStats returns resolution statistics (DEPRECATED: use ComputeStatsFromContexts)
@@ -126,6 +146,9 @@ func (s *service) resolveAll(references []Reference) []*ResolvedContext {
 		
 		// Create timeout context
 		ctx, cancel := context.WithTimeout(context.Background(), resolver.getTimeout())
+		if ref.NoCache {
+			ctx = withNoCache(ctx)
+		}
 		
 		resolved, err := resolver.resolve(ctx, ref.Target)
 		cancel()
@@ -145,8 +168,8 @@ func (s *service) resolveAll(references []Reference) []*ResolvedContext {
 	return results
 }
 
-// formatForAI formats resolved contexts for AI consumption
-func (s *service) formatForAI(contexts []*ResolvedContext) string {
+// formatForAI formats resolved contexts for AI consumption within the budget
+func (s *service) formatForAI(contexts []*ResolvedContext, budget ContextBudget, promptSize int) (string, []TruncationRecord) {
 	var parts []string
 	
 	// Only include successful resolutions
@@ -158,44 +181,40 @@ func (s *service) formatForAI(contexts []*ResolvedContext) string {
 	}
 	
 	if len(successful) == 0 {
-		return ""
+		return "", nil
 	}
 	
 	parts = append(parts, "CONTEXTUAL INFORMATION:")
 	
-	// Apply simple size limiting (8KB total)
-	totalSize := 0
-	maxSize := 8 * 1024
+	// Fit reference content into the budget
+	contents, truncations := budget.allocate(successful, promptSize)
 	
-	for _, ctx := range successful {
-		content := ctx.Content
-		
-		// Limit individual context size
-		if len(content) > 2000 {
-			content = content[:2000] + "\n[Content truncated for size]"
-		}
-		
-		if totalSize+len(content) > maxSize {
-			parts = append(parts, "\n[Additional references omitted due to size limit]")
-			break
+	omitted := 0
+	for i, ctx := range successful {
+		if contents[i] == "" {
+			omitted++
+			continue
 		}
 		
 		contextBlock := fmt.Sprintf("\n%s Reference (%s):\n%s\n", 
-			strings.Title(ctx.Type), ctx.Target, content)
+			strings.Title(ctx.Type), ctx.Target, contents[i])
 		
 		parts = append(parts, contextBlock)
-		totalSize += len(contextBlock)
+	}
+	
+	if omitted > 0 {
+		parts = append(parts, fmt.Sprintf("\n[%d additional references omitted due to size limit]", omitted))
 	}
 	
 	if len(parts) == 1 {
-		return "" // Only header
+		return "", truncations // Only header
 	}
 	
 	parts = append(parts, "\nUse this contextual information to generate more relevant tools.\n")
 	
 	result := strings.Join(parts, "")
-	log.Printf("✨ AI context formatted: %d chars from %d successful references", 
-		len(result), len(successful))
+	log.Printf("✨ AI context formatted: %d chars from %d successful references (%d truncated)", 
+		len(result), len(successful), len(truncations))
 	
-	return result
+	return result, truncations
 }
\ No newline at end of file
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMaxResponseSize caps an encoded response (PORT42_MAX_RESPONSE_SIZE, bytes, 0 for no limit)
+const defaultMaxResponseSize = 8 * 1024 * 1024
+
+// responseFlagReserve is room kept below the limit for the truncation flags
+const responseFlagReserve = 1024
+
+// maxTruncationPasses bounds how many fields are cut before giving up
+const maxTruncationPasses = 64
+
+// limitResponse keeps an oversized response under maxSize by cutting its
+// heaviest fields, the longest strings and longest lists, until it fits.
+// Object data gains "truncated": true, the JSON paths that were cut under
+// "truncated_fields", and a "truncation_hint" on how to get the rest.
+// Data that isn't an object, or that can't be cut small enough, becomes an
+// error response, so clients always receive well-formed JSON.
+func limitResponse(req Request, resp Response, maxSize int) Response {
+	if maxSize <= 0 || len(resp.Data) == 0 {
+		return resp
+	}
+	encoded, err := json.Marshal(resp)
+	if err != nil || len(encoded) <= maxSize {
+		return resp
+	}
+	originalSize := len(encoded)
+
+	tooLarge := func() Response {
+		log.Printf("⚠️ Response [%s] for %s is %d bytes and could not be cut under %d", req.ID, req.Type, originalSize, maxSize)
+		return NewErrorResponse(req.ID, fmt.Sprintf("RESPONSE_TOO_LARGE: response is %d bytes, over the %d byte limit. %s",
+			originalSize, maxSize, truncationHint(req)))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(resp.Data))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return tooLarge()
+	}
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return tooLarge()
+	}
+
+	// Everything but Data counts against the budget too
+	budget := maxSize - (len(encoded) - len(resp.Data)) - responseFlagReserve
+	cut := []string{}
+	for pass := 0; pass < maxTruncationPasses; pass++ {
+		size := encodedSize(object)
+		if size <= budget {
+			break
+		}
+		field := heaviestField(object)
+		if field == nil || !field.truncate(size-budget) {
+			return tooLarge()
+		}
+		if len(cut) == 0 || cut[len(cut)-1] != field.path {
+			cut = append(cut, field.path)
+		}
+	}
+	if encodedSize(object) > budget {
+		return tooLarge()
+	}
+
+	object["truncated"] = true
+	object["truncated_fields"] = cut
+	object["truncation_hint"] = truncationHint(req)
+	if err := resp.SetData(object); err != nil {
+		return tooLarge()
+	}
+	log.Printf("✂️ Response [%s] for %s truncated from %d bytes to under %d (%s)",
+		req.ID, req.Type, originalSize, maxSize, strings.Join(cut, ", "))
+	return resp
+}
+
+// truncationHint says how a client can fetch what a truncated response left out
+func truncationHint(req Request) string {
+	switch req.Type {
+	case "search":
+		return "Page through results with filters.limit and filters.offset, or raise PORT42_MAX_RESPONSE_SIZE"
+	case RequestMemory:
+		var payload struct {
+			SessionID string `json:"session_id"`
+		}
+		json.Unmarshal(req.Payload, &payload)
+		if payload.SessionID == "" {
+			return "Fetch sessions one at a time with session_id, or list one agent's with agent"
+		}
+		return "Raise PORT42_MAX_RESPONSE_SIZE to receive the whole transcript"
+	case "read_path", "read_object":
+		return "Raise PORT42_MAX_RESPONSE_SIZE to read the whole object"
+	case "read_paths":
+		return "Read fewer paths per request, or raise PORT42_MAX_RESPONSE_SIZE"
+	case "list_path", "list_relations":
+		return "List a narrower path, or raise PORT42_MAX_RESPONSE_SIZE"
+	default:
+		return "Fetch individual objects with read_path, or raise PORT42_MAX_RESPONSE_SIZE"
+	}
+}
+
+// encodedSize is the JSON length of value
+func encodedSize(value interface{}) int {
+	encoded, _ := json.Marshal(value)
+	return len(encoded)
+}
+
+// responseField is a string or list inside a response, and how to replace it
+type responseField struct {
+	path  string
+	size  int
+	value interface{}
+	set   func(interface{})
+}
+
+// heaviestField finds the longest string or list in object. Lists are
+// weighed whole, so a list of many small entries is shortened before any
+// of its entries are cut.
+func heaviestField(object map[string]interface{}) *responseField {
+	var heaviest *responseField
+	var weigh func(value interface{}, path string, set func(interface{})) int
+	weigh = func(value interface{}, path string, set func(interface{})) int {
+		size := 0
+		switch v := value.(type) {
+		case string:
+			size = encodedSize(v)
+		case map[string]interface{}:
+			size = 2
+			for key, child := range v {
+				// The flags added by a previous pass aren't content
+				if path == "" && (key == "truncated_fields" || key == "truncation_hint") {
+					continue
+				}
+				childPath := key
+				if path != "" {
+					childPath = path + "." + key
+				}
+				size += len(key) + 4 + weigh(child, childPath, func(replacement interface{}) { v[key] = replacement })
+			}
+			return size
+		case []interface{}:
+			size = 2
+			for i, child := range v {
+				size += 1 + weigh(child, fmt.Sprintf("%s[%d]", path, i), func(replacement interface{}) { v[i] = replacement })
+			}
+		default:
+			return encodedSize(v)
+		}
+		// A list with one entry can only shrink by cutting inside it
+		if list, ok := value.([]interface{}); ok && len(list) < 2 {
+			return size
+		}
+		if heaviest == nil || size > heaviest.size {
+			heaviest = &responseField{path: path, size: size, value: value, set: set}
+		}
+		return size
+	}
+	weigh(object, "", nil)
+	return heaviest
+}
+
+// truncate shortens the field by at least excess bytes, or empties it.
+// Reports whether anything was cut.
+func (f *responseField) truncate(excess int) bool {
+	switch v := f.value.(type) {
+	case string:
+		if v == "" {
+			return false
+		}
+		// Multiples of 4 keep base64 content decodable
+		keep := max(len(v)-excess, 0) / 4 * 4
+		for keep > 0 && !utf8.RuneStart(v[keep]) {
+			keep--
+		}
+		f.set(v[:keep])
+	case []interface{}:
+		if len(v) == 0 {
+			return false
+		}
+		average := max(f.size/len(v), 1)
+		drop := min(excess/average+1, len(v))
+		f.set(v[:len(v)-drop])
+	default:
+		return false
+	}
+	return true
+}
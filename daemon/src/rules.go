@@ -21,6 +21,7 @@ type Rule struct {
 type RuleEngine struct {
 	rules    []Rule
 	compiler *RealityCompiler
+	events   *EventBus // Rule activity for watch (nil if nobody is listening)
 }
 
 // NewRuleEngine creates a new rule engine with the given rules
@@ -33,7 +34,7 @@ func NewRuleEngine(compiler *RealityCompiler, rules []Rule) *RuleEngine {
 
 // ProcessRelation evaluates all enabled rules against a relation and executes matching ones
 func (re *RuleEngine) ProcessRelation(relation Relation) ([]string, error) {
-	log.Printf("🔍 Processing relation %s through %d rules", relation.ID, len(re.rules))
+	logger.Debugf("🔍 Processing relation %s through %d rules", relation.ID, len(re.rules))
 	
 	var spawnedIDs []string
 	var errors []string
@@ -46,6 +47,7 @@ func (re *RuleEngine) ProcessRelation(relation Relation) ([]string, error) {
 		// Check if rule condition matches
 		if rule.Condition(relation) {
 			log.Printf("🌱 Rule '%s' matched relation %s", rule.Name, relation.ID)
+			re.events.publishRule(rule, relation, "rule_triggered", "")
 			
 			// Execute rule action
 			err := rule.Action(relation, re.compiler)
@@ -53,8 +55,10 @@ func (re *RuleEngine) ProcessRelation(relation Relation) ([]string, error) {
 				errorMsg := fmt.Sprintf("Rule '%s' failed: %v", rule.Name, err)
 				log.Printf("❌ %s", errorMsg)
 				errors = append(errors, errorMsg)
+				re.events.publishRule(rule, relation, "rule_failed", err.Error())
 			} else {
 				log.Printf("✅ Rule '%s' executed successfully", rule.Name)
+				re.events.publishRule(rule, relation, "rule_completed", "")
 				// Note: We don't track spawned IDs yet, but rule actions can spawn relations
 				// This will be enhanced in Phase 2
 			}
@@ -112,18 +116,12 @@ func hasTransform(relation Relation, transform string) bool {
 	return contains(transforms, transform)
 }
 
-// getTransforms extracts transforms array from relation properties
+// getTransforms extracts transforms array from relation properties. Stored
+// relations already hold []string (see normalizeRelation); relations still
+// being declared may not.
 func getTransforms(relation Relation) []string {
 	if transformsRaw, exists := relation.Properties["transforms"]; exists {
-		if transformsList, ok := transformsRaw.([]interface{}); ok {
-			var transforms []string
-			for _, t := range transformsList {
-				if tStr, ok := t.(string); ok {
-					transforms = append(transforms, tStr)
-				}
-			}
-			return transforms
-		}
+		return canonicalStringList(transformsRaw)
 	}
 	return []string{}
 }
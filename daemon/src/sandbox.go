@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Sandbox profiles a Tool relation can ask to be run under, in its
+// sandbox_profile property. They apply when the daemon runs the tool, as
+// run_command does for AI sessions; running it from a shell is unchanged.
+const (
+	SandboxNone       = "none"       // The user's environment, the default
+	SandboxRestricted = "restricted" // Allowlisted commands, a throwaway working directory, the network hook
+)
+
+// defaultSandboxAllow is what a restricted tool may run by name when
+// PORT42_SANDBOX_ALLOW isn't set
+var defaultSandboxAllow = []string{
+	"sh", "bash", "env", "cat", "echo", "printf", "ls", "grep", "head", "tail",
+	"wc", "sort", "uniq", "cut", "tr", "sed", "awk", "date", "basename",
+	"dirname", "mkdir", "touch", "find", "xargs", "tee", "diff", "test", "true",
+	"false", "jq", "python3", "node",
+}
+
+// SandboxConfig is how the restricted profile is set up
+type SandboxConfig struct {
+	// Commands on the restricted PATH (PORT42_SANDBOX_ALLOW, comma separated)
+	Allow []string
+	// Prefix that runs a command without network access, e.g.
+	// "unshare -rn" (PORT42_SANDBOX_NETWORK_HOOK); none when empty
+	NetworkHook []string
+}
+
+// loadSandboxConfig reads the sandbox settings from the environment
+func loadSandboxConfig() SandboxConfig {
+	config := SandboxConfig{Allow: defaultSandboxAllow}
+	if allow := envString("PORT42_SANDBOX_ALLOW", ""); allow != "" {
+		config.Allow = nil
+		for _, name := range strings.Split(allow, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				config.Allow = append(config.Allow, name)
+			}
+		}
+	}
+	config.NetworkHook = strings.Fields(envString("PORT42_SANDBOX_NETWORK_HOOK", ""))
+	return config
+}
+
+// toolSandboxProfile is the profile a tool's relation asks for
+func toolSandboxProfile(relation Relation) string {
+	if profile := getStringProperty(relation.Properties, "sandbox_profile"); profile != "" {
+		return profile
+	}
+	return SandboxNone
+}
+
+// sandboxCommand builds the command that runs cmdPath under profile. The
+// returned cleanup removes whatever the sandbox created and must be called
+// once the command has finished.
+//
+// A restricted command starts in an empty temporary directory that is also
+// its HOME and TMPDIR, with a PATH holding only the allowlisted commands,
+// and runs under the network hook when one is configured. The allowlist
+// only covers commands run by name, so this limits mistakes in generated
+// code rather than containing a hostile one.
+func (c SandboxConfig) sandboxCommand(ctx context.Context, profile, cmdPath string, args []string) (*exec.Cmd, func(), error) {
+	switch profile {
+	case "", SandboxNone:
+		cmd := exec.CommandContext(ctx, cmdPath, args...)
+		cmd.Env = os.Environ()
+		return cmd, func() {}, nil
+	case SandboxRestricted:
+	default:
+		return nil, nil, fmt.Errorf("unknown sandbox profile: %s", profile)
+	}
+
+	root, err := os.MkdirTemp("", "port42-sandbox-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(root) }
+
+	bin := filepath.Join(root, "bin")
+	work := filepath.Join(root, "work")
+	for _, dir := range []string{bin, work} {
+		if err := os.Mkdir(dir, 0700); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to create sandbox: %w", err)
+		}
+	}
+	for _, name := range c.Allow {
+		if strings.Contains(name, "/") {
+			continue
+		}
+		if target, err := exec.LookPath(name); err == nil {
+			os.Symlink(target, filepath.Join(bin, name))
+		}
+	}
+
+	// The hook is found on the daemon's PATH, before the tool's is set
+	argv := append(append([]string{}, c.NetworkHook...), cmdPath)
+	argv = append(argv, args...)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = work
+	cmd.Env = []string{
+		"PATH=" + bin,
+		"HOME=" + work,
+		"TMPDIR=" + work,
+		"PORT42_SANDBOX=" + SandboxRestricted,
+	}
+	for _, name := range []string{"LANG", "LC_ALL", "TERM", "TZ"} {
+		if value, ok := os.LookupEnv(name); ok {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+	return cmd, cleanup, nil
+}
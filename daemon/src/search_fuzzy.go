@@ -0,0 +1,201 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchModeFuzzy matches query terms against field tokens by edit distance,
+// so typos like "vided-splicer" still find "video-splicer"
+const SearchModeFuzzy = "fuzzy"
+
+// defaultFuzzyThreshold is the most edits a term may be from a token when
+// SearchFilters.FuzzyThreshold is unset
+const defaultFuzzyThreshold = 2
+
+// fuzzyQuery is a fuzzy search prepared once per request
+type fuzzyQuery struct {
+	terms     []string
+	threshold int
+}
+
+// newFuzzyQuery splits the query into terms. threshold caps the edit
+// distance; 0 or less uses the default.
+func newFuzzyQuery(queryLower string, threshold int) *fuzzyQuery {
+	if threshold <= 0 {
+		threshold = defaultFuzzyThreshold
+	}
+	return &fuzzyQuery{terms: strings.Fields(queryLower), threshold: threshold}
+}
+
+// maxDistance is how many edits a term may need. Short terms get fewer so
+// "cat" doesn't match every three-letter word.
+func (q *fuzzyQuery) maxDistance(term string) int {
+	allowed := len([]rune(term)) / 4
+	if allowed > q.threshold {
+		allowed = q.threshold
+	}
+	return allowed
+}
+
+// fuzzyToken is a word in a field and where it sits in the original text
+type fuzzyToken struct {
+	text       string
+	start, end int
+}
+
+// fuzzyTokens splits text into lowercase words. Hyphenated and underscored
+// names are kept whole as well as split, so a query can match either.
+func fuzzyTokens(text string) []fuzzyToken {
+	var tokens []fuzzyToken
+	emit := func(isSep func(rune) bool) {
+		start := -1
+		for i, r := range text + " " {
+			if isSep(r) {
+				if start >= 0 {
+					tokens = append(tokens, fuzzyToken{strings.ToLower(text[start:i]), start, i})
+					start = -1
+				}
+				continue
+			}
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	wordSep := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	emit(wordSep)
+	emit(func(r rune) bool { return r != '-' && r != '_' && wordSep(r) })
+	return tokens
+}
+
+// editDistance is the optimal string alignment distance between a and b
+// (insertions, deletions, substitutions and adjacent transpositions), giving
+// up once it must exceed limit
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > limit || -diff > limit {
+		return limit + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
+
+// fieldScore scores text the way OR mode does: weight times the share of
+// terms matched, except each term counts for less the more edits it needed.
+// Returns the first matched token for the snippet.
+func (q *fuzzyQuery) fieldScore(text string, weight float64) (float64, *fuzzyToken) {
+	if text == "" || len(q.terms) == 0 {
+		return 0, nil
+	}
+	tokens := fuzzyTokens(text)
+
+	total := 0.0
+	var first *fuzzyToken
+	for _, term := range q.terms {
+		limit := q.maxDistance(term)
+		best := limit + 1
+		var bestToken *fuzzyToken
+		for i := range tokens {
+			if d := editDistance(term, tokens[i].text, limit); d < best {
+				best, bestToken = d, &tokens[i]
+				if d == 0 {
+					break
+				}
+			}
+		}
+		if bestToken == nil {
+			continue
+		}
+		total += 1 - float64(best)/float64(limit+1)
+		if first == nil {
+			first = bestToken
+		}
+	}
+	if first == nil {
+		return 0, nil
+	}
+	return weight * total / float64(len(q.terms)), first
+}
+
+// searchMetadataFuzzy is the fuzzy branch of searchInMetadata, using the same field weights
+func searchMetadataFuzzy(metadata *Metadata, q *fuzzyQuery) (float64, []string, string) {
+	score := 0.0
+	matchFields := []string{}
+	snippet := ""
+
+	fields := []struct {
+		name   string
+		text   string
+		weight float64
+	}{
+		{"description", metadata.Description, 3.0},
+		{"title", metadata.Title, 2.5},
+		{"tags", strings.Join(metadata.Tags, ", "), 2.0},
+	}
+
+	for _, field := range fields {
+		fieldScore, token := q.fieldScore(field.text, field.weight)
+		if fieldScore == 0 {
+			continue
+		}
+		score += fieldScore
+		matchFields = append(matchFields, field.name)
+		if snippet == "" {
+			snippet = extractSnippetAt(field.text, token.start, token.end)
+		}
+	}
+
+	return score * recencyBoost(metadata.Created), matchFields, snippet
+}
+
+// scoreRelationFuzzy is the fuzzy branch of scoreRelation, using the same field weights
+func scoreRelationFuzzy(relation Relation, q *fuzzyQuery) (float64, []string, string) {
+	var score float64
+	var matchFields []string
+	var snippet string
+
+	addMatch := func(name, text string, weight float64) {
+		fieldScore, token := q.fieldScore(text, weight)
+		if fieldScore == 0 {
+			return
+		}
+		score += fieldScore
+		matchFields = append(matchFields, name)
+		if snippet == "" {
+			snippet = extractSnippetAt(text, token.start, token.end)
+		}
+	}
+
+	addMatch("name", getStringProperty(relation.Properties, "name"), 10.0)
+	addMatch("transforms", strings.Join(getTransforms(relation), " "), 8.0)
+	addMatch("description", getStringProperty(relation.Properties, "description"), 5.0)
+	addMatch("tags", strings.Join(stringList(relation.Properties["tags"]), ", "), 2.0)
+
+	return score, matchFields, snippet
+}
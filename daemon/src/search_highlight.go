@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// snippetHighlighter finds where a query matches within result snippets,
+// using the same rules as the query's mode: the pattern itself for regex,
+// edit distance for fuzzy, and case-insensitive substrings for the rest
+// (the whole phrase, or each term for and/or)
+type snippetHighlighter struct {
+	re    *regexp.Regexp
+	fuzzy *fuzzyQuery
+}
+
+// newSnippetHighlighter prepares highlighting for one search request.
+// Returns nil when there is nothing to highlight.
+func newSnippetHighlighter(queryLower, mode string, fuzzy *fuzzyQuery) *snippetHighlighter {
+	if queryLower == "" {
+		return nil
+	}
+	switch mode {
+	case SearchModeRegex:
+		re, err := compileSearchRegex(queryLower)
+		if err != nil {
+			return nil
+		}
+		return &snippetHighlighter{re: re}
+	case SearchModeFuzzy:
+		if fuzzy == nil {
+			return nil
+		}
+		return &snippetHighlighter{fuzzy: fuzzy}
+	}
+
+	// Longest terms first, so a term that contains another wins
+	terms := []string{queryLower}
+	if mode != "phrase" && mode != "exact" {
+		terms = strings.Fields(queryLower)
+		sort.SliceStable(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	}
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	re, err := regexp.Compile("(?i)" + strings.Join(quoted, "|"))
+	if err != nil {
+		return nil
+	}
+	return &snippetHighlighter{re: re}
+}
+
+// highlight returns the byte ranges of snippet that matched, in order and
+// without overlaps
+func (h *snippetHighlighter) highlight(snippet string) []MatchRange {
+	if h == nil || snippet == "" {
+		return nil
+	}
+
+	var ranges []MatchRange
+	if h.fuzzy != nil {
+		for _, token := range fuzzyTokens(snippet) {
+			for _, term := range h.fuzzy.terms {
+				limit := h.fuzzy.maxDistance(term)
+				if editDistance(term, token.text, limit) <= limit {
+					ranges = append(ranges, MatchRange{Start: token.start, End: token.end})
+					break
+				}
+			}
+		}
+	} else {
+		for _, position := range h.re.FindAllStringIndex(snippet, -1) {
+			if position[1] > position[0] {
+				ranges = append(ranges, MatchRange{Start: position[0], End: position[1]})
+			}
+		}
+	}
+	return mergeMatchRanges(ranges)
+}
+
+// mergeMatchRanges sorts ranges and joins those that overlap. Fuzzy tokens
+// overlap when a hyphenated name matches both whole and by its parts.
+func mergeMatchRanges(ranges []MatchRange) []MatchRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			last.End = max(last.End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxContentScanCandidates caps how many objects without a metadata match
+// have their content read during a single search
+const maxContentScanCandidates = 200
+
+// searchIndex keeps every object's metadata in memory with an inverted index
+// over its searchable fields, so queries don't re-read the metadata directory.
+//
+// Scoring in searchInMetadata is substring based, so lookups match query
+// terms against the token vocabulary by substring rather than by exact
+// token. Tokens are whitespace-separated, which means any term a field
+// contains is always contained in one of that field's tokens, and the
+// candidate set is a superset of what would score above zero.
+type searchIndex struct {
+	mu        sync.RWMutex
+	docs      map[string]*Metadata           // Object ID -> metadata copy
+	postings  map[string]map[string]struct{} // Token -> object IDs
+	docTokens map[string][]string            // Object ID -> tokens, for removal
+}
+
+// newSearchIndex creates an empty index
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		docs:      make(map[string]*Metadata),
+		postings:  make(map[string]map[string]struct{}),
+		docTokens: make(map[string][]string),
+	}
+}
+
+// buildSearchIndex loads all metadata files into a new index
+func buildSearchIndex(metadataDir string) *searchIndex {
+	idx := newSearchIndex()
+
+	entries, err := os.ReadDir(metadataDir)
+	if err != nil {
+		log.Printf("⚠️ [SEARCH] Failed to read metadata directory for index: %v", err)
+		return idx
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(metadataDir, entry.Name()))
+		if err != nil {
+			log.Printf("Failed to load metadata for %s: %v", entry.Name(), err)
+			continue
+		}
+		var meta Metadata
+		if err := json.Unmarshal(data, &meta); err != nil {
+			log.Printf("Failed to load metadata for %s: %v", entry.Name(), err)
+			continue
+		}
+		idx.add(&meta)
+	}
+
+	log.Printf("🔎 [SEARCH] Indexed %d objects (%d tokens)", len(idx.docs), len(idx.postings))
+	return idx
+}
+
+// indexTokens returns the distinct lowercase tokens of every searchable field
+func indexTokens(meta *Metadata) []string {
+	fields := []string{meta.Type, meta.Title, meta.Description, meta.Session, meta.Agent}
+	fields = append(fields, meta.Tags...)
+	fields = append(fields, meta.Paths...)
+
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, field := range fields {
+		for _, token := range strings.Fields(strings.ToLower(field)) {
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// add indexes meta, replacing any previous entry for the same ID
+func (idx *searchIndex) add(meta *Metadata) {
+	doc := cloneMetadata(meta)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(doc.ID)
+	idx.docs[doc.ID] = doc
+	tokens := indexTokens(doc)
+	idx.docTokens[doc.ID] = tokens
+	for _, token := range tokens {
+		ids, ok := idx.postings[token]
+		if !ok {
+			ids = make(map[string]struct{})
+			idx.postings[token] = ids
+		}
+		ids[doc.ID] = struct{}{}
+	}
+}
+
+// get returns the indexed metadata for id (read-only), or nil
+func (idx *searchIndex) get(id string) *Metadata {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.docs[id]
+}
+
+// remove drops an object from the index
+func (idx *searchIndex) remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(id)
+}
+
+func (idx *searchIndex) removeLocked(id string) {
+	for _, token := range idx.docTokens[id] {
+		if ids, ok := idx.postings[token]; ok {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(idx.postings, token)
+			}
+		}
+	}
+	delete(idx.docTokens, id)
+	delete(idx.docs, id)
+}
+
+// snapshot returns all indexed metadata in metadata-file order, plus the IDs
+// whose fields may match the query. A nil candidate set means every object
+// is a candidate (empty query). Entries are replaced rather than mutated, so
+// the returned pointers are safe to read but must be cloned before handing
+// them out.
+func (idx *searchIndex) snapshot(queryLower, mode string) ([]*Metadata, map[string]bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	docs := make([]*Metadata, 0, len(idx.docs))
+	for _, doc := range idx.docs {
+		docs = append(docs, doc)
+	}
+	// Same order os.ReadDir gave the old directory scan
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].ID+".json" < docs[j].ID+".json"
+	})
+
+	// Regex patterns can't be split into terms, and fuzzy terms needn't
+	// appear verbatim; score everything
+	terms := strings.Fields(queryLower)
+	if len(terms) == 0 || mode == SearchModeRegex || mode == SearchModeFuzzy {
+		return docs, nil
+	}
+
+	// Phrase and AND matches need every term somewhere; OR needs any
+	intersect := mode == "phrase" || mode == "exact" || mode == "and"
+
+	var candidates map[string]bool
+	for _, term := range terms {
+		matched := make(map[string]bool)
+		for token, ids := range idx.postings {
+			if !strings.Contains(token, term) {
+				continue
+			}
+			for id := range ids {
+				matched[id] = true
+			}
+		}
+
+		switch {
+		case candidates == nil:
+			candidates = matched
+		case intersect:
+			for id := range candidates {
+				if !matched[id] {
+					delete(candidates, id)
+				}
+			}
+		default:
+			for id := range matched {
+				candidates[id] = true
+			}
+		}
+	}
+	return docs, candidates
+}
+
+// cloneMetadata copies meta so index entries never share slices with callers
+func cloneMetadata(meta *Metadata) *Metadata {
+	clone := *meta
+	clone.Paths = cloneStrings(meta.Paths)
+	clone.Tags = cloneStrings(meta.Tags)
+	if meta.Embeddings != nil {
+		clone.Embeddings = make([]float32, len(meta.Embeddings))
+		copy(clone.Embeddings, meta.Embeddings)
+	}
+	clone.Relationships.ParentArtifacts = cloneStrings(meta.Relationships.ParentArtifacts)
+	clone.Relationships.ChildArtifacts = cloneStrings(meta.Relationships.ChildArtifacts)
+	clone.Relationships.GeneratedCommands = cloneStrings(meta.Relationships.GeneratedCommands)
+	clone.Relationships.References = cloneStrings(meta.Relationships.References)
+	return &clone
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	clone := make([]string, len(values))
+	copy(clone, values)
+	return clone
+}
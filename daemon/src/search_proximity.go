@@ -0,0 +1,85 @@
+package main
+
+import "strings"
+
+// proximityWindow is how many consecutive words query terms must fall
+// within to count as near each other
+const proximityWindow = 8
+
+// Proximity bonus weights, added on top of the field's match score
+const (
+	proximityContentWeight     = 0.5
+	proximityDescriptionWeight = 1.0
+	proximityTitleWeight       = 0.5
+)
+
+// proximityBonus rewards text where several query terms occur close
+// together. The best run of at most proximityWindow words is scored by the
+// share of distinct terms it holds and by how tightly they are packed, so
+// adjacent terms earn the full weight and terms a window apart little.
+// Queries with fewer than two distinct terms get no bonus.
+func proximityBonus(textLower string, terms []string, weight float64) float64 {
+	distinct := uniqueTerms(terms)
+	if len(distinct) < 2 {
+		return 0
+	}
+
+	// Which terms each word holds, as the same substring match the modes use
+	words := strings.Fields(textLower)
+	held := make([][]int, len(words))
+	for i, word := range words {
+		for t, term := range distinct {
+			if strings.Contains(word, term) {
+				held[i] = append(held[i], t)
+			}
+		}
+	}
+
+	best := 0.0
+	seen := make([]bool, len(distinct))
+	for start := range words {
+		if len(held[start]) == 0 {
+			continue
+		}
+		for t := range seen {
+			seen[t] = false
+		}
+		found := 0
+		end := min(start+proximityWindow, len(words))
+		for i := start; i < end; i++ {
+			added := false
+			for _, t := range held[i] {
+				if !seen[t] {
+					seen[t] = true
+					found++
+					added = true
+				}
+			}
+			if !added || found < 2 {
+				continue
+			}
+			// Words between the terms beyond the ones they occupy
+			gap := max(i-start+1-found, 0)
+			closeness := 1 - float64(gap)/float64(proximityWindow)
+			share := float64(found-1) / float64(len(distinct)-1)
+			best = max(best, share*closeness)
+			if found == len(distinct) {
+				break
+			}
+		}
+	}
+	return weight * best
+}
+
+// uniqueTerms drops repeated query terms, keeping their order
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
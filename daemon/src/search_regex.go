@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// SearchModeRegex matches the query as a case-insensitive Go regular expression
+const SearchModeRegex = "regex"
+
+// regexMatchCap limits how many matches per field count toward the score
+const regexMatchCap = 5
+
+// regexCacheSize bounds the compiled pattern cache
+const regexCacheSize = 64
+
+var (
+	regexCache   = make(map[string]*regexp.Regexp)
+	regexCacheMu sync.Mutex
+)
+
+// compileSearchRegex compiles a regex query, reusing earlier compilations
+func compileSearchRegex(pattern string) (*regexp.Regexp, error) {
+	regexCacheMu.Lock()
+	defer regexCacheMu.Unlock()
+
+	if re, ok := regexCache[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %v", pattern, err)
+	}
+
+	// Simple reset keeps memory bounded; patterns are cheap to recompile
+	if len(regexCache) >= regexCacheSize {
+		regexCache = make(map[string]*regexp.Regexp)
+	}
+	regexCache[pattern] = re
+	return re, nil
+}
+
+// searchQuery normalizes the query for a mode. Regex patterns keep their
+// case because lowercasing would change escapes like \S and \W.
+func searchQuery(query, mode string) string {
+	if mode == SearchModeRegex {
+		return query
+	}
+	return strings.ToLower(query)
+}
+
+// regexFieldScore scores one field: the weight for the first match plus 20%
+// for each further match up to regexMatchCap. Returns the match positions.
+func regexFieldScore(re *regexp.Regexp, text string, weight float64) (float64, [][]int) {
+	if text == "" {
+		return 0, nil
+	}
+	positions := re.FindAllStringIndex(text, regexMatchCap)
+	if len(positions) == 0 {
+		return 0, nil
+	}
+	return weight * (1 + 0.2*float64(len(positions)-1)), positions
+}
+
+// searchMetadataRegex is the regex branch of searchInMetadata, using the same field weights
+func searchMetadataRegex(metadata *Metadata, re *regexp.Regexp) (float64, []string, string) {
+	score := 0.0
+	matchFields := []string{}
+	snippet := ""
+
+	fields := []struct {
+		name   string
+		text   string
+		weight float64
+	}{
+		{"description", metadata.Description, 3.0},
+		{"title", metadata.Title, 2.5},
+		{"tags", strings.Join(metadata.Tags, ", "), 2.0},
+		{"session", metadata.Session, 1.5},
+		{"agent", metadata.Agent, 1.5},
+		{"path", strings.Join(metadata.Paths, " "), 0.5},
+	}
+
+	for _, field := range fields {
+		fieldScore, positions := regexFieldScore(re, field.text, field.weight)
+		if fieldScore == 0 {
+			continue
+		}
+		score += fieldScore
+		matchFields = append(matchFields, field.name)
+		if snippet == "" && field.name != "path" {
+			snippet = extractSnippetAt(field.text, positions[0][0], positions[0][1])
+		}
+	}
+
+	return score, matchFields, snippet
+}
+
+// scoreRelationRegex is the regex branch of scoreRelation, using the same field weights
+func scoreRelationRegex(relation Relation, re *regexp.Regexp) (float64, []string, string) {
+	var score float64
+	var matchFields []string
+	var snippet string
+
+	addMatch := func(name, text string, weight float64) {
+		fieldScore, positions := regexFieldScore(re, text, weight)
+		if fieldScore == 0 {
+			return
+		}
+		score += fieldScore
+		matchFields = append(matchFields, name)
+		if snippet == "" {
+			snippet = extractSnippetAt(text, positions[0][0], positions[0][1])
+		}
+	}
+
+	addMatch("name", getStringProperty(relation.Properties, "name"), 10.0)
+	addMatch("transforms", strings.Join(getTransforms(relation), " "), 8.0)
+	addMatch("description", getStringProperty(relation.Properties, "description"), 5.0)
+	addMatch("parent", getStringProperty(relation.Properties, "parent"), 6.0)
+
+	for key, value := range relation.Properties {
+		if key == "name" || key == "description" || key == "transforms" || key == "parent" {
+			continue // Already searched
+		}
+		if valueStr, ok := value.(string); ok {
+			addMatch(key, valueStr, 2.0)
+		}
+	}
+
+	return score, matchFields, snippet
+}
+
+// searchContentRegex is the regex branch of searchInContent
+func searchContentRegex(content string, re *regexp.Regexp) (float64, string) {
+	positions := re.FindAllStringIndex(content, regexMatchCap)
+	if len(positions) == 0 {
+		return 0, ""
+	}
+	// Same shape as phrase mode: base score plus 0.2 per occurrence
+	score := 1.0 + float64(len(positions))*0.2
+	return score, extractSnippetAt(content, positions[0][0], positions[0][1])
+}
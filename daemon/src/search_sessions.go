@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// MessageMatch locates the best matching message in a session search result
+type MessageMatch struct {
+	SessionID string    `json:"session_id"`
+	Index     int       `json:"index"` // Position in the session's messages, from 0
+	Role      string    `json:"role"`
+	Timestamp time.Time `json:"timestamp"`
+	Matches   int       `json:"matches"` // How many of the session's messages matched
+	Total     int       `json:"total"`   // Messages in the session
+}
+
+// hasMessageFilters reports whether any message filter is set
+func (f SearchFilters) hasMessageFilters() bool {
+	return f.MessageRole != "" || !f.MessageAfter.IsZero() || !f.MessageBefore.IsZero()
+}
+
+// matchesMessage checks a message against the message filters
+func (f SearchFilters) matchesMessage(message Message) bool {
+	if f.MessageRole != "" && !strings.EqualFold(message.Role, strings.TrimSpace(f.MessageRole)) {
+		return false
+	}
+	if !f.MessageAfter.IsZero() && message.Timestamp.Before(f.MessageAfter) {
+		return false
+	}
+	if !f.MessageBefore.IsZero() && message.Timestamp.After(f.MessageBefore) {
+		return false
+	}
+	return true
+}
+
+// messageScore is one matching message of a session
+type messageScore struct {
+	index   int
+	score   float64
+	snippet string
+}
+
+// searchSessionMessages scores each message of every session's current
+// transcript and returns one result per session, located at its best
+// message. Only the version the session index points at is searched, so a
+// conversation appears once however many times it was saved. metadataMatches
+// holds the sessions' metadata matches by object ID; a session whose
+// messages don't match still appears if its current metadata did.
+//
+// With message filters only the messages they allow are scored, every
+// matching message is its own result, and metadata matches only add to the
+// score of messages that match. An empty query then matches every allowed
+// message with a score of 1.
+func (s *Storage) searchSessionMessages(queryLower, mode string, filters SearchFilters, metadataMatches map[string]SearchResult) []SearchResult {
+	perMessage := filters.hasMessageFilters()
+
+	s.indexMutex.RLock()
+	refs := make([]SessionReference, 0, len(s.sessionIndex.Sessions))
+	for _, ref := range s.sessionIndex.Sessions {
+		refs = append(refs, ref)
+	}
+	s.indexMutex.RUnlock()
+
+	results := []SearchResult{}
+	for _, ref := range refs {
+		metadata := s.searchIndex.get(ref.ObjectID)
+		if metadata == nil || !matchesFilters(metadata, filters) {
+			continue
+		}
+		metadataMatch, hasMetadataMatch := metadataMatches[ref.ObjectID]
+		hasMetadataMatch = hasMetadataMatch && queryLower != ""
+
+		var matched []messageScore
+		var session PersistentSession
+		if content, err := s.Read(ref.ObjectID); err == nil && json.Unmarshal(content, &session) == nil {
+			for i, message := range session.Messages {
+				if !filters.matchesMessage(message) {
+					continue
+				}
+				score, snippet := 1.0, extractSnippetAt(message.Content, 0, 40)
+				if queryLower != "" {
+					score, snippet = scoreContent(message.Content, queryLower, mode)
+					score *= 0.8 // Message matches rank like content matches
+				}
+				if score > 0 {
+					matched = append(matched, messageScore{index: i, score: score, snippet: snippet})
+				}
+			}
+		}
+		if len(matched) == 0 {
+			if hasMetadataMatch && !perMessage {
+				results = append(results, metadataMatch)
+			}
+			continue
+		}
+
+		matchFields := []string{"messages"}
+		metadataScore := 0.0
+		if hasMetadataMatch {
+			metadataScore = metadataMatch.Score
+			matchFields = append(cloneStrings(metadataMatch.MatchFields), "messages")
+		}
+		located := func(match messageScore, score float64) SearchResult {
+			result := newMetadataSearchResult(metadata, score, matchFields, match.snippet)
+			message := session.Messages[match.index]
+			result.Message = &MessageMatch{
+				SessionID: ref.SessionID,
+				Index:     match.index,
+				Role:      message.Role,
+				Timestamp: message.Timestamp,
+				Matches:   len(matched),
+				Total:     len(session.Messages),
+			}
+			return result
+		}
+
+		if perMessage {
+			for _, match := range matched {
+				results = append(results, located(match, match.score+metadataScore))
+			}
+			continue
+		}
+
+		// A small boost for conversations that keep coming back to the query
+		best := matched[0]
+		for _, match := range matched[1:] {
+			if match.score > best.score {
+				best = match
+			}
+		}
+		results = append(results, located(best, best.score+float64(min(len(matched)-1, 5))*0.05+metadataScore))
+	}
+	return results
+}
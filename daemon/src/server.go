@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +33,15 @@ type Daemon struct {
 	validator       *validation.RequestValidator // Step 5: Request validation
 	referenceHandler *ReferenceHandler // Common reference resolution logic
 	contextCollector *ContextCollector // Step 2: Context tracking and suggestions
+	fileAccess      *FileAccessPolicy  // Which local files file: references may read
+	similarity      SimilarityConfig   // Similarity backend for /similar and similar_to links
+	sessionEvictions int64             // Sessions dropped from memory to stay under MaxSessions (guarded by mu)
+	events          *EventBus          // Activity streamed to watch clients
+	auth            *AuthConfig        // Optional shared-secret token (PORT42_AUTH_TOKEN)
+	ws              *wsServer          // WebSocket transport, when PORT42_WS_PORT is set
+	generations     generationRegistry // In-flight possess generations, for cancel requests
+	audit           *AuditLog          // Mutating requests, appended to ~/.port42/audit.log
+	uploads         *uploadManager     // Chunked store_path uploads in progress
 }
 
 // Session represents an active swim session
@@ -43,6 +54,8 @@ type Session struct {
 	Messages         []Message    `json:"messages"`
 	CommandGenerated *CommandSpec `json:"command_generated,omitempty"`
 	IdleTimeout      time.Duration `json:"idle_timeout"`
+	ForkedFrom       string       `json:"forked_from,omitempty"` // Session this one was forked from (fork_session)
+	ForkPoint        int          `json:"fork_point,omitempty"`  // Messages copied from ForkedFrom
 	mu               sync.Mutex
 }
 
@@ -56,12 +69,37 @@ type Message struct {
 
 // Config holds daemon configuration
 type Config struct {
-	Port         string
-	AIBackend    string
-	MaxSessions  int
-	SessionTTL   time.Duration
-	MemoryPath   string
-	CommandsPath string
+	Port              string
+	AIBackend         string
+	MaxSessions       int
+	SessionTTL        time.Duration
+	MemoryPath        string
+	CommandsPath      string
+	
+	// Connection keepalive (PORT42_CONN_IDLE_TIMEOUT, PORT42_KEEPALIVE_INTERVAL)
+	ConnIdleTimeout   time.Duration
+	KeepaliveInterval time.Duration
+	
+	// Session lifecycle (PORT42_IDLE_TIMEOUT, PORT42_ABANDON_MULTIPLIER)
+	IdleTimeout       time.Duration
+	AbandonMultiplier float64
+	
+	// Run install-deps.sh for missing dependencies of declared tools (PORT42_AUTO_INSTALL_DEPS)
+	AutoInstallDeps bool
+	
+	// Optional WebSocket port alongside the TCP port (PORT42_WS_PORT)
+	WSPort string
+	
+	// Largest encoded response before heavy fields are cut (PORT42_MAX_RESPONSE_SIZE)
+	MaxResponseSize int
+	
+	// How tools with sandbox_profile "restricted" are run (PORT42_SANDBOX_ALLOW, PORT42_SANDBOX_NETWORK_HOOK)
+	Sandbox SandboxConfig
+	
+	// Agent of Tool relations declared without one (PORT42_DEFAULT_AGENT), and
+	// what declares naming an unconfigured agent get (PORT42_UNKNOWN_AGENTS)
+	DefaultAgent  string
+	UnknownAgents string
 }
 
 // NewDaemon creates a new daemon instance
@@ -72,79 +110,121 @@ func NewDaemon(listener net.Listener, port string) *Daemon {
 	// Initialize relation store first
 	relationStore, err := NewFileRelationStore(baseDir)
 	if err != nil {
-		log.Printf("❌ Failed to initialize relation store: %v", err)
+		logger.Errorf("❌ Failed to initialize relation store: %v", err)
 		relationStore = nil // Continue without relations
 	}
 	
 	// Initialize unified storage with relation store
-	log.Printf("🗄️ Initializing storage...")
+	logger.Infof("🗄️ Initializing storage...")
 	storage, err := NewStorage(baseDir, relationStore)
 	if err != nil {
-		log.Printf("❌ Failed to initialize storage: %v", err)
+		logger.Errorf("❌ Failed to initialize storage: %v", err)
 		// Continue without storage for now
 	} else {
-		log.Printf("✅ Storage initialized successfully")
+		logger.Infof("✅ Storage initialized successfully")
+		
+		// Optional consistency scan; problems are reported, never fatal
+		if envBool("PORT42_INTEGRITY_CHECK", false) {
+			logger.Debugf("🔍 Checking storage integrity...")
+			report, err := storage.CheckIntegrity(envBool("PORT42_INTEGRITY_QUARANTINE", false))
+			if err != nil {
+				logger.Warnf("⚠️ Integrity check failed: %v", err)
+			} else {
+				logIntegrityReport(report)
+			}
+		}
 	}
 	
 	// Debug logging
-	log.Printf("DEBUG: NewDaemon called with port = '%s'", port)
+	logger.Debugf("DEBUG: NewDaemon called with port = '%s'", port)
 	
 	daemon := &Daemon{
 		listener:   listener,
 		sessions:   make(map[string]*Session),
+		similarity: defaultSimilarityConfig(),
+		events:     NewEventBus(),
+		auth:       loadAuthConfig(),
 		shutdownCh: make(chan struct{}),
 		storage:    storage,
 		baseDir:    baseDir,
 		config: Config{
-			Port:         port,
-			AIBackend:    "http://localhost:3000/api/ai", // Default, can be overridden
-			MaxSessions:  100,
-			SessionTTL:   24 * time.Hour,
-			MemoryPath:   filepath.Join(homeDir, ".port42", "memory"),
-			CommandsPath: filepath.Join(homeDir, ".port42", "commands"),
+			Port:              port,
+			AIBackend:         "http://localhost:3000/api/ai", // Default, can be overridden
+			MaxSessions:       envInt("PORT42_MAX_SESSIONS", defaultMaxSessions),
+			SessionTTL:        24 * time.Hour,
+			MemoryPath:        filepath.Join(homeDir, ".port42", "memory"),
+			CommandsPath:      filepath.Join(homeDir, ".port42", "commands"),
+			ConnIdleTimeout:   envDuration("PORT42_CONN_IDLE_TIMEOUT", defaultConnIdleTimeout),
+			KeepaliveInterval: envDuration("PORT42_KEEPALIVE_INTERVAL", defaultKeepaliveInterval),
+			IdleTimeout:       envPositiveDuration("PORT42_IDLE_TIMEOUT", defaultIdleTimeout),
+			AbandonMultiplier: envPositiveFloat("PORT42_ABANDON_MULTIPLIER", defaultAbandonMultiplier),
+			AutoInstallDeps:   envBool("PORT42_AUTO_INSTALL_DEPS", false),
+			WSPort:            envString("PORT42_WS_PORT", ""),
+			MaxResponseSize:   envInt("PORT42_MAX_RESPONSE_SIZE", defaultMaxResponseSize),
+			Sandbox:           loadSandboxConfig(),
+			DefaultAgent:      loadDefaultAgent(),
+			UnknownAgents:     loadUnknownAgentsPolicy(),
 		},
 	}
+	logger.Infof("⏱️ Sessions go idle after %v, abandoned after %v", daemon.config.IdleTimeout, abandonAfter(daemon.config.IdleTimeout, daemon.config.AbandonMultiplier))
+	logger.Infof("⏱️ AI calls time out after %v, retries included", loadRetryPolicy().Deadline)
+	
+	// Sessions loaded from disk use the same idle timeout
+	if storage != nil {
+		storage.sessionIdleTimeout = daemon.config.IdleTimeout
+	}
 	
 	// Initialize Context Collector FIRST (before Reality Compiler needs it)
-	log.Printf("📊 Initializing Context Collector...")
+	logger.Infof("📊 Initializing Context Collector...")
 	daemon.contextCollector = NewContextCollector(daemon)
-	log.Printf("✅ Context Collector initialized")
+	logger.Infof("✅ Context Collector initialized")
 	
 	// Initialize Reality Compiler (now has access to context collector)
-	log.Printf("🌟 Initializing Reality Compiler...")
+	logger.Infof("🌟 Initializing Reality Compiler...")
 	if err := daemon.initializeRealityCompiler(); err != nil {
-		log.Printf("⚠️ Failed to initialize Reality Compiler: %v", err)
-		log.Printf("💡 Declarative commands will not be available")
+		logger.Warnf("⚠️ Failed to initialize Reality Compiler: %v", err)
+		logger.Warnf("💡 Declarative commands will not be available")
 	} else {
-		log.Printf("✅ Reality Compiler initialized successfully")
+		logger.Infof("✅ Reality Compiler initialized successfully")
+	}
+	
+	// file: references are limited to the roots in ~/.port42/file-access.json
+	daemon.fileAccess = loadFileAccessPolicy(baseDir)
+	logger.Infof("🔐 File access policy: %s", daemon.fileAccess)
+	
+	// Every mutating request is appended to ~/.port42/audit.log
+	daemon.audit = NewAuditLog(baseDir)
+	
+	if storage != nil {
+		daemon.uploads = newUploadManager(baseDir)
 	}
 	
 	// Initialize Reference Resolution Manager (Phase 2)
-	log.Printf("📎 Initializing Reference Resolution Manager...")
+	logger.Infof("📎 Initializing Reference Resolution Manager...")
 	if err := daemon.initializeResolutionManager(); err != nil {
-		log.Printf("⚠️ Failed to initialize Resolution Manager: %v", err)
-		log.Printf("💡 Reference resolution will not be available")
+		logger.Warnf("⚠️ Failed to initialize Resolution Manager: %v", err)
+		logger.Warnf("💡 Reference resolution will not be available")
 	} else {
-		log.Printf("✅ Resolution Manager initialized successfully")
+		logger.Infof("✅ Resolution Manager initialized successfully")
 	}
 	
 	// Initialize Request Validator (Step 5)
-	log.Printf("🛡️ Initializing Request Validator...")
+	logger.Infof("🛡️ Initializing Request Validator...")
 	daemon.validator = validation.NewRequestValidator()
-	log.Printf("✅ Request Validator initialized successfully")
+	logger.Infof("✅ Request Validator initialized successfully")
 	
 	// Initialize Reference Handler (common reference resolution logic)
-	log.Printf("🔗 Initializing Reference Handler...")
+	logger.Infof("🔗 Initializing Reference Handler...")
 	daemon.referenceHandler = NewReferenceHandler(daemon.resolutionService)
-	log.Printf("✅ Reference Handler initialized successfully")
+	logger.Infof("✅ Reference Handler initialized successfully")
 	
-	log.Printf("DEBUG: Created daemon with config.Port = '%s'", daemon.config.Port)
+	logger.Debugf("DEBUG: Created daemon with config.Port = '%s'", daemon.config.Port)
 	return daemon
 }
 
 // Start begins accepting connections
 func (d *Daemon) Start() {
-	log.Printf("🐬 Daemon starting with config: %+v", d.config)
+	logger.Infof("🐬 Daemon starting with config: %+v", d.config)
 	
 	// Load recent sessions from disk
 	if d.storage != nil {
@@ -155,6 +235,9 @@ func (d *Daemon) Start() {
 	d.wg.Add(1)
 	go d.cleanupSessions()
 	
+	// Optional WebSocket transport
+	d.startWebSocket()
+	
 	// Accept connections
 	for {
 		conn, err := d.listener.Accept()
@@ -163,7 +246,7 @@ func (d *Daemon) Start() {
 			case <-d.shutdownCh:
 				return
 			default:
-				log.Printf("Error accepting connection: %v", err)
+				logger.Warnf("Error accepting connection: %v", err)
 				continue
 			}
 		}
@@ -178,11 +261,17 @@ func (d *Daemon) Start() {
 
 // Shutdown gracefully stops the daemon
 func (d *Daemon) Shutdown() {
-	log.Println("🐬 Daemon shutting down...")
+	logger.Infof("🐬 Daemon shutting down...")
 	close(d.shutdownCh)
 	d.listener.Close()
+	if d.ws != nil {
+		d.ws.Close()
+	}
 	d.wg.Wait()
-	log.Println("🐬 Daemon stopped")
+	if d.storage != nil {
+		d.storage.Close()
+	}
+	logger.Infof("🐬 Daemon stopped")
 }
 
 // handleConnection processes a single connection
@@ -191,58 +280,126 @@ func (d *Daemon) handleConnection(conn net.Conn) {
 	
 	clientAddr := conn.RemoteAddr().String()
 	
-	decoder := json.NewDecoder(conn)
-	encoder := json.NewEncoder(conn)
+	client := newClientConn(conn, d.config.ConnIdleTimeout)
 	
-	// Read JSON request
-	var req Request
-	if err := decoder.Decode(&req); err != nil {
-		log.Printf("Error decoding request from %s: %v", clientAddr, err)
-		resp := Response{
-			ID:      "error",
-			Success: false,
-			Error:   "Invalid JSON request",
+	// Read JSON request (keepalive ping frames are answered and skipped)
+	req, err := client.readRequest()
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			logger.Infof("◊ Idle connection from %s timed out", clientAddr)
+			return
 		}
-		encoder.Encode(resp)
+		logger.Warnf("Error decoding request from %s: %v", clientAddr, err)
+		client.send(NewErrorResponse("error", "Invalid JSON request"))
 		return
 	}
 	
 	// Only log non-context requests to reduce noise
 	if req.Type != "context" {
-		log.Printf("◊ New swimmer connected from %s", clientAddr)
-		log.Printf("◊ Request [%s] type: %s", req.ID, req.Type)
+		logger.Debugf("◊ New swimmer connected from %s", clientAddr)
+		logger.Debugf("◊ Request [%s] type: %s", req.ID, req.Type)
+	}
+	
+	// Streaming watch: the connection stays open for event frames
+	if target, ok := wantsWatchStream(req); ok {
+		if !d.auth.Allows(req) {
+			client.send(rejectUnauthorized(req))
+			return
+		}
+		d.streamWatch(client, req, target)
+		logger.Debugf("◊ Swimmer disconnected: %s", clientAddr)
+		return
+	}
+	
+	// Streaming possess: partial output goes out as chunk frames before
+	// the final response, with keepalives while the model is thinking
+	streaming := wantsStream(req)
+	if streaming {
+		stop := make(chan struct{})
+		defer close(stop)
+		client.startKeepalive(d.config.KeepaliveInterval, stop)
+		gone := client.watchClientFrames()
+		req.gone = gone
+		
+		req.emit = func(content string) {
+			select {
+			case <-gone:
+				return // Nobody left to read it
+			default:
+			}
+			chunk := NewResponse(req.ID, true)
+			chunk.Frame = FrameChunk
+			chunk.SetData(StreamChunk{Content: content})
+			if err := client.send(chunk); err != nil {
+				logger.Warnf("⚠️ Failed to send chunk to %s: %v", clientAddr, err)
+			}
+		}
 	}
 	
 	// Process request
 	resp := d.handleRequest(req)
+	if streaming {
+		resp.Frame = FrameComplete
+	}
 	
 	// Debug: Check response size (skip for context)
 	var respJSON []byte
 	if req.Type != "context" {
 		respJSON, _ = json.Marshal(resp)
-		log.Printf("🔍 Response size for [%s]: %d bytes", resp.ID, len(respJSON))
+		logger.Debugf("🔍 Response size for [%s]: %d bytes", resp.ID, len(respJSON))
 		
 		// For very large responses, log a warning
 		if len(respJSON) > 1024*1024 { // 1MB
-			log.Printf("⚠️ Large response detected: %.2f MB", float64(len(respJSON))/(1024*1024))
+			logger.Warnf("⚠️ Large response detected: %.2f MB", float64(len(respJSON))/(1024*1024))
 		}
 	}
 	
 	// Send response
-	if err := encoder.Encode(resp); err != nil {
-		log.Printf("Error encoding response to %s: %v", clientAddr, err)
+	if err := client.send(resp); err != nil {
+		logger.Warnf("Error encoding response to %s: %v", clientAddr, err)
 		return
 	}
 	
 	// Only log non-context responses
 	if req.Type != "context" {
-		log.Printf("◊ Response sent [%s] success: %v", resp.ID, resp.Success)
-		log.Printf("◊ Swimmer disconnected: %s", clientAddr)
+		logger.Debugf("◊ Response sent [%s] success: %v", resp.ID, resp.Success)
+		logger.Debugf("◊ Swimmer disconnected: %s", clientAddr)
+	}
+}
+
+// wantsStream reports whether a request asked for chunked output.
+// Only possess (swim) supports streaming, and only for clients speaking
+// ProtocolV2; older ones get the single response they expect.
+func wantsStream(req Request) bool {
+	if req.Type != RequestSwim || !req.Supports(ProtocolV2) {
+		return false
+	}
+	var payload struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(req.Payload, &payload) == nil && payload.Stream
+}
+
+// wantsWatchStream reports whether a watch request asked to stay open,
+// returning its target. Like possess streams, that needs ProtocolV2.
+func wantsWatchStream(req Request) (string, bool) {
+	if req.Type != RequestWatch || !req.Supports(ProtocolV2) {
+		return "", false
+	}
+	var payload WatchPayload
+	if json.Unmarshal(req.Payload, &payload) != nil || !payload.Stream {
+		return "", false
 	}
+	return payload.Target, true
 }
 
 // handleRequest routes requests to appropriate handlers
 func (d *Daemon) handleRequest(req Request) Response {
+	// With PORT42_AUTH_TOKEN set, nothing is routed without the token
+	if !d.auth.Allows(req) {
+		return rejectUnauthorized(req)
+	}
+	
 	// Track only meaningful user commands (not internal operations)
 	if d.contextCollector != nil {
 		commandName := ""
@@ -284,8 +441,10 @@ func (d *Daemon) handleRequest(req Request) Response {
 		}
 	}
 	
-	// Now handle the request
-	return d.handleRequestInternal(req)
+	// Now handle the request, auditing it before the response is cut to size
+	resp := d.handleRequestInternal(req)
+	d.recordAudit(req, resp)
+	return limitResponse(req, resp, d.config.MaxResponseSize)
 }
 
 // handleRequestInternal actually processes the request
@@ -306,32 +465,96 @@ func (d *Daemon) handleRequestInternal(req Request) Response {
 	case "ping":
 		// Simple ping handler for connection checks
 		return NewResponse(req.ID, true)
+	case "cancel":
+		return d.handleCancel(req)
 	case "store_path":
 		return d.handleStorePath(req)
+	case "begin_upload":
+		return d.handleBeginUpload(req)
+	case "upload_chunk":
+		return d.handleUploadChunk(req)
+	case "commit_upload":
+		return d.handleCommitUpload(req)
+	case "abort_upload":
+		return d.handleAbortUpload(req)
 	case "update_path":
 		return d.handleUpdatePath(req)
 	case "delete_path":
 		return d.handleDeletePath(req)
+	case "move_path":
+		return d.handleMovePath(req)
 	case "create_memory":
 		return d.handleCreateMemory(req)
 	case "list_path":
 		return d.handleListPath(req)
 	case "read_path":
 		return d.handleReadPath(req)
+	case "read_paths":
+		return d.handleReadPaths(req)
+	case "read_object":
+		return d.handleReadObject(req)
 	case "get_metadata":
 		return d.handleGetMetadata(req)
+	case "list_aliases":
+		return d.handleListAliases(req)
+	case "resolve_path":
+		return d.handleResolvePath(req)
 	case "search":
 		return d.handleSearch(req)
 	case "get_last_session":
 		return d.handleGetLastSession(req)
+	case "fork_session":
+		return d.handleForkSession(req)
+	case "list_agents":
+		return d.handleListAgents(req)
+	case "storage_stats":
+		return d.handleStorageStats(req)
+	case "audit_tools":
+		return d.handleAuditTools(req)
+	case "artifact_manifest":
+		return d.handleArtifactManifest(req)
+	case "validate_tool_safety":
+		return d.handleValidateToolSafety(req)
+	case "restore_version":
+		return d.handleRestoreVersion(req)
+	case "execution":
+		return d.handleExecution(req)
+	case "batch_op":
+		return d.handleBatchOp(req)
+	case "reassign_session":
+		return d.handleReassignSession(req)
+	case "gc":
+		return d.handleGC(req)
+	case "prune":
+		return d.handlePrune(req)
+	case "check_integrity":
+		return d.handleCheckIntegrity(req)
+	case "verify":
+		return d.handleVerify(req)
+	case "rebuild_index":
+		return d.handleRebuildIndex(req)
+	case "export":
+		return d.handleExport(req)
+	case "import":
+		return d.handleImport(req)
 	case "declare_relation":
 		return d.handleDeclareRelation(req)
+	case "declare_relations":
+		return d.handleDeclareRelations(req)
 	case "get_relation":
 		return d.handleGetRelation(req)
 	case "list_relations":
 		return d.handleListRelations(req)
 	case "delete_relation":
 		return d.handleDeleteRelation(req)
+	case "uninstall_tool":
+		return d.handleUninstallTool(req)
+	case "purge_agent":
+		return d.handlePurgeAgent(req)
+	case "get_audit":
+		return d.handleGetAudit(req)
+	case "get_graph":
+		return d.handleGetGraph(req)
 	case "context":
 		return d.handleGetContext(req)
 	default:
@@ -426,6 +649,274 @@ func (d *Daemon) handleDeletePath(req Request) Response {
 	return resp
 }
 
+// handleMovePath moves an object from one virtual path to another
+func (d *Daemon) handleMovePath(req Request) Response {
+	var payload struct {
+		OldPath string `json:"old_path"`
+		NewPath string `json:"new_path"`
+	}
+
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+
+	// Delegate to storage
+	result, err := d.storage.HandleMovePath(payload.OldPath, payload.NewPath)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(result)
+	return resp
+}
+
+// handleBatchOp applies an action to every object matching a search selector
+func (d *Daemon) handleBatchOp(req Request) Response {
+	var payload struct {
+		Selector  BatchSelector `json:"selector"`
+		Action    string        `json:"action"`
+		Tags      []string      `json:"tags,omitempty"`      // For add-tags
+		Lifecycle string        `json:"lifecycle,omitempty"` // For set-lifecycle
+		Confirm   bool          `json:"confirm,omitempty"`   // Dry run unless true
+	}
+
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+
+	// Delegate to storage
+	result, err := d.storage.HandleBatchOp(payload.Selector, payload.Action, payload.Tags, payload.Lifecycle, payload.Confirm)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(result)
+	return resp
+}
+
+// handleGC deletes unreferenced objects (dry run unless dry_run is false)
+func (d *Daemon) handleGC(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	var payload struct {
+		DryRun *bool `json:"dry_run,omitempty"` // Defaults to true
+	}
+	
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+		}
+	}
+	
+	dryRun := payload.DryRun == nil || *payload.DryRun
+	
+	// Delegate to storage
+	report, err := d.storage.GC(dryRun)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(report)
+	return resp
+}
+
+// handlePrune removes old deprecated objects and abandoned sessions (dry run
+// unless dry_run is false)
+func (d *Daemon) handlePrune(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	var payload struct {
+		OlderThan string   `json:"older_than,omitempty"` // Duration such as "720h" or "30d"; defaults to 30 days
+		States    []string `json:"states,omitempty"`     // Defaults to deprecated and abandoned
+		DryRun    *bool    `json:"dry_run,omitempty"`    // Defaults to true
+	}
+	
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+		}
+	}
+	
+	olderThan := defaultPruneAge
+	if payload.OlderThan != "" {
+		parsed, err := parsePruneAge(payload.OlderThan)
+		if err != nil {
+			return NewErrorResponse(req.ID, "Invalid older_than: "+err.Error())
+		}
+		olderThan = parsed
+	}
+	
+	dryRun := payload.DryRun == nil || *payload.DryRun
+	
+	// Delegate to storage
+	report, err := d.storage.Prune(olderThan, payload.States, dryRun)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(report)
+	return resp
+}
+
+// handleCheckIntegrity reports entries whose metadata, object or relation
+// references disagree, optionally moving them into quarantine
+func (d *Daemon) handleCheckIntegrity(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	var payload struct {
+		Quarantine bool `json:"quarantine,omitempty"`
+	}
+	
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+		}
+	}
+	
+	report, err := d.storage.CheckIntegrity(payload.Quarantine)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	logIntegrityReport(report)
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(report)
+	return resp
+}
+
+// handleExport writes the store to a tar.gz archive on the daemon's machine
+func (d *Daemon) handleExport(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	var payload struct {
+		Path string `json:"path,omitempty"` // Defaults to ~/.port42/exports/port42-<timestamp>.tar.gz
+	}
+	
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+		}
+	}
+	
+	archivePath := payload.Path
+	if archivePath == "" {
+		archivePath = filepath.Join(d.baseDir, "exports", fmt.Sprintf("port42-%s.tar.gz", time.Now().Format("20060102-150405")))
+	}
+	archivePath = expandAccessRoot(archivePath)
+	if archivePath == "" {
+		return NewErrorResponse(req.ID, "Invalid path: "+payload.Path)
+	}
+	if !d.isFileAccessAllowed(archivePath) {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Access denied: %s is outside the file access policy", archivePath))
+	}
+	
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return NewErrorResponse(req.ID, "Failed to create export directory: "+err.Error())
+	}
+	
+	// Written beside the target and renamed, so a failed export leaves no partial archive
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), filepath.Base(archivePath)+".tmp*")
+	if err != nil {
+		return NewErrorResponse(req.ID, "Failed to create archive: "+err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	
+	if err := d.storage.Export(tmp); err != nil {
+		tmp.Close()
+		return NewErrorResponse(req.ID, "Export failed: "+err.Error())
+	}
+	if err := tmp.Close(); err != nil {
+		return NewErrorResponse(req.ID, "Export failed: "+err.Error())
+	}
+	if err := os.Rename(tmp.Name(), archivePath); err != nil {
+		return NewErrorResponse(req.ID, "Export failed: "+err.Error())
+	}
+	
+	var size int64
+	if info, err := os.Stat(archivePath); err == nil {
+		size = info.Size()
+	}
+	logger.Infof("📦 Exported store to %s (%d bytes)", archivePath, size)
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(map[string]interface{}{
+		"path": archivePath,
+		"size": size,
+	})
+	return resp
+}
+
+// handleImport restores or merges an archive written by export
+func (d *Daemon) handleImport(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	var payload struct {
+		Path  string `json:"path"`
+		Merge bool   `json:"merge,omitempty"` // Keep local data on conflicts instead of overwriting it
+	}
+	
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if payload.Path == "" {
+		return NewErrorResponse(req.ID, "path is required")
+	}
+	
+	archivePath := expandAccessRoot(payload.Path)
+	if archivePath == "" {
+		return NewErrorResponse(req.ID, "Invalid path: "+payload.Path)
+	}
+	if !d.isFileAccessAllowed(archivePath) {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Access denied: %s is outside the file access policy", archivePath))
+	}
+	
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return NewErrorResponse(req.ID, "Failed to open archive: "+err.Error())
+	}
+	defer file.Close()
+	
+	report, err := d.storage.Import(file, payload.Merge)
+	if err != nil {
+		return NewErrorResponse(req.ID, "Import failed: "+err.Error())
+	}
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(report)
+	return resp
+}
+
+// handleRebuildIndex reconstructs the session index from session objects
+func (d *Daemon) handleRebuildIndex(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	// Delegate to storage
+	report, err := d.storage.rebuildSessionIndex()
+	if err != nil {
+		return NewErrorResponse(req.ID, "Failed to rebuild session index: "+err.Error())
+	}
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(report)
+	return resp
+}
+
 // handleCreateMemory creates a new memory (session) thread
 func (d *Daemon) handleCreateMemory(req Request) Response {
 	var payload struct {
@@ -447,7 +938,10 @@ func (d *Daemon) handleCreateMemory(req Request) Response {
 	memoryID := result["memory_id"].(string)
 
 	// Create actual session
-	session := d.getOrCreateSession(memoryID, payload.Agent)
+	session, err := d.getOrCreateSession(memoryID, payload.Agent, false)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
 
 	// Add initial message if provided
 	if payload.InitialMessage != "" {
@@ -461,7 +955,7 @@ func (d *Daemon) handleCreateMemory(req Request) Response {
 
 		// Save to disk
 		if d.storage != nil {
-			d.storage.SaveSession(session)
+			d.storage.QueueSessionSave(session)
 		}
 	}
 
@@ -512,7 +1006,7 @@ func (d *Daemon) handleListPath(req Request) Response {
 	// Get directory listing
 	entries := d.listVirtualPath(path)
 	
-	log.Printf("🔍 List operation for path '%s' returned %d entries", path, len(entries))
+	logger.Debugf("🔍 List operation for path '%s' returned %d entries", path, len(entries))
 
 	resp := NewResponse(req.ID, true)
 	resp.SetData(map[string]interface{}{
@@ -525,70 +1019,256 @@ func (d *Daemon) handleListPath(req Request) Response {
 // handleReadPath reads content from a virtual path
 func (d *Daemon) handleReadPath(req Request) Response {
 	var payload struct {
-		Path string `json:"path"`
+		Path     string `json:"path"`
+		Encoding string `json:"encoding,omitempty"` // "base64" (default) or "utf8"
 	}
 
 	if err := json.Unmarshal(req.Payload, &payload); err != nil {
 		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
 	}
+	
+	encoding := strings.ToLower(payload.Encoding)
+	if encoding == "" {
+		encoding = ReadEncodingBase64
+	}
+	if encoding != ReadEncodingBase64 && encoding != ReadEncodingUTF8 {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Unsupported encoding: %s (use base64 or utf8)", payload.Encoding))
+	}
+
+	responseData, err := d.readPathData(payload.Path, encoding)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(responseData)
+	return resp
+}
 
+// readPathData resolves and reads one virtual path for read_path and
+// read_paths
+func (d *Daemon) readPathData(path, encoding string) (map[string]interface{}, error) {
 	// Track artifact access
 	if d.contextCollector != nil {
 		accessType := "artifact"
-		if strings.HasPrefix(payload.Path, "/commands/") {
+		if strings.HasPrefix(path, "/commands/") {
 			accessType = "command"
-		} else if strings.HasPrefix(payload.Path, "/tools/") {
+		} else if strings.HasPrefix(path, "/tools/") {
 			accessType = "tool"
-		} else if strings.HasPrefix(payload.Path, "/memory/") {
+		} else if strings.HasPrefix(path, "/memory/") {
 			accessType = "memory"
 		}
-		d.contextCollector.TrackMemoryAccess(payload.Path, accessType)
+		d.contextCollector.TrackMemoryAccess(path, accessType)
 	}
 
 	// Resolve path to object ID
-	objID := d.resolvePath(payload.Path)
+	objID := d.resolvePath(path)
 	if objID == "" {
-		return NewErrorResponse(req.ID, fmt.Sprintf("Path not found: %s", payload.Path))
+		return nil, fmt.Errorf("Path not found: %s", path)
 	}
-
-	// Read content
-	content, err := d.storage.Read(objID)
-	if err != nil {
-		return NewErrorResponse(req.ID, fmt.Sprintf("Failed to read content: %v", err))
+	
+	// A bare tool path reads as its definition header and source
+	if toolName, ok := toolDirName(path); ok && strings.HasPrefix(objID, "relation:") {
+		return d.readToolView(path, toolName, encoding)
 	}
 
-	// Load metadata
-	metadata, err := d.storage.LoadMetadata(objID)
+	responseData, content, err := d.readObjectData(objID, encoding, path)
 	if err != nil {
-		// Continue without metadata - it's optional
-		log.Printf("Warning: Failed to load metadata for %s: %v", objID, err)
+		return nil, err
 	}
+	responseData["path"] = path
 
-	// Prepare response data
-	responseData := map[string]interface{}{
-		"content": base64.StdEncoding.EncodeToString(content),
-		"size":    len(content),
-		"path":    payload.Path,
+	// Tool source carries its language so clients can highlight it
+	if toolName, ok := toolSourceName(path); ok {
+		responseData["language"] = d.storage.ToolLanguage(toolName, content)
+	}
+	if toolName, ok := toolPathName(path); ok {
+		if _, resolution, err := d.storage.resolveTool(toolName); err == nil {
+			responseData["tool_resolution"] = resolution
+		}
 	}
+	return responseData, nil
+}
 
-	// Add metadata if available
-	if metadata != nil {
-		responseData["metadata"] = map[string]interface{}{
-			"type":        metadata.Type,
-			"created":     metadata.Created,
-			"modified":    metadata.Modified,
+// readToolView reads /tools/{name} as the tool's view
+func (d *Daemon) readToolView(path, toolName, encoding string) (map[string]interface{}, error) {
+	view, err := d.storage.ToolView(toolName)
+	if err != nil {
+		return nil, err
+	}
+	
+	encoded := string(view.Content)
+	if encoding == ReadEncodingBase64 {
+		encoded = base64.StdEncoding.EncodeToString(view.Content)
+	}
+	
+	return map[string]interface{}{
+		"path":            path,
+		"content":         encoded,
+		"encoding":        encoding,
+		"size":            len(view.Content),
+		"mime_type":       "text/plain",
+		"view":            "tool",
+		"relation_id":     view.RelationID,
+		"language":        view.Language,
+		"tool_resolution": view.Resolution,
+	}, nil
+}
+
+// readObjectData reads an object for read_path and read_object: its
+// content in the requested encoding, size, mime type and metadata. name
+// is how errors refer to the object.
+func (d *Daemon) readObjectData(objID, encoding, name string) (map[string]interface{}, []byte, error) {
+	// Read content
+	content, err := d.storage.Read(objID)
+	if errors.Is(err, ErrObjectCorrupt) {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read content: %v", err)
+	}
+	d.storage.TouchAccessed(objID)
+
+	// Load metadata
+	metadata, err := d.storage.LoadMetadata(objID)
+	if err != nil {
+		// Continue without metadata - it's optional
+		logger.Warnf("Warning: Failed to load metadata for %s: %v", objID, err)
+	}
+	
+	// Text is only handed back as a string when it really is text
+	encoded := ""
+	if encoding == ReadEncodingUTF8 {
+		if isBinaryContent(metadata, content) {
+			return nil, nil, fmt.Errorf("%s is binary content; read it with encoding base64", name)
+		}
+		encoded = string(content)
+	} else {
+		encoded = base64.StdEncoding.EncodeToString(content)
+	}
+
+	// Prepare response data
+	responseData := map[string]interface{}{
+		"content":  encoded,
+		"encoding": encoding,
+		"size":     len(content),
+	}
+	if metadata != nil && metadata.MimeType != "" {
+		responseData["mime_type"] = metadata.MimeType
+	}
+
+	// Add metadata if available
+	if metadata != nil {
+		responseData["metadata"] = map[string]interface{}{
+			"type":        metadata.Type,
+			"created":     metadata.Created,
+			"modified":    metadata.Modified,
 			"agent":       metadata.Agent,
 			"session":     metadata.Session,
 			"title":       metadata.Title,
 			"description": metadata.Description,
+			"paths":       metadata.Paths,
 		}
 	}
+	
+	return responseData, content, nil
+}
 
+// handleReadObject reads an object by its content hash, for clients that
+// already know the ID (p42: references, resolve_path) and have no path.
+// Relation pseudo-IDs are refused: relations are read with get_relation.
+func (d *Daemon) handleReadObject(req Request) Response {
+	var payload struct {
+		ObjectID string `json:"object_id"`
+		Encoding string `json:"encoding,omitempty"` // "base64" (default) or "utf8"
+	}
+
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	objID := strings.TrimSpace(payload.ObjectID)
+	if relationID, ok := strings.CutPrefix(objID, "relation:"); ok {
+		return NewErrorResponse(req.ID, fmt.Sprintf("%s names a relation, not an object; read it with get_relation and relation_id %q", objID, relationID))
+	}
+	if !isObjectID(objID) {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Invalid object ID %q: expected %d hexadecimal characters", payload.ObjectID, objectIDLength))
+	}
+	objID = strings.ToLower(objID)
+	
+	encoding := strings.ToLower(payload.Encoding)
+	if encoding == "" {
+		encoding = ReadEncodingBase64
+	}
+	if encoding != ReadEncodingBase64 && encoding != ReadEncodingUTF8 {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Unsupported encoding: %s (use base64 or utf8)", payload.Encoding))
+	}
+	
+	if !d.storage.objects.Exists(objID) {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Object not found: %s", objID))
+	}
+	
+	responseData, _, err := d.readObjectData(objID, encoding, objID)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	responseData["object_id"] = objID
+	
 	resp := NewResponse(req.ID, true)
 	resp.SetData(responseData)
 	return resp
 }
 
+// handleResolvePath returns the object ID a virtual path resolves to. An
+// unknown path is not an error: it comes back with exists false. Paths
+// backed by a relation (tool definitions) report the relation ID and, when
+// it has one, the object holding its executable or content.
+func (d *Daemon) handleResolvePath(req Request) Response {
+	var payload struct {
+		Path string `json:"path"`
+	}
+	
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if payload.Path == "" {
+		return NewErrorResponse(req.ID, "path is required")
+	}
+	
+	data := map[string]interface{}{
+		"path":      payload.Path,
+		"object_id": "",
+		"exists":    false,
+	}
+	
+	objID := d.resolvePath(payload.Path)
+	if relationID, ok := strings.CutPrefix(objID, "relation:"); ok {
+		data["relation_id"] = relationID
+		objID = ""
+		if d.storage.relationStore != nil {
+			if relation, err := d.storage.relationStore.Load(relationID); err == nil {
+				data["exists"] = true
+				for _, key := range []string{"executable_id", "content_id"} {
+					if id, _ := relation.Properties[key].(string); id != "" {
+						objID = id
+						break
+					}
+				}
+			}
+		}
+	} else if objID != "" {
+		data["exists"] = true
+	}
+	data["object_id"] = objID
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(data)
+	return resp
+}
+
 // handleGetMetadata retrieves enriched metadata for a virtual path
 func (d *Daemon) handleGetMetadata(req Request) Response {
 	var payload struct {
@@ -691,6 +1371,19 @@ func (d *Daemon) handleGetMetadata(req Request) Response {
 			d.mu.RUnlock()
 		}
 	}
+	
+	// A tool's executable comes from the same place as the tool
+	responseData["source"] = SourceUnknown
+	responseData["user_prompt"] = ""
+	if toolName := toolNameForExecutablePath(payload.Path); toolName != "" {
+		if tool, err := d.storage.findToolRelation(toolName); err == nil {
+			for key, value := range relationProvenance(*tool) {
+				if value != "" || responseData[key] == "" {
+					responseData[key] = value
+				}
+			}
+		}
+	}
 
 	resp := NewResponse(req.ID, true)
 	resp.SetData(responseData)
@@ -715,11 +1408,13 @@ func (d *Daemon) handleSearch(req Request) Response {
 	}
 
 	// Perform search with mode
-	results, err := d.storage.SearchObjects(payload.Query, payload.Mode, payload.Filters)
+	results, total, err := d.storage.SearchObjectsPage(payload.Query, payload.Mode, payload.Filters)
 	if err != nil {
 		return NewErrorResponse(req.ID, fmt.Sprintf("Search failed: %v", err))
 	}
 
+	// count is the size of this page; total counts every match, so
+	// offset+count < total means there are more pages
 	resp := NewResponse(req.ID, true)
 	resp.SetData(map[string]interface{}{
 		"query":   payload.Query,
@@ -727,6 +1422,8 @@ func (d *Daemon) handleSearch(req Request) Response {
 		"filters": payload.Filters,
 		"results": results,
 		"count":   len(results),
+		"total":   total,
+		"offset":  payload.Filters.Offset,
 	})
 	return resp
 }
@@ -782,6 +1479,301 @@ func (d *Daemon) handleGetLastSession(req Request) Response {
 	return resp
 }
 
+// handleStorageStats returns object store statistics including chunk dedup savings
+func (d *Daemon) handleStorageStats(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	data := map[string]interface{}{
+		"stats": d.storage.GetStats(),
+		"dedup": d.storage.GetDedupStats(),
+	}
+	if fileStore, ok := d.storage.relationStore.(*FileRelationStore); ok {
+		data["relation_cache"] = fileStore.ListCacheStats()
+	}
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(data)
+	return resp
+}
+
+// handleRestoreVersion makes an earlier version of a tool's executable active
+func (d *Daemon) handleRestoreVersion(req Request) Response {
+	var payload struct {
+		Tool    string `json:"tool"`
+		Version string `json:"version"` // v2, 2, or an object ID prefix
+	}
+	
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if payload.Tool == "" {
+		return NewErrorResponse(req.ID, "tool is required")
+	}
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	// Delegate to storage
+	result, err := d.storage.RestoreToolVersion(payload.Tool, payload.Version)
+	if err != nil {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Restore failed: %v", err))
+	}
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(result)
+	return resp
+}
+
+// handleAuditTools reports tools that were never validated or run, or whose last run failed
+func (d *Daemon) handleAuditTools(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	// Delegate to storage
+	result, err := d.storage.AuditTools()
+	if err != nil {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Audit failed: %v", err))
+	}
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(result)
+	return resp
+}
+
+// handleExecution records a run of a generated command
+func (d *Daemon) handleExecution(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+	
+	var payload struct {
+		Tool       string `json:"tool"`
+		Status     string `json:"status,omitempty"` // Defaults to success
+		ExitCode   int    `json:"exit_code,omitempty"`
+		DurationMs int64  `json:"duration_ms,omitempty"`
+	}
+	
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	
+	status := payload.Status
+	if status == "" {
+		status = "success"
+		if payload.ExitCode != 0 {
+			status = "failed"
+		}
+	}
+	
+	// Delegate to storage
+	result, err := d.storage.RecordExecution(ToolRun{
+		Tool:       payload.Tool,
+		Status:     status,
+		ExitCode:   payload.ExitCode,
+		DurationMs: payload.DurationMs,
+	})
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	
+	resp := NewResponse(req.ID, true)
+	resp.SetData(result)
+	return resp
+}
+
+// handleListAgents returns the configured agents with model and last-session info
+func (d *Daemon) handleListAgents(req Request) Response {
+	resp := NewResponse(req.ID, true)
+	
+	if agentConfig == nil {
+		resp.SetError("Agent configuration not loaded")
+		return resp
+	}
+	
+	// Sort keys so clients get a stable ordering
+	keys := make([]string, 0, len(agentConfig.Agents))
+	for key := range agentConfig.Agents {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	agents := make([]AgentInfo, 0, len(keys))
+	for _, key := range keys {
+		agent := agentConfig.Agents[key]
+		
+		modelKey := agent.Model
+		if modelKey == "" {
+			modelKey = agentConfig.DefaultModel
+		}
+		
+		info := AgentInfo{
+			Key:                 key,
+			Name:                agent.Name,
+			Description:         agent.Description,
+			Personality:         agent.Personality,
+			Style:               agent.Style,
+			ModelKey:            modelKey,
+			TemperatureOverride: agent.TemperatureOverride,
+			HasCustomPrompt:     agent.CustomPrompt != "",
+		}
+		
+		if model, err := GetModelForAgent(key); err == nil {
+			info.Model = model
+		}
+		
+		info.LastSession = d.lastSessionForAgent(agent.Name)
+		agents = append(agents, info)
+	}
+	
+	resp.SetData(ListAgentsData{
+		Agents:       agents,
+		DefaultModel: agentConfig.DefaultModel,
+		GuidanceFile: agentConfig.GuidanceFile,
+		HasGuidance:  agentConfig.LoadedGuidance != "",
+	})
+	return resp
+}
+
+// lastSessionForAgent looks up the agent's most recent session, falling back to agent_sessions.json
+func (d *Daemon) lastSessionForAgent(agentName string) *AgentLastSession {
+	if d.storage == nil || agentName == "" {
+		return nil
+	}
+	
+	agent := strings.TrimPrefix(agentName, "@")
+	sessionID, err := d.storage.GetLastSession(agent)
+	if err != nil {
+		if d.storage.agentSessions == nil {
+			return nil
+		}
+		id, exists := d.storage.agentSessions.GetLastSession(agent)
+		if !exists {
+			return nil
+		}
+		sessionID = id
+	}
+	
+	// Prefer the in-memory session if it is still live
+	d.mu.RLock()
+	live, exists := d.sessions[sessionID]
+	d.mu.RUnlock()
+	if exists {
+		live.mu.Lock()
+		defer live.mu.Unlock()
+		return &AgentLastSession{
+			SessionID:    live.ID,
+			State:        string(live.State),
+			LastActivity: live.LastActivity.Format(time.RFC3339),
+			MessageCount: len(live.Messages),
+		}
+	}
+	
+	session, err := d.storage.LoadSession(sessionID)
+	if err != nil {
+		return &AgentLastSession{SessionID: sessionID}
+	}
+	
+	return &AgentLastSession{
+		SessionID:    session.ID,
+		State:        string(session.State),
+		LastActivity: session.LastActivity.Format(time.RFC3339),
+		MessageCount: len(session.Messages),
+	}
+}
+
+// handleReassignSession moves a session to a different agent
+func (d *Daemon) handleReassignSession(req Request) Response {
+	resp := NewResponse(req.ID, true)
+	
+	var payload struct {
+		SessionID string `json:"session_id"`
+		Agent     string `json:"agent"`
+		Force     bool   `json:"force,omitempty"`
+	}
+	
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		resp.SetError("Invalid payload: " + err.Error())
+		return resp
+	}
+	
+	if payload.SessionID == "" || payload.Agent == "" {
+		resp.SetError("session_id and agent parameters required")
+		return resp
+	}
+	
+	if d.storage == nil {
+		resp.SetError("Storage not initialized")
+		return resp
+	}
+	
+	// Sessions carry the display form, e.g. @ai-engineer
+	newAgent := payload.Agent
+	if !strings.HasPrefix(newAgent, "@") {
+		newAgent = "@" + newAgent
+	}
+	if agentConfig != nil && !IsKnownAgent(newAgent) {
+		resp.SetError(fmt.Sprintf("Unknown agent: %s (known: %s)", payload.Agent, strings.Join(KnownAgentNames(), ", ")))
+		return resp
+	}
+	
+	// Prefer the live session so in-flight state isn't lost
+	d.mu.RLock()
+	session, live := d.sessions[payload.SessionID]
+	d.mu.RUnlock()
+	if !live {
+		loaded, err := d.storage.LoadSession(payload.SessionID)
+		if err != nil {
+			resp.SetError(fmt.Sprintf("Session not found: %s", payload.SessionID))
+			return resp
+		}
+		session = loaded
+	}
+	
+	session.mu.Lock()
+	if session.State == SessionCompleted && !payload.Force {
+		session.mu.Unlock()
+		resp.SetError(fmt.Sprintf("Session %s is completed; use force to reassign", payload.SessionID))
+		return resp
+	}
+	
+	oldAgent := session.Agent
+	if oldAgent == newAgent {
+		session.mu.Unlock()
+		resp.SetError(fmt.Sprintf("Session %s already belongs to %s", payload.SessionID, newAgent))
+		return resp
+	}
+	session.Agent = newAgent
+	
+	// Save under the new agent, holding the lock so a concurrent possess
+	// can't append to the transcript mid-save, then fix up tracking and
+	// derived paths
+	err := d.storage.SaveSession(session)
+	state := session.State
+	session.mu.Unlock()
+	if err != nil {
+		resp.SetError(fmt.Sprintf("Failed to save session: %v", err))
+		return resp
+	}
+	
+	paths, err := d.storage.ReassignSessionAgent(session.ID, oldAgent, newAgent)
+	if err != nil {
+		resp.SetError(fmt.Sprintf("Failed to update session paths: %v", err))
+		return resp
+	}
+	
+	resp.SetData(map[string]interface{}{
+		"session_id": session.ID,
+		"old_agent":  oldAgent,
+		"agent":      newAgent,
+		"state":      state,
+		"paths":      paths,
+	})
+	return resp
+}
+
 // handleGetContext returns current session context information
 func (d *Daemon) handleGetContext(req Request) Response {
 	resp := NewResponse(req.ID, true)
@@ -824,54 +1816,116 @@ func (d *Daemon) listVirtualPath(path string) []map[string]interface{} {
 	return d.storage.ListPathWithActiveSessions(path, d.sessions)
 }
 
+// lastSessionFor returns the agent's most recently active session. Sessions
+// still in memory are newer than any saved one that left memory, so the
+// session index is only consulted when the agent has none in memory.
+func (d *Daemon) lastSessionFor(agent string) (string, bool) {
+	agent = strings.TrimPrefix(agent, "@")
+	
+	lastID := ""
+	var lastActivity time.Time
+	d.mu.RLock()
+	for id, session := range d.sessions {
+		session.mu.Lock()
+		if strings.TrimPrefix(session.Agent, "@") == agent && session.LastActivity.After(lastActivity) {
+			lastID, lastActivity = id, session.LastActivity
+		}
+		session.mu.Unlock()
+	}
+	d.mu.RUnlock()
+	if lastID != "" {
+		return lastID, true
+	}
+	
+	if d.storage == nil {
+		return "", false
+	}
+	sessionID, err := d.storage.GetLastSession(agent)
+	if err != nil {
+		return "", false
+	}
+	return sessionID, true
+}
+
 // Session management methods
-func (d *Daemon) getOrCreateSession(sessionID, agent string) *Session {
+//
+// getOrCreateSession returns the session a message is about to be added to.
+// Idle sessions become active again; completed and abandoned ones do only
+// when reopen is set, and otherwise fail with ErrSessionClosed, so an ended
+// conversation is never revived by accident. LastActivity is left as it
+// was, for the caller to bump when the message is actually added.
+func (d *Daemon) getOrCreateSession(sessionID, agent string, reopen bool) (*Session, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	
 	// Step 1: Check in-memory sessions
 	if session, exists := d.sessions[sessionID]; exists {
-		// Update last activity
-		session.LastActivity = time.Now()
-		if session.State == SessionIdle {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		if sessionClosed(session.State) {
+			if !reopen {
+				return nil, fmt.Errorf("%w: session %s is %s; send \"reopen\": true to continue it", ErrSessionClosed, sessionID, session.State)
+			}
+			logger.Infof("🔄 Session %s reopened from %s", sessionID, session.State)
+			session.State = SessionActive
+		} else if session.State == SessionIdle {
 			session.State = SessionActive
-			log.Printf("🔄 Session %s reactivated from memory", sessionID)
+			logger.Infof("🔄 Session %s reactivated from memory", sessionID)
 		}
-		return session
+		return session, nil
 	}
 	
 	// Step 2: Check on disk (NEW)
+	var persistedSession *Session
 	if d.storage != nil {
-		if persistedSession, err := d.storage.LoadSession(sessionID); err == nil {
-			// Convert from PersistentSession to Session
-			session := &Session{
-				ID:               persistedSession.ID,
-				Agent:            persistedSession.Agent,
-				CreatedAt:        persistedSession.CreatedAt,
-				LastActivity:     time.Now(), // Update to current time
-				State:            SessionActive, // Reactivate session
-				Messages:         persistedSession.Messages,
-				CommandGenerated: nil,
-				IdleTimeout:      30 * time.Minute,
+		if loaded, err := d.storage.LoadSession(sessionID); err == nil {
+			if sessionClosed(loaded.State) && !reopen {
+				return nil, fmt.Errorf("%w: session %s is %s; send \"reopen\": true to continue it", ErrSessionClosed, sessionID, loaded.State)
 			}
-			
-			// Convert command info if exists
-			if persistedSession.CommandGenerated != nil {
-				// Note: CommandGenerationInfo only stores basic info (name, path, created_at)
-				// The full CommandSpec is not persisted, just tracking that a command was generated
-				session.CommandGenerated = &CommandSpec{
-					Name: persistedSession.CommandGenerated.Name,
-					// Other fields would need to be loaded from the actual command file if needed
-				}
+			persistedSession = loaded
+		}
+	}
+	
+	// Restoring or creating adds to the map, so make room first
+	if err := d.makeRoomForSessionLocked(); err != nil {
+		return nil, err
+	}
+	
+	if persistedSession != nil {
+		if sessionClosed(persistedSession.State) {
+			logger.Infof("🔄 Session %s reopened from %s", sessionID, persistedSession.State)
+		}
+		
+		// Convert from PersistentSession to Session
+		session := &Session{
+			ID:               persistedSession.ID,
+			Agent:            persistedSession.Agent,
+			CreatedAt:        persistedSession.CreatedAt,
+			LastActivity:     persistedSession.LastActivity, // Kept until a message is added
+			State:            SessionActive, // Reactivate session
+			Messages:         persistedSession.Messages,
+			CommandGenerated: nil,
+			IdleTimeout:      d.config.IdleTimeout,
+			ForkedFrom:       persistedSession.ForkedFrom,
+			ForkPoint:        persistedSession.ForkPoint,
+		}
+		
+		// Convert command info if exists
+		if persistedSession.CommandGenerated != nil {
+			// Note: CommandGenerationInfo only stores basic info (name, path, created_at)
+			// The full CommandSpec is not persisted, just tracking that a command was generated
+			session.CommandGenerated = &CommandSpec{
+				Name: persistedSession.CommandGenerated.Name,
+				// Other fields would need to be loaded from the actual command file if needed
 			}
-			
-			// Add to active sessions
-			d.sessions[sessionID] = session
-			
-			log.Printf("🔄 Session %s restored from disk (%d messages)", 
-				sessionID, len(session.Messages))
-			return session
 		}
+		
+		// Add to active sessions
+		d.sessions[sessionID] = session
+		
+		logger.Infof("🔄 Session %s restored from disk (%d messages)", 
+			sessionID, len(session.Messages))
+		return session, nil
 	}
 	
 	// Step 3: Create new session (existing logic)
@@ -883,32 +1937,84 @@ func (d *Daemon) getOrCreateSession(sessionID, agent string) *Session {
 		LastActivity: now,
 		State:        SessionActive,
 		Messages:     []Message{},
-		IdleTimeout:  30 * time.Minute, // Default 30 minutes
+		IdleTimeout:  d.config.IdleTimeout,
 	}
 	
 	d.sessions[sessionID] = session
-	log.Printf("📊 Session added to map. Current map size: %d", len(d.sessions))
+	logger.Debugf("📊 Session added to map. Current map size: %d", len(d.sessions))
 	
 	// CRITICAL: Must return session from this function
 	// The mutex is still held here and will be released by defer
 	
 	// Save new session to disk
-	log.Printf("🔍 Memory store check: memoryStore != nil: %v", d.storage != nil)
+	logger.Debugf("🔍 Memory store check: memoryStore != nil: %v", d.storage != nil)
 	if d.storage != nil {
-		log.Printf("🔍 [NEW_SESSION] Saving newly created session %s", sessionID)
-		go func() {
-			if err := d.storage.SaveSession(session); err != nil {
-				log.Printf("❌ Failed to save new session: %v", err)
-			} else {
-				log.Printf("✅ Successfully saved session %s", sessionID)
-			}
-		}()
+		logger.Debugf("🔍 [NEW_SESSION] Saving newly created session %s", sessionID)
+		go d.storage.saveQueuedSession(session)
 	} else {
-		log.Printf("⚠️  Memory store is nil, skipping save")
+		logger.Warnf("⚠️  Memory store is nil, skipping save")
 	}
 	
-	log.Printf("✨ New session created: %s with agent %s", sessionID, agent)
-	return session
+	logger.Infof("✨ New session created: %s with agent %s", sessionID, agent)
+	d.events.Publish(WatchMemory, WatchData{
+		Type:      "memory_created",
+		SessionID: sessionID,
+		Agent:     agent,
+		Path:      "/memory/" + sessionID,
+	})
+	return session, nil
+}
+
+// ErrSessionLimit is returned when MaxSessions sessions are in memory and
+// all of them are active
+var ErrSessionLimit = errors.New("session limit reached")
+
+// ErrSessionClosed is returned when continuing a completed or abandoned
+// session without reopen. Its text is the error code clients see.
+var ErrSessionClosed = errors.New("SESSION_CLOSED")
+
+// sessionClosed reports whether a session has ended, so continuing it
+// needs an explicit reopen
+func sessionClosed(state SessionState) bool {
+	return state == SessionCompleted || state == SessionAbandoned
+}
+
+// makeRoomForSessionLocked evicts the least recently active idle, completed
+// or abandoned session when the map is at MaxSessions. Evicted sessions are
+// persisted first and can be restored from disk later. Caller holds d.mu.
+func (d *Daemon) makeRoomForSessionLocked() error {
+	if d.config.MaxSessions <= 0 {
+		return nil
+	}
+	
+	for len(d.sessions) >= d.config.MaxSessions {
+		var oldest *Session
+		for _, session := range d.sessions {
+			session.mu.Lock()
+			evictable := session.State != SessionActive
+			if evictable && (oldest == nil || session.LastActivity.Before(oldest.LastActivity)) {
+				oldest = session
+			}
+			session.mu.Unlock()
+		}
+		if oldest == nil {
+			return fmt.Errorf("%w: %d of %d sessions are active; try again once one goes idle",
+				ErrSessionLimit, len(d.sessions), d.config.MaxSessions)
+		}
+		
+		if d.storage != nil {
+			oldest.mu.Lock()
+			err := d.storage.SaveSession(oldest)
+			oldest.mu.Unlock()
+			if err != nil {
+				return fmt.Errorf("failed to persist session %s before eviction: %v", oldest.ID, err)
+			}
+		}
+		delete(d.sessions, oldest.ID)
+		d.sessionEvictions++
+		logger.Infof("📤 Evicted %s session %s to stay under %d sessions", oldest.State, oldest.ID, d.config.MaxSessions)
+	}
+	return nil
 }
 
 func (d *Daemon) getSession(sessionID string) (*Session, bool) {
@@ -921,9 +2027,9 @@ func (d *Daemon) getSession(sessionID string) (*Session, bool) {
 
 // loadRecentSessions loads active/idle sessions from disk on startup
 func (d *Daemon) loadRecentSessions() {
-	sessions, err := d.storage.LoadRecentSessions(1) // Last 24 hours
+	sessions, err := d.storage.LoadRecentSessions(1, "") // Last 24 hours
 	if err != nil {
-		log.Printf("Failed to load recent sessions: %v", err)
+		logger.Warnf("Failed to load recent sessions: %v", err)
 		return
 	}
 	
@@ -942,7 +2048,9 @@ func (d *Daemon) loadRecentSessions() {
 				State:            ps.State,
 				Messages:         ps.Messages,
 				CommandGenerated: nil,
-				IdleTimeout:      30 * time.Minute,
+				IdleTimeout:      d.config.IdleTimeout,
+				ForkedFrom:       ps.ForkedFrom,
+				ForkPoint:        ps.ForkPoint,
 			}
 			
 			// Convert command info if exists
@@ -961,7 +2069,7 @@ func (d *Daemon) loadRecentSessions() {
 	}
 	
 	if loaded > 0 {
-		log.Printf("📚 Loaded %d sessions from disk", loaded)
+		logger.Infof("📚 Loaded %d sessions from disk", loaded)
 	}
 }
 
@@ -971,7 +2079,7 @@ func (d *Daemon) endSession(sessionID string) {
 	
 	if session, exists := d.sessions[sessionID]; exists {
 		session.State = SessionCompleted
-		log.Printf("◊ Session ended: %s", sessionID)
+		logger.Infof("◊ Session ended: %s", sessionID)
 	}
 }
 
@@ -998,23 +2106,23 @@ func (d *Daemon) cleanupSessions() {
 					// Check if session should go idle
 					if timeSinceActivity > session.IdleTimeout {
 						session.State = SessionIdle
-						log.Printf("⏸️  Session %s is now idle (no activity for %v)", id, session.IdleTimeout)
+						logger.Infof("⏸️  Session %s is now idle (no activity for %v)", id, session.IdleTimeout)
 						
-						// Save idle state to disk
+						// Save idle state to disk, once this lock is released
 						if d.storage != nil {
-							go d.storage.SaveSession(session)
+							go d.storage.saveQueuedSession(session)
 						}
 					}
 					
 				case SessionIdle:
-					// Check if session should be abandoned (2x idle timeout)
-					if timeSinceActivity > session.IdleTimeout*2 {
+					// Check if session should be abandoned (AbandonMultiplier x idle timeout)
+					if timeSinceActivity > abandonAfter(session.IdleTimeout, d.config.AbandonMultiplier) {
 						session.State = SessionAbandoned
-						log.Printf("🚪 Session %s abandoned (idle for %v)", id, timeSinceActivity)
+						logger.Infof("🚪 Session %s abandoned (idle for %v)", id, timeSinceActivity)
 						
 						// Save final state and remove from memory
 						if d.storage != nil {
-							go d.storage.SaveSession(session)
+							go d.storage.saveQueuedSession(session)
 						}
 						delete(d.sessions, id)
 					}
@@ -1034,7 +2142,7 @@ func (d *Daemon) cleanupSessions() {
 			d.mu.RLock()
 			for _, session := range d.sessions {
 				if d.storage != nil && (session.State == SessionActive || session.State == SessionIdle) {
-					d.storage.SaveSession(session)
+					d.storage.saveQueuedSession(session)
 				}
 			}
 			d.mu.RUnlock()
@@ -1048,7 +2156,7 @@ func (d *Daemon) handleStatus(req Request) Response {
 	resp := NewResponse(req.ID, true)
 	
 	// Debug logging to see what port is stored
-	log.Printf("DEBUG: handleStatus called, d.config.Port = '%s'", d.config.Port)
+	logger.Debugf("DEBUG: handleStatus called, d.config.Port = '%s'", d.config.Port)
 	
 	uptime := time.Since(startTime).Round(time.Second).String()
 	
@@ -1059,6 +2167,7 @@ func (d *Daemon) handleStatus(req Request) Response {
 			activeSessions++
 		}
 	}
+	sessionEvictions := d.sessionEvictions
 	d.mu.RUnlock()
 	
 	// Get rule engine status
@@ -1083,19 +2192,33 @@ func (d *Daemon) handleStatus(req Request) Response {
 
 	status := StatusData{
 		Status:    "swimming",
+		ProtocolVersion:    CurrentProtocolVersion,
+		MinProtocolVersion: ProtocolV1,
 		Port:      d.config.Port,
 		Sessions:  activeSessions,
 		Uptime:    uptime,
 		Dolphins:  "🐬🐬🐬 laughing in the digital waves",
 		RuleCount: ruleCount,
 		Rules:     rulesStatus,
+		MaxSessions:      d.config.MaxSessions,
+		SessionEvictions: sessionEvictions,
+	}
+	
+	if d.storage != nil {
+		stats := d.storage.GetStats()
+		status.StorageSize = stats.StorageSize
+		status.ObjectBytes = stats.ObjectBytes
+		status.MetadataBytes = stats.MetadataBytes
+		status.UnsavedSessions = d.storage.UnsavedSessions()
 	}
+	status.PersistenceOK = len(status.UnsavedSessions) == 0
 	
 	resp.SetData(status)
 	return resp
 }
 
-// handleWatch handles watch requests for real-time monitoring
+// handleWatch handles one-shot watch requests. Watches with "stream": true
+// are taken over by streamWatch in handleConnection before they get here.
 func (d *Daemon) handleWatch(req Request) Response {
 	// Parse the watch payload
 	var payload WatchPayload
@@ -1103,57 +2226,28 @@ func (d *Daemon) handleWatch(req Request) Response {
 		return NewErrorResponse(req.ID, fmt.Sprintf("Invalid watch payload: %v", err))
 	}
 	
-	resp := NewResponse(req.ID, true)
-	
 	// Handle different watch targets
 	switch payload.Target {
-	case "rules":
+	case WatchRules:
 		return d.handleWatchRules(req)
+	case WatchRelations, WatchTools, WatchMemory:
+		return NewErrorResponse(req.ID, fmt.Sprintf("Watch target %s only streams events; set \"stream\": true with \"protocol_version\": %d", payload.Target, ProtocolV2))
 	default:
 		return NewErrorResponse(req.ID, fmt.Sprintf("Unsupported watch target: %s", payload.Target))
 	}
-	
-	return resp
 }
 
-// handleWatchRules provides real-time rule engine activity monitoring
+// handleWatchRules returns a snapshot of rule engine status
 func (d *Daemon) handleWatchRules(req Request) Response {
 	resp := NewResponse(req.ID, true)
 	
-	// For now, return current rule status since we don't have streaming yet
-	// This would be enhanced to stream real-time events in a full implementation
+	snapshot := ruleStatusSnapshot(d.realityCompiler)
 	if d.realityCompiler == nil || d.realityCompiler.ruleEngine == nil {
-		data := WatchData{
-			Timestamp: time.Now().Format(time.RFC3339),
-			Type:      "status",
-			RuleID:    "system",
-			RuleName:  "Rule Engine Status",
-			Details:   "Rule engine not initialized",
-		}
-		resp.SetData(data)
+		resp.SetData(snapshot[0])
 		return resp
 	}
 	
-	rules := d.realityCompiler.ruleEngine.ListRules()
-	var watchData []WatchData
-	
-	for _, rule := range rules {
-		status := "enabled"
-		if !rule.Enabled {
-			status = "disabled"
-		}
-		
-		data := WatchData{
-			Timestamp: time.Now().Format(time.RFC3339),
-			Type:      "rule_status",
-			RuleID:    rule.ID,
-			RuleName:  rule.Name,
-			Details:   fmt.Sprintf("Status: %s, Description: %s", status, rule.Description),
-		}
-		watchData = append(watchData, data)
-	}
-	
-	resp.SetData(watchData)
+	resp.SetData(snapshot)
 	return resp
 }
 
@@ -1186,12 +2280,50 @@ func (d *Daemon) handleList(req Request) Response {
 	
 	list := ListData{
 		Commands: commands,
+		Details:  d.commandDetails(commands),
 	}
 	
 	resp.SetData(list)
 	return resp
 }
 
+// commandDetails describes each command from the Tool relation of the same
+// name, loading the relation store once for the whole listing
+func (d *Daemon) commandDetails(commands []string) []CommandInfo {
+	tools := make(map[string]Relation)
+	if d.realityCompiler != nil {
+		if relations, err := d.realityCompiler.GetRelationStore().LoadByType("Tool"); err == nil {
+			for _, relation := range relations {
+				name := getRelationName(relation)
+				// A regenerated name belongs to its newest relation
+				if existing, ok := tools[name]; !ok || relation.UpdatedAt.After(existing.UpdatedAt) {
+					tools[name] = relation
+				}
+			}
+		} else {
+			logger.Warnf("⚠️ Failed to load tools for list: %v", err)
+		}
+	}
+	
+	details := make([]CommandInfo, 0, len(commands))
+	for _, name := range commands {
+		info := CommandInfo{Name: name}
+		if relation, ok := tools[name]; ok {
+			info.RelationID = relation.ID
+			info.Description = getStringProperty(relation.Properties, "description")
+			info.Language = getStringProperty(relation.Properties, "language")
+			info.Agent = getStringProperty(relation.Properties, "agent")
+			info.Transforms = getTransforms(relation)
+			if !relation.CreatedAt.IsZero() {
+				created := relation.CreatedAt
+				info.Created = &created
+			}
+		}
+		details = append(details, info)
+	}
+	return details
+}
+
 func (d *Daemon) handleMemory(req Request) Response {
 	resp := NewResponse(req.ID, true)
 	
@@ -1199,32 +2331,45 @@ func (d *Daemon) handleMemory(req Request) Response {
 	var payload struct {
 		SessionID      string `json:"session_id,omitempty"`
 		IncludeContent bool   `json:"include_content,omitempty"`
+		Agent          string `json:"agent,omitempty"` // Only this agent's sessions, with or without its @
+		Days           int    `json:"days,omitempty"`  // How far back recent sessions go (default 7)
 	}
 	
-	log.Printf("🔍 [DEBUG] Memory endpoint - request ID: %s", req.ID)
-	log.Printf("🔍 [DEBUG] Memory endpoint - payload: %s", string(req.Payload))
+	logger.Debugf("🔍 [DEBUG] Memory endpoint - request ID: %s", req.ID)
+	logger.Debugf("🔍 [DEBUG] Memory endpoint - payload: %s", string(req.Payload))
 	
 	if req.Payload != nil && len(req.Payload) > 0 {
 		if err := json.Unmarshal(req.Payload, &payload); err == nil && payload.SessionID != "" {
-			log.Printf("🔍 [DEBUG] Memory endpoint - parsed session_id: %s, include_content: %t", payload.SessionID, payload.IncludeContent)
+			logger.Debugf("🔍 [DEBUG] Memory endpoint - parsed session_id: %s, include_content: %t", payload.SessionID, payload.IncludeContent)
 			// Handle specific session request
 			return d.handleMemoryShow(req, payload.SessionID)
 		} else if err != nil {
-			log.Printf("🔍 [DEBUG] Memory endpoint - payload unmarshal error: %v", err)
+			logger.Debugf("🔍 [DEBUG] Memory endpoint - payload unmarshal error: %v", err)
 		}
 	}
 	
-	// Handle list all sessions
+	// Handle list all sessions, or one agent's
+	if payload.Days < 0 {
+		return NewErrorResponse(req.ID, fmt.Sprintf("days must be positive, got %d", payload.Days))
+	}
+	days := payload.Days
+	if days == 0 {
+		days = defaultRecentSessionDays
+	}
+	agent := strings.TrimPrefix(strings.TrimSpace(payload.Agent), "@")
 	d.mu.RLock()
-	log.Printf("🔍 Memory endpoint: Current map size: %d", len(d.sessions))
-	log.Printf("🔍 Session IDs in map:")
+	logger.Debugf("🔍 Memory endpoint: Current map size: %d", len(d.sessions))
+	logger.Debugf("🔍 Session IDs in map:")
 	for id := range d.sessions {
-		log.Printf("   - %s", id)
+		logger.Debugf("   - %s", id)
 	}
 	
 	// Create summaries for active sessions
 	activeSummaries := make([]SessionSummary, 0, len(d.sessions))
 	for _, session := range d.sessions {
+		if agent != "" && strings.TrimPrefix(session.Agent, "@") != agent {
+			continue
+		}
 		activeSummaries = append(activeSummaries, SessionSummary{
 			ID:           session.ID,
 			Agent:        session.Agent,
@@ -1241,8 +2386,8 @@ func (d *Daemon) handleMemory(req Request) Response {
 	var stats *MemoryStats
 	
 	if d.storage != nil {
-		// Load last 7 days of sessions
-		if sessions, err := d.storage.LoadRecentSessions(7); err == nil {
+		// Load the last days of sessions
+		if sessions, err := d.storage.LoadRecentSessions(days, agent); err == nil {
 			// Convert to summaries
 			recentSummaries = make([]SessionSummary, 0, len(sessions))
 			for _, ps := range sessions {
@@ -1265,253 +2410,674 @@ func (d *Daemon) handleMemory(req Request) Response {
 			LastSessionTime:   sStats.LastUpdated,
 		}
 	}
-	
-	data := map[string]interface{}{
-		"active_sessions": activeSummaries,
-		"active_count":    len(activeSummaries),
-		"recent_sessions": recentSummaries,
-		"stats":           stats,
-		"uptime":          time.Since(startTime).String(),
+	
+	data := map[string]interface{}{
+		"active_sessions": activeSummaries,
+		"active_count":    len(activeSummaries),
+		"recent_sessions": recentSummaries,
+		"recent_by_date":  groupSessionsByDate(recentSummaries),
+		"days":            days,
+		"stats":           stats,
+		"uptime":          time.Since(startTime).String(),
+	}
+	if agent != "" {
+		data["agent"] = "@" + agent
+	}
+	
+	resp.SetData(data)
+	return resp
+}
+
+func (d *Daemon) handleEnd(req Request) Response {
+	resp := NewResponse(req.ID, true)
+	
+	d.endSession(req.ID)
+	
+	data := map[string]string{
+		"message": "Session crystallized. The dolphins remember...",
+	}
+	
+	resp.SetData(data)
+	return resp
+}
+
+// handleMemoryShow returns full details for a specific session
+func (d *Daemon) handleMemoryShow(req Request, sessionID string) Response {
+	resp := NewResponse(req.ID, true)
+	
+	logger.Debugf("🔍 [DEBUG] handleMemoryShow - looking for session: %s", sessionID)
+	
+	// Track memory access
+	if d.contextCollector != nil {
+		memoryPath := fmt.Sprintf("/memory/%s", sessionID)
+		d.contextCollector.TrackMemoryAccess(memoryPath, "session")
+	}
+	
+	// First check in-memory sessions
+	d.mu.RLock()
+	if session, exists := d.sessions[sessionID]; exists {
+		d.mu.RUnlock()
+		
+		logger.Debugf("🔍 [DEBUG] handleMemoryShow - found session in memory, messages: %d", len(session.Messages))
+		
+		data := map[string]interface{}{
+			"id":           session.ID,
+			"agent":        session.Agent,
+			"state":        session.State,
+			"created_at":   session.CreatedAt,
+			"last_activity": session.LastActivity,
+			"messages":     session.Messages,
+			"command_generated": session.CommandGenerated,
+		}
+		resp.SetData(data)
+		
+		logger.Debugf("🔍 [DEBUG] handleMemoryShow - returning data with %d messages", len(session.Messages))
+		return resp
+	}
+	d.mu.RUnlock()
+	
+	logger.Debugf("🔍 [DEBUG] handleMemoryShow - session not in memory, checking storage")
+	
+	// Try to load from disk
+	if d.storage != nil {
+		if session, err := d.storage.LoadSession(sessionID); err == nil {
+			logger.Debugf("🔍 [DEBUG] handleMemoryShow - found session on disk, messages: %d", len(session.Messages))
+			
+			data := map[string]interface{}{
+				"id":           session.ID,
+				"agent":        session.Agent,
+				"state":        session.State,
+				"created_at":   session.CreatedAt,
+				"last_activity": session.LastActivity,
+				"messages":     session.Messages,
+				"command_generated": session.CommandGenerated,
+			}
+			resp.SetData(data)
+			
+			logger.Debugf("🔍 [DEBUG] handleMemoryShow - returning data from disk with %d messages", len(session.Messages))
+			return resp
+		} else {
+			logger.Debugf("🔍 [DEBUG] handleMemoryShow - failed to load from storage: %v", err)
+		}
+	} else {
+		logger.Debugf("🔍 [DEBUG] handleMemoryShow - no storage available")
+	}
+	
+	// Session not found
+	logger.Debugf("🔍 [DEBUG] handleMemoryShow - session not found: %s", sessionID)
+	resp.SetError(fmt.Sprintf("Session '%s' not found", sessionID))
+	return resp
+}
+
+// Reality Compiler handlers
+
+// handleDeclareRelation declares a new relation and materializes it
+func (d *Daemon) handleDeclareRelation(req Request) Response {
+	resp := NewResponse(req.ID, true)
+	
+	// Step 5: Early validation - fail fast with helpful messages
+	if errMsg := d.validateDeclareRequest(req); errMsg != "" {
+		resp.SetError(errMsg)
+		return resp
+	}
+	
+	// Parse relation from payload
+	var payload struct {
+		Relation Relation `json:"relation"`
+		Explain  bool     `json:"explain"` // Return the final generation prompt
+		DryRun   bool     `json:"dry_run"` // Generate and return the tool without storing anything
+	}
+	
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		resp.SetError("Invalid relation payload: " + err.Error())
+		return resp
+	}
+	
+	declare := d.declareRelation
+	if payload.DryRun {
+		declare = d.previewRelation
+	}
+	declared, data, err := declare(req, payload.Relation, payload.Explain, d.resolveDeclareContext(req))
+	var invalid *relationValidationError
+	if errors.As(err, &invalid) {
+		resp.SetData(map[string]interface{}{
+			"code":        "VALIDATION",
+			"relation_id": declared.ID,
+			"errors":      invalid.errors,
+		})
+		resp.SetError(invalid.Error())
+		return resp
+	}
+	if errors.Is(err, ErrAITimeout) {
+		resp.SetData(map[string]interface{}{
+			"code":        "TIMEOUT",
+			"relation_id": declared.ID,
+		})
+		resp.SetError("TIMEOUT: " + err.Error())
+		return resp
+	}
+	if err != nil {
+		resp.SetError("Failed to declare relation: " + err.Error())
+		return resp
+	}
+	
+	// Step 6 Phase C: Create similarity relationships for new tools
+	// Process in background after successful response to avoid any blocking
+	if declared.Type == "Tool" && !payload.DryRun {
+		d.processSimilarityInBackground([]Relation{declared})
+	}
+	
+	resp.SetData(data)
+	return resp
+}
+
+// handleDeclareRelations declares several relations in one request, in
+// order. The request's session context, references and user prompt apply to
+// every relation; references are resolved once for the whole batch. It stops
+// at the first failure unless continue_on_error is set, and similarity for
+// the declared tools is computed once at the end.
+func (d *Daemon) handleDeclareRelations(req Request) Response {
+	resp := NewResponse(req.ID, true)
+	
+	if errMsg := d.validateDeclareRequest(req); errMsg != "" {
+		resp.SetError(errMsg)
+		return resp
+	}
+	
+	var payload struct {
+		Relations       []Relation `json:"relations"`
+		ContinueOnError bool       `json:"continue_on_error,omitempty"`
+		Explain         bool       `json:"explain,omitempty"`
+	}
+	
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		resp.SetError("Invalid relations payload: " + err.Error())
+		return resp
+	}
+	if len(payload.Relations) == 0 {
+		resp.SetError("relations is required")
+		return resp
+	}
+	
+	declareCtx := d.resolveDeclareContext(req)
+	results := make([]map[string]interface{}, 0, len(payload.Relations))
+	var tools []Relation
+	failed := 0
+	
+	for i, relation := range payload.Relations {
+		declared, data, err := d.declareRelation(req, relation, payload.Explain, declareCtx)
+		if err != nil {
+			failed++
+			logger.Errorf("❌ Batch declare %d/%d (%s) failed: %v", i+1, len(payload.Relations), declared.ID, err)
+			result := map[string]interface{}{
+				"index":       i,
+				"relation_id": declared.ID,
+				"type":        declared.Type,
+				"success":     false,
+				"error":       err.Error(),
+			}
+			var invalid *relationValidationError
+			if errors.As(err, &invalid) {
+				result["code"] = "VALIDATION"
+				result["validation_errors"] = invalid.errors
+			} else if errors.Is(err, ErrAITimeout) {
+				result["code"] = "TIMEOUT"
+			}
+			results = append(results, result)
+			if !payload.ContinueOnError {
+				break
+			}
+			continue
+		}
+		
+		data["index"] = i
+		data["success"] = true
+		results = append(results, data)
+		if declared.Type == "Tool" {
+			tools = append(tools, declared)
+		}
+	}
+	
+	// One similarity pass over every tool the batch declared
+	if len(tools) > 0 {
+		d.processSimilarityInBackground(tools)
+	}
+	
+	skipped := len(payload.Relations) - len(results)
+	logger.Infof("📦 Batch declare: %d declared, %d failed, %d skipped", len(results)-failed, failed, skipped)
+	
+	resp.SetData(map[string]interface{}{
+		"results":  results,
+		"total":    len(payload.Relations),
+		"declared": len(results) - failed,
+		"failed":   failed,
+		"skipped":  skipped,
+	})
+	if failed > 0 {
+		resp.SetError(fmt.Sprintf("%d of %d relations failed to declare", failed, len(payload.Relations)))
+	}
+	return resp
+}
+
+// validateDeclareRequest runs the request validator over a declare
+// request's user prompt and references, returning a user-facing error or ""
+func (d *Daemon) validateDeclareRequest(req Request) string {
+	if d.validator != nil {
+		// Create validation request from raw request data
+		validationReq := map[string]interface{}{
+			"user_prompt": req.UserPrompt,
+			"references":  req.References,
+		}
+		
+		if validationResult := d.validator.ValidateRequest(validationReq); validationResult.HasErrors() {
+			return d.validator.FormatErrors(validationResult.Errors)
+		}
+	}
+	
+	if d.realityCompiler == nil {
+		return "Reality compiler not initialized"
+	}
+	return ""
+}
+
+// defaultAgent is the agent given to Tool relations declared without one
+func (d *Daemon) defaultAgent() string {
+	if d.config.DefaultAgent != "" {
+		return d.config.DefaultAgent
+	}
+	return defaultDeclareAgent
+}
+
+// checkDeclaredAgent checks the agent a relation was declared with against
+// the agent configuration, so a typo such as @ai-enginer doesn't silently
+// label a tool. Under the reject policy an unknown agent is a validation
+// error; otherwise it is logged and returned as a warning for the
+// response. Relations without an agent, and every relation when agents.json
+// isn't loaded, pass.
+func (d *Daemon) checkDeclaredAgent(relation Relation) (string, error) {
+	agent, _ := relation.Properties["agent"].(string)
+	if agent = strings.TrimSpace(agent); agent == "" || agentConfig == nil || IsKnownAgent(agent) {
+		return "", nil
+	}
+
+	known := strings.Join(KnownAgentNames(), ", ")
+	message := fmt.Sprintf("Unknown agent %s", agent)
+	if d.config.UnknownAgents == UnknownAgentsReject {
+		return "", &relationValidationError{
+			errors: []validation.ValidationError{{
+				Field:      "agent",
+				Message:    message,
+				Code:       "UNKNOWN_AGENT",
+				Suggestion: "Use one of the configured agents: " + known,
+			}},
+			message: fmt.Sprintf("%s; known agents: %s", message, known),
+		}
+	}
+	logger.Warnf("⚠️ %s declared with unknown agent %s (known: %s)", getRelationName(relation), agent, known)
+	return fmt.Sprintf("%s; known agents: %s", message, known), nil
+}
+
+// relationValidationError reports every schema problem with a relation,
+// found before anything was stored
+type relationValidationError struct {
+	errors  []validation.ValidationError
+	message string
+}
+
+func (e *relationValidationError) Error() string {
+	return e.message
+}
+
+// validateRelation checks a relation against the schema for its type
+func (d *Daemon) validateRelation(relation Relation) error {
+	if d.validator == nil {
+		return nil
+	}
+	result := d.validator.ValidateRelation(relation.Type, relation.Properties)
+	if !result.HasErrors() {
+		return nil
+	}
+	return &relationValidationError{
+		errors:  result.Errors,
+		message: d.validator.FormatErrors(result.Errors),
+	}
+}
+
+// defaultToolDescription describes a tool declared without a description,
+// from the user prompt or else its transforms, so declares from clients
+// that only send a name and transforms still validate
+func defaultToolDescription(userPrompt string, transforms []string) string {
+	if prompt := strings.TrimSpace(userPrompt); prompt != "" {
+		line, _, _ := strings.Cut(prompt, "\n")
+		if runes := []rune(line); len(runes) > 200 {
+			return string(runes[:200]) + "..."
+		}
+		return line
+	}
+	if len(transforms) > 0 {
+		return "Transforms " + strings.Join(transforms, ", ")
 	}
-	
-	resp.SetData(data)
-	return resp
+	return ""
 }
 
-func (d *Daemon) handleEnd(req Request) Response {
-	resp := NewResponse(req.ID, true)
-	
-	d.endSession(req.ID)
+// declareContext is the resolved reference context a declare request adds
+// to the relations it declares
+type declareContext struct {
+	resolved    string // Formatted reference content for AI generation ("" if none)
+	truncations []resolution.TruncationRecord
+}
+
+// resolveDeclareContext resolves the request's references for declare mode
+func (d *Daemon) resolveDeclareContext(req Request) declareContext {
+	var declareCtx declareContext
+	if len(req.References) == 0 {
+		return declareCtx
+	}
 	
-	data := map[string]string{
-		"message": "Session crystallized. The dolphins remember...",
+	// Use common reference handler for resolution
+	if d.referenceHandler == nil {
+		logger.Warnf("⚠️ No reference handler available - skipping reference resolution")
+		return declareCtx
 	}
 	
-	resp.SetData(data)
-	return resp
+	result := d.referenceHandler.ResolveReferences(req.References, "declare", len(req.UserPrompt))
+	if result.Success {
+		declareCtx.resolved = d.referenceHandler.FormatForDeclare(result.ResolvedText)
+		logger.Debugf("✨ Resolved context stored (%d chars)", len(declareCtx.resolved))
+	}
+	if len(result.Truncations) > 0 {
+		declareCtx.truncations = result.Truncations
+	}
+	if !result.Success && result.Error != nil {
+		logger.Warnf("⚠️ Reference resolution failed: %v", result.Error)
+		// For declare mode, we could fail the request or continue with graceful degradation
+		// Continuing with graceful degradation for consistency
+	}
+	return declareCtx
 }
 
-// handleMemoryShow returns full details for a specific session
-func (d *Daemon) handleMemoryShow(req Request, sessionID string) Response {
-	resp := NewResponse(req.ID, true)
-	
-	log.Printf("🔍 [DEBUG] handleMemoryShow - looking for session: %s", sessionID)
-	
-	// Track memory access
-	if d.contextCollector != nil {
-		memoryPath := fmt.Sprintf("/memory/%s", sessionID)
-		d.contextCollector.TrackMemoryAccess(memoryPath, "session")
+// declareRelation fills in a relation's ID and the request's session,
+// reference and prompt properties, then declares and materializes it.
+// Returns the relation as declared (with its ID even on failure) and the
+// per-relation response data.
+func (d *Daemon) declareRelation(req Request, relation Relation, explain bool, declareCtx declareContext) (Relation, map[string]interface{}, error) {
+	agentWarning, err := d.checkDeclaredAgent(relation)
+	if err != nil {
+		return relation, nil, err
+	}
+	relation, updating, err := d.prepareRelation(req, relation, explain, declareCtx)
+	if err != nil {
+		return relation, nil, err
 	}
 	
-	// First check in-memory sessions
-	d.mu.RLock()
-	if session, exists := d.sessions[sessionID]; exists {
-		d.mu.RUnlock()
-		
-		log.Printf("🔍 [DEBUG] handleMemoryShow - found session in memory, messages: %d", len(session.Messages))
-		
-		data := map[string]interface{}{
-			"id":           session.ID,
-			"agent":        session.Agent,
-			"state":        session.State,
-			"created_at":   session.CreatedAt,
-			"last_activity": session.LastActivity,
-			"messages":     session.Messages,
-			"command_generated": session.CommandGenerated,
-		}
-		resp.SetData(data)
-		
-		log.Printf("🔍 [DEBUG] handleMemoryShow - returning data with %d messages", len(session.Messages))
-		return resp
+	// Declare and materialize the relation
+	entity, err := d.realityCompiler.DeclareRelation(relation)
+	if err != nil {
+		return relation, nil, err
 	}
-	d.mu.RUnlock()
 	
-	log.Printf("🔍 [DEBUG] handleMemoryShow - session not in memory, checking storage")
+	// Return success with materialized entity info
+	data := map[string]interface{}{
+		"relation_id":    relation.ID,
+		"type":          relation.Type,
+		"materialized":  true,
+		"physical_path": entity.PhysicalPath,
+		"status":        entity.Status,
+		"updated":       updating != nil,
+	}
+	if len(declareCtx.truncations) > 0 {
+		data["context_truncations"] = declareCtx.truncations
+	}
+	if agentWarning != "" {
+		data["agent_warning"] = agentWarning
+	}
 	
-	// Try to load from disk
-	if d.storage != nil {
-		if session, err := d.storage.LoadSession(sessionID); err == nil {
-			log.Printf("🔍 [DEBUG] handleMemoryShow - found session on disk, messages: %d", len(session.Messages))
-			
-			data := map[string]interface{}{
-				"id":           session.ID,
-				"agent":        session.Agent,
-				"state":        session.State,
-				"created_at":   session.CreatedAt,
-				"last_activity": session.LastActivity,
-				"messages":     session.Messages,
-				"command_generated": session.CommandGenerated,
+	// The materializer records the tool's dependencies on the relation
+	if relation.Type == "Tool" {
+		if deps := stringList(relation.Properties["dependencies"]); len(deps) > 0 {
+			language, _ := relation.Properties["language"].(string)
+			report := checkDependencies(d.baseDir, language, deps, d.config.AutoInstallDeps)
+			if len(report.Missing) > 0 && !report.Attempted {
+				logger.Infof("📦 %s has missing dependencies %v (set PORT42_AUTO_INSTALL_DEPS=1 to install them)",
+					relation.ID, report.Missing)
 			}
-			resp.SetData(data)
-			
-			log.Printf("🔍 [DEBUG] handleMemoryShow - returning data from disk with %d messages", len(session.Messages))
-			return resp
+			data["dependencies"] = report
+		}
+		if warnings := stringList(relation.Properties["safety_warnings"]); len(warnings) > 0 {
+			data["safety_warnings"] = warnings
+		}
+	}
+	if explain {
+		if prompt, ok := relation.Properties["explain_prompt"]; ok {
+			data["explain_prompt"] = prompt
 		} else {
-			log.Printf("🔍 [DEBUG] handleMemoryShow - failed to load from storage: %v", err)
+			data["explain_prompt"] = nil // Relation type does not use AI generation
 		}
-	} else {
-		log.Printf("🔍 [DEBUG] handleMemoryShow - no storage available")
 	}
 	
-	// Session not found
-	log.Printf("🔍 [DEBUG] handleMemoryShow - session not found: %s", sessionID)
-	resp.SetError(fmt.Sprintf("Session '%s' not found", sessionID))
-	return resp
+	return relation, data, nil
 }
 
-// Reality Compiler handlers
-
-// handleDeclareRelation declares a new relation and materializes it
-func (d *Daemon) handleDeclareRelation(req Request) Response {
-	resp := NewResponse(req.ID, true)
-	
-	// Step 5: Early validation - fail fast with helpful messages
-	if d.validator != nil {
-		// Create validation request from raw request data
-		validationReq := map[string]interface{}{
-			"user_prompt": req.UserPrompt,
-			"references":  req.References,
-		}
-		
-		if validationResult := d.validator.ValidateRequest(validationReq); validationResult.HasErrors() {
-			userFriendlyError := d.validator.FormatErrors(validationResult.Errors)
-			resp.SetError(userFriendlyError)
-			return resp
-		}
+// previewRelation prepares a Tool relation like declareRelation and runs
+// its code generation, but stores nothing: no relation, no object, no
+// symlink and no similarity pass. The response data has the would-be spec.
+func (d *Daemon) previewRelation(req Request, relation Relation, explain bool, declareCtx declareContext) (Relation, map[string]interface{}, error) {
+	if relation.Type != "Tool" {
+		return relation, nil, fmt.Errorf("dry_run is only supported for Tool relations, not %s", relation.Type)
+	}
+	agentWarning, err := d.checkDeclaredAgent(relation)
+	if err != nil {
+		return relation, nil, err
+	}
+	relation, updating, err := d.prepareRelation(req, relation, explain, declareCtx)
+	if err != nil {
+		return relation, nil, err
 	}
 	
-	if d.realityCompiler == nil {
-		resp.SetError("Reality compiler not initialized")
-		return resp
+	preview, err := d.realityCompiler.PreviewRelation(relation)
+	if err != nil {
+		return relation, nil, err
 	}
 	
-	// Parse relation from payload
-	var payload struct {
-		Relation Relation `json:"relation"`
+	data := map[string]interface{}{
+		"relation_id":  relation.ID,
+		"type":         relation.Type,
+		"dry_run":      true,
+		"materialized": false,
+		"updated":      updating != nil,
+		"spec":         preview,
+	}
+	if len(declareCtx.truncations) > 0 {
+		data["context_truncations"] = declareCtx.truncations
+	}
+	if agentWarning != "" {
+		data["agent_warning"] = agentWarning
+	}
+	// Report what is missing, but never install during a dry run
+	if len(preview.Dependencies) > 0 {
+		data["dependencies"] = checkDependencies(d.baseDir, preview.Language, preview.Dependencies, false)
+	}
+	if explain {
+		data["explain_prompt"] = relation.Properties["explain_prompt"]
+	}
+	return relation, data, nil
+}
+
+// prepareRelation fills in a relation's ID and the request's session,
+// reference and prompt properties and validates it. Returns the prepared
+// relation, and the stored tool it replaces when the declare is an update.
+func (d *Daemon) prepareRelation(req Request, relation Relation, explain bool, declareCtx declareContext) (Relation, *Relation, error) {
+	// A tool declared with a reference to itself updates that tool in
+	// place instead of becoming a second tool with the same name. Keeping
+	// the ID keeps its creation time and executable history; the new
+	// executable becomes the next version.
+	var updating *Relation
+	if relation.ID == "" && relation.Type == "Tool" {
+		if updating = d.referencedToolToUpdate(req, getRelationName(relation)); updating != nil {
+			relation.ID = updating.ID
+			relation.CreatedAt = updating.CreatedAt
+			if relation.Properties == nil {
+				relation.Properties = make(map[string]interface{})
+			}
+			if _, has := relation.Properties["description"]; !has {
+				if description, ok := updating.Properties["description"]; ok {
+					relation.Properties["description"] = description
+				}
+			}
+			logger.Infof("♻️ Declare of %s references the existing tool, updating %s", getRelationName(relation), relation.ID)
+		}
 	}
 	
-	if err := json.Unmarshal(req.Payload, &payload); err != nil {
-		resp.SetError("Invalid relation payload: " + err.Error())
-		return resp
+	if relation.Type == "Relationship" && relation.Properties != nil {
+		d.resolveEdgeEndpoints(&relation)
 	}
 	
 	// Set ID if not provided
-	if payload.Relation.ID == "" {
-		payload.Relation.ID = generateRelationID(payload.Relation.Type, 
-			fmt.Sprintf("%v", payload.Relation.Properties["name"]))
+	if relation.ID == "" {
+		relation.ID = generateRelationID(relation.Type, 
+			fmt.Sprintf("%v", relation.Properties["name"]))
+	}
+	if relation.Properties == nil {
+		relation.Properties = make(map[string]interface{})
+	}
+	
+	// Record how the relation was created, unless the declarer said
+	if source, _ := relation.Properties["source"].(string); source == "" {
+		relation.Properties["source"] = SourceDeclare
 	}
 	
 	// Step 5: Capture session context for memory-relation bridge
 	if req.SessionContext != nil && req.SessionContext.SessionID != "" {
 		// Add memory session properties to relation
-		if payload.Relation.Properties == nil {
-			payload.Relation.Properties = make(map[string]interface{})
-		}
-		payload.Relation.Properties["memory_session"] = req.SessionContext.SessionID
+		relation.Properties["memory_session"] = req.SessionContext.SessionID
 		if req.SessionContext.Agent != "" {
-			payload.Relation.Properties["crystallized_agent"] = req.SessionContext.Agent
+			relation.Properties["crystallized_agent"] = req.SessionContext.Agent
 		}
-		log.Printf("🔗 Linking relation %s to memory session %s", 
-			payload.Relation.ID, req.SessionContext.SessionID)
+		logger.Debugf("🔗 Linking relation %s to memory session %s", 
+			relation.ID, req.SessionContext.SessionID)
 	}
 	
-	// Phase 1: Universal References - Validate, store, and resolve references
+	// Phase 1: Universal References - store the references and their resolved context
 	if len(req.References) > 0 {
-		// Store original references in relation properties
-		if payload.Relation.Properties == nil {
-			payload.Relation.Properties = make(map[string]interface{})
+		relation.Properties["references"] = req.References
+		logger.Debugf("📎 References stored for %s: %d references", 
+			relation.ID, len(req.References))
+		if declareCtx.resolved != "" {
+			relation.Properties["resolved_context"] = declareCtx.resolved
 		}
-		payload.Relation.Properties["references"] = req.References
-		log.Printf("📎 References stored for %s: %d references", 
-			payload.Relation.ID, len(req.References))
-		
-		// Use common reference handler for resolution
-		if d.referenceHandler != nil {
-			result := d.referenceHandler.ResolveReferences(req.References, "declare")
-			if result.Success {
-				// Store resolved context for AI generation
-				contextStr := d.referenceHandler.FormatForDeclare(result.ResolvedText)
-				payload.Relation.Properties["resolved_context"] = contextStr
-				log.Printf("✨ Resolved context stored (%d chars)", len(contextStr))
-			} else if result.Error != nil {
-				log.Printf("⚠️ Reference resolution failed: %v", result.Error)
-				// For declare mode, we could fail the request or continue with graceful degradation
-				// Continuing with graceful degradation for consistency
-			}
-		} else {
-			log.Printf("⚠️ No reference handler available - skipping reference resolution")
+		if len(declareCtx.truncations) > 0 {
+			relation.Properties["context_truncations"] = declareCtx.truncations
 		}
 	}
 	
 	// Phase 3: Universal User Prompt - Store user prompt if provided
 	if req.UserPrompt != "" {
-		if payload.Relation.Properties == nil {
-			payload.Relation.Properties = make(map[string]interface{})
-		}
-		payload.Relation.Properties["user_prompt"] = req.UserPrompt
+		relation.Properties["user_prompt"] = req.UserPrompt
 		
-		log.Printf("💬 User prompt stored for %s: %.100s...", 
-			payload.Relation.ID, req.UserPrompt)
+		logger.Debugf("💬 User prompt stored for %s: %.100s...", 
+			relation.ID, req.UserPrompt)
 	}
 	
 	// Add default agent for Tool relations created via direct declare
-	if payload.Relation.Type == "Tool" {
-		if payload.Relation.Properties == nil {
-			payload.Relation.Properties = make(map[string]interface{})
-		}
+	if relation.Type == "Tool" {
 		// Only set agent if not already set (preserve session agents)
-		if _, hasAgent := payload.Relation.Properties["agent"]; !hasAgent {
-			payload.Relation.Properties["agent"] = "@ai-engineer"
+		if _, hasAgent := relation.Properties["agent"]; !hasAgent {
+			relation.Properties["agent"] = d.defaultAgent()
 		}
 	}
 	
-	// Declare and materialize the relation
-	entity, err := d.realityCompiler.DeclareRelation(payload.Relation)
-	if err != nil {
-		resp.SetError("Failed to declare relation: " + err.Error())
-		return resp
+	// Explain mode: the materializer records the final prompt in the
+	// relation properties so it is persisted alongside the tool
+	if explain {
+		relation.Properties["explain"] = true
 	}
 	
-	// Step 6 Phase C: Create similarity relationships for new tools
-	// Process in background after successful response to avoid any blocking
-	if payload.Relation.Type == "Tool" && d.realityCompiler != nil {
-		relationCopy := payload.Relation // Copy for goroutine safety
-		go func() {
-			// Add a small delay to ensure main response is sent first
-			time.Sleep(100 * time.Millisecond)
-			
-			defer func() {
-				// Catch any panics in similarity processing
-				if r := recover(); r != nil {
-					log.Printf("⚠️ Panic in similarity processing: %v", r)
-				}
-			}()
-			
-			similarityCalculator := NewSimilarityCalculator(d.realityCompiler.GetRelationStore())
-			if similarityCalculator != nil {
-				err := similarityCalculator.createSimilarityRelationships(relationCopy, 0.5)
-				if err != nil {
-					log.Printf("⚠️ Failed to create similarity relationships for %s: %v", 
-						relationCopy.ID, err)
-				} else {
-					log.Printf("🔗 Similarity relationships processed for %s", 
-						relationCopy.Properties["name"])
-				}
+	// Schema check before anything is stored, on the canonical form so a
+	// comma-separated transforms string counts as a list
+	normalizeRelation(&relation)
+	if relation.Type == "Tool" {
+		if _, has := relation.Properties["description"]; !has {
+			if description := defaultToolDescription(req.UserPrompt, getTransforms(relation)); description != "" {
+				relation.Properties["description"] = description
 			}
-		}()
+		}
 	}
-	
-	// Return success with materialized entity info
-	data := map[string]interface{}{
-		"relation_id":    payload.Relation.ID,
-		"type":          payload.Relation.Type,
-		"materialized":  true,
-		"physical_path": entity.PhysicalPath,
-		"status":        entity.Status,
+	if err := d.validateRelation(relation); err != nil {
+		return relation, updating, err
 	}
 	
-	resp.SetData(data)
-	return resp
+	return relation, updating, nil
+}
+
+// referencedToolToUpdate returns the stored Tool named name when one of the
+// request's references points at it (tool:{name}, or p42: paths under
+// /commands/{name} or /tools/{name}), or nil if the declare should create a
+// new tool
+func (d *Daemon) referencedToolToUpdate(req Request, name string) *Relation {
+	if name == "" || d.storage == nil {
+		return nil
+	}
+	for _, ref := range req.References {
+		if referencedToolName(ref) != name {
+			continue
+		}
+		tool, err := d.storage.findToolRelation(name)
+		if err != nil {
+			if !errors.Is(err, errToolNotFound) {
+				logger.Warnf("⚠️ Failed to look up referenced tool %s: %v", name, err)
+			}
+			return nil
+		}
+		return tool
+	}
+	return nil
+}
+
+// referencedToolName returns the tool a reference points at, or ""
+func referencedToolName(ref Reference) string {
+	switch ref.Type {
+	case "tool":
+		return strings.TrimSpace(ref.Target)
+	case "p42":
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(ref.Target, "p42:"), "/"), "/")
+		if len(parts) >= 2 && (parts[0] == "commands" || parts[0] == "tools") {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// processSimilarityInBackground creates similar_to relationships for newly
+// declared tools after the response has gone out, loading the relation
+// store once for all of them. PORT42_AUTO_SIMILARITY=false turns it off;
+// /similar views are scored on demand either way.
+func (d *Daemon) processSimilarityInBackground(tools []Relation) {
+	if d.realityCompiler == nil || len(tools) == 0 || !d.similarity.AutoLink {
+		return
+	}
+	go func() {
+		// Add a small delay to ensure main response is sent first
+		time.Sleep(100 * time.Millisecond)
+		
+		defer func() {
+			// Catch any panics in similarity processing
+			if r := recover(); r != nil {
+				logger.Warnf("⚠️ Panic in similarity processing: %v", r)
+			}
+		}()
+		
+		similarityCalculator := NewSimilarityCalculator(d.realityCompiler.GetRelationStore(), d.similarity)
+		if similarityCalculator == nil {
+			return
+		}
+		if err := similarityCalculator.createSimilarityRelationshipsForTools(tools, d.similarity.LinkThreshold); err != nil {
+			logger.Warnf("⚠️ Failed to create similarity relationships: %v", err)
+			return
+		}
+		for _, tool := range tools {
+			logger.Debugf("🔗 Similarity relationships processed for %s", tool.Properties["name"])
+		}
+	}()
 }
 
 // handleGetRelation retrieves a relation by ID
@@ -1554,7 +3120,8 @@ func (d *Daemon) handleListRelations(req Request) Response {
 	}
 	
 	var payload struct {
-		Type string `json:"type,omitempty"`
+		Type           string `json:"type,omitempty"`
+		DuplicateNames bool   `json:"duplicate_names,omitempty"` // Only relations sharing a name with another of their type
 	}
 	
 	// Parse payload (optional)
@@ -1576,10 +3143,30 @@ func (d *Daemon) handleListRelations(req Request) Response {
 		return resp
 	}
 	
-	resp.SetData(map[string]interface{}{
-		"relations": relations,
-		"count":     len(relations),
-	})
+	data := map[string]interface{}{}
+	if payload.DuplicateNames {
+		// Each name lists its relations in resolution order, the one
+		// /tools/{name} uses first
+		conflicts := toolNameConflicts(relations)
+		conflicting := make(map[string]bool)
+		for _, ids := range conflicts {
+			for _, id := range ids {
+				conflicting[id] = true
+			}
+		}
+		filtered := []Relation{}
+		for _, relation := range relations {
+			if conflicting[relation.ID] {
+				filtered = append(filtered, relation)
+			}
+		}
+		relations = filtered
+		data["duplicates"] = conflicts
+	}
+	
+	data["relations"] = relations
+	data["count"] = len(relations)
+	resp.SetData(data)
 	return resp
 }
 
@@ -1594,6 +3181,7 @@ func (d *Daemon) handleDeleteRelation(req Request) Response {
 	
 	var payload struct {
 		RelationID string `json:"relation_id"`
+		Cascade    bool   `json:"cascade,omitempty"` // Also delete relations it spawned
 	}
 	
 	if err := json.Unmarshal(req.Payload, &payload); err != nil {
@@ -1601,15 +3189,23 @@ func (d *Daemon) handleDeleteRelation(req Request) Response {
 		return resp
 	}
 	
-	if err := d.realityCompiler.DeleteRelation(payload.RelationID); err != nil {
+	result, err := d.realityCompiler.DeleteRelationCascade(payload.RelationID, payload.Cascade)
+	if err != nil {
 		resp.SetError("Failed to delete relation: " + err.Error())
 		return resp
 	}
 	
-	resp.SetData(map[string]interface{}{
+	data := map[string]interface{}{
 		"deleted": true,
 		"relation_id": payload.RelationID,
-	})
+		"deleted_relations": result.DeletedRelations,
+		"removed_paths": result.RemovedPaths,
+	}
+	if len(result.OrphanedChildren) > 0 {
+		data["orphaned_children"] = result.OrphanedChildren
+		data["warning"] = fmt.Sprintf("%d spawned relations still reference %s; use cascade to delete them", len(result.OrphanedChildren), payload.RelationID)
+	}
+	resp.SetData(data)
 	return resp
 }
 
@@ -1627,12 +3223,18 @@ func (d *Daemon) initializeRealityCompiler() error {
 		return fmt.Errorf("failed to initialize materialization store: %w", err)
 	}
 	
-	// Initialize AI client for tool generation
-	aiClient := NewAnthropicClient()
+	// Initialize AI provider for tool generation (PORT42_AI_PROVIDER)
+	aiProvider := selectAIProvider()
+	
+	// Similarity backend (PORT42_SIMILARITY) may embed with the same provider
+	d.similarity = loadSimilarityConfig(aiProvider)
+	if d.storage != nil {
+		d.storage.similarity = d.similarity
+	}
 	
 	// Initialize tool materializer with context collector
-	log.Printf("🔧 Creating tool materializer with context collector: %v", d.contextCollector != nil)
-	toolMaterializer, err := NewToolMaterializer(aiClient, d.storage, matStore, d.contextCollector)
+	logger.Debugf("🔧 Creating tool materializer with context collector: %v", d.contextCollector != nil)
+	toolMaterializer, err := NewToolMaterializer(aiProvider, d.storage, matStore, d.contextCollector)
 	if err != nil {
 		return fmt.Errorf("failed to initialize tool materializer: %w", err)
 	}
@@ -1648,8 +3250,9 @@ func (d *Daemon) initializeRealityCompiler() error {
 	// Initialize rule engine with default rules
 	ruleEngine := NewRuleEngine(d.realityCompiler, defaultRules())
 	d.realityCompiler.SetRuleEngine(ruleEngine)
+	d.realityCompiler.SetEventBus(d.events)
 	
-	log.Printf("🎯 Reality compiler initialized with %d rules", len(ruleEngine.ListRules()))
+	logger.Infof("🎯 Reality compiler initialized with %d rules", len(ruleEngine.ListRules()))
 	
 	return nil
 }
@@ -1750,10 +3353,10 @@ func (d *Daemon) initializeResolutionManager() error {
 	handlers := resolution.Handlers{
 		// Search handler - queries the storage system
 		SearchHandler: func(query string, limit int) ([]resolution.SearchResult, error) {
-			log.Printf("🔍 Search handler called for: %s (limit: %d)", query, limit)
+			logger.Debugf("🔍 Search handler called for: %s (limit: %d)", query, limit)
 			
 			if d.storage == nil {
-				log.Printf("⚠️ Storage not available for search")
+				logger.Warnf("⚠️ Storage not available for search")
 				return []resolution.SearchResult{}, nil
 			}
 			
@@ -1765,7 +3368,7 @@ func (d *Daemon) initializeResolutionManager() error {
 			// Execute search using storage system
 			results, err := d.storage.SearchObjects(query, "or", filters)
 			if err != nil {
-				log.Printf("❌ Search failed: %v", err)
+				logger.Errorf("❌ Search failed: %v", err)
 				return []resolution.SearchResult{}, nil // Return empty results, don't fail resolution
 			}
 			
@@ -1787,23 +3390,23 @@ func (d *Daemon) initializeResolutionManager() error {
 				})
 			}
 			
-			log.Printf("✅ Search completed: %d results found", len(resolverResults))
+			logger.Debugf("✅ Search completed: %d results found", len(resolverResults))
 			return resolverResults, nil
 		},
 		
 		// Tool handler - queries relations store for existing tools
 		ToolHandler: func(toolName string) (*resolution.ToolDefinition, error) {
-			log.Printf("🔧 Tool handler called for: %s", toolName)
+			logger.Debugf("🔧 Tool handler called for: %s", toolName)
 			
 			if d.realityCompiler == nil {
-				log.Printf("⚠️ Reality compiler not available for tool lookup")
+				logger.Warnf("⚠️ Reality compiler not available for tool lookup")
 				return nil, nil // Don't fail resolution, just return empty
 			}
 			
 			// Get all tool relations
 			toolRelations, err := d.realityCompiler.ListRelationsByType("Tool")
 			if err != nil {
-				log.Printf("❌ Failed to list tool relations: %v", err)
+				logger.Errorf("❌ Failed to list tool relations: %v", err)
 				return nil, nil // Don't fail resolution, just return empty
 			}
 			
@@ -1816,16 +3419,8 @@ func (d *Daemon) initializeResolutionManager() error {
 					var commands []string
 					
 					// Extract transforms from properties
-					if transformsVal, exists := relation.Properties["transforms"]; exists {
-						if transformsList, ok := transformsVal.([]interface{}); ok {
-							for _, t := range transformsList {
-								if tStr, ok := t.(string); ok {
-									transforms = append(transforms, tStr)
-								}
-							}
-						} else if transformsStr, ok := transformsVal.(string); ok {
-							transforms = []string{transformsStr}
-						}
+					if _, exists := relation.Properties["transforms"]; exists {
+						transforms = getTransforms(relation)
 					}
 					
 					// Check for generated commands in properties
@@ -1858,28 +3453,37 @@ func (d *Daemon) initializeResolutionManager() error {
 						Agent:      agent,
 					}
 					
-					log.Printf("✅ Tool found: %s (ID: %s)", toolName, relation.ID)
+					logger.Debugf("✅ Tool found: %s (ID: %s)", toolName, relation.ID)
 					return toolDef, nil
 				}
 			}
 			
-			log.Printf("⚠️ Tool '%s' not found in %d tool relations", toolName, len(toolRelations))
+			logger.Warnf("⚠️ Tool '%s' not found in %d tool relations", toolName, len(toolRelations))
 			return nil, nil // Don't fail resolution, just return empty
 		},
 		
 		
 		// File handler - local filesystem with security boundaries
 		FileHandler: func(path string) (*resolution.FileContent, error) {
-			log.Printf("📄 File handler called for: %s", path)
+			logger.Debugf("📄 File handler called for: %s", path)
 			return d.handleLocalFile(path)
 		},
 		
 		// P42 handler - Port 42 VFS and crystallized knowledge access
 		P42Handler: func(p42Path string) (*resolution.FileContent, error) {
-			log.Printf("🏗️ P42 handler called for: %s", p42Path)
+			logger.Debugf("🏗️ P42 handler called for: %s", p42Path)
 			return d.handleP42File(p42Path)
 		},
 		
+		// Freshness for url: references without a Cache-Control max-age
+		URLCacheTTL: envPositiveDuration("PORT42_URL_CACHE_TTL", resolution.DefaultCachePolicy().DefaultTTL),
+		
+		// Git handler - single files from remote repositories
+		GitHandler: func(ctx context.Context, ref resolution.GitReference, maxSize int64) (*resolution.FileContent, error) {
+			logger.Debugf("🌿 Git handler called for: %s", ref)
+			return fetchGitFile(ctx, ref, maxSize)
+		},
+		
 		// Relations handler - provides access to relations for URL artifact caching
 		RelationsHandler: func() resolution.RelationsManager {
 			return &relationsAdapter{
@@ -1890,28 +3494,28 @@ func (d *Daemon) initializeResolutionManager() error {
 	}
 	
 	d.resolutionService = resolution.NewResolutionService(handlers)
-	log.Printf("🔗 Resolution service initialized")
+	logger.Infof("🔗 Resolution service initialized")
 	return nil
 }
 
 // Command generation functionality
 func (d *Daemon) generateCommand(spec *CommandSpec) error {
-	log.Printf("🔍 [GENERATE_COMMAND] Starting generation for '%s' (session=%s)", spec.Name, spec.SessionID)
+	logger.Debugf("🔍 [GENERATE_COMMAND] Starting generation for '%s' (session=%s)", spec.Name, spec.SessionID)
 	
 	// Check for dependencies
 	if len(spec.Dependencies) > 0 {
-		log.Printf("📦 Command requires dependencies: %v", spec.Dependencies)
+		logger.Infof("📦 Command requires dependencies: %v", spec.Dependencies)
 	}
 	
 	// Generate dependency check code based on language
 	var depCheckCode string
-	log.Printf("🔍 Language: %s, Dependencies: %v", spec.Language, spec.Dependencies)
-	if len(spec.Dependencies) > 0 && spec.Language == "bash" {
-		// Only add bash dependency check for bash scripts
-		depCheckCode = d.generateDependencyCheck(spec.Dependencies)
-		log.Printf("✅ Adding bash dependency check for bash script")
-	} else if len(spec.Dependencies) > 0 {
-		log.Printf("⚠️  Skipping dependency check for %s script with deps: %v", spec.Language, spec.Dependencies)
+	logger.Debugf("🔍 Language: %s, Dependencies: %v", spec.Language, spec.Dependencies)
+	if len(spec.Dependencies) > 0 {
+		if err := writeDependencyInstaller(d.baseDir); err != nil {
+			logger.Warnf("⚠️ Failed to write dependency installer: %v", err)
+		}
+		depCheckCode = generateDependencyCheck(spec.Language, spec.Dependencies)
+		logger.Debugf("✅ Adding %s dependency check", spec.Language)
 	}
 	
 	// Use implementation as-is - Go's json.Unmarshal already handled unescaping
@@ -1971,7 +3575,7 @@ func (d *Daemon) generateCommand(spec *CommandSpec) error {
 				"name":        spec.Name,
 				"description": spec.Description,
 				"language":    spec.Language,
-				"source":      "swim", // Track creation method
+				"source":      SourceSwim, // Track creation method
 			},
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
@@ -1992,12 +3596,12 @@ func (d *Daemon) generateCommand(spec *CommandSpec) error {
 			relation.Properties["agent"] = spec.Agent
 		}
 		
-		log.Printf("🔗 Creating relation for swim-generated command: %s", relation.ID)
+		logger.Debugf("🔗 Creating relation for swim-generated command: %s", relation.ID)
 		if _, err := d.realityCompiler.DeclareRelation(relation); err != nil {
-			log.Printf("⚠️ Failed to create relation for command %s: %v", spec.Name, err)
+			logger.Warnf("⚠️ Failed to create relation for command %s: %v", spec.Name, err)
 			// Don't fail the command generation, just log the issue
 		} else {
-			log.Printf("✅ Relation created for swim-generated command: %s", spec.Name)
+			logger.Debugf("✅ Relation created for swim-generated command: %s", spec.Name)
 		}
 	}
 	
@@ -2009,7 +3613,7 @@ func (d *Daemon) generateCommand(spec *CommandSpec) error {
 
 // Artifact generation functionality
 func (d *Daemon) generateArtifact(spec *ArtifactSpec) error {
-	log.Printf("🔍 [GENERATE_ARTIFACT] Starting generation for '%s' (type=%s, session=%s)", 
+	logger.Debugf("🔍 [GENERATE_ARTIFACT] Starting generation for '%s' (type=%s, session=%s)", 
 		spec.Name, spec.Type, spec.SessionID)
 	
 	// Check if storage is available
@@ -2046,28 +3650,32 @@ func (d *Daemon) generateArtifact(spec *ArtifactSpec) error {
 			metadata[k] = v
 		}
 		
-		result, err := d.storage.HandleStorePath(fullPath, []byte(spec.SingleFile), metadata)
+		content, err := decodeArtifactContent(spec.SingleFile, spec.Encoding)
+		if err != nil {
+			return fmt.Errorf("failed to decode artifact %s: %v", spec.Name, err)
+		}
+		
+		result, err := d.storage.HandleStorePath(fullPath, content, metadata)
 		if err != nil {
 			return fmt.Errorf("failed to store artifact: %v", err)
 		}
 		
-		log.Printf("✨ Artifact stored: %s (id=%s)", fullPath, result["id"])
+		logger.Infof("✨ Artifact stored: %s (id=%s)", fullPath, result["id"])
 		
 	} else if spec.Content != nil && len(spec.Content) > 0 {
 		// Multi-file artifact (e.g., a web app with multiple files)
-		for filePath, content := range spec.Content {
+		for filePath, encoded := range spec.Content {
 			fullPath := fmt.Sprintf("%s/%s", basePath, filePath)
 			
-			// Infer file type from extension
-			fileType := "file"
-			if strings.HasSuffix(filePath, ".md") {
-				fileType = "document"
-			} else if strings.HasSuffix(filePath, ".js") || strings.HasSuffix(filePath, ".py") {
-				fileType = "code"
-			} else if strings.HasSuffix(filePath, ".html") || strings.HasSuffix(filePath, ".css") {
-				fileType = "web"
+			content, err := decodeArtifactContent(encoded, spec.Encoding)
+			if err != nil {
+				logger.Errorf("❌ Failed to decode file %s: %v", filePath, err)
+				continue
 			}
 			
+			// Infer file type from extension, then from the bytes for media
+			fileType := artifactFileType(filePath, detectMimeType(filePath, content))
+			
 			metadata := map[string]interface{}{
 				"type":                 fileType,
 				"parent_type":          spec.Type,
@@ -2078,17 +3686,17 @@ func (d *Daemon) generateArtifact(spec *ArtifactSpec) error {
 				"artifact_name":        spec.Name,
 			}
 			
-			result, err := d.storage.HandleStorePath(fullPath, []byte(content), metadata)
+			result, err := d.storage.HandleStorePath(fullPath, content, metadata)
 			if err != nil {
-				log.Printf("❌ Failed to store file %s: %v", filePath, err)
+				logger.Errorf("❌ Failed to store file %s: %v", filePath, err)
 				continue
 			}
 			
-			log.Printf("✨ Artifact file stored: %s (id=%s)", fullPath, result["id"])
+			logger.Infof("✨ Artifact file stored: %s (id=%s)", fullPath, result["id"])
 		}
 	}
 	
-	log.Printf("🎨 Artifact generation completed: %s", spec.Name)
+	logger.Infof("🎨 Artifact generation completed: %s", spec.Name)
 	return nil
 }
 
@@ -2129,120 +3737,6 @@ func extractTags(spec *CommandSpec) []string {
 	return tags
 }
 
-// Generate dependency check code for commands
-func (d *Daemon) generateDependencyCheck(deps []string) string {
-	if len(deps) == 0 {
-		return ""
-	}
-	
-	// Create dependency install script
-	d.createDependencyInstaller(deps)
-	
-	// Bash dependency check
-	check := `# Dependency check
-missing_deps=()
-`
-	for _, dep := range deps {
-		check += fmt.Sprintf("if ! command -v %s &> /dev/null; then\n", dep)
-		check += fmt.Sprintf("  missing_deps+=(%s)\n", dep)
-		check += "fi\n"
-	}
-	
-	check += `
-if [ ${#missing_deps[@]} -ne 0 ]; then
-  echo "❌ Missing dependencies: ${missing_deps[*]}"
-  echo ""
-  echo "To install dependencies, run:"
-  echo "  ~/.port42/install-deps.sh ${missing_deps[*]}"
-  echo ""
-  echo "Or install manually:"
-  for dep in "${missing_deps[@]}"; do
-    case "$dep" in
-      lolcat) echo "  brew install lolcat  # or: gem install lolcat" ;;
-      tree) echo "  brew install tree    # or: apt-get install tree" ;;
-      figlet) echo "  brew install figlet  # or: apt-get install figlet" ;;
-      jq) echo "  brew install jq      # or: apt-get install jq" ;;
-      rg|ripgrep) echo "  brew install ripgrep # or: cargo install ripgrep" ;;
-      fzf) echo "  brew install fzf     # or: git clone https://github.com/junegunn/fzf.git" ;;
-      *) echo "  # Install $dep using your package manager" ;;
-    esac
-  done
-  exit 1
-fi
-
-`
-	return check
-}
-
-// Create a dependency installer script
-func (d *Daemon) createDependencyInstaller(deps []string) {
-	homeDir, _ := os.UserHomeDir()
-	installerPath := filepath.Join(homeDir, ".port42", "install-deps.sh")
-	
-	installer := `#!/bin/bash
-# Port 42 Dependency Installer
-# Generated automatically to help install command dependencies
-
-set -e
-
-echo "🐬 Port 42 Dependency Installer"
-echo ""
-
-# Detect OS
-if [[ "$OSTYPE" == "darwin"* ]]; then
-  OS="macos"
-elif [[ -f /etc/debian_version ]]; then
-  OS="debian"
-elif [[ -f /etc/redhat-release ]]; then
-  OS="redhat"
-else
-  OS="unknown"
-fi
-
-# Function to install a dependency
-install_dep() {
-  local dep=$1
-  echo "📦 Installing $dep..."
-  
-  case "$OS" in
-    macos)
-      if command -v brew &> /dev/null; then
-        brew install "$dep" || true
-      else
-        echo "❌ Homebrew not found. Please install: https://brew.sh"
-        return 1
-      fi
-      ;;
-    debian)
-      sudo apt-get update && sudo apt-get install -y "$dep" || true
-      ;;
-    redhat)
-      sudo yum install -y "$dep" || true
-      ;;
-    *)
-      echo "❌ Unknown OS. Please install $dep manually."
-      return 1
-      ;;
-  esac
-}
-
-# Install each dependency passed as argument
-for dep in "$@"; do
-  if ! command -v "$dep" &> /dev/null; then
-    install_dep "$dep"
-  else
-    echo "✅ $dep is already installed"
-  fi
-done
-
-echo ""
-echo "✨ Installation complete!"
-`
-	
-	os.WriteFile(installerPath, []byte(installer), 0755)
-}
-
-
 // Ensure ~/.port42/commands is in PATH
 func (d *Daemon) ensureCommandsInPath() {
 	homeDir, _ := os.UserHomeDir()
@@ -2269,8 +3763,8 @@ Then restart your shell or run: source ~/.bashrc (or ~/.zshrc)
 	
 	os.WriteFile(hintPath, []byte(hint), 0644)
 	
-	log.Printf("💡 Add %s to your PATH to use generated commands", cmdDir)
-	log.Printf("   See %s for instructions", hintPath)
+	logger.Infof("💡 Add %s to your PATH to use generated commands", cmdDir)
+	logger.Infof("   See %s for instructions", hintPath)
 }
 
 // Simple command generation logging
@@ -2305,17 +3799,17 @@ func (d *Daemon) handleLocalFile(path string) (*resolution.FileContent, error) {
 	if strings.HasPrefix(path, "~/") {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			log.Printf("❌ Failed to get home directory: %v", err)
+			logger.Errorf("❌ Failed to get home directory: %v", err)
 			return nil, fmt.Errorf("failed to get home directory: %w", err)
 		}
 		path = filepath.Join(homeDir, path[2:])
-		log.Printf("🏠 Expanded tilde path to: %s", path)
+		logger.Debugf("🏠 Expanded tilde path to: %s", path)
 	}
 	
 	// Security: Clean path and prevent directory traversal
 	cleanPath := filepath.Clean(path)
 	if strings.Contains(cleanPath, "..") {
-		log.Printf("🚨 SECURITY WARNING: Path traversal attempt blocked - %s", path)
+		logger.Warnf("🚨 SECURITY WARNING: Path traversal attempt blocked - %s", path)
 		return nil, fmt.Errorf("path traversal not allowed: %s", path)
 	}
 	
@@ -2336,10 +3830,15 @@ func (d *Daemon) handleLocalFile(path string) (*resolution.FileContent, error) {
 	
 	// Security: Only allow files within reasonable boundaries
 	if !d.isFileAccessAllowed(absPath) {
-		log.Printf("🚨 SECURITY WARNING: File access boundary violation blocked - %s", path)
+		logger.Warnf("🚨 SECURITY WARNING: File access boundary violation blocked - %s", path)
 		return nil, fmt.Errorf("file access not allowed: %s", path)
 	}
 	
+	// Glob patterns collect every matching file
+	if isGlobPattern(cleanPath) {
+		return d.handleLocalFileSet(path, absPath, true)
+	}
+	
 	// Check if file exists and get info
 	fileInfo, err := os.Stat(absPath)
 	if err != nil {
@@ -2349,18 +3848,22 @@ func (d *Daemon) handleLocalFile(path string) (*resolution.FileContent, error) {
 		return nil, fmt.Errorf("failed to access file: %w", err)
 	}
 	
+	// Directories collect their allowed files recursively
+	if fileInfo.IsDir() {
+		return d.handleLocalFileSet(path, absPath, false)
+	}
+	
 	// Security: Check file size (prevent memory exhaustion)
-	const maxFileSize = 1 * 1024 * 1024 // 1MB
-	if fileInfo.Size() > maxFileSize {
-		log.Printf("🚨 SECURITY WARNING: Large file access attempt blocked - %s (%d bytes > %d bytes)", 
-			path, fileInfo.Size(), maxFileSize)
+	if fileInfo.Size() > maxReferenceFileSize {
+		logger.Warnf("🚨 SECURITY WARNING: Large file access attempt blocked - %s (%d bytes > %d bytes)", 
+			path, fileInfo.Size(), maxReferenceFileSize)
 		return nil, fmt.Errorf("file too large: %s (size: %d bytes, max: %d bytes)", 
-			path, fileInfo.Size(), maxFileSize)
+			path, fileInfo.Size(), maxReferenceFileSize)
 	}
 	
 	// Security: Only allow certain file types
 	if !d.isFileTypeAllowed(absPath) {
-		log.Printf("🚨 SECURITY WARNING: Disallowed file type access attempt blocked - %s", path)
+		logger.Warnf("🚨 SECURITY WARNING: Disallowed file type access attempt blocked - %s", path)
 		return nil, fmt.Errorf("file type not allowed: %s", path)
 	}
 	
@@ -2373,7 +3876,7 @@ func (d *Daemon) handleLocalFile(path string) (*resolution.FileContent, error) {
 	// Detect content type
 	contentType := d.detectFileType(absPath, content)
 	
-	log.Printf("✅ Local file accessed: %s (%d bytes, type: %s)", path, len(content), contentType)
+	logger.Infof("✅ Local file accessed: %s (%d bytes, type: %s)", path, len(content), contentType)
 	
 	return &resolution.FileContent{
 		Path:    path, // Return original path requested
@@ -2391,44 +3894,16 @@ func (d *Daemon) handleLocalFile(path string) (*resolution.FileContent, error) {
 
 // isFileAccessAllowed checks if file access is within security boundaries
 func (d *Daemon) isFileAccessAllowed(absPath string) bool {
-	// Get current working directory
-	cwd, err := os.Getwd()
-	if err != nil {
-		log.Printf("⚠️ Failed to get working directory for security check: %v", err)
-		return false
-	}
-	
-	// Allow files within current working directory tree
-	if strings.HasPrefix(absPath, cwd) {
-		return true
-	}
-	
-	// Allow common config directories relative to home
-	if homeDir, err := os.UserHomeDir(); err == nil {
-		// Allow .port42 directory
-		port42Dir := filepath.Join(homeDir, ".port42")
-		if strings.HasPrefix(absPath, port42Dir) {
-			return true
-		}
-		
-		// Allow files directly in home directory (like ~/test.txt)
-		// but still exclude sensitive system paths
-		if strings.HasPrefix(absPath, homeDir) && !strings.Contains(absPath, "/.ssh/") && 
-		   !strings.Contains(absPath, "/.gnupg/") && !strings.Contains(absPath, "/.aws/") {
-			return true
-		}
+	policy := d.fileAccess
+	if policy == nil {
+		policy = defaultFileAccessPolicy()
 	}
 	
-	// Deny access to system directories and files outside project
-	systemPaths := []string{"/etc", "/usr", "/var", "/bin", "/sbin", "/sys", "/proc"}
-	for _, sysPath := range systemPaths {
-		if strings.HasPrefix(absPath, sysPath) {
-			return false
-		}
+	if !policy.Allows(absPath) {
+		logger.Warnf("⚠️ File access denied for security: %s (outside file access policy)", absPath)
+		return false
 	}
-	
-	log.Printf("⚠️ File access denied for security: %s (not within working directory: %s)", absPath, cwd)
-	return false
+	return true
 }
 
 // isFileTypeAllowed checks if file extension/type is allowed
@@ -2500,7 +3975,7 @@ func (d *Daemon) handleP42File(p42Path string) (*resolution.FileContent, error)
 		return nil, fmt.Errorf("empty P42 path: %s", p42Path)
 	}
 	
-	log.Printf("🔍 P42 VFS access: %s", p42Path)
+	logger.Debugf("🔍 P42 VFS access: %s", p42Path)
 	
 	// Method 1: Handle /tools/ paths via Relations store
 	if strings.HasPrefix(p42Path, "/tools/") {
@@ -2544,7 +4019,7 @@ func (d *Daemon) handleP42ToolPath(p42Path string) (*resolution.FileContent, err
 			// Build content from tool relation
 			content := d.formatToolRelationAsP42Content(relation)
 			
-			log.Printf("✅ P42 tool found: %s -> %s", toolName, relation.ID)
+			logger.Debugf("✅ P42 tool found: %s -> %s", toolName, relation.ID)
 			// Extract agent from properties for proper info display
 			metadata := map[string]interface{}{
 				"relation_id": relation.ID,
@@ -2575,7 +4050,7 @@ func (d *Daemon) handleP42ToolPath(p42Path string) (*resolution.FileContent, err
 // handleP42CommandPath resolves /commands/name paths via VFS direct access
 func (d *Daemon) handleP42CommandPath(p42Path string) (*resolution.FileContent, error) {
 	// Use VFS to resolve path to object ID - same pattern as port42 cat
-	log.Printf("🔍 P42 command path resolution via VFS: %s", p42Path)
+	logger.Debugf("🔍 P42 command path resolution via VFS: %s", p42Path)
 	
 	objID := d.resolvePath(p42Path)
 	if objID == "" {
@@ -2588,7 +4063,7 @@ func (d *Daemon) handleP42CommandPath(p42Path string) (*resolution.FileContent,
 		return nil, fmt.Errorf("failed to read command content: %w", err)
 	}
 	
-	log.Printf("✅ P42 command found via VFS: %s -> %s (size: %d bytes)", p42Path, objID, len(content))
+	logger.Debugf("✅ P42 command found via VFS: %s -> %s (size: %d bytes)", p42Path, objID, len(content))
 	return &resolution.FileContent{
 		Path:    p42Path,
 		Content: string(content),
@@ -2623,7 +4098,7 @@ func (d *Daemon) handleP42MemoryPath(p42Path string) (*resolution.FileContent, e
 		return nil, fmt.Errorf("failed to load session %s: %w", sessionID, err)
 	}
 	
-	log.Printf("✅ P42 memory found: %s -> session with %d messages", sessionID, len(session.Messages))
+	logger.Debugf("✅ P42 memory found: %s -> session with %d messages", sessionID, len(session.Messages))
 	
 	// Format session as conversation transcript
 	var content strings.Builder
@@ -2686,7 +4161,7 @@ func (d *Daemon) handleP42SearchPath(p42Path string) (*resolution.FileContent, e
 		return nil, fmt.Errorf("failed to read P42 content: %w", err)
 	}
 	
-	log.Printf("✅ P42 path resolved via search: %s -> %s (score: %.2f)", 
+	logger.Debugf("✅ P42 path resolved via search: %s -> %s (score: %.2f)", 
 		p42Path, bestResult.Path, bestResult.Score)
 	
 	return &resolution.FileContent{
@@ -2752,7 +4227,7 @@ func (d *Daemon) handleRelationInfo(requestID, path, objID string) Response {
 	}
 	
 	// Extract data from relation properties
-	var objectType, title, description, agent string
+	var objectType, title, description string
 	var size int64
 	
 	// Type from relation.Type
@@ -2765,9 +4240,6 @@ func (d *Daemon) handleRelationInfo(requestID, path, objID string) Response {
 	if desc, ok := relation.Properties["description"].(string); ok {
 		description = desc
 	}
-	if ag, ok := relation.Properties["agent"].(string); ok {
-		agent = ag
-	}
 	
 	// Calculate size from relation JSON
 	if relationData, err := json.Marshal(relation); err == nil {
@@ -2787,14 +4259,15 @@ func (d *Daemon) handleRelationInfo(requestID, path, objID string) Response {
 		"title":       title,
 		"description": description,
 		
-		// Context
-		"agent":       agent,
-		"session":     relation.Properties["session_id"],
-		"source":      relation.Properties["source"], // "declare" or "swim"
 		"language":    relation.Properties["language"],
 		"transforms":  relation.Properties["transforms"],
 	}
 	
+	// Context: source, session, agent and user_prompt
+	for key, value := range relationProvenance(*relation) {
+		responseData[key] = value
+	}
+	
 	resp.SetData(responseData)
 	return resp
 }
\ No newline at end of file
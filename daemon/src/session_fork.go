@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SessionForkResult describes a session created by fork_session
+type SessionForkResult struct {
+	SessionID    string   `json:"session_id"`
+	Agent        string   `json:"agent"`
+	ForkedFrom   string   `json:"forked_from"`
+	ForkPoint    int      `json:"fork_point"` // Messages copied from the source
+	MessageCount int      `json:"message_count"`
+	Paths        []string `json:"paths"`
+}
+
+// sessionDescription is the description stored with a session object
+func sessionDescription(session *Session) string {
+	if session.ForkedFrom != "" {
+		return fmt.Sprintf("AI conversation with %s, forked from %s", session.Agent, session.ForkedFrom)
+	}
+	return fmt.Sprintf("AI conversation with %s", session.Agent)
+}
+
+// sessionSnapshot copies a session's fields, preferring the in-memory
+// session, which may hold messages whose save is still pending
+func (d *Daemon) sessionSnapshot(sessionID string) (*Session, error) {
+	d.mu.RLock()
+	session, exists := d.sessions[sessionID]
+	d.mu.RUnlock()
+
+	if exists {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		return &Session{
+			ID:         session.ID,
+			Agent:      session.Agent,
+			CreatedAt:  session.CreatedAt,
+			State:      session.State,
+			Messages:   append([]Message(nil), session.Messages...),
+			ForkedFrom: session.ForkedFrom,
+			ForkPoint:  session.ForkPoint,
+		}, nil
+	}
+
+	if d.storage == nil {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	return d.storage.LoadSession(sessionID)
+}
+
+// forkSession creates a session whose messages are a copy of the source's
+// first upTo messages, or all of them when upTo is nil. The source is not
+// changed. The fork is saved straight away and, like any saved session,
+// loaded into memory when a swim names it.
+func (d *Daemon) forkSession(sourceID string, upTo *int, newID string) (*Session, error) {
+	if d.storage == nil {
+		return nil, fmt.Errorf("Storage not initialized")
+	}
+
+	source, err := d.sessionSnapshot(sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	forkPoint := len(source.Messages)
+	if upTo != nil {
+		if *upTo < 0 || *upTo > len(source.Messages) {
+			return nil, fmt.Errorf("message_index %d out of range: session %s has %d messages", *upTo, sourceID, len(source.Messages))
+		}
+		forkPoint = *upTo
+	}
+
+	if newID == "" {
+		newID = "fork-" + generateID()
+	} else if _, err := d.sessionSnapshot(newID); err == nil {
+		return nil, fmt.Errorf("session already exists: %s", newID)
+	}
+
+	now := time.Now()
+	fork := &Session{
+		ID:           newID,
+		Agent:        source.Agent,
+		CreatedAt:    now,
+		LastActivity: now,
+		State:        SessionIdle,
+		Messages:     append([]Message{}, source.Messages[:forkPoint]...),
+		IdleTimeout:  d.config.IdleTimeout,
+		ForkedFrom:   sourceID,
+		ForkPoint:    forkPoint,
+	}
+	if err := d.storage.SaveSession(fork); err != nil {
+		return nil, fmt.Errorf("failed to save fork: %v", err)
+	}
+
+	logger.Infof("🍴 Forked session %s from %s at message %d", newID, sourceID, forkPoint)
+	return fork, nil
+}
+
+// handleForkSession copies a session, up to a message, into a new session
+// that can be continued without touching the original
+func (d *Daemon) handleForkSession(req Request) Response {
+	var payload struct {
+		SessionID    string `json:"session_id"`
+		MessageIndex *int   `json:"message_index,omitempty"`  // Copy messages before this index; all when omitted
+		NewSessionID string `json:"new_session_id,omitempty"` // Defaults to a generated fork-<id>
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if payload.SessionID == "" {
+		return NewErrorResponse(req.ID, "session_id is required")
+	}
+	if strings.Contains(payload.NewSessionID, "/") {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Invalid session ID: %q", payload.NewSessionID))
+	}
+
+	fork, err := d.forkSession(payload.SessionID, payload.MessageIndex, payload.NewSessionID)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(SessionForkResult{
+		SessionID:    fork.ID,
+		Agent:        fork.Agent,
+		ForkedFrom:   fork.ForkedFrom,
+		ForkPoint:    fork.ForkPoint,
+		MessageCount: len(fork.Messages),
+		Paths:        []string{"/memory/" + fork.ID, "/memory/sessions/" + fork.ID},
+	})
+	return resp
+}
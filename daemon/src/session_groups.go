@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultRecentSessionDays is how far back memory lists sessions when the
+// request doesn't say
+const defaultRecentSessionDays = 7
+
+// SessionDateGroup is one day of recent sessions, the day a session was
+// created as in its /memory/sessions/by-date path
+type SessionDateGroup struct {
+	Date     string           `json:"date"` // 2006-01-02
+	Path     string           `json:"path"`
+	Count    int              `json:"count"`
+	Agents   []string         `json:"agents"` // Agents with sessions that day, sorted
+	Sessions []SessionSummary `json:"sessions"`
+}
+
+// groupSessionsByDate groups summaries by creation day, newest day first
+// and newest session first within a day
+func groupSessionsByDate(summaries []SessionSummary) []SessionDateGroup {
+	sorted := append([]SessionSummary(nil), summaries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	groups := []SessionDateGroup{}
+	agents := make(map[string]bool)
+	for _, summary := range sorted {
+		date := summary.CreatedAt.Format("2006-01-02")
+		if len(groups) == 0 || groups[len(groups)-1].Date != date {
+			groups = append(groups, SessionDateGroup{
+				Date:   date,
+				Path:   "/memory/sessions/by-date/" + date,
+				Agents: []string{},
+			})
+			agents = make(map[string]bool)
+		}
+		group := &groups[len(groups)-1]
+		group.Sessions = append(group.Sessions, summary)
+		group.Count++
+		if agent := "@" + strings.TrimPrefix(summary.Agent, "@"); summary.Agent != "" && !agents[agent] {
+			agents[agent] = true
+			group.Agents = append(group.Agents, agent)
+			sort.Strings(group.Agents)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionIndexRebuild summarizes how a rebuild changed session-index.json
+type SessionIndexRebuild struct {
+	Sessions  int      `json:"sessions"`
+	Added     []string `json:"added"`     // On disk but missing from the index
+	Removed   []string `json:"removed"`   // Indexed but no session object exists
+	Corrected []string `json:"corrected"` // Indexed with a stale object or summary
+}
+
+// RebuildSessionIndex reconstructs session-index.json from the session
+// objects on disk, for when the index has drifted after a crash mid-save
+// or objects were removed by hand
+func (s *Storage) RebuildSessionIndex() error {
+	_, err := s.rebuildSessionIndex()
+	return err
+}
+
+func (s *Storage) rebuildSessionIndex() (SessionIndexRebuild, error) {
+	report := SessionIndexRebuild{Added: []string{}, Removed: []string{}, Corrected: []string{}}
+
+	// Every saved version of a session is its own object; newest first
+	versions := make(map[string][]*Metadata)
+	docs, _ := s.searchIndex.snapshot("", "")
+	for _, meta := range docs {
+		if meta.Type == "session" && meta.Session != "" {
+			versions[meta.Session] = append(versions[meta.Session], meta)
+		}
+	}
+
+	rebuilt := make(map[string]SessionReference, len(versions))
+	for sessionID, metas := range versions {
+		sort.Slice(metas, func(i, j int) bool {
+			return metas[i].Created.After(metas[j].Created)
+		})
+		for _, meta := range metas {
+			ref, err := s.sessionReferenceFromObject(meta)
+			if err != nil {
+				logger.Warnf("⚠️ [STORAGE] Skipping unreadable session object %s: %v", meta.ID[:12]+"...", err)
+				continue
+			}
+			rebuilt[sessionID] = ref
+			break
+		}
+	}
+
+	s.indexMutex.Lock()
+	defer s.indexMutex.Unlock()
+
+	old := s.sessionIndex.Sessions
+	for sessionID, ref := range rebuilt {
+		existing, ok := old[sessionID]
+		switch {
+		case !ok:
+			report.Added = append(report.Added, sessionID)
+		case existing.ObjectID != ref.ObjectID || existing.State != ref.State ||
+			existing.MessageCount != ref.MessageCount || existing.Agent != ref.Agent:
+			report.Corrected = append(report.Corrected, sessionID)
+		}
+	}
+	for sessionID := range old {
+		if _, ok := rebuilt[sessionID]; !ok {
+			report.Removed = append(report.Removed, sessionID)
+		}
+	}
+	sort.Strings(report.Added)
+	sort.Strings(report.Removed)
+	sort.Strings(report.Corrected)
+
+	// Keep each agent's last session if it still exists, otherwise use the
+	// agent's most recently updated one
+	lastSessions := make(map[string]string)
+	for agent, sessionID := range s.sessionIndex.LastSessions {
+		if ref, ok := rebuilt[sessionID]; ok && ref.Agent == agent {
+			lastSessions[agent] = sessionID
+		}
+	}
+	latest := make(map[string]SessionReference)
+	for _, ref := range rebuilt {
+		if ref.Agent == "" {
+			continue
+		}
+		if current, ok := latest[ref.Agent]; !ok || ref.LastUpdated.After(current.LastUpdated) {
+			latest[ref.Agent] = ref
+		}
+	}
+	for agent, ref := range latest {
+		if _, ok := lastSessions[agent]; !ok {
+			lastSessions[agent] = ref.SessionID
+		}
+	}
+
+	index := &SessionIndex{
+		Sessions:     rebuilt,
+		LastSessions: lastSessions,
+		Metadata: SessionIndexMetadata{
+			Version:       "2.0",
+			LastUpdated:   time.Now(),
+			TotalSessions: len(rebuilt),
+		},
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return report, err
+	}
+	if err := atomicWriteFile(filepath.Join(s.baseDir, "session-index.json"), data, 0644); err != nil {
+		return report, fmt.Errorf("failed to write session index: %w", err)
+	}
+
+	s.sessionIndex = index
+	s.updateStats()
+	report.Sessions = len(rebuilt)
+
+	logger.Infof("🔧 [STORAGE] Rebuilt session index: %d sessions (%d added, %d removed, %d corrected)",
+		report.Sessions, len(report.Added), len(report.Removed), len(report.Corrected))
+	return report, nil
+}
+
+// sessionReferenceFromObject reads a session object and builds its index entry
+func (s *Storage) sessionReferenceFromObject(meta *Metadata) (SessionReference, error) {
+	data, err := s.Read(meta.ID)
+	if err != nil {
+		return SessionReference{}, err
+	}
+
+	var ps PersistentSession
+	if err := json.Unmarshal(data, &ps); err != nil {
+		return SessionReference{}, fmt.Errorf("failed to unmarshal session: %v", err)
+	}
+	if ps.ID == "" {
+		ps.ID = meta.Session
+	}
+
+	lastUpdated := ps.UpdatedAt
+	if lastUpdated.IsZero() {
+		lastUpdated = meta.Created
+	}
+
+	return SessionReference{
+		ObjectID:         meta.ID,
+		SessionID:        ps.ID,
+		Agent:            strings.TrimPrefix(ps.Agent, "@"),
+		CreatedAt:        ps.CreatedAt,
+		LastUpdated:      lastUpdated,
+		CommandGenerated: ps.CommandGenerated != nil,
+		State:            string(ps.State),
+		MessageCount:     len(ps.Messages),
+	}, nil
+}
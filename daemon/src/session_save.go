@@ -0,0 +1,156 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultSessionSaveDebounce is how long QueueSessionSave waits before
+// writing, so the saves after a user message and after the AI's answer
+// usually become one write
+const defaultSessionSaveDebounce = 3 * time.Second
+
+// queuedSessionSave is a session waiting for its debounced write
+type queuedSessionSave struct {
+	session *Session
+	timer   *time.Timer
+}
+
+// QueueSessionSave schedules a save of session. Calls for the same session
+// within PORT42_SESSION_SAVE_DEBOUNCE of the first are coalesced into one
+// write of its state at the time the window ends. SaveSession writes
+// immediately and cancels a queued save, so state transitions aren't
+// delayed; Close flushes whatever is still queued.
+// Callers must not hold session.mu.
+func (s *Storage) QueueSessionSave(session *Session) {
+	s.saveMu.Lock()
+	if s.savesClosed {
+		// Shutting down: write now, so the save isn't lost with the process
+		s.saveMu.Unlock()
+		s.saveQueuedSession(session)
+		return
+	}
+	defer s.saveMu.Unlock()
+
+	if s.sessionSaveDebounce <= 0 {
+		go s.saveQueuedSession(session)
+		return
+	}
+	if queued, ok := s.queuedSaves[session.ID]; ok {
+		queued.session = session
+		return
+	}
+	id := session.ID
+	s.queuedSaves[id] = &queuedSessionSave{
+		session: session,
+		timer:   time.AfterFunc(s.sessionSaveDebounce, func() { s.flushQueuedSave(id) }),
+	}
+}
+
+// dequeueSessionSave drops a queued save, returning the session it was for
+func (s *Storage) dequeueSessionSave(sessionID string) *Session {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	queued, ok := s.queuedSaves[sessionID]
+	if !ok {
+		return nil
+	}
+	queued.timer.Stop()
+	delete(s.queuedSaves, sessionID)
+	return queued.session
+}
+
+// flushQueuedSave writes a queued save once its debounce window ends
+func (s *Storage) flushQueuedSave(sessionID string) {
+	if session := s.dequeueSessionSave(sessionID); session != nil {
+		s.saveQueuedSession(session)
+	}
+}
+
+// saveQueuedSession writes a session on behalf of QueueSessionSave or the
+// daemon's background saves, where nobody waits for the result. The
+// session lock is held so messages appended meanwhile aren't half-written.
+// A failure leaves the session in UnsavedSessions until a save succeeds.
+func (s *Storage) saveQueuedSession(session *Session) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if err := s.SaveSession(session); err != nil {
+		logger.Errorf("❌ [STORAGE] Failed to save session %s: %v", session.ID, err)
+	}
+}
+
+// UnsavedSession is a session whose latest save failed, so what's on disk
+// is older than what the daemon holds
+type UnsavedSession struct {
+	SessionID   string    `json:"session_id"`
+	Agent       string    `json:"agent"`
+	Error       string    `json:"error"` // From the latest attempt
+	Since       time.Time `json:"since"` // First failure since the last successful save
+	LastAttempt time.Time `json:"last_attempt"`
+	Attempts    int       `json:"attempts"` // Failed saves since the last successful one
+}
+
+// recordSessionSave notes the outcome of a session save: a failure marks
+// the session unsaved, a success clears it
+func (s *Storage) recordSessionSave(session *Session, err error) {
+	s.unsavedMu.Lock()
+	defer s.unsavedMu.Unlock()
+
+	if err == nil {
+		if _, ok := s.unsaved[session.ID]; ok {
+			logger.Infof("💾 [STORAGE] Session %s saved again after failing", session.ID)
+			delete(s.unsaved, session.ID)
+		}
+		return
+	}
+
+	now := time.Now()
+	unsaved, ok := s.unsaved[session.ID]
+	if !ok {
+		unsaved = &UnsavedSession{SessionID: session.ID, Since: now}
+		s.unsaved[session.ID] = unsaved
+	}
+	unsaved.Agent = session.Agent
+	unsaved.Error = err.Error()
+	unsaved.LastAttempt = now
+	unsaved.Attempts++
+}
+
+// UnsavedSessions returns the sessions whose latest save failed, longest
+// failing first
+func (s *Storage) UnsavedSessions() []UnsavedSession {
+	s.unsavedMu.Lock()
+	defer s.unsavedMu.Unlock()
+
+	sessions := make([]UnsavedSession, 0, len(s.unsaved))
+	for _, unsaved := range s.unsaved {
+		sessions = append(sessions, *unsaved)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].Since.Equal(sessions[j].Since) {
+			return sessions[i].Since.Before(sessions[j].Since)
+		}
+		return sessions[i].SessionID < sessions[j].SessionID
+	})
+	return sessions
+}
+
+// FlushSessionSaves writes every queued session save now. Later queued
+// saves are written immediately.
+func (s *Storage) FlushSessionSaves() {
+	s.saveMu.Lock()
+	s.savesClosed = true
+	queued := s.queuedSaves
+	s.queuedSaves = make(map[string]*queuedSessionSave)
+	s.saveMu.Unlock()
+
+	for _, save := range queued {
+		save.timer.Stop()
+		s.saveQueuedSession(save.session)
+	}
+	if len(queued) > 0 {
+		logger.Debugf("💾 [STORAGE] Flushed %d queued session saves", len(queued))
+	}
+}
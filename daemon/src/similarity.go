@@ -11,6 +11,7 @@ import (
 // SimilarityCalculator handles tool similarity detection and scoring
 type SimilarityCalculator struct {
 	relationStore RelationStore
+	config        SimilarityConfig
 }
 
 // SimilarTool represents a tool with its similarity score to a target tool
@@ -20,10 +21,12 @@ type SimilarTool struct {
 	Reason     []string `json:"reason"`
 }
 
-// NewSimilarityCalculator creates a new similarity calculator
-func NewSimilarityCalculator(relationStore RelationStore) *SimilarityCalculator {
+// NewSimilarityCalculator creates a new similarity calculator using the
+// backend selected in config
+func NewSimilarityCalculator(relationStore RelationStore, config SimilarityConfig) *SimilarityCalculator {
 	return &SimilarityCalculator{
 		relationStore: relationStore,
+		config:        config,
 	}
 }
 
@@ -134,12 +137,64 @@ func calculateSemanticBoost(transforms1, transforms2 []string) float64 {
 	return math.Min(0.3, boost)
 }
 
-// findSimilarTools finds all tools similar to the target tool above the threshold
+// findSimilarTools finds all tools similar to the target tool above the threshold.
+// With the embedding backend it compares description embeddings, falling
+// back to the transform heuristic if embeddings can't be fetched.
 func (sc *SimilarityCalculator) findSimilarTools(targetTool Relation, threshold float64) ([]SimilarTool, error) {
 	if targetTool.Type != "Tool" {
 		return nil, fmt.Errorf("target relation is not a Tool: %s", targetTool.Type)
 	}
 	
+	// Load all relations
+	allRelations, err := sc.relationStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load relations: %v", err)
+	}
+	
+	return sc.similarToolsAmong(targetTool, allRelations, threshold)
+}
+
+// similarToolsAmong scores the tools in allRelations against the target,
+// so callers comparing several tools can load the relation store once
+func (sc *SimilarityCalculator) similarToolsAmong(targetTool Relation, allRelations []Relation, threshold float64) ([]SimilarTool, error) {
+	var err error
+	var candidates []Relation
+	for _, relation := range allRelations {
+		// Skip non-tools and self
+		if relation.Type != "Tool" || relation.ID == targetTool.ID {
+			continue
+		}
+		candidates = append(candidates, relation)
+	}
+	
+	var similarTools []SimilarTool
+	if sc.config.embeddings != nil {
+		similarTools, err = sc.embeddingSimilarTools(targetTool, candidates, threshold)
+		if err != nil {
+			log.Printf("⚠️ Embedding similarity unavailable, using heuristic: %v", err)
+		}
+	}
+	if sc.config.embeddings == nil || err != nil {
+		similarTools, err = sc.heuristicSimilarTools(targetTool, candidates, threshold)
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	// Sort by similarity score (highest first)
+	for i := 0; i < len(similarTools)-1; i++ {
+		for j := i + 1; j < len(similarTools); j++ {
+			if similarTools[i].Similarity < similarTools[j].Similarity {
+				similarTools[i], similarTools[j] = similarTools[j], similarTools[i]
+			}
+		}
+	}
+	
+	return similarTools, nil
+}
+
+// heuristicSimilarTools scores candidates by transform overlap
+func (sc *SimilarityCalculator) heuristicSimilarTools(targetTool Relation, candidates []Relation, threshold float64) ([]SimilarTool, error) {
 	// Get target tool transforms
 	targetTransforms, err := sc.extractTransforms(targetTool)
 	if err != nil {
@@ -150,21 +205,10 @@ func (sc *SimilarityCalculator) findSimilarTools(targetTool Relation, threshold
 		return []SimilarTool{}, nil // No transforms to compare against
 	}
 	
-	// Load all relations
-	allRelations, err := sc.relationStore.List()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load relations: %v", err)
-	}
-	
 	var similarTools []SimilarTool
 	
 	// Compare against each tool
-	for _, relation := range allRelations {
-		// Skip non-tools and self
-		if relation.Type != "Tool" || relation.ID == targetTool.ID {
-			continue
-		}
-		
+	for _, relation := range candidates {
 		// Extract transforms
 		candidateTransforms, err := sc.extractTransforms(relation)
 		if err != nil {
@@ -193,42 +237,44 @@ func (sc *SimilarityCalculator) findSimilarTools(targetTool Relation, threshold
 		}
 	}
 	
-	// Sort by similarity score (highest first)
-	for i := 0; i < len(similarTools)-1; i++ {
-		for j := i + 1; j < len(similarTools); j++ {
-			if similarTools[i].Similarity < similarTools[j].Similarity {
-				similarTools[i], similarTools[j] = similarTools[j], similarTools[i]
-			}
-		}
-	}
-	
 	return similarTools, nil
 }
 
-// extractTransforms safely extracts transforms array from relation properties
-func (sc *SimilarityCalculator) extractTransforms(relation Relation) ([]string, error) {
-	transformsRaw, exists := relation.Properties["transforms"]
-	if !exists {
-		return []string{}, nil
+// embeddingSimilarTools scores candidates by cosine similarity of their
+// description embeddings
+func (sc *SimilarityCalculator) embeddingSimilarTools(targetTool Relation, candidates []Relation, threshold float64) ([]SimilarTool, error) {
+	vectors, err := sc.config.embeddings.vectors(sc.relationStore, append([]Relation{targetTool}, candidates...))
+	if err != nil {
+		return nil, err
 	}
 	
-	// Handle []interface{} (JSON arrays)
-	if transformsInterface, ok := transformsRaw.([]interface{}); ok {
-		var transforms []string
-		for _, t := range transformsInterface {
-			if tStr, ok := t.(string); ok {
-				transforms = append(transforms, tStr)
-			}
-		}
-		return transforms, nil
-	}
+	targetVector := vectors[targetTool.ID]
+	targetTransforms, _ := sc.extractTransforms(targetTool)
 	
-	// Handle []string (direct string arrays)
-	if transformsString, ok := transformsRaw.([]string); ok {
-		return transformsString, nil
+	similarTools := []SimilarTool{}
+	for _, relation := range candidates {
+		similarity := cosineSimilarity(targetVector, vectors[relation.ID])
+		if similarity < threshold {
+			continue
+		}
+		
+		candidateTransforms, _ := sc.extractTransforms(relation)
+		reasons := append([]string{"Similar description (embedding)"},
+			sc.generateReasons(targetTransforms, candidateTransforms, similarity)...)
+		
+		similarTools = append(similarTools, SimilarTool{
+			Tool:       relation,
+			Similarity: similarity,
+			Reason:     reasons,
+		})
 	}
 	
-	return nil, fmt.Errorf("transforms property has unsupported type: %T", transformsRaw)
+	return similarTools, nil
+}
+
+// extractTransforms safely extracts transforms array from relation properties
+func (sc *SimilarityCalculator) extractTransforms(relation Relation) ([]string, error) {
+	return getTransforms(relation), nil
 }
 
 // generateReasons creates human-readable explanations for why tools are similar
@@ -281,21 +327,14 @@ func (sc *SimilarityCalculator) GetSimilarToolsForTool(toolName string, threshol
 		return nil, fmt.Errorf("failed to load relations: %v", err)
 	}
 	
-	var targetTool *Relation
-	for _, relation := range allRelations {
-		if relation.Type == "Tool" {
-			if name, ok := relation.Properties["name"].(string); ok && name == toolName {
-				targetTool = &relation
-				break
-			}
-		}
-	}
-	
-	if targetTool == nil {
+	// Resolved like /tools/{name}, so both agree on which tool it is
+	named := toolsNamed(allRelations, toolName)
+	if len(named) == 0 {
 		return nil, fmt.Errorf("tool not found: %s", toolName)
 	}
+	targetTool, _ := chooseToolRelation(toolName, named)
 	
-	return sc.findSimilarTools(*targetTool, threshold)
+	return sc.findSimilarTools(targetTool, threshold)
 }
 
 // createSimilarityRelationships stores similarity relationships in the relation system
@@ -310,8 +349,38 @@ func (sc *SimilarityCalculator) createSimilarityRelationships(tool Relation, thr
 		return fmt.Errorf("failed to find similar tools: %v", err)
 	}
 	
+	sc.storeSimilarityRelationships(tool, similarTools)
+	return nil
+}
+
+// createSimilarityRelationshipsForTools stores similarity relationships for
+// several newly declared tools, such as a batch declare, from a single load
+// of the relation store
+func (sc *SimilarityCalculator) createSimilarityRelationshipsForTools(tools []Relation, threshold float64) error {
+	allRelations, err := sc.relationStore.List()
+	if err != nil {
+		return fmt.Errorf("failed to load relations: %v", err)
+	}
+	
+	for _, tool := range tools {
+		if tool.Type != "Tool" {
+			continue
+		}
+		similarTools, err := sc.similarToolsAmong(tool, allRelations, threshold)
+		if err != nil {
+			log.Printf("⚠️ Failed to find similar tools for %s: %v", tool.ID, err)
+			continue
+		}
+		sc.storeSimilarityRelationships(tool, similarTools)
+	}
+	return nil
+}
+
+// storeSimilarityRelationships saves a bidirectional similar_to relationship
+// between tool and each of its similar tools
+func (sc *SimilarityCalculator) storeSimilarityRelationships(tool Relation, similarTools []SimilarTool) {
 	if len(similarTools) == 0 {
-		return nil // No similar tools found, nothing to store
+		return // No similar tools found, nothing to store
 	}
 	
 	// Create bidirectional similarity relationships
@@ -365,6 +434,4 @@ func (sc *SimilarityCalculator) createSimilarityRelationships(tool Relation, thr
 		log.Printf("✓ Created bidirectional similarity relationship: %s ↔ %s (%.0f%%)", 
 			tool.Properties["name"], simTool.Tool.Properties["name"], simTool.Similarity*100)
 	}
-	
-	return nil
 }
\ No newline at end of file
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Similarity backends accepted by PORT42_SIMILARITY
+const (
+	SimilarityHeuristic = "heuristic"
+	SimilarityEmbedding = "embedding"
+)
+
+// Default thresholds: /similar listings show matches from 0.2 up, and new
+// tools get similar_to relationships from 0.5 up
+const (
+	defaultSimilarityViewThreshold = 0.2
+	defaultSimilarityLinkThreshold = 0.5
+)
+
+const (
+	embeddingTimeout   = 60 * time.Second
+	embeddingBatchSize = 100
+	// After a failed embedding call, use the heuristic for this long before trying again
+	embeddingRetryAfter = 5 * time.Minute
+)
+
+// Relation properties caching a tool's embedding. The hash is of the text
+// that was embedded, so editing a tool's description re-embeds it.
+const (
+	propEmbedding      = "embedding"
+	propEmbeddingModel = "embedding_model"
+	propEmbeddingHash  = "embedding_hash"
+)
+
+// Embedder turns text into vectors. AI providers with an embeddings API
+// implement it alongside AIProvider.
+type Embedder interface {
+	EmbeddingModel() string
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// SimilarityConfig selects the similarity backend and its thresholds
+type SimilarityConfig struct {
+	Backend       string  // heuristic or embedding (PORT42_SIMILARITY)
+	ViewThreshold float64 // PORT42_SIMILARITY_THRESHOLD
+	LinkThreshold float64 // PORT42_SIMILARITY_LINK_THRESHOLD
+	AutoLink      bool    // Create similar_to relationships for new tools (PORT42_AUTO_SIMILARITY)
+
+	embeddings *embeddingBackend // Set when Backend is embedding and the provider can embed
+}
+
+// defaultSimilarityConfig is the heuristic backend with default thresholds
+func defaultSimilarityConfig() SimilarityConfig {
+	return SimilarityConfig{
+		Backend:       SimilarityHeuristic,
+		ViewThreshold: defaultSimilarityViewThreshold,
+		LinkThreshold: defaultSimilarityLinkThreshold,
+		AutoLink:      true,
+	}
+}
+
+// loadSimilarityConfig reads the PORT42_SIMILARITY* settings. The embedding
+// backend needs a provider that implements Embedder; without one it logs
+// and stays on the heuristic.
+func loadSimilarityConfig(provider AIProvider) SimilarityConfig {
+	config := defaultSimilarityConfig()
+	config.ViewThreshold = envThreshold("PORT42_SIMILARITY_THRESHOLD", defaultSimilarityViewThreshold)
+	config.LinkThreshold = envThreshold("PORT42_SIMILARITY_LINK_THRESHOLD", defaultSimilarityLinkThreshold)
+	config.AutoLink = envBool("PORT42_AUTO_SIMILARITY", true)
+
+	switch backend := strings.ToLower(envString("PORT42_SIMILARITY", SimilarityHeuristic)); backend {
+	case SimilarityEmbedding:
+		embedder, ok := provider.(Embedder)
+		if !ok || provider == nil || !provider.Available() {
+			name := "none"
+			if provider != nil {
+				name = provider.Name()
+			}
+			log.Printf("⚠️ PORT42_SIMILARITY=embedding but AI provider %s can't embed, using heuristic similarity", name)
+			break
+		}
+		config.Backend = SimilarityEmbedding
+		config.embeddings = &embeddingBackend{embedder: embedder}
+	case SimilarityHeuristic:
+	default:
+		log.Printf("⚠️ Unknown PORT42_SIMILARITY %q, using heuristic", backend)
+	}
+
+	if config.AutoLink {
+		log.Printf("🧭 Similarity backend: %s, view threshold %.2f, link threshold %.2f",
+			config.Describe(), config.ViewThreshold, config.LinkThreshold)
+	} else {
+		log.Printf("🧭 Similarity backend: %s, view threshold %.2f, automatic similar_to links off",
+			config.Describe(), config.ViewThreshold)
+	}
+	return config
+}
+
+// Describe names the active backend, including the embedding model
+func (c SimilarityConfig) Describe() string {
+	if c.embeddings != nil {
+		return fmt.Sprintf("%s (%s)", c.Backend, c.embeddings.embedder.EmbeddingModel())
+	}
+	return SimilarityHeuristic
+}
+
+// envThreshold reads a similarity threshold between 0 and 1
+func envThreshold(name string, def float64) float64 {
+	value := envFloat(name, def)
+	if value < 0 || value > 1 {
+		log.Printf("⚠️ %s must be between 0 and 1, using %v", name, def)
+		return def
+	}
+	return value
+}
+
+// embeddingBackend embeds tools on demand and caches vectors in relation
+// properties. It is shared by every SimilarityCalculator so a failing API
+// is backed off once rather than per request.
+type embeddingBackend struct {
+	embedder Embedder
+
+	mu               sync.Mutex
+	unavailableUntil time.Time
+}
+
+// vectors returns an embedding for each tool, embedding and saving the ones
+// whose cache is missing or stale
+func (b *embeddingBackend) vectors(store RelationStore, tools []Relation) (map[string][]float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if time.Now().Before(b.unavailableUntil) {
+		return nil, fmt.Errorf("embeddings unavailable until %s", b.unavailableUntil.Format("15:04:05"))
+	}
+
+	model := b.embedder.EmbeddingModel()
+	vectors := make(map[string][]float64, len(tools))
+	var stale []Relation
+	var texts []string
+	for _, tool := range tools {
+		text := toolEmbeddingText(tool)
+		if vector, ok := cachedEmbedding(tool, model, text); ok {
+			vectors[tool.ID] = vector
+			continue
+		}
+		stale = append(stale, tool)
+		texts = append(texts, text)
+	}
+
+	for start := 0; start < len(stale); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(stale) {
+			end = len(stale)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), embeddingTimeout)
+		embedded, err := b.embedder.Embed(ctx, texts[start:end])
+		cancel()
+		if err == nil && len(embedded) != end-start {
+			err = fmt.Errorf("got %d embeddings for %d tools", len(embedded), end-start)
+		}
+		if err != nil {
+			b.unavailableUntil = time.Now().Add(embeddingRetryAfter)
+			return nil, err
+		}
+
+		for i, vector := range embedded {
+			tool := stale[start+i]
+			vectors[tool.ID] = vector
+			storeEmbedding(store, tool, model, texts[start+i], vector)
+		}
+	}
+	if len(stale) > 0 {
+		log.Printf("🧭 Embedded %d tool descriptions with %s", len(stale), model)
+	}
+	return vectors, nil
+}
+
+// toolEmbeddingText is what gets embedded for a tool: its name, description and transforms
+func toolEmbeddingText(tool Relation) string {
+	var parts []string
+	if name, ok := tool.Properties["name"].(string); ok && name != "" {
+		parts = append(parts, name)
+	}
+	if description, ok := tool.Properties["description"].(string); ok && description != "" {
+		parts = append(parts, description)
+	}
+	if transforms := getTransforms(tool); len(transforms) > 0 {
+		parts = append(parts, "transforms: "+strings.Join(transforms, ", "))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// embeddingTextHash identifies the text a cached embedding was made from
+func embeddingTextHash(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return fmt.Sprintf("%x", hash[:8])
+}
+
+// cachedEmbedding returns the tool's cached vector if it was made by model from text
+func cachedEmbedding(tool Relation, model, text string) ([]float64, bool) {
+	if cachedModel, _ := tool.Properties[propEmbeddingModel].(string); cachedModel != model {
+		return nil, false
+	}
+	if cachedHash, _ := tool.Properties[propEmbeddingHash].(string); cachedHash != embeddingTextHash(text) {
+		return nil, false
+	}
+	encoded, _ := tool.Properties[propEmbedding].(string)
+	vector, err := decodeEmbedding(encoded)
+	if err != nil || len(vector) == 0 {
+		return nil, false
+	}
+	return vector, true
+}
+
+// storeEmbedding caches a vector on the tool relation. A failed save only
+// costs a re-embed next time, so it is logged rather than returned.
+func storeEmbedding(store RelationStore, tool Relation, model, text string, vector []float64) {
+	properties := make(map[string]interface{}, len(tool.Properties)+3)
+	for k, v := range tool.Properties {
+		properties[k] = v
+	}
+	properties[propEmbedding] = encodeEmbedding(vector)
+	properties[propEmbeddingModel] = model
+	properties[propEmbeddingHash] = embeddingTextHash(text)
+	tool.Properties = properties
+
+	if err := store.Save(tool); err != nil {
+		log.Printf("⚠️ Failed to cache embedding for %s: %v", tool.ID, err)
+	}
+}
+
+// encodeEmbedding packs a vector as base64 little-endian float32s, which
+// keeps relation files small compared to a JSON number array
+func encodeEmbedding(vector []float64) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// decodeEmbedding reverses encodeEmbedding
+func decodeEmbedding(encoded string) ([]float64, error) {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("embedding has %d bytes, not a multiple of 4", len(buf))
+	}
+	vector := make([]float64, len(buf)/4)
+	for i := range vector {
+		vector[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+	}
+	return vector, nil
+}
+
+// cosineSimilarity of two vectors, clamped to 0..1 so scores compare with
+// the heuristic's. Mismatched or zero vectors score 0.
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return math.Max(0, math.Min(1, dot/(math.Sqrt(normA)*math.Sqrt(normB))))
+}
@@ -6,35 +6,53 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// AgentSessions manages last session tracking per agent
+// agentSessionsFlushDelay is how long AgentSessions waits after an update
+// before writing agent_sessions.json, so a burst of updates is one write
+const agentSessionsFlushDelay = time.Second
+
+// AgentSessions manages last session tracking per agent. Updates change the
+// map at once and are written to disk by a single pending flush.
+//
+// Locking: mu guards the map and the flush state and is only held for
+// in-memory work, never across disk I/O or while taking another lock.
+// AgentSessions never takes Storage.indexMutex, so its methods are safe to
+// call with or without indexMutex held (the order is always indexMutex, then mu).
+// writeMu serializes file writes so an older snapshot can't overwrite a newer one.
 type AgentSessions struct {
-	mu       sync.RWMutex
+	mu       sync.Mutex
 	sessions map[string]string // agent -> sessionID
 	filePath string
+	
+	flushDelay time.Duration
+	dirty      bool        // The map has changes not yet written
+	flushTimer *time.Timer // Pending flush; at most one at a time
+	closed     bool        // After Close, updates are written immediately
+	
+	writeMu sync.Mutex
 }
 
 // NewAgentSessions creates a new agent session tracker
 func NewAgentSessions(baseDir string) *AgentSessions {
 	return &AgentSessions{
-		sessions: make(map[string]string),
-		filePath: filepath.Join(baseDir, "agent_sessions.json"),
+		sessions:   make(map[string]string),
+		filePath:   filepath.Join(baseDir, "agent_sessions.json"),
+		flushDelay: agentSessionsFlushDelay,
 	}
 }
 
 // Load reads agent sessions from disk
 func (as *AgentSessions) Load() error {
-	as.mu.Lock()
-	defer as.mu.Unlock()
-	
 	data, err := os.ReadFile(as.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -44,67 +62,144 @@ func (as *AgentSessions) Load() error {
 		return fmt.Errorf("failed to read agent sessions: %w", err)
 	}
 	
-	if err := json.Unmarshal(data, &as.sessions); err != nil {
+	sessions := make(map[string]string)
+	if err := json.Unmarshal(data, &sessions); err != nil {
 		return fmt.Errorf("failed to parse agent sessions: %w", err)
 	}
 	
-	log.Printf("📌 [AGENT_SESSIONS] Loaded sessions for %d agents", len(as.sessions))
+	as.mu.Lock()
+	as.sessions = sessions
+	as.mu.Unlock()
+	
+	logger.Infof("📌 [AGENT_SESSIONS] Loaded sessions for %d agents", len(sessions))
 	return nil
 }
 
-// Save writes agent sessions to disk
+// Save writes agent sessions to disk now
 func (as *AgentSessions) Save() error {
-	as.mu.RLock()
-	defer as.mu.RUnlock()
+	as.mu.Lock()
+	as.dirty = true
+	as.mu.Unlock()
 	
-	data, err := json.MarshalIndent(as.sessions, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal agent sessions: %w", err)
+	return as.Flush()
+}
+
+// Flush writes pending changes to disk, if there are any
+func (as *AgentSessions) Flush() error {
+	as.writeMu.Lock()
+	defer as.writeMu.Unlock()
+	
+	as.mu.Lock()
+	if as.flushTimer != nil {
+		as.flushTimer.Stop()
+		as.flushTimer = nil
 	}
+	if !as.dirty {
+		as.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(as.sessions, "", "  ")
+	as.dirty = false
+	as.mu.Unlock()
 	
-	if err := os.WriteFile(as.filePath, data, 0644); err != nil {
+	if err == nil {
+		err = atomicWriteFile(as.filePath, data, 0644)
+	}
+	if err != nil {
+		// Keep the changes pending so the next flush retries them
+		as.mu.Lock()
+		as.dirty = true
+		as.mu.Unlock()
 		return fmt.Errorf("failed to write agent sessions: %w", err)
 	}
-	
 	return nil
 }
 
+// Close writes pending changes; later updates are written immediately
+func (as *AgentSessions) Close() error {
+	as.mu.Lock()
+	as.closed = true
+	as.mu.Unlock()
+	
+	return as.Flush()
+}
+
+// changed schedules a flush after an update. Called with mu held; returns
+// true when the caller must flush itself after unlocking.
+func (as *AgentSessions) changed() bool {
+	as.dirty = true
+	if as.closed || as.flushDelay <= 0 {
+		return true
+	}
+	if as.flushTimer == nil {
+		as.flushTimer = time.AfterFunc(as.flushDelay, func() {
+			if err := as.Flush(); err != nil {
+				logger.Errorf("❌ [AGENT_SESSIONS] %v", err)
+			}
+		})
+	}
+	return false
+}
+
 // GetLastSession returns the last session for an agent
 func (as *AgentSessions) GetLastSession(agent string) (string, bool) {
-	as.mu.RLock()
-	defer as.mu.RUnlock()
+	as.mu.Lock()
+	defer as.mu.Unlock()
 	
 	sessionID, exists := as.sessions[agent]
 	return sessionID, exists
 }
 
-// SetLastSession updates the last session for an agent
-func (as *AgentSessions) SetLastSession(agent, sessionID string) error {
+// ClearLastSession removes an agent's last-session entry if it points at sessionID
+func (as *AgentSessions) ClearLastSession(agent, sessionID string) error {
 	as.mu.Lock()
-	defer as.mu.Unlock()
-	
-	as.sessions[agent] = sessionID
-	
-	// Save immediately for persistence
-	data, err := json.MarshalIndent(as.sessions, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal agent sessions: %w", err)
+	if as.sessions[agent] != sessionID {
+		as.mu.Unlock()
+		return nil
 	}
+	delete(as.sessions, agent)
+	flushNow := as.changed()
+	as.mu.Unlock()
 	
-	if err := os.WriteFile(as.filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write agent sessions: %w", err)
+	logger.Debugf("📌 [AGENT_SESSIONS] Cleared %s -> %s", agent, sessionID)
+	if flushNow {
+		return as.Flush()
+	}
+	return nil
+}
+
+// SetLastSession updates the last session for an agent. The change is
+// visible at once and reaches disk with the next flush.
+func (as *AgentSessions) SetLastSession(agent, sessionID string) error {
+	as.mu.Lock()
+	if as.sessions[agent] == sessionID {
+		as.mu.Unlock()
+		return nil // Unchanged; nothing to write
 	}
+	as.sessions[agent] = sessionID
+	flushNow := as.changed()
+	as.mu.Unlock()
 	
-	log.Printf("📌 [AGENT_SESSIONS] Updated %s -> %s", agent, sessionID)
+	logger.Debugf("📌 [AGENT_SESSIONS] Updated %s -> %s", agent, sessionID)
+	if flushNow {
+		return as.Flush()
+	}
 	return nil
 }
 
+// accessFlushInterval is how often batched access times are written to disk
+const accessFlushInterval = 30 * time.Second
+
 // Storage provides unified storage for all Port 42 data
 type Storage struct {
 	baseDir     string
 	objectsDir  string
 	metadataDir string
 	
+	// Object layout (whole files, or chunked when PORT42_CHUNK_DEDUP is set)
+	objects      ObjectStore
+	objectLayout shardLayout // Where whole object files live (PORT42_OBJECT_SHARD_DEPTH)
+	
 	// Session index for quick lookups
 	sessionIndex *SessionIndex
 	indexMutex   sync.RWMutex
@@ -115,8 +210,57 @@ type Storage struct {
 	// Relations integration for virtual filesystem
 	relationStore RelationStore
 	
+	// Tool name conflicts already logged (see resolveTool)
+	toolConflicts sync.Map
+	
+	// Source for the /commands listing (PORT42_COMMANDS_VIEW)
+	commandsViewSource string
+	
+	// Rehash object content on every Read (PORT42_VERIFY_READS)
+	verifyReads bool
+	
+	// Idle timeout given to sessions loaded from disk (PORT42_IDLE_TIMEOUT)
+	sessionIdleTimeout time.Duration
+	
+	// Backend and thresholds for /similar views (PORT42_SIMILARITY)
+	similarity SimilarityConfig
+	
+	// In-memory metadata search index, kept current by writeMetadata
+	searchIndex *searchIndex
+	
+	// Largest object any search reads content from (PORT42_MAX_CONTENT_SEARCH_SIZE)
+	contentSearchCeiling int64
+	
+	// Per-object locks around the exists/put/metadata write sequence
+	objectLocks idLocks
+	
+	// Per-object locks around every load/modify/save of metadata. Taken
+	// before accessMu, never while holding it.
+	metadataLocks idLocks
+	
+	// Batched access-time updates (see TouchAccessed)
+	accessMu      sync.Mutex
+	pendingAccess map[string]time.Time
+	runLogMu      sync.Mutex // Appends to runs.jsonl and its rotation
+	stopFlush     chan struct{}
+	closeOnce     sync.Once
+	
+	// Debounced session saves (see QueueSessionSave)
+	saveMu              sync.Mutex
+	queuedSaves         map[string]*queuedSessionSave
+	savesClosed         bool
+	sessionSaveDebounce time.Duration // PORT42_SESSION_SAVE_DEBOUNCE
+	
+	// Sessions whose latest save failed (see UnsavedSessions)
+	unsavedMu sync.Mutex
+	unsaved   map[string]*UnsavedSession
+	
 	// Stats
 	stats StorageStats
+	
+	// Running on-disk byte totals, measured at startup and adjusted on write
+	objectBytes   atomic.Int64
+	metadataBytes atomic.Int64
 }
 
 // StorageStats tracks storage metrics
@@ -125,7 +269,9 @@ type StorageStats struct {
 	ActiveSessions     int       `json:"active_sessions"`
 	CompletedSessions  int       `json:"completed_sessions"`
 	TotalObjects       int       `json:"total_objects"`
-	StorageSize        int64     `json:"storage_size"`
+	StorageSize        int64     `json:"storage_size"`   // ObjectBytes + MetadataBytes
+	ObjectBytes        int64     `json:"object_bytes"`   // objects/, plus chunks/ and manifests/ when chunking
+	MetadataBytes      int64     `json:"metadata_bytes"` // metadata/*.json
 	LastUpdated        time.Time `json:"last_updated"`
 }
 
@@ -136,15 +282,15 @@ func NewStorage(baseDir string, relationStore RelationStore) (*Storage, error) {
 	
 	// Check if directories exist (they should be created by installer)
 	if _, err := os.Stat(objectsDir); os.IsNotExist(err) {
-		log.Printf("⚠️  Warning: objects directory missing at %s", objectsDir)
-		log.Printf("⚠️  Creating it now, but this indicates Port 42 wasn't installed properly")
+		logger.Warnf("⚠️  Warning: objects directory missing at %s", objectsDir)
+		logger.Warnf("⚠️  Creating it now, but this indicates Port 42 wasn't installed properly")
 		if err := os.MkdirAll(objectsDir, 0755); err != nil {
 			return nil, fmt.Errorf("failed to create objects directory: %w", err)
 		}
 	}
 	if _, err := os.Stat(metadataDir); os.IsNotExist(err) {
-		log.Printf("⚠️  Warning: metadata directory missing at %s", metadataDir)
-		log.Printf("⚠️  Creating it now, but this indicates Port 42 wasn't installed properly")
+		logger.Warnf("⚠️  Warning: metadata directory missing at %s", metadataDir)
+		logger.Warnf("⚠️  Creating it now, but this indicates Port 42 wasn't installed properly")
 		if err := os.MkdirAll(metadataDir, 0755); err != nil {
 			return nil, fmt.Errorf("failed to create metadata directory: %w", err)
 		}
@@ -153,26 +299,78 @@ func NewStorage(baseDir string, relationStore RelationStore) (*Storage, error) {
 	// Initialize agent sessions
 	agentSessions := NewAgentSessions(baseDir)
 	if err := agentSessions.Load(); err != nil {
-		log.Printf("⚠️ [STORAGE] Failed to load agent sessions: %v", err)
+		logger.Warnf("⚠️ [STORAGE] Failed to load agent sessions: %v", err)
 		// Continue anyway, will create new file on first save
 	}
 	
+	// Select object layout, re-sharding existing objects if the depth changed
+	shardDepth := loadObjectShardDepth()
+	if err := migrateShardDepth(baseDir, shardDepth); err != nil {
+		logger.Warnf("⚠️ [STORAGE] Object re-sharding incomplete, will retry on next start: %v", err)
+	}
+	compressMin := loadCompressMinSize()
+	if compressMin > 0 {
+		logger.Infof("🗜️ [STORAGE] Compressing objects >= %d bytes", compressMin)
+	}
+	var objects ObjectStore = NewFileObjectStore(objectsDir, compressMin, shardDepth)
+	if envBool("PORT42_CHUNK_DEDUP", false) {
+		minSize := envInt("PORT42_CHUNK_MIN_OBJECT_SIZE", defaultChunkLimit)
+		chunked, err := NewChunkedObjectStore(baseDir, NewFileObjectStore(objectsDir, compressMin, shardDepth), minSize)
+		if err != nil {
+			logger.Warnf("⚠️ [STORAGE] Chunk dedup unavailable, storing whole objects: %v", err)
+		} else {
+			objects = chunked
+			logger.Infof("🧩 [STORAGE] Chunk dedup enabled for objects >= %d bytes", minSize)
+		}
+	}
+	
 	s := &Storage{
-		baseDir:       baseDir,
-		objectsDir:    objectsDir,
-		metadataDir:   metadataDir,
-		sessionIndex:  nil, // Will be loaded below
-		agentSessions: agentSessions,
-		relationStore: relationStore,
-		stats:         StorageStats{LastUpdated: time.Now()},
+		baseDir:            baseDir,
+		objectsDir:         objectsDir,
+		objectLayout:       shardLayout{root: objectsDir, depth: shardDepth},
+		metadataDir:        metadataDir,
+		objects:            objects,
+		commandsViewSource: loadCommandsViewSource(),
+		verifyReads:        loadVerifyReads(),
+		searchIndex:        buildSearchIndex(metadataDir),
+		contentSearchCeiling: int64(envPositiveInt("PORT42_MAX_CONTENT_SEARCH_SIZE", defaultContentSearchCeiling)),
+		pendingAccess:      make(map[string]time.Time),
+		stopFlush:          make(chan struct{}),
+		queuedSaves:        make(map[string]*queuedSessionSave),
+		sessionSaveDebounce: envDuration("PORT42_SESSION_SAVE_DEBOUNCE", defaultSessionSaveDebounce),
+		unsaved:            make(map[string]*UnsavedSession),
+		sessionIdleTimeout: defaultIdleTimeout,
+		similarity:         defaultSimilarityConfig(),
+		sessionIndex:       nil, // Will be loaded below
+		agentSessions:      agentSessions,
+		relationStore:      relationStore,
+		stats:              StorageStats{LastUpdated: time.Now()},
 	}
 	
 	// Load session index
 	if err := s.loadSessionIndex(); err != nil {
-		log.Printf("Warning: Failed to load session index: %v", err)
+		logger.Warnf("Warning: Failed to load session index: %v", err)
 		// Continue anyway, we'll rebuild it
 	}
 	
+	// Measure disk usage once; Store and writeMetadata keep it current
+	objectBytes := dirSize(objectsDir)
+	if chunked, ok := objects.(*ChunkedObjectStore); ok {
+		objectBytes += dirSize(chunked.chunks.root) + dirSize(chunked.manifests.root)
+	}
+	s.objectBytes.Store(objectBytes)
+	s.metadataBytes.Store(dirSize(metadataDir))
+	if s.verifyReads {
+		logger.Infof("🔎 [STORAGE] Verifying object hashes on every read")
+	}
+	
+	// Command symlinks from before the command store still target objects
+	s.migrateCommandLinks()
+	// Tools declared before their source was recorded
+	s.backfillToolSources()
+	
+	go s.accessFlushLoop()
+	
 	return s, nil
 }
 
@@ -180,32 +378,39 @@ func NewStorage(baseDir string, relationStore RelationStore) (*Storage, error) {
 
 // Store saves content and returns its hash ID
 func (s *Storage) Store(content []byte) (string, error) {
+	return s.store(content, true)
+}
+
+// store saves content, letting the object store compress it if allowed
+func (s *Storage) store(content []byte, compress bool) (string, error) {
 	// Calculate SHA256 hash
 	hash := sha256.Sum256(content)
 	id := hex.EncodeToString(hash[:])
 	
-	log.Printf("🔍 [STORAGE] Store called: size=%d, id=%s", len(content), id[:12]+"...")
-	
-	// Store in git-like structure: objects/3a/4f/2b8c9d...
-	dir := filepath.Join(s.objectsDir, id[:2], id[2:4])
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create object directory: %w", err)
-	}
-	
-	path := filepath.Join(dir, id[4:])
+	logger.Debugf("🔍 [STORAGE] Store called: size=%d, id=%s", len(content), id[:12]+"...")
 	
+	unlock := s.objectLocks.Lock(id)
+	defer unlock()
+	return s.putObject(id, content, compress)
+}
+
+// putObject writes content under id unless it is already stored. Callers
+// hold id's object lock.
+func (s *Storage) putObject(id string, content []byte, compress bool) (string, error) {
 	// Check if object already exists
-	if _, err := os.Stat(path); err == nil {
-		log.Printf("🔍 [STORAGE] Object already exists: %s", id[:12]+"...")
+	if s.objects.Exists(id) {
+		logger.Debugf("🔍 [STORAGE] Object already exists: %s", id[:12]+"...")
 		return id, nil
 	}
 	
-	// Write content
-	if err := os.WriteFile(path, content, 0644); err != nil {
-		return "", fmt.Errorf("failed to write object: %w", err)
+	// Write content (git-like structure: objects/3a/4f/2b8c9d... at the default depth)
+	written, err := s.objects.Put(id, content, compress)
+	s.objectBytes.Add(written)
+	if err != nil {
+		return "", err
 	}
 	
-	log.Printf("✅ [STORAGE] New object stored: %s at %s", id[:12]+"...", path)
+	logger.Debugf("✅ [STORAGE] New object stored: %s", id[:12]+"...")
 	return id, nil
 }
 
@@ -227,15 +432,16 @@ func (s *Storage) Read(id string) ([]byte, error) {
 		return nil, fmt.Errorf("invalid object ID: %s", id)
 	}
 	
-	path := filepath.Join(s.objectsDir, id[:2], id[2:4], id[4:])
-	content, err := os.ReadFile(path)
+	content, err := s.objects.Get(id)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("object not found: %s", id)
+		return nil, err
+	}
+	if s.verifyReads && isObjectID(id) {
+		if err := verifyContent(id, content); err != nil {
+			logger.Errorf("❌ [STORAGE] %v", err)
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to read object: %w", err)
 	}
-	
 	return content, nil
 }
 
@@ -244,7 +450,7 @@ func (s *Storage) GetPath(id string) string {
 	if len(id) < 4 {
 		return ""
 	}
-	return filepath.Join(s.objectsDir, id[:2], id[2:4], id[4:])
+	return s.objectLayout.path(id)
 }
 
 // ==================== Metadata Management ====================
@@ -256,17 +462,30 @@ func (s *Storage) SaveMetadata(meta *Metadata) error {
 	}
 	
 	// Update timestamps
+	now := time.Now()
 	if meta.Created.IsZero() {
-		meta.Created = time.Now()
+		meta.Created = now
 	}
-	meta.Modified = time.Now()
-	meta.Accessed = time.Now()
 	
 	// Set defaults
 	if meta.Lifecycle == "" {
 		meta.Lifecycle = "draft"
 	}
 	
+	// Only a real change bumps Modified; re-saving identical metadata
+	// (or metadata that only differs in access time) keeps it
+	if previous := s.searchIndex.get(meta.ID); previous != nil && sameMetadataContent(previous, meta) {
+		meta.Modified = previous.Modified
+	} else {
+		meta.Modified = now
+	}
+	meta.Accessed = now
+	
+	return s.writeMetadata(meta)
+}
+
+// writeMetadata writes metadata to disk as-is and updates the search index
+func (s *Storage) writeMetadata(meta *Metadata) error {
 	// Marshal to JSON
 	data, err := json.MarshalIndent(meta, "", "  ")
 	if err != nil {
@@ -275,14 +494,40 @@ func (s *Storage) SaveMetadata(meta *Metadata) error {
 	
 	// Store in metadata directory
 	metaPath := filepath.Join(s.metadataDir, meta.ID+".json")
-	if err := os.WriteFile(metaPath, data, 0644); err != nil {
+	var previousSize int64
+	if info, err := os.Stat(metaPath); err == nil {
+		previousSize = info.Size()
+	}
+	if err := atomicWriteFile(metaPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write metadata: %w", err)
 	}
+	s.metadataBytes.Add(int64(len(data)) - previousSize)
+	
+	// Keep search in step with disk
+	s.searchIndex.add(meta)
 	
 	return nil
 }
 
-// LoadMetadata retrieves metadata for an object
+// sameMetadataContent reports whether two metadata records differ only in timestamps
+func sameMetadataContent(a, b *Metadata) bool {
+	left, right := *cloneMetadata(a), *cloneMetadata(b)
+	left.Modified, right.Modified = time.Time{}, time.Time{}
+	left.Accessed, right.Accessed = time.Time{}, time.Time{}
+	
+	leftJSON, err := json.Marshal(left)
+	if err != nil {
+		return false
+	}
+	rightJSON, err := json.Marshal(right)
+	if err != nil {
+		return false
+	}
+	return string(leftJSON) == string(rightJSON)
+}
+
+// LoadMetadata retrieves metadata for an object. It never writes to disk;
+// use TouchAccessed to record an access.
 func (s *Storage) LoadMetadata(id string) (*Metadata, error) {
 	metaPath := filepath.Join(s.metadataDir, id+".json")
 	data, err := os.ReadFile(metaPath)
@@ -298,20 +543,114 @@ func (s *Storage) LoadMetadata(id string) (*Metadata, error) {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
 	
-	// Update access time
-	meta.Accessed = time.Now()
-	s.SaveMetadata(&meta)
+	// Reflect accesses that haven't been flushed yet
+	s.accessMu.Lock()
+	if accessed, ok := s.pendingAccess[id]; ok && accessed.After(meta.Accessed) {
+		meta.Accessed = accessed
+	}
+	s.accessMu.Unlock()
 	
 	return &meta, nil
 }
 
+// TouchAccessed records an access to an object. Access times are batched in
+// memory and written every accessFlushInterval and on shutdown.
+func (s *Storage) TouchAccessed(id string) {
+	s.accessMu.Lock()
+	s.pendingAccess[id] = time.Now()
+	s.accessMu.Unlock()
+}
+
+// FlushAccessTimes writes pending access times without touching Modified.
+// Each write holds the object's metadata lock, so an access time never
+// overwrites a concurrent edit with the copy it read.
+func (s *Storage) FlushAccessTimes() {
+	s.accessMu.Lock()
+	pending := s.pendingAccess
+	s.pendingAccess = make(map[string]time.Time)
+	s.accessMu.Unlock()
+	
+	if len(pending) == 0 {
+		return
+	}
+	
+	flushed := 0
+	for id, accessed := range pending {
+		if s.flushAccessTime(id, accessed) {
+			flushed++
+		}
+	}
+	
+	logger.Debugf("🕒 [STORAGE] Flushed %d access times", flushed)
+}
+
+// flushAccessTime writes one object's access time if it is newer than the
+// stored one, reporting whether it did
+func (s *Storage) flushAccessTime(id string, accessed time.Time) bool {
+	unlock := s.metadataLocks.Lock(id)
+	defer unlock()
+	
+	meta, err := s.readMetadataFile(id)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("⚠️ [STORAGE] %v", err)
+		}
+		return false // Object removed since it was accessed
+	}
+	if !accessed.After(meta.Accessed) {
+		return false
+	}
+	meta.Accessed = accessed
+	if err := s.writeMetadata(meta); err != nil {
+		logger.Warnf("⚠️ [STORAGE] Failed to flush access time for %s: %v", id, err)
+		return false
+	}
+	return true
+}
+
+// accessFlushLoop periodically flushes batched access times until Close
+func (s *Storage) accessFlushLoop() {
+	ticker := time.NewTicker(accessFlushInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ticker.C:
+			s.FlushAccessTimes()
+		case <-s.stopFlush:
+			return
+		}
+	}
+}
+
+// Close stops background work and flushes queued session saves and
+// pending access times
+func (s *Storage) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stopFlush)
+		s.FlushSessionSaves()
+		s.FlushAccessTimes()
+		if s.agentSessions != nil {
+			if err := s.agentSessions.Close(); err != nil {
+				logger.Errorf("❌ [AGENT_SESSIONS] %v", err)
+			}
+		}
+	})
+}
+
 // StoreWithMetadata stores content with associated metadata
 func (s *Storage) StoreWithMetadata(content []byte, meta *Metadata) (string, error) {
-	log.Printf("🔍 [STORAGE] StoreWithMetadata called with type=%s, paths=%v", meta.Type, meta.Paths)
+	logger.Debugf("🔍 [STORAGE] StoreWithMetadata called with type=%s, paths=%v", meta.Type, meta.Paths)
 	
-	// Store content
-	id, err := s.Store(content)
-	if err != nil {
+	// Store content (metadata decides whether it may be compressed). The
+	// lock is held through the metadata write so concurrent stores of the
+	// same content don't interleave their metadata.
+	hash := sha256.Sum256(content)
+	id := hex.EncodeToString(hash[:])
+	unlock := s.objectLocks.Lock(id)
+	defer unlock()
+	
+	if _, err := s.putObject(id, content, shouldCompress(meta)); err != nil {
 		return "", err
 	}
 	
@@ -319,30 +658,41 @@ func (s *Storage) StoreWithMetadata(content []byte, meta *Metadata) (string, err
 	meta.ID = id
 	meta.Size = int64(len(content))
 	
-	log.Printf("🔍 [STORAGE] Saving metadata for object %s", id[:12]+"...")
+	logger.Debugf("🔍 [STORAGE] Saving metadata for object %s", id[:12]+"...")
 	
 	// Save metadata
 	if err := s.SaveMetadata(meta); err != nil {
 		return "", fmt.Errorf("failed to save metadata: %w", err)
 	}
 	
-	log.Printf("✅ [STORAGE] StoreWithMetadata complete: id=%s, type=%s", id[:12]+"...", meta.Type)
+	logger.Debugf("✅ [STORAGE] StoreWithMetadata complete: id=%s, type=%s", id[:12]+"...", meta.Type)
 	return id, nil
 }
 
 // ==================== Session Management ====================
 
-// SaveSession saves a session to storage
+// SaveSession saves a session to storage. The outcome is recorded, so a
+// session whose save failed shows in UnsavedSessions until one succeeds.
 func (s *Storage) SaveSession(session *Session) error {
-	log.Printf("🔍 [STORAGE] SaveSession starting for %s (messages=%d, state=%s)", 
+	err := s.saveSession(session)
+	s.recordSessionSave(session, err)
+	return err
+}
+
+// saveSession is SaveSession's write
+func (s *Storage) saveSession(session *Session) error {
+	logger.Debugf("🔍 [STORAGE] SaveSession starting for %s (messages=%d, state=%s)", 
 		session.ID, len(session.Messages), session.State)
 	
+	// This write supersedes any debounced one
+	s.dequeueSessionSave(session.ID)
+	
 	s.indexMutex.Lock()
 	defer s.indexMutex.Unlock()
 	
 	// Check if session already exists in index
 	if existing, exists := s.sessionIndex.Sessions[session.ID]; exists {
-		log.Printf("🔍 [STORAGE] Session %s already exists with object ID %s", 
+		logger.Debugf("🔍 [STORAGE] Session %s already exists with object ID %s", 
 			session.ID, existing.ObjectID[:12]+"...")
 	}
 	
@@ -355,6 +705,8 @@ func (s *Storage) SaveSession(session *Session) error {
 		UpdatedAt:    time.Now(),
 		LastActivity: session.LastActivity,
 		Messages:     session.Messages,
+		ForkedFrom:   session.ForkedFrom,
+		ForkPoint:    session.ForkPoint,
 		Metadata: map[string]interface{}{
 			"agent": session.Agent,
 		},
@@ -379,7 +731,7 @@ func (s *Storage) SaveSession(session *Session) error {
 	metadata := &Metadata{
 		Type:        "session",
 		Title:       fmt.Sprintf("Session %s", session.ID),
-		Description: fmt.Sprintf("AI conversation with %s", session.Agent),
+		Description: sessionDescription(session),
 		Tags:        extractSessionTags(session),
 		Session:     session.ID,
 		Agent:       session.Agent,
@@ -427,12 +779,13 @@ func (s *Storage) SaveSession(session *Session) error {
 	// Update stats
 	s.updateStats()
 	
-	// Save index
+	// Save index; without it a restart finds the previous save, so this
+	// fails the save too
 	if err := s.saveSessionIndex(); err != nil {
-		log.Printf("Warning: Failed to save session index: %v", err)
+		return fmt.Errorf("failed to save session index: %v", err)
 	}
 	
-	log.Printf("✅ [STORAGE] Session %s saved with object ID %s", session.ID, objectID[:12]+"...")
+	logger.Debugf("✅ [STORAGE] Session %s saved with object ID %s", session.ID, objectID[:12]+"...")
 	return nil
 }
 
@@ -467,7 +820,9 @@ func (s *Storage) LoadSession(sessionID string) (*Session, error) {
 		State:            ps.State,
 		Messages:         ps.Messages,
 		CommandGenerated: nil,
-		IdleTimeout:      30 * time.Minute,
+		IdleTimeout:      s.sessionIdleTimeout,
+		ForkedFrom:       ps.ForkedFrom,
+		ForkPoint:        ps.ForkPoint,
 	}
 	
 	// Convert command info if exists
@@ -480,26 +835,31 @@ func (s *Storage) LoadSession(sessionID string) (*Session, error) {
 	return session, nil
 }
 
-// LoadRecentSessions loads sessions from the last N days
-func (s *Storage) LoadRecentSessions(days int) ([]*PersistentSession, error) {
+// LoadRecentSessions loads sessions from the last N days. A non-empty
+// agent (with or without its @) limits them to that agent's sessions.
+func (s *Storage) LoadRecentSessions(days int, agent string) ([]*PersistentSession, error) {
 	s.indexMutex.RLock()
 	defer s.indexMutex.RUnlock()
 	
 	cutoff := time.Now().AddDate(0, 0, -days)
+	agent = strings.TrimPrefix(agent, "@")
 	var sessions []*PersistentSession
 	
 	for _, ref := range s.sessionIndex.Sessions {
+		if agent != "" && strings.TrimPrefix(ref.Agent, "@") != agent {
+			continue
+		}
 		if ref.CreatedAt.After(cutoff) {
 			// Load session data
 			data, err := s.Read(ref.ObjectID)
 			if err != nil {
-				log.Printf("Warning: Failed to load session %s: %v", ref.SessionID, err)
+				logger.Warnf("Warning: Failed to load session %s: %v", ref.SessionID, err)
 				continue
 			}
 			
 			var ps PersistentSession
 			if err := json.Unmarshal(data, &ps); err != nil {
-				log.Printf("Warning: Failed to unmarshal session %s: %v", ref.SessionID, err)
+				logger.Warnf("Warning: Failed to unmarshal session %s: %v", ref.SessionID, err)
 				continue
 			}
 			
@@ -534,16 +894,98 @@ func (s *Storage) GetLastSession(agent string) (string, error) {
 		return "", fmt.Errorf("session %s no longer exists", sessionID)
 	}
 	
-	log.Printf("🔍 [STORAGE] Retrieved last session for %s: %s", agent, sessionID)
+	logger.Debugf("🔍 [STORAGE] Retrieved last session for %s: %s", agent, sessionID)
 	return sessionID, nil
 }
 
 
+// ReassignSessionAgent moves last-session tracking from oldAgent to newAgent
+// and rewrites by-agent paths on every stored version of the session.
+// The session itself must already have been saved with the new agent.
+func (s *Storage) ReassignSessionAgent(sessionID, oldAgent, newAgent string) ([]string, error) {
+	oldNorm := strings.TrimPrefix(oldAgent, "@")
+	newNorm := strings.TrimPrefix(newAgent, "@")
+	
+	// Consolidated index: point the old agent at its next most recent session
+	s.indexMutex.Lock()
+	if s.sessionIndex.LastSessions[oldNorm] == sessionID {
+		delete(s.sessionIndex.LastSessions, oldNorm)
+		var latest SessionReference
+		for id, ref := range s.sessionIndex.Sessions {
+			if id != sessionID && ref.Agent == oldNorm && ref.LastUpdated.After(latest.LastUpdated) {
+				latest = ref
+			}
+		}
+		if latest.SessionID != "" {
+			s.sessionIndex.LastSessions[oldNorm] = latest.SessionID
+		}
+	}
+	s.sessionIndex.LastSessions[newNorm] = sessionID
+	if err := s.saveSessionIndex(); err != nil {
+		logger.Warnf("Warning: Failed to save session index: %v", err)
+	}
+	s.indexMutex.Unlock()
+	
+	// Legacy agent_sessions.json tracking; written by its own flush
+	if s.agentSessions != nil {
+		if err := s.agentSessions.ClearLastSession(oldNorm, sessionID); err != nil {
+			logger.Warnf("⚠️ [STORAGE] Failed to clear agent session for %s: %v", oldNorm, err)
+		}
+		if err := s.agentSessions.SetLastSession(newNorm, sessionID); err != nil {
+			logger.Warnf("⚠️ [STORAGE] Failed to set agent session for %s: %v", newNorm, err)
+		}
+	}
+	
+	// Rewrite by-agent paths on older versions of the session object
+	oldSegment := "/by-agent/" + cleanAgentName(oldAgent) + "/"
+	newSegment := "/by-agent/" + cleanAgentName(newAgent) + "/"
+	
+	entries, err := os.ReadDir(s.metadataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata directory: %v", err)
+	}
+	
+	var updatedPaths []string
+	var newest time.Time
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		unlock := s.metadataLocks.Lock(id)
+		meta, err := s.LoadMetadata(id)
+		if err != nil || meta.Type != "session" || meta.Session != sessionID {
+			unlock()
+			continue
+		}
+		
+		for i, path := range meta.Paths {
+			meta.Paths[i] = strings.Replace(path, oldSegment, newSegment, 1)
+		}
+		meta.Agent = newAgent
+		meta.Description = fmt.Sprintf("AI conversation with %s", newAgent)
+		err = s.SaveMetadata(meta)
+		unlock()
+		if err != nil {
+			logger.Warnf("⚠️ [STORAGE] Failed to update metadata for %s: %v", meta.ID, err)
+			continue
+		}
+		
+		if meta.Created.After(newest) || updatedPaths == nil {
+			newest = meta.Created
+			updatedPaths = meta.Paths
+		}
+	}
+	
+	logger.Infof("🔀 [STORAGE] Session %s reassigned from %s to %s", sessionID, oldAgent, newAgent)
+	return updatedPaths, nil
+}
+
 // ==================== Command Management ====================
 
 // StoreCommand stores a command with metadata and creates symlink
 func (s *Storage) StoreCommand(spec *CommandSpec, code string) error {
-	log.Printf("🔍 [STORAGE] StoreCommand for '%s' (session=%s)", spec.Name, spec.SessionID)
+	logger.Debugf("🔍 [STORAGE] StoreCommand for '%s' (session=%s)", spec.Name, spec.SessionID)
 	
 	// Create metadata
 	metadata := &Metadata{
@@ -580,42 +1022,22 @@ func (s *Storage) StoreCommand(spec *CommandSpec, code string) error {
 		return fmt.Errorf("failed to create symlink: %v", err)
 	}
 	
-	log.Printf("✅ [STORAGE] Command '%s' stored with ID %s", spec.Name, objectID[:12]+"...")
+	logger.Debugf("✅ [STORAGE] Command '%s' stored with ID %s", spec.Name, objectID[:12]+"...")
 	return nil
 }
 
-// CreateCommandSymlink creates a symlink for command execution
+// CreateCommandSymlink makes a command runnable as ~/.port42/commands/<name>.
+// The symlink points at the command's file in the command store, which is
+// rewritten with the object's content, so it stays valid after updates.
 func (s *Storage) CreateCommandSymlink(objID, cmdName string) error {
-	homeDir, _ := os.UserHomeDir()
-	cmdDir := filepath.Join(homeDir, ".port42", "commands")
-	
-	// Ensure commands directory exists
-	if err := os.MkdirAll(cmdDir, 0755); err != nil {
-		log.Printf("❌ [STORAGE] Failed to create commands directory: %v", err)
-		return fmt.Errorf("failed to create commands directory: %v", err)
-	}
-	
-	// Create symlink
-	linkPath := filepath.Join(cmdDir, cmdName)
-	targetPath := s.GetPath(objID)
-	
-	log.Printf("🔍 [STORAGE] Creating symlink: %s -> %s", linkPath, targetPath)
-	
-	// Remove existing symlink if it exists
-	os.Remove(linkPath)
+	logger.Debugf("🔍 [STORAGE] Linking command %s -> %s", cmdName, objID[:12]+"...")
 	
-	// Create new symlink
-	if err := os.Symlink(targetPath, linkPath); err != nil {
-		log.Printf("❌ [STORAGE] Failed to create symlink: %v", err)
-		return fmt.Errorf("failed to create symlink: %v", err)
-	}
-	
-	// Make executable
-	if err := os.Chmod(targetPath, 0755); err != nil {
-		log.Printf("⚠️  [STORAGE] Failed to make command executable: %v", err)
+	if err := s.writeCommandFile(objID, cmdName); err != nil {
+		logger.Errorf("❌ [STORAGE] Failed to link command %s: %v", cmdName, err)
+		return err
 	}
 	
-	log.Printf("✅ [STORAGE] Symlink created successfully")
+	logger.Debugf("✅ [STORAGE] Symlink created successfully")
 	return nil
 }
 
@@ -638,10 +1060,20 @@ func (s *Storage) ResolvePath(path string) string {
 		return s.resolveMemoryPath(path)
 	}
 	
+	// Lifecycle view entries are computed, not stored paths
+	if strings.HasPrefix(path, "/by-lifecycle/") {
+		return s.resolveByLifecyclePath(path)
+	}
+	
+	// Tag view entries are computed too
+	if strings.HasPrefix(path, "/by-tag/") {
+		return s.resolveByTagPath(path)
+	}
+	
 	// List all objects and check their metadata
 	ids, err := s.List()
 	if err != nil {
-		log.Printf("Error listing objects: %v", err)
+		logger.Warnf("Error listing objects: %v", err)
 		return ""
 	}
 	
@@ -696,6 +1128,14 @@ func (s *Storage) ListPath(path string) []map[string]interface{} {
 			"name": "by-type",
 			"type": "directory",
 		})
+		entries = append(entries, map[string]interface{}{
+			"name": "by-lifecycle",
+			"type": "directory",
+		})
+		entries = append(entries, map[string]interface{}{
+			"name": "by-tag",
+			"type": "directory",
+		})
 		return entries
 	}
 	
@@ -704,9 +1144,16 @@ func (s *Storage) ListPath(path string) []map[string]interface{} {
 		return s.handleToolsPath(path)
 	}
 	
-	// Handle enhanced commands view - show relation-backed tools
+	// Handle commands view - relation-backed, symlink-backed, or reconciled
 	if path == "/commands" || path == "/commands/" {
-		return s.handleEnhancedCommandsView()
+		switch s.commandsViewSource {
+		case CommandsViewRelation:
+			return s.handleEnhancedCommandsView()
+		case CommandsViewSymlink:
+			return s.handleSymlinkCommandsView()
+		default:
+			return s.handleReconciledCommandsView()
+		}
 	}
 	
 	// Handle enhanced by-date view - include relations
@@ -724,10 +1171,20 @@ func (s *Storage) ListPath(path string) []map[string]interface{} {
 		return s.handleSimilarView(path)
 	}
 	
+	// Handle lifecycle view - objects grouped by metadata lifecycle
+	if path == "/by-lifecycle" || strings.HasPrefix(path, "/by-lifecycle/") {
+		return s.handleByLifecycleView(path)
+	}
+	
+	// Handle tag view - objects grouped by metadata tags
+	if path == "/by-tag" || strings.HasPrefix(path, "/by-tag/") {
+		return s.handleByTagView(path)
+	}
+	
 	// List all objects and organize by virtual paths
 	ids, err := s.List()
 	if err != nil {
-		log.Printf("Error listing objects: %v", err)
+		logger.Warnf("Error listing objects: %v", err)
 		return entries
 	}
 	
@@ -802,61 +1259,64 @@ func (s *Storage) resolveToolsPath(path string) string {
 		toolName := parts[0]
 		
 		// Skip organizational paths (by-name, by-transform, etc.)
-		if toolName == "by-name" || toolName == "by-transform" || toolName == "spawned-by" || toolName == "ancestry" {
+		if isToolsIndexDir(toolName) {
 			return "" // These are organizational directories, not objects
 		}
 		
-		// For individual tool directory, default to definition
+		// For individual tool directory, default to definition. read_path
+		// shows it as a tool view instead (see ToolView).
 		return s.resolveToolsPath("/tools/" + toolName + "/definition")
 	}
 	
-	// Handle specific tool paths like /tools/{toolname}/definition or /tools/{toolname}/executable
+	// Handle specific tool paths like /tools/{toolname}/definition, /executable, /source or /run
 	if len(parts) >= 2 {
 		toolName := parts[0]
 		subpath := parts[1]
 		
 		// Skip organizational paths (by-name, by-transform, etc.)
-		if toolName == "by-name" || toolName == "by-transform" || toolName == "spawned-by" || toolName == "ancestry" {
+		if isToolsIndexDir(toolName) {
 			return "" // These are organizational directories, not objects
 		}
 		
-		// Find the tool relation
-		if s.relationStore != nil {
-			if relations, err := s.relationStore.List(); err == nil {
-				for _, relation := range relations {
-					if relation.Type == "Tool" {
-						if name, ok := relation.Properties["name"].(string); ok && name == toolName {
-							// Handle different subpaths
-							switch subpath {
-							case "definition":
-								// Return the relation as JSON
-								return "relation:" + relation.ID
-							case "executable":
-								// Look for executable object ID in properties
-								if executableID, exists := relation.Properties["executable_id"]; exists {
-									if objID, ok := executableID.(string); ok && objID != "" {
-										// Return the canonical object ID directly
-										return objID
-									}
-								}
-								
-								// Fallback: if only executable content is stored (legacy), convert it
-								if executable, exists := relation.Properties["executable"]; exists {
-									if execStr, ok := executable.(string); ok && execStr != "" {
-										// Store the executable content and return its ID
-										objID, err := s.Store([]byte(execStr))
-										if err == nil {
-											return objID
-										}
-									}
-								}
-								return "" // No executable found
-							}
-							break
-						}
+		// Find the tool relation; when several share the name the most
+		// recently updated one wins (see resolveTool)
+		relation, _, err := s.resolveTool(toolName)
+		if err != nil {
+			return ""
+		}
+		
+		// Handle different subpaths
+		switch subpath {
+		case "definition":
+			// Return the relation as JSON
+			return "relation:" + relation.ID
+		case "executable", "source", "run":
+			// Source and run are the executable content; read_path adds the language
+			// Look for executable object ID in properties
+			if executableID, exists := relation.Properties["executable_id"]; exists {
+				if objID, ok := executableID.(string); ok && objID != "" {
+					// Return the canonical object ID directly
+					return objID
+				}
+			}
+			
+			// Fallback: if only executable content is stored (legacy), convert it
+			if executable, exists := relation.Properties["executable"]; exists {
+				if execStr, ok := executable.(string); ok && execStr != "" {
+					// Store the executable content and return its ID
+					objID, err := s.Store([]byte(execStr))
+					if err == nil {
+						return objID
 					}
 				}
 			}
+			return "" // No executable found
+		case "versions":
+			// /tools/{toolname}/versions/{vN} is that version's executable
+			if len(parts) == 3 {
+				return s.resolveToolVersionPath(*relation, parts[2])
+			}
+			return ""
 		}
 	}
 	
@@ -873,23 +1333,9 @@ func (s *Storage) resolveCommandPath(path string) string {
 	// Remove any trailing slash
 	commandPath = strings.TrimSuffix(commandPath, "/")
 	
-	// Check if there's a symlink in the commands directory
-	homeDir, _ := os.UserHomeDir()
-	symlinkPath := filepath.Join(homeDir, ".port42", "commands", commandPath)
-	
-	// Follow the symlink to get the actual object path
-	if targetPath, err := os.Readlink(symlinkPath); err == nil {
-		// Extract object ID from the target path
-		// Path format: /Users/.../objects/ab/cd/efgh... -> abcdefgh...
-		if strings.Contains(targetPath, "/objects/") {
-			parts := strings.Split(targetPath, "/objects/")
-			if len(parts) == 2 {
-				objectPath := parts[1]
-				// Remove directory structure: "ab/cd/efgh..." -> "abcdefgh..."
-				objectID := strings.ReplaceAll(objectPath, "/", "")
-				return objectID
-			}
-		}
+	// Check for a command symlink in the commands directory
+	if objectID := s.commandObjectID(commandPath); objectID != "" {
+		return objectID
 	}
 	
 	// Fallback to tools path for backward compatibility
@@ -915,7 +1361,7 @@ func (s *Storage) resolveMemoryPath(path string) string {
 	// Search for objects with this session ID in their metadata
 	ids, err := s.List()
 	if err != nil {
-		log.Printf("Error listing objects for memory resolution: %v", err)
+		logger.Warnf("Error listing objects for memory resolution: %v", err)
 		return ""
 	}
 	
@@ -941,52 +1387,45 @@ func (s *Storage) resolveMemoryPath(path string) string {
 	return ""
 }
 
+// objectIDFromPath extracts an object ID from an object file path
+// Path format: /Users/.../objects/ab/cd/efgh... -> abcdefgh...
+func objectIDFromPath(targetPath string) string {
+	if !strings.Contains(targetPath, "/objects/") {
+		return ""
+	}
+	parts := strings.Split(targetPath, "/objects/")
+	if len(parts) != 2 {
+		return ""
+	}
+	// Remove directory structure: "ab/cd/efgh..." -> "abcdefgh..."
+	return strings.ReplaceAll(parts[1], "/", "")
+}
+
 // ==================== Utilities ====================
 
 // List returns all object IDs in storage
 func (s *Storage) List() ([]string, error) {
-	var ids []string
-	
-	// Walk through the object store directory structure
-	err := filepath.Walk(s.objectsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
-		
-		// Reconstruct ID from path
-		rel, err := filepath.Rel(s.objectsDir, path)
-		if err != nil {
-			return err
-		}
-		
-		// Convert path back to ID: 3a/4f/2b8c9d... -> 3a4f2b8c9d...
-		parts := strings.Split(rel, string(filepath.Separator))
-		if len(parts) == 3 {
-			id := parts[0] + parts[1] + parts[2]
-			ids = append(ids, id)
-		}
-		
-		return nil
-	})
-	
-	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+	return s.objects.List()
+}
+
+// GetDedupStats reports chunk dedup savings (zero values when chunking is off)
+func (s *Storage) GetDedupStats() DedupStats {
+	if chunked, ok := s.objects.(*ChunkedObjectStore); ok {
+		return chunked.Stats()
 	}
-	
-	return ids, nil
+	return DedupStats{Enabled: false, DedupRatio: 1.0}
 }
 
 // GetStats returns storage statistics
 func (s *Storage) GetStats() StorageStats {
 	s.indexMutex.RLock()
-	defer s.indexMutex.RUnlock()
+	stats := s.stats
+	s.indexMutex.RUnlock()
 	
-	return s.stats
+	stats.ObjectBytes = s.objectBytes.Load()
+	stats.MetadataBytes = s.metadataBytes.Load()
+	stats.StorageSize = stats.ObjectBytes + stats.MetadataBytes
+	return stats
 }
 
 // ==================== Private Helper Methods ====================
@@ -1039,7 +1478,7 @@ func (s *Storage) saveSessionIndex() error {
 		return err
 	}
 	
-	return os.WriteFile(indexPath, data, 0644)
+	return atomicWriteFile(indexPath, data, 0644)
 }
 
 // updateStats updates storage statistics
@@ -1083,10 +1522,28 @@ func (s *Storage) CopyFrom(r io.Reader) (string, error) {
 
 // HandleStorePath processes store_path requests
 func (s *Storage) HandleStorePath(path string, content []byte, metadata map[string]interface{}) (map[string]interface{}, error) {
+	meta, pathType, subpath, err := newStorePathMetadata(path, content, metadata)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Store in object store
+	objID, err := s.StoreWithMetadata(content, meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store content: %v", err)
+	}
+	
+	return s.finishStorePath(objID, pathType, subpath, meta), nil
+}
+
+// newStorePathMetadata builds the metadata for content stored at a virtual
+// path. head is the content, or at least its first 512 bytes, for sniffing
+// the MIME type.
+func newStorePathMetadata(path string, head []byte, metadata map[string]interface{}) (*Metadata, string, string, error) {
 	// Parse virtual path
 	pathType, subpath := parseVirtualPath(path)
 	if pathType == "invalid" {
-		return nil, fmt.Errorf("invalid virtual path: %s", path)
+		return nil, "", "", fmt.Errorf("invalid virtual path: %s", path)
 	}
 	
 	// Create metadata
@@ -1097,6 +1554,7 @@ func (s *Storage) HandleStorePath(path string, content []byte, metadata map[stri
 		Accessed:  time.Now(),
 		Lifecycle: "active",
 		Paths:     []string{path},
+		MimeType:  detectMimeType(path, head),
 	}
 	
 	// Add metadata from payload
@@ -1116,29 +1574,34 @@ func (s *Storage) HandleStorePath(path string, content []byte, metadata map[stri
 		if title, ok := metadata["title"].(string); ok {
 			meta.Title = title
 		}
+		if mimeType, ok := metadata["mime_type"].(string); ok && mimeType != "" {
+			meta.MimeType = mimeType
+		}
+		if artifactName, ok := metadata["artifact_name"].(string); ok {
+			meta.ArtifactName = artifactName
+		}
 	}
 	
 	// Generate additional virtual paths based on type
 	meta.Paths = generateVirtualPaths(pathType, subpath, meta)
-	
-	// Store in object store
-	objID, err := s.StoreWithMetadata(content, meta)
-	if err != nil {
-		return nil, fmt.Errorf("failed to store content: %v", err)
-	}
-	
+	return meta, pathType, subpath, nil
+}
+
+// finishStorePath links a stored command and builds the store_path result
+func (s *Storage) finishStorePath(objID, pathType, subpath string, meta *Metadata) map[string]interface{} {
 	// Special handling for commands - create symlink
 	if pathType == "commands" {
 		if err := s.CreateCommandSymlink(objID, subpath); err != nil {
-			log.Printf("Warning: Failed to create symlink for command %s: %v", subpath, err)
+			logger.Warnf("Warning: Failed to create symlink for command %s: %v", subpath, err)
 		}
 	}
 	
 	return map[string]interface{}{
-		"id":    objID,
-		"paths": meta.Paths,
-		"size":  len(content),
-	}, nil
+		"id":        objID,
+		"paths":     meta.Paths,
+		"size":      meta.Size,
+		"mime_type": meta.MimeType,
+	}
 }
 
 // HandleUpdatePath processes update_path requests
@@ -1149,12 +1612,23 @@ func (s *Storage) HandleUpdatePath(path string, content []byte, metadataUpdates
 		return nil, fmt.Errorf("path not found: %s", path)
 	}
 	
+	// Concurrent updates to the same object apply one after the other, so
+	// add_tags and remove_tags from different clients all land
+	unlock := s.metadataLocks.Lock(objID)
+	defer unlock()
+	
 	// Load existing metadata
 	meta, err := s.LoadMetadata(objID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load metadata: %v", err)
 	}
 	
+	// Update metadata fields
+	changed, err := applyMetadataUpdates(meta, metadataUpdates)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Update content if provided
 	if len(content) > 0 {
 		// Store new version
@@ -1164,6 +1638,9 @@ func (s *Storage) HandleUpdatePath(path string, content []byte, metadataUpdates
 		}
 		
 		// Update metadata to point to new object
+		if newID != meta.ID {
+			changed = append(changed, "content")
+		}
 		meta.ID = newID
 		meta.Modified = time.Now()
 		
@@ -1175,36 +1652,31 @@ func (s *Storage) HandleUpdatePath(path string, content []byte, metadataUpdates
 				s.updateCommandSymlink(newID, cmdName)
 			}
 		}
-	}
-	
-	// Update metadata fields
-	if metadataUpdates != nil {
-		if lifecycle, ok := metadataUpdates["lifecycle"].(string); ok {
-			meta.Lifecycle = lifecycle
-		}
-		if tags, ok := metadataUpdates["tags"].([]interface{}); ok {
-			meta.Tags = make([]string, len(tags))
-			for i, tag := range tags {
-				meta.Tags[i] = fmt.Sprintf("%v", tag)
+		
+		// A tool's new executable becomes its active version
+		if toolName := toolNameForExecutablePath(path); toolName != "" {
+			if strings.HasPrefix(path, "/tools/") {
+				s.updateCommandSymlink(newID, toolName)
+			}
+			if err := s.RecordToolVersion(toolName, newID, ""); err != nil {
+				logger.Warnf("⚠️ Failed to record version for %s: %v", toolName, err)
 			}
-		}
-		if importance, ok := metadataUpdates["importance"].(string); ok {
-			meta.Importance = importance
-		}
-		if summary, ok := metadataUpdates["summary"].(string); ok {
-			meta.Summary = summary
 		}
 	}
 	
-	// Save updated metadata
-	if err := s.SaveMetadata(meta); err != nil {
-		return nil, fmt.Errorf("failed to save metadata: %v", err)
+	// Save updated metadata, unless nothing changed
+	if len(content) > 0 || len(changed) > 0 {
+		if err := s.SaveMetadata(meta); err != nil {
+			return nil, fmt.Errorf("failed to save metadata: %v", err)
+		}
 	}
 	
 	return map[string]interface{}{
 		"id":       meta.ID,
 		"modified": meta.Modified,
 		"paths":    meta.Paths,
+		"tags":     meta.Tags,
+		"changed":  changed,
 	}, nil
 }
 
@@ -1216,6 +1688,9 @@ func (s *Storage) HandleDeletePath(path string) (map[string]interface{}, error)
 		return nil, fmt.Errorf("path not found: %s", path)
 	}
 	
+	unlock := s.metadataLocks.Lock(objID)
+	defer unlock()
+	
 	// Load metadata
 	meta, err := s.LoadMetadata(objID)
 	if err != nil {
@@ -1257,6 +1732,277 @@ func (s *Storage) HandleDeletePath(path string) (map[string]interface{}, error)
 	}, nil
 }
 
+// HandleMovePath processes move_path requests. The object and its history
+// stay the same; only the stored path (and any aliases derived from its name)
+// change, plus the command symlink when /commands/ is involved.
+func (s *Storage) HandleMovePath(oldPath, newPath string) (map[string]interface{}, error) {
+	newType, newSubpath := parseVirtualPath(newPath)
+	if newType == "invalid" {
+		return nil, fmt.Errorf("invalid virtual path: %s", newPath)
+	}
+	if oldPath == newPath {
+		return nil, fmt.Errorf("source and destination are the same: %s", oldPath)
+	}
+	
+	// Resolve path to object ID
+	objID := s.ResolvePath(oldPath)
+	if objID == "" {
+		return nil, fmt.Errorf("path not found: %s", oldPath)
+	}
+	if existing := s.ResolvePath(newPath); existing != "" {
+		return nil, fmt.Errorf("destination already exists: %s", newPath)
+	}
+	
+	unlock := s.metadataLocks.Lock(objID)
+	defer unlock()
+	
+	// Load metadata
+	meta, err := s.LoadMetadata(objID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %v", err)
+	}
+	
+	found := false
+	for _, p := range meta.Paths {
+		if p == oldPath {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("cannot move %s: it is a generated view, not a stored path", oldPath)
+	}
+	
+	// Rewrite the path, and rename aliases that generateVirtualPaths built from the old name
+	oldBase := filepath.Base(oldPath)
+	newBase := filepath.Base(newPath)
+	newPaths := make([]string, 0, len(meta.Paths))
+	for _, p := range meta.Paths {
+		switch {
+		case p == oldPath:
+			p = newPath
+		case oldBase != newBase && filepath.Base(p) == oldBase && isDerivedPath(p):
+			p = filepath.Join(filepath.Dir(p), newBase)
+		}
+		newPaths = append(newPaths, p)
+	}
+	meta.Paths = newPaths
+	
+	// Save updated metadata
+	if err := s.SaveMetadata(meta); err != nil {
+		return nil, fmt.Errorf("failed to save metadata: %v", err)
+	}
+	
+	// Move the command symlink along with the path
+	if strings.HasPrefix(oldPath, "/commands/") {
+		if err := s.removeCommandSymlink(strings.TrimPrefix(oldPath, "/commands/")); err != nil && !os.IsNotExist(err) {
+			logger.Warnf("Warning: Failed to remove symlink for command %s: %v", oldPath, err)
+		}
+	}
+	if newType == "commands" {
+		if err := s.CreateCommandSymlink(objID, newSubpath); err != nil {
+			logger.Warnf("Warning: Failed to create symlink for command %s: %v", newSubpath, err)
+		}
+	}
+	
+	logger.Infof("✅ [STORAGE] Moved %s -> %s (object %s)", oldPath, newPath, objID[:12]+"...")
+	return map[string]interface{}{
+		"id":       objID,
+		"old_path": oldPath,
+		"new_path": newPath,
+		"paths":    meta.Paths,
+	}, nil
+}
+
+// isDerivedPath reports whether a path is one of the views generateVirtualPaths adds
+func isDerivedPath(path string) bool {
+	return strings.HasPrefix(path, "/by-date/") || strings.HasPrefix(path, "/by-type/") ||
+		strings.HasPrefix(path, "/by-agent/") ||
+		(strings.HasPrefix(path, "/memory/") && strings.Contains(path, "/generated/"))
+}
+
+// Batch operation actions
+const (
+	BatchAddTags      = "add-tags"
+	BatchSetLifecycle = "set-lifecycle"
+	BatchDeprecate    = "deprecate"
+	BatchDelete       = "delete"
+	BatchPin          = "pin"
+)
+
+// BatchSelector picks the objects a batch operation applies to, using search semantics
+type BatchSelector struct {
+	Query   string        `json:"query"`
+	Mode    string        `json:"mode,omitempty"`
+	Filters SearchFilters `json:"filters"`
+}
+
+// batchPageSize is how many matches a batch operation asks for first; a
+// selector without a limit then fetches the rest in one more search
+const batchPageSize = 100
+
+// HandleBatchOp applies an action to every object matched by the selector,
+// or to the first Filters.Limit of them when the selector sets a limit.
+// Without confirm it only reports what would change.
+func (s *Storage) HandleBatchOp(selector BatchSelector, action string, tags []string, lifecycle string, confirm bool) (map[string]interface{}, error) {
+	switch action {
+	case BatchAddTags:
+		if len(tags) == 0 {
+			return nil, fmt.Errorf("add-tags requires tags")
+		}
+	case BatchSetLifecycle:
+		if lifecycle == "" {
+			return nil, fmt.Errorf("set-lifecycle requires lifecycle")
+		}
+	case BatchDeprecate, BatchDelete, BatchPin:
+	default:
+		return nil, fmt.Errorf("unknown batch action: %s", action)
+	}
+	
+	if selector.Query == "" && selector.Filters.Path == "" && len(selector.Filters.typeNames()) == 0 &&
+		selector.Filters.Agent == "" && len(selector.Filters.Tags) == 0 &&
+		selector.Filters.After.IsZero() && selector.Filters.Before.IsZero() {
+		return nil, fmt.Errorf("selector requires a query or at least one filter")
+	}
+	
+	if selector.Mode == "" {
+		selector.Mode = "or"
+	}
+	
+	// Every match is found before anything changes, so an action that takes
+	// objects out of the selector can't shift the ones still to come
+	matches, total, err := s.batchMatches(selector)
+	if err != nil {
+		return nil, fmt.Errorf("selector search failed: %v", err)
+	}
+	
+	affected := []map[string]interface{}{}
+	skipped := []map[string]interface{}{}
+	seen := make(map[string]bool)
+	
+	for _, match := range matches {
+		if seen[match.ObjectID] {
+			continue
+		}
+		seen[match.ObjectID] = true
+		
+		// Relations have no stored metadata to modify
+		unlock := s.metadataLocks.Lock(match.ObjectID)
+		meta, err := s.LoadMetadata(match.ObjectID)
+		if err != nil {
+			unlock()
+			skipped = append(skipped, map[string]interface{}{
+				"path":   match.Path,
+				"id":     match.ObjectID,
+				"reason": "not a stored object",
+			})
+			continue
+		}
+		
+		entry := map[string]interface{}{
+			"path":      match.Path,
+			"id":        match.ObjectID,
+			"type":      meta.Type,
+			"lifecycle": meta.Lifecycle,
+			"tags":      meta.Tags,
+		}
+		
+		if confirm {
+			err = s.applyBatchAction(meta, action, tags, lifecycle)
+		}
+		unlock()
+		if err != nil {
+			entry["error"] = err.Error()
+			skipped = append(skipped, entry)
+			continue
+		}
+		if confirm {
+			entry["lifecycle"] = meta.Lifecycle
+			entry["tags"] = meta.Tags
+			entry["paths"] = meta.Paths
+		}
+		
+		affected = append(affected, entry)
+	}
+	
+	if confirm {
+		logger.Infof("🧹 [STORAGE] Batch %s applied to %d objects (%d skipped)", action, len(affected), len(skipped))
+	} else {
+		logger.Debugf("🔍 [STORAGE] Batch %s dry run: %d objects would change", action, len(affected))
+	}
+	
+	return map[string]interface{}{
+		"action":        action,
+		"dry_run":       !confirm,
+		"affected":      affected,
+		"skipped":       skipped,
+		"count":         len(affected),
+		"total_matches": total,
+		"truncated":     max(selector.Filters.Offset, 0)+len(matches) < total,
+	}, nil
+}
+
+// batchMatches runs a batch selector's search, returning its matches and
+// the total. Without a limit every match is returned.
+func (s *Storage) batchMatches(selector BatchSelector) ([]SearchResult, int, error) {
+	filters := selector.Filters
+	if filters.Limit > 0 {
+		return s.SearchObjectsPage(selector.Query, selector.Mode, filters)
+	}
+	
+	filters.Limit = batchPageSize
+	matches, total, err := s.SearchObjectsPage(selector.Query, selector.Mode, filters)
+	if err != nil || len(matches) < filters.Limit {
+		return matches, total, err
+	}
+	filters.Limit = total
+	return s.SearchObjectsPage(selector.Query, selector.Mode, filters)
+}
+
+// applyBatchAction mutates one object's metadata for a batch operation
+func (s *Storage) applyBatchAction(meta *Metadata, action string, tags []string, lifecycle string) error {
+	switch action {
+	case BatchAddTags:
+		meta.Tags = appendUniqueTags(meta.Tags, tags...)
+	case BatchSetLifecycle:
+		meta.Lifecycle = lifecycle
+	case BatchDeprecate:
+		meta.Lifecycle = "deprecated"
+	case BatchPin:
+		meta.Importance = "high"
+		meta.Tags = appendUniqueTags(meta.Tags, "pinned")
+	case BatchDelete:
+		// Same as delete_path on every virtual path: unlink and deprecate
+		for _, path := range meta.Paths {
+			if strings.HasPrefix(path, "/commands/") {
+				parts := strings.Split(path, "/")
+				if len(parts) >= 3 {
+					s.removeCommandSymlink(parts[2])
+				}
+			}
+		}
+		meta.Paths = []string{}
+		meta.Lifecycle = "deprecated"
+	}
+	
+	return s.SaveMetadata(meta)
+}
+
+// appendUniqueTags adds tags that are not already present
+func appendUniqueTags(existing []string, tags ...string) []string {
+	have := make(map[string]bool, len(existing))
+	for _, tag := range existing {
+		have[tag] = true
+	}
+	for _, tag := range tags {
+		if tag != "" && !have[tag] {
+			existing = append(existing, tag)
+			have[tag] = true
+		}
+	}
+	return existing
+}
+
 // HandleCreateMemory processes create_memory requests
 func (s *Storage) HandleCreateMemory(agent, initialMessage string) (map[string]interface{}, error) {
 	// Generate memory ID
@@ -1385,9 +2131,7 @@ func (s *Storage) updateCommandSymlink(objID, cmdName string) error {
 }
 
 func (s *Storage) removeCommandSymlink(cmdName string) error {
-	homeDir, _ := os.UserHomeDir()
-	linkPath := filepath.Join(homeDir, ".port42", "commands", cmdName)
-	return os.Remove(linkPath)
+	return s.removeCommandFiles(cmdName)
 }
 
 func generateMemoryID() string {
@@ -1396,6 +2140,15 @@ func generateMemoryID() string {
 
 // SearchObjects searches across all objects and relations in the virtual filesystem
 func (s *Storage) SearchObjects(query string, mode string, filters SearchFilters) ([]SearchResult, error) {
+	results, _, err := s.SearchObjectsPage(query, mode, filters)
+	return results, err
+}
+
+// SearchObjectsPage returns one page of results plus the total number of
+// matches. The full result set is scored and sorted before slicing
+// [offset:offset+limit], so pages are stable; an offset at or past the
+// total returns an empty page with the total still set.
+func (s *Storage) SearchObjectsPage(query string, mode string, filters SearchFilters) ([]SearchResult, int, error) {
 	results := []SearchResult{}
 	
 	// Default limit
@@ -1403,88 +2156,129 @@ func (s *Storage) SearchObjects(query string, mode string, filters SearchFilters
 	if limit <= 0 {
 		limit = 20
 	}
+	offset := filters.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	
+	// Message filters only match session messages
+	messageSearch := filters.hasMessageFilters()
+	if messageSearch && mode == SearchModeFuzzy {
+		return nil, 0, fmt.Errorf("message filters can't be used with fuzzy mode")
+	}
 	
 	// Phase D: Search relations first (tools, artifacts defined as relations)
-	if s.relationStore != nil {
+	if s.relationStore != nil && !messageSearch {
 		relationResults, err := s.searchInRelations(query, mode, filters)
 		if err == nil {
 			results = append(results, relationResults...)
 		}
 	}
 	
-	// Load all metadata files (traditional objects)
-	entries, err := os.ReadDir(s.metadataDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read metadata directory: %v", err)
+	// Regex queries must compile; fail loudly instead of matching nothing
+	if mode == SearchModeRegex {
+		if _, err := compileSearchRegex(query); err != nil {
+			return nil, 0, err
+		}
 	}
 	
 	// Convert query to lowercase for case-insensitive search
-	queryLower := strings.ToLower(query)
+	queryLower := searchQuery(query, mode)
 	
-	for _, entry := range entries {
-		if !strings.HasSuffix(entry.Name(), ".json") {
-			continue
-		}
-		
-		// Load metadata
-		objID := strings.TrimSuffix(entry.Name(), ".json")
-		metadata, err := s.LoadMetadata(objID)
-		if err != nil {
-			log.Printf("Failed to load metadata for %s: %v", objID, err)
-			continue
-		}
-		
+	// Fuzzy terms can't be looked up by substring; they are matched per word
+	var fuzzy *fuzzyQuery
+	if mode == SearchModeFuzzy {
+		fuzzy = newFuzzyQuery(queryLower, filters.FuzzyThreshold)
+	}
+	
+	// Traditional objects come from the in-memory index; only objects whose
+	// fields could match the query are scored against their metadata
+	docs, candidates := s.searchIndex.snapshot(queryLower, mode)
+	
+	// type=session searches each message of the current transcripts, with
+	// no size cap, rather than scanning session objects as whole files
+	sessionSearch := filters.onlyType("session") && query != "" && fuzzy == nil
+	if messageSearch {
+		sessionSearch = filters.matchesType("session")
+	}
+	sessionMatches := make(map[string]SearchResult)
+	
+	var contentCandidates []*Metadata
+	contentLimits := s.contentSearchLimits(filters)
+	for _, metadata := range docs {
 		// Apply filters
-		if !matchesFilters(metadata, filters) {
+		if !matchesFilters(metadata, filters) || (messageSearch && metadata.Type != "session") {
 			continue
 		}
 		
 		// Search in metadata fields with mode
-		score, matchFields, snippet := searchInMetadata(metadata, queryLower, mode)
+		score, matchFields, snippet := 0.0, []string{}, ""
+		if fuzzy != nil && query != "" {
+			score, matchFields, snippet = searchMetadataFuzzy(metadata, fuzzy)
+		} else if candidates == nil || candidates[metadata.ID] {
+			score, matchFields, snippet = searchInMetadata(metadata, queryLower, mode)
+		}
 		
-		// If no metadata match and query exists, optionally search in content
-		if score == 0 && query != "" && metadata.Size < 100*1024 { // Only for small files
-			contentScore, contentSnippet := s.searchInContent(objID, queryLower, mode, metadata.Type)
-			if contentScore > 0 {
-				score = contentScore * 0.8 // Content matches score lower than metadata
-				matchFields = append(matchFields, "content")
-				snippet = contentSnippet
+		// Session metadata matches are merged with message matches, per
+		// session, by searchSessionMessages
+		if sessionSearch {
+			if score > 0 {
+				sessionMatches[metadata.ID] = newMetadataSearchResult(metadata, score, matchFields, snippet)
 			}
+			continue
 		}
 		
-		// Skip if no match
+		// No metadata match: remember files within the content limits for
+		// the content pass. Fuzzy matching stays on metadata; scanning
+		// content word by word would be far slower than the substring modes.
 		if score == 0 && query != "" {
+			if fuzzy != nil {
+				continue
+			}
+			if contentLimits.searchable(metadata.Size) {
+				contentCandidates = append(contentCandidates, metadata)
+			}
 			continue
 		}
 		
-		// Pick the best path for display
-		displayPath := ""
-		if len(metadata.Paths) > 0 {
-			// Prefer shorter, more intuitive paths
-			displayPath = metadata.Paths[0]
-			for _, path := range metadata.Paths {
-				if len(path) < len(displayPath) && !strings.Contains(path, "by-date") {
-					displayPath = path
-				}
-			}
+		results = append(results, newMetadataSearchResult(metadata, score, matchFields, snippet))
+	}
+	
+	if sessionSearch {
+		results = append(results, s.searchSessionMessages(queryLower, mode, filters, sessionMatches)...)
+	}
+	
+	// Fall back to content scanning only for the most recently modified
+	// candidates, so the total stays the same from page to page
+	if len(contentCandidates) > 0 {
+		sort.SliceStable(contentCandidates, func(i, j int) bool {
+			return contentCandidates[i].Modified.After(contentCandidates[j].Modified)
+		})
+		if len(contentCandidates) > maxContentScanCandidates {
+			contentCandidates = contentCandidates[:maxContentScanCandidates]
 		}
 		
-		result := SearchResult{
-			Path:        displayPath,
-			ObjectID:    objID,
-			Type:        metadata.Type,
-			Score:       score,
-			Snippet:     snippet,
-			Metadata:    *metadata,
-			MatchFields: matchFields,
+		for _, metadata := range contentCandidates {
+			contentScore, contentSnippet := s.searchInContent(metadata.ID, queryLower, mode, contentLimits.readLimit(metadata.Size))
+			if contentScore == 0 {
+				continue
+			}
+			
+			// Content matches score lower than metadata
+			results = append(results, newMetadataSearchResult(metadata, contentScore*0.8, []string{"content"}, contentSnippet))
 		}
-		
-		results = append(results, result)
-		
-		// Stop if we have enough results
-		if len(results) >= limit {
-			break
+	}
+	
+	// Low-relevance matches are dropped before sorting, so they count
+	// toward neither the page nor the total
+	if filters.MinScore > 0 {
+		kept := results[:0]
+		for _, result := range results {
+			if result.Score >= filters.MinScore {
+				kept = append(kept, result)
+			}
 		}
+		results = kept
 	}
 	
 	// Sort by score (highest first)
@@ -1494,21 +2288,75 @@ func (s *Storage) SearchObjects(query string, mode string, filters SearchFilters
 			return results[i].Score > results[j].Score
 		}
 		// Secondary sort by creation date (newest first)
-		return results[i].Metadata.Created.After(results[j].Metadata.Created)
+		if !results[i].Metadata.Created.Equal(results[j].Metadata.Created) {
+			return results[i].Metadata.Created.After(results[j].Metadata.Created)
+		}
+		// Then the newest message, when results are messages
+		mi, mj := results[i].Message, results[j].Message
+		if mi != nil && mj != nil && !mi.Timestamp.Equal(mj.Timestamp) {
+			return mi.Timestamp.After(mj.Timestamp)
+		}
+		// Tie-break on ID so pages never overlap
+		if results[i].ObjectID != results[j].ObjectID || mi == nil || mj == nil {
+			return results[i].ObjectID < results[j].ObjectID
+		}
+		return mi.Index < mj.Index
 	})
 	
-	// Trim to limit
-	if len(results) > limit {
-		results = results[:limit]
+	// Slice out the requested page
+	total := len(results)
+	if offset >= total {
+		return []SearchResult{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
 	}
 	
-	return results, nil
+	// Only the returned page is highlighted
+	page := results[offset:end]
+	if highlighter := newSnippetHighlighter(queryLower, mode, fuzzy); highlighter != nil {
+		for i := range page {
+			page[i].Highlights = highlighter.highlight(page[i].Snippet)
+		}
+	}
+	
+	return page, total, nil
+}
+
+// newMetadataSearchResult builds a search result for a traditional object
+func newMetadataSearchResult(metadata *Metadata, score float64, matchFields []string, snippet string) SearchResult {
+	// Pick the best path for display
+	displayPath := ""
+	if len(metadata.Paths) > 0 {
+		// Prefer shorter, more intuitive paths
+		displayPath = metadata.Paths[0]
+		for _, path := range metadata.Paths {
+			if len(path) < len(displayPath) && !strings.Contains(path, "by-date") {
+				displayPath = path
+			}
+		}
+	}
+	
+	return SearchResult{
+		Path:        displayPath,
+		ObjectID:    metadata.ID,
+		Type:        metadata.Type,
+		Score:       score,
+		Snippet:     snippet,
+		Metadata:    *cloneMetadata(metadata),
+		MatchFields: matchFields,
+	}
 }
 
 // searchInRelations searches within the relation store for Phase D advanced discovery
 func (s *Storage) searchInRelations(query string, mode string, filters SearchFilters) ([]SearchResult, error) {
 	results := []SearchResult{}
-	queryLower := strings.ToLower(query)
+	queryLower := searchQuery(query, mode)
+	var fuzzy *fuzzyQuery
+	if mode == SearchModeFuzzy && queryLower != "" {
+		fuzzy = newFuzzyQuery(queryLower, filters.FuzzyThreshold)
+	}
 	
 	// Load all relations
 	relations, err := s.relationStore.List()
@@ -1517,24 +2365,26 @@ func (s *Storage) searchInRelations(query string, mode string, filters SearchFil
 	}
 	
 	for _, relation := range relations {
-		// Skip if type filter doesn't match
-		if filters.Type != "" && !strings.EqualFold(relation.Type, filters.Type) {
+		// Type, agent and date filters are checked before scoring
+		if !s.relationMatchesFilters(relation, filters) {
 			continue
 		}
 		
 		// Calculate search score and find matches with mode
-		score, matchFields, snippet := s.scoreRelation(relation, queryLower, mode)
+		var score float64
+		var matchFields []string
+		var snippet string
+		if fuzzy != nil {
+			score, matchFields, snippet = scoreRelationFuzzy(relation, fuzzy)
+		} else {
+			score, matchFields, snippet = s.scoreRelation(relation, queryLower, mode)
+		}
 		
 		// Skip if no match and query is specified
 		if score == 0 && query != "" {
 			continue
 		}
 		
-		// Apply additional filters (agent, date range, etc.)
-		if !s.relationMatchesFilters(relation, filters) {
-			continue
-		}
-		
 		// Create search result
 		displayPath := fmt.Sprintf("/tools/%s", relation.Properties["name"])
 		if relation.Type != "Tool" {
@@ -1580,6 +2430,14 @@ func (s *Storage) scoreRelation(relation Relation, queryLower string, mode strin
 		return 1.0, []string{}, ""
 	}
 	
+	if mode == SearchModeRegex {
+		re, err := compileSearchRegex(queryLower)
+		if err != nil {
+			return 0, nil, ""
+		}
+		return scoreRelationRegex(relation, re)
+	}
+	
 	// Split query into terms for OR/AND modes
 	terms := strings.Fields(queryLower)
 	if len(terms) == 0 {
@@ -1631,14 +2489,8 @@ func (s *Storage) scoreRelation(relation Relation, queryLower string, mode strin
 	}
 	
 	// Search in transforms (high weight for semantic similarity)
-	if transforms, ok := relation.Properties["transforms"].([]interface{}); ok {
-		transformStrs := []string{}
-		for _, transform := range transforms {
-			if transformStr, ok := transform.(string); ok {
-				transformStrs = append(transformStrs, transformStr)
-			}
-		}
-		transformText := strings.ToLower(strings.Join(transformStrs, " "))
+	if transforms := getTransforms(relation); len(transforms) > 0 {
+		transformText := strings.ToLower(strings.Join(transforms, " "))
 		
 		switch mode {
 		case "phrase", "exact":
@@ -1834,10 +2686,15 @@ func (s *Storage) scoreRelation(relation Relation, queryLower string, mode strin
 
 // relationMatchesFilters checks if relation matches search filters
 func (s *Storage) relationMatchesFilters(relation Relation, filters SearchFilters) bool {
+	// Type filter
+	if !filters.matchesType(relation.Type) {
+		return false
+	}
+	
 	// Agent filter
 	if filters.Agent != "" {
 		if agent, ok := relation.Properties["agent"].(string); ok {
-			if !strings.EqualFold(agent, filters.Agent) {
+			if !strings.EqualFold(strings.TrimPrefix(agent, "@"), strings.TrimPrefix(filters.Agent, "@")) {
 				return false
 			}
 		}
@@ -1862,6 +2719,50 @@ func getStringProperty(properties map[string]interface{}, key string) string {
 	return ""
 }
 
+// typeNames returns the types a search is limited to, Type and Types
+// together; empty means every type
+func (f SearchFilters) typeNames() []string {
+	names := make([]string, 0, len(f.Types)+1)
+	if f.Type != "" {
+		names = append(names, f.Type)
+	}
+	for _, name := range f.Types {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// matchesType reports whether objType is one of the filtered types,
+// ignoring case (relation types are capitalized, object types are not)
+func (f SearchFilters) matchesType(objType string) bool {
+	names := f.typeNames()
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if strings.EqualFold(name, objType) {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyType reports whether the search is limited to objType alone
+func (f SearchFilters) onlyType(objType string) bool {
+	names := f.typeNames()
+	if len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		if !strings.EqualFold(name, objType) {
+			return false
+		}
+	}
+	return true
+}
+
 // matchesFilters checks if metadata matches all provided filters
 func matchesFilters(metadata *Metadata, filters SearchFilters) bool {
 	// Path filter
@@ -1879,7 +2780,7 @@ func matchesFilters(metadata *Metadata, filters SearchFilters) bool {
 	}
 	
 	// Type filter
-	if filters.Type != "" && metadata.Type != filters.Type {
+	if !filters.matchesType(metadata.Type) {
 		return false
 	}
 	
@@ -1931,6 +2832,15 @@ func searchInMetadata(metadata *Metadata, queryLower string, mode string) (float
 		return 1.0, []string{"all"}, metadata.Description
 	}
 	
+	if mode == SearchModeRegex {
+		re, err := compileSearchRegex(queryLower)
+		if err != nil {
+			return 0, matchFields, ""
+		}
+		score, matchFields, snippet = searchMetadataRegex(metadata, re)
+		return score * recencyBoost(metadata.Created), matchFields, snippet
+	}
+	
 	// Handle different search modes
 	switch mode {
 	case "phrase", "exact":
@@ -1977,6 +2887,7 @@ func searchInMetadata(metadata *Metadata, queryLower string, mode string) (float
 		}
 		if allMatchInDesc {
 			score += 3.0
+			score += proximityBonus(strings.ToLower(metadata.Description), terms, proximityDescriptionWeight)
 			matchFields = append(matchFields, "description")
 			snippet = extractSnippet(metadata.Description, terms[0])
 		}
@@ -1991,6 +2902,7 @@ func searchInMetadata(metadata *Metadata, queryLower string, mode string) (float
 		}
 		if allMatchInTitle {
 			score += 2.5
+			score += proximityBonus(strings.ToLower(metadata.Title), terms, proximityTitleWeight)
 			matchFields = append(matchFields, "title")
 			if snippet == "" {
 				snippet = metadata.Title
@@ -2033,6 +2945,7 @@ func searchInMetadata(metadata *Metadata, queryLower string, mode string) (float
 		if descMatches > 0 {
 			// Score based on percentage of terms matched
 			score += 3.0 * float64(descMatches) / float64(len(terms))
+			score += proximityBonus(strings.ToLower(metadata.Description), terms, proximityDescriptionWeight)
 			matchFields = append(matchFields, "description")
 			// Find first matching term for snippet
 			for _, term := range terms {
@@ -2052,6 +2965,7 @@ func searchInMetadata(metadata *Metadata, queryLower string, mode string) (float
 		}
 		if titleMatches > 0 {
 			score += 2.5 * float64(titleMatches) / float64(len(terms))
+			score += proximityBonus(strings.ToLower(metadata.Title), terms, proximityTitleWeight)
 			matchFields = append(matchFields, "title")
 			if snippet == "" {
 				snippet = metadata.Title
@@ -2146,24 +3060,44 @@ func searchInMetadata(metadata *Metadata, queryLower string, mode string) (float
 	}
 	
 	// Boost recent items slightly
-	age := time.Since(metadata.Created)
+	score *= recencyBoost(metadata.Created)
+	
+	return score, matchFields, snippet
+}
+
+// recencyBoost slightly favors recently created items
+func recencyBoost(created time.Time) float64 {
+	age := time.Since(created)
 	if age < 24*time.Hour {
-		score *= 1.2
+		return 1.2
 	} else if age < 7*24*time.Hour {
-		score *= 1.1
+		return 1.1
 	}
-	
-	return score, matchFields, snippet
+	return 1.0
 }
 
-// searchInContent searches in the actual content of an object
-func (s *Storage) searchInContent(objID, queryLower, mode, objType string) (float64, string) {
+// searchInContent searches in the actual content of an object, or in its
+// first limit bytes
+func (s *Storage) searchInContent(objID, queryLower, mode string, limit int64) (float64, string) {
 	content, err := s.Read(objID)
 	if err != nil {
 		return 0, ""
 	}
 	
-	contentStr := string(content)
+	return scoreContent(string(truncateUTF8(content, limit)), queryLower, mode)
+}
+
+// scoreContent scores text against a query the way content search does,
+// returning 0 when it doesn't match
+func scoreContent(contentStr, queryLower, mode string) (float64, string) {
+	if mode == SearchModeRegex {
+		re, err := compileSearchRegex(queryLower)
+		if err != nil {
+			return 0, ""
+		}
+		return searchContentRegex(contentStr, re)
+	}
+	
 	contentLower := strings.ToLower(contentStr)
 	
 	score := 0.0
@@ -2212,6 +3146,7 @@ func (s *Storage) searchInContent(objID, queryLower, mode, objType string) (floa
 			totalCount += count
 		}
 		score += float64(totalCount) * 0.1
+		score += proximityBonus(contentLower, terms, proximityContentWeight)
 		snippet = extractSnippet(contentStr, terms[0])
 		
 	case "or":
@@ -2248,6 +3183,7 @@ func (s *Storage) searchInContent(objID, queryLower, mode, objType string) (floa
 		// Score based on percentage of terms matched
 		score = 1.0 * float64(matchCount) / float64(len(terms))
 		score += float64(totalCount) * 0.1
+		score += proximityBonus(contentLower, terms, proximityContentWeight)
 		snippet = extractSnippet(contentStr, firstMatch)
 	}
 	
@@ -2262,13 +3198,18 @@ func extractSnippet(text, query string) string {
 		return ""
 	}
 	
+	return extractSnippetAt(text, idx, idx+len(query))
+}
+
+// extractSnippetAt extracts a snippet around the match at text[matchStart:matchEnd]
+func extractSnippetAt(text string, matchStart, matchEnd int) string {
 	// Extract ~80 chars around the match
-	start := idx - 40
+	start := matchStart - 40
 	if start < 0 {
 		start = 0
 	}
 	
-	end := idx + len(query) + 40
+	end := matchEnd + 40
 	if end > len(text) {
 		end = len(text)
 	}
@@ -2317,6 +3258,14 @@ func (s *Storage) handleToolsPath(path string) []map[string]interface{} {
 			"name": "ancestry",
 			"type": "directory",
 		})
+		entries = append(entries, map[string]interface{}{
+			"name": "by-date",
+			"type": "directory",
+		})
+		entries = append(entries, map[string]interface{}{
+			"name": "by-usage",
+			"type": "directory",
+		})
 		
 		// Also show individual tools as directories
 		if relations, err := s.relationStore.List(); err == nil {
@@ -2363,6 +3312,20 @@ func (s *Storage) handleToolsPath(path string) []map[string]interface{} {
 		// /tools/ancestry/ - tools with parent chains
 		return s.handleAncestryIndex()
 		
+	case toolsPath == "/by-date" || toolsPath == "/by-date/":
+		// /tools/by-date/ - days that have tools, newest first
+		return s.handleToolsByDate("")
+		
+	case strings.HasPrefix(toolsPath, "/by-date/"):
+		// /tools/by-date/{YYYY-MM-DD}/ - tools created that day
+		day := strings.TrimPrefix(toolsPath, "/by-date/")
+		day = strings.TrimSuffix(day, "/")
+		return s.handleToolsByDate(day)
+		
+	case toolsPath == "/by-usage" || toolsPath == "/by-usage/":
+		// /tools/by-usage/ - most used tools first
+		return s.handleToolsByUsage()
+		
 	default:
 		// Check if it's an individual tool directory
 		parts := strings.Split(strings.Trim(toolsPath, "/"), "/")
@@ -2404,6 +3367,108 @@ func (s *Storage) handleToolsByName() []map[string]interface{} {
 	return entries
 }
 
+// handleToolsByDate lists days with tools (newest first), or the tools
+// created on a specific day (newest first)
+func (s *Storage) handleToolsByDate(day string) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	if s.relationStore == nil {
+		return entries
+	}
+	
+	relations, err := s.relationStore.List()
+	if err != nil {
+		return entries
+	}
+	
+	tools := []Relation{}
+	for _, relation := range relations {
+		if relation.Type == "Tool" && getRelationName(relation) != "" {
+			tools = append(tools, relation)
+		}
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].CreatedAt.After(tools[j].CreatedAt)
+	})
+	
+	if day == "" {
+		// Tools are sorted newest first, so days come out in order too
+		counts := make(map[string]int)
+		days := []string{}
+		for _, tool := range tools {
+			toolDay := tool.CreatedAt.Format("2006-01-02")
+			if counts[toolDay] == 0 {
+				days = append(days, toolDay)
+			}
+			counts[toolDay]++
+		}
+		for _, toolDay := range days {
+			entries = append(entries, map[string]interface{}{
+				"name":  toolDay,
+				"type":  "directory",
+				"count": counts[toolDay],
+			})
+		}
+		return entries
+	}
+	
+	for _, tool := range tools {
+		if tool.CreatedAt.Format("2006-01-02") != day {
+			continue
+		}
+		entries = append(entries, map[string]interface{}{
+			"name":        getRelationName(tool),
+			"type":        "directory",
+			"relation_id": tool.ID,
+			"created":     tool.CreatedAt,
+			"modified":    tool.UpdatedAt,
+		})
+	}
+	return entries
+}
+
+// handleToolsByUsage lists tools by recorded executions, most used first
+func (s *Storage) handleToolsByUsage() []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	if s.relationStore == nil {
+		return entries
+	}
+	
+	relations, err := s.relationStore.List()
+	if err != nil {
+		return entries
+	}
+	
+	tools := []Relation{}
+	for _, relation := range relations {
+		if relation.Type == "Tool" && getRelationName(relation) != "" {
+			tools = append(tools, relation)
+		}
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		left, right := toolUsageCount(tools[i]), toolUsageCount(tools[j])
+		if left != right {
+			return left > right
+		}
+		return getRelationName(tools[i]) < getRelationName(tools[j])
+	})
+	
+	for _, tool := range tools {
+		entry := map[string]interface{}{
+			"name":        getRelationName(tool),
+			"type":        "directory",
+			"relation_id": tool.ID,
+			"usage_count": toolUsageCount(tool),
+			"created":     tool.CreatedAt,
+		}
+		if lastRun := getStringProperty(tool.Properties, PropLastRun); lastRun != "" {
+			entry["last_run"] = lastRun
+			entry["last_run_status"] = getStringProperty(tool.Properties, PropLastRunStatus)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
 // handleToolsByTransform shows tools grouped by transforms or specific transform
 func (s *Storage) handleToolsByTransform(specificTransform string) []map[string]interface{} {
 	entries := []map[string]interface{}{}
@@ -2414,14 +3479,8 @@ func (s *Storage) handleToolsByTransform(specificTransform string) []map[string]
 		if relations, err := s.relationStore.List(); err == nil {
 			for _, relation := range relations {
 				if relation.Type == "Tool" {
-					if transformsRaw, exists := relation.Properties["transforms"]; exists {
-						if transformsList, ok := transformsRaw.([]interface{}); ok {
-							for _, t := range transformsList {
-								if tStr, ok := t.(string); ok {
-									transformSet[tStr] = true
-								}
-							}
-						}
+					for _, transform := range getTransforms(relation) {
+						transformSet[transform] = true
 					}
 				}
 			}
@@ -2495,17 +3554,8 @@ func (s *Storage) handleSpawnedByTool(toolName string) []map[string]interface{}
 	entries := []map[string]interface{}{}
 	
 	// Find the tool relation first
-	var parentRelation *Relation
-	if relations, err := s.relationStore.List(); err == nil {
-		for _, relation := range relations {
-			if name, ok := relation.Properties["name"].(string); ok && name == toolName {
-				parentRelation = &relation
-				break
-			}
-		}
-	}
-	
-	if parentRelation == nil {
+	parentRelation, _, err := s.resolveTool(toolName)
+	if err != nil {
 		return entries
 	}
 	
@@ -2569,6 +3619,15 @@ func (s *Storage) handleIndividualTool(toolName string) []map[string]interface{}
 		"name": "executable",
 		"type": "file",
 	})
+	entries = append(entries, map[string]interface{}{
+		"name":     "source",
+		"type":     "file",
+		"language": s.ToolLanguage(toolName, nil),
+	})
+	entries = append(entries, map[string]interface{}{
+		"name": "run",
+		"type": "file",
+	})
 	entries = append(entries, map[string]interface{}{
 		"name": "spawned",
 		"type": "directory",
@@ -2577,10 +3636,75 @@ func (s *Storage) handleIndividualTool(toolName string) []map[string]interface{}
 		"name": "parents",
 		"type": "directory",
 	})
+	entries = append(entries, map[string]interface{}{
+		"name": "versions",
+		"type": "directory",
+	})
 	
 	return entries
 }
 
+// ToolLanguage returns a tool's language from its relation's language
+// property, falling back to the shebang of its source. content may be nil,
+// in which case the source is read if needed.
+func (s *Storage) ToolLanguage(toolName string, content []byte) string {
+	if s.relationStore == nil {
+		return ""
+	}
+	relations, err := s.relationStore.LoadByType("Tool")
+	if err != nil {
+		return ""
+	}
+	
+	for _, relation := range relations {
+		if name, ok := relation.Properties["name"].(string); !ok || name != toolName {
+			continue
+		}
+		if language, ok := relation.Properties["language"].(string); ok && language != "" {
+			return language
+		}
+		
+		// Tools materialized before the language was recorded
+		if content == nil {
+			if objID := s.resolveToolsPath("/tools/" + toolName + "/source"); objID != "" {
+				content, _ = s.Read(objID)
+			}
+		}
+		return languageFromShebang(content)
+	}
+	return ""
+}
+
+// toolSourceName returns the tool name for a /tools/{name}/source or
+// /tools/{name}/run path
+func toolSourceName(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 3 && parts[0] == "tools" && (parts[2] == "source" || parts[2] == "run") {
+		return parts[1], true
+	}
+	return "", false
+}
+
+// languageFromShebang maps a script's shebang to a supported tool language
+func languageFromShebang(content []byte) string {
+	firstLine := string(content)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if !strings.HasPrefix(firstLine, "#!") {
+		return ""
+	}
+	switch {
+	case strings.Contains(firstLine, "python"):
+		return "python"
+	case strings.Contains(firstLine, "node"):
+		return "node"
+	case strings.Contains(firstLine, "bash"), strings.HasSuffix(firstLine, "/sh"):
+		return "bash"
+	}
+	return ""
+}
+
 // handleToolSubpath handles specific tool subpaths
 func (s *Storage) handleToolSubpath(toolName string, subpath string) []map[string]interface{} {
 	entries := []map[string]interface{}{}
@@ -2593,6 +3717,10 @@ func (s *Storage) handleToolSubpath(toolName string, subpath string) []map[strin
 	case "parents", "parents/":
 		// Show parent chain for this tool
 		return s.handleParentChain(toolName)
+		
+	case "versions", "versions/":
+		// Show executable history for this tool
+		return s.handleToolVersions(toolName)
 	}
 	
 	return entries
@@ -2603,18 +3731,13 @@ func (s *Storage) handleParentChain(toolName string) []map[string]interface{} {
 	entries := []map[string]interface{}{}
 	
 	// Find the tool and its parent
-	if relations, err := s.relationStore.List(); err == nil {
-		for _, relation := range relations {
-			if name, ok := relation.Properties["name"].(string); ok && name == toolName {
-				if parent, exists := relation.Properties["parent"]; exists {
-					if parentName, ok := parent.(string); ok {
-						entries = append(entries, map[string]interface{}{
-							"name": parentName,
-							"type": "directory",
-						})
-					}
-				}
-				break
+	if relation, _, err := s.resolveTool(toolName); err == nil {
+		if parent, exists := relation.Properties["parent"]; exists {
+			if parentName, ok := parent.(string); ok {
+				entries = append(entries, map[string]interface{}{
+					"name": parentName,
+					"type": "directory",
+				})
 			}
 		}
 	}
@@ -2624,16 +3747,7 @@ func (s *Storage) handleParentChain(toolName string) []map[string]interface{} {
 
 // hasTransformInRelation checks if a relation has a specific transform (helper function)
 func hasTransformInRelation(relation Relation, transform string) bool {
-	if transformsRaw, exists := relation.Properties["transforms"]; exists {
-		if transformsList, ok := transformsRaw.([]interface{}); ok {
-			for _, t := range transformsList {
-				if tStr, ok := t.(string); ok && tStr == transform {
-					return true
-				}
-			}
-		}
-	}
-	return false
+	return contains(getTransforms(relation), transform)
 }
 
 // handleEnhancedCommandsView shows relation-backed tools as commands with metadata
@@ -2647,7 +3761,7 @@ func (s *Storage) handleEnhancedCommandsView() []map[string]interface{} {
 	// Get all tool relations
 	relations, err := s.relationStore.List()
 	if err != nil {
-		log.Printf("Failed to load relations for commands view: %v", err)
+		logger.Warnf("Failed to load relations for commands view: %v", err)
 		return entries
 	}
 	
@@ -2685,6 +3799,155 @@ func (s *Storage) handleEnhancedCommandsView() []map[string]interface{} {
 	return entries
 }
 
+// /commands view sources
+const (
+	CommandsViewRelation   = "relation"   // Tool relations only
+	CommandsViewSymlink    = "symlink"    // ~/.port42/commands symlinks only
+	CommandsViewReconciled = "reconciled" // Merge both and flag drift
+)
+
+// loadCommandsViewSource reads PORT42_COMMANDS_VIEW, defaulting to reconciled
+func loadCommandsViewSource() string {
+	source := strings.ToLower(envString("PORT42_COMMANDS_VIEW", CommandsViewReconciled))
+	switch source {
+	case CommandsViewRelation, CommandsViewSymlink, CommandsViewReconciled:
+		return source
+	default:
+		logger.Warnf("⚠️ [STORAGE] Unknown PORT42_COMMANDS_VIEW %q, using %s", source, CommandsViewReconciled)
+		return CommandsViewReconciled
+	}
+}
+
+// handleSymlinkCommandsView lists the command symlinks actually on disk
+func (s *Storage) handleSymlinkCommandsView() []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	
+	cmdDir := filepath.Join(s.baseDir, "commands")
+	files, err := os.ReadDir(cmdDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("Failed to read commands directory: %v", err)
+		}
+		return entries
+	}
+	
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		
+		entry := map[string]interface{}{
+			"name": file.Name(),
+			"type": "file",
+		}
+		
+		linkPath := filepath.Join(cmdDir, file.Name())
+		if target, err := os.Readlink(linkPath); err == nil {
+			entry["target"] = target
+			if objectID := s.commandObjectID(file.Name()); objectID != "" {
+				entry["id"] = objectID
+			}
+			if _, err := os.Stat(linkPath); err != nil {
+				entry["broken"] = true
+			}
+		}
+		if info, err := os.Stat(linkPath); err == nil {
+			entry["size"] = info.Size()
+			entry["modified"] = info.ModTime()
+		}
+		
+		entries = append(entries, entry)
+	}
+	
+	return entries
+}
+
+// handleReconciledCommandsView merges relation and symlink views, flagging drift
+func (s *Storage) handleReconciledCommandsView() []map[string]interface{} {
+	relationEntries := s.handleEnhancedCommandsView()
+	symlinkEntries := s.handleSymlinkCommandsView()
+	
+	symlinks := make(map[string]map[string]interface{}, len(symlinkEntries))
+	for _, entry := range symlinkEntries {
+		symlinks[entry["name"].(string)] = entry
+	}
+	
+	// Executable IDs recorded on relations, for target comparison
+	executables := make(map[string]string)
+	if s.relationStore != nil {
+		if relations, err := s.relationStore.LoadByType("Tool"); err == nil {
+			for _, relation := range relations {
+				name, _ := relation.Properties["name"].(string)
+				if executableID, ok := relation.Properties["executable_id"].(string); ok && name != "" {
+					executables[name] = executableID
+				}
+			}
+		}
+	}
+	
+	entries := []map[string]interface{}{}
+	seen := make(map[string]bool)
+	driftCount := 0
+	
+	for _, entry := range relationEntries {
+		name := entry["name"].(string)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		
+		link, hasLink := symlinks[name]
+		switch {
+		case !hasLink:
+			entry["source"] = "relation"
+			entry["drift"] = "missing_symlink"
+		case link["broken"] == true:
+			entry["source"] = "both"
+			entry["drift"] = "broken_symlink"
+			entry["target"] = link["target"]
+		default:
+			entry["source"] = "both"
+			entry["target"] = link["target"]
+			if linkID, ok := link["id"].(string); ok {
+				entry["id"] = linkID
+				if executableID, ok := executables[name]; ok && executableID != linkID {
+					entry["drift"] = "executable_mismatch"
+					entry["executable_id"] = executableID
+				}
+			}
+		}
+		
+		if _, drifted := entry["drift"]; drifted {
+			driftCount++
+		}
+		entries = append(entries, entry)
+	}
+	
+	// Symlinks with no backing relation (legacy or orphaned commands)
+	for _, entry := range symlinkEntries {
+		name := entry["name"].(string)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		
+		entry["source"] = "symlink"
+		if entry["broken"] == true {
+			entry["drift"] = "broken_symlink"
+		} else {
+			entry["drift"] = "missing_relation"
+		}
+		driftCount++
+		entries = append(entries, entry)
+	}
+	
+	if driftCount > 0 {
+		logger.Warnf("⚠️ [STORAGE] /commands drift: %d entries disagree between relations and symlinks", driftCount)
+	}
+	
+	return entries
+}
+
 // handleEnhancedByDateView includes relations alongside traditional objects by date
 func (s *Storage) handleEnhancedByDateView(path string) []map[string]interface{} {
 	entries := []map[string]interface{}{}
@@ -2701,7 +3964,7 @@ func (s *Storage) handleEnhancedByDateView(path string) []map[string]interface{}
 	// Get traditional object entries by date (existing logic)
 	ids, err := s.List()
 	if err != nil {
-		log.Printf("Error listing objects for by-date: %v", err)
+		logger.Warnf("Error listing objects for by-date: %v", err)
 		return entries
 	}
 	
@@ -2802,7 +4065,7 @@ func (s *Storage) handleMemoryGeneratedView(path string) []map[string]interface{
 	// Get all relations and filter by memory_session
 	relations, err := s.relationStore.List()
 	if err != nil {
-		log.Printf("Failed to load relations for generated view: %v", err)
+		logger.Warnf("Failed to load relations for generated view: %v", err)
 		return entries
 	}
 	
@@ -2830,23 +4093,75 @@ func (s *Storage) handleMemoryGeneratedView(path string) []map[string]interface{
 
 // handleSimilarView provides semantic tool discovery through similarity analysis
 func (s *Storage) handleSimilarView(path string) []map[string]interface{} {
+	// An optional ?min= suffix overrides the view threshold
+	path, threshold, err := parseSimilarPath(path, s.similarity.ViewThreshold)
+	if err != nil {
+		return []map[string]interface{}{
+			{
+				"name":        "⚠️ Invalid similarity threshold",
+				"type":        "error",
+				"description": err.Error(),
+			},
+		}
+	}
+	
 	// Extract tool name from path if provided
 	pathParts := strings.Split(strings.Trim(path, "/"), "/")
 	
 	if len(pathParts) == 1 && pathParts[0] == "similar" {
 		// Root /similar path
-		return s.handleSimilarRootView()
+		return s.handleSimilarRootView(threshold)
 	} else if len(pathParts) >= 2 {
 		// Specific tool similarity path
 		toolName := pathParts[1]
-		return s.handleSimilarToolView(toolName)
+		return s.handleSimilarToolView(toolName, threshold)
 	}
 	
 	return []map[string]interface{}{}
 }
 
+// parseSimilarPath splits a /similar path from its optional "?min=" query.
+// min is a fraction (0.6) or a percentage (60); without it the threshold is
+// defaultThreshold.
+func parseSimilarPath(path string, defaultThreshold float64) (string, float64, error) {
+	path, rawQuery, hasQuery := strings.Cut(path, "?")
+	if !hasQuery {
+		return path, defaultThreshold, nil
+	}
+	
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return path, 0, fmt.Errorf("invalid query %q: %v", rawQuery, err)
+	}
+	value := query.Get("min")
+	if value == "" {
+		return path, defaultThreshold, nil
+	}
+	
+	threshold, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return path, 0, fmt.Errorf("min must be a number, got %q", value)
+	}
+	if threshold > 1 {
+		threshold /= 100 // A percentage
+	}
+	if threshold <= 0 || threshold > 1 {
+		return path, 0, fmt.Errorf("min must be between 0 and 1 (or 1 and 100 as a percentage), got %q", value)
+	}
+	return path, threshold, nil
+}
+
+// similarThresholdSuffix is the ?min= query that keeps a non-default
+// threshold on the paths a /similar listing links to
+func (s *Storage) similarThresholdSuffix(threshold float64) string {
+	if threshold == s.similarity.ViewThreshold {
+		return ""
+	}
+	return "?min=" + strconv.FormatFloat(threshold, 'f', -1, 64)
+}
+
 // handleSimilarRootView shows all tools that have similar tools available
-func (s *Storage) handleSimilarRootView() []map[string]interface{} {
+func (s *Storage) handleSimilarRootView(threshold float64) []map[string]interface{} {
 	calculator := s.getSimilarityCalculator()
 	if calculator == nil {
 		return []map[string]interface{}{
@@ -2872,7 +4187,7 @@ func (s *Storage) handleSimilarRootView() []map[string]interface{} {
 	
 	entries := []map[string]interface{}{}
 	
-	// Find tools with similar tools (threshold 0.2 for directory listing)
+	// Find tools with similar tools (PORT42_SIMILARITY_THRESHOLD unless ?min= is given)
 	for _, relation := range allRelations {
 		if relation.Type != "Tool" {
 			continue
@@ -2884,7 +4199,7 @@ func (s *Storage) handleSimilarRootView() []map[string]interface{} {
 		}
 		
 		// Find similar tools for this tool
-		similarTools, err := calculator.findSimilarTools(relation, 0.2)
+		similarTools, err := calculator.similarToolsAmong(relation, allRelations, threshold)
 		if err != nil {
 			continue
 		}
@@ -2896,7 +4211,7 @@ func (s *Storage) handleSimilarRootView() []map[string]interface{} {
 				"type":             "directory",
 				"similar_count":    len(similarTools),
 				"description":      fmt.Sprintf("Tool with %d similar tools", len(similarTools)),
-				"path":            fmt.Sprintf("/similar/%s", toolName),
+				"path":            fmt.Sprintf("/similar/%s%s", toolName, s.similarThresholdSuffix(threshold)),
 			}
 			entries = append(entries, entry)
 		}
@@ -2907,7 +4222,7 @@ func (s *Storage) handleSimilarRootView() []map[string]interface{} {
 			{
 				"name":        "🔍 No tools with similarities found",
 				"type":        "notice",
-				"description": "Either no tools exist or none have sufficient similarity (>20%)",
+				"description": fmt.Sprintf("Either no tools exist or none have sufficient similarity (>%.0f%%)", threshold*100),
 			},
 		}
 	}
@@ -2916,7 +4231,7 @@ func (s *Storage) handleSimilarRootView() []map[string]interface{} {
 }
 
 // handleSimilarToolView shows tools similar to a specific target tool
-func (s *Storage) handleSimilarToolView(toolName string) []map[string]interface{} {
+func (s *Storage) handleSimilarToolView(toolName string, threshold float64) []map[string]interface{} {
 	calculator := s.getSimilarityCalculator()
 	if calculator == nil {
 		return []map[string]interface{}{
@@ -2928,8 +4243,8 @@ func (s *Storage) handleSimilarToolView(toolName string) []map[string]interface{
 		}
 	}
 	
-	// Find similar tools using the calculator (threshold 0.2)
-	similarTools, err := calculator.GetSimilarToolsForTool(toolName, 0.2)
+	// Find similar tools using the calculator (PORT42_SIMILARITY_THRESHOLD unless ?min= is given)
+	similarTools, err := calculator.GetSimilarToolsForTool(toolName, threshold)
 	if err != nil {
 		return []map[string]interface{}{
 			{
@@ -2945,7 +4260,7 @@ func (s *Storage) handleSimilarToolView(toolName string) []map[string]interface{
 			{
 				"name":        fmt.Sprintf("🔍 No similar tools found for '%s'", toolName),
 				"type":        "notice",
-				"description": "No tools found with similarity above 20% threshold",
+				"description": fmt.Sprintf("No tools found with similarity above %.0f%% threshold", threshold*100),
 			},
 		}
 	}
@@ -2976,18 +4291,7 @@ func (s *Storage) similarToolToVirtualNode(similarTool SimilarTool) map[string]i
 	}
 	
 	// Extract transforms for additional context
-	transforms := []string{}
-	if transformsRaw, exists := similarTool.Tool.Properties["transforms"]; exists {
-		if transformsInterface, ok := transformsRaw.([]interface{}); ok {
-			for _, t := range transformsInterface {
-				if tStr, ok := t.(string); ok {
-					transforms = append(transforms, tStr)
-				}
-			}
-		} else if transformsString, ok := transformsRaw.([]string); ok {
-			transforms = transformsString
-		}
-	}
+	transforms := getTransforms(similarTool.Tool)
 	
 	entry := map[string]interface{}{
 		"name":          toolName,
@@ -3008,5 +4312,5 @@ func (s *Storage) getSimilarityCalculator() *SimilarityCalculator {
 	if s.relationStore == nil {
 		return nil
 	}
-	return NewSimilarityCalculator(s.relationStore)
+	return NewSimilarityCalculator(s.relationStore, s.similarity)
 }
\ No newline at end of file
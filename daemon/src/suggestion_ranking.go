@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Suggestion scoring defaults: recency halves every half-life, and
+// frequency is use count relative to the most used item
+const (
+	defaultSuggestRecencyWeight   = 0.6
+	defaultSuggestFrequencyWeight = 0.4
+	defaultSuggestHalfLife        = 30 * time.Minute
+	maxSuggestions                = 5
+)
+
+// SuggestionWeights controls how context suggestions are ranked
+type SuggestionWeights struct {
+	Recency   float64       // PORT42_SUGGEST_RECENCY_WEIGHT
+	Frequency float64       // PORT42_SUGGEST_FREQUENCY_WEIGHT
+	HalfLife  time.Duration // PORT42_SUGGEST_HALF_LIFE
+}
+
+// loadSuggestionWeights reads the ranking weights, falling back to the
+// defaults if both weights are zero or either is negative
+func loadSuggestionWeights() SuggestionWeights {
+	weights := SuggestionWeights{
+		Recency:   envFloat("PORT42_SUGGEST_RECENCY_WEIGHT", defaultSuggestRecencyWeight),
+		Frequency: envFloat("PORT42_SUGGEST_FREQUENCY_WEIGHT", defaultSuggestFrequencyWeight),
+		HalfLife:  envPositiveDuration("PORT42_SUGGEST_HALF_LIFE", defaultSuggestHalfLife),
+	}
+	if weights.Recency < 0 || weights.Frequency < 0 || weights.Recency+weights.Frequency == 0 {
+		log.Printf("⚠️ Suggestion weights must be non-negative and not both zero, using %.1f/%.1f",
+			defaultSuggestRecencyWeight, defaultSuggestFrequencyWeight)
+		weights.Recency = defaultSuggestRecencyWeight
+		weights.Frequency = defaultSuggestFrequencyWeight
+	}
+	return weights
+}
+
+// score combines how recently and how often something was used into 0..1.
+// frequency is already relative to the most used candidate.
+func (w SuggestionWeights) score(last time.Time, frequency float64, now time.Time) float64 {
+	recency := 0.0
+	if !last.IsZero() {
+		recency = math.Pow(0.5, now.Sub(last).Seconds()/w.HalfLife.Seconds())
+	}
+	return (w.Recency*recency + w.Frequency*frequency) / (w.Recency + w.Frequency)
+}
+
+// activityCandidate is a tracked command or path that could be suggested
+type activityCandidate struct {
+	command string
+	reason  string
+	count   int
+	last    time.Time
+}
+
+// activitySuggestions scores the tracked commands and memory accesses.
+// Commands are grouped by text; truncated search queries and bare command
+// names can't be re-run, so they aren't suggested.
+func (cc *ContextCollector) activitySuggestions(data *ContextData, now time.Time) []ContextSuggestion {
+	var candidates []*activityCandidate
+	byCommand := make(map[string]*activityCandidate)
+	add := func(command, noun string, count int, last time.Time) {
+		if existing, ok := byCommand[command]; ok {
+			existing.count += count
+			if last.After(existing.last) {
+				existing.last = last
+			}
+			return
+		}
+		candidate := &activityCandidate{command: command, reason: noun, count: count, last: last}
+		byCommand[command] = candidate
+		candidates = append(candidates, candidate)
+	}
+
+	for _, record := range data.RecentCommands {
+		if !strings.Contains(record.Command, " ") || strings.Contains(record.Command, "...") {
+			continue
+		}
+		add("port42 "+record.Command, "Run again", 1, record.Timestamp)
+	}
+	for _, access := range data.AccessedMemories {
+		verb, noun := "cat", "Read again"
+		switch {
+		case strings.HasPrefix(access.Type, "browse"):
+			verb, noun = "ls", "Browse again"
+		case strings.HasPrefix(access.Type, "info"), access.Type == "session", access.Type == "created":
+			verb, noun = "info", "Revisit"
+		}
+		name := access.DisplayName
+		if name == "" {
+			name = access.Path
+		}
+		add(fmt.Sprintf("port42 %s %s", verb, access.Path), noun+": "+name, access.AccessCount, access.LastAccessed)
+	}
+
+	maxCount := 1
+	for _, candidate := range candidates {
+		maxCount = max(maxCount, candidate.count)
+	}
+	suggestions := make([]ContextSuggestion, 0, len(candidates))
+	for _, candidate := range candidates {
+		score := cc.weights.score(candidate.last, float64(candidate.count)/float64(maxCount), now)
+		suggestions = append(suggestions, ContextSuggestion{
+			Command:    candidate.command,
+			Reason:     fmt.Sprintf("%s (%s, %s)", candidate.reason, pluralize(candidate.count, "use"), formatAge(now.Sub(candidate.last))),
+			Confidence: score,
+			Score:      score,
+		})
+	}
+	return suggestions
+}
+
+// rankSuggestions orders suggestions by score, keeps the best scoring
+// entry per command, and returns the top maxSuggestions
+func rankSuggestions(suggestions []ContextSuggestion) []ContextSuggestion {
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	ranked := make([]ContextSuggestion, 0, maxSuggestions)
+	seen := make(map[string]bool)
+	for _, suggestion := range suggestions {
+		if seen[suggestion.Command] {
+			continue
+		}
+		seen[suggestion.Command] = true
+		suggestion.Score = math.Round(suggestion.Score*1000) / 1000
+		ranked = append(ranked, suggestion)
+		if len(ranked) == maxSuggestions {
+			break
+		}
+	}
+	return ranked
+}
+
+// pluralize formats a count with its noun
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// formatAge describes a duration as "just now", "5m ago", "2h ago" or "3d ago"
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
+}
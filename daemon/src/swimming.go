@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,6 +15,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+	
+	"port42/daemon/resolution"
 )
 
 // PendingApproval tracks a bash command waiting for user approval
@@ -60,6 +63,7 @@ type AnthropicClient struct {
 	httpClient *http.Client
 	lastRequest time.Time
 	requestMutex sync.Mutex
+	retry      RetryPolicy
 }
 
 // AnthropicRequest represents a request to Claude
@@ -83,15 +87,18 @@ type AnthropicTool struct {
 
 // AnthropicResponse represents Claude's response
 type AnthropicResponse struct {
-	Content []struct {
-		Type  string          `json:"type"`
-		Text  string          `json:"text,omitempty"`
-		ID    string          `json:"id,omitempty"` // Tool use ID
-		Name  string          `json:"name,omitempty"`
-		Input json.RawMessage `json:"input,omitempty"`
-	} `json:"content"`
-	Error      *AnthropicError `json:"error,omitempty"`
-	StopReason string          `json:"stop_reason,omitempty"`
+	Content    []AnthropicContentBlock `json:"content"`
+	Error      *AnthropicError         `json:"error,omitempty"`
+	StopReason string                  `json:"stop_reason,omitempty"`
+}
+
+// AnthropicContentBlock is one text or tool_use block of a response
+type AnthropicContentBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"` // Tool use ID
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
 }
 
 // AnthropicError for API errors
@@ -120,6 +127,7 @@ type ArtifactSpec struct {
 	Content     map[string]string      `json:"content"`     // For multi-file artifacts (path -> content)
 	SingleFile  string                 `json:"single_file,omitempty"` // For single file content
 	Format      string                 `json:"format"`      // md, html, py, js, svg, etc
+	Encoding    string                 `json:"encoding,omitempty"` // "base64" when file contents are binary, else text
 	Metadata    map[string]interface{} `json:"metadata,omitempty"` // Additional metadata
 	SessionID   string                 `json:"session_id,omitempty"` // Session that created this
 	Agent       string                 `json:"agent,omitempty"` // Agent that created this
@@ -151,6 +159,7 @@ func NewAnthropicClient() *AnthropicClient {
 		apiKey:     apiKey,
 		apiURL:     "https://api.anthropic.com/v1/messages",
 		httpClient: &http.Client{Timeout: 300 * time.Second}, // Increased timeout for Claude Opus (5 minutes)
+		retry:      loadRetryPolicy(),
 	}
 }
 
@@ -162,6 +171,12 @@ type AnthropicMessage struct {
 
 // SendWithoutTools sends a message to Claude without any tools - for pure text generation
 func (c *AnthropicClient) SendWithoutTools(messages []Message, systemPrompt string, agentName string) (*AnthropicResponse, error) {
+	return c.SendWithoutToolsContext(context.Background(), messages, systemPrompt, agentName)
+}
+
+// SendWithoutToolsContext is SendWithoutTools bounded by ctx; without a
+// deadline the retry policy's PORT42_AI_TIMEOUT applies
+func (c *AnthropicClient) SendWithoutToolsContext(ctx context.Context, messages []Message, systemPrompt string, agentName string) (*AnthropicResponse, error) {
 	// Get model configuration for this agent
 	modelDef, err := GetModelForAgent(agentName)
 	if err != nil {
@@ -219,7 +234,7 @@ func (c *AnthropicClient) SendWithoutTools(messages []Message, systemPrompt stri
 	// We explicitly set tools to nil for pure text generation
 	
 	// Debug log the model
-	log.Printf("🔍 Using model for agent %s (NO TOOLS): ID=%s, Name=%s, Temp=%.2f", 
+	logger.Debugf("🔍 Using model for agent %s (NO TOOLS): ID=%s, Name=%s, Temp=%.2f", 
 		agentName, modelDef.ID, modelDef.Name, modelDef.Temperature)
 	
 	req := AnthropicRequest{
@@ -240,7 +255,7 @@ func (c *AnthropicClient) SendWithoutTools(messages []Message, systemPrompt stri
 	}
 	
 	// Log request details for debugging
-	log.Printf("🔍 Claude API Request: model=%s, messages=%d, tokens=%d, temp=%.2f", 
+	logger.Debugf("🔍 Claude API Request: model=%s, messages=%d, tokens=%d, temp=%.2f", 
 		req.Model, len(req.Messages), req.MaxTokens, req.Temperature)
 	
 	// Log system prompt
@@ -274,23 +289,13 @@ func (c *AnthropicClient) SendWithoutTools(messages []Message, systemPrompt stri
 		log.Printf("  Message %d [%s]: %s", i+1, msg.Role, preview)
 	}
 	
-	// Retry logic with exponential backoff
-	maxRetries := 3
-	baseDelay := 2 * time.Second
-	
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 2s, 4s, 8s
-			delay := baseDelay * time.Duration(1<<(attempt-1))
-			log.Printf("Retrying Claude API after %v (attempt %d/%d)", delay, attempt+1, maxRetries)
-			time.Sleep(delay)
-		}
-		
+	var result *AnthropicResponse
+	err = c.retry.Do(ctx, "Claude", func(ctx context.Context) error {
 		startTime := time.Now()
 		
-		httpReq, err := http.NewRequest("POST", c.apiURL, bytes.NewBuffer(jsonData))
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.apiURL, bytes.NewBuffer(jsonData))
 		if err != nil {
-			return nil, err
+			return err
 		}
 		
 		httpReq.Header.Set("Content-Type", "application/json")
@@ -301,12 +306,12 @@ func (c *AnthropicClient) SendWithoutTools(messages []Message, systemPrompt stri
 		elapsed := time.Since(startTime)
 		
 		if err != nil {
-			// Network error - retry
+			// Network error - retry unless our deadline ran out
 			log.Printf("❌ Network error after %v: %v", elapsed, err)
-			if attempt < maxRetries-1 {
-				continue
+			if ctx.Err() == nil {
+				return retryable(err, nil)
 			}
-			return nil, err
+			return err
 		}
 		defer resp.Body.Close()
 		
@@ -314,34 +319,49 @@ func (c *AnthropicClient) SendWithoutTools(messages []Message, systemPrompt stri
 		
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		
 		var anthropicResp AnthropicResponse
 		if err := json.Unmarshal(body, &anthropicResp); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %v", err)
+			if retryableStatus(resp.StatusCode) {
+				// Gateways can return non-JSON error pages
+				return retryable(fmt.Errorf("API error: status %d", resp.StatusCode), resp)
+			}
+			return fmt.Errorf("failed to parse response: %v", err)
 		}
 		
 		if anthropicResp.Error != nil {
-			// Check if it's a rate limit error (429) or server error (5xx)
-			if resp.StatusCode == 429 || resp.StatusCode >= 500 {
-				if attempt < maxRetries-1 {
-					log.Printf("API error %d (will retry): %s", resp.StatusCode, anthropicResp.Error.Message)
-					continue
-				}
+			apiErr := fmt.Errorf("API error: %s - %s", anthropicResp.Error.Type, anthropicResp.Error.Message)
+			// Rate limit errors (429) and server errors (5xx) are retried
+			if retryableStatus(resp.StatusCode) {
+				return retryable(apiErr, resp)
 			}
-			return nil, fmt.Errorf("API error: %s - %s", anthropicResp.Error.Type, anthropicResp.Error.Message)
+			return apiErr
 		}
 		
 		// Success!
-		return &anthropicResp, nil
+		result = &anthropicResp
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	
-	return nil, fmt.Errorf("failed after %d retries", maxRetries)
+	return result, nil
 }
 
 // Send a message to Claude with retry logic
 func (c *AnthropicClient) Send(messages []Message, systemPrompt string, agentName string) (*AnthropicResponse, error) {
+	return c.send(context.Background(), messages, systemPrompt, agentName, nil)
+}
+
+// SendStream is Send with a streamed response: onText receives each text
+// delta as it arrives, and the assembled response is returned at the end
+func (c *AnthropicClient) SendStream(messages []Message, systemPrompt string, agentName string, onText func(string)) (*AnthropicResponse, error) {
+	return c.send(context.Background(), messages, systemPrompt, agentName, onText)
+}
+
+func (c *AnthropicClient) send(ctx context.Context, messages []Message, systemPrompt string, agentName string, onText func(string)) (*AnthropicResponse, error) {
 	// Get model configuration for this agent
 	modelDef, err := GetModelForAgent(agentName)
 	if err != nil {
@@ -401,11 +421,11 @@ func (c *AnthropicClient) Send(messages []Message, systemPrompt string, agentNam
 	// Get agent config
 	cleanName := strings.TrimPrefix(agentName, "@ai-")
 	cleanName = strings.TrimPrefix(cleanName, "@")
-	log.Printf("🔍 Checking tools for agent: %s (clean: %s), config exists: %v", agentName, cleanName, agentConfig != nil)
+	logger.Debugf("🔍 Checking tools for agent: %s (clean: %s), config exists: %v", agentName, cleanName, agentConfig != nil)
 	
 	if agentConfig != nil {
 		if _, exists := agentConfig.Agents[cleanName]; exists {
-			log.Printf("🔍 Agent %s found", cleanName)
+			logger.Debugf("🔍 Agent %s found", cleanName)
 			// All agents get the same tools - the guidance controls what they do with them
 			tools = []AnthropicTool{
 				getCommandRunnerTool(),
@@ -420,7 +440,7 @@ func (c *AnthropicClient) Send(messages []Message, systemPrompt string, agentNam
 	}
 	
 	// Debug log the model
-	log.Printf("🔍 Using model for agent %s: ID=%s, Name=%s, Temp=%.2f", 
+	logger.Debugf("🔍 Using model for agent %s: ID=%s, Name=%s, Temp=%.2f", 
 		agentName, modelDef.ID, modelDef.Name, modelDef.Temperature)
 	
 	req := AnthropicRequest{
@@ -428,7 +448,7 @@ func (c *AnthropicClient) Send(messages []Message, systemPrompt string, agentNam
 		System:      systemPrompt,
 		Messages:    anthropicMessages,
 		MaxTokens:   responseConfig.MaxTokens,
-		Stream:      responseConfig.Stream,
+		Stream:      responseConfig.Stream || onText != nil,
 		Temperature: modelDef.Temperature,
 		Tools:       tools,
 	}
@@ -446,7 +466,7 @@ func (c *AnthropicClient) Send(messages []Message, systemPrompt string, agentNam
 	}
 	
 	// Log request details for debugging
-	log.Printf("🔍 Claude API Request: model=%s, messages=%d, tokens=%d, temp=%.2f", 
+	logger.Debugf("🔍 Claude API Request: model=%s, messages=%d, tokens=%d, temp=%.2f", 
 		req.Model, len(req.Messages), req.MaxTokens, req.Temperature)
 	
 	// Log system prompt
@@ -480,23 +500,13 @@ func (c *AnthropicClient) Send(messages []Message, systemPrompt string, agentNam
 		log.Printf("  Message %d [%s]: %s", i+1, msg.Role, preview)
 	}
 	
-	// Retry logic with exponential backoff
-	maxRetries := 3
-	baseDelay := 2 * time.Second
-	
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 2s, 4s, 8s
-			delay := baseDelay * time.Duration(1<<(attempt-1))
-			log.Printf("Retrying Claude API after %v (attempt %d/%d)", delay, attempt+1, maxRetries)
-			time.Sleep(delay)
-		}
-		
+	var result *AnthropicResponse
+	err = c.retry.Do(ctx, "Claude", func(ctx context.Context) error {
 		startTime := time.Now()
 		
-		httpReq, err := http.NewRequest("POST", c.apiURL, bytes.NewBuffer(jsonData))
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.apiURL, bytes.NewBuffer(jsonData))
 		if err != nil {
-			return nil, err
+			return err
 		}
 		
 		httpReq.Header.Set("Content-Type", "application/json")
@@ -507,43 +517,63 @@ func (c *AnthropicClient) Send(messages []Message, systemPrompt string, agentNam
 		elapsed := time.Since(startTime)
 		
 		if err != nil {
-			// Network error - retry
+			// Network error - retry unless our deadline ran out
 			log.Printf("❌ Network error after %v: %v", elapsed, err)
-			if attempt < maxRetries-1 {
-				continue
+			if ctx.Err() == nil {
+				return retryable(err, nil)
 			}
-			return nil, err
+			return err
 		}
 		defer resp.Body.Close()
 		
 		log.Printf("✅ Claude API responded in %v with status %d", elapsed, resp.StatusCode)
 		
+		// Streamed responses are server-sent events; errors before the
+		// stream starts still come back as a plain JSON body
+		if onText != nil && resp.StatusCode == http.StatusOK {
+			streamed, emitted, err := readAnthropicStream(resp.Body, onText)
+			if err != nil {
+				if !emitted {
+					// Nothing reached the client yet, so a retry is invisible
+					return retryable(err, nil)
+				}
+				return err
+			}
+			result = streamed
+			return nil
+		}
+		
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		
 		var anthropicResp AnthropicResponse
 		if err := json.Unmarshal(body, &anthropicResp); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %v", err)
+			if retryableStatus(resp.StatusCode) {
+				// Gateways can return non-JSON error pages
+				return retryable(fmt.Errorf("API error: status %d", resp.StatusCode), resp)
+			}
+			return fmt.Errorf("failed to parse response: %v", err)
 		}
 		
 		if anthropicResp.Error != nil {
-			// Check if it's a rate limit error (429) or server error (5xx)
-			if resp.StatusCode == 429 || resp.StatusCode >= 500 {
-				if attempt < maxRetries-1 {
-					log.Printf("API error %d (will retry): %s", resp.StatusCode, anthropicResp.Error.Message)
-					continue
-				}
+			apiErr := fmt.Errorf("API error: %s - %s", anthropicResp.Error.Type, anthropicResp.Error.Message)
+			// Rate limit errors (429) and server errors (5xx) are retried
+			if retryableStatus(resp.StatusCode) {
+				return retryable(apiErr, resp)
 			}
-			return nil, fmt.Errorf("API error: %s - %s", anthropicResp.Error.Type, anthropicResp.Error.Message)
+			return apiErr
 		}
 		
 		// Success!
-		return &anthropicResp, nil
+		result = &anthropicResp
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	
-	return nil, fmt.Errorf("failed after %d retries", maxRetries)
+	return result, nil
 }
 
 // Enhanced swim handler with real AI
@@ -629,15 +659,30 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 		}
 	}
 	
-	// Get or create session - use session_id from payload if provided, otherwise use request ID
+	// Get or create session - use session_id from payload if provided, the
+	// agent's last session when resuming, otherwise the request ID
 	sessionID := req.ID
+	resumed := false
 	if payload.SessionID != "" {
 		sessionID = payload.SessionID
+	} else if payload.Resume {
+		if lastID, ok := d.lastSessionFor(payload.Agent); ok {
+			sessionID = lastID
+			resumed = true
+			log.Printf("⏯️ Resuming %s's last session %s", payload.Agent, sessionID)
+		}
 	}
-	session := d.getOrCreateSession(sessionID, payload.Agent)
-	if session == nil {
-		log.Printf("❌ getOrCreateSession returned nil!")
-		resp.SetError("Failed to create or load session")
+	// Asking to resume is asking to continue, even a session that has ended
+	session, err := d.getOrCreateSession(sessionID, payload.Agent, payload.Reopen || resumed)
+	if err != nil {
+		log.Printf("❌ Failed to create or load session %s: %v", sessionID, err)
+		if errors.Is(err, ErrSessionLimit) {
+			resp.SetError(fmt.Sprintf("SESSION_LIMIT_ERROR: %v", err))
+		} else if errors.Is(err, ErrSessionClosed) {
+			resp.SetError(err.Error())
+		} else {
+			resp.SetError(fmt.Sprintf("Failed to create or load session: %v", err))
+		}
 		return resp
 	}
 	
@@ -648,7 +693,7 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 		d.contextCollector.TrackMemoryAccess(memoryPath, "created")
 	}
 	
-	log.Printf("🔍 Session loaded: ID=%s, MessageCount=%d", session.ID, len(session.Messages))
+	logger.Debugf("🔍 Session loaded: ID=%s, MessageCount=%d", session.ID, len(session.Messages))
 	
 	// Add user message to session
 	session.mu.Lock()
@@ -663,8 +708,10 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 	agentPrompt := getAgentPrompt(payload.Agent)
 	
 	// Process references using common reference handler
+	var contextTruncations []resolution.TruncationRecord
 	if len(req.References) > 0 && d.referenceHandler != nil {
-		result := d.referenceHandler.ResolveReferences(req.References, "swim")
+		result := d.referenceHandler.ResolveReferences(req.References, "swim", len(payload.Message))
+		contextTruncations = result.Truncations
 		if result.Success {
 			// Inject resolved references into system prompt
 			referenceSection := d.referenceHandler.FormatForSwim(result.ResolvedText)
@@ -694,15 +741,15 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 	session.mu.Unlock()
 	
 	// Save session after user message
-	log.Printf("🔍 Swim handler: memoryStore != nil: %v", d.storage != nil)
+	logger.Debugf("🔍 Swim handler: memoryStore != nil: %v", d.storage != nil)
 	if d.storage != nil {
-		log.Printf("🔍 [SWIM] Saving session after user message (messages=%d)", len(session.Messages))
-		go d.storage.SaveSession(session)
+		logger.Debugf("🔍 [SWIM] Saving session after user message (messages=%d)", len(session.Messages))
+		d.storage.QueueSessionSave(session)
 	}
 	
 	// Call Claude
 	aiClient := NewAnthropicClient()
-	log.Printf("🔍 AI client created, has API key: %v", aiClient.apiKey != "")
+	logger.Debugf("🔍 AI client created, has API key: %v", aiClient.apiKey != "")
 	
 	if aiClient.apiKey == "" {
 		// No API key - return error
@@ -713,14 +760,46 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 	
 	log.Printf("🤖 Using REAL AI handler with Claude")
 	
-	log.Printf("🔍 Sending to AI with %d messages in context", len(messages))
-	aiResp, err := aiClient.Send(messages, agentPrompt, payload.Agent)
+	// Generation runs until done, a cancel request names it, or the
+	// streaming client goes away
+	ctx, done := d.generations.start(req.ID, session.ID)
+	defer done()
+	if req.gone != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-req.gone:
+				log.Printf("🛑 Client for request %s went away, stopping generation", req.ID)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	
+	// Streaming requests forward text deltas to the client as chunk frames
+	var onText func(string)
+	if payload.Stream && req.emit != nil {
+		onText = req.emit
+	}
+	send := func(messages []Message, systemPrompt string, agentName string) (*AnthropicResponse, error) {
+		return aiClient.send(ctx, messages, systemPrompt, agentName, onText)
+	}
+	
+	logger.Debugf("🔍 Sending to AI with %d messages in context", len(messages))
+	aiResp, err := send(messages, agentPrompt, payload.Agent)
 	if err != nil {
 		log.Printf("AI error: %v", err)
 		
 		// Classify error by source for better user messaging
 		errorMsg := err.Error()
-		if strings.Contains(errorMsg, "api_error") || strings.Contains(errorMsg, "Overloaded") || strings.Contains(errorMsg, "rate_limit") {
+		if ctx.Err() != nil {
+			log.Printf("🛑 Generation for request %s cancelled", req.ID)
+			resp.SetError("CANCELLED: generation was cancelled")
+		} else if errors.Is(err, ErrAITimeout) {
+			resp.SetError(fmt.Sprintf("TIMEOUT: %v", err))
+		} else if strings.Contains(errorMsg, "api_error") || strings.Contains(errorMsg, "Overloaded") || strings.Contains(errorMsg, "rate_limit") {
 			resp.SetError(fmt.Sprintf("CLAUDE_API_ERROR: %v", err))
 		} else if strings.Contains(errorMsg, "ANTHROPIC_API_KEY") || strings.Contains(errorMsg, "authentication") || strings.Contains(errorMsg, "invalid_api_key") {
 			resp.SetError(fmt.Sprintf("API_KEY_ERROR: %v", err))
@@ -731,7 +810,7 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 		}
 		return resp
 	}
-	log.Printf("🔍 Got AI response")
+	logger.Debugf("🔍 Got AI response")
 	
 	// Extract response text and check for tool calls
 	var responseText string
@@ -739,17 +818,17 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 	var toolResults []map[string]interface{} // Track tool results for continuation
 	
 	// Log the full AI response structure for debugging
-	log.Printf("🔍 [DEBUG] AI Response Content Array Length: %d", len(aiResp.Content))
+	logger.Debugf("🔍 [DEBUG] AI Response Content Array Length: %d", len(aiResp.Content))
 	for i, content := range aiResp.Content {
-		log.Printf("🔍 [DEBUG] Content[%d] Type: %s, Name: %s, ID: %s", i, content.Type, content.Name, content.ID)
+		logger.Debugf("🔍 [DEBUG] Content[%d] Type: %s, Name: %s, ID: %s", i, content.Type, content.Name, content.ID)
 		if content.Type == "text" {
-			log.Printf("🔍 [DEBUG] Text content length: %d chars", len(content.Text))
+			logger.Debugf("🔍 [DEBUG] Text content length: %d chars", len(content.Text))
 			// Log first 200 chars of text
 			preview := content.Text
 			if len(preview) > 200 {
 				preview = preview[:200] + "..."
 			}
-			log.Printf("🔍 [DEBUG] Text preview: %s", preview)
+			logger.Debugf("🔍 [DEBUG] Text preview: %s", preview)
 		}
 	}
 	
@@ -769,7 +848,7 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 				log.Printf("🏃 AI is executing a Port 42 command")
 				var toolOutput string
 				var toolError error
-				if output, err := executeCommand(content.Input, session.ID); err != nil {
+				if output, err := d.executeCommand(content.Input, session.ID); err != nil {
 					// Check if this is an approval needed error
 					if approvalErr, ok := err.(*ApprovalNeededError); ok {
 						// Need to return approval request to CLI
@@ -831,7 +910,7 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 					responseText += "\n\n"
 				}
 				responseText += content.Text
-				log.Printf("🔍 [DEBUG] Accumulated responseText length: %d chars", len(responseText))
+				logger.Debugf("🔍 [DEBUG] Accumulated responseText length: %d chars", len(responseText))
 			}
 		}
 		
@@ -889,8 +968,15 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 		
 		// Send continuation request using the same client and agent
 		log.Printf("🔄 [CONTINUATION] Sending continuation with %d messages", len(continuationMessages))
-		continuationResp, err := aiClient.Send(continuationMessages, agentPrompt, payload.Agent)
-		if err != nil {
+		if payload.Stream && req.emit != nil {
+			req.emit("\n\n") // Same spacing the final message puts before the continuation
+		}
+		continuationResp, err := send(continuationMessages, agentPrompt, payload.Agent)
+		if err != nil && ctx.Err() != nil {
+			log.Printf("🛑 Generation for request %s cancelled during continuation", req.ID)
+			resp.SetError("CANCELLED: generation was cancelled")
+			return resp
+		} else if err != nil {
 			log.Printf("❌ [CONTINUATION] Failed to get continuation: %v", err)
 		} else {
 			log.Printf("✅ [CONTINUATION] Got continuation response")
@@ -901,7 +987,7 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 				for _, content := range continuationResp.Content {
 					if content.Type == "text" {
 						responseText += content.Text
-						log.Printf("🔍 [CONTINUATION] Added %d chars of continuation text", len(content.Text))
+						logger.Debugf("🔍 [CONTINUATION] Added %d chars of continuation text", len(content.Text))
 					}
 					// We could handle more tool uses here, but let's limit to one round for now
 				}
@@ -910,11 +996,11 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 	}
 	
 	// Final response check
-	log.Printf("🔍 [DEBUG] Final responseText length: %d chars", len(responseText))
+	logger.Debugf("🔍 [DEBUG] Final responseText length: %d chars", len(responseText))
 	if len(responseText) > 500 {
-		log.Printf("🔍 [DEBUG] Final response preview (first 500 chars): %s", responseText[:500])
+		logger.Debugf("🔍 [DEBUG] Final response preview (first 500 chars): %s", responseText[:500])
 	} else {
-		log.Printf("🔍 [DEBUG] Final response: %s", responseText)
+		logger.Debugf("🔍 [DEBUG] Final response: %s", responseText)
 	}
 	
 	// Add AI response to session
@@ -947,11 +1033,11 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 	session.mu.Unlock()
 	
 	// Save session after AI response
-	log.Printf("🔍 After AI response: memoryStore != nil: %v", d.storage != nil)
+	logger.Debugf("🔍 After AI response: memoryStore != nil: %v", d.storage != nil)
 	if d.storage != nil {
-		log.Printf("🔍 [SWIM] Saving session after AI response (messages=%d, command=%v)", 
+		logger.Debugf("🔍 [SWIM] Saving session after AI response (messages=%d, command=%v)", 
 			len(session.Messages), session.CommandGenerated != nil)
-		go d.storage.SaveSession(session)
+		d.storage.QueueSessionSave(session)
 	}
 	
 	// Prepare response
@@ -980,9 +1066,17 @@ func (d *Daemon) handleSwimWithAI(req Request) Response {
 		data["artifact_generated"] = true
 	}
 	
+	if payload.Resume && payload.SessionID == "" {
+		data["resumed"] = resumed
+	}
+	
+	if len(contextTruncations) > 0 {
+		data["context_truncations"] = contextTruncations
+	}
+	
 	// Debug: Log response size
 	if jsonBytes, err := json.Marshal(data); err == nil {
-		log.Printf("🔍 Swim response size: %d bytes", len(jsonBytes))
+		logger.Debugf("🔍 Swim response size: %d bytes", len(jsonBytes))
 		if len(jsonBytes) > 10000 {
 			log.Printf("⚠️  Large swim response detected! Keys: %v", getMapKeys(data))
 		}
@@ -1099,6 +1193,11 @@ func getArtifactGenerationTool() AnthropicTool {
 					"type":        "string",
 					"description": "For single file artifacts: the file content",
 				},
+				"encoding": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"utf8", "base64"},
+					"description": "How file contents are written: utf8 text (default), or base64 for binary files such as PNG images or audio",
+				},
 				"metadata": map[string]interface{}{
 					"type":        "object",
 					"description": "Additional metadata",
@@ -1215,10 +1314,11 @@ func extractArtifactSpecFromToolCall(toolInput json.RawMessage) (*ArtifactSpec,
 	return &spec, nil
 }
 
-// executeCommand safely executes a Port 42 command
-func executeCommand(input json.RawMessage, sessionID string) (string, error) {
+// executeCommand safely executes a Port 42 command, under the tool's
+// sandbox profile when it has one
+func (d *Daemon) executeCommand(input json.RawMessage, sessionID string) (string, error) {
 	// DEBUG: Log the raw JSON input to see what Claude is sending
-	log.Printf("🔍 [DEBUG] executeCommand received JSON: %s", string(input))
+	logger.Debugf("🔍 [DEBUG] executeCommand received JSON: %s", string(input))
 	
 	var params struct {
 		Command string   `json:"command"`
@@ -1227,12 +1327,12 @@ func executeCommand(input json.RawMessage, sessionID string) (string, error) {
 	}
 	
 	if err := json.Unmarshal(input, &params); err != nil {
-		log.Printf("❌ [DEBUG] JSON unmarshal failed. Expected: {\"command\":\"string\",\"args\":[\"array\"],\"stdin\":\"string\"}")
-		log.Printf("❌ [DEBUG] Received JSON: %s", string(input))
+		logger.Errorf("❌ [DEBUG] JSON unmarshal failed. Expected: {\"command\":\"string\",\"args\":[\"array\"],\"stdin\":\"string\"}")
+		logger.Errorf("❌ [DEBUG] Received JSON: %s", string(input))
 		return "", fmt.Errorf("invalid parameters: %v", err)
 	}
 	
-	log.Printf("✅ [DEBUG] Successfully parsed command: %s, args: %v", params.Command, params.Args)
+	logger.Debugf("✅ [DEBUG] Successfully parsed command: %s, args: %v", params.Command, params.Args)
 	
 	// Special case: Allow Claude to call port42 CLI directly
 	if params.Command == "port42" {
@@ -1259,6 +1359,7 @@ func executeCommand(input json.RawMessage, sessionID string) (string, error) {
 	}
 	
 	var cmdPath string
+	profile := SandboxNone
 	
 	// Check if it's an allowed system command
 	if allowedSystemCommands[params.Command] {
@@ -1287,6 +1388,11 @@ func executeCommand(input json.RawMessage, sessionID string) (string, error) {
 		if _, err := os.Stat(cmdPath); err != nil {
 			return "", fmt.Errorf("command not found: %s", params.Command)
 		}
+		if d.storage != nil {
+			if tool, err := d.storage.findToolRelation(params.Command); err == nil {
+				profile = toolSandboxProfile(*tool)
+			}
+		}
 	}
 	
 	// Create command with timeout
@@ -1307,10 +1413,15 @@ func executeCommand(input json.RawMessage, sessionID string) (string, error) {
 		}
 	}
 	
-	cmd := exec.CommandContext(ctx, cmdPath, expandedArgs...)
-	
-	// Set up environment with proper PATH
-	cmd.Env = os.Environ() // Inherit current environment including PATH
+	// Unsandboxed commands inherit the current environment including PATH
+	cmd, cleanup, err := d.config.Sandbox.sandboxCommand(ctx, profile, cmdPath, expandedArgs)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	if profile != SandboxNone {
+		log.Printf("🔒 Running %s under the %s sandbox profile", params.Command, profile)
+	}
 	
 	// Set up stdin if provided
 	if params.Stdin != "" {
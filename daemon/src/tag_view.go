@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// /by-tag/ groups objects by their metadata tags. An object appears once
+// under each tag it carries. Like /by-lifecycle it is computed from the
+// search index on every listing, since tags change (batch add-tags) without
+// the object's paths being rewritten.
+
+// tagDirName is a tag as a directory name: lowercased, with slashes replaced
+// so a tag can't nest
+func tagDirName(tag string) string {
+	return strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, "/", "-")))
+}
+
+// objectTags returns an object's distinct tag directory names
+func objectTags(meta *Metadata) []string {
+	seen := make(map[string]bool, len(meta.Tags))
+	var tags []string
+	for _, tag := range meta.Tags {
+		if name := tagDirName(tag); name != "" && !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+// tagObjects maps entry names to the objects carrying one tag, named and
+// disambiguated the same way as lifecycle entries
+func (s *Storage) tagObjects(tag string) map[string]*Metadata {
+	docs, _ := s.searchIndex.snapshot("", "")
+
+	byName := make(map[string][]*Metadata)
+	for _, meta := range docs {
+		for _, name := range objectTags(meta) {
+			if name == tag {
+				entryName := lifecycleEntryName(meta)
+				byName[entryName] = append(byName[entryName], meta)
+				break
+			}
+		}
+	}
+
+	objects := make(map[string]*Metadata)
+	for name, metas := range byName {
+		if len(metas) == 1 {
+			objects[name] = metas[0]
+			continue
+		}
+		for _, meta := range metas {
+			objects[name+"-"+meta.ID[:min(8, len(meta.ID))]] = meta
+		}
+	}
+	return objects
+}
+
+// handleByTagView lists the tags in use at /by-tag/ and the objects carrying
+// a tag at /by-tag/{tag}/
+func (s *Storage) handleByTagView(path string) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/by-tag"), "/"), "/")
+
+	if len(parts) == 0 || parts[0] == "" {
+		docs, _ := s.searchIndex.snapshot("", "")
+		counts := make(map[string]int)
+		for _, meta := range docs {
+			for _, tag := range objectTags(meta) {
+				counts[tag]++
+			}
+		}
+		tags := make([]string, 0, len(counts))
+		for tag := range counts {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			entries = append(entries, map[string]interface{}{
+				"name":  tag,
+				"type":  "directory",
+				"count": counts[tag],
+			})
+		}
+		return entries
+	}
+
+	if len(parts) > 1 {
+		return entries // Entries are files; there is nothing below them
+	}
+
+	objects := s.tagObjects(tagDirName(parts[0]))
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		meta := objects[name]
+		entry := map[string]interface{}{
+			"name":     name,
+			"type":     "file",
+			"id":       meta.ID,
+			"size":     meta.Size,
+			"created":  meta.Created,
+			"modified": meta.Modified,
+			"tags":     cloneStrings(meta.Tags),
+			"paths":    cloneStrings(meta.Paths),
+		}
+		if meta.Type != "" {
+			entry["content_type"] = meta.Type
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// resolveByTagPath returns the object behind /by-tag/{tag}/{name}
+func (s *Storage) resolveByTagPath(path string) string {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/by-tag"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+	if meta, ok := s.tagObjects(tagDirName(parts[0]))[parts[1]]; ok {
+		return meta.ID
+	}
+	return ""
+}
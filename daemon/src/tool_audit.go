@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Tool health properties recorded on Tool relations by validation and
+// execution tracking
+const (
+	PropValidated     = "validated"       // bool: definition/code passed validation
+	PropLastRun       = "last_run"        // RFC3339 time of the most recent execution
+	PropLastRunStatus = "last_run_status" // "success" or "failed"
+	PropUsageCount    = "usage_count"     // number of recorded executions
+)
+
+// ToolAuditEntry describes a tool flagged by the health audit
+type ToolAuditEntry struct {
+	Name          string    `json:"name"`
+	RelationID    string    `json:"relation_id"`
+	Created       time.Time `json:"created"`
+	Reason        string    `json:"reason"` // "never_checked" or "last_run_failed"
+	Validated     bool      `json:"validated"`
+	UsageCount    int       `json:"usage_count"`
+	LastRun       string    `json:"last_run,omitempty"`
+	LastRunStatus string    `json:"last_run_status,omitempty"`
+}
+
+// AuditTools lists tools that were never validated or run, and tools whose
+// last run failed, oldest first
+func (s *Storage) AuditTools() (map[string]interface{}, error) {
+	if s.relationStore == nil {
+		return nil, fmt.Errorf("relation store not available")
+	}
+
+	tools, err := s.relationStore.LoadByType("Tool")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tools: %w", err)
+	}
+
+	// Only audit if some tool actually carries health metadata, otherwise
+	// every tool would look unchecked simply because tracking is missing
+	trackingAvailable := false
+	for _, tool := range tools {
+		for _, key := range []string{PropValidated, PropLastRun, PropLastRunStatus, PropUsageCount} {
+			if _, exists := tool.Properties[key]; exists {
+				trackingAvailable = true
+				break
+			}
+		}
+		if trackingAvailable {
+			break
+		}
+	}
+
+	if !trackingAvailable {
+		log.Printf("🩺 [AUDIT] No validation or run metadata on %d tools, skipping audit", len(tools))
+		return map[string]interface{}{
+			"tracking_available": false,
+			"total_tools":        len(tools),
+			"never_checked":      []ToolAuditEntry{},
+			"last_run_failed":    []ToolAuditEntry{},
+			"message":            "No tools have validation or run metadata yet",
+		}, nil
+	}
+
+	neverChecked := []ToolAuditEntry{}
+	lastRunFailed := []ToolAuditEntry{}
+
+	for _, tool := range tools {
+		entry := ToolAuditEntry{
+			Name:          getRelationName(tool),
+			RelationID:    tool.ID,
+			Created:       tool.CreatedAt,
+			LastRun:       getStringProperty(tool.Properties, PropLastRun),
+			LastRunStatus: getStringProperty(tool.Properties, PropLastRunStatus),
+		}
+		if validated, ok := tool.Properties[PropValidated].(bool); ok {
+			entry.Validated = validated
+		}
+		entry.UsageCount = toolUsageCount(tool)
+
+		switch {
+		case entry.LastRunStatus == "failed":
+			entry.Reason = "last_run_failed"
+			lastRunFailed = append(lastRunFailed, entry)
+		case !entry.Validated && entry.LastRun == "" && entry.UsageCount == 0:
+			entry.Reason = "never_checked"
+			neverChecked = append(neverChecked, entry)
+		}
+	}
+
+	// Oldest unchecked tools surface first
+	byCreated := func(entries []ToolAuditEntry) {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Created.Before(entries[j].Created)
+		})
+	}
+	byCreated(neverChecked)
+	byCreated(lastRunFailed)
+
+	log.Printf("🩺 [AUDIT] %d tools: %d never checked, %d last run failed",
+		len(tools), len(neverChecked), len(lastRunFailed))
+
+	return map[string]interface{}{
+		"tracking_available": true,
+		"total_tools":        len(tools),
+		"never_checked":      neverChecked,
+		"last_run_failed":    lastRunFailed,
+	}, nil
+}
+
+// toolUsageCount reads PropUsageCount, which is an int when set in memory
+// and a float64 once the relation has been through JSON
+func toolUsageCount(tool Relation) int {
+	switch count := tool.Properties[PropUsageCount].(type) {
+	case float64:
+		return int(count)
+	case int:
+		return count
+	}
+	return 0
+}
+
+// runLogMaxSize rotates runs.jsonl to runs.jsonl.1 once it grows past this
+const runLogMaxSize = 1024 * 1024
+
+// ToolRun is one line of the execution run log (~/.port42/runs.jsonl)
+type ToolRun struct {
+	Tool       string    `json:"tool"`
+	Status     string    `json:"status"` // "success" or "failed"
+	ExitCode   int       `json:"exit_code"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// RecordExecution counts a run of a generated command: it increments the
+// usage count and access time on the command's object and Tool relation and
+// appends to the run log. The object's count is updated under its metadata
+// lock and the relation's through Update, so concurrent executions never
+// lose an increment to a read-modify-write race.
+func (s *Storage) RecordExecution(run ToolRun) (map[string]interface{}, error) {
+	if run.Tool == "" {
+		return nil, fmt.Errorf("tool name is required")
+	}
+	if run.Status != "success" && run.Status != "failed" {
+		return nil, fmt.Errorf("invalid status %q, expected success or failed", run.Status)
+	}
+	if run.Time.IsZero() {
+		run.Time = time.Now()
+	}
+
+	objID := s.ResolvePath("/commands/" + run.Tool)
+
+	result := map[string]interface{}{
+		"tool":   run.Tool,
+		"status": run.Status,
+	}
+	found := false
+
+	// The command's object metadata
+	if objID != "" {
+		count, err := s.countExecution(objID, run.Time)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			result["object_id"] = objID
+			result["usage_count"] = count
+			found = true
+		}
+	}
+
+	// The Tool relation, which carries the health properties
+	if s.relationStore != nil {
+		if tools, err := s.relationStore.LoadByType("Tool"); err == nil {
+			for _, tool := range tools {
+				if getRelationName(tool) != run.Tool {
+					continue
+				}
+				// Counted on the freshly loaded relation so a concurrent
+				// declare or version update isn't overwritten
+				var count int
+				err := s.relationStore.Update(tool.ID, func(current *Relation) error {
+					if current.Properties == nil {
+						current.Properties = make(map[string]interface{})
+					}
+					count = toolUsageCount(*current)
+					current.Properties[PropUsageCount] = count + 1
+					current.Properties[PropLastRun] = run.Time.Format(time.RFC3339)
+					current.Properties[PropLastRunStatus] = run.Status
+					return nil
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to update tool relation: %w", err)
+				}
+				result["relation_id"] = tool.ID
+				if _, ok := result["usage_count"]; !ok {
+					result["usage_count"] = count + 1
+				}
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("tool not found: %s", run.Tool)
+	}
+
+	if err := s.appendRunLog(run); err != nil {
+		log.Printf("⚠️ [AUDIT] Failed to append run log: %v", err)
+	}
+
+	log.Printf("▶️ [AUDIT] Recorded %s run of %s (usage %v)", run.Status, run.Tool, result["usage_count"])
+	return result, nil
+}
+
+// countExecution increments an object's usage count and sets its access
+// time, returning the new count, or 0 if the object has no metadata
+func (s *Storage) countExecution(objID string, at time.Time) (int, error) {
+	unlock := s.metadataLocks.Lock(objID)
+	defer unlock()
+
+	meta, err := s.readMetadataFile(objID)
+	if err != nil {
+		return 0, nil
+	}
+	meta.UsageCount++
+	meta.Accessed = at
+	s.accessMu.Lock()
+	delete(s.pendingAccess, objID) // Superseded by this write
+	s.accessMu.Unlock()
+	if err := s.writeMetadata(meta); err != nil {
+		return 0, fmt.Errorf("failed to update metadata: %w", err)
+	}
+	return meta.UsageCount, nil
+}
+
+// readMetadataFile reads an object's metadata straight from disk, without
+// the pending access overlay LoadMetadata applies
+func (s *Storage) readMetadataFile(id string) (*Metadata, error) {
+	data, err := os.ReadFile(filepath.Join(s.metadataDir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var meta Metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata for %s: %w", id, err)
+	}
+	return &meta, nil
+}
+
+// appendRunLog appends a run to runs.jsonl, rotating it when it gets large
+func (s *Storage) appendRunLog(run ToolRun) error {
+	s.runLogMu.Lock()
+	defer s.runLogMu.Unlock()
+
+	path := filepath.Join(s.baseDir, "runs.jsonl")
+	if info, err := os.Stat(path); err == nil && info.Size() > runLogMaxSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -12,16 +13,16 @@ import (
 
 // ToolMaterializer implements Materializer for Tool relations
 type ToolMaterializer struct {
-	aiClient         *AnthropicClient
+	provider         AIProvider
 	storage          *Storage // Use existing storage system
 	matStore         MaterializationStore
 	contextCollector *ContextCollector
 }
 
 // NewToolMaterializer creates a new tool materializer
-func NewToolMaterializer(aiClient *AnthropicClient, storage *Storage, matStore MaterializationStore, contextCollector *ContextCollector) (*ToolMaterializer, error) {
+func NewToolMaterializer(provider AIProvider, storage *Storage, matStore MaterializationStore, contextCollector *ContextCollector) (*ToolMaterializer, error) {
 	return &ToolMaterializer{
-		aiClient:         aiClient,
+		provider:         provider,
 		storage:          storage,
 		matStore:         matStore,
 		contextCollector: contextCollector,
@@ -44,25 +45,38 @@ func (tm *ToolMaterializer) Materialize(relation Relation) (*MaterializedEntity,
 	}
 	
 	// Get transforms (optional)
-	transforms := []string{}
-	if transformsRaw, exists := relation.Properties["transforms"]; exists {
-		if transformsList, ok := transformsRaw.([]interface{}); ok {
-			for _, t := range transformsList {
-				if tStr, ok := t.(string); ok {
-					transforms = append(transforms, tStr)
-				}
-			}
-		}
-	}
+	transforms := getTransforms(relation)
 	
 	log.Printf("🔨 Generating code for tool: %s with transforms: %v", name, transforms)
 	
 	// Generate tool code using AI - this returns a CommandSpec 
 	spec, code, err := tm.generateToolCode(name, transforms, relation.ID, relation)
 	if err != nil {
+		// Keep the rejected responses with the declared relation
+		if failures, ok := relation.Properties["generation_failures"]; ok && tm.storage.relationStore != nil {
+			if saveErr := tm.storage.relationStore.Update(relation.ID, func(stored *Relation) error {
+				if stored.Properties == nil {
+					stored.Properties = make(map[string]interface{})
+				}
+				stored.Properties["generation_failures"] = failures
+				return nil
+			}); saveErr != nil {
+				log.Printf("⚠️ Failed to record generation failures on %s: %v", relation.ID, saveErr)
+			}
+		}
 		return nil, fmt.Errorf("failed to generate tool code: %w", err)
 	}
 	
+	// The spec passed validateToolSpec to get this far; the audit counts
+	// the tool as checked once the final code passes too
+	validated := tm.validateGeneratedCode(code, spec.Language) == nil
+	
+	// Warn about dangerous-looking code before it becomes runnable
+	safetyWarnings := safetyWarningLines(scanToolSafety(code))
+	for _, warning := range safetyWarnings {
+		log.Printf("⚠️ Safety warning for %s: %s", name, warning)
+	}
+	
 	// Store using existing storage system (creates object store + symlink)
 	if err := tm.storage.StoreCommand(spec, code); err != nil {
 		return nil, fmt.Errorf("failed to store tool in object store: %w", err)
@@ -86,19 +100,58 @@ func (tm *ToolMaterializer) Materialize(relation Relation) (*MaterializedEntity,
 	if relation.Properties == nil {
 		relation.Properties = make(map[string]interface{})
 	}
-	relation.Properties["executable_id"] = executableID
-	
-	// Remove legacy executable content if it exists to save memory
-	delete(relation.Properties, "executable")
+	// Regenerating a tool keeps its earlier executables restorable.
+	// existing is the stored relation, or nil if there isn't one.
+	applyExecutable := func(existing *Relation) {
+		if existing != nil {
+			if _, has := relation.Properties[PropVersions]; !has {
+				if versions := toolVersions(*existing); len(versions) > 0 {
+					relation.Properties[PropVersions] = versions
+				} else if previous, _ := existing.Properties["executable_id"].(string); previous != "" {
+					relation.Properties["executable_id"] = previous
+				}
+			}
+		}
+		recordToolVersion(&relation, executableID, getStringProperty(relation.Properties, "session_id"))
+		relation.Properties["language"] = spec.Language
+		relation.Properties[PropValidated] = validated
+		if len(spec.Dependencies) > 0 {
+			relation.Properties["dependencies"] = spec.Dependencies
+		}
+		if len(safetyWarnings) > 0 {
+			relation.Properties["safety_warnings"] = safetyWarnings
+		} else {
+			delete(relation.Properties, "safety_warnings") // From an earlier executable
+		}
+		
+		// Remove legacy executable content if it exists to save memory
+		delete(relation.Properties, "executable")
+	}
 	
-	// Save the updated relation with the object ID
+	// Save the updated relation with the object ID. Merging the version
+	// history happens under the relation's lock so concurrent declares of
+	// the same tool each keep the other's version.
 	relationStore := tm.storage.relationStore
 	if relationStore != nil {
-		if err := relationStore.Save(relation); err != nil {
+		applied := false
+		err := relationStore.Update(relation.ID, func(existing *Relation) error {
+			applyExecutable(existing)
+			applied = true
+			*existing = relation
+			return nil
+		})
+		if err != nil && !applied {
+			// Not stored yet (materialized without a declare)
+			applyExecutable(nil)
+			err = relationStore.Save(relation)
+		}
+		if err != nil {
 			log.Printf("⚠️ Failed to update relation with executable_id: %v", err)
 		} else {
 			log.Printf("✅ Updated relation %s with executable_id: %s", relation.ID, executableID[:12]+"...")
 		}
+	} else {
+		applyExecutable(nil)
 	}
 	
 	// Get the symlink path for the materialized entity
@@ -128,6 +181,52 @@ func (tm *ToolMaterializer) Materialize(relation Relation) (*MaterializedEntity,
 	return entity, nil
 }
 
+// ToolPreview is the tool a declare would install, returned by dry runs
+type ToolPreview struct {
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	Language       string          `json:"language"`
+	Implementation string          `json:"implementation"` // The executable as it would be written, shebang included
+	Dependencies   []string        `json:"dependencies"`
+	Transforms     []string        `json:"transforms"`
+	Paths          []string        `json:"paths"` // Virtual paths and the command symlink the tool would get
+	SafetyWarnings []SafetyWarning `json:"safety_warnings"`
+}
+
+// Preview runs code generation for a tool relation and returns the result
+// without storing the code, creating the symlink or saving the relation
+func (tm *ToolMaterializer) Preview(relation Relation) (*ToolPreview, error) {
+	name, ok := relation.Properties["name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("tool relation missing 'name' property")
+	}
+	transforms := getTransforms(relation)
+	
+	log.Printf("👀 Previewing tool: %s with transforms: %v", name, transforms)
+	spec, code, err := tm.generateToolCode(name, transforms, relation.ID, relation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tool code: %w", err)
+	}
+	
+	homeDir, _ := os.UserHomeDir()
+	return &ToolPreview{
+		Name:           name,
+		Description:    spec.Description,
+		Language:       spec.Language,
+		Implementation: code,
+		Dependencies:   cloneStrings(spec.Dependencies),
+		Transforms:     transforms,
+		SafetyWarnings: scanToolSafety(code),
+		Paths: []string{
+			fmt.Sprintf("/commands/%s", name),
+			fmt.Sprintf("/tools/%s", name),
+			fmt.Sprintf("/by-date/%s/%s", time.Now().Format("2006-01-02"), name),
+			fmt.Sprintf("/by-type/command/%s", name),
+			filepath.Join(homeDir, ".port42", "commands", name),
+		},
+	}, nil
+}
+
 // Dematerialize removes the physical manifestation of a tool
 func (tm *ToolMaterializer) Dematerialize(entity *MaterializedEntity) error {
 	log.Printf("🗑️ Dematerializing tool: %s", entity.PhysicalPath)
@@ -136,6 +235,11 @@ func (tm *ToolMaterializer) Dematerialize(entity *MaterializedEntity) error {
 	if err := os.Remove(entity.PhysicalPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove tool file: %w", err)
 	}
+	// And the command store file the symlink pointed at
+	storePath := filepath.Join(filepath.Dir(entity.PhysicalPath), commandStoreDir, filepath.Base(entity.PhysicalPath))
+	if err := os.Remove(storePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("⚠️ Failed to remove command store file: %v", err)
+	}
 	
 	// Remove materialization info
 	if err := tm.matStore.Delete(entity.RelationID); err != nil {
@@ -147,15 +251,48 @@ func (tm *ToolMaterializer) Dematerialize(entity *MaterializedEntity) error {
 	return nil
 }
 
-// validateLanguageSelection ensures the selected language is supported (B2.4 Error Handling)
-func (tm *ToolMaterializer) validateLanguageSelection(language string) error {
-	supportedLanguages := []string{"bash", "python", "node"}
-	for _, supported := range supportedLanguages {
-		if language == supported {
-			return nil
-		}
+// parseGeneratedSpec extracts a tool spec from a model response and checks
+// it is usable (B2.4 Error Handling)
+func (tm *ToolMaterializer) parseGeneratedSpec(responseText string) (*CommandSpec, error) {
+	if strings.TrimSpace(responseText) == "" {
+		return nil, fmt.Errorf("AI returned empty response")
+	}
+	
+	// Extract tool specification from our new unified AI response format
+	spec, err := tm.extractToolSpecFromResponse(responseText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract tool spec from AI response: %w", err)
+	}
+	
+	// Common aliases for the languages we run
+	switch strings.ToLower(strings.TrimSpace(spec.Language)) {
+	case "sh", "shell", "bash":
+		spec.Language = "bash"
+	case "python3", "py", "python":
+		spec.Language = "python"
+	case "javascript", "js", "nodejs", "node":
+		spec.Language = "node"
+	}
+	
+	if err := validateToolSpec(spec); err != nil {
+		return nil, fmt.Errorf("generated tool spec is invalid: %w", err)
 	}
-	return fmt.Errorf("unsupported language '%s', supported languages: %v", language, supportedLanguages)
+	return spec, nil
+}
+
+// recordGenerationFailure keeps a rejected model response on the relation
+// (under generation_failures) for debugging
+func recordGenerationFailure(relation Relation, attempt int, problem error, responseText string) {
+	if relation.Properties == nil {
+		return
+	}
+	failures, _ := relation.Properties["generation_failures"].([]interface{})
+	relation.Properties["generation_failures"] = append(failures, map[string]interface{}{
+		"attempt":    attempt,
+		"error":      problem.Error(),
+		"raw_output": truncateOutput(responseText),
+		"time":       time.Now().Format(time.RFC3339),
+	})
 }
 
 // validateGeneratedCode performs basic syntax validation for generated code (B2.4 Error Handling)  
@@ -219,46 +356,63 @@ func (tm *ToolMaterializer) generateToolCode(name string, transforms []string, r
 	// Get agent prompt for tool creation (reuse existing logic)
 	agentPrompt := getAgentPrompt("@ai-engineer")
 	
-	// Use SendWithoutTools for pure text generation (we want JSON, not tool execution)
-	response, err := tm.aiClient.SendWithoutTools(messages, agentPrompt, "@ai-engineer")
-	if err != nil {
-		return nil, "", fmt.Errorf("AI code generation failed: %w", err)
-	}
-	
-	// Extract code from response
-	if len(response.Content) == 0 {
-		return nil, "", fmt.Errorf("AI returned empty response")
-	}
-	
-	responseText := response.Content[0].Text
-	if responseText == "" {
-		return nil, "", fmt.Errorf("AI returned empty response")
-	}
-	
-	
-	// Extract tool specification from our new unified AI response format
-	spec, err := tm.extractToolSpecFromResponse(responseText)
-	if err != nil {
-		log.Printf("❌ DEBUG: Failed to extract tool spec. Response was:\n%s", responseText)
+	// Explain mode: record exactly what the provider receives
+	if explain, ok := relation.Properties["explain"].(bool); ok && explain {
+		relation.Properties["explain_prompt"] = map[string]interface{}{
+			"provider": tm.provider.Name(),
+			"agent":    "@ai-engineer",
+			"system":   redactSecrets(agentPrompt),
+			"user":     redactSecrets(prompt),
+		}
+		log.Printf("🔎 Explain mode: recorded final prompt for %s (%d chars)", relationID, len(agentPrompt)+len(prompt))
+	}
+	
+	// Pure text generation (we want JSON, not tool execution). An unusable
+	// spec is sent back to the model once with what was wrong with it. The
+	// AI timeout covers every attempt together, not each one.
+	ctx, cancel := context.WithTimeout(context.Background(), loadRetryPolicy().Deadline)
+	defer cancel()
+	var spec *CommandSpec
+	var problem error
+	for attempt := 1; attempt <= maxGenerationAttempts; attempt++ {
+		responseText, err := tm.provider.Generate(ctx, messages, agentPrompt, "@ai-engineer")
+		if err != nil {
+			return nil, "", fmt.Errorf("AI code generation failed: %w", err)
+		}
+		
+		spec, problem = tm.parseGeneratedSpec(responseText)
+		if problem == nil {
+			break
+		}
+		
+		log.Printf("❌ Generated tool spec for %s unusable (attempt %d/%d): %v", name, attempt, maxGenerationAttempts, problem)
 		
-		// Write failed response to file for debugging
-		if debugErr := tm.writeDebugResponse(responseText, err, relationID); debugErr != nil {
+		// Write failed response to file for debugging, and keep it on the
+		// relation so it can be seen from port42 without the daemon's disk
+		if debugErr := tm.writeDebugResponse(responseText, problem, relationID); debugErr != nil {
 			log.Printf("⚠️ Failed to write debug file: %v", debugErr)
 		}
+		recordGenerationFailure(relation, attempt, problem, responseText)
 		
-		return nil, "", fmt.Errorf("failed to extract tool spec from AI response: %w", err)
+		if responseText == "" {
+			responseText = "(empty response)"
+		}
+		messages = append(messages,
+			Message{Role: "assistant", Content: responseText},
+			Message{Role: "user", Content: correctivePrompt(problem)},
+		)
 	}
-	
-	// Validate language selection (B2.4 Error Handling)
-	if err := tm.validateLanguageSelection(spec.Language); err != nil {
-		log.Printf("⚠️ Invalid language selection, falling back to Python: %v", err)
-		spec.Language = "python" // Fallback to Python
+	if problem != nil {
+		return nil, "", fmt.Errorf("AI returned an unusable tool spec after %d attempts: %w", maxGenerationAttempts, problem)
 	}
 	
 	// Add relation context to spec
 	spec.SessionID = relationID // Use relation ID as session context
 	spec.Agent = "@ai-engineer"
 	
+	// Dependencies declared on the relation add to the ones the AI listed
+	spec.Dependencies = validDependencies(append(stringList(relation.Properties["dependencies"]), spec.Dependencies...))
+	
 	// Process implementation the same way as existing command crystallization
 	implementation := spec.Implementation
 	
@@ -279,8 +433,18 @@ func (tm *ToolMaterializer) generateToolCode(name string, transforms []string, r
 		shebang = "#!/usr/bin/env python3"
 	}
 	
-	code := fmt.Sprintf("%s\n%s",
+	// Missing dependencies stop the tool with install instructions
+	depCheckCode := ""
+	if len(spec.Dependencies) > 0 {
+		if err := writeDependencyInstaller(tm.storage.baseDir); err != nil {
+			log.Printf("⚠️ Failed to write dependency installer: %v", err)
+		}
+		depCheckCode = generateDependencyCheck(spec.Language, spec.Dependencies)
+	}
+	
+	code := fmt.Sprintf("%s\n%s%s",
 		shebang,
+		depCheckCode,
 		implementation)
 	
 	// Validate generated code (B2.4 Error Handling)
@@ -314,6 +478,7 @@ func (tm *ToolMaterializer) extractToolSpecFromResponse(responseText string) (*C
 		Language       string   `json:"language"`
 		Implementation string   `json:"implementation"`
 		Tags           []string `json:"tags"`
+		Dependencies   []string `json:"dependencies"`
 	}
 	
 	// Look for JSON code block (same as legacy)
@@ -332,7 +497,7 @@ func (tm *ToolMaterializer) extractToolSpecFromResponse(responseText string) (*C
 	}
 	
 	jsonStr := strings.TrimSpace(responseText[startIdx : startIdx+endIdx])
-	log.Printf("🔍 DEBUG: Extracted JSON:\n%s", jsonStr)
+	logger.Debugf("🔍 DEBUG: Extracted JSON:\n%s", jsonStr)
 	
 	// Parse our new clean slate format
 	var toolResp ToolResponse
@@ -361,7 +526,7 @@ func (tm *ToolMaterializer) extractToolSpecFromResponse(responseText string) (*C
 		Description:    toolResp.Description,
 		Language:       toolResp.Language,
 		Implementation: toolResp.Implementation,
-		Dependencies:   []string{}, // AI handles dependencies in implementation
+		Dependencies:   toolResp.Dependencies, // External commands or modules the AI declared
 		Tags:           toolResp.Tags, // Use AI-generated tags
 		// Other fields will be set by materialization process
 	}
@@ -471,17 +636,14 @@ Respond with ONLY the language name: bash, python, or node`, strings.Join(transf
 
 	// Get AI response for language selection
 	messages := []Message{{Role: "user", Content: prompt}}
-	response, err := tm.aiClient.Send(messages, "", "language-selector")
+	responseText, err := tm.provider.Generate(context.Background(), messages, "", "language-selector")
 	if err != nil {
 		// Fallback to simple heuristics if AI fails
 		return tm.selectLanguageWithHeuristics(transforms)
 	}
 	
 	// Parse AI response
-	var responseText string
-	if len(response.Content) > 0 {
-		responseText = response.Content[0].Text
-	} else {
+	if responseText == "" {
 		return tm.selectLanguageWithHeuristics(transforms)
 	}
 	
@@ -578,7 +740,7 @@ NODE: Web servers, REST APIs, GraphQL, interactive tools, user interfaces, real-
 <dependency_management>
 1. Use only standard library modules when possible
 2. For Python: Handle missing modules gracefully with helpful install messages
-3. Check for and declare any required external dependencies
+3. List required external commands, Python modules or Node packages in "dependencies" (empty if none); a check for them is added automatically
 </dependency_management>
 
 <metadata>
@@ -596,6 +758,7 @@ Respond with a JSON object in this EXACT format (no additional text):
   "description": "Brief description of what this tool does",
   "language": "your_selected_language_here",
   "tags": ["semantic-tag1", "domain-tag", "tool-type", "functionality"],
+  "dependencies": [],
   "implementation": "Your complete implementation here. Do NOT include shebang - it will be added automatically"
 }
 ` + "```", name)
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ToolResolution records which relation a tool name resolved to and why.
+// Several Tool relations can share a name, for instance a tool generated
+// by possess and one declared later; the most recently updated one wins.
+type ToolResolution struct {
+	RelationID string   `json:"relation_id"`
+	Reason     string   `json:"reason"`
+	Candidates []string `json:"candidates,omitempty"` // Every relation with the name, chosen first; only set on a conflict
+}
+
+// rankToolRelations orders relations sharing a name by preference: most
+// recently updated first, then most recently created, then by ID, so the
+// choice doesn't depend on listing order
+func rankToolRelations(relations []Relation) {
+	sort.SliceStable(relations, func(i, j int) bool {
+		a, b := relations[i], relations[j]
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// chooseToolRelation picks the relation a tool name means from the Tool
+// relations carrying it
+func chooseToolRelation(toolName string, named []Relation) (Relation, ToolResolution) {
+	if len(named) == 1 {
+		return named[0], ToolResolution{
+			RelationID: named[0].ID,
+			Reason:     "only tool named " + toolName,
+		}
+	}
+
+	ranked := append([]Relation(nil), named...)
+	rankToolRelations(ranked)
+	candidates := make([]string, len(ranked))
+	for i, relation := range ranked {
+		candidates[i] = relation.ID
+	}
+	chosen := ranked[0]
+	return chosen, ToolResolution{
+		RelationID: chosen.ID,
+		Reason: fmt.Sprintf("most recently updated of %d tools named %s (updated %s)",
+			len(ranked), toolName, chosen.UpdatedAt.Format("2006-01-02 15:04:05")),
+		Candidates: candidates,
+	}
+}
+
+// toolsNamed returns the Tool relations called toolName
+func toolsNamed(relations []Relation, toolName string) []Relation {
+	var named []Relation
+	for _, relation := range relations {
+		if relation.Type == "Tool" && getRelationName(relation) == toolName {
+			named = append(named, relation)
+		}
+	}
+	return named
+}
+
+// resolveTool finds the Tool relation a name refers to. A conflict is
+// logged the first time each choice is made, rather than on every lookup.
+func (s *Storage) resolveTool(toolName string) (*Relation, ToolResolution, error) {
+	if s.relationStore == nil {
+		return nil, ToolResolution{}, fmt.Errorf("relation store not available")
+	}
+	tools, err := s.relationStore.LoadByType("Tool")
+	if err != nil {
+		return nil, ToolResolution{}, fmt.Errorf("failed to load tools: %w", err)
+	}
+	named := toolsNamed(tools, toolName)
+	if len(named) == 0 {
+		return nil, ToolResolution{}, fmt.Errorf("%w: %s", errToolNotFound, toolName)
+	}
+
+	tool, resolution := chooseToolRelation(toolName, named)
+	if len(resolution.Candidates) > 1 {
+		key := toolName + "\x00" + strings.Join(resolution.Candidates, ",")
+		if _, seen := s.toolConflicts.LoadOrStore(key, true); !seen {
+			logger.Infof("⚠️ [TOOLS] %d relations are named %s (%s); using %s, the %s",
+				len(resolution.Candidates), toolName, strings.Join(resolution.Candidates, ", "), tool.ID, resolution.Reason)
+		}
+	}
+	return &tool, resolution, nil
+}
+
+// toolPathName returns the tool a /tools/{name}/... path is about
+func toolPathName(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 2 && parts[0] == "tools" && !isToolsIndexDir(parts[1]) {
+		return parts[1], true
+	}
+	return "", false
+}
+
+// toolNameConflicts groups relations of the same type that share a name,
+// keyed by "type/name", each list ordered the way resolution prefers them
+func toolNameConflicts(relations []Relation) map[string][]string {
+	byName := make(map[string][]Relation)
+	for _, relation := range relations {
+		name := getRelationName(relation)
+		if name == "" {
+			continue
+		}
+		key := relation.Type + "/" + name
+		byName[key] = append(byName[key], relation)
+	}
+
+	conflicts := make(map[string][]string)
+	for key, named := range byName {
+		if len(named) < 2 {
+			continue
+		}
+		rankToolRelations(named)
+		ids := make([]string, len(named))
+		for i, relation := range named {
+			ids[i] = relation.ID
+		}
+		conflicts[key] = ids
+	}
+	return conflicts
+}
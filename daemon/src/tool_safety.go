@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Severities of a safety warning. A high one is code that can destroy data
+// or run something fetched from the network.
+const (
+	SafetyHigh   = "high"
+	SafetyMedium = "medium"
+)
+
+// SafetyWarning is a dangerous-looking pattern found in tool code
+type SafetyWarning struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Match    string `json:"match"`
+}
+
+// safetyPatterns are checked line by line. They look for obvious mistakes
+// and are easy to get past on purpose; a clean scan isn't a guarantee.
+var safetyPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity string
+	message  string
+}{
+	{regexp.MustCompile(`\brm\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*[rR][a-zA-Z]*\s+(-[a-zA-Z]+\s+)*("?(/|~|\$HOME|\$\{HOME\})"?/?\*?)(\s|;|$)`), SafetyHigh, "recursively deletes the root or home directory"},
+	{regexp.MustCompile(`\b(curl|wget)\b[^|#]*\|\s*(sudo\s+)?(ba|z|k|da)?sh\b`), SafetyHigh, "pipes a download into a shell"},
+	{regexp.MustCompile(`\b(ba|z)?sh\s+(-c\s+)?["']?\$\(\s*(curl|wget)\b`), SafetyHigh, "runs a downloaded script"},
+	{regexp.MustCompile(`\beval\s+["']?\$\(\s*(curl|wget)\b`), SafetyHigh, "evaluates downloaded code"},
+	{regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}`), SafetyHigh, "fork bomb"},
+	{regexp.MustCompile(`\bmkfs(\.\w+)?\b`), SafetyHigh, "formats a filesystem"},
+	{regexp.MustCompile(`\bdd\b[^#\n]*\bof=/dev/(sd|hd|nvme|disk|xvd|mmcblk)`), SafetyHigh, "writes to a raw disk"},
+	{regexp.MustCompile(`>\s*/dev/(sd|hd|nvme|disk|xvd|mmcblk)\w*`), SafetyHigh, "writes to a raw disk"},
+	{regexp.MustCompile(`shutil\.rmtree\(\s*(os\.path\.expanduser\()?["'](/|~)/?["']`), SafetyHigh, "recursively deletes the root or home directory"},
+	{regexp.MustCompile(`\bchmod\s+(-R\s+)?(0?777|a\+rwx)\s+/`), SafetyMedium, "makes system paths world-writable"},
+	{regexp.MustCompile(`(^|[;&|]\s*|\s)sudo\s`), SafetyMedium, "runs commands as root"},
+	{regexp.MustCompile(`\bsubprocess\.\w+\([^)]*shell\s*=\s*True`), SafetyMedium, "runs shell commands built at run time"},
+}
+
+// scanToolSafety returns the dangerous-looking patterns in code, in line order
+func scanToolSafety(code string) []SafetyWarning {
+	warnings := []SafetyWarning{}
+	for i, line := range strings.Split(code, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+			continue // Comments, including the shebang
+		}
+		for _, check := range safetyPatterns {
+			if match := check.pattern.FindString(line); match != "" {
+				warnings = append(warnings, SafetyWarning{
+					Line:     i + 1,
+					Severity: check.severity,
+					Message:  check.message,
+					Match:    strings.TrimSpace(match),
+				})
+			}
+		}
+	}
+	return warnings
+}
+
+// safetyWarningLines formats warnings to keep on a relation
+func safetyWarningLines(warnings []SafetyWarning) []string {
+	lines := make([]string, len(warnings))
+	for i, warning := range warnings {
+		lines[i] = fmt.Sprintf("line %d (%s): %s: %s", warning.Line, warning.Severity, warning.Message, warning.Match)
+	}
+	return lines
+}
+
+// hasHighSeverity reports whether any warning is high severity
+func hasHighSeverity(warnings []SafetyWarning) bool {
+	for _, warning := range warnings {
+		if warning.Severity == SafetyHigh {
+			return true
+		}
+	}
+	return false
+}
+
+// handleValidateToolSafety scans tool code for dangerous patterns: the code
+// given, or the current executable of a named tool
+func (d *Daemon) handleValidateToolSafety(req Request) Response {
+	var payload struct {
+		Code string `json:"code,omitempty"`
+		Name string `json:"name,omitempty"` // A tool, scanned as stored
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if (payload.Code == "") == (payload.Name == "") {
+		return NewErrorResponse(req.ID, "Give either code or name")
+	}
+
+	code := payload.Code
+	data := map[string]interface{}{}
+	if payload.Name != "" {
+		if d.storage == nil {
+			return NewErrorResponse(req.ID, "Storage not initialized")
+		}
+		tool, err := d.storage.findToolRelation(payload.Name)
+		if err != nil {
+			return NewErrorResponse(req.ID, err.Error())
+		}
+		executableID := getStringProperty(tool.Properties, "executable_id")
+		if executableID == "" {
+			return NewErrorResponse(req.ID, fmt.Sprintf("%s has no stored executable", payload.Name))
+		}
+		content, err := d.storage.Read(executableID)
+		if err != nil {
+			return NewErrorResponse(req.ID, fmt.Sprintf("Failed to read %s: %v", payload.Name, err))
+		}
+		code = string(content)
+		data["name"] = payload.Name
+		data["relation_id"] = tool.ID
+		data["sandbox_profile"] = toolSandboxProfile(*tool)
+	}
+
+	warnings := scanToolSafety(code)
+	data["warnings"] = warnings
+	data["count"] = len(warnings)
+	data["safe"] = !hasHighSeverity(warnings)
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(data)
+	return resp
+}
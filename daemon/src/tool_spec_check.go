@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxGenerationAttempts is how many times a tool spec is requested before
+// materialization gives up: the first generation plus one corrective retry
+const maxGenerationAttempts = 2
+
+// maxRecordedOutput caps how much of a rejected model response is kept on
+// the relation for debugging
+const maxRecordedOutput = 16 * 1024
+
+// validateToolSpec rejects generated specs that would install a broken
+// script: no implementation, a language we can't run, or code that stops
+// before its brackets close, which is what a truncated response looks like
+func validateToolSpec(spec *CommandSpec) error {
+	implementation := spec.Implementation
+	if lines := strings.SplitN(implementation, "\n", 2); strings.HasPrefix(lines[0], "#!") {
+		implementation = ""
+		if len(lines) == 2 {
+			implementation = lines[1]
+		}
+	}
+	if strings.TrimSpace(implementation) == "" {
+		return fmt.Errorf("implementation is empty")
+	}
+
+	switch spec.Language {
+	case "bash", "python", "node":
+	default:
+		return fmt.Errorf("unrecognized language %q (expected bash, python or node)", spec.Language)
+	}
+
+	return checkBalanced(implementation, spec.Language)
+}
+
+// checkBalanced reports unclosed or mismatched brackets and unterminated
+// strings, skipping string literals and comments. Python checks (), []
+// and {}; bash and node only [] and {}, since case patterns and regex
+// literals legitimately leave parentheses unpaired. It is a truncation
+// check, not a parser: a stray closing bracket is allowed.
+func checkBalanced(code, language string) error {
+	pairs := map[rune]rune{']': '[', '}': '{'}
+	if language == "python" {
+		pairs[')'] = '('
+	}
+	openers := make(map[rune]bool, len(pairs))
+	for _, opener := range pairs {
+		openers[opener] = true
+	}
+
+	var stack []rune
+	var lines []int
+	runes := []rune(code)
+	line := 1
+	heredoc := "" // Delimiter of a bash here-document whose body starts on the next line
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+			if heredoc != "" {
+				i = skipHeredoc(runes, i, heredoc, &line) - 1
+				heredoc = ""
+			}
+
+		// Here-document bodies are text, not code
+		case r == '<' && language == "bash" && heredoc == "":
+			if match := heredocStart.FindStringSubmatch(string(runes[i:min(i+80, len(runes))])); match != nil && !strings.HasPrefix(match[0], "<<<") {
+				heredoc = match[2]
+				i += len([]rune(match[0])) - 1
+			}
+
+		// Comments run to the end of the line; in bash only at the start of
+		// a word, since ${#var} and $# aren't comments
+		case r == '#' && language == "python",
+			r == '#' && language == "bash" && (i == 0 || strings.ContainsRune(" \t\n;", runes[i-1])):
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case r == '/' && language == "node" && i+1 < len(runes) && runes[i+1] == '/':
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case r == '/' && language == "node" && i+1 < len(runes) && runes[i+1] == '*':
+			end := strings.Index(string(runes[i+2:]), "*/")
+			if end < 0 {
+				return fmt.Errorf("unterminated comment starting on line %d", line)
+			}
+			skipped := []rune(string(runes[i+2:])[:end])
+			line += strings.Count(string(skipped), "\n")
+			i += 2 + len(skipped) + 1
+
+		// Strings: triple quotes in python, ` in node, otherwise ' and "
+		case r == '"' || r == '\'' || (r == '`' && language == "node"):
+			start := line
+			delimiter := string(r)
+			if language == "python" && i+2 < len(runes) && runes[i+1] == r && runes[i+2] == r {
+				delimiter = strings.Repeat(string(r), 3)
+			}
+			i += len(delimiter)
+			closed := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '\n' {
+					line++
+				}
+				if runes[i] == '\\' && !(language == "bash" && r == '\'') {
+					i++
+					continue
+				}
+				if strings.HasPrefix(string(runes[i:min(i+len(delimiter), len(runes))]), delimiter) {
+					i += len(delimiter) - 1
+					closed = true
+					break
+				}
+			}
+			if !closed {
+				return fmt.Errorf("unterminated string starting on line %d", start)
+			}
+
+		case openers[r]:
+			stack = append(stack, r)
+			lines = append(lines, line)
+		case pairs[r] != 0:
+			if len(stack) == 0 {
+				continue
+			}
+			if top := stack[len(stack)-1]; top != pairs[r] {
+				return fmt.Errorf("mismatched %q on line %d closes %q from line %d", r, line, top, lines[len(lines)-1])
+			}
+			stack = stack[:len(stack)-1]
+			lines = lines[:len(lines)-1]
+		}
+	}
+
+	if len(stack) > 0 {
+		return fmt.Errorf("unclosed %q from line %d (the code looks truncated)", stack[len(stack)-1], lines[len(lines)-1])
+	}
+	return nil
+}
+
+// heredocStart matches a bash here-document operator and its delimiter
+var heredocStart = regexp.MustCompile(`^<<-?\s*(['"]?)([A-Za-z_][A-Za-z0-9_]*)['"]?`)
+
+// skipHeredoc returns the index of the newline ending a here-document
+// whose body starts after the newline at runes[i], or len(runes) if it
+// never ends. line counts the body lines skipped.
+func skipHeredoc(runes []rune, i int, delimiter string, line *int) int {
+	for i < len(runes) {
+		end := i + 1
+		for end < len(runes) && runes[end] != '\n' {
+			end++
+		}
+		if strings.TrimLeft(string(runes[i+1:end]), "\t") == delimiter {
+			return end
+		}
+		*line++
+		i = end
+	}
+	return len(runes)
+}
+
+// correctivePrompt asks the model to try again after an unusable response
+func correctivePrompt(problem error) string {
+	return fmt.Sprintf(`Your previous response could not be used: %v.
+
+Reply again with the complete tool specification as a single `+"```json"+` code block containing "name", "description", "language" (bash, python or node), "implementation", "tags" and "dependencies". The implementation must be the full, untruncated program with every bracket and string closed.`, problem)
+}
+
+// truncateOutput shortens a model response for recording on a relation
+func truncateOutput(output string) string {
+	if len(output) <= maxRecordedOutput {
+		return output
+	}
+	return output[:maxRecordedOutput] + fmt.Sprintf("\n... [%d more bytes]", len(output)-maxRecordedOutput)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ToolUninstallResult reports everything uninstall_tool removed
+type ToolUninstallResult struct {
+	Tool             string   `json:"tool"`
+	RelationID       string   `json:"relation_id,omitempty"`
+	DeletedRelations []string `json:"deleted_relations"`
+	OrphanedChildren []string `json:"orphaned_children,omitempty"` // Spawned relations left in place without cascade
+	RemovedSymlinks  []string `json:"removed_symlinks"`
+	RemovedPaths     []string `json:"removed_paths"`
+	DeletedObjects   []string `json:"deleted_objects"`
+	KeptObjects      []string `json:"kept_objects,omitempty"` // Executables something else still refers to
+	FreedBytes       int64    `json:"freed_bytes"`
+}
+
+// uninstallTool removes a tool in one go: its relation (and with cascade,
+// the relations it spawned), its command symlink and store file, and its
+// /commands and /tools paths. With gc, the tool's executables that nothing
+// refers to any more are deleted too; the GC grace period doesn't apply,
+// since they are known to belong to the tool.
+func (d *Daemon) uninstallTool(name string, cascade, gc bool) (*ToolUninstallResult, error) {
+	s := d.storage
+	result := &ToolUninstallResult{
+		Tool:             name,
+		DeletedRelations: []string{},
+		RemovedSymlinks:  []string{},
+		RemovedPaths:     []string{},
+		DeletedObjects:   []string{},
+	}
+
+	executables := make(map[string]bool)
+	if id := s.commandObjectID(name); id != "" {
+		executables[id] = true
+	}
+	names := []string{name}
+
+	tool, err := s.findToolRelation(name)
+	if err != nil && !errors.Is(err, errToolNotFound) {
+		return nil, err
+	}
+	if tool != nil {
+		if d.realityCompiler == nil {
+			return nil, fmt.Errorf("reality compiler not initialized")
+		}
+		result.RelationID = tool.ID
+
+		// Loaded first: the cascade may delete relations we need names from
+		relations, err := s.relationStore.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load relations: %w", err)
+		}
+		byID := make(map[string]Relation, len(relations))
+		for _, relation := range relations {
+			byID[relation.ID] = relation
+		}
+
+		deleted, err := d.realityCompiler.DeleteRelationCascade(tool.ID, cascade)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete relation: %w", err)
+		}
+		result.DeletedRelations = deleted.DeletedRelations
+		result.OrphanedChildren = deleted.OrphanedChildren
+		result.RemovedSymlinks = append(result.RemovedSymlinks, deleted.RemovedPaths...)
+
+		for _, id := range deleted.DeletedRelations {
+			relation, ok := byID[id]
+			if !ok {
+				continue
+			}
+			if executableID, _ := relation.Properties["executable_id"].(string); executableID != "" {
+				executables[executableID] = true
+			}
+			for _, version := range toolVersions(relation) {
+				executables[version.ObjectID] = true
+			}
+			if relation.Type == "Tool" && id != tool.ID {
+				if childName := getRelationName(relation); childName != "" {
+					names = append(names, childName)
+				}
+			}
+		}
+	}
+
+	for _, toolName := range names {
+		// Dematerializing already removed symlinks of materialized tools
+		link := filepath.Join(s.commandsDir(), toolName)
+		if _, err := os.Lstat(link); err == nil {
+			if err := s.removeCommandFiles(toolName); err != nil {
+				return result, fmt.Errorf("failed to remove command %s: %w", toolName, err)
+			}
+			result.RemovedSymlinks = append(result.RemovedSymlinks, link)
+		} else {
+			os.Remove(s.commandStorePath(toolName))
+		}
+
+		paths, holders, err := s.removeToolPaths(toolName)
+		if err != nil {
+			return result, err
+		}
+		result.RemovedPaths = append(result.RemovedPaths, paths...)
+		for _, id := range holders {
+			executables[id] = true
+		}
+	}
+
+	if tool == nil && len(result.RemovedSymlinks) == 0 && len(result.RemovedPaths) == 0 {
+		return nil, fmt.Errorf("%w: %s", errToolNotFound, name)
+	}
+
+	if gc {
+		deleted, kept, freed, err := s.deleteUnreferenced(executables)
+		result.DeletedObjects = append(result.DeletedObjects, deleted...)
+		result.KeptObjects = kept
+		result.FreedBytes += freed
+		if err != nil {
+			return result, err
+		}
+	}
+
+	log.Printf("🧹 Uninstalled %s: %d relations, %d symlinks, %d paths, %d objects (%d bytes) removed",
+		name, len(result.DeletedRelations), len(result.RemovedSymlinks), len(result.RemovedPaths),
+		len(result.DeletedObjects), result.FreedBytes)
+	return result, nil
+}
+
+// isToolPath reports whether path is the tool's /commands path or one of
+// its /tools paths
+func isToolPath(path, name string) bool {
+	return path == "/commands/"+name || path == "/tools/"+name || strings.HasPrefix(path, "/tools/"+name+"/")
+}
+
+// removeToolPaths drops a tool's /commands and /tools paths from every
+// object's metadata, deprecating objects left without paths the way
+// delete_path does. Returns the paths removed and the objects that had them.
+func (s *Storage) removeToolPaths(name string) ([]string, []string, error) {
+	docs, _ := s.searchIndex.snapshot("", "")
+
+	removed := make(map[string]bool)
+	var holders []string
+	for _, doc := range docs {
+		held := false
+		for _, path := range doc.Paths {
+			if isToolPath(path, name) {
+				held = true
+				break
+			}
+		}
+		if !held {
+			continue
+		}
+
+		unlock := s.metadataLocks.Lock(doc.ID)
+		meta, err := s.LoadMetadata(doc.ID)
+		if err != nil {
+			unlock()
+			return nil, nil, fmt.Errorf("failed to load metadata for %s: %w", shortID(doc.ID), err)
+		}
+		kept := []string{}
+		for _, path := range meta.Paths {
+			if isToolPath(path, name) {
+				removed[path] = true
+			} else {
+				kept = append(kept, path)
+			}
+		}
+		meta.Paths = kept
+		if len(meta.Paths) == 0 {
+			meta.Lifecycle = "deprecated"
+		}
+		err = s.SaveMetadata(meta)
+		unlock()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to update metadata for %s: %w", shortID(doc.ID), err)
+		}
+		holders = append(holders, doc.ID)
+	}
+
+	paths := make([]string, 0, len(removed))
+	for path := range removed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, holders, nil
+}
+
+// deleteUnreferenced deletes the given objects that GC would find no
+// referrers for, returning which were deleted and which were kept
+func (s *Storage) deleteUnreferenced(candidates map[string]bool) (deleted, kept []string, freed int64, err error) {
+	ids, err := s.List()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to list objects: %w", err)
+	}
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		exists[id] = true
+	}
+	refs, err := s.gcReferences(exists)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	sorted := make([]string, 0, len(candidates))
+	for id := range candidates {
+		if exists[id] {
+			sorted = append(sorted, id)
+		}
+	}
+	sort.Strings(sorted)
+	for _, id := range sorted {
+		if refs.referenced[id] {
+			kept = append(kept, id)
+			continue
+		}
+		objectFreed, err := s.deleteObject(id)
+		if err != nil {
+			return deleted, kept, freed, fmt.Errorf("failed to delete object %s: %w", shortID(id), err)
+		}
+		deleted = append(deleted, id)
+		freed += objectFreed
+	}
+
+	if chunked, ok := s.objects.(*ChunkedObjectStore); ok && len(deleted) > 0 {
+		_, chunksFreed := chunked.SweepChunks()
+		s.objectBytes.Add(-chunksFreed)
+		freed += chunksFreed
+	}
+	return deleted, kept, freed, nil
+}
+
+// handleUninstallTool removes a tool's relation, command, paths and
+// (with gc) its orphaned executables in one request
+func (d *Daemon) handleUninstallTool(req Request) Response {
+	if d.storage == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		Name    string `json:"name"`
+		Cascade bool   `json:"cascade,omitempty"` // Also delete relations it spawned
+		GC      bool   `json:"gc,omitempty"`      // Also delete executables nothing else refers to
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		return NewErrorResponse(req.ID, "name is required")
+	}
+	if strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		return NewErrorResponse(req.ID, fmt.Sprintf("Invalid tool name: %q", name))
+	}
+
+	result, err := d.uninstallTool(name, payload.Cascade, payload.GC)
+	if err != nil {
+		return NewErrorResponse(req.ID, "Failed to uninstall tool: "+err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(result)
+	return resp
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PropVersions holds a Tool relation's executable history, oldest first.
+// executable_id always names the active entry.
+const PropVersions = "versions"
+
+// ToolVersion is one executable a tool has had
+type ToolVersion struct {
+	ObjectID  string    `json:"object_id"`
+	CreatedAt time.Time `json:"created_at"`
+	SessionID string    `json:"session_id,omitempty"`
+}
+
+// toolVersions reads PropVersions, which is []ToolVersion when set in memory
+// and []interface{} of maps once the relation has been through JSON
+func toolVersions(tool Relation) []ToolVersion {
+	switch raw := tool.Properties[PropVersions].(type) {
+	case []ToolVersion:
+		return append([]ToolVersion{}, raw...)
+	case []interface{}:
+		versions := make([]ToolVersion, 0, len(raw))
+		for _, item := range raw {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			version := ToolVersion{
+				ObjectID:  getStringProperty(entry, "object_id"),
+				SessionID: getStringProperty(entry, "session_id"),
+			}
+			if version.ObjectID == "" {
+				continue
+			}
+			if created, err := time.Parse(time.RFC3339Nano, getStringProperty(entry, "created_at")); err == nil {
+				version.CreatedAt = created
+			}
+			versions = append(versions, version)
+		}
+		return versions
+	}
+	return nil
+}
+
+// recordToolVersion makes objectID the tool's active executable and appends
+// it to the history unless it is already there. Tools from before versioning
+// get their current executable recorded first so it isn't lost.
+func recordToolVersion(tool *Relation, objectID, sessionID string) {
+	if tool.Properties == nil {
+		tool.Properties = make(map[string]interface{})
+	}
+	versions := toolVersions(*tool)
+
+	if len(versions) == 0 {
+		if current, _ := tool.Properties["executable_id"].(string); current != "" && current != objectID {
+			versions = append(versions, ToolVersion{
+				ObjectID:  current,
+				CreatedAt: tool.CreatedAt,
+				SessionID: getStringProperty(tool.Properties, "session_id"),
+			})
+		}
+	}
+
+	known := false
+	for _, version := range versions {
+		if version.ObjectID == objectID {
+			known = true
+			break
+		}
+	}
+	if !known {
+		versions = append(versions, ToolVersion{
+			ObjectID:  objectID,
+			CreatedAt: time.Now(),
+			SessionID: sessionID,
+		})
+	}
+
+	tool.Properties[PropVersions] = versions
+	tool.Properties["executable_id"] = objectID
+}
+
+// errToolNotFound is returned when no Tool relation has the requested name
+var errToolNotFound = errors.New("tool not found")
+
+// findToolRelation returns the Tool relation with the given name, the
+// most recently updated one if several have it
+func (s *Storage) findToolRelation(toolName string) (*Relation, error) {
+	tool, _, err := s.resolveTool(toolName)
+	return tool, err
+}
+
+// RecordToolVersion points a tool's relation at a new executable, keeping
+// the previous one in its history. Commands without a Tool relation have no
+// history to record and are ignored.
+func (s *Storage) RecordToolVersion(toolName, objectID, sessionID string) error {
+	tool, err := s.findToolRelation(toolName)
+	if errors.Is(err, errToolNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var count int
+	err = s.relationStore.Update(tool.ID, func(current *Relation) error {
+		recordToolVersion(current, objectID, sessionID)
+		current.UpdatedAt = time.Now()
+		count = len(toolVersions(*current))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save tool relation: %w", err)
+	}
+	log.Printf("🗂️ Recorded version %d of %s: %s", count, toolName, shortID(objectID))
+	return nil
+}
+
+// resolveToolVersion finds a version by its listing name (v1, v2, ...),
+// its number, or an object ID prefix
+func resolveToolVersion(versions []ToolVersion, selector string) (int, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		return -1, fmt.Errorf("version is required")
+	}
+
+	if n, err := strconv.Atoi(strings.TrimPrefix(selector, "v")); err == nil {
+		if n < 1 || n > len(versions) {
+			return -1, fmt.Errorf("version %d out of range (tool has %d versions)", n, len(versions))
+		}
+		return n - 1, nil
+	}
+
+	match := -1
+	for i, version := range versions {
+		if strings.HasPrefix(version.ObjectID, selector) {
+			if match >= 0 && versions[match].ObjectID != version.ObjectID {
+				return -1, fmt.Errorf("object ID prefix %q is ambiguous", selector)
+			}
+			match = i
+		}
+	}
+	if match < 0 {
+		return -1, fmt.Errorf("no version matches %q", selector)
+	}
+	return match, nil
+}
+
+// RestoreToolVersion makes an earlier executable active again: the relation's
+// executable_id, the /commands symlink, and the object's metadata (so it
+// owns the tool's paths again) all move to the chosen version.
+func (s *Storage) RestoreToolVersion(toolName, selector string) (map[string]interface{}, error) {
+	tool, err := s.findToolRelation(toolName)
+	if err != nil {
+		return nil, err
+	}
+	versions := toolVersions(*tool)
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("tool %s has no version history", toolName)
+	}
+	index, err := resolveToolVersion(versions, selector)
+	if err != nil {
+		return nil, err
+	}
+	target := versions[index]
+
+	if !s.objects.Exists(target.ObjectID) {
+		return nil, fmt.Errorf("version %d object %s is no longer stored", index+1, shortID(target.ObjectID))
+	}
+
+	previous, _ := tool.Properties["executable_id"].(string)
+	if err := s.CreateCommandSymlink(target.ObjectID, toolName); err != nil {
+		return nil, fmt.Errorf("failed to repoint command: %w", err)
+	}
+
+	// Metadata: the restored object takes over the paths of the one it replaces
+	unlock := s.metadataLocks.Lock(target.ObjectID)
+	meta, err := s.LoadMetadata(target.ObjectID)
+	if err != nil && previous != "" {
+		if current, currentErr := s.LoadMetadata(previous); currentErr == nil {
+			copied := *current
+			copied.ID = target.ObjectID
+			meta, err = &copied, nil
+		}
+	}
+	if err == nil {
+		// Written as-is: SaveMetadata would keep the old Modified time for
+		// unchanged content, and Modified decides which object owns a path
+		meta.Modified = time.Now()
+		meta.Accessed = meta.Modified
+		meta.Lifecycle = "active"
+		if saveErr := s.writeMetadata(meta); saveErr != nil {
+			log.Printf("⚠️ Failed to update metadata for restored version of %s: %v", toolName, saveErr)
+		}
+	} else {
+		log.Printf("⚠️ No metadata for restored version of %s: %v", toolName, err)
+	}
+	unlock()
+
+	err = s.relationStore.Update(tool.ID, func(current *Relation) error {
+		if current.Properties == nil {
+			current.Properties = make(map[string]interface{})
+		}
+		current.Properties["executable_id"] = target.ObjectID
+		current.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save tool relation: %w", err)
+	}
+
+	log.Printf("⏪ Restored %s to version %d (%s)", toolName, index+1, shortID(target.ObjectID))
+	return map[string]interface{}{
+		"tool":          toolName,
+		"relation_id":   tool.ID,
+		"version":       index + 1,
+		"executable_id": target.ObjectID,
+		"previous_id":   previous,
+		"created_at":    target.CreatedAt,
+		"session_id":    target.SessionID,
+	}, nil
+}
+
+// handleToolVersions lists /tools/{name}/versions/, oldest first
+func (s *Storage) handleToolVersions(toolName string) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	tool, err := s.findToolRelation(toolName)
+	if err != nil {
+		return entries
+	}
+
+	active, _ := tool.Properties["executable_id"].(string)
+	versions := toolVersions(*tool)
+	if len(versions) == 0 && active != "" {
+		// Tools from before versioning have just the one
+		versions = []ToolVersion{{ObjectID: active, CreatedAt: tool.CreatedAt, SessionID: getStringProperty(tool.Properties, "session_id")}}
+	}
+
+	for i, version := range versions {
+		entry := map[string]interface{}{
+			"name":       fmt.Sprintf("v%d", i+1),
+			"type":       "file",
+			"object_id":  version.ObjectID,
+			"created_at": version.CreatedAt,
+			"active":     version.ObjectID == active,
+			"path":       fmt.Sprintf("/tools/%s/versions/v%d", toolName, i+1),
+		}
+		if version.SessionID != "" {
+			entry["session_id"] = version.SessionID
+		}
+		if meta, err := s.LoadMetadata(version.ObjectID); err == nil {
+			entry["size"] = meta.Size
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// resolveToolVersionPath returns the object behind /tools/{name}/versions/{vN}
+func (s *Storage) resolveToolVersionPath(tool Relation, selector string) string {
+	versions := toolVersions(tool)
+	if len(versions) == 0 {
+		if active, _ := tool.Properties["executable_id"].(string); active != "" {
+			versions = []ToolVersion{{ObjectID: active}}
+		}
+	}
+	index, err := resolveToolVersion(versions, selector)
+	if err != nil {
+		return ""
+	}
+	return versions[index].ObjectID
+}
+
+// shortID abbreviates an object ID for logs
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12] + "..."
+	}
+	return id
+}
+
+// toolNameForExecutablePath returns the tool a path is the executable of:
+// /commands/{name}, /tools/{name}/executable, /source or /run
+func toolNameForExecutablePath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "commands":
+		return parts[1]
+	case len(parts) == 3 && parts[0] == "tools" && (parts[2] == "executable" || parts[2] == "source" || parts[2] == "run"):
+		return parts[1]
+	}
+	return ""
+}
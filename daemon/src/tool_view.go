@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isToolsIndexDir reports whether a name under /tools is one of the
+// organizational directories rather than a tool
+func isToolsIndexDir(name string) bool {
+	switch name {
+	case "by-name", "by-transform", "spawned-by", "ancestry", "by-date", "by-usage":
+		return true
+	}
+	return false
+}
+
+// toolDirName returns the tool name for a bare /tools/{name} path
+func toolDirName(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 2 && parts[0] == "tools" && !isToolsIndexDir(parts[1]) {
+		return parts[1], true
+	}
+	return "", false
+}
+
+// ToolView is what reading a bare /tools/{name} shows: a short header from
+// the tool's definition followed by its source, so `cat /tools/foo` gives
+// the code rather than relation JSON. /definition and /executable still
+// return each on its own.
+type ToolView struct {
+	Name       string
+	RelationID string
+	Resolution ToolResolution // Which relation the name resolved to, when several share it
+	Language   string
+	Content    []byte
+}
+
+// ToolView builds the view of a tool from its relation and source
+func (s *Storage) ToolView(toolName string) (*ToolView, error) {
+	relation, resolution, err := s.resolveTool(toolName)
+	if err != nil {
+		return nil, fmt.Errorf("tool not found: %s", toolName)
+	}
+
+	var source []byte
+	if sourceID := s.resolveToolsPath("/tools/" + toolName + "/source"); sourceID != "" {
+		if source, err = s.Read(sourceID); err != nil {
+			return nil, fmt.Errorf("failed to read source of %s: %v", toolName, err)
+		}
+	}
+	language := s.ToolLanguage(toolName, source)
+
+	var b strings.Builder
+	b.WriteString(toolName)
+	if description, _ := relation.Properties["description"].(string); description != "" {
+		b.WriteString(": " + description)
+	}
+	b.WriteString("\n")
+	if language != "" {
+		fmt.Fprintf(&b, "language:   %s\n", language)
+	}
+	if transforms := stringList(relation.Properties["transforms"]); len(transforms) > 0 {
+		fmt.Fprintf(&b, "transforms: %s\n", strings.Join(transforms, ", "))
+	}
+	fmt.Fprintf(&b, "definition: /tools/%s/definition\n", toolName)
+	if source == nil {
+		b.WriteString("\n(no executable stored for this tool)\n")
+	} else {
+		fmt.Fprintf(&b, "run:        /tools/%s/run\n\n", toolName)
+		b.Write(source)
+	}
+
+	return &ToolView{
+		Name:       toolName,
+		RelationID: relation.ID,
+		Resolution: resolution,
+		Language:   language,
+		Content:    []byte(b.String()),
+	}, nil
+}
@@ -25,6 +25,10 @@ type Metadata struct {
 	Accessed time.Time `json:"accessed"`
 	Session  string    `json:"session,omitempty"`
 	Agent    string    `json:"agent,omitempty"`
+	MimeType string    `json:"mime_type,omitempty"` // Detected when stored through a virtual path
+	
+	// Multi-file artifact the object is a file of (see ArtifactManifest)
+	ArtifactName string `json:"artifact_name,omitempty"`
 	
 	// Rich metadata
 	Title       string   `json:"title,omitempty"`
@@ -73,6 +77,8 @@ type PersistentSession struct {
 	LastActivity     time.Time              `json:"last_activity"`
 	Messages         []Message              `json:"messages"`
 	CommandGenerated *CommandGenerationInfo `json:"command_generated,omitempty"`
+	ForkedFrom       string                 `json:"forked_from,omitempty"`
+	ForkPoint        int                    `json:"fork_point,omitempty"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
 }
 
@@ -104,12 +110,36 @@ type SessionSummary struct {
 // SearchFilters defines filters for searching objects
 type SearchFilters struct {
 	Path   string    `json:"path,omitempty"`   // Limit to paths under this prefix
-	Type   string    `json:"type,omitempty"`   // Object type filter
+	Type   string    `json:"type,omitempty"`   // Object type filter, the same as Types with one entry
+	Types  []string  `json:"types,omitempty"`  // Match any of these types; with Type, either matches
 	After  time.Time `json:"after,omitempty"`  // Created after
 	Before time.Time `json:"before,omitempty"` // Created before
 	Agent  string    `json:"agent,omitempty"`  // Filter by agent
 	Tags   []string  `json:"tags,omitempty"`   // Must have all these tags
 	Limit  int       `json:"limit,omitempty"`  // Max results (default 20)
+	Offset int       `json:"offset,omitempty"` // Skip this many results; past the total gives an empty page
+	
+	FuzzyThreshold int `json:"fuzzy_threshold,omitempty"` // Fuzzy mode: max edits per query term (default 2)
+	
+	// Drop results scoring below this, after boosts, before sorting and
+	// paging. An empty query scores every result 1.0, so any threshold
+	// above 1 returns nothing and any at or below 1 keeps everything.
+	MinScore float64 `json:"min_score,omitempty"`
+	
+	// Objects without a metadata match have their content searched when
+	// they are at most MaxContentSize bytes (default 100KB). Larger ones,
+	// up to the daemon's PORT42_MAX_CONTENT_SEARCH_SIZE, have just their
+	// first ContentPrefix bytes searched when it is set, and are skipped
+	// otherwise. Both are capped at that ceiling.
+	MaxContentSize int64 `json:"max_content_size,omitempty"`
+	ContentPrefix  int64 `json:"content_prefix,omitempty"`
+	
+	// Message filters. Setting any limits the search to sessions (with Agent
+	// filtering by session agent) and returns one result per matching
+	// message. The query may then be empty to list every such message.
+	MessageRole   string    `json:"message_role,omitempty"`   // user or assistant
+	MessageAfter  time.Time `json:"message_after,omitempty"`  // Sent after
+	MessageBefore time.Time `json:"message_before,omitempty"` // Sent before
 }
 
 // SearchResult represents a search match
@@ -121,6 +151,16 @@ type SearchResult struct {
 	Snippet     string   `json:"snippet"`      // Context around match
 	Metadata    Metadata `json:"metadata"`     // Full metadata
 	MatchFields []string `json:"match_fields"` // Which fields matched
+	
+	Message    *MessageMatch `json:"message,omitempty"`    // Session searches: the best matching message
+	Highlights []MatchRange  `json:"highlights,omitempty"` // Where the query matched within Snippet
+}
+
+// MatchRange is a match within a search result's snippet, as byte offsets
+// from the snippet's start: Snippet[Start:End] is the matched text
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
 // SessionIndex represents the complete session storage (v2.0 format)
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chunked upload limits. An upload left without chunks for
+// uploadIdleTimeout is discarded the next time one begins.
+const (
+	defaultMaxUploadSize = 1024 * 1024 * 1024 // PORT42_MAX_UPLOAD_SIZE, 0 for no limit
+	uploadIdleTimeout    = time.Hour
+)
+
+// upload is a store_path whose content arrives in chunks. Chunks are
+// appended to a temp file and hashed as they arrive, so neither the
+// content nor its hash needs the whole file in memory.
+type upload struct {
+	id           string
+	path         string // Virtual path it will be stored at
+	metadata     map[string]interface{}
+	file         *os.File
+	hash         hash.Hash
+	size         int64
+	head         []byte // First bytes, for MIME sniffing
+	lastActivity time.Time
+	mu           sync.Mutex
+}
+
+// uploadManager tracks uploads in progress. Their temp files live in
+// ~/.port42/uploads, on the same filesystem as the object store so a
+// finished file can be renamed into place.
+type uploadManager struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex
+	uploads map[string]*upload
+}
+
+// newUploadManager clears temp files left by uploads that never finished
+// before a restart
+func newUploadManager(baseDir string) *uploadManager {
+	dir := filepath.Join(baseDir, "uploads")
+	if err := os.RemoveAll(dir); err != nil {
+		logger.Warnf("⚠️ Failed to clear %s: %v", dir, err)
+	}
+	return &uploadManager{
+		dir:     dir,
+		maxSize: int64(envInt("PORT42_MAX_UPLOAD_SIZE", defaultMaxUploadSize)),
+		uploads: make(map[string]*upload),
+	}
+}
+
+// begin opens a new upload for path
+func (m *uploadManager) begin(path string, metadata map[string]interface{}) (*upload, error) {
+	m.expireIdle()
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	u := &upload{
+		id:           "upload-" + generateID(),
+		path:         path,
+		metadata:     metadata,
+		hash:         sha256.New(),
+		lastActivity: time.Now(),
+	}
+	file, err := os.OpenFile(filepath.Join(m.dir, u.id+".part"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	u.file = file
+
+	m.mu.Lock()
+	m.uploads[u.id] = u
+	m.mu.Unlock()
+	return u, nil
+}
+
+// get returns an upload in progress
+func (m *uploadManager) get(id string) (*upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.uploads[id]
+	if !ok {
+		return nil, fmt.Errorf("upload not found: %s (it may have expired after %v without chunks)", id, uploadIdleTimeout)
+	}
+	return u, nil
+}
+
+// remove forgets an upload and deletes its temp file, if still there
+func (m *uploadManager) remove(u *upload) {
+	m.mu.Lock()
+	delete(m.uploads, u.id)
+	m.mu.Unlock()
+
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// expireIdle discards uploads that stopped receiving chunks
+func (m *uploadManager) expireIdle() {
+	m.mu.Lock()
+	var idle []*upload
+	for _, u := range m.uploads {
+		u.mu.Lock()
+		if time.Since(u.lastActivity) > uploadIdleTimeout {
+			idle = append(idle, u)
+		}
+		u.mu.Unlock()
+	}
+	m.mu.Unlock()
+
+	for _, u := range idle {
+		logger.Infof("🗑️ Discarding idle upload %s for %s (%d bytes)", u.id, u.path, u.size)
+		m.remove(u)
+	}
+}
+
+// append writes a chunk at offset, which must be where the upload has got
+// to; a negative offset appends without checking. Returns the new size.
+func (m *uploadManager) append(u *upload, chunk []byte, offset int64) (int64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if offset >= 0 && offset != u.size {
+		return u.size, fmt.Errorf("chunk offset %d does not match upload size %d", offset, u.size)
+	}
+	if m.maxSize > 0 && u.size+int64(len(chunk)) > m.maxSize {
+		return u.size, fmt.Errorf("upload exceeds the %d byte limit (PORT42_MAX_UPLOAD_SIZE)", m.maxSize)
+	}
+	if _, err := u.file.Write(chunk); err != nil {
+		return u.size, fmt.Errorf("failed to write chunk: %w", err)
+	}
+	u.hash.Write(chunk)
+	if len(u.head) < 512 {
+		need := 512 - len(u.head)
+		if need > len(chunk) {
+			need = len(chunk)
+		}
+		u.head = append(u.head, chunk[:need]...)
+	}
+	u.size += int64(len(chunk))
+	u.lastActivity = time.Now()
+	return u.size, nil
+}
+
+// StoreFileWithMetadata stores the content of a file whose SHA256 is
+// already known, like StoreWithMetadata. When the object store would keep
+// the content as a plain whole file anyway, the file is renamed into
+// place instead of being read into memory. The file is gone afterwards.
+func (s *Storage) StoreFileWithMetadata(file string, id string, size int64, meta *Metadata) (string, error) {
+	defer os.Remove(file)
+
+	unlock := s.objectLocks.Lock(id)
+	defer unlock()
+
+	if !s.objects.Exists(id) {
+		compress := shouldCompress(meta)
+		if whole, ok := s.objects.(*FileObjectStore); ok && (!compress || whole.compressMin <= 0 || size < int64(whole.compressMin)) {
+			path := whole.Path(id)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return "", fmt.Errorf("failed to create object directory: %w", err)
+			}
+			if err := os.Rename(file, path); err != nil {
+				return "", fmt.Errorf("failed to move upload into place: %w", err)
+			}
+			s.objectBytes.Add(size)
+		} else {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return "", fmt.Errorf("failed to read upload: %w", err)
+			}
+			if _, err := s.putObject(id, content, compress); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	meta.ID = id
+	meta.Size = size
+	if err := s.SaveMetadata(meta); err != nil {
+		return "", fmt.Errorf("failed to save metadata: %w", err)
+	}
+	return id, nil
+}
+
+// commitUpload finishes an upload, storing it at its virtual path
+func (d *Daemon) commitUpload(u *upload, expectedSHA string) (map[string]interface{}, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	id := hex.EncodeToString(u.hash.Sum(nil))
+	if expectedSHA != "" && !strings.EqualFold(expectedSHA, id) {
+		return nil, fmt.Errorf("%w: upload hashes to %s, expected %s", ErrObjectCorrupt, shortID(id), shortID(expectedSHA))
+	}
+	if err := u.file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to flush upload: %w", err)
+	}
+	if err := u.file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close upload: %w", err)
+	}
+
+	meta, pathType, subpath, err := newStorePathMetadata(u.path, u.head, u.metadata)
+	if err != nil {
+		return nil, err
+	}
+	objID, err := d.storage.StoreFileWithMetadata(u.file.Name(), id, u.size, meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store content: %v", err)
+	}
+	result := d.storage.finishStorePath(objID, pathType, subpath, meta)
+	result["sha256"] = objID
+	return result, nil
+}
+
+// handleBeginUpload starts a chunked store_path for content too large to
+// send in one message
+func (d *Daemon) handleBeginUpload(req Request) Response {
+	if d.storage == nil || d.uploads == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		Path     string                 `json:"path"`
+		Metadata map[string]interface{} `json:"metadata,omitempty"`
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+	if pathType, _ := parseVirtualPath(payload.Path); pathType == "invalid" {
+		return NewErrorResponse(req.ID, fmt.Sprintf("invalid virtual path: %s", payload.Path))
+	}
+
+	u, err := d.uploads.begin(payload.Path, payload.Metadata)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(map[string]interface{}{
+		"upload_id": u.id,
+		"path":      u.path,
+		"max_size":  d.uploads.maxSize,
+	})
+	return resp
+}
+
+// handleUploadChunk appends a base64 chunk to an upload
+func (d *Daemon) handleUploadChunk(req Request) Response {
+	if d.uploads == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		UploadID string `json:"upload_id"`
+		Data     string `json:"data"`             // base64 encoded
+		Offset   *int64 `json:"offset,omitempty"` // Where the chunk goes; lets a client detect a lost or repeated chunk
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+
+	u, err := d.uploads.get(payload.UploadID)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	chunk, err := base64.StdEncoding.DecodeString(payload.Data)
+	if err != nil {
+		return NewErrorResponse(req.ID, "Failed to decode chunk: "+err.Error())
+	}
+	offset := int64(-1)
+	if payload.Offset != nil {
+		offset = *payload.Offset
+	}
+
+	size, err := d.uploads.append(u, chunk, offset)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(map[string]interface{}{
+		"upload_id": u.id,
+		"size":      size,
+	})
+	return resp
+}
+
+// handleCommitUpload stores a finished upload at its path
+func (d *Daemon) handleCommitUpload(req Request) Response {
+	if d.storage == nil || d.uploads == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		UploadID string `json:"upload_id"`
+		SHA256   string `json:"sha256,omitempty"` // Checked against the received content when given
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+
+	u, err := d.uploads.get(payload.UploadID)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	// Committed or not, the upload is finished
+	defer d.uploads.remove(u)
+
+	result, err := d.commitUpload(u, payload.SHA256)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	logger.Infof("📦 Upload %s stored at %s (%d bytes)", u.id, u.path, u.size)
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(result)
+	return resp
+}
+
+// handleAbortUpload discards an upload
+func (d *Daemon) handleAbortUpload(req Request) Response {
+	if d.uploads == nil {
+		return NewErrorResponse(req.ID, "Storage not initialized")
+	}
+
+	var payload struct {
+		UploadID string `json:"upload_id"`
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		return NewErrorResponse(req.ID, "Invalid payload: "+err.Error())
+	}
+
+	u, err := d.uploads.get(payload.UploadID)
+	if err != nil {
+		return NewErrorResponse(req.ID, err.Error())
+	}
+	d.uploads.remove(u)
+
+	resp := NewResponse(req.ID, true)
+	resp.SetData(map[string]interface{}{
+		"upload_id": u.id,
+		"aborted":   true,
+	})
+	return resp
+}
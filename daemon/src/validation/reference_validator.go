@@ -64,6 +64,8 @@ func (rv *ReferenceValidator) ValidateReference(ref Reference) ValidationError {
 		return rv.validateP42Reference(ref.Target)
 	case "url":
 		return rv.validateURLReference(ref.Target)
+	case "git":
+		return rv.validateGitReference(ref.Target)
 	case "search":
 		return rv.validateSearchReference(ref.Target)
 	default:
@@ -71,7 +73,7 @@ func (rv *ReferenceValidator) ValidateReference(ref Reference) ValidationError {
 			Field:      "reference.type",
 			Message:    fmt.Sprintf("Unknown reference type: %s", ref.Type),
 			Code:       "INVALID_REFERENCE_TYPE",
-			Suggestion: "Valid types: file, p42, url, search",
+			Suggestion: "Valid types: file, p42, url, git, search",
 			Example:    "file:./data.json, p42:/tools/analyzer, url:https://api.docs, search:\"patterns\"",
 		}
 	}
@@ -88,6 +90,20 @@ func (rv *ReferenceValidator) validateFileReference(target string) ValidationErr
 		}
 	}
 
+	// Glob patterns must match something
+	if strings.ContainsAny(target, "*?[") {
+		if matches, err := filepath.Glob(target); err != nil || len(matches) == 0 {
+			return ValidationError{
+				Field:      "reference.target",
+				Message:    fmt.Sprintf("No files match pattern: %s", target),
+				Code:       "FILE_NOT_FOUND",
+				Suggestion: "Check the pattern; * does not cross directories, reference a directory to include it recursively",
+				Example:    "file:./src/*.go or file:./src",
+			}
+		}
+		return ValidationError{} // No error
+	}
+
 	// Check if file exists
 	if _, err := os.Stat(target); os.IsNotExist(err) {
 		return ValidationError{
@@ -165,6 +181,21 @@ func (rv *ReferenceValidator) validateURLReference(target string) ValidationErro
 	return ValidationError{} // No error
 }
 
+func (rv *ReferenceValidator) validateGitReference(target string) ValidationError {
+	repo, filePath, ok := strings.Cut(target, ":")
+	if target == "" || !ok || filePath == "" || !strings.Contains(repo, "/") {
+		return ValidationError{
+			Field:      "reference.target",
+			Message:    fmt.Sprintf("Invalid git reference: %s", target),
+			Code:       "INVALID_GIT_REFERENCE",
+			Suggestion: "Use host/owner/repo[@ref]:path; the ref defaults to HEAD",
+			Example:    "git:github.com/org/repo@main:src/main.go",
+		}
+	}
+
+	return ValidationError{} // No error
+}
+
 func (rv *ReferenceValidator) validateSearchReference(target string) ValidationError {
 	if target == "" {
 		return ValidationError{
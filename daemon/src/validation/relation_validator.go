@@ -0,0 +1,180 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// toolNamePattern is what a tool name must look like to become a command
+// symlink: lowercase letters, digits, '-' and '_', not starting with either
+var toolNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidToolName reports whether name can be used as a tool's command name
+func ValidToolName(name string) bool {
+	return toolNamePattern.MatchString(name)
+}
+
+type RelationValidator struct{}
+
+func NewRelationValidator() *RelationValidator {
+	return &RelationValidator{}
+}
+
+// ValidateRelation checks a relation's properties against the schema for
+// its type, returning every problem found. Types without a schema pass.
+func (rv *RelationValidator) ValidateRelation(relationType string, properties map[string]interface{}) []ValidationError {
+	switch relationType {
+	case "Tool":
+		return rv.validateTool(properties)
+	case "Relationship":
+		return rv.validateRelationship(properties)
+	default:
+		return nil
+	}
+}
+
+func (rv *RelationValidator) validateTool(properties map[string]interface{}) []ValidationError {
+	var errors []ValidationError
+
+	// name: required, and usable as a command name
+	switch name := properties["name"].(type) {
+	case nil:
+		errors = append(errors, ValidationError{
+			Field:      "relation.properties.name",
+			Message:    "Tool name is required",
+			Code:       "MISSING_TOOL_NAME",
+			Suggestion: "Give the tool a command name",
+			Example:    "port42 declare tool csv-analyzer --transforms csv,analyze",
+		})
+	case string:
+		if !toolNamePattern.MatchString(name) {
+			errors = append(errors, ValidationError{
+				Field:      "relation.properties.name",
+				Message:    fmt.Sprintf("Invalid tool name: %q", name),
+				Code:       "INVALID_TOOL_NAME",
+				Suggestion: "Use lowercase letters, digits, '-' and '_', starting with a letter or digit",
+				Example:    "csv-analyzer, log_parser, json2yaml",
+			})
+		}
+	default:
+		errors = append(errors, ValidationError{
+			Field:   "relation.properties.name",
+			Message: fmt.Sprintf("Tool name must be a string, got %T", name),
+			Code:    "INVALID_TOOL_NAME",
+			Example: `"name": "csv-analyzer"`,
+		})
+	}
+
+	// description: a non-empty string
+	if description, ok := properties["description"].(string); !ok || strings.TrimSpace(description) == "" {
+		errors = append(errors, ValidationError{
+			Field:      "relation.properties.description",
+			Message:    "Tool description must be a non-empty string",
+			Code:       "MISSING_TOOL_DESCRIPTION",
+			Suggestion: "Describe what the tool does",
+			Example:    `"description": "Summarize columns of a CSV file"`,
+		})
+	}
+
+	// transforms: a non-empty array of non-empty strings
+	transformsErr := ValidationError{
+		Field:      "relation.properties.transforms",
+		Message:    "Tool transforms must be a non-empty array of strings",
+		Code:       "INVALID_TOOL_TRANSFORMS",
+		Suggestion: "List what the tool transforms, as strings",
+		Example:    `"transforms": ["csv", "analyze"]`,
+	}
+	switch transforms := properties["transforms"].(type) {
+	case []string:
+		if len(transforms) == 0 || hasBlank(transforms) {
+			errors = append(errors, transformsErr)
+		}
+	case []interface{}:
+		values := make([]string, 0, len(transforms))
+		for _, transform := range transforms {
+			value, ok := transform.(string)
+			if !ok {
+				values = nil
+				break
+			}
+			values = append(values, value)
+		}
+		if len(values) == 0 || hasBlank(values) {
+			errors = append(errors, transformsErr)
+		}
+	default:
+		errors = append(errors, transformsErr)
+	}
+
+	// sandbox_profile: optional, one of the known profiles
+	if profile, present := properties["sandbox_profile"]; present {
+		name, _ := profile.(string)
+		known := false
+		for _, sandboxProfile := range sandboxProfiles {
+			known = known || name == sandboxProfile
+		}
+		if !known {
+			errors = append(errors, ValidationError{
+				Field:      "relation.properties.sandbox_profile",
+				Message:    fmt.Sprintf("Invalid sandbox profile: %v", profile),
+				Code:       "INVALID_SANDBOX_PROFILE",
+				Suggestion: "Use one of " + strings.Join(sandboxProfiles, ", "),
+				Example:    `"sandbox_profile": "restricted"`,
+			})
+		}
+	}
+
+	return errors
+}
+
+// sandboxProfiles are the profiles a Tool may be run under
+var sandboxProfiles = []string{"none", "restricted"}
+
+// relationshipTypes are the edge types a Relationship may declare
+var relationshipTypes = []string{"depends_on", "references", "derived_from", "similar_to"}
+
+func (rv *RelationValidator) validateRelationship(properties map[string]interface{}) []ValidationError {
+	var errors []ValidationError
+
+	// relationship_type: one of the known edge types
+	edgeType, _ := properties["relationship_type"].(string)
+	known := false
+	for _, relationshipType := range relationshipTypes {
+		known = known || edgeType == relationshipType
+	}
+	if !known {
+		errors = append(errors, ValidationError{
+			Field:      "relation.properties.relationship_type",
+			Message:    fmt.Sprintf("Invalid relationship type: %q", edgeType),
+			Code:       "INVALID_RELATIONSHIP_TYPE",
+			Suggestion: "Use one of " + strings.Join(relationshipTypes, ", "),
+			Example:    `"relationship_type": "depends_on"`,
+		})
+	}
+
+	// from, to: the relations (or tool names) the edge connects
+	for _, key := range []string{"from", "to"} {
+		if end, ok := properties[key].(string); !ok || strings.TrimSpace(end) == "" {
+			errors = append(errors, ValidationError{
+				Field:      "relation.properties." + key,
+				Message:    fmt.Sprintf("Relationship %s must be a relation ID or tool name", key),
+				Code:       "MISSING_RELATIONSHIP_END",
+				Suggestion: "Name both ends of the edge",
+				Example:    `"from": "report-builder", "to": "csv-parser"`,
+			})
+		}
+	}
+
+	return errors
+}
+
+// hasBlank reports whether any value is empty or only whitespace
+func hasBlank(values []string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			return true
+		}
+	}
+	return false
+}
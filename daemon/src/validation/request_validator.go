@@ -9,6 +9,7 @@ import (
 type RequestValidator struct {
 	referenceValidator *ReferenceValidator
 	promptValidator    *PromptValidator
+	relationValidator  *RelationValidator
 	errorFormatter     *ErrorFormatter
 }
 
@@ -16,6 +17,7 @@ func NewRequestValidator() *RequestValidator {
 	return &RequestValidator{
 		referenceValidator: NewReferenceValidator(),
 		promptValidator:    NewPromptValidator(),
+		relationValidator:  NewRelationValidator(),
 		errorFormatter:     NewErrorFormatter(true), // Use color output
 	}
 }
@@ -120,6 +122,16 @@ func (rv *RequestValidator) ValidatePromptAndReferences(prompt string, reference
 	}
 }
 
+// ValidateRelation validates a relation's properties against the schema for
+// its type, reporting every problem at once
+func (rv *RequestValidator) ValidateRelation(relationType string, properties map[string]interface{}) ValidationResult {
+	errors := rv.relationValidator.ValidateRelation(relationType, properties)
+	return ValidationResult{
+		Valid:  len(errors) == 0,
+		Errors: errors,
+	}
+}
+
 // FormatErrors formats validation errors for user display
 func (rv *RequestValidator) FormatErrors(errors []ValidationError) string {
 	return rv.errorFormatter.FormatValidationErrors(errors)
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket transport (PORT42_WS_PORT). Each text message a client sends is
+// one Request envelope and gets its Response back as a text message, exactly
+// as on the TCP port, but any number of requests can share one socket and
+// run concurrently; clients match responses to requests by ID. Streaming
+// possess sends its chunk frames the same way. The TCP listener is unchanged.
+//
+// Browsers can reach localhost from any page, so upgrades carrying an
+// Origin header are only accepted from localhost pages or the origins in
+// PORT42_WS_ORIGINS. Set PORT42_AUTH_TOKEN as well when exposing it to
+// browser clients.
+
+// wsGUID is the fixed key suffix from RFC 6455 section 1.3
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessageSize bounds one client message, large enough for store_path
+// uploads
+const wsMaxMessageSize = 64 * 1024 * 1024
+
+// WebSocket opcodes
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// WebSocket close codes
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseTooBig        = 1009
+)
+
+// wsServer accepts WebSocket upgrades and tracks open sockets so Shutdown
+// can close them
+type wsServer struct {
+	daemon   *Daemon
+	server   *http.Server
+	origins  map[string]bool // Allowed Origin values beyond localhost (PORT42_WS_ORIGINS)
+	mu       sync.Mutex
+	conns    map[*wsConn]bool
+	requests sync.WaitGroup
+}
+
+// startWebSocket listens on PORT42_WS_PORT, if set, alongside the TCP port
+func (d *Daemon) startWebSocket() {
+	if d.config.WSPort == "" {
+		return
+	}
+
+	// Same address as the TCP port (PORT42_BIND_ADDR)
+	host := defaultBindAddr
+	if d.listener != nil {
+		if addr, ok := d.listener.Addr().(*net.TCPAddr); ok {
+			host = addr.IP.String()
+		}
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, d.config.WSPort))
+	if err != nil {
+		log.Printf("⚠️ WebSocket listener unavailable on port %s: %v", d.config.WSPort, err)
+		return
+	}
+
+	ws := &wsServer{
+		daemon:  d,
+		origins: make(map[string]bool),
+		conns:   make(map[*wsConn]bool),
+	}
+	for _, origin := range strings.Split(envString("PORT42_WS_ORIGINS", ""), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			ws.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+	ws.server = &http.Server{Handler: ws, ReadHeaderTimeout: 10 * time.Second}
+	d.ws = ws
+
+	log.Printf("🕸️ WebSocket listening on ws://localhost:%s", d.config.WSPort)
+	go func() {
+		if err := ws.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("⚠️ WebSocket listener stopped: %v", err)
+		}
+	}()
+}
+
+// Close stops accepting upgrades, closes every socket and waits for
+// in-flight requests to finish
+func (ws *wsServer) Close() {
+	ws.server.Shutdown(context.Background())
+
+	ws.mu.Lock()
+	for conn := range ws.conns {
+		conn.close(wsCloseNormal, "daemon shutting down")
+	}
+	ws.mu.Unlock()
+
+	ws.requests.Wait()
+}
+
+// allowsOrigin accepts non-browser clients (no Origin), localhost pages
+// and configured origins
+func (ws *wsServer) allowsOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	if ws.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch parsed.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// ServeHTTP performs the RFC 6455 opening handshake and then serves the socket
+func (ws *wsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Port 42 speaks WebSocket here", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusBadRequest)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	if origin := r.Header.Get("Origin"); !ws.allowsOrigin(origin) {
+		log.Printf("🚫 Rejected WebSocket upgrade from origin %s", origin)
+		http.Error(w, "Origin not allowed (PORT42_WS_ORIGINS)", http.StatusForbidden)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket upgrade unavailable", http.StatusInternalServerError)
+		return
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("⚠️ WebSocket hijack failed: %v", err)
+		return
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return
+	}
+
+	conn := &wsConn{conn: netConn, reader: rw.Reader, idleTimeout: ws.daemon.config.ConnIdleTimeout}
+	ws.mu.Lock()
+	ws.conns[conn] = true
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		delete(ws.conns, conn)
+		ws.mu.Unlock()
+	}()
+
+	ws.serve(conn)
+}
+
+// headerContains reports whether a comma-separated header lists token
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serve reads request messages until the socket closes, handling each in
+// its own goroutine
+func (ws *wsServer) serve(conn *wsConn) {
+	clientAddr := conn.conn.RemoteAddr().String()
+	log.Printf("◊ WebSocket swimmer connected from %s", clientAddr)
+	defer log.Printf("◊ WebSocket swimmer disconnected: %s", clientAddr)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	conn.startKeepalive(ws.daemon.config.KeepaliveInterval, stop)
+
+	for {
+		message, err := conn.readMessage()
+		if err != nil {
+			var netErr net.Error
+			switch {
+			case errors.Is(err, errWSTooBig):
+				conn.close(wsCloseTooBig, "message too large")
+			case errors.Is(err, errWSProtocol):
+				log.Printf("⚠️ WebSocket protocol error from %s: %v", clientAddr, err)
+				conn.close(wsCloseProtocolError, err.Error())
+			case errors.As(err, &netErr) && netErr.Timeout():
+				log.Printf("◊ Idle WebSocket from %s timed out", clientAddr)
+				conn.close(wsCloseNormal, "idle timeout")
+			default:
+				conn.close(wsCloseNormal, "")
+			}
+			return
+		}
+
+		var req Request
+		if err := json.Unmarshal(message, &req); err != nil {
+			conn.sendResponse(NewErrorResponse("error", "Invalid JSON request"))
+			continue
+		}
+
+		switch req.Type {
+		case FramePing:
+			pong := NewResponse(req.ID, true)
+			pong.Frame = "pong"
+			conn.sendResponse(pong)
+			continue
+		case FramePong, FrameStop:
+			continue
+		}
+
+		ws.requests.Add(1)
+		go func() {
+			defer ws.requests.Done()
+			conn.sendResponse(ws.handle(conn, req))
+		}()
+	}
+}
+
+// handle routes one request through handleRequest, wiring up chunk frames
+// for streaming possess
+func (ws *wsServer) handle(conn *wsConn, req Request) Response {
+	if req.Type != "context" {
+		log.Printf("◊ WebSocket request [%s] type: %s", req.ID, req.Type)
+	}
+
+	if _, ok := wantsWatchStream(req); ok {
+		return NewErrorResponse(req.ID, "Streaming watch is only available on the TCP port")
+	}
+
+	streaming := wantsStream(req)
+	if streaming {
+		req.emit = func(content string) {
+			chunk := NewResponse(req.ID, true)
+			chunk.Frame = FrameChunk
+			chunk.SetData(StreamChunk{Content: content})
+			conn.sendResponse(chunk)
+		}
+	}
+
+	resp := ws.daemon.handleRequest(req)
+	if streaming {
+		resp.Frame = FrameComplete
+	}
+	return resp
+}
+
+var (
+	errWSProtocol = errors.New("websocket protocol error")
+	errWSTooBig   = errors.New("websocket message too large")
+	errWSClosed   = errors.New("websocket closed")
+)
+
+// wsConn is a server-side WebSocket connection
+type wsConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	writeMu     sync.Mutex
+	closeOnce   sync.Once
+	idleTimeout time.Duration
+}
+
+// readMessage returns the next complete text or binary message, answering
+// pings and reassembling fragments along the way. Every frame refreshes
+// the read deadline.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		if c.idleTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		}
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			c.writeFrame(wsOpPong, payload)
+		case wsOpPong:
+		case wsOpClose:
+			return nil, errWSClosed
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			if (opcode == wsOpContinuation) != fragmented {
+				return nil, fmt.Errorf("%w: unexpected continuation state", errWSProtocol)
+			}
+			if len(message)+len(payload) > wsMaxMessageSize {
+				return nil, errWSTooBig
+			}
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+			fragmented = true
+		default:
+			return nil, fmt.Errorf("%w: unknown opcode %d", errWSProtocol, opcode)
+		}
+	}
+}
+
+// readFrame reads and unmasks one frame
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return fin, opcode, nil, fmt.Errorf("%w: reserved bits set", errWSProtocol)
+	}
+	if header[1]&0x80 == 0 {
+		return fin, opcode, nil, fmt.Errorf("%w: client frames must be masked", errWSProtocol)
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if opcode >= wsOpClose && (length > 125 || !fin) {
+		return fin, opcode, nil, fmt.Errorf("%w: invalid control frame", errWSProtocol)
+	}
+	if length > wsMaxMessageSize {
+		return fin, opcode, nil, errWSTooBig
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes one unmasked, unfragmented frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// sendResponse writes a response as a text message; safe to call from
+// multiple goroutines
+func (c *wsConn) sendResponse(resp Response) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode WebSocket response [%s]: %v", resp.ID, err)
+		return
+	}
+	if err := c.writeFrame(wsOpText, data); err != nil {
+		log.Printf("⚠️ Failed to send WebSocket response [%s] to %s: %v", resp.ID, c.conn.RemoteAddr(), err)
+	}
+}
+
+// startKeepalive sends WebSocket pings every interval until stop is closed
+func (c *wsConn) startKeepalive(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.writeFrame(wsOpPing, nil); err != nil {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// close sends a close frame and closes the connection, once
+func (c *wsConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason[:min(len(reason), 123)]...)
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.writeFrame(wsOpClose, payload)
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// purge_agent lists an agent's relations, objects and sessions on a dry
+// run, removes their paths, symlinks and relations once confirmed, and
+// deletes the objects with delete_blobs, leaving other agents' work alone
+func TestPurgeAgent(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{
+		storage:         storage,
+		sessions:        make(map[string]*Session),
+		realityCompiler: NewRealityCompiler(relationStore, nil),
+	}
+	d.realityCompiler.ruleEngine = nil
+
+	install := func(name, agent string) string {
+		t.Helper()
+		id, err := storage.StoreWithMetadata([]byte("#!/bin/sh\necho "+name+"\n"), &Metadata{
+			Type:  "command",
+			Title: name,
+			Agent: agent,
+			Paths: []string{"/commands/" + name, "/tools/" + name + "/executable"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to store %s: %v", name, err)
+		}
+		relation := Relation{ID: "tool-" + name, Type: "Tool", Properties: map[string]interface{}{"name": name, "agent": agent, "executable_id": id}}
+		if err := relationStore.Save(relation); err != nil {
+			t.Fatalf("Failed to save %s: %v", name, err)
+		}
+		if err := storage.CreateCommandSymlink(id, name); err != nil {
+			t.Fatalf("Failed to link %s: %v", name, err)
+		}
+		return id
+	}
+	museTool := install("haiku-maker", "@ai-muse")
+	engineerTool := install("csv-parser", "@ai-engineer")
+	poem, err := storage.StoreWithMetadata([]byte("waves fold into foam"), &Metadata{
+		Type:  "artifact",
+		Title: "poem",
+		Agent: "ai-muse",
+		Paths: []string{"/artifacts/document/poem.md"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to store poem: %v", err)
+	}
+	session := &Session{ID: "muse-session", Agent: "@ai-muse", State: SessionIdle, Messages: []Message{{Role: "user", Content: "write a haiku"}}}
+	if err := storage.SaveSession(session); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	d.sessions[session.ID] = session
+
+	purge := func(payload map[string]interface{}) AgentPurgeReport {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		resp := d.handlePurgeAgent(Request{Type: "purge_agent", ID: "test", Payload: data})
+		if !resp.Success {
+			t.Fatalf("purge_agent failed: %s", resp.Error)
+		}
+		var report AgentPurgeReport
+		json.Unmarshal(resp.Data, &report)
+		return report
+	}
+
+	report := purge(map[string]interface{}{"agent": "AI-Muse"})
+	if !report.DryRun || report.Agent != "@ai-muse" {
+		t.Errorf("dry run report = dry_run %v, agent %s", report.DryRun, report.Agent)
+	}
+	if len(report.Relations) != 1 || report.Relations[0].ID != "tool-haiku-maker" {
+		t.Errorf("relations = %+v, want tool-haiku-maker", report.Relations)
+	}
+	if len(report.Objects) != 3 || len(report.Sessions) != 1 || !report.Sessions[0].InMemory || report.Sessions[0].Versions != 1 {
+		t.Errorf("objects = %d, sessions = %+v; want the tool, the poem and one session version", len(report.Objects), report.Sessions)
+	}
+	if _, err := relationStore.Load("tool-haiku-maker"); err != nil {
+		t.Errorf("dry run deleted the relation: %v", err)
+	}
+
+	report = purge(map[string]interface{}{"agent": "@ai-muse", "confirm": true})
+	if report.DryRun || len(report.Errors) != 0 {
+		t.Fatalf("purge = dry_run %v, errors %v", report.DryRun, report.Errors)
+	}
+	if len(report.DeletedRelations) != 1 || len(report.DeletedObjects) != 0 {
+		t.Errorf("deleted relations %v and objects %v, want the relation and no objects", report.DeletedRelations, report.DeletedObjects)
+	}
+	if _, err := os.Lstat(filepath.Join(baseDir, "commands", "haiku-maker")); !os.IsNotExist(err) {
+		t.Errorf("haiku-maker symlink left behind")
+	}
+	if _, err := os.Lstat(filepath.Join(baseDir, "commands", "csv-parser")); err != nil {
+		t.Errorf("csv-parser symlink removed: %v", err)
+	}
+	if storage.ResolvePath("/artifacts/document/poem.md") != "" || storage.ResolvePath("/commands/csv-parser") != engineerTool {
+		t.Errorf("paths after purge: poem %q, csv-parser %q", storage.ResolvePath("/artifacts/document/poem.md"), storage.ResolvePath("/commands/csv-parser"))
+	}
+	if _, ok := d.sessions[session.ID]; ok {
+		t.Errorf("session still held in memory")
+	}
+	if _, err := storage.LoadSession(session.ID); err == nil {
+		t.Errorf("session still loadable after purge")
+	}
+	if !storage.objects.Exists(museTool) || !storage.objects.Exists(poem) {
+		t.Errorf("objects deleted without delete_blobs")
+	}
+
+	report = purge(map[string]interface{}{"agent": "ai-muse", "confirm": true, "delete_blobs": true})
+	if len(report.DeletedObjects) != 3 || report.FreedBytes == 0 {
+		t.Errorf("deleted objects = %v (%d bytes), want the tool, the poem and the session", report.DeletedObjects, report.FreedBytes)
+	}
+	if storage.objects.Exists(museTool) || storage.objects.Exists(poem) || !storage.objects.Exists(engineerTool) {
+		t.Errorf("objects left after delete_blobs: muse tool %v, poem %v, engineer tool %v",
+			storage.objects.Exists(museTool), storage.objects.Exists(poem), storage.objects.Exists(engineerTool))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race: many agents updating and reading their last session at
+// once, with flushes landing in between. Every update is visible at once,
+// and after Close the file holds each agent's final session.
+func TestAgentSessionsConcurrentUpdates(t *testing.T) {
+	baseDir := t.TempDir()
+	sessions := NewAgentSessions(baseDir)
+	sessions.flushDelay = time.Millisecond
+
+	const agents = 8
+	const updates = 200
+
+	var wg sync.WaitGroup
+	for a := 0; a < agents; a++ {
+		agent := fmt.Sprintf("agent-%d", a)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				sessionID := fmt.Sprintf("%s-session-%d", agent, i)
+				if err := sessions.SetLastSession(agent, sessionID); err != nil {
+					t.Errorf("SetLastSession(%s) failed: %v", agent, err)
+					return
+				}
+				if got, _ := sessions.GetLastSession(agent); got != sessionID {
+					t.Errorf("GetLastSession(%s) = %q right after setting %q", agent, got, sessionID)
+					return
+				}
+				if i%50 == 0 {
+					if err := sessions.Flush(); err != nil {
+						t.Errorf("Flush failed: %v", err)
+					}
+				}
+			}
+			// Clearing someone else's session is a no-op
+			sessions.ClearLastSession(agent, "not-the-last-session")
+		}()
+	}
+	wg.Wait()
+
+	if err := sessions.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reloaded := NewAgentSessions(baseDir)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for a := 0; a < agents; a++ {
+		agent := fmt.Sprintf("agent-%d", a)
+		want := fmt.Sprintf("%s-session-%d", agent, updates-1)
+		if got, _ := reloaded.GetLastSession(agent); got != want {
+			t.Errorf("Persisted last session for %s = %q, want %q", agent, got, want)
+		}
+	}
+
+	// After Close, updates are written straight away
+	if err := sessions.SetLastSession("late-agent", "late-session"); err != nil {
+		t.Fatalf("SetLastSession after Close failed: %v", err)
+	}
+	data, err := os.ReadFile(sessions.filePath)
+	if err != nil {
+		t.Fatalf("Failed to read agent sessions: %v", err)
+	}
+	if !strings.Contains(string(data), "late-session") {
+		t.Errorf("Update after Close not written: %s", data)
+	}
+}
+
+// Updates reach disk through the pending flush without an explicit Flush
+func TestAgentSessionsDebouncedFlush(t *testing.T) {
+	baseDir := t.TempDir()
+	sessions := NewAgentSessions(baseDir)
+	sessions.flushDelay = 20 * time.Millisecond
+	defer sessions.Close()
+
+	sessions.SetLastSession("claude", "session-1")
+	sessions.SetLastSession("claude", "session-2")
+	if _, err := os.Stat(sessions.filePath); !os.IsNotExist(err) {
+		t.Errorf("agent_sessions.json written before the flush delay: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(sessions.filePath); err == nil && strings.Contains(string(data), "session-2") {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("Pending flush never wrote the update")
+}
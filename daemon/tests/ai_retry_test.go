@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// A retry whose backoff would pass the deadline isn't made, and the call
+// fails with the API error rather than a timeout; a deadline that expires
+// during an attempt is a timeout
+func TestRetryDeadline(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour, Deadline: time.Second}
+
+	rateLimited := errors.New("rate_limit_error: slow down")
+	calls := 0
+	err := policy.Do(context.Background(), "test", func(ctx context.Context) error {
+		calls++
+		return &retryableError{err: rateLimited}
+	})
+	if calls != 1 || !errors.Is(err, rateLimited) || errors.Is(err, ErrAITimeout) {
+		t.Errorf("%d calls, error %v; want one call failing with the API error", calls, err)
+	}
+
+	policy.Deadline = 20 * time.Millisecond
+	err = policy.Do(context.Background(), "test", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, ErrAITimeout) {
+		t.Errorf("error %v, want ErrAITimeout", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Import refuses relations whose ID or tool name would write outside the
+// relations and commands directories, and still imports the rest
+func TestImportRejectsUnsafeRelations(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	baseDir := filepath.Join(home, ".port42")
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	script := []byte("#!/bin/sh\necho pwned\n")
+	hash := sha256.Sum256(script)
+	executableID := hex.EncodeToString(hash[:])
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	add := func(name string, value interface{}) {
+		t.Helper()
+		data, ok := value.([]byte)
+		if !ok {
+			data, _ = json.Marshal(value)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		tw.Write(data)
+	}
+	add(archiveManifestName, ArchiveManifest{FormatVersion: archiveFormatVersion})
+	add("metadata/"+executableID+".json", Metadata{ID: executableID, Type: "command", Title: "ls"})
+	add("relations/evil-id.json", Relation{ID: "x/../../../foo", Type: "Artifact", Properties: map[string]interface{}{"name": "foo"}})
+	add("relations/evil-tool.json", Relation{ID: "tool-ls", Type: "Tool", Properties: map[string]interface{}{
+		"name":          "../../.local/bin/ls",
+		"executable_id": executableID,
+	}})
+	add("relations/good.json", Relation{ID: "tool-csv-parser", Type: "Tool", Properties: map[string]interface{}{"name": "csv-parser"}})
+	add("objects/"+executableID, script)
+	tw.Close()
+	gz.Close()
+
+	report, err := storage.Import(&buf, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if report.RelationsAdded != 1 || report.CommandsRelinked != 0 {
+		t.Errorf("added %d relations and relinked %d commands, want only tool-csv-parser", report.RelationsAdded, report.CommandsRelinked)
+	}
+	rejected := strings.Join(report.Rejected, "\n")
+	if !strings.Contains(rejected, "invalid relation ID") || !strings.Contains(rejected, "invalid tool name") {
+		t.Errorf("rejected = %v, want the bad ID and the bad tool name", report.Rejected)
+	}
+	if _, err := relationStore.Load("tool-ls"); err == nil {
+		t.Errorf("tool with an unsafe name was saved")
+	}
+	for _, escaped := range []string{
+		filepath.Join(home, ".local", "bin", "ls"),
+		filepath.Join(baseDir, "foo.json"),
+		filepath.Join(home, "foo.json"),
+		filepath.Join(filepath.Dir(home), "foo.json"),
+	} {
+		if _, err := os.Lstat(escaped); err == nil {
+			t.Errorf("import wrote %s", escaped)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func TestArtifactManifest(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	for path, content := range map[string]string{
+		"/artifacts/app/site/index.html":  "<html></html>",
+		"/artifacts/app/site/js/app.js":   "console.log('hi')",
+		"/artifacts/app/site/README.md":   "# Site",
+		"/artifacts/app/sitemap/notes.md": "# Not part of site",
+	} {
+		if _, err := storage.HandleStorePath(path, []byte(content), map[string]interface{}{"artifact_name": "site"}); err != nil {
+			t.Fatalf("Failed to store %s: %v", path, err)
+		}
+	}
+
+	manifest, err := storage.ArtifactManifest("app", "site", nil)
+	if err != nil {
+		t.Fatalf("ArtifactManifest: %v", err)
+	}
+	var paths, types []string
+	for _, file := range manifest.Files {
+		paths = append(paths, file.Path)
+		types = append(types, file.Type)
+	}
+	if !sameStrings(paths, []string{"README.md", "index.html", "js/app.js"}) || !sameStrings(types, []string{"document", "web", "code"}) {
+		t.Errorf("files = %v, types = %v", paths, types)
+	}
+	if manifest.Count != 3 || manifest.TotalSize != int64(len("<html></html>")+len("console.log('hi')")+len("# Site")) {
+		t.Errorf("count = %d, total_size = %d", manifest.Count, manifest.TotalSize)
+	}
+
+	code, err := storage.ArtifactManifest("app", "site", []string{"code", "text/html"})
+	if err != nil || code.Count != 2 {
+		t.Fatalf("content type filter: %+v, %v", code, err)
+	}
+	if _, err := storage.ArtifactManifest("app", "missing", nil); err == nil {
+		t.Errorf("missing artifact has a manifest")
+	}
+
+	var buf bytes.Buffer
+	if err := storage.WriteArtifactBundle(&buf, code); err != nil {
+		t.Fatalf("WriteArtifactBundle: %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("bundle is not gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	bundled := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading bundle: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		bundled[header.Name] = string(content)
+	}
+	if len(bundled) != 2 || bundled["site/js/app.js"] != "console.log('hi')" || bundled["site/index.html"] != "<html></html>" {
+		t.Errorf("bundle = %v", bundled)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+// Mutating requests land in the audit log, successful or not, and
+// get_audit returns them newest first with filters applied
+func TestAuditLog(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{
+		storage:  storage,
+		sessions: make(map[string]*Session),
+		audit:    NewAuditLog(baseDir),
+	}
+	send := func(reqType string, payload interface{}) Response {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		return d.handleRequest(Request{
+			Type:           reqType,
+			ID:             "req-" + reqType,
+			Payload:        data,
+			SessionContext: &SessionContext{SessionID: "session-1", Agent: "@ai-engineer"},
+		})
+	}
+	audit := func(payload map[string]interface{}) []AuditEntry {
+		t.Helper()
+		resp := send("get_audit", payload)
+		if !resp.Success {
+			t.Fatalf("get_audit failed: %s", resp.Error)
+		}
+		var data struct {
+			Entries []AuditEntry `json:"entries"`
+		}
+		json.Unmarshal(resp.Data, &data)
+		return data.Entries
+	}
+
+	content := base64.StdEncoding.EncodeToString([]byte("# Notes\n"))
+	if resp := send("store_path", map[string]interface{}{"path": "/artifacts/document/notes.md", "content": content}); !resp.Success {
+		t.Fatalf("store_path failed: %s", resp.Error)
+	}
+	send("move_path", map[string]interface{}{"old_path": "/artifacts/document/notes.md", "new_path": "/artifacts/document/moved.md"})
+	send("delete_path", map[string]interface{}{"path": "/artifacts/document/missing.md"})
+	send("list_path", map[string]interface{}{"path": "/artifacts"})
+
+	entries := audit(nil)
+	if len(entries) != 3 {
+		t.Fatalf("Got %d audit entries, want store, move and delete but not list: %+v", len(entries), entries)
+	}
+	if entries[0].Operation != "delete_path" || entries[0].Success || entries[0].Error == "" {
+		t.Errorf("Newest entry = %+v, want the failed delete", entries[0])
+	}
+	move := entries[1]
+	if move.Target != "/artifacts/document/notes.md" || move.Details["new_path"] != "/artifacts/document/moved.md" {
+		t.Errorf("Move entry = %+v", move)
+	}
+	if move.Agent != "@ai-engineer" || move.Session != "session-1" || move.RequestID != "req-move_path" {
+		t.Errorf("Move entry not attributed to its requester: %+v", move)
+	}
+	if entries[2].Details["id"] == nil {
+		t.Errorf("Store entry lacks the stored object ID: %+v", entries[2])
+	}
+
+	if got := audit(map[string]interface{}{"failed_only": true}); len(got) != 1 {
+		t.Errorf("failed_only returned %d entries", len(got))
+	}
+	if got := audit(map[string]interface{}{"operation": "store_path", "since": "1h"}); len(got) != 1 || got[0].Operation != "store_path" {
+		t.Errorf("Filtering by operation returned %+v", got)
+	}
+	if got := audit(map[string]interface{}{"target": "/commands/"}); len(got) != 0 {
+		t.Errorf("Target prefix /commands/ matched %+v", got)
+	}
+	if got := audit(map[string]interface{}{"limit": 1}); len(got) != 1 {
+		t.Errorf("limit 1 returned %d entries", len(got))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// A batch without a limit changes every match, however many there are; one
+// with a limit stops there and says it was truncated
+func TestBatchOpAppliesToEveryMatch(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	const objects = 250
+	for i := 0; i < objects; i++ {
+		if _, err := storage.StoreWithMetadata([]byte(fmt.Sprintf("note %d", i)), &Metadata{
+			Type:  "artifact",
+			Paths: []string{fmt.Sprintf("/artifacts/document/note-%d.md", i)},
+			Tags:  []string{"scratch"},
+		}); err != nil {
+			t.Fatalf("Failed to store note %d: %v", i, err)
+		}
+	}
+
+	selector := BatchSelector{Filters: SearchFilters{Tags: []string{"scratch"}}}
+	result, err := storage.HandleBatchOp(selector, BatchAddTags, []string{"reviewed"}, "", true)
+	if err != nil {
+		t.Fatalf("batch_op failed: %v", err)
+	}
+	if result["count"] != objects || result["total_matches"] != objects || result["truncated"] != false {
+		t.Errorf("count %v, total_matches %v, truncated %v; want all %d changed", result["count"], result["total_matches"], result["truncated"], objects)
+	}
+	_, total, err := storage.SearchObjectsPage("", "or", SearchFilters{Tags: []string{"reviewed"}, Limit: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if total != objects {
+		t.Errorf("%d objects tagged reviewed, want %d", total, objects)
+	}
+
+	selector.Filters.Limit = 10
+	result, err = storage.HandleBatchOp(selector, BatchPin, nil, "", false)
+	if err != nil {
+		t.Fatalf("batch_op failed: %v", err)
+	}
+	if result["count"] != 10 || result["total_matches"] != objects || result["truncated"] != true {
+		t.Errorf("count %v, total_matches %v, truncated %v; want 10 of %d, truncated", result["count"], result["total_matches"], result["truncated"], objects)
+	}
+}
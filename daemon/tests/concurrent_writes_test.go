@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// versionMaterializer stands in for the tool materializer without calling
+// an AI provider: it stores the tool's executable and records it as a new
+// version, which is the write sequence concurrent declares race on
+type versionMaterializer struct {
+	storage *Storage
+}
+
+func (m *versionMaterializer) CanMaterialize(relation Relation) bool {
+	return relation.Type == "Tool"
+}
+
+func (m *versionMaterializer) Materialize(relation Relation) (*MaterializedEntity, error) {
+	code := fmt.Sprintf("#!/bin/sh\necho %v\n", relation.Properties["variant"])
+	id, err := m.storage.StoreWithMetadata([]byte(code), &Metadata{
+		Type:  "command",
+		Title: getRelationName(relation),
+		Paths: []string{"/commands/" + getRelationName(relation)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := m.storage.RecordToolVersion(getRelationName(relation), id, ""); err != nil {
+		return nil, err
+	}
+	return &MaterializedEntity{RelationID: relation.ID, Status: MaterializedSuccess}, nil
+}
+
+func (m *versionMaterializer) Dematerialize(entity *MaterializedEntity) error {
+	return nil
+}
+
+// Run with -race: declares of similar tools, same-content stores and
+// similarity linking all happen at once
+func TestConcurrentDeclaresOfSimilarTools(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	compiler := NewRealityCompiler(relationStore, []Materializer{&versionMaterializer{storage: storage}})
+	similarity := NewSimilarityCalculator(relationStore, defaultSimilarityConfig())
+
+	// Every tool transforms the same way, so they are all similar
+	tool := func(name string, variant int) Relation {
+		return Relation{
+			ID:   "tool-" + name,
+			Type: "Tool",
+			Properties: map[string]interface{}{
+				"name":       name,
+				"transforms": []string{"csv", "json", "convert"},
+				"variant":    variant,
+			},
+		}
+	}
+
+	const declares = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, declares*3)
+	for i := 0; i < declares; i++ {
+		wg.Add(3)
+
+		// The same tool declared again and again with new code
+		go func(i int) {
+			defer wg.Done()
+			relation := tool("csv-to-json", i)
+			if _, err := compiler.DeclareRelation(relation); err != nil {
+				errs <- fmt.Errorf("declare %d: %w", i, err)
+				return
+			}
+			if err := similarity.createSimilarityRelationshipsForTools([]Relation{relation}, 0.1); err != nil {
+				errs <- fmt.Errorf("similarity %d: %w", i, err)
+			}
+		}(i)
+
+		// Distinct but similar tools
+		go func(i int) {
+			defer wg.Done()
+			if _, err := compiler.DeclareRelation(tool(fmt.Sprintf("csv-json-%d", i), i)); err != nil {
+				errs <- fmt.Errorf("declare similar %d: %w", i, err)
+			}
+		}(i)
+
+		// Identical content stored alongside
+		go func(i int) {
+			defer wg.Done()
+			if _, err := storage.StoreWithMetadata([]byte("shared content"), &Metadata{Type: "file", Title: "shared"}); err != nil {
+				errs <- fmt.Errorf("store %d: %w", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// No declare may lose another's version
+	stored, err := relationStore.Load("tool-csv-to-json")
+	if err != nil {
+		t.Fatalf("Failed to load tool: %v", err)
+	}
+	if versions := toolVersions(*stored); len(versions) != declares {
+		t.Errorf("Expected %d versions, got %d", declares, len(versions))
+	}
+
+	// Every relation file must still parse
+	relations, err := relationStore.List()
+	if err != nil {
+		t.Fatalf("Failed to list relations: %v", err)
+	}
+	tools := 0
+	for _, relation := range relations {
+		if relation.Type == "Tool" {
+			tools++
+		}
+	}
+	if tools != declares+1 {
+		t.Errorf("Expected %d tools, got %d", declares+1, tools)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Declares naming an agent agents.json doesn't have are warned about, or
+// rejected under the reject policy, and tools without one get the default
+func TestCheckDeclaredAgent(t *testing.T) {
+	saved := agentConfig
+	defer func() { agentConfig = saved }()
+	agentConfig = &AgentConfig{Agents: map[string]Agent{
+		"engineer": {Name: "@ai-engineer"},
+		"muse":     {Name: "@ai-muse"},
+	}}
+
+	tool := func(agent string) Relation {
+		properties := map[string]interface{}{"name": "csv-parser"}
+		if agent != "" {
+			properties["agent"] = agent
+		}
+		return Relation{Type: "Tool", Properties: properties}
+	}
+
+	d := &Daemon{config: Config{UnknownAgents: UnknownAgentsWarn}}
+	for _, agent := range []string{"", "@ai-engineer", "muse"} {
+		if warning, err := d.checkDeclaredAgent(tool(agent)); warning != "" || err != nil {
+			t.Errorf("%q: warning %q, error %v; want neither", agent, warning, err)
+		}
+	}
+	warning, err := d.checkDeclaredAgent(tool("@ai-enginer"))
+	if err != nil || !strings.Contains(warning, "@ai-enginer") || !strings.Contains(warning, "@ai-engineer, @ai-muse") {
+		t.Errorf("warn policy: warning %q, error %v", warning, err)
+	}
+
+	d.config.UnknownAgents = UnknownAgentsReject
+	_, err = d.checkDeclaredAgent(tool("@ai-enginer"))
+	var invalid *relationValidationError
+	if !errors.As(err, &invalid) || len(invalid.errors) != 1 || invalid.errors[0].Code != "UNKNOWN_AGENT" {
+		t.Errorf("reject policy: error %v, want an UNKNOWN_AGENT validation error", err)
+	}
+
+	if got := d.defaultAgent(); got != "@ai-engineer" {
+		t.Errorf("default agent = %s, want @ai-engineer", got)
+	}
+	d.config.DefaultAgent = "@ai-muse"
+	if got := d.defaultAgent(); got != "@ai-muse" {
+		t.Errorf("default agent = %s, want the configured @ai-muse", got)
+	}
+}
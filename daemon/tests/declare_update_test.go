@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Re-declaring a tool with a p42: reference to itself must update the
+// existing relation, not create a second tool with the same name
+func TestRedeclareWithSelfReferenceUpdatesTool(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{
+		storage:         storage,
+		realityCompiler: NewRealityCompiler(relationStore, []Materializer{&versionMaterializer{storage: storage}}),
+		auth:            loadAuthConfig(),
+	}
+
+	declare := func(variant int, references []Reference) map[string]interface{} {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"relation": map[string]interface{}{
+				"type": "Tool",
+				"properties": map[string]interface{}{
+					"name":        "video-splicer",
+					"description": "Splice video clips",
+					"transforms":  []string{"video", "splice"},
+					"variant":     variant,
+				},
+			},
+		})
+		resp := d.handleDeclareRelation(Request{Type: "declare_relation", ID: "test", Payload: payload, References: references})
+		if !resp.Success {
+			t.Fatalf("Declare %d failed: %s", variant, resp.Error)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return data
+	}
+
+	first := declare(1, nil)
+	if first["updated"] != false {
+		t.Errorf("First declare reported updated=%v", first["updated"])
+	}
+
+	second := declare(2, []Reference{{Type: "p42", Target: "/commands/video-splicer"}})
+	if second["relation_id"] != first["relation_id"] {
+		t.Errorf("Re-declare created %v instead of updating %v", second["relation_id"], first["relation_id"])
+	}
+	if second["updated"] != true {
+		t.Errorf("Re-declare reported updated=%v", second["updated"])
+	}
+
+	tools, err := relationStore.LoadByType("Tool")
+	if err != nil {
+		t.Fatalf("Failed to load tools: %v", err)
+	}
+	if len(tools) != 1 {
+		t.Fatalf("Expected 1 tool, got %d", len(tools))
+	}
+	if versions := toolVersions(tools[0]); len(versions) != 2 {
+		t.Errorf("Expected both executables in the history, got %d versions", len(versions))
+	}
+
+	// A reference to a different tool still declares a new one
+	payload, _ := json.Marshal(map[string]interface{}{
+		"relation": map[string]interface{}{
+			"type":       "Tool",
+			"properties": map[string]interface{}{"name": "clip-trimmer", "description": "Trim clips", "transforms": []string{"video", "trim"}},
+		},
+	})
+	resp := d.handleDeclareRelation(Request{Type: "declare_relation", ID: "test", Payload: payload,
+		References: []Reference{{Type: "p42", Target: "/commands/video-splicer"}}})
+	if !resp.Success {
+		t.Fatalf("Declare of clip-trimmer failed: %s", resp.Error)
+	}
+	if tools, _ := relationStore.LoadByType("Tool"); len(tools) != 2 {
+		t.Errorf("Expected 2 tools, got %d", len(tools))
+	}
+}
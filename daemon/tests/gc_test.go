@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// GC deletes superseded and unreferenced objects past the grace period and
+// keeps ones a command store file, a session version or a tool's version
+// history still refers to
+func TestGC(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	store := func(content string, meta *Metadata) string {
+		t.Helper()
+		var id string
+		var err error
+		if meta == nil {
+			id, err = storage.Store([]byte(content))
+		} else {
+			id, err = storage.StoreWithMetadata([]byte(content), meta)
+		}
+		if err != nil {
+			t.Fatalf("Failed to store %q: %v", content, err)
+		}
+		return id
+	}
+
+	// Superseded: a newer object took over its only path
+	const notesPath = "/artifacts/document/notes.md"
+	superseded := store("notes v1", &Metadata{Type: "artifact", Paths: []string{notesPath}})
+	time.Sleep(10 * time.Millisecond)
+	current := store("notes v2", &Metadata{Type: "artifact", Paths: []string{notesPath}})
+	unreferenced := store("scratch", nil)
+
+	// A command known only through its command store file
+	command := store("#!/bin/sh\necho hi\n", &Metadata{Type: "command"})
+	if err := storage.CreateCommandSymlink(command, "hi"); err != nil {
+		t.Fatalf("Failed to link command: %v", err)
+	}
+
+	// A tool's earlier version, kept only by its history
+	if err := relationStore.Save(Relation{ID: "tool-versioned", Type: "Tool", Properties: map[string]interface{}{"name": "versioned"}}); err != nil {
+		t.Fatalf("Failed to save relation: %v", err)
+	}
+	oldVersion := store("#!/bin/sh\necho v1\n", nil)
+	newVersion := store("#!/bin/sh\necho v2\n", nil)
+	for _, id := range []string{oldVersion, newVersion} {
+		if err := storage.RecordToolVersion("versioned", id, ""); err != nil {
+			t.Fatalf("Failed to record version: %v", err)
+		}
+	}
+
+	// An older version of a session the index no longer points at
+	session := &Session{ID: "gc-session", Agent: "@ai-engineer", State: SessionIdle, Messages: []Message{{Role: "user", Content: "first"}}}
+	if err := storage.SaveSession(session); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	storage.indexMutex.RLock()
+	firstVersion := storage.sessionIndex.Sessions[session.ID].ObjectID
+	storage.indexMutex.RUnlock()
+	session.Messages = append(session.Messages, Message{Role: "assistant", Content: "second"})
+	if err := storage.SaveSession(session); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	storage.indexMutex.RLock()
+	latestVersion := storage.sessionIndex.Sessions[session.ID].ObjectID
+	storage.indexMutex.RUnlock()
+	if firstVersion == "" || firstVersion == latestVersion {
+		t.Fatalf("session versions %q and %q, want two different objects", firstVersion, latestVersion)
+	}
+
+	// Everything is past the grace period
+	ids, err := storage.List()
+	if err != nil {
+		t.Fatalf("Failed to list objects: %v", err)
+	}
+	old := time.Now().Add(-2 * gcGracePeriod)
+	for _, id := range ids {
+		if err := os.Chtimes(storage.GetPath(id), old, old); err != nil {
+			t.Fatalf("Failed to backdate %s: %v", id, err)
+		}
+	}
+
+	report, err := storage.GC(false)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	reasons := make(map[string]string)
+	for _, orphan := range report.Orphans {
+		reasons[orphan.ID] = orphan.Reason
+	}
+	if reasons[superseded] != "superseded" || reasons[unreferenced] != "no_metadata" || len(report.Orphans) != 2 {
+		t.Errorf("orphans = %+v, want the superseded notes and the unreferenced scratch", report.Orphans)
+	}
+	if report.DeletedObjects != 2 || storage.objects.Exists(superseded) || storage.objects.Exists(unreferenced) {
+		t.Errorf("deleted %d objects; superseded left %v, scratch left %v",
+			report.DeletedObjects, storage.objects.Exists(superseded), storage.objects.Exists(unreferenced))
+	}
+	for name, id := range map[string]string{
+		"current notes":       current,
+		"command":             command,
+		"old tool version":    oldVersion,
+		"new tool version":    newVersion,
+		"first session saved": firstVersion,
+	} {
+		if !storage.objects.Exists(id) {
+			t.Errorf("GC deleted the %s", name)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Message filters return each matching message, limited by role, session
+// agent and when it was sent
+func TestMessageSearchFilters(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	now := time.Now()
+	lastWeek := now.AddDate(0, 0, -5)
+	sessions := []*Session{
+		{ID: "engineer-session", Agent: "@ai-engineer", State: SessionIdle, CreatedAt: lastWeek, Messages: []Message{
+			{Role: "user", Content: "write a csv parser", Timestamp: lastWeek},
+			{Role: "assistant", Content: "here is the csv parser", Timestamp: lastWeek.Add(time.Minute)},
+			{Role: "assistant", Content: "the parser now streams", Timestamp: lastWeek.Add(2 * time.Minute)},
+		}},
+		{ID: "old-session", Agent: "@ai-engineer", State: SessionIdle, CreatedAt: now.AddDate(0, -2, 0), Messages: []Message{
+			{Role: "assistant", Content: "an old parser", Timestamp: now.AddDate(0, -2, 0)},
+		}},
+		{ID: "muse-session", Agent: "@ai-muse", State: SessionIdle, CreatedAt: lastWeek, Messages: []Message{
+			{Role: "assistant", Content: "a parser of dreams", Timestamp: lastWeek},
+		}},
+	}
+	for _, session := range sessions {
+		if err := storage.SaveSession(session); err != nil {
+			t.Fatalf("Failed to save %s: %v", session.ID, err)
+		}
+	}
+
+	filters := SearchFilters{
+		Agent:        "ai-engineer",
+		MessageRole:  "assistant",
+		MessageAfter: now.AddDate(0, 0, -7),
+	}
+	results, total, err := storage.SearchObjectsPage("parser", "or", filters)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("got %d results, want the two assistant messages of engineer-session: %+v", total, results)
+	}
+	for _, result := range results {
+		if result.Message == nil || result.Message.SessionID != "engineer-session" || result.Message.Role != "assistant" {
+			t.Errorf("unexpected result %+v", result.Message)
+		}
+	}
+	if results[0].Message.Index != 2 || results[1].Message.Index != 1 {
+		t.Errorf("message order = %d, %d; want the newest first", results[0].Message.Index, results[1].Message.Index)
+	}
+
+	// No query lists every message the filters allow
+	results, _, err = storage.SearchObjectsPage("", "or", SearchFilters{MessageRole: "user"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Message.Index != 0 || results[0].Score != 1 {
+		t.Errorf("user messages = %+v, want the one user message scored 1", results)
+	}
+
+	if _, _, err := storage.SearchObjectsPage("parser", SearchModeFuzzy, filters); err == nil {
+		t.Errorf("fuzzy mode accepted message filters")
+	}
+}
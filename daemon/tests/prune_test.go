@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Prune deletes old deprecated objects and abandoned sessions, keeps what a
+// relation still refers to, and changes nothing on a dry run
+func TestPrune(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	longAgo := time.Now().AddDate(0, -2, 0)
+	deprecated := func(content string) string {
+		t.Helper()
+		id, err := storage.StoreWithMetadata([]byte(content), &Metadata{Type: "artifact", Title: content})
+		if err != nil {
+			t.Fatalf("Failed to store %s: %v", content, err)
+		}
+		meta, err := storage.LoadMetadata(id)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", content, err)
+		}
+		meta.Lifecycle = "deprecated"
+		meta.Modified = longAgo
+		if err := storage.writeMetadata(meta); err != nil {
+			t.Fatalf("Failed to backdate %s: %v", content, err)
+		}
+		return id
+	}
+	stale := deprecated("stale notes")
+	executable := deprecated("#!/bin/sh\necho still installed\n")
+	if err := relationStore.Save(Relation{ID: "tool-installed", Type: "Tool", Properties: map[string]interface{}{
+		"name":          "installed",
+		"executable_id": executable,
+	}}); err != nil {
+		t.Fatalf("Failed to save relation: %v", err)
+	}
+	fresh, err := storage.StoreWithMetadata([]byte("fresh notes"), &Metadata{Type: "artifact", Lifecycle: "deprecated"})
+	if err != nil {
+		t.Fatalf("Failed to store fresh notes: %v", err)
+	}
+
+	session := &Session{ID: "abandoned-session", Agent: "@ai-muse", State: SessionAbandoned, CreatedAt: longAgo,
+		Messages: []Message{{Role: "user", Content: "hello?", Timestamp: longAgo}}}
+	if err := storage.SaveSession(session); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	storage.indexMutex.Lock()
+	ref := storage.sessionIndex.Sessions[session.ID]
+	ref.LastUpdated = longAgo
+	storage.sessionIndex.Sessions[session.ID] = ref
+	storage.indexMutex.Unlock()
+	sessionObject := ref.ObjectID
+
+	exists := func(id string) bool {
+		_, err := os.Stat(filepath.Join(storage.metadataDir, id+".json"))
+		return err == nil && storage.objects.Exists(id)
+	}
+
+	report, err := storage.Prune(30*24*time.Hour, nil, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(report.Objects) != 1 || report.Objects[0].ID != stale || len(report.Sessions) != 1 || report.Sessions[0].Versions != 1 {
+		t.Errorf("dry run objects %+v, sessions %+v; want the stale notes and the abandoned session", report.Objects, report.Sessions)
+	}
+	if len(report.KeptReferenced) != 1 || report.KeptReferenced[0] != executable {
+		t.Errorf("kept referenced = %v, want the tool's executable", report.KeptReferenced)
+	}
+	if report.DeletedObjects != 0 || report.FreedBytes == 0 {
+		t.Errorf("dry run deleted %d objects, would free %d bytes", report.DeletedObjects, report.FreedBytes)
+	}
+	if !exists(stale) || !exists(sessionObject) {
+		t.Fatalf("dry run removed files")
+	}
+	if _, err := storage.LoadSession(session.ID); err != nil {
+		t.Fatalf("dry run removed the session: %v", err)
+	}
+
+	report, err = storage.Prune(30*24*time.Hour, nil, false)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if report.DeletedObjects != 2 {
+		t.Errorf("deleted %d objects, want the stale notes and the session version", report.DeletedObjects)
+	}
+	if exists(stale) || exists(sessionObject) {
+		t.Errorf("stale notes or session version left behind")
+	}
+	if !exists(executable) || !exists(fresh) {
+		t.Errorf("pruned the referenced executable or the recently deprecated notes")
+	}
+	storage.indexMutex.RLock()
+	_, indexed := storage.sessionIndex.Sessions[session.ID]
+	storage.indexMutex.RUnlock()
+	if indexed {
+		t.Errorf("abandoned session still in the session index")
+	}
+}
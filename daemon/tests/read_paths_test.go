@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// read_paths reads each path like read_path, reporting failures and the
+// paths left out to respect the response size cap per path
+func TestReadPaths(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	for path, content := range map[string]string{
+		"/artifacts/document/small.md": "# Small\n",
+		"/artifacts/document/large.md": strings.Repeat("large ", 2000),
+	} {
+		if _, err := storage.StoreWithMetadata([]byte(content), &Metadata{Type: "document", Paths: []string{path}}); err != nil {
+			t.Fatalf("Failed to store %s: %v", path, err)
+		}
+	}
+
+	d := &Daemon{storage: storage, sessions: make(map[string]*Session)}
+	read := func(maxSize int, paths ...string) map[string]interface{} {
+		t.Helper()
+		d.config.MaxResponseSize = maxSize
+		payload, _ := json.Marshal(map[string]interface{}{"paths": paths, "encoding": "utf8"})
+		resp := d.handleReadPaths(Request{Type: "read_paths", ID: "test", Payload: payload})
+		if !resp.Success {
+			t.Fatalf("read_paths failed: %s", resp.Error)
+		}
+		var data map[string]interface{}
+		json.Unmarshal(resp.Data, &data)
+		return data
+	}
+	entry := func(data map[string]interface{}, path string) map[string]interface{} {
+		entry, _ := data["results"].(map[string]interface{})[path].(map[string]interface{})
+		return entry
+	}
+
+	data := read(0, "/artifacts/document/small.md", "/artifacts/document/missing.md", "/artifacts/document/small.md")
+	if got := entry(data, "/artifacts/document/small.md")["content"]; got != "# Small\n" {
+		t.Errorf("small.md content = %v", got)
+	}
+	if entry(data, "/artifacts/document/missing.md")["error"] == nil {
+		t.Errorf("Missing path has no error: %v", entry(data, "/artifacts/document/missing.md"))
+	}
+	if data["count"] != 2.0 || data["failed"] != 1.0 {
+		t.Errorf("count = %v, failed = %v, want 2 and 1", data["count"], data["failed"])
+	}
+
+	data = read(4096, "/artifacts/document/small.md", "/artifacts/document/large.md")
+	if entry(data, "/artifacts/document/small.md")["content"] == nil {
+		t.Errorf("small.md left out under the cap: %v", data)
+	}
+	if errMsg, _ := entry(data, "/artifacts/document/large.md")["error"].(string); !strings.HasPrefix(errMsg, "RESPONSE_TOO_LARGE") {
+		t.Errorf("large.md error = %q, want RESPONSE_TOO_LARGE", errMsg)
+	}
+	if skipped, _ := data["skipped"].([]interface{}); len(skipped) != 1 {
+		t.Errorf("skipped = %v, want large.md", data["skipped"])
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"port42/daemon/validation"
+)
+
+// Edges implied by a tool's depends_on and references are stored, get_graph
+// walks them in either direction, and deleting a tool removes them
+func TestRelationGraphEdges(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{
+		storage:         storage,
+		realityCompiler: NewRealityCompiler(relationStore, []Materializer{&versionMaterializer{storage: storage}}),
+		auth:            loadAuthConfig(),
+		validator:       validation.NewRequestValidator(),
+	}
+	d.realityCompiler.ruleEngine = nil
+
+	declare := func(properties map[string]interface{}, references []Reference) string {
+		t.Helper()
+		payload, _ := json.Marshal(map[string]interface{}{
+			"relation": map[string]interface{}{"type": "Tool", "properties": properties},
+		})
+		resp := d.handleDeclareRelation(Request{Type: "declare_relation", ID: "test", Payload: payload, References: references})
+		if !resp.Success {
+			t.Fatalf("Declare of %v failed: %s", properties["name"], resp.Error)
+		}
+		var data map[string]interface{}
+		json.Unmarshal(resp.Data, &data)
+		id, _ := data["relation_id"].(string)
+		return id
+	}
+	graph := func(id string, depth int, direction string) RelationGraph {
+		t.Helper()
+		payload, _ := json.Marshal(map[string]interface{}{"id": id, "depth": depth, "direction": direction})
+		resp := d.handleGetGraph(Request{Type: "get_graph", ID: "test", Payload: payload})
+		if !resp.Success {
+			t.Fatalf("get_graph %s failed: %s", id, resp.Error)
+		}
+		var result RelationGraph
+		if err := json.Unmarshal(resp.Data, &result); err != nil {
+			t.Fatalf("Failed to decode graph: %v", err)
+		}
+		return result
+	}
+	names := func(g RelationGraph) []string {
+		var found []string
+		for _, node := range g.Nodes {
+			found = append(found, node.Name)
+		}
+		sort.Strings(found)
+		return found
+	}
+
+	parser := declare(map[string]interface{}{"name": "csv-parser", "description": "Parse CSV", "transforms": []string{"csv"}}, nil)
+	summary := declare(map[string]interface{}{"name": "csv-summary", "description": "Summarize CSV", "transforms": []string{"csv"}, "depends_on": "csv-parser"}, nil)
+	report := declare(map[string]interface{}{"name": "csv-report", "description": "Report on CSV", "transforms": []string{"report"}},
+		[]Reference{{Type: "tool", Target: "csv-summary"}})
+
+	// What breaks if csv-parser goes: csv-summary directly, csv-report through it
+	impact := graph("csv-parser", 2, "in")
+	if got := names(impact); len(got) != 3 || got[0] != "csv-parser" || got[1] != "csv-report" || got[2] != "csv-summary" {
+		t.Fatalf("Nodes depending on csv-parser = %v", got)
+	}
+	types := map[string]string{}
+	for _, edge := range impact.Edges {
+		types[edge.From+">"+edge.To] = edge.Type
+	}
+	if types[summary+">"+parser] != EdgeDependsOn || types[report+">"+summary] != EdgeReferences {
+		t.Errorf("Edges = %v", impact.Edges)
+	}
+
+	// Depth 1 out of csv-report stops at csv-summary
+	if got := names(graph(report, 1, "out")); len(got) != 2 || got[1] != "csv-summary" {
+		t.Errorf("Depth 1 from csv-report = %v", got)
+	}
+
+	// Redeclaring csv-summary without depends_on drops that edge
+	declare(map[string]interface{}{"name": "csv-summary", "description": "Summarize CSV", "transforms": []string{"csv"}}, []Reference{{Type: "tool", Target: "csv-summary"}})
+	if got := names(graph("csv-parser", 2, "in")); len(got) != 1 {
+		t.Errorf("After dropping depends_on, csv-parser still has dependents %v", got)
+	}
+
+	// Deleting a tool removes the edges into it
+	if _, err := d.realityCompiler.DeleteRelationCascade(summary, false); err != nil {
+		t.Fatalf("Failed to delete csv-summary: %v", err)
+	}
+	if got := graph(report, 1, "both"); len(got.Edges) != 0 {
+		t.Errorf("Edges left after delete: %v", got.Edges)
+	}
+}
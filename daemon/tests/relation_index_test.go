@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRelationIndexStaysCurrent(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+
+	for _, relation := range []Relation{
+		{ID: "tool-b", Type: "Tool", Properties: map[string]interface{}{"name": "b"}},
+		{ID: "tool-a", Type: "Tool", Properties: map[string]interface{}{"name": "a"}},
+		{ID: "artifact-a", Type: "Artifact", Properties: map[string]interface{}{"name": "doc"}},
+	} {
+		if err := store.Save(relation); err != nil {
+			t.Fatalf("Save %s: %v", relation.ID, err)
+		}
+	}
+
+	ids := func(relations []Relation) []string {
+		var out []string
+		for _, relation := range relations {
+			out = append(out, relation.ID)
+		}
+		return out
+	}
+	expect := func(what string, got []Relation, want ...string) {
+		t.Helper()
+		if g := ids(got); len(g) != len(want) || (len(g) > 0 && !sameStrings(g, want)) {
+			t.Fatalf("%s = %v, want %v", what, g, want)
+		}
+	}
+
+	all, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	expect("List", all, "artifact-a", "tool-a", "tool-b")
+
+	tools, _ := store.ListByType("Tool")
+	expect("ListByType(Tool)", tools, "tool-a", "tool-b")
+
+	// Changing a returned relation must not change the index
+	tools[0].Properties["name"] = "changed"
+	tool, _ := store.ListByType("Tool")
+	if tool[0].Properties["name"] != "a" {
+		t.Fatalf("index modified through a returned relation: %v", tool[0].Properties["name"])
+	}
+
+	// Writes through the store are visible without rereading the directory
+	misses := store.ListCacheStats().Misses
+	if err := store.Update("tool-a", func(relation *Relation) error {
+		relation.Type = "Artifact"
+		return nil
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := store.Delete("tool-b"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	tools, _ = store.ListByType("Tool")
+	expect("ListByType(Tool) after update and delete", tools)
+	artifacts, _ := store.ListByType("Artifact")
+	expect("ListByType(Artifact) after update", artifacts, "artifact-a", "tool-a")
+	if got := store.ListCacheStats().Misses; got != misses {
+		t.Fatalf("store writes caused %d directory reads", got-misses)
+	}
+
+	// A file written by something else shows up once the directory changes
+	time.Sleep(10 * time.Millisecond)
+	data := []byte(`{"id":"tool-c","type":"Tool","properties":{"name":"c"}}`)
+	if err := os.WriteFile(filepath.Join(baseDir, "relations", "relation-tool-c.json"), data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	tools, _ = store.ListByType("Tool")
+	expect("ListByType(Tool) after an outside write", tools, "tool-c")
+}
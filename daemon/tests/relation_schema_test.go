@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"port42/daemon/validation"
+)
+
+// Tool properties declared in any of their accepted shapes must be stored,
+// and loaded, in one canonical form
+func TestToolPropertiesAreStoredCanonically(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{
+		storage:         storage,
+		realityCompiler: NewRealityCompiler(relationStore, []Materializer{&versionMaterializer{storage: storage}}),
+		auth:            loadAuthConfig(),
+		validator:       validation.NewRequestValidator(),
+	}
+
+	lastRun := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	wantTransforms := []string{"csv", "report"}
+	wantLastRun := lastRun.Format(time.RFC3339)
+
+	// Through declare_relation: a JSON array and a comma-separated string
+	declared := map[string]map[string]interface{}{
+		"array-tool": {
+			"transforms": []string{"csv", "report"},
+			"agent":      "@ai-analyst",
+			"last_run":   lastRun.Format(time.RFC3339Nano),
+		},
+		"string-tool": {
+			"transforms": "csv, report, csv",
+			"agent":      "ai-analyst",
+			"last_run":   lastRun.Unix(),
+		},
+	}
+	ids := make(map[string]string)
+	for name, properties := range declared {
+		properties["name"] = name
+		properties["description"] = "Summarize CSV files"
+		payload, _ := json.Marshal(map[string]interface{}{
+			"relation": map[string]interface{}{"type": "Tool", "properties": properties},
+		})
+		resp := d.handleDeclareRelation(Request{Type: "declare_relation", ID: "test", Payload: payload})
+		if !resp.Success {
+			t.Fatalf("Declare of %s failed: %s", name, resp.Error)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		ids[name], _ = data["relation_id"].(string)
+	}
+
+	// Saved directly: Go []string and []interface{} values
+	for name, transforms := range map[string]interface{}{
+		"slice-tool":     []string{"csv", "report"},
+		"interface-tool": []interface{}{"csv", "report", ""},
+	} {
+		relation := Relation{
+			ID:   "relation-" + name,
+			Type: "Tool",
+			Properties: map[string]interface{}{
+				"name":       name,
+				"transforms": transforms,
+				"agent":      "ai-analyst",
+				"last_run":   lastRun,
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := relationStore.Save(relation); err != nil {
+			t.Fatalf("Failed to save %s: %v", name, err)
+		}
+		ids[name] = relation.ID
+	}
+
+	for name, id := range ids {
+		// The file on disk
+		data, err := os.ReadFile(filepath.Join(baseDir, "relations", "relation-"+id+".json"))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		var stored struct {
+			Properties struct {
+				Transforms []string `json:"transforms"`
+				Agent      string   `json:"agent"`
+				LastRun    string   `json:"last_run"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(data, &stored); err != nil {
+			t.Fatalf("%s is not in canonical form: %v\n%s", name, err, data)
+		}
+		if !reflect.DeepEqual(stored.Properties.Transforms, wantTransforms) {
+			t.Errorf("%s: stored transforms %v, want %v", name, stored.Properties.Transforms, wantTransforms)
+		}
+		if stored.Properties.Agent != "@ai-analyst" {
+			t.Errorf("%s: stored agent %q, want @ai-analyst", name, stored.Properties.Agent)
+		}
+		if stored.Properties.LastRun != wantLastRun {
+			t.Errorf("%s: stored last_run %q, want %q", name, stored.Properties.LastRun, wantLastRun)
+		}
+
+		// What the store hands back
+		relation, err := relationStore.Load(id)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", name, err)
+		}
+		if transforms, ok := relation.Properties["transforms"].([]string); !ok || !reflect.DeepEqual(transforms, wantTransforms) {
+			t.Errorf("%s: loaded transforms %#v, want %v", name, relation.Properties["transforms"], wantTransforms)
+		}
+	}
+}
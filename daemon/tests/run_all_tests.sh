@@ -90,6 +90,9 @@ fi
 # Run all tests
 echo -e "\n${BLUE}Running tests...${NC}\n"
 
+# Source layout tests
+run_test "Single Daemon Package" "test_single_daemon_package.sh"
+
 # Basic connectivity tests
 run_test "TCP Connection" "test_tcp.sh"
 
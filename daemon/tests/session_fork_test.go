@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// fork_session copies the source's messages up to the index into a new
+// saved session that links back to it, leaving the source as it was
+func TestForkSession(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{storage: storage, sessions: make(map[string]*Session)}
+	source := &Session{
+		ID:    "source-session",
+		Agent: "@ai-engineer",
+		State: SessionActive,
+		Messages: []Message{
+			{Role: "user", Content: "build a csv parser"},
+			{Role: "assistant", Content: "here is csv-parser"},
+			{Role: "user", Content: "now make it stream"},
+		},
+	}
+	if err := storage.SaveSession(source); err != nil {
+		t.Fatalf("Failed to save source: %v", err)
+	}
+	fork := func(payload string) (SessionForkResult, Response) {
+		t.Helper()
+		resp := d.handleForkSession(Request{Type: "fork_session", ID: "test", Payload: json.RawMessage(payload)})
+		var result SessionForkResult
+		json.Unmarshal(resp.Data, &result)
+		return result, resp
+	}
+
+	result, resp := fork(`{"session_id": "source-session", "message_index": 2}`)
+	if !resp.Success {
+		t.Fatalf("fork_session failed: %s", resp.Error)
+	}
+	forked, err := storage.LoadSession(result.SessionID)
+	if err != nil {
+		t.Fatalf("Fork not saved: %v", err)
+	}
+	if len(forked.Messages) != 2 || forked.Messages[1].Content != "here is csv-parser" {
+		t.Errorf("Fork messages = %v, want the first two", forked.Messages)
+	}
+	if forked.ForkedFrom != "source-session" || forked.ForkPoint != 2 || forked.Agent != source.Agent {
+		t.Errorf("Fork links back to %q at %d as %q", forked.ForkedFrom, forked.ForkPoint, forked.Agent)
+	}
+	if original, _ := storage.LoadSession("source-session"); len(original.Messages) != 3 {
+		t.Errorf("Source changed to %d messages", len(original.Messages))
+	}
+
+	if result, _ := fork(`{"session_id": "source-session", "new_session_id": "whole-copy"}`); result.ForkPoint != 3 {
+		t.Errorf("Fork without message_index copied %d messages, want all 3", result.ForkPoint)
+	}
+	for _, payload := range []string{
+		`{"session_id": "source-session", "message_index": 4}`,
+		`{"session_id": "source-session", "new_session_id": "whole-copy"}`,
+		`{"session_id": "no-such-session"}`,
+	} {
+		if _, resp := fork(payload); resp.Success {
+			t.Errorf("fork_session %s succeeded", payload)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupSessionsByDate(t *testing.T) {
+	day := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	groups := groupSessionsByDate([]SessionSummary{
+		{ID: "a", Agent: "@ai-engineer", CreatedAt: day},
+		{ID: "b", Agent: "ai-muse", CreatedAt: day.Add(2 * time.Hour)},
+		{ID: "c", Agent: "@ai-engineer", CreatedAt: day.AddDate(0, 0, -3)},
+		{ID: "d", Agent: "@ai-engineer", CreatedAt: day.Add(time.Hour)},
+	})
+
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %+v", len(groups), groups)
+	}
+	first := groups[0]
+	if first.Date != "2025-03-10" || first.Path != "/memory/sessions/by-date/2025-03-10" || first.Count != 3 {
+		t.Errorf("first group = %s %s %d", first.Date, first.Path, first.Count)
+	}
+	if !sameStrings(first.Agents, []string{"@ai-engineer", "@ai-muse"}) {
+		t.Errorf("first group agents = %v", first.Agents)
+	}
+	if ids := []string{first.Sessions[0].ID, first.Sessions[1].ID, first.Sessions[2].ID}; !sameStrings(ids, []string{"b", "d", "a"}) {
+		t.Errorf("first group sessions = %v, want newest first", ids)
+	}
+	if groups[1].Date != "2025-03-07" || groups[1].Count != 1 {
+		t.Errorf("second group = %+v", groups[1])
+	}
+}
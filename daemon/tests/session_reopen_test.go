@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Completed and abandoned sessions stay closed unless reopen is passed,
+// whether they are in memory or only on disk, and loading one keeps its
+// last activity time
+func TestSessionReopen(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{storage: storage, sessions: make(map[string]*Session)}
+	lastActivity := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	saved := &Session{
+		ID:           "ended-session",
+		Agent:        "@ai-engineer",
+		CreatedAt:    lastActivity,
+		LastActivity: lastActivity,
+		State:        SessionAbandoned,
+		Messages:     []Message{{Role: "user", Content: "hello"}},
+	}
+	if err := storage.SaveSession(saved); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	if _, err := d.getOrCreateSession("ended-session", "@ai-engineer", false); !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("Continuing an abandoned session from disk: err = %v, want ErrSessionClosed", err)
+	}
+	if len(d.sessions) != 0 {
+		t.Errorf("Refused session was loaded into memory")
+	}
+
+	session, err := d.getOrCreateSession("ended-session", "@ai-engineer", true)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	if session.State != SessionActive || len(session.Messages) != 1 {
+		t.Errorf("Reopened session is %s with %d messages", session.State, len(session.Messages))
+	}
+	if !session.LastActivity.Equal(lastActivity) {
+		t.Errorf("LastActivity = %v, want the saved %v", session.LastActivity, lastActivity)
+	}
+
+	session.State = SessionCompleted
+	if _, err := d.getOrCreateSession("ended-session", "@ai-engineer", false); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Continuing a completed session in memory: err = %v, want ErrSessionClosed", err)
+	}
+	session.State = SessionIdle
+	if _, err := d.getOrCreateSession("ended-session", "@ai-engineer", false); err != nil || session.State != SessionActive {
+		t.Errorf("Idle session not reactivated: err = %v, state = %s", err, session.State)
+	}
+}
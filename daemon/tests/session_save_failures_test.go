@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// A failed session save leaves the session in UnsavedSessions, and status
+// reports it, until a later save succeeds
+func TestUnsavedSessions(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	session := &Session{
+		ID:       "flaky-session",
+		Agent:    "@ai-engineer",
+		State:    SessionActive,
+		Messages: []Message{{Role: "user", Content: "remember this"}},
+	}
+
+	// Metadata can't be written while its directory is a file
+	metadataDir := filepath.Join(baseDir, "metadata")
+	if err := os.Rename(metadataDir, metadataDir+".bak"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := os.WriteFile(metadataDir, nil, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		storage.saveQueuedSession(session)
+	}
+
+	unsaved := storage.UnsavedSessions()
+	if len(unsaved) != 1 || unsaved[0].SessionID != session.ID || unsaved[0].Attempts != 2 || unsaved[0].Error == "" {
+		t.Fatalf("unsaved = %+v, want one session with 2 attempts", unsaved)
+	}
+
+	d := &Daemon{storage: storage, sessions: make(map[string]*Session)}
+	var status StatusData
+	json.Unmarshal(d.handleStatus(Request{Type: "status", ID: "test"}).Data, &status)
+	if status.PersistenceOK || len(status.UnsavedSessions) != 1 {
+		t.Errorf("status = persistence_ok %v, %d unsaved; want false, 1", status.PersistenceOK, len(status.UnsavedSessions))
+	}
+
+	os.Remove(metadataDir)
+	if err := os.Rename(metadataDir+".bak", metadataDir); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := storage.SaveSession(session); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	if unsaved := storage.UnsavedSessions(); len(unsaved) != 0 {
+		t.Errorf("unsaved after a successful save = %+v", unsaved)
+	}
+	json.Unmarshal(d.handleStatus(Request{Type: "status", ID: "test"}).Data, &status)
+	if !status.PersistenceOK {
+		t.Errorf("persistence_ok false after a successful save")
+	}
+}
+
+func TestIsTransientWriteError(t *testing.T) {
+	transient := &os.PathError{Op: "write", Path: "x", Err: syscall.EINTR}
+	if !isTransientWriteError(transient) || !isTransientWriteError(fmt.Errorf("wrapped: %w", transient)) {
+		t.Errorf("EINTR not treated as transient")
+	}
+	for _, err := range []error{syscall.ENOSPC, syscall.EACCES, os.ErrNotExist} {
+		if isTransientWriteError(err) {
+			t.Errorf("%v treated as transient", err)
+		}
+	}
+}
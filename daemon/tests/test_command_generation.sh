@@ -10,7 +10,7 @@ BLUE='\033[0;34m'
 NC='\033[0m'
 
 # Change to daemon directory
-cd daemon
+cd daemon/src
 
 # Run command generation tests
 echo -e "${BLUE}Running command generation tests...${NC}"
@@ -36,7 +36,7 @@ fi
 
 # Build daemon to ensure everything compiles
 echo -e "\n${BLUE}Building daemon with updated command generation...${NC}"
-go build -o ../bin/port42d
+go build -o ../../bin/port42d
 
 if [ $? -eq 0 ]; then
     echo -e "${GREEN}✅ Daemon builds successfully!${NC}"
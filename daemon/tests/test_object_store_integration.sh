@@ -10,7 +10,7 @@ BLUE='\033[0;34m'
 NC='\033[0m'
 
 # Change to daemon directory
-cd daemon
+cd daemon/src
 
 # Run Go tests
 echo -e "${BLUE}Running object store tests...${NC}"
@@ -25,7 +25,7 @@ fi
 
 # Build daemon to ensure integration compiles
 echo -e "\n${BLUE}Building daemon with object store...${NC}"
-go build -o ../bin/port42d
+go build -o ../../bin/port42d
 
 if [ $? -eq 0 ]; then
     echo -e "${GREEN}✅ Daemon builds successfully with object store!${NC}"
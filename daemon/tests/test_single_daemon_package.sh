@@ -0,0 +1,42 @@
+#!/bin/bash
+# The daemon has one source of truth: the package in daemon/src.
+# Fails if Go sources reappear directly under daemon/ (stale copies of
+# main.go, server.go, storage.go drifted from daemon/src before) or if
+# daemon/src doesn't build on its own.
+
+# Colors
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+NC='\033[0m'
+
+# Get to repository root
+cd "$(dirname "$0")/../.."
+
+echo "🐬 Checking for a single daemon package..."
+
+STALE=$(find daemon -maxdepth 1 -name '*.go' | sort)
+if [ -n "$STALE" ]; then
+    echo -e "${RED}❌ Go sources found outside daemon/src:${NC}"
+    echo "$STALE" | sed 's/^/   - /'
+    echo "   Move any changes into daemon/src and delete these copies"
+    exit 1
+fi
+
+if [ ! -f daemon/src/main.go ]; then
+    echo -e "${RED}❌ daemon/src/main.go is missing${NC}"
+    exit 1
+fi
+
+MAINS=$(grep -l '^func main()' daemon/src/*.go)
+if [ "$(echo "$MAINS" | wc -l | tr -d ' ')" != "1" ]; then
+    echo -e "${RED}❌ Expected one main() in daemon/src, found:${NC}"
+    echo "$MAINS" | sed 's/^/   - /'
+    exit 1
+fi
+
+if ! (cd daemon/src && go build -o /dev/null .); then
+    echo -e "${RED}❌ daemon/src does not build${NC}"
+    exit 1
+fi
+
+echo -e "${GREEN}✅ daemon/src is the only daemon package${NC}"
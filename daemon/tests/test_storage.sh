@@ -7,10 +7,10 @@ echo "Running storage tests..."
 cd "$(dirname "$0")"
 
 # Copy test file to daemon directory temporarily
-cp storage_test.go ../daemon/storage_test.go
+cp storage_test.go ../src/storage_test.go
 
 # Run tests
-cd ../daemon
+cd ../src
 go test -v -run TestStorage
 
 # Clean up
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Tools sharing a name resolve to the most recently updated one, whatever
+// order the relations are listed in
+func TestDuplicateToolNamesResolveToNewest(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	now := time.Now()
+	for _, relation := range []Relation{
+		{ID: "tool-a-declared", Type: "Tool", UpdatedAt: now, CreatedAt: now.Add(-time.Hour)},
+		{ID: "tool-z-generated", Type: "Tool", UpdatedAt: now.Add(-time.Minute), CreatedAt: now.Add(-time.Minute)},
+		{ID: "tool-other", Type: "Tool", UpdatedAt: now, CreatedAt: now},
+	} {
+		name := "greet"
+		if relation.ID == "tool-other" {
+			name = "other"
+		}
+		relation.Properties = map[string]interface{}{"name": name}
+		if err := relationStore.Save(relation); err != nil {
+			t.Fatalf("Save %s: %v", relation.ID, err)
+		}
+	}
+
+	if got := storage.resolveToolsPath("/tools/greet/definition"); got != "relation:tool-a-declared" {
+		t.Errorf("/tools/greet/definition = %s, want relation:tool-a-declared", got)
+	}
+	_, resolution, err := storage.resolveTool("greet")
+	if err != nil {
+		t.Fatalf("resolveTool: %v", err)
+	}
+	if len(resolution.Candidates) != 2 || resolution.Candidates[0] != "tool-a-declared" || resolution.Reason == "" {
+		t.Errorf("resolution = %+v", resolution)
+	}
+	if _, resolution, _ := storage.resolveTool("other"); len(resolution.Candidates) != 0 {
+		t.Errorf("unique name reported candidates: %+v", resolution)
+	}
+
+	relations, _ := relationStore.List()
+	conflicts := toolNameConflicts(relations)
+	if len(conflicts) != 1 || !sameStrings(conflicts["Tool/greet"], []string{"tool-a-declared", "tool-z-generated"}) {
+		t.Errorf("conflicts = %v", conflicts)
+	}
+}
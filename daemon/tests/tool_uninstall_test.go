@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// uninstall_tool removes a tool's relation, spawned children with cascade,
+// symlink, paths and every version's executable, and reports each of them
+func TestUninstallTool(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{
+		storage:         storage,
+		realityCompiler: NewRealityCompiler(relationStore, nil),
+	}
+	d.realityCompiler.ruleEngine = nil
+
+	install := func(relation Relation, variants ...string) []string {
+		t.Helper()
+		name := getRelationName(relation)
+		var ids []string
+		for _, variant := range variants {
+			id, err := storage.StoreWithMetadata([]byte("#!/bin/sh\necho "+variant+"\n"), &Metadata{
+				Type:  "command",
+				Title: name,
+				Paths: []string{"/commands/" + name, "/tools/" + name + "/executable"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to store %s: %v", name, err)
+			}
+			recordToolVersion(&relation, id, "")
+			ids = append(ids, id)
+		}
+		if err := relationStore.Save(relation); err != nil {
+			t.Fatalf("Failed to save %s: %v", name, err)
+		}
+		if err := storage.CreateCommandSymlink(ids[len(ids)-1], name); err != nil {
+			t.Fatalf("Failed to link %s: %v", name, err)
+		}
+		return ids
+	}
+	uninstall := func(payload map[string]interface{}) (ToolUninstallResult, Response) {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		resp := d.handleUninstallTool(Request{Type: "uninstall_tool", ID: "test", Payload: data})
+		var result ToolUninstallResult
+		json.Unmarshal(resp.Data, &result)
+		return result, resp
+	}
+
+	parserIDs := install(Relation{ID: "tool-csv-parser", Type: "Tool", Properties: map[string]interface{}{"name": "csv-parser"}}, "v1", "v2")
+	install(Relation{ID: "tool-csv-parser-view", Type: "Tool", Properties: map[string]interface{}{"name": "csv-parser-view", "spawned_by": "tool-csv-parser"}}, "view")
+
+	result, resp := uninstall(map[string]interface{}{"name": "csv-parser", "cascade": true, "gc": true})
+	if !resp.Success {
+		t.Fatalf("uninstall_tool failed: %s", resp.Error)
+	}
+	if len(result.DeletedRelations) != 2 {
+		t.Errorf("Deleted relations = %v, want the tool and the one it spawned", result.DeletedRelations)
+	}
+	if len(result.RemovedSymlinks) != 2 || len(result.RemovedPaths) != 4 {
+		t.Errorf("Removed symlinks %v and paths %v", result.RemovedSymlinks, result.RemovedPaths)
+	}
+	if len(result.DeletedObjects) != 3 || result.FreedBytes == 0 {
+		t.Errorf("Deleted objects = %v (%d bytes), want both versions and the child's executable", result.DeletedObjects, result.FreedBytes)
+	}
+	for _, id := range parserIDs {
+		if storage.objects.Exists(id) {
+			t.Errorf("Version %s still stored", shortID(id))
+		}
+	}
+	for _, name := range []string{"csv-parser", "csv-parser-view"} {
+		if _, err := os.Lstat(filepath.Join(baseDir, "commands", name)); !os.IsNotExist(err) {
+			t.Errorf("Command %s still linked: %v", name, err)
+		}
+		if storage.ResolvePath("/commands/"+name) != "" {
+			t.Errorf("/commands/%s still resolves", name)
+		}
+	}
+
+	// Nothing left to uninstall
+	if _, resp := uninstall(map[string]interface{}{"name": "csv-parser"}); resp.Success {
+		t.Error("Second uninstall succeeded")
+	}
+	if _, resp := uninstall(map[string]interface{}{"name": "../csv-parser"}); resp.Success {
+		t.Error("Uninstall accepted a path as a name")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// cannedProvider answers every generation with the same response
+type cannedProvider struct{ response string }
+
+func (p *cannedProvider) Name() string    { return "canned" }
+func (p *cannedProvider) Available() bool { return true }
+func (p *cannedProvider) Generate(ctx context.Context, messages []Message, systemPrompt string, agentName string) (string, error) {
+	return p.response, nil
+}
+
+// A materialized tool whose generated code passed validation is marked
+// validated, so the audit no longer reports it as never checked
+func TestMaterializedToolIsValidated(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+	matStore, err := NewFileMaterializationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create materialization store: %v", err)
+	}
+
+	provider := &cannedProvider{response: "```json\n" +
+		`{"name": "hello-world", "description": "Says hello", "language": "bash", "implementation": "echo hello"}` +
+		"\n```"}
+	materializer, _ := NewToolMaterializer(provider, storage, matStore, nil)
+
+	relation := Relation{ID: "tool-hello-world", Type: "Tool", Properties: map[string]interface{}{"name": "hello-world"}}
+	if err := relationStore.Save(relation); err != nil {
+		t.Fatalf("Failed to save relation: %v", err)
+	}
+	if _, err := materializer.Materialize(relation); err != nil {
+		t.Fatalf("Materialize failed: %v", err)
+	}
+
+	stored, err := relationStore.Load(relation.ID)
+	if err != nil {
+		t.Fatalf("Failed to load relation: %v", err)
+	}
+	if validated, _ := stored.Properties[PropValidated].(bool); !validated {
+		t.Errorf("%s = %v, want true", PropValidated, stored.Properties[PropValidated])
+	}
+
+	report, err := storage.AuditTools()
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if report["tracking_available"] != true || len(report["never_checked"].([]ToolAuditEntry)) != 0 {
+		t.Errorf("audit = %+v, want tracking and no unchecked tools", report)
+	}
+}
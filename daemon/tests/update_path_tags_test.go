@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// add_tags and remove_tags edit single tags case-insensitively, and
+// concurrent edits to the same object all land
+func TestUpdatePathTagEdits(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	const path = "/artifacts/document/notes.md"
+	if _, err := storage.StoreWithMetadata([]byte("# Notes\n"), &Metadata{
+		Type:       "artifact",
+		Paths:      []string{path},
+		Tags:       []string{"Go", "draft"},
+		Importance: "medium",
+	}); err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+	update := func(updates map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		result, err := storage.HandleUpdatePath(path, nil, updates)
+		if err != nil {
+			t.Fatalf("update_path %v failed: %v", updates, err)
+		}
+		return result
+	}
+	tags := func() []string {
+		t.Helper()
+		meta, err := storage.LoadMetadata(storage.ResolvePath(path))
+		if err != nil {
+			t.Fatalf("Failed to load metadata: %v", err)
+		}
+		return meta.Tags
+	}
+
+	result := update(map[string]interface{}{
+		"add_tags":    []interface{}{"go", "notes"},
+		"remove_tags": []interface{}{"DRAFT"},
+		"importance":  "Medium",
+	})
+	if got := fmt.Sprint(tags()); got != "[Go notes]" {
+		t.Errorf("Tags = %s, want [Go notes]", got)
+	}
+	if got := fmt.Sprint(result["changed"]); got != "[tags]" {
+		t.Errorf("Changed = %s, want only tags", got)
+	}
+	if got := fmt.Sprint(update(map[string]interface{}{"add_tags": []interface{}{"NOTES"}})["changed"]); got != "[]" {
+		t.Errorf("Re-adding an existing tag changed %s", got)
+	}
+	if _, err := storage.HandleUpdatePath(path, nil, map[string]interface{}{"add_tags": "notes"}); err == nil {
+		t.Error("add_tags accepted a string")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := storage.HandleUpdatePath(path, nil, map[string]interface{}{"add_tags": []interface{}{fmt.Sprintf("tag-%d", i)}}); err != nil {
+				t.Errorf("Concurrent update_path failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := len(tags()); got != 22 {
+		t.Errorf("Got %d tags after concurrent adds, want 22: %v", got, tags())
+	}
+}
+
+// Flushing access times while tags are edited never puts back the copy of
+// the metadata the flush read
+func TestAccessFlushKeepsConcurrentEdits(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	const path = "/artifacts/document/notes.md"
+	id, err := storage.StoreWithMetadata([]byte("# Notes\n"), &Metadata{Type: "artifact", Paths: []string{path}})
+	if err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+
+	done := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				storage.TouchAccessed(id)
+				storage.FlushAccessTimes()
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := storage.HandleUpdatePath(path, nil, map[string]interface{}{"add_tags": []interface{}{fmt.Sprintf("tag-%d", i)}}); err != nil {
+				t.Errorf("Concurrent update_path failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(done)
+	<-flushed
+
+	meta, err := storage.LoadMetadata(id)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %v", err)
+	}
+	if len(meta.Tags) != 50 {
+		t.Errorf("Got %d tags after adds racing access flushes, want 50", len(meta.Tags))
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+// A chunked upload stores the same object store_path would, at the path
+// given to begin_upload, and checks offsets and the expected hash
+func TestChunkedUpload(t *testing.T) {
+	baseDir := t.TempDir()
+	relationStore, err := NewFileRelationStore(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create relation store: %v", err)
+	}
+	storage, err := NewStorage(baseDir, relationStore)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	d := &Daemon{storage: storage, uploads: newUploadManager(baseDir)}
+	send := func(handler func(Request) Response, payload interface{}) map[string]interface{} {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		resp := handler(Request{ID: "test", Payload: data})
+		if !resp.Success {
+			t.Fatalf("Request %s failed: %s", data, resp.Error)
+		}
+		var result map[string]interface{}
+		json.Unmarshal(resp.Data, &result)
+		return result
+	}
+	fails := func(handler func(Request) Response, payload interface{}) bool {
+		data, _ := json.Marshal(payload)
+		return !handler(Request{ID: "test", Payload: data}).Success
+	}
+
+	content := bytes.Repeat([]byte("frame-data-0123456789\n"), 20000)
+	hash := sha256.Sum256(content)
+	wantID := hex.EncodeToString(hash[:])
+
+	uploadID := send(d.handleBeginUpload, map[string]interface{}{"path": "/artifacts/media/big.txt"})["upload_id"].(string)
+	const chunkSize = 64 * 1024
+	for offset := 0; offset < len(content); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+		send(d.handleUploadChunk, map[string]interface{}{
+			"upload_id": uploadID,
+			"offset":    offset,
+			"data":      base64.StdEncoding.EncodeToString(content[offset:end]),
+		})
+	}
+	if !fails(d.handleUploadChunk, map[string]interface{}{"upload_id": uploadID, "offset": 0, "data": "eA=="}) {
+		t.Error("Chunk at a stale offset accepted")
+	}
+
+	result := send(d.handleCommitUpload, map[string]interface{}{"upload_id": uploadID, "sha256": wantID})
+	if result["id"] != wantID || int(result["size"].(float64)) != len(content) {
+		t.Errorf("Commit returned %v, want object %s of %d bytes", result, shortID(wantID), len(content))
+	}
+	if storage.ResolvePath("/artifacts/media/big.txt") != wantID {
+		t.Error("Upload not stored at its path")
+	}
+	stored, err := storage.Read(wantID)
+	if err != nil || !bytes.Equal(stored, content) {
+		t.Errorf("Stored content differs (%d bytes, %v)", len(stored), err)
+	}
+	if !fails(d.handleUploadChunk, map[string]interface{}{"upload_id": uploadID, "data": "eA=="}) {
+		t.Error("Chunk accepted after commit")
+	}
+
+	// A hash mismatch stores nothing
+	uploadID = send(d.handleBeginUpload, map[string]interface{}{"path": "/artifacts/media/other.txt"})["upload_id"].(string)
+	send(d.handleUploadChunk, map[string]interface{}{"upload_id": uploadID, "data": base64.StdEncoding.EncodeToString([]byte("other"))})
+	if !fails(d.handleCommitUpload, map[string]interface{}{"upload_id": uploadID, "sha256": wantID}) {
+		t.Error("Commit with the wrong sha256 succeeded")
+	}
+	if storage.ResolvePath("/artifacts/media/other.txt") != "" {
+		t.Error("Mismatched upload was stored")
+	}
+}